@@ -0,0 +1,158 @@
+// Built-in rule packs, and the accessibility pack's structural checks
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// builtinRulePacks are the rule packs the Packs setting can enable. Rules
+// in a pack are skipped when the config defines a rule of the same name.
+var builtinRulePacks = map[string][]Rule{
+    // accessibility failures and AI-consumption failures overlap: a color
+    // or a picture that carries meaning reaches neither a screen reader nor
+    // a retrieval pipeline
+    "accessibility": {
+        {
+            Name:        "color-only-reference",
+            Description: "Element identified only by its color",
+            Pattern:     `(?i)\b(?:(?:red|green|blue|yellow|orange|purple|pink|gr[ae]y|black|white)\s+(?:buttons?|icons?|links?|text|box(?:es)?|bars?|areas?|fields?|dots?|circles?|lights?|indicators?|labels?|banners?|arrows?|lines?|tabs?)|(?:shown|highlighted|marked|displayed|colored|coloured)\s+in\s+(?:red|green|blue|yellow|orange|purple|pink|gr[ae]y))\b`,
+            Suggestion:  "Name the element by its label or role; '${match}' means nothing to screen reader users or to text-only retrieval",
+            Severity:    "warning",
+            Type:        "suggest",
+        },
+        {
+            Name:        "image-alt-text",
+            Description: "Image with no alt text, or alt text that doesn't describe it",
+            Pattern:     `!\[\s*(?:(?i:image|screenshot|picture|photo|img|icon|graphic|diagram|figure)?\s*\d*|[^\]]*\.(?i:png|jpe?g|gif|svg|webp))\s*\]\(`,
+            Suggestion:  "Describe what the image shows, or what the reader should notice in it",
+            Severity:    "warning",
+            Type:        "suggest",
+        },
+        {
+            Name:        "non-descriptive-links",
+            Description: "Links with non-descriptive text",
+            Pattern:     `(?i)\[(?:here|click here|this|this page|this link|read more|more|learn more|link|see here|details)\]\([^)]+\)|\[https?://[^\]]+\]\([^)]+\)|<a\b[^>]*>\s*(?:here|click here|read more|more|learn more|link|details)\s*</a>`,
+            Suggestion:  "Use link text that names the destination, such as 'the CloudSync install guide'",
+            Severity:    "suggestion",
+            Type:        "suggest",
+        },
+    },
+    // troubleshooting articles answer a pasted error only when they quote
+    // it; its structural checks are in analyzeTroubleshooting
+    "troubleshooting": {
+        {
+            Name:        "troubleshooting-vague-symptom",
+            Description: "Symptom described without the error or behavior the reader sees",
+            Pattern:     `(?i)\b(?:(?:does|do|is|are)(?:n't| not) working|(?:does|do)(?:n't| not) work|something (?:goes|went) wrong|an error (?:occurs|appears|is (?:shown|displayed))|you (?:get|see|receive) an error)\b`,
+            Suggestion:  "Replace '${match}' with the exact error message or the behavior the reader observes",
+            Severity:    "warning",
+            Type:        "suggest",
+            PageTypes:   []string{"troubleshooting"},
+        },
+    },
+}
+
+// imgTagRegex matches an HTML img tag
+var imgTagRegex = regexp.MustCompile(`(?i)<img\b(?:"[^"]*"|'[^']*'|[^'">])*>`)
+
+// builtinRules returns the rules of the enabled packs that defined doesn't
+// already name
+func builtinRules(packs []string, defined []Rule) []Rule {
+    names := make(map[string]bool)
+    for _, rule := range defined {
+        names[rule.Name] = true
+    }
+    var rules []Rule
+    for _, pack := range packs {
+        for _, rule := range builtinRulePacks[pack] {
+            if !names[rule.Name] {
+                rules = append(rules, rule)
+            }
+        }
+    }
+    return rules
+}
+
+// packEnabled reports whether the Packs setting enables a built-in pack
+func (c *Config) packEnabled(name string) bool {
+    for _, pack := range c.Packs {
+        if pack == name {
+            return true
+        }
+    }
+    return false
+}
+
+// analyzeAccessibility runs the structural checks of the accessibility
+// pack: skipped heading levels, tables without header text and HTML
+// images without an alt attribute
+func (a *Analyzer) analyzeAccessibility(doc *Document) []Issue {
+    if !a.config.packEnabled("accessibility") {
+        return nil
+    }
+
+    var issues []Issue
+    previous := 0
+    for _, section := range doc.Sections {
+        if section.Level == 0 {
+            continue
+        }
+        if previous > 0 && section.Level > previous+1 {
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         section.Line,
+                Column:       1,
+                Rule:         "heading-order",
+                Message:      fmt.Sprintf("Heading level %d follows level %d, skipping a level", section.Level, previous),
+                Severity:     "warning",
+                Suggestion:   fmt.Sprintf("Use a level %d heading, so the outline that screen readers and chunkers follow stays intact", previous+1),
+                OriginalText: strings.TrimSpace(doc.Lines[section.Line-1]),
+            })
+        }
+        previous = section.Level
+    }
+
+    for i := doc.BodyStart - 1; i < len(doc.Lines); i++ {
+        if i < 0 || doc.Fenced[i] {
+            continue
+        }
+        line := doc.Lines[i]
+
+        if i > 0 && strings.Contains(line, "|") && tableDividerRegex.MatchString(line) && strings.Contains(doc.Lines[i-1], "|") {
+            header := strings.Trim(strings.TrimSpace(doc.Lines[i-1]), "|")
+            if strings.TrimSpace(strings.ReplaceAll(header, "|", "")) == "" {
+                issues = append(issues, Issue{
+                    File:         doc.Path,
+                    Line:         i,
+                    Column:       1,
+                    Rule:         "table-header",
+                    Message:      "Table header row is empty, so its columns have no names",
+                    Severity:     "warning",
+                    Suggestion:   "Name each column in the header row",
+                    OriginalText: strings.TrimSpace(doc.Lines[i-1]),
+                })
+            }
+        }
+
+        for _, match := range imgTagRegex.FindAllStringIndex(line, -1) {
+            tag := line[match[0]:match[1]]
+            if _, ok := htmlAttributes(tag[len("<img"):])["alt"]; ok {
+                continue // alt="" marks a decorative image
+            }
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         i + 1,
+                Column:       match[0] + 1,
+                Rule:         "image-alt-text",
+                Message:      "Image has no alt attribute",
+                Severity:     "warning",
+                Suggestion:   "Add alt text describing the image, or alt=\"\" if it is decorative",
+                OriginalText: tag,
+            })
+        }
+    }
+    return issues
+}
@@ -0,0 +1,199 @@
+// Admonition and callout recognition
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var (
+    mkdocsAdmonitionRegex     = regexp.MustCompile(`^(\s*)(?:!!!|\?\?\?\+?)\s+([\w-]+)`)
+    docusaurusAdmonitionRegex = regexp.MustCompile(`^\s*:::+\s*([\w-]+)`)
+    docusaurusCloseRegex      = regexp.MustCompile(`^\s*:::+\s*$`)
+    asideOpenRegex            = regexp.MustCompile(`(?i)<aside\b[^>]*>`)
+    asideCloseRegex           = regexp.MustCompile(`(?i)</aside\s*>`)
+    asideClassRegex           = regexp.MustCompile(`(?i)class\s*=\s*["']([\w-]+)`)
+    plainWarningRegex         = regexp.MustCompile(`(?i)^\s*(?:\*\*|__)?(?:warning|caution|danger|important)\s*[:!]`)
+)
+
+// criticalAdmonitionKinds are callout types whose content readers must not miss
+var criticalAdmonitionKinds = []string{"warning", "danger", "caution", "important", "attention", "error"}
+
+// Admonition is a callout block such as an MkDocs "!!! note", a Docusaurus
+// ":::warning" or an HTML <aside>
+type Admonition struct {
+    Kind      string
+    StartLine int // 1-based line of the opening marker
+    EndLine   int // 1-based last line of the block (inclusive)
+}
+
+// parseAdmonitions finds callout blocks outside code fences and masks their
+// markers so line rules don't match the syntax itself
+func parseAdmonitions(doc *Document) []Admonition {
+    var admonitions []Admonition
+    lines := doc.Lines
+
+    for i := 0; i < len(lines); i++ {
+        if doc.Fenced[i] {
+            continue
+        }
+        line := lines[i]
+
+        if match := mkdocsAdmonitionRegex.FindStringSubmatchIndex(line); match != nil {
+            end := indentedBlockEnd(lines, i)
+            doc.mask(i, match[0], match[1])
+            admonitions = append(admonitions, Admonition{
+                Kind:      strings.ToLower(line[match[4]:match[5]]),
+                StartLine: i + 1,
+                EndLine:   end + 1,
+            })
+            i = end
+            continue
+        }
+
+        if match := docusaurusAdmonitionRegex.FindStringSubmatchIndex(line); match != nil {
+            end := len(lines) - 1
+            for j := i + 1; j < len(lines); j++ {
+                if docusaurusCloseRegex.MatchString(lines[j]) {
+                    end = j
+                    doc.mask(j, 0, len(lines[j]))
+                    break
+                }
+            }
+            doc.mask(i, match[0], match[1])
+            admonitions = append(admonitions, Admonition{
+                Kind:      strings.ToLower(line[match[2]:match[3]]),
+                StartLine: i + 1,
+                EndLine:   end + 1,
+            })
+            i = end
+            continue
+        }
+
+        if match := asideOpenRegex.FindStringIndex(line); match != nil {
+            kind := "aside"
+            if class := asideClassRegex.FindStringSubmatch(line[match[0]:match[1]]); class != nil {
+                kind = strings.ToLower(class[1])
+            }
+            doc.mask(i, match[0], match[1])
+
+            end := len(lines) - 1
+            for j := i; j < len(lines); j++ {
+                from := 0
+                if j == i {
+                    from = match[1]
+                }
+                if closing := asideCloseRegex.FindStringIndex(lines[j][from:]); closing != nil {
+                    end = j
+                    doc.mask(j, from+closing[0], from+closing[1])
+                    break
+                }
+            }
+            admonitions = append(admonitions, Admonition{
+                Kind:      kind,
+                StartLine: i + 1,
+                EndLine:   end + 1,
+            })
+            i = end
+        }
+    }
+
+    return admonitions
+}
+
+// indentedBlockEnd returns the 0-based index of the last line of the block
+// opened at start, i.e. the following lines indented deeper than start.
+// Blank lines inside the block are included, trailing ones are not.
+func indentedBlockEnd(lines []string, start int) int {
+    indent := leadingWidth(lines[start])
+    end := start
+    for j := start + 1; j < len(lines); j++ {
+        if strings.TrimSpace(lines[j]) == "" {
+            continue
+        }
+        if leadingWidth(lines[j]) <= indent {
+            break
+        }
+        end = j
+    }
+    return end
+}
+
+// leadingWidth returns the indentation width of line, counting tabs as four spaces
+func leadingWidth(line string) int {
+    width := 0
+    for _, r := range line {
+        switch r {
+        case ' ':
+            width++
+        case '\t':
+            width += 4
+        default:
+            return width
+        }
+    }
+    return width
+}
+
+// InAdmonition reports whether the 1-based line falls inside a callout block
+func (d *Document) InAdmonition(lineNum int) bool {
+    for _, adm := range d.Admonitions {
+        if lineNum >= adm.StartLine && lineNum <= adm.EndLine {
+            return true
+        }
+    }
+    return false
+}
+
+// isCritical reports whether the callout type carries warnings readers must see
+func (adm Admonition) isCritical() bool {
+    for _, kind := range criticalAdmonitionKinds {
+        if adm.Kind == kind {
+            return true
+        }
+    }
+    return false
+}
+
+// analyzeAdmonitions flags critical warnings that only exist inside callouts,
+// which many HTML-to-text and Markdown converters strip or flatten away
+func (a *Analyzer) analyzeAdmonitions(doc *Document) []Issue {
+    var issues []Issue
+
+    for _, adm := range doc.Admonitions {
+        if !adm.isCritical() || a.hasPlainWarning(doc, adm) {
+            continue
+        }
+
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         adm.StartLine,
+            Column:       1,
+            Rule:         "callout-only-warning",
+            Message:      fmt.Sprintf("Critical '%s' content appears only inside a callout, which some converters strip", adm.Kind),
+            Severity:     "warning",
+            Suggestion:   "State the warning in the body text as well, e.g. with a plain 'Warning:' sentence",
+            OriginalText: strings.TrimSpace(doc.Lines[adm.StartLine-1]),
+        })
+    }
+
+    return issues
+}
+
+// hasPlainWarning reports whether the section containing the callout also
+// carries a plain-text warning outside of any callout
+func (a *Analyzer) hasPlainWarning(doc *Document, adm Admonition) bool {
+    for _, section := range doc.Sections {
+        if adm.StartLine < section.StartLine || adm.StartLine > section.EndLine {
+            continue
+        }
+        for lineNum := section.StartLine; lineNum <= section.EndLine; lineNum++ {
+            if !doc.InAdmonition(lineNum) && plainWarningRegex.MatchString(doc.Lines[lineNum-1]) {
+                return true
+            }
+        }
+    }
+    return false
+}
@@ -7,11 +7,13 @@ import (
     "encoding/json"
     "flag"
     "fmt"
-    "io/fs"
+    "io"
     "os"
     "path/filepath"
     "regexp"
+    "sort"
     "strings"
+    "time"
 //    "unicode"
 
     "gopkg.in/yaml.v3"
@@ -19,26 +21,80 @@ import (
 
 // Config represents the main configuration structure
 type Config struct {
-    StylesPath   string            `yaml:"StylesPath"`
-    MinWordCount int               `yaml:"MinWordCount"`
-    Formats      map[string]Format `yaml:"Formats"`
-    Rules        []Rule            `yaml:"Rules"`
+    StylesPath           string            `yaml:"StylesPath"`
+    Language             string            `yaml:"Language,omitempty"` // default document language, "en" if unset
+    MinWordCount         int               `yaml:"MinWordCount"`
+    MaxLinkDepth         int               `yaml:"MaxLinkDepth,omitempty"`
+    MinDescriptionLength int               `yaml:"MinDescriptionLength,omitempty"`
+    MinBoilerplateFiles  int               `yaml:"MinBoilerplateFiles,omitempty"` // files a paragraph must repeat in to count as boilerplate
+    Formats              map[string]Format `yaml:"Formats"`
+    FrontMatter          FrontMatterConfig `yaml:"FrontMatter,omitempty"`
+    SpellCheck           SpellCheckConfig  `yaml:"SpellCheck,omitempty"`
+    OpenAPI              OpenAPIConfig     `yaml:"OpenAPI,omitempty"`
+    CLI                  CLIConfig         `yaml:"CLI,omitempty"`
+    ValidateSnippets     []string          `yaml:"ValidateSnippets,omitempty"` // "json", "yaml", "shell", "go"
+    Severities           map[string]string `yaml:"Severities,omitempty"` // rule name -> severity, for any rule
+    PageTypes            map[string][]string `yaml:"PageTypes,omitempty"` // rule name -> page types it runs on, for any rule
+    Overrides            []PathOverride    `yaml:"Overrides,omitempty"`
+    ColumnUnit           string            `yaml:"ColumnUnit,omitempty"` // "rune" (default), "utf16", "byte"
+    Overlaps             string            `yaml:"Overlaps,omitempty"` // overlapping matches on a line: "all" (default), "highest-severity", "most-specific"
+    Formatters           map[string]string `yaml:"Formatters,omitempty"` // -output name -> external formatter command
+    DocsBaseURL          string            `yaml:"DocsBaseURL,omitempty"` // page explaining each rule: the rule name appended, or in place of {rule}
+    Embeddings           EmbeddingsConfig  `yaml:"Embeddings,omitempty"`
+    Jargon               JargonConfig      `yaml:"Jargon,omitempty"`
+    Cohesion             CohesionConfig    `yaml:"Cohesion,omitempty"`
+    Audience             AudienceConfig    `yaml:"Audience,omitempty"`
+    ReleaseNotes         ReleaseNotesConfig `yaml:"ReleaseNotes,omitempty"`
+    Headings             HeadingsConfig    `yaml:"Headings,omitempty"`
+    Remediation          RemediationConfig `yaml:"Remediation,omitempty"`
+    Includes             IncludesConfig    `yaml:"Includes,omitempty"`
+    Variables            VariablesConfig   `yaml:"Variables,omitempty"`
+    Webhook              WebhookConfig     `yaml:"Webhook,omitempty"`
+    Schedule             []ScheduledJob    `yaml:"Schedule,omitempty"` // runs serve starts periodically
+    Nav                  string            `yaml:"Nav,omitempty"`   // mkdocs.yml, sidebars.js, nav.adoc or SUMMARY.md giving reading order
+    Packs                []string          `yaml:"Packs,omitempty"` // built-in rule packs to enable: "accessibility", "troubleshooting"
+    MinRuleVersion       map[string]int    `yaml:"MinRuleVersion,omitempty"` // rule name -> Version the configuration was reviewed against
+    SeverityLevels       []SeverityLevel   `yaml:"SeverityLevels,omitempty"` // severities beyond error, warning and suggestion, and changes to those
+    Rules                []Rule            `yaml:"Rules"`
 }
 
 // Format defines file format configurations
 type Format struct {
     Extensions []string `yaml:"Extensions"`
     Parser     string   `yaml:"Parser"`
+    Templates  []string `yaml:"Templates,omitempty"` // template syntaxes to mask: "jinja", "liquid", "hugo"
 }
 
 // Rule defines transformation rules
 type Rule struct {
-    Name        string `yaml:"Name"`
-    Description string `yaml:"Description"`
-    Pattern     string `yaml:"Pattern"`
-    Replacement string `yaml:"Replacement,omitempty"`
-    Severity    string `yaml:"Severity"`
-    Type        string `yaml:"Type"` // "suggest", "error", "warning"
+    Name        string     `yaml:"Name"`
+    Description string     `yaml:"Description"`
+    Message     string     `yaml:"Message,omitempty"`     // message template for each issue, replacing Description
+    Pattern     string     `yaml:"Pattern"`
+    Replacement string     `yaml:"Replacement,omitempty"` // suggested replacement for each match; see expandTemplate
+    Suggestion  string     `yaml:"Suggestion,omitempty"`  // suggestion template, replacing the built-in one
+    Severity    string     `yaml:"Severity"`
+    Type        string     `yaml:"Type"`                 // "suggest", "error", "warning"
+    Scope       string     `yaml:"Scope,omitempty"`      // "" (all lines), "admonition", "body", "sentence"
+    Multiline   bool       `yaml:"Multiline,omitempty"`  // match Pattern against the whole document, with ^ and $ at line boundaries
+    Exceptions  []string   `yaml:"Exceptions,omitempty"` // literal text, or /regex/, that suppresses an overlapping match
+    Conditions  *Condition `yaml:"Conditions,omitempty"` // further constraints on the matched line
+    Languages   []string   `yaml:"Languages,omitempty"`  // document languages; default Language if empty, "*" for all
+    PageTypes   []string   `yaml:"PageTypes,omitempty"`  // "conceptual", "task", "reference", "troubleshooting"; all if empty
+    Fix         *RuleFix   `yaml:"Fix,omitempty"`        // mechanical replacement for each match, applied by -fix
+    Version     int        `yaml:"Version,omitempty"`    // revision of the rule's behavior, 1 if unset; raised when its matches change
+    Changed     string     `yaml:"Changed,omitempty"`    // what changed in this Version, for configurations on an earlier one
+    Deprecated  string     `yaml:"Deprecated,omitempty"` // why the rule is going away and what to use instead
+    Aliases     []string   `yaml:"Aliases,omitempty"`    // former names, still accepted in Severities, PageTypes, Overrides, MinRuleVersion and Remediation.Minutes
+
+    source string // file the rule is defined in, for diagnostics
+    line   int    // line of the rule's entry in source, 0 if unknown
+}
+
+// RuleFix describes how -fix rewrites a rule's matches
+type RuleFix struct {
+    Replace string `yaml:"Replace"`          // replacement text, a template as for Replacement
+    Safety  string `yaml:"Safety,omitempty"` // "safe" if the rewrite can't change meaning, else "review" (default)
 }
 
 // Issue represents a found issue in documentation
@@ -51,12 +107,36 @@ type Issue struct {
     Severity    string
     Suggestion  string
     OriginalText string
+    URL         string `json:",omitempty"` // web address of a document fetched from a remote source
+    Hits        int    `json:",omitempty"` // times the document was retrieved, from -search-log
+    Fix         *Fix   `json:",omitempty"` // edit -fix applies, for issues with a mechanical fix
+    DocsURL     string `json:",omitempty"` // page explaining the rule, from DocsBaseURL
+    Root        string `json:",omitempty"` // root of a -roots run the issue is in
+    Skip        string `json:",omitempty"` // why the path wasn't analyzed, for file-skipped and read-failure issues
+    Section     *IssueSection `json:"-"` // section of the document the issue falls in, as analyzed
 }
 
-// Analyzer handles document analysis
+// Analyzer handles document analysis. It is safe for concurrent use by
+// multiple goroutines: NewAnalyzer compiles rules and loads dictionaries and
+// specs up front, and nothing is modified once analysis starts. Only the
+// embedder is set after NewAnalyzer, by runAnalyze and loadRootAnalyzers,
+// before the first document is analyzed.
 type Analyzer struct {
-    config *Config
-    rules  []Rule
+    config    *Config
+    rules     []compiledRule
+    filter    *ruleFilter // picks the rules worth running on a line
+    spelling  *SpellChecker
+    api       *APISpec
+    cli       *CLIReference
+    embedder  *Embedder // set after NewAnalyzer for -semantic and -contradictions
+    glossary  map[string]bool
+    variables map[string]string // doc-site variable values, keyed by lowercased name
+    nav       *Nav              // site navigation, when configured
+    audience  *regexp.Regexp    // introductions naming one of the Audience.Roles, see declaresAudience
+
+    invalidRules []error          // rules checkRules skipped, which -strict-config makes fatal
+    severities   severityPolicy   // the configuration's severities
+    components   []*regexp.Regexp // the ReleaseNotes.Components, see namesComponent
 }
 
 // NewAnalyzer creates a new analyzer instance
@@ -66,9 +146,77 @@ func NewAnalyzer(configPath string) (*Analyzer, error) {
         return nil, fmt.Errorf("failed to load config: %w", err)
     }
 
+    spelling, err := newSpellChecker(config.SpellCheck)
+    if err != nil {
+        return nil, err
+    }
+
+    api, err := loadAPISpec(config.OpenAPI)
+    if err != nil {
+        return nil, err
+    }
+
+    cli, err := loadCLIReference(config.CLI)
+    if err != nil {
+        return nil, err
+    }
+
+    glossary, err := loadGlossary(config.Jargon)
+    if err != nil {
+        return nil, err
+    }
+
+    variables, err := loadVariables(config.Variables)
+    if err != nil {
+        return nil, err
+    }
+
+    nav, err := loadNav(config.Nav)
+    if err != nil {
+        return nil, err
+    }
+
+    if isRemote(config.StylesPath) {
+        if config.StylesPath, err = fetchRemoteStyles(config.StylesPath); err != nil {
+            return nil, fmt.Errorf("failed to fetch StylesPath: %w", err)
+        }
+    }
+    packs, err := loadRulePacks(config.StylesPath)
+    if err != nil {
+        return nil, err
+    }
+    rules := append(append([]Rule(nil), config.Rules...), packs...)
+    rules = append(rules, builtinRules(config.Packs, rules)...)
+    if err := config.validateRuleNames(rules); err != nil {
+        return nil, err
+    }
+    severities := config.severities()
+    valid, invalid := checkRules(rules, severities)
+    valid, outdated := config.checkRuleVersions(valid)
+    invalid = append(invalid, outdated...)
+    compiled, err := compileRules(valid)
+    if err != nil {
+        return nil, err
+    }
+    audience := defaultAudienceRegex
+    if len(config.Audience.Roles) > 0 {
+        audience = audienceRegex(config.Audience.Roles)
+    }
+
     return &Analyzer{
-        config: config,
-        rules:  config.Rules,
+        config:       config,
+        rules:        compiled,
+        invalidRules: invalid,
+        severities:   severities,
+        filter:       newRuleFilter(compiled),
+        spelling:     spelling,
+        api:          api,
+        cli:          cli,
+        glossary:     glossary,
+        variables:    variables,
+        nav:          nav,
+        audience:     audience,
+        components:   componentRegexes(config.ReleaseNotes.Components),
     }, nil
 }
 
@@ -78,19 +226,120 @@ func loadConfig(configPath string) (*Config, error) {
         return getDefaultConfig(), nil
     }
 
+    location := configPath
+    if isRemote(configPath) {
+        var err error
+        if configPath, err = fetchRemoteFile(configPath); err != nil {
+            return nil, err
+        }
+    }
+
     data, err := os.ReadFile(configPath)
     if err != nil {
         return nil, err
     }
 
+    if err := validateConfigSchema(location, data); err != nil {
+        return nil, err
+    }
     var config Config
     if err := yaml.Unmarshal(data, &config); err != nil {
         return nil, err
     }
+    setRuleLines(config.Rules, data)
+    for i := range config.Rules {
+        config.Rules[i].source = location
+    }
+    if config.Rules == nil {
+        // Without Rules, the built-in rules run, so Severities and
+        // Overrides can adjust them; Rules: [] turns them off
+        config.Rules = getDefaultConfig().Rules
+    }
+    if location != configPath {
+        if err := config.checkRemoteCommands(location); err != nil {
+            return nil, err
+        }
+        config.StylesPath = relativeToRemote(location, configPath, config.StylesPath)
+    } else if abs, err := filepath.Abs(filepath.Dir(configPath)); err == nil {
+        // Includes stay within the configuration's directory, and within
+        // the working directory for a remote or default configuration
+        config.Includes.root = abs
+    }
+    if err := config.validate(); err != nil {
+        return nil, err
+    }
 
     return &config, nil
 }
 
+// validate rejects settings that would otherwise be silently ignored
+func (c *Config) validate() error {
+    if !columnUnits[c.ColumnUnit] {
+        return fmt.Errorf("invalid ColumnUnit %q (want rune, utf16 or byte)", c.ColumnUnit)
+    }
+    if c.Overlaps != "" && !contains(overlapPolicies, c.Overlaps) {
+        return fmt.Errorf("invalid Overlaps %q (want %s)", c.Overlaps, strings.Join(overlapPolicies, ", "))
+    }
+    if err := c.validateFormats(); err != nil {
+        return err
+    }
+    if err := c.Includes.validate(); err != nil {
+        return err
+    }
+    if err := c.Headings.validate(); err != nil {
+        return err
+    }
+    if err := c.Remediation.validate(); err != nil {
+        return err
+    }
+    if err := c.Embeddings.validate(); err != nil {
+        return err
+    }
+    if err := c.validateSeverityLevels(); err != nil {
+        return err
+    }
+    if err := c.validateDocsBaseURL(); err != nil {
+        return err
+    }
+    severities := c.severities()
+    for _, rule := range sortedKeys(c.Severities) {
+        if severity := c.Severities[rule]; !severities.allows(severity) {
+            return fmt.Errorf("invalid severity %q for %s (want %s)", severity, rule, severities.names())
+        }
+    }
+    for _, pack := range c.Packs {
+        if _, ok := builtinRulePacks[pack]; !ok {
+            return fmt.Errorf("unknown rule pack %q (want %s)", pack, strings.Join(sortedKeys(builtinRulePacks), ", "))
+        }
+    }
+    for _, rule := range sortedKeys(c.PageTypes) {
+        if err := validatePageTypes(c.PageTypes[rule]); err != nil {
+            return fmt.Errorf("PageTypes for %s: %w", rule, err)
+        }
+    }
+    for _, rule := range sortedKeys(c.MinRuleVersion) {
+        if c.MinRuleVersion[rule] < 1 {
+            return fmt.Errorf("invalid MinRuleVersion %d for %s (want 1 or more)", c.MinRuleVersion[rule], rule)
+        }
+    }
+    for _, job := range c.Schedule {
+        if err := job.validate(); err != nil {
+            return err
+        }
+    }
+    for i, override := range c.Overrides {
+        if len(override.Paths) == 0 {
+            return fmt.Errorf("override %d has no Paths", i+1)
+        }
+        for _, rule := range sortedKeys(override.Severity) {
+            if severity := override.Severity[rule]; !severities.allows(severity) {
+                return fmt.Errorf("override %d: invalid severity %q for %s (want %s)", i+1, severity, rule, severities.names())
+            }
+        }
+    }
+    return nil
+}
+
 // getDefaultConfig returns default AI optimization rules
 func getDefaultConfig() *Config {
     return &Config{
@@ -100,10 +349,12 @@ func getDefaultConfig() *Config {
             "markdown": {
                 Extensions: []string{".md", ".markdown"},
                 Parser:     "markdown",
+                Templates:  []string{"jinja", "hugo"},
             },
             "html": {
                 Extensions: []string{".html", ".htm"},
                 Parser:     "html",
+                Templates:  []string{"jinja"},
             },
         },
         Rules: []Rule{
@@ -127,6 +378,7 @@ func getDefaultConfig() *Config {
                 Pattern:     `(?i)\b(?:simply|just|obviously|clearly|of course|naturally)\b`,
                 Severity:    "warning",
                 Type:        "suggest",
+                Exceptions:  []string{"just-in-time"},
             },
             {
                 Name:        "visual-dependency",
@@ -155,87 +407,405 @@ func getDefaultConfig() *Config {
 
 // AnalyzeFile analyzes a single file for AI optimization issues
 func (a *Analyzer) AnalyzeFile(filePath string) ([]Issue, error) {
-    content, err := os.ReadFile(filePath)
+    content, err := readDocument(filePath)
     if err != nil {
         return nil, err
     }
 
-    return a.analyzeContent(filePath, string(content)), nil
+    return a.analyzeContent(filePath, content), nil
 }
 
-// analyzeContent analyzes content string for issues
+// analyzeContent analyzes content string for issues, resolving its
+// includes first when configured
 func (a *Analyzer) analyzeContent(filePath, content string) []Issue {
+    return a.analyzeContentIncluding(filePath, content, a.config.Includes.enabled())
+}
+
+// analyzeContentIncluding analyzes content, resolving its includes only if
+// includes says to. Content that doesn't come from the file system, such
+// as a request to serve, never reads the files its directives name.
+func (a *Analyzer) analyzeContentIncluding(filePath, content string, includes bool) []Issue {
+    if isGraphQLSchema(filePath) {
+        return a.analyzeGraphQL(filePath, content)
+    }
+    if sourceLanguage(filePath) != "" {
+        return a.analyzeSourceComments(filePath, content)
+    }
+    if isCaptionFile(filePath) {
+        return a.analyzeCaptions(filePath, content)
+    }
+    if isEmailTemplate(filePath, content) {
+        return a.analyzeEmail(filePath, content)
+    }
+    if !includes {
+        return a.analyzeResolved(filePath, content)
+    }
+    resolved := a.config.Includes.resolve(filePath, content)
+    return resolved.attribute(a.analyzeResolved(filePath, strings.Join(resolved.lines, "\n")))
+}
+
+// analyzeResolved analyzes content whose includes, if any, are resolved
+func (a *Analyzer) analyzeResolved(filePath, content string) []Issue {
+    if tracing == nil {
+        return a.analyzeTimed(filePath, content, nil)
+    }
+
+    span := tracing.start(nil, "analyze file")
+    span.set("file.path", filePath)
+    timings := newAnalysisTimings()
+    issues := a.analyzeTimed(filePath, content, timings)
+    timings.record(span)
+    span.set("issues", len(issues))
+    span.finish()
+    return issues
+}
+
+// analyzeTimed runs every check on content, adding the time each stage
+// takes to timings unless it is nil
+func (a *Analyzer) analyzeTimed(filePath, content string, timings *analysisTimings) []Issue {
     var issues []Issue
-    lines := strings.Split(content, "\n")
+    start := time.Now()
+    var substituted *substitution
+    if len(a.variables) > 0 {
+        substituted = substituteVariables(filePath, a.config.documentFormat(filePath, content), content, a.variables)
+        content = substituted.content
+    }
+    doc := ParseDocument(filePath, content)
+    if substituted != nil {
+        doc.expanded = substituted.spans
+    }
+    if format, ok := a.config.formatFor(filePath); ok {
+        doc.useFormat(format)
+        doc.MaskTemplates(format.Templates)
+    }
+    if timings != nil {
+        timings.Parse += time.Since(start)
+    }
+    issues = append(issues, frontMatterFailure(doc)...)
 
-    for i, line := range lines {
+    lang := a.language(doc)
+    for i, line := range doc.Masked {
         lineNum := i + 1
-        issues = append(issues, a.analyzeLine(filePath, line, lineNum)...)
+        issues = append(issues, a.analyzeLine(doc, line, lineNum, lang, timings)...)
     }
+    issues = append(issues, a.analyzeSentences(doc, lang, timings)...)
+    issues = append(issues, a.analyzeMultiline(doc, lang, timings)...)
 
     // Additional content-level analysis
-    issues = append(issues, a.analyzeStructure(filePath, content)...)
+    for _, check := range a.checks(content, lang) {
+        if timings == nil {
+            issues = append(issues, check.run(doc)...)
+            continue
+        }
+        start := time.Now()
+        issues = append(issues, check.run(doc)...)
+        timings.Checks[check.name] += time.Since(start)
+    }
+    issues = a.filterPageTypes(issues, classifyPage(doc))
 
+    // Overlaps are found on the lines as analyzed, before the columns of
+    // expanded variables are restored
+    issues = a.resolveOverlaps(doc, a.applyOverrides(issues))
+    if substituted != nil {
+        issues = substituted.restore(doc, issues)
+    }
+    issues = a.convertColumns(doc, issues)
+    locateSections(issues, doc)
     return issues
 }
 
+// check is a built-in document-level analysis
+type check struct {
+    name string
+    run  func(doc *Document) []Issue
+}
+
+// checks returns the document-level analyses to run, in order
+func (a *Analyzer) checks(content, lang string) []check {
+    checks := []check{
+        {"structure", a.analyzeStructure},
+        {"admonitions", a.analyzeAdmonitions},
+        {"tabs", a.analyzeTabs},
+        {"platforms", a.analyzePlatforms},
+        {"front-matter", a.analyzeFrontMatter},
+        {"description", a.analyzeDescription},
+        {"page-metadata", a.analyzePageMetadata},
+        {"lead-paragraph", a.analyzeLeadParagraph},
+        {"heading-mismatch", a.analyzeHeadingMismatch},
+        {"cohesion", a.analyzeCohesion},
+        {"jargon", a.analyzeJargon},
+        {"faq", func(doc *Document) []Issue { return a.analyzeFAQ(doc, lang) }},
+    }
+    if lang == "en" {
+        // The pronoun, verb and label patterns are English
+        checks = append(checks,
+            check{"anaphora", a.analyzeAnaphora},
+            check{"pronoun-subject", a.analyzePronounSubjects},
+            check{"step-voice", a.analyzeStepVoice},
+            check{"step-completeness", a.analyzeStepCompleteness},
+            check{"prerequisites", a.analyzePrerequisites},
+            check{"error-reference", a.analyzeErrorReference},
+            check{"troubleshooting", a.analyzeTroubleshooting},
+            check{"vagueness", a.analyzeVagueness},
+            check{"list-items", a.analyzeListItems},
+            check{"parameters", a.analyzeParameters},
+            check{"deprecation", a.analyzeDeprecation},
+            check{"hardcoded-versions", a.analyzeHardcodedVersions},
+            check{"audience", a.analyzeAudience},
+            check{"release-notes", a.analyzeReleaseNotes},
+            check{"data-files", a.analyzeDataFiles},
+            check{"heading-case", a.analyzeHeadingCase},
+        )
+    }
+    return append(checks,
+        check{"diagrams", a.analyzeDiagrams},
+        check{"screenshot-procedures", a.analyzeScreenshotProcedures},
+        check{"step-numbering", a.analyzeStepNumbering},
+        check{"spelling", a.analyzeSpelling},
+        check{"markdown-syntax", a.analyzeMarkdownSyntax},
+        check{"placeholders", a.analyzeUnresolvedPlaceholders},
+        check{"raw-html", a.analyzeRawHTML},
+        check{"html-conversion", a.analyzeHTMLConversion},
+        check{"accessibility", a.analyzeAccessibility},
+        check{"api-references", a.analyzeAPIReferences},
+        check{"cli-references", a.analyzeCLIReferences},
+        check{"snippets", a.analyzeSnippets},
+        check{"command-safety", a.analyzeCommandSafety},
+    )
+}
+
 // analyzeLine analyzes a single line for issues
-func (a *Analyzer) analyzeLine(filePath, line string, lineNum int) []Issue {
+func (a *Analyzer) analyzeLine(doc *Document, line string, lineNum int, lang string, timings *analysisTimings) []Issue {
     var issues []Issue
+    inAdmonition := doc.InAdmonition(lineNum)
+    run := a.filter.candidates(line)
+    if timings != nil {
+        timings.Lines++
+    }
 
-    for _, rule := range a.rules {
-        regex, err := regexp.Compile(rule.Pattern)
-        if err != nil {
+    for i, rule := range a.rules {
+        if rule.Scope == "sentence" || rule.Multiline || !run[i] {
+            continue // matched by analyzeSentences or analyzeMultiline, or can't match
+        }
+        if timings == nil {
+            issues = append(issues, a.matchRule(doc, rule, line, lineNum, inAdmonition, lang)...)
             continue
         }
+        start := time.Now()
+        issues = append(issues, a.matchRule(doc, rule, line, lineNum, inAdmonition, lang)...)
+        timings.Rules[rule.Name] += time.Since(start)
+        timings.RuleLines[rule.Name]++
+    }
+
+    return issues
+}
+
+// analyzeSentences runs the rules scoped to sentences over each sentence
+// of the document's prose, so their patterns match across wrapped lines.
+// Issues are reported where the match starts in the file; a fix is kept
+// only when the match lies on one line as written.
+func (a *Analyzer) analyzeSentences(doc *Document, lang string, timings *analysisTimings) []Issue {
+    var rules []int
+    for i, rule := range a.rules {
+        if rule.Scope == "sentence" {
+            rules = append(rules, i)
+        }
+    }
+    if len(rules) == 0 {
+        return nil
+    }
 
-        matches := regex.FindAllStringSubmatchIndex(line, -1)
-        for _, match := range matches {
-            if len(match) >= 2 {
-                matchText := line[match[0]:match[1]]
-                issue := Issue{
-                    File:         filePath,
-                    Line:         lineNum,
-                    Column:       match[0] + 1,
-                    Rule:         rule.Name,
-                    Message:      a.generateMessage(rule, matchText),
-                    Severity:     rule.Severity,
-                    Suggestion:   a.generateSuggestion(rule, matchText, line),
-                    OriginalText: matchText,
+    var issues []Issue
+    for _, paragraph := range doc.Paragraphs() {
+        for _, sentence := range paragraph.Sentences() {
+            inAdmonition := doc.InAdmonition(sentence.Line)
+            run := a.filter.candidates(sentence.Text)
+            for _, i := range rules {
+                if !run[i] {
+                    continue
+                }
+                rule := a.rules[i]
+                start := time.Now()
+                for _, issue := range a.matchRule(doc, rule, sentence.Text, sentence.Line, inAdmonition, lang) {
+                    issue.Line, issue.Column = paragraph.Position(sentence, issue.Column-1)
+                    if issue.Fix != nil {
+                        line := doc.Masked[issue.Line-1]
+                        if end := issue.Column - 1 + len(issue.OriginalText); end > len(line) || line[issue.Column-1:end] != issue.OriginalText {
+                            issue.Fix = nil
+                        } else {
+                            issue.Fix.Line, issue.Fix.Column = issue.Line, issue.Column
+                        }
+                    }
+                    issues = append(issues, issue)
+                }
+                if timings != nil {
+                    timings.Rules[rule.Name] += time.Since(start)
                 }
-                issues = append(issues, issue)
             }
         }
     }
+    return issues
+}
+
+// matchRule returns the issues one pattern rule finds in a line
+func (a *Analyzer) matchRule(doc *Document, rule compiledRule, line string, lineNum int, inAdmonition bool, lang string) []Issue {
+    if !rule.appliesTo(inAdmonition) || !rule.appliesToLanguage(lang, a.defaultLanguage()) {
+        return nil
+    }
+    if rule.conditions != nil && !rule.conditions.holds(doc, lineNum) {
+        return nil
+    }
+
+    var issues []Issue
+    matches := rule.pattern.FindAllStringSubmatchIndex(line, -1)
+    for _, match := range matches {
+        if len(match) >= 2 && !rule.excepted(line, match[0], match[1]) {
+            issues = append(issues, a.ruleIssue(doc, rule, line, lineNum, 0, match))
+        }
+    }
+    return issues
+}
+
+// ruleIssue builds the issue of one match of a pattern rule in text, whose
+// line lineNum starts at byte lineStart
+func (a *Analyzer) ruleIssue(doc *Document, rule compiledRule, text string, lineNum, lineStart int, match []int) Issue {
+    matchText := text[match[0]:match[1]]
+    column := match[0] - lineStart + 1
+    issue := Issue{
+        File:         doc.Path,
+        Line:         lineNum,
+        Column:       column,
+        Rule:         rule.Name,
+        Message:      a.generateMessage(rule.Rule, matchText),
+        Severity:     rule.Severity,
+        Suggestion:   a.generateSuggestion(rule.Rule, matchText, text),
+        OriginalText: matchText,
+    }
+    if rule.Message != "" {
+        issue.Message = a.expandTemplate(rule.Message, doc, rule, text, lineNum, match)
+    }
+    switch {
+    case rule.Suggestion != "":
+        issue.Suggestion = a.expandTemplate(rule.Suggestion, doc, rule, text, lineNum, match)
+    case rule.Replacement != "":
+        issue.Suggestion = fmt.Sprintf("Replace '%s' with '%s'", matchText, a.expandTemplate(rule.Replacement, doc, rule, text, lineNum, match))
+    }
+    // Fixes rewrite within one line
+    if rule.Fix != nil && !strings.Contains(matchText, "\n") {
+        issue.Fix = &Fix{
+            Line:    lineNum,
+            Column:  column,
+            Length:  match[1] - match[0],
+            Replace: a.expandTemplate(rule.Fix.Replace, doc, rule, text, lineNum, match),
+            Safe:    rule.Fix.Safety == "safe",
+        }
+    }
+    if rule.Name == "contextual-dependency" && rule.Suggestion == "" && rule.Replacement == "" {
+        if reference, referent := doc.contextualReferent(lineNum, column, matchText); referent != "" {
+            issue.Suggestion = fmt.Sprintf("Replace '%s' with '%s'", reference, referent)
+        }
+    }
+    if rule.Name == "generic-headings" && rule.Suggestion == "" && rule.Replacement == "" {
+        if heading := a.suggestHeading(doc, lineNum); heading != "" {
+            issue.Suggestion = fmt.Sprintf("Add product/feature name to heading, e.g. '%s'", heading)
+        }
+    }
+    return issue
+}
 
+// analyzeMultiline runs the Multiline rules over the whole document, so
+// their patterns can span lines, as for a fence that is never closed or a
+// table right under its heading. Issues are reported where the match
+// starts, and the rule's scope and conditions apply to that line.
+func (a *Analyzer) analyzeMultiline(doc *Document, lang string, timings *analysisTimings) []Issue {
+    var rules []int
+    for i, rule := range a.rules {
+        if rule.Multiline {
+            rules = append(rules, i)
+        }
+    }
+    if len(rules) == 0 {
+        return nil
+    }
+
+    text := strings.Join(doc.Masked, "\n")
+    starts := make([]int, len(doc.Masked))
+    for i := 1; i < len(starts); i++ {
+        starts[i] = starts[i-1] + len(doc.Masked[i-1]) + 1
+    }
+    run := a.filter.candidates(text)
+    var issues []Issue
+    for _, i := range rules {
+        rule := a.rules[i]
+        if !run[i] || !rule.appliesToLanguage(lang, a.defaultLanguage()) {
+            continue
+        }
+        start := time.Now()
+        for _, match := range rule.pattern.FindAllStringSubmatchIndex(text, -1) {
+            lineNum := max(sort.SearchInts(starts, match[0]+1), 1) // lines starting at or before the match
+            if !rule.appliesTo(doc.InAdmonition(lineNum)) || rule.excepted(text, match[0], match[1]) {
+                continue
+            }
+            if rule.conditions != nil && !rule.conditions.holds(doc, lineNum) {
+                continue
+            }
+            issues = append(issues, a.ruleIssue(doc, rule, text, lineNum, starts[lineNum-1], match))
+        }
+        if timings != nil {
+            timings.Rules[rule.Name] += time.Since(start)
+        }
+    }
     return issues
 }
 
-// analyzeStructure performs document-level structural analysis
-func (a *Analyzer) analyzeStructure(filePath, content string) []Issue {
+// exceptionRegex compiles one Exceptions entry: "/.../" is a regular
+// expression, anything else is literal text matched case-insensitively
+func exceptionRegex(exception string) (*regexp.Regexp, error) {
+    if len(exception) > 2 && strings.HasPrefix(exception, "/") && strings.HasSuffix(exception, "/") {
+        return regexp.Compile(exception[1 : len(exception)-1])
+    }
+    return regexp.Compile(`(?i)` + regexp.QuoteMeta(exception))
+}
+
+// appliesTo reports whether the rule's scope covers a line, given whether
+// the line sits inside an admonition
+func (r Rule) appliesTo(inAdmonition bool) bool {
+    switch r.Scope {
+    case "admonition":
+        return inAdmonition
+    case "body":
+        return !inAdmonition
+    default:
+        return true
+    }
+}
+
+// analyzeStructure performs document-level structural analysis, reporting
+// each heading at the position the parser found it, so repeated headings
+// are told apart
+func (a *Analyzer) analyzeStructure(doc *Document) []Issue {
     var issues []Issue
 
     // Check for missing product context in headings
-    headingRegex := regexp.MustCompile(`(?m)^#{1,6}\s+(.+)$`)
-    headings := headingRegex.FindAllStringSubmatch(content, -1)
+    productNames := a.extractProductNames(doc.Content)
 
-    productNames := a.extractProductNames(content)
-    
-    for _, heading := range headings {
-        if len(heading) > 1 {
-            headingText := heading[1]
-            if a.isGenericHeading(headingText) && !a.containsProductContext(headingText, productNames) {
-                issues = append(issues, Issue{
-                    File:     filePath,
-                    Line:     a.findLineNumber(content, heading[0]),
-                    Rule:     "missing-product-context",
-                    Message:  "Heading lacks product-specific context",
-                    Severity: "suggestion",
-                    Suggestion: fmt.Sprintf("Consider adding product name: '%s %s'", 
-                        a.inferProductName(productNames), headingText),
-                    OriginalText: headingText,
-                })
-            }
+    for _, section := range doc.Sections {
+        if section.Line == 0 {
+            continue
+        }
+        headingText := section.Heading
+        if a.isGenericHeading(headingText) && !a.containsProductContext(headingText, productNames) {
+            issues = append(issues, Issue{
+                File:     doc.Path,
+                Line:     section.Line,
+                Column:   section.Column,
+                Rule:     "missing-product-context",
+                Message:  "Heading lacks product-specific context",
+                Severity: "suggestion",
+                Suggestion: fmt.Sprintf("Consider adding product name: '%s %s'",
+                    a.inferProductName(productNames), headingText),
+                OriginalText: headingText,
+            })
         }
     }
 
@@ -281,36 +851,11 @@ func (a *Analyzer) generateSuggestion(rule Rule, matchText, fullLine string) str
 }
 
 // Helper functions
-func (a *Analyzer) extractProductNames(content string) []string {
-    // Simple heuristic to find potential product names
-    // Look for capitalized words that appear frequently
-    words := regexp.MustCompile(`\b[A-Z][a-zA-Z]+\b`).FindAllString(content, -1)
-    frequency := make(map[string]int)
-    
-    for _, word := range words {
-        if len(word) > 3 && !a.isCommonWord(word) {
-            frequency[word]++
-        }
-    }
-
-    var products []string
-    for word, count := range frequency {
-        if count >= 3 { // Appears at least 3 times
-            products = append(products, word)
-        }
-    }
-
-    return products
-}
 
-func (a *Analyzer) isCommonWord(word string) bool {
-    commonWords := []string{"The", "This", "That", "With", "From", "Your", "When", "Where", "What", "How"}
-    for _, common := range commonWords {
-        if word == common {
-            return true
-        }
-    }
-    return false
+// extractProductNames returns the configured product variables and the
+// product names the content uses, most frequent first
+func (a *Analyzer) extractProductNames(content string) []string {
+    return append(a.productVariables(), productNames(content)...)
 }
 
 func (a *Analyzer) isGenericHeading(heading string) bool {
@@ -341,51 +886,53 @@ func (a *Analyzer) inferProductName(products []string) string {
     return "[PRODUCT_NAME]"
 }
 
-func (a *Analyzer) findLineNumber(content, target string) int {
-    lines := strings.Split(content, "\n")
-    for i, line := range lines {
-        if strings.Contains(line, target) {
-            return i + 1
-        }
-    }
-    return 1
-}
-
 // Output formatting
-func printIssues(issues []Issue, format string) {
-    switch format {
-    case "json":
-        printJSONIssues(issues)
-    default:
-        printStandardIssues(issues)
-    }
-}
-
-func printStandardIssues(issues []Issue) {
+func printStandardIssues(w io.Writer, issues []Issue) error {
     for _, issue := range issues {
         severity := strings.ToUpper(issue.Severity)
-        fmt.Printf("%s:%d:%d: %s [%s] %s\n",
+        fmt.Fprintf(w, "%s:%d:%d: %s [%s] %s\n",
             issue.File, issue.Line, issue.Column, severity, issue.Rule, issue.Message)
         
         if issue.Suggestion != "" {
-            fmt.Printf("    Suggestion: %s\n", issue.Suggestion)
+            fmt.Fprintf(w, "    Suggestion: %s\n", issue.Suggestion)
+        }
+        if issue.Fix != nil {
+            fmt.Fprintf(w, "    Fix: %s\n", issue.Fix.describe())
+        }
+        if issue.URL != "" {
+            fmt.Fprintf(w, "    URL: %s\n", issue.URL)
+        }
+        if issue.Hits > 0 {
+            fmt.Fprintf(w, "    Retrieved: %d times\n", issue.Hits)
+        }
+        if issue.DocsURL != "" {
+            fmt.Fprintf(w, "    Docs: %s\n", issue.DocsURL)
+        }
+        if issue.Root != "" {
+            fmt.Fprintf(w, "    Root: %s\n", issue.Root)
+        }
+        if _, err := fmt.Fprintln(w); err != nil {
+            return err
         }
-        fmt.Println()
     }
+    return nil
 }
 
-func printJSONIssues(issues []Issue) {
+func printJSONIssues(w io.Writer, issues []Issue) error {
     // Create a structured output format similar to other linters
     output := struct {
-        Version string  `json:"version"`
-        Issues  []Issue `json:"issues"`
+        Version string       `json:"version"`
+        Run     *RunMetadata `json:"run,omitempty"`
+        Issues  []Issue      `json:"issues"`
         Summary struct {
             Total    int            `json:"total"`
             BySeverity map[string]int `json:"by_severity"`
             ByRule   map[string]int `json:"by_rule"`
+            ByRoot   map[string]int `json:"by_root,omitempty"`
         } `json:"summary"`
     }{
-        Version: "1.0.0",
+        Version: toolVersion,
+        Run:     currentRun,
         Issues:  issues,
     }
 
@@ -397,126 +944,415 @@ func printJSONIssues(issues []Issue) {
     for _, issue := range issues {
         output.Summary.BySeverity[issue.Severity]++
         output.Summary.ByRule[issue.Rule]++
+        if issue.Root != "" {
+            if output.Summary.ByRoot == nil {
+                output.Summary.ByRoot = make(map[string]int)
+            }
+            output.Summary.ByRoot[issue.Root]++
+        }
     }
 
     // Pretty print JSON
-    encoder := json.NewEncoder(os.Stdout)
+    encoder := json.NewEncoder(w)
     encoder.SetIndent("", "  ")
     if err := encoder.Encode(output); err != nil {
-        fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+        return fmt.Errorf("failed to encode JSON: %w", err)
     }
+    return nil
 }
 
 // CLI interface
 func main() {
+    if len(os.Args) > 1 {
+        if os.Args[1] == "help" {
+            os.Exit(runHelp(os.Args[2:]))
+        }
+        if command, ok := lookupCommand(os.Args[1]); ok {
+            os.Exit(command.run(os.Args[2:]))
+        }
+    }
+    // Without a command, the arguments are those of analyze
+    os.Exit(runAnalyze(os.Args[1:]))
+}
+
+// runAnalyze implements the analyze subcommand, the default
+func runAnalyze(args []string) int {
+    flags := flag.NewFlagSet("analyze", flag.ExitOnError)
     var (
-        configPath = flag.String("config", "", "Path to configuration file")
-        outputFormat = flag.String("output", "standard", "Output format (standard, json)")
-        fix = flag.Bool("fix", false, "Attempt to automatically fix issues")
-        recursive = flag.Bool("recursive", false, "Process directories recursively")
+        configPath = flags.String("config", "", "Path or HTTPS/git URL of configuration file")
+        outputFormat = flags.String("output", "standard", "Output format (standard, short, json, sections, sarif, codequality, or a configured or external formatter)")
+        fix = flags.Bool("fix", false, "Apply available fixes to local files and report only the remaining issues")
+        safeOnly = flags.Bool("safe-only", false, "With -fix, apply only the fixes marked safe")
+        patchDir = flags.String("emit-patches", "", "Write the safe fixes to this directory as one patch per file, for review, instead of applying them")
+        recursive = flags.Bool("recursive", false, "Process directories recursively")
+        followSymlinks = flags.Bool("follow-symlinks", false, "Descend into symlinked directories during recursive walks")
+        linkGraph = flags.Bool("link-graph", false, "Check cross-file links for orphan and hard-to-reach pages")
+        contradictions = flags.Bool("contradictions", false, "Report sentences in different sections that may contradict each other, using the configured embedding model")
+        mmap = flags.Bool("mmap", false, "Memory-map files for the cross-file checks instead of reading them into memory")
+        timeout = flags.Duration("timeout-per-file", 30*time.Second, "Skip files whose analysis takes longer than this (0 for no limit)")
+        maxSize = flags.String("max-file-size", "10MB", "Skip files larger than this (0 for no limit)")
+        maxPerRule = flags.Int("max-issues-per-rule", 0, "Report at most this many issues of one rule in one file, summarizing the rest (0 for no limit)")
+        maxPerFile = flags.Int("max-issues-per-file", 0, "Report at most this many issues in one file, summarizing the rest (0 for no limit)")
+        cpuProfile = flags.String("cpuprofile", "", "Write a CPU profile to this file")
+        memProfile = flags.String("memprofile", "", "Write a heap profile to this file")
+        onlyNew = flags.Bool("only-new", false, "Report only issues that are not present at the -base revision")
+        base = flags.String("base", "origin/main", "Git revision -only-new compares against")
+        semantic = flags.Bool("semantic", false, "Use the configured embedding model for semantic checks")
+        maxCost = flags.Float64("max-cost", 0, "Most to spend on embeddings in this run, in US dollars (0 for no limit)")
+        searchLog = flags.String("search-log", "", "Search or chat query log (CSV or JSON); issues on the most-retrieved pages are listed first")
+        strictConfig = flags.Bool("strict-config", false, "Fail instead of skipping rules that are invalid")
+        dbPath = flags.String("db", "", "Record the run and its issues in this SQLite results database, using the sqlite3 command")
+        forceFormat = flags.String("force-format", "", "Parse every file as this format (markdown, html or rst), whatever its extension or content")
+        skipReport = flags.String("skip-report", "", "Write the paths that were skipped or couldn't be read to this file, one per line with the reason")
+        repoManifest = flags.String("repos", "", "Analyze the git repositories and directories this manifest lists, cloning them shallowly, each with its own configuration, instead of paths")
+        budgetPath = flags.String("budget-file", "", "Fail only when a directory this budget file lists has more issues of a severity than its budget allows")
+        ratchetBudgets = flags.Bool("ratchet-budgets", false, "Lower the -budget-file budgets a run stays under to its counts, so cleaned-up directories can't regress")
+        multiRoot = flags.Bool("roots", false, "Analyze each path as a project root, with the .ai-doc-optimizer.yml nearest it (or -config), labeling its issues; name a root with name=path")
     )
-    flag.Parse()
+    flags.Parse(args)
 
-    if len(flag.Args()) == 0 {
-        fmt.Fprintf(os.Stderr, "Usage: %s [options] <file_or_directory>\n", os.Args[0])
-        flag.PrintDefaults()
-        os.Exit(1)
+    if len(flags.Args()) == 0 && *repoManifest == "" {
+        fmt.Fprintf(os.Stderr, "Usage: %s [analyze] [options] <file_or_directory>\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+    if *mmap && *fix {
+        // Rewriting a mapped file in place changes, or truncates, the
+        // memory the cross-file issues point into
+        fmt.Fprintf(os.Stderr, "Error: -mmap can't be combined with -fix, which rewrites the mapped files\n")
+        return 1
+    }
+    if *fix && *patchDir != "" {
+        fmt.Fprintf(os.Stderr, "Error: -emit-patches can't be combined with -fix, which applies the fixes it would write\n")
+        return 1
+    }
+    if *multiRoot && *onlyNew {
+        fmt.Fprintf(os.Stderr, "Error: -roots can't be combined with -only-new\n")
+        return 1
+    }
+    if *repoManifest != "" {
+        switch {
+        case len(flags.Args()) > 0:
+            fmt.Fprintf(os.Stderr, "Error: -repos replaces the paths to analyze\n")
+            return 1
+        case *multiRoot || *onlyNew || *fix || *patchDir != "":
+            // The repositories are cache checkouts, not the working tree
+            fmt.Fprintf(os.Stderr, "Error: -repos can't be combined with -roots, -only-new, -fix or -emit-patches\n")
+            return 1
+        }
+    }
+    switch {
+    case *ratchetBudgets && *budgetPath == "":
+        fmt.Fprintf(os.Stderr, "Error: -ratchet-budgets needs a -budget-file\n")
+        return 1
+    case *ratchetBudgets && *onlyNew:
+        // The new issues alone would lower every budget to almost nothing
+        fmt.Fprintf(os.Stderr, "Error: -ratchet-budgets can't be combined with -only-new\n")
+        return 1
     }
+    if *forceFormat != "" && !isDocumentFormat(*forceFormat) {
+        fmt.Fprintf(os.Stderr, "Error: invalid -force-format %q (want %s)\n", *forceFormat, strings.Join(documentFormats, ", "))
+        return 1
+    }
+    forcedFormat = *forceFormat
+
+    tracing = newTracer()
+    failures = newFailureLog()
 
     analyzer, err := NewAnalyzer(*configPath)
     if err != nil {
         fmt.Fprintf(os.Stderr, "Error creating analyzer: %v\n", err)
-        os.Exit(1)
+        return 1
+    }
+    if *strictConfig {
+        if err := analyzer.strictRules(); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return 1
+        }
+    }
+
+    var budgets *BudgetFile
+    if *budgetPath != "" {
+        if budgets, err = loadBudgets(*budgetPath, analyzer.severities); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return 1
+        }
+    }
+
+    run := newRunMetadata("analyze", analyzer, flags.Args())
+
+    if *semantic || *contradictions {
+        analyzer.embedder = newEmbedder(analyzer.config.Embeddings)
+        if err := analyzer.embedder.setBudget(*maxCost); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return 1
+        }
+    }
+
+    var roots []projectRoot
+    switch {
+    case *repoManifest != "":
+        roots, err = loadRepoRoots(*repoManifest, *configPath)
+    case *multiRoot:
+        roots, err = parseRoots(flags.Args(), *configPath)
+    }
+    if err == nil && roots != nil {
+        err = loadRootAnalyzers(roots, *configPath, analyzer)
+    }
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error creating analyzer: %v\n", err)
+        return 1
+    }
+    if *strictConfig {
+        for _, root := range roots {
+            if err := root.analyzer.strictRules(); err != nil {
+                fmt.Fprintf(os.Stderr, "Error: root %s: %v\n", root.label, err)
+                return 1
+            }
+        }
+    }
+    printRoots(roots)
+
+    formatter, err := formatterFor(*outputFormat, analyzer.config.Formatters)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+
+    limits := FileLimits{Timeout: *timeout}
+    if limits.MaxSize, err = parseSize(*maxSize); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: -max-file-size: %v\n", err)
+        return 1
+    }
+    archives.maxSize = limits.MaxSize
+
+    var retrievals *SearchLog
+    if *searchLog != "" {
+        if retrievals, err = loadSearchLog(*searchLog); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return 1
+        }
+    }
+
+    stopProfiling, err := startProfiling(*cpuProfile, *memProfile)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+
+    walk := WalkOptions{Recursive: *recursive, FollowSymlinks: *followSymlinks, Formats: analyzer.config.Formats}
+    corpusOptions := CorpusOptions{LinkGraph: *linkGraph, Contradictions: *contradictions, Mmap: *mmap}
+
+    projected := 0
+    if analyzer.embedder != nil {
+        var files []string
+        for _, path := range rootPaths(roots, flags.Args()) {
+            found, _ := collectFiles(path, walk)
+            files = append(files, found...)
+        }
+        projected = analyzer.projectEmbeddingTokens(loadDocuments(files, analyzer.config.Formats), *semantic, *contradictions)
+        analyzer.embedder.warnOverBudget(projected)
     }
 
     var allIssues []Issue
+    var files []string
+    if roots != nil {
+        allIssues, files = analyzeRoots(roots, walk, limits, corpusOptions)
+    } else {
+        allIssues = analyzePaths(analyzer, flags.Args(), walk, limits, corpusOptions)
+        for _, path := range flags.Args() {
+            found, _ := collectFiles(path, walk)
+            files = append(files, found...)
+        }
+    }
+    failed := failures.drain()
+    // The results database follows every issue across runs, before
+    // -only-new, -fix and the caps leave some out of the report
+    recorded := append(append([]Issue(nil), allIssues...), failed...)
+    run.countFiles(files, recorded)
 
-    for _, path := range flag.Args() {
-        issues, err := processPath(analyzer, path, *recursive)
+    if *onlyNew {
+        baseIssues, baseDocs, err := analyzeRevision(analyzer, *base, flags.Args(), walk, limits, corpusOptions)
         if err != nil {
-            fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", path, err)
-            continue
+            fmt.Fprintf(os.Stderr, "Error analyzing %s: %v\n", *base, err)
+            return 1
         }
-        allIssues = append(allIssues, issues...)
+        // Heading edits break links only relative to the base, so the
+        // anchor changes are always new
+        var current []string
+        for _, path := range flags.Args() {
+            found, _ := collectFiles(path, walk)
+            current = append(current, found...)
+        }
+        moved := analyzer.applyOverrides(anchorChanges(baseDocs, loadDocuments(current, analyzer.config.Formats)))
+        allIssues = append(diffReports(baseIssues, allIssues).New, moved...)
+        failures.drain() // the base revision's failures aren't this run's
     }
+    allIssues = append(allIssues, failed...)
 
     if *fix {
-        fmt.Println("Auto-fix functionality not yet implemented")
+        allIssues = applyFixes(allIssues, *safeOnly)
+    }
+    if *patchDir != "" {
+        written, err := emitPatches(*patchDir, allIssues)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error writing patches: %v\n", err)
+            return 1
+        }
+        fmt.Fprintf(os.Stderr, "Wrote %d patch(es) to %s\n", written, *patchDir)
+    }
+    remediation := analyzer.config.Remediation.estimate(allIssues)
+    // Counted before capping, which would hide issues from the budgets;
+    // the issues they cover fail the run only by exceeding them
+    failing := allIssues
+    var usage []budgetUsage
+    lowered := 0
+    if budgets != nil {
+        usage, failing = budgets.tally(allIssues)
+        if *ratchetBudgets {
+            paths := rootPaths(roots, flags.Args())
+            whole := func(dir string) bool { return analyzedWhole(dir, paths, *recursive) }
+            if reason := ratchetBlocker(allIssues); reason != "" {
+                fmt.Fprintf(os.Stderr, "Warning: not lowering budgets, as %s and their issues weren't counted\n", reason)
+            } else if lowered, err = budgets.ratchet(*budgetPath, usage, whole); err != nil {
+                fmt.Fprintf(os.Stderr, "Error writing budget file: %v\n", err)
+                return 1
+            }
+        }
+    }
+    skips := tallySkips(allIssues)
+    run.Skipped = skips.counts()
+    if *skipReport != "" {
+        if err := skips.write(*skipReport); err != nil {
+            fmt.Fprintf(os.Stderr, "Error writing skip report: %v\n", err)
+            return 1
+        }
+    }
+    // Sorted before capping, so the caps keep the same issues every run,
+    // and after, to place the summaries
+    sortIssues(allIssues)
+    allIssues = capRoots(roots, analyzer, IssueCaps{PerRule: *maxPerRule, PerFile: *maxPerFile}, allIssues)
+    sortIssues(allIssues)
+    if retrievals != nil {
+        retrievals.prioritize(allIssues)
+    }
+
+    if roots != nil {
+        linkRootDocs(roots, analyzer, allIssues)
+    } else {
+        analyzer.config.linkRuleDocs(allIssues)
+    }
+    run.finish()
+    currentRun = run
+    emit := tracing.start(nil, "emit output")
+    emit.set("output.format", *outputFormat)
+    emit.set("issues", len(allIssues))
+    if err := formatter.Format(os.Stdout, allIssues); err != nil {
+        fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+        return 1
+    }
+    emit.finish()
+    if *dbPath != "" || analyzer.config.Webhook.url() != "" {
+        if err := reportRun(*dbPath, analyzer.config.Webhook, run, files, fileWords(files), recorded); err != nil {
+            fmt.Fprintf(os.Stderr, "Error recording results: %v\n", err)
+            return 1
+        }
+    }
+    if analyzer.embedder != nil {
+        analyzer.embedder.printUsage(os.Stderr, projected)
     }
+    remediation.print(os.Stderr)
+    skips.print(os.Stderr, *skipReport)
+    if budgets != nil {
+        printBudgets(os.Stderr, usage, *ratchetBudgets, lowered)
+    }
+    stopProfiling()
+    tracing.shutdown()
+
+    if rootsFail(roots, analyzer, failing) || budgetsExceeded(usage) {
+        return 1
+    }
+    return 0
+}
+
+// analyzePaths analyzes every file the paths name or contain, then runs
+// the cross-file checks over them
+func analyzePaths(analyzer *Analyzer, paths []string, walk WalkOptions, limits FileLimits, corpusOptions CorpusOptions) []Issue {
+    var allIssues []Issue
 
-    printIssues(allIssues, *outputFormat)
+    for _, path := range paths {
+        issues, err := processPath(analyzer, path, walk, limits)
+        if err != nil {
+            reportFailure(readFailureRule, path, 0, "failed to process %s: %v", path, err)
+            continue
+        }
+        allIssues = append(allIssues, issues...)
+    }
+
+    // Files skipped for their size or content stay out of the corpus pass
+    skipped := make(map[string]bool)
+    for _, issue := range allIssues {
+        if issue.Rule == "file-skipped" {
+            skipped[issue.File] = true
+        }
+    }
+    var files []string
+    for _, path := range paths {
+        found, err := collectFiles(path, walk)
+        if err != nil {
+            continue
+        }
+        for _, file := range found {
+            if !skipped[file] {
+                files = append(files, file)
+            }
+        }
+    }
+    corpus := tracing.start(nil, "analyze corpus")
+    corpus.set("files", len(files))
+    allIssues = append(allIssues, analyzer.analyzeCorpus(files, corpusOptions)...)
+    corpus.finish()
 
-    if len(allIssues) > 0 {
-        os.Exit(1)
+    if analyzer.config.Includes.enabled() {
+        return dedupeIssues(allIssues)
     }
+    return allIssues
 }
 
-func processPath(analyzer *Analyzer, path string, recursive bool) ([]Issue, error) {
+func processPath(analyzer *Analyzer, path string, walk WalkOptions, limits FileLimits) ([]Issue, error) {
     var allIssues []Issue
 
-    stat, err := os.Stat(path)
+    files, err := collectFiles(path, walk)
     if err != nil {
         return nil, err
     }
 
-    if stat.IsDir() {
-        if recursive {
-            err = filepath.WalkDir(path, func(filePath string, d fs.DirEntry, err error) error {
-                if err != nil {
-                    return err
-                }
-
-                if !d.IsDir() && isSupportedFile(filePath) {
-                    issues, err := analyzer.AnalyzeFile(filePath)
-                    if err != nil {
-                        fmt.Fprintf(os.Stderr, "Warning: failed to analyze %s: %v\n", filePath, err)
-                        return nil
-                    }
-                    allIssues = append(allIssues, issues...)
-                }
-                return nil
-            })
-        } else {
-            entries, err := os.ReadDir(path)
-            if err != nil {
-                return nil, err
-            }
-
-            for _, entry := range entries {
-                if !entry.IsDir() {
-                    filePath := filepath.Join(path, entry.Name())
-                    if isSupportedFile(filePath) {
-                        issues, err := analyzer.AnalyzeFile(filePath)
-                        if err != nil {
-                            fmt.Fprintf(os.Stderr, "Warning: failed to analyze %s: %v\n", filePath, err)
-                            continue
-                        }
-                        allIssues = append(allIssues, issues...)
-                    }
-                }
-            }
-        }
-    } else {
-        if isSupportedFile(path) {
-            issues, err := analyzer.AnalyzeFile(path)
-            if err != nil {
+    for _, filePath := range files {
+        issues, err := analyzer.analyzeFileWithin(filePath, limits)
+        if err != nil {
+            if filePath == path {
                 return nil, err
             }
-            allIssues = append(allIssues, issues...)
+            reportFailure(readFailureRule, filePath, 0, "failed to analyze %s: %v", filePath, err)
+            continue
         }
+        allIssues = append(allIssues, issues...)
     }
 
-    return allIssues, err
+    return allIssues, nil
 }
 
-func isSupportedFile(path string) bool {
+// isSupportedFile reports whether path is a document by its extension: a
+// built-in one, or one of formats
+func isSupportedFile(path string, formats map[string]Format) bool {
     ext := strings.ToLower(filepath.Ext(path))
-    supportedExts := []string{".md", ".markdown", ".html", ".htm", ".txt", ".rst"}
+    supportedExts := append(append([]string{".md", ".markdown", ".html", ".htm", ".txt", ".rst", ".mjml"}, graphQLExtensions...), captionExtensions...)
     
     for _, supported := range supportedExts {
         if ext == supported {
             return true
         }
     }
-    return false
+    _, configured := formatFor(formats, path)
+    return configured
 }
@@ -0,0 +1,351 @@
+// Cross-paragraph anaphora detection and referent lookup
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+    "unicode"
+)
+
+var (
+    leadingPronounRegex = regexp.MustCompile(`^(?:[-*+]\s+|\d+[.)]\s+)?(It|This|That|These|Those|They|Its|Their)\b(?:\s+([a-z][\w-]*))?`)
+    codeSpanRegex       = regexp.MustCompile("`([^`]+)`")
+    boldSpanRegex       = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+)
+
+// selfReferenceNouns follow a demonstrative without needing an antecedent
+var selfReferenceNouns = []string{"page", "document", "guide", "section", "article", "tutorial", "topic", "chapter"}
+
+// pronounVerbs commonly follow a bare demonstrative ("This is", "That means"),
+// in which case the next word isn't the noun being referred to
+var pronounVerbs = map[string]bool{
+    "is": true, "are": true, "was": true, "were": true, "will": true, "would": true, "can": true,
+    "could": true, "should": true, "must": true, "may": true, "might": true, "has": true, "have": true,
+    "does": true, "do": true, "also": true, "not": true, "means": true, "allows": true, "lets": true,
+    "makes": true, "ensures": true, "helps": true, "causes": true, "provides": true, "requires": true,
+}
+
+// determiners never start a referent noun phrase
+var determiners = map[string]bool{
+    "the": true, "a": true, "an": true, "this": true, "that": true, "these": true, "those": true,
+    "its": true, "their": true, "your": true, "our": true, "each": true, "every": true, "any": true,
+    "some": true, "of": true, "to": true, "in": true, "on": true, "for": true, "and": true, "or": true,
+}
+
+// lookbackParagraphs is how many earlier paragraphs are searched for a referent
+const lookbackParagraphs = 3
+
+// analyzeAnaphora flags paragraphs whose first sentence opens with a pronoun
+// or demonstrative, since its antecedent lives in an earlier paragraph or
+// section that a retrieved chunk may not include
+func (a *Analyzer) analyzeAnaphora(doc *Document) []Issue {
+    var issues []Issue
+    paragraphs := doc.Paragraphs()
+
+    for i, paragraph := range paragraphs {
+        if i == 0 {
+            continue
+        }
+        sentences := paragraph.Sentences()
+        if len(sentences) == 0 {
+            continue
+        }
+
+        first := sentences[0]
+        match := leadingPronounRegex.FindStringSubmatch(first.Text)
+        if match == nil || isSelfReference(match[2]) {
+            continue
+        }
+
+        pronoun, noun := match[1], ""
+        if strings.EqualFold(pronoun, "this") || strings.EqualFold(pronoun, "that") ||
+            strings.EqualFold(pronoun, "these") || strings.EqualFold(pronoun, "those") {
+            if !pronounVerbs[match[2]] {
+                noun = match[2]
+            }
+        }
+
+        suggestion := fmt.Sprintf("Replace '%s' with the concrete subject it refers to", strings.TrimSpace(pronoun+" "+noun))
+        if referent := findReferent(paragraphs, i, noun); referent != "" {
+            suggestion = fmt.Sprintf("Replace '%s' with '%s'", strings.TrimSpace(pronoun+" "+noun), referent)
+        }
+
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         first.Line,
+            Column:       first.Column + strings.Index(first.Text, pronoun),
+            Rule:         "unresolved-reference",
+            Message:      fmt.Sprintf("Paragraph opens with '%s', whose referent is in an earlier paragraph", pronoun),
+            Severity:     "warning",
+            Suggestion:   suggestion,
+            OriginalText: match[0],
+        })
+    }
+
+    return issues
+}
+
+var (
+    pronounSubjectRegex  = regexp.MustCompile(`^(?:[-*+]\s+|\d+[.)]\s+)?(It|This|These)\s+([a-z][\w-]*)\b`)
+    sentenceSubjectRegex = regexp.MustCompile(`^(?:[-*+]\s+|\d+[.)]\s+)?(?:The|A|An)((?:\s+[a-z][\w-]*){2,4})`)
+)
+
+// pluralVerbs commonly follow "These" when it stands alone as the subject
+var pluralVerbs = map[string]bool{
+    "include": true, "let": true, "allow": true, "make": true, "ensure": true, "help": true,
+    "cause": true, "provide": true, "require": true, "mean": true, "need": true, "apply": true,
+    "run": true, "work": true, "contain": true, "control": true, "return": true, "take": true,
+    "use": true, "depend": true, "remain": true, "become": true, "determine": true, "affect": true,
+    "expire": true, "start": true, "stop": true, "fail": true, "change": true, "support": true,
+    "store": true, "keep": true, "show": true, "appear": true, "update": true, "create": true,
+}
+
+// isSubjectVerb reports whether word is likely the verb of a subject, in
+// the plural when plural is set: "It runs", "These include"
+func isSubjectVerb(word string, plural bool) bool {
+    switch {
+    case pronounVerbs[word] && word != "not" && word != "also":
+        return !plural || (word != "is" && word != "was" && word != "has" && word != "does")
+    case strings.HasSuffix(word, "ed"):
+        return true
+    case plural:
+        return pluralVerbs[word]
+    }
+    return strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") &&
+        !strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is")
+}
+
+// analyzePronounSubjects flags sentences within a paragraph whose subject
+// is a bare "It", "This" or "These", as in "It retries failed uploads". A
+// chunk split between the sentences loses what the pronoun stands for.
+// When the paragraph's earlier sentences have exactly one candidate
+// referent, the fix names it instead. The first sentence of a paragraph is
+// left to analyzeAnaphora.
+func (a *Analyzer) analyzePronounSubjects(doc *Document) []Issue {
+    var issues []Issue
+    for _, paragraph := range doc.Paragraphs() {
+        sentences := paragraph.Sentences()
+        for i := 1; i < len(sentences); i++ {
+            sentence := sentences[i]
+            match := pronounSubjectRegex.FindStringSubmatch(sentence.Text)
+            if match == nil || !isSubjectVerb(match[2], match[1] == "These") {
+                continue
+            }
+
+            pronoun := match[1]
+            line := doc.Masked[sentence.Line-1]
+            column := sentence.Column + strings.Index(line[sentence.Column-1:], pronoun)
+            issue := Issue{
+                File:         doc.Path,
+                Line:         sentence.Line,
+                Column:       column,
+                Rule:         "pronoun-subject",
+                Message:      fmt.Sprintf("Sentence has a bare '%s' as its subject, which a chunk split before it can't resolve", pronoun),
+                Severity:     "warning",
+                Suggestion:   fmt.Sprintf("Replace '%s' with the noun phrase it refers to", pronoun),
+                OriginalText: pronoun,
+            }
+            if referent := sentenceReferent(sentences[:i], pronoun == "These"); referent != "" {
+                referent = strings.ToUpper(referent[:1]) + referent[1:]
+                issue.Suggestion = fmt.Sprintf("Replace '%s' with '%s'", pronoun, referent)
+                issue.Fix = &Fix{Line: sentence.Line, Column: column, Length: len(pronoun), Replace: referent}
+            }
+            issues = append(issues, issue)
+        }
+    }
+    return issues
+}
+
+// sentenceReferent returns what a pronoun subject most likely stands for,
+// from the sentences before it in its paragraph: a code span or bold term,
+// or the subject of a sentence ("the sync agent" in "The sync agent runs
+// hourly"), agreeing with the pronoun in number. It returns "" unless
+// there is exactly one such candidate.
+func sentenceReferent(sentences []Sentence, plural bool) string {
+    seen := make(map[string]bool)
+    var candidates []string
+    add := func(candidate string) {
+        if !seen[strings.ToLower(candidate)] {
+            seen[strings.ToLower(candidate)] = true
+            candidates = append(candidates, candidate)
+        }
+    }
+    for _, sentence := range sentences {
+        if match := sentenceSubjectRegex.FindStringSubmatch(sentence.Text); match != nil && !determiners[strings.Fields(match[1])[0]] {
+            // The subject runs up to the first word that can be its verb
+            words := strings.Fields(match[1])
+            for end := 1; end < len(words); end++ {
+                noun := words[end-1]
+                isPlural := strings.HasSuffix(noun, "s") && !strings.HasSuffix(noun, "ss")
+                if isSubjectVerb(words[end], isPlural) {
+                    if isPlural == plural {
+                        add("the " + strings.Join(words[:end], " "))
+                    }
+                    break
+                }
+            }
+        }
+        if plural {
+            continue // a code span or term names one thing
+        }
+        for _, span := range codeSpanRegex.FindAllStringSubmatch(sentence.Text, -1) {
+            add("`" + span[1] + "`")
+        }
+        for _, span := range boldSpanRegex.FindAllStringSubmatch(sentence.Text, -1) {
+            add(span[1] + span[2])
+        }
+    }
+    if len(candidates) != 1 {
+        return ""
+    }
+    return candidates[0]
+}
+
+// isSelfReference reports whether the word after a demonstrative names the
+// document itself ("This page ..."), which needs no antecedent
+func isSelfReference(word string) bool {
+    for _, noun := range selfReferenceNouns {
+        if word == noun {
+            return true
+        }
+    }
+    return false
+}
+
+// findReferent searches the paragraphs before index, nearest first, for the
+// most likely concrete referent. With a noun ("this setting"), it looks for
+// a modified mention of that noun ("session-timeout setting"); otherwise it
+// takes the last code span or bold term.
+func findReferent(paragraphs []Paragraph, index int, noun string) string {
+    for i := index - 1; i >= 0 && i >= index-lookbackParagraphs; i-- {
+        text := strings.Join(strings.Fields(paragraphs[i].Text), " ")
+
+        if noun != "" {
+            if phrase := lastModifiedMention(text, noun); phrase != "" {
+                return "the " + phrase
+            }
+            continue
+        }
+
+        if spans := codeSpanRegex.FindAllStringSubmatch(text, -1); len(spans) > 0 {
+            return "`" + spans[len(spans)-1][1] + "`"
+        }
+        if spans := boldSpanRegex.FindAllStringSubmatch(text, -1); len(spans) > 0 {
+            last := spans[len(spans)-1]
+            return last[1] + last[2]
+        }
+    }
+    return ""
+}
+
+// lastModifiedMention returns the last "<modifier> <noun>" phrase in text
+// whose modifier isn't a determiner, also matching the noun's plural
+func lastModifiedMention(text, noun string) string {
+    stem := strings.TrimSuffix(noun, "s")
+    pattern := regexp.MustCompile(`(?i)\b([\w-]+)\s+(` + regexp.QuoteMeta(stem) + `s?)\b`)
+
+    matches := pattern.FindAllStringSubmatch(text, -1)
+    for i := len(matches) - 1; i >= 0; i-- {
+        if !determiners[strings.ToLower(matches[i][1])] {
+            return matches[i][1] + " " + matches[i][2]
+        }
+    }
+    return ""
+}
+
+// nonReferentNouns follow a demonstrative without naming a thing
+var nonReferentNouns = map[string]bool{"one": true, "ones": true, "way": true, "time": true, "point": true, "case": true, "is": true}
+
+// contextualReferent returns the reference in a contextual-dependency
+// match at line lineNum, byte column column ("this setting" in "this
+// setting will"), and its most likely concrete referent. Both are "" when
+// no referent is likely, and for references such as "above". A noun
+// phrase takes the nearest earlier mention of its noun with a modifier
+// ("the session-timeout setting"); a bare "this" takes the last code span
+// earlier in its paragraph, or else the subject of the section heading.
+func (d *Document) contextualReferent(lineNum, column int, match string) (string, string) {
+    words := strings.Fields(match)
+    if len(words) < 2 {
+        return "", ""
+    }
+    demonstrative := strings.ToLower(words[0])
+    if demonstrative != "this" && demonstrative != "that" && demonstrative != "these" && demonstrative != "those" {
+        return "", ""
+    }
+    noun := words[1]
+    if len(words) == 2 || pronounVerbs[noun] || determiners[noun] || nonReferentNouns[noun] || strings.ToLower(noun) != noun {
+        // A bare "that" is usually a conjunction ("so that it will")
+        if demonstrative == "that" {
+            return "", ""
+        }
+        noun = ""
+    }
+
+    // The paragraphs before the reference, the last cut off at the match
+    var preceding []Paragraph
+    current := ""
+    for _, paragraph := range d.Paragraphs() {
+        if paragraph.StartLine > lineNum {
+            break
+        }
+        if paragraph.EndLine >= lineNum {
+            lines := append([]string(nil), d.Masked[paragraph.StartLine-1:lineNum]...)
+            last := lines[len(lines)-1]
+            lines[len(lines)-1] = last[:min(column-1, len(last))]
+            paragraph.Text = strings.Join(lines, "\n")
+            current = paragraph.Text
+        }
+        preceding = append(preceding, paragraph)
+    }
+
+    if noun != "" {
+        if referent := findReferent(preceding, len(preceding), noun); referent != "" {
+            return words[0] + " " + noun, referent
+        }
+    } else if spans := codeSpanRegex.FindAllStringSubmatch(current, -1); len(spans) > 0 {
+        return words[0], "`" + spans[len(spans)-1][1] + "`"
+    }
+
+    subject := d.headingSubject(lineNum)
+    switch {
+    case subject == "":
+        return "", ""
+    case noun == "":
+        return words[0], subject
+    case strings.HasSuffix(strings.ToLower(subject), strings.TrimSuffix(noun, "s")) || strings.HasSuffix(strings.ToLower(subject), noun):
+        return words[0] + " " + noun, subject
+    }
+    return "", ""
+}
+
+// headingSubject returns the subject named by the heading of the section
+// holding lineNum, with "the" before a common noun phrase, or "" when the
+// heading is a question or too long to be a name
+func (d *Document) headingSubject(lineNum int) string {
+    for _, section := range d.Sections {
+        if section.Line == 0 || lineNum < section.StartLine || lineNum > section.EndLine {
+            continue
+        }
+        subject := plainText(section.Heading)
+        if len(strings.Fields(subject)) > 4 || strings.HasSuffix(subject, "?") {
+            return ""
+        }
+        // A sentence-case heading is a common noun phrase; a Title Case one
+        // may be a name, so it is kept as written
+        words := strings.Fields(subject)
+        if len(words) == 0 {
+            return "" // a heading of only emphasis markers or images
+        }
+        common := len(subject) > 1 && unicode.IsUpper(rune(subject[0])) && unicode.IsLower(rune(subject[1]))
+        for _, word := range words[1:] {
+            common = common && !unicode.IsUpper(rune(word[0]))
+        }
+        if common {
+            return "the " + strings.ToLower(subject[:1]) + subject[1:]
+        }
+        return subject
+    }
+    return ""
+}
@@ -0,0 +1,100 @@
+// Anchor stability: links that heading edits since the base revision break
+
+package main
+
+import (
+    "fmt"
+    "path/filepath"
+    "strings"
+)
+
+// anchorChanges reports the headings of current whose anchor changed since
+// base, the same files at the base revision, and that are still linked
+// under the old anchor from current. A heading is taken as edited when a
+// section of the same file now has its content under another anchor, or,
+// failing that, when the section in its place in the outline has a new
+// anchor. Headings that were removed outright are left to the link checks.
+func anchorChanges(base, current []*Document) []Issue {
+    byPath := make(map[string]*Document)
+    for _, doc := range base {
+        byPath[filepath.Clean(doc.Path)] = doc
+    }
+    graph := buildLinkGraph(current)
+
+    var issues []Issue
+    for _, doc := range current {
+        old, ok := byPath[filepath.Clean(doc.Path)]
+        if !ok {
+            continue
+        }
+        oldAnchors, _ := sectionIdentities(old)
+        newAnchors, _ := sectionIdentities(doc)
+        isOld, isNew := make(map[string]bool), make(map[string]bool)
+        for _, anchor := range oldAnchors {
+            isOld[anchor] = true
+        }
+        for _, anchor := range newAnchors {
+            isNew[anchor] = true
+        }
+
+        from, to := headedSections(old), headedSections(doc)
+        byContent := make(map[string]Section)
+        for _, section := range to {
+            if !isOld[newAnchors[section.Line]] {
+                byContent[contentHash(doc.Body(section))] = section
+            }
+        }
+        for i, section := range from {
+            anchor := oldAnchors[section.Line]
+            if isNew[anchor] {
+                continue
+            }
+            replacement, ok := byContent[contentHash(old.Body(section))]
+            if !ok && i < len(to) && to[i].Level == section.Level && !isOld[newAnchors[to[i].Line]] {
+                replacement, ok = to[i], true
+            }
+            if !ok {
+                continue
+            }
+
+            links := anchorLinks(graph, doc, anchor)
+            if len(links) == 0 {
+                continue
+            }
+            heading := strings.TrimSpace(customAnchorRegex.ReplaceAllString(replacement.Heading, ""))
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         replacement.Line,
+                Column:       1,
+                Rule:         "anchor-changed",
+                Message:      fmt.Sprintf("Heading change moves the anchor #%s to #%s, breaking %d link(s): %s", anchor, newAnchors[replacement.Line], len(links), strings.Join(links, ", ")),
+                Severity:     "warning",
+                Suggestion:   fmt.Sprintf("Keep the old anchor with an explicit ID, as in `%s %s {#%s}`, or update the links", strings.Repeat("#", replacement.Level), heading, anchor),
+                OriginalText: replacement.Heading,
+            })
+        }
+    }
+    return issues
+}
+
+// anchorLinks returns the locations, as file:line, of the links to anchor
+// of doc, from other pages of the link graph and from doc itself
+func anchorLinks(graph *LinkGraph, doc *Document, anchor string) []string {
+    var links []string
+    for _, link := range graph.Inbound[filepath.Clean(doc.Path)] {
+        if link.Anchor == anchor {
+            links = append(links, fmt.Sprintf("%s:%d", link.Source, link.Line))
+        }
+    }
+    for i, line := range doc.Lines {
+        if doc.Fenced[i] {
+            continue
+        }
+        for _, target := range extractLinkTargets(line) {
+            if target == "#"+anchor {
+                links = append(links, fmt.Sprintf("%s:%d", doc.Path, i+1))
+            }
+        }
+    }
+    return links
+}
@@ -0,0 +1,325 @@
+// Applying reviewed fix patches, merging them into files changed since
+// they were written
+
+package main
+
+import (
+    "bytes"
+    "flag"
+    "fmt"
+    "io/fs"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// filePatch is the diff of one file in a patch, as -emit-patches writes it
+type filePatch struct {
+    path  string
+    base  string // blob of the file the patch was made against, if known
+    fixed string // blob of the file with the patch applied, if known
+    hunks []patchHunk
+}
+
+// patchHunk is one hunk of a file's diff
+type patchHunk struct {
+    oldStart int      // 1-based line the hunk starts at in the original
+    old      []string // context and removed lines
+    new      []string // context and added lines
+    oldEOF   bool     // the original's last line, with no newline, ends old
+    newEOF   bool     // the patched file's last line, with no newline, ends new
+}
+
+var (
+    patchIndexRegex = regexp.MustCompile(`^index ([0-9a-f]+)\.\.([0-9a-f]+)`)
+    patchHunkRegex  = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+)
+
+// parsePatch reads the file diffs of a unified diff. Hunk line counts are
+// ignored, so hunks a reviewer trimmed or edited by hand still apply.
+func parsePatch(data string) ([]filePatch, error) {
+    var patches []filePatch
+    var patch *filePatch
+    var hunk *patchHunk
+    lines := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+    for number, line := range lines {
+        switch {
+        case strings.HasPrefix(line, "diff --git "):
+            patches = append(patches, filePatch{})
+            patch, hunk = &patches[len(patches)-1], nil
+        case hunk == nil && strings.HasPrefix(line, "index "):
+            if match := patchIndexRegex.FindStringSubmatch(line); match != nil && patch != nil {
+                patch.base, patch.fixed = match[1], match[2]
+            }
+        case hunk == nil && strings.HasPrefix(line, "--- "):
+            if patch == nil || patch.path != "" {
+                patches = append(patches, filePatch{})
+                patch = &patches[len(patches)-1]
+            }
+        case hunk == nil && strings.HasPrefix(line, "+++ "):
+            name := strings.TrimPrefix(strings.SplitN(line[4:], "\t", 2)[0], "b/")
+            if patch == nil || name == "/dev/null" {
+                return nil, fmt.Errorf("line %d: only changes to existing files can be applied", number+1)
+            }
+            patch.path = filepath.FromSlash(name)
+        case strings.HasPrefix(line, "@@"):
+            match := patchHunkRegex.FindStringSubmatch(line)
+            if match == nil || patch == nil || patch.path == "" {
+                return nil, fmt.Errorf("line %d: hunk without a file header", number+1)
+            }
+            start, _ := strconv.Atoi(match[1])
+            patch.hunks = append(patch.hunks, patchHunk{oldStart: start})
+            hunk = &patch.hunks[len(patch.hunks)-1]
+        case hunk != nil && strings.HasPrefix(line, `\`):
+            // "\ No newline at end of file", after the line it applies to
+            previous := lines[number-1] + " "
+            hunk.oldEOF = hunk.oldEOF || previous[0] != '+'
+            hunk.newEOF = hunk.newEOF || previous[0] != '-'
+        case hunk != nil && (line == "" || line[0] == ' '):
+            text := strings.TrimPrefix(line, " ")
+            hunk.old = append(hunk.old, text)
+            hunk.new = append(hunk.new, text)
+        case hunk != nil && line[0] == '-':
+            hunk.old = append(hunk.old, line[1:])
+        case hunk != nil && line[0] == '+':
+            hunk.new = append(hunk.new, line[1:])
+        default:
+            hunk = nil
+        }
+    }
+
+    var files []filePatch
+    for _, patch := range patches {
+        if len(patch.hunks) > 0 {
+            files = append(files, patch)
+        }
+    }
+    if len(files) == 0 {
+        return nil, fmt.Errorf("no file diffs")
+    }
+    return files, nil
+}
+
+// applyHunks applies a file's hunks to its content, each where its lines
+// match nearest to where the patch places it, so they still apply after
+// lines are added or removed elsewhere in the file
+func applyHunks(content string, hunks []patchHunk) (string, error) {
+    lines := strings.Split(content, "\n")
+    var out []string
+    next, shift := 0, 0 // first line not yet copied; how far hunks have moved from their place
+    for i, hunk := range hunks {
+        at := -1
+        expected := max(hunk.oldStart-1+shift, next)
+        if len(hunk.old) == 0 {
+            at = min(hunk.oldStart+shift, len(lines)) // insertion after line oldStart
+            if at < next {
+                return "", fmt.Errorf("hunk %d, at line %d, is out of order or overlaps the hunk before it", i+1, hunk.oldStart)
+            }
+        }
+        for distance := 0; at < 0 && (expected-distance >= next || expected+distance <= len(lines)); distance++ {
+            for _, candidate := range []int{expected - distance, expected + distance} {
+                if candidate >= next && hunk.matches(lines, candidate) {
+                    at = candidate
+                    break
+                }
+            }
+        }
+        if at < 0 {
+            return "", fmt.Errorf("hunk %d, at line %d, doesn't match", i+1, hunk.oldStart)
+        }
+
+        out = append(out, lines[next:at]...)
+        out = append(out, hunk.new...)
+        next, shift = at+len(hunk.old), at-(hunk.oldStart-1)
+        switch {
+        case hunk.oldEOF && !hunk.newEOF:
+            out = append(out, "")
+        case hunk.newEOF && !hunk.oldEOF && next == len(lines)-1 && lines[next] == "":
+            next++
+        }
+    }
+    out = append(out, lines[next:]...)
+    return strings.Join(out, "\n"), nil
+}
+
+// matches reports whether the hunk's original lines are those of the file
+// at index at, the last one ending the file if the hunk says so
+func (h patchHunk) matches(lines []string, at int) bool {
+    if at < 0 || at+len(h.old) > len(lines) {
+        return false
+    }
+    for i, line := range h.old {
+        if lines[at+i] != line {
+            return false
+        }
+    }
+    return h.oldEOF == (at+len(h.old) == len(lines))
+}
+
+// patchResult is what applying one file's patch came to
+type patchResult struct {
+    status    string // applied, merged, conflict, already applied or failed
+    conflicts int
+    err       error
+}
+
+// applyFilePatch applies a patch to its file, or with check only works
+// out what that would do. A file that has changed since the patch was
+// made is patched where the hunks still match, and otherwise merged three
+// ways, with git merge-file, from the original git has of it: conflicting
+// hunks are written with conflict markers, as git merge does.
+func applyFilePatch(patch filePatch, check bool) patchResult {
+    info, err := os.Stat(patch.path)
+    if err != nil {
+        return patchResult{status: "failed", err: err}
+    }
+    data, err := os.ReadFile(patch.path)
+    if err != nil {
+        return patchResult{status: "failed", err: err}
+    }
+    current := gitBlobID(data)
+    if patch.fixed != "" && strings.HasPrefix(current, patch.fixed) {
+        return patchResult{status: "already applied"}
+    }
+
+    result := patchResult{status: "applied"}
+    output, err := applyHunks(string(data), patch.hunks)
+    if err != nil {
+        if patch.base == "" || strings.HasPrefix(current, patch.base) {
+            return patchResult{status: "failed", err: err}
+        }
+        output, result.conflicts, err = mergePatch(patch, data)
+        if err != nil {
+            return patchResult{status: "failed", err: err}
+        }
+        result.status = "merged"
+        if result.conflicts > 0 {
+            result.status = "conflict"
+        }
+    }
+    if output == string(data) {
+        return patchResult{status: "already applied"}
+    }
+    if !check {
+        if err := os.WriteFile(patch.path, []byte(output), info.Mode().Perm()); err != nil {
+            return patchResult{status: "failed", err: err}
+        }
+    }
+    return result
+}
+
+// mergePatch merges the changes a patch makes to the original of a file
+// into its current content, and returns the result and its number of
+// conflicts
+func mergePatch(patch filePatch, current []byte) (string, int, error) {
+    base, err := exec.Command("git", "cat-file", "blob", patch.base).Output()
+    if err != nil {
+        return "", 0, fmt.Errorf("the file has changed since the patch was made, and git has no copy of the original to merge with")
+    }
+    fixed, err := applyHunks(string(base), patch.hunks)
+    if err != nil {
+        return "", 0, fmt.Errorf("the patch doesn't apply to the original it names: %w", err)
+    }
+
+    dir, err := os.MkdirTemp("", "ai-doc-optimizer-merge")
+    if err != nil {
+        return "", 0, err
+    }
+    defer os.RemoveAll(dir)
+    versions := map[string][]byte{"current": current, "original": base, "fixes": []byte(fixed)}
+    for name, content := range versions {
+        if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+            return "", 0, err
+        }
+    }
+
+    cmd := exec.Command("git", "merge-file", "-p", "-L", filepath.ToSlash(patch.path), "-L", "original", "-L", "fixes",
+        filepath.Join(dir, "current"), filepath.Join(dir, "original"), filepath.Join(dir, "fixes"))
+    var stderr bytes.Buffer
+    cmd.Stderr = &stderr
+    merged, err := cmd.Output()
+    if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() > 0 && exit.ExitCode() < 128 {
+        return string(merged), exit.ExitCode(), nil
+    }
+    if err != nil {
+        return "", 0, fmt.Errorf("git merge-file: %v %s", err, strings.TrimSpace(stderr.String()))
+    }
+    return string(merged), 0, nil
+}
+
+// patchFiles lists the .patch files under the given files and directories
+func patchFiles(paths []string) ([]string, error) {
+    var files []string
+    for _, path := range paths {
+        err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+            if err != nil {
+                return err
+            }
+            if !entry.IsDir() && (file == path || strings.HasSuffix(file, ".patch")) {
+                files = append(files, file)
+            }
+            return nil
+        })
+        if err != nil {
+            return nil, err
+        }
+    }
+    return files, nil
+}
+
+// runApply implements the apply subcommand, applying the patches
+// -emit-patches wrote once they are reviewed. It exits with status 1 when
+// a patch conflicts or fails to apply.
+func runApply(args []string) int {
+    flags := flag.NewFlagSet("apply", flag.ExitOnError)
+    check := flags.Bool("check", false, "Report how each patch would apply without changing any file")
+    flags.Parse(args)
+
+    if flags.NArg() == 0 {
+        fmt.Fprintf(os.Stderr, "Usage: %s apply [options] <patch_file_or_directory>...\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+    files, err := patchFiles(flags.Args())
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+
+    counts := make(map[string]int)
+    for _, file := range files {
+        data, err := os.ReadFile(file)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return 1
+        }
+        patches, err := parsePatch(string(data))
+        if err != nil {
+            fmt.Printf("%-16s %s: %v\n", "FAILED", file, err)
+            counts["failed"]++
+            continue
+        }
+        for _, patch := range patches {
+            result := applyFilePatch(patch, *check)
+            counts[result.status]++
+            switch {
+            case result.err != nil:
+                fmt.Printf("%-16s %s (%s): %v\n", strings.ToUpper(result.status), patch.path, file, result.err)
+            case result.conflicts > 0:
+                fmt.Printf("%-16s %s (%s): %d conflict(s); resolve the <<<<<<< markers\n", strings.ToUpper(result.status), patch.path, file, result.conflicts)
+            default:
+                fmt.Printf("%-16s %s (%s)\n", strings.ToUpper(result.status), patch.path, file)
+            }
+        }
+    }
+
+    fmt.Printf("\n%d applied, %d merged, %d conflicted, %d failed, %d already applied\n",
+        counts["applied"], counts["merged"], counts["conflict"], counts["failed"], counts["already applied"])
+    if counts["conflict"] > 0 || counts["failed"] > 0 {
+        return 1
+    }
+    return 0
+}
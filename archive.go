@@ -0,0 +1,276 @@
+// Archive input
+
+package main
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "compress/gzip"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "sync"
+)
+
+// archiveSeparator joins an archive's path and a member's path inside it,
+// as in docs.zip!guide/install.md
+const archiveSeparator = "!"
+
+// archiveExtensions are the archive formats read as input
+var archiveExtensions = []string{".zip", ".tar.gz", ".tgz", ".tar"}
+
+// archives lists the members of each archive read, and holds the contents
+// of the last tar file extracted, so a tar file is decompressed once
+// however many of its members are analyzed in a row
+var archives = &archiveCache{sizes: make(map[string]map[string]int64), listed: make(map[string]map[string]bool)}
+
+type archiveCache struct {
+    mu       sync.Mutex
+    maxSize  int64                       // -max-file-size; larger members are listed but never read
+    sizes    map[string]map[string]int64 // archive path -> member path -> size
+    listed   map[string]map[string]bool  // archive path -> members archiveFiles returned, the ones extracted
+    current  string                      // the tar file contents holds
+    contents map[string][]byte           // member path -> contents
+}
+
+// isArchive reports whether path names a supported archive
+func isArchive(path string) bool {
+    lower := strings.ToLower(path)
+    for _, ext := range archiveExtensions {
+        if strings.HasSuffix(lower, ext) {
+            return true
+        }
+    }
+    return false
+}
+
+// splitArchivePath splits a path of the form archive!member
+func splitArchivePath(path string) (string, string, bool) {
+    lower := strings.ToLower(path)
+    for _, ext := range archiveExtensions {
+        if i := strings.Index(lower, ext+archiveSeparator); i >= 0 {
+            end := i + len(ext)
+            return path[:end], path[end+len(archiveSeparator):], true
+        }
+    }
+    return "", "", false
+}
+
+// archiveFiles returns the paths of the files inside an archive that are
+// supported with formats, in archive!member form and in order
+func archiveFiles(archive string, formats map[string]Format) ([]string, error) {
+    archives.mu.Lock()
+    defer archives.mu.Unlock()
+    sizes, err := archives.list(archive)
+    if err != nil {
+        return nil, err
+    }
+    if archives.listed[archive] == nil {
+        archives.listed[archive] = make(map[string]bool)
+    }
+    var files []string
+    for _, member := range sortedKeys(sizes) {
+        if isSupportedFile(member, formats) {
+            archives.listed[archive][member] = true
+            files = append(files, archive+archiveSeparator+member)
+        }
+    }
+    return files, nil
+}
+
+// readFileData reads a file, or a member of an archive
+func readFileData(path string) ([]byte, error) {
+    archive, member, ok := splitArchivePath(path)
+    if !ok {
+        return os.ReadFile(path)
+    }
+    return archives.read(archive, member)
+}
+
+// fileSize returns the size of a file, or of a member of an archive as its
+// header gives it
+func fileSize(path string) (int64, error) {
+    if archive, member, ok := splitArchivePath(path); ok {
+        archives.mu.Lock()
+        defer archives.mu.Unlock()
+        sizes, err := archives.list(archive)
+        if err != nil {
+            return 0, err
+        }
+        size, ok := sizes[member]
+        if !ok {
+            return 0, fmt.Errorf("%s has no member %s", archive, member)
+        }
+        return size, nil
+    }
+    info, err := os.Stat(path)
+    if err != nil {
+        return 0, err
+    }
+    return info.Size(), nil
+}
+
+// list returns the sizes of the files in an archive, reading its headers
+// once. The caller holds c.mu.
+func (c *archiveCache) list(archive string) (map[string]int64, error) {
+    if sizes, ok := c.sizes[archive]; ok {
+        return sizes, nil
+    }
+    sizes := make(map[string]int64)
+    var err error
+    if isZip(archive) {
+        err = walkZip(archive, func(name string, file *zip.File) (bool, error) {
+            sizes[name] = int64(file.UncompressedSize64)
+            return true, nil
+        })
+    } else {
+        err = walkTar(archive, func(name string, header *tar.Header, _ io.Reader) (bool, error) {
+            sizes[name] = header.Size
+            return true, nil
+        })
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read archive %s: %w", archive, err)
+    }
+    c.sizes[archive] = sizes
+    return sizes, nil
+}
+
+// read returns the contents of an archive member. A zip member is read on
+// its own; the listed members of a tar file are extracted together,
+// dropping the tar file extracted before, since its members can only be
+// reached in order.
+func (c *archiveCache) read(archive, member string) ([]byte, error) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    sizes, err := c.list(archive)
+    if err != nil {
+        return nil, err
+    }
+    size, ok := sizes[member]
+    switch {
+    case !ok:
+        return nil, fmt.Errorf("%s has no member %s", archive, member)
+    case c.tooLarge(size):
+        return nil, fmt.Errorf("%s%s%s is %s, over the %s limit", archive, archiveSeparator, member, formatSize(size), formatSize(c.maxSize))
+    }
+
+    if isZip(archive) {
+        var data []byte
+        err := walkZip(archive, func(name string, file *zip.File) (bool, error) {
+            if name != member {
+                return true, nil
+            }
+            contents, err := file.Open()
+            if err != nil {
+                return false, err
+            }
+            defer contents.Close()
+            data, err = readMember(contents, size)
+            return false, err
+        })
+        if err != nil {
+            return nil, fmt.Errorf("failed to read archive %s: %w", archive, err)
+        }
+        return data, nil
+    }
+
+    if c.current != archive {
+        c.current, c.contents = "", nil
+        contents := make(map[string][]byte)
+        err := walkTar(archive, func(name string, header *tar.Header, reader io.Reader) (bool, error) {
+            if c.tooLarge(header.Size) || !c.listed[archive][name] && name != member {
+                return true, nil
+            }
+            data, err := readMember(reader, header.Size)
+            contents[name] = data
+            return true, err
+        })
+        if err != nil {
+            return nil, fmt.Errorf("failed to read archive %s: %w", archive, err)
+        }
+        c.current, c.contents = archive, contents
+    }
+    return c.contents[member], nil
+}
+
+// tooLarge reports whether a member of size bytes is over -max-file-size
+func (c *archiveCache) tooLarge(size int64) bool {
+    return c.maxSize > 0 && size > c.maxSize
+}
+
+// readMember reads a member whose header gives its size, never reading
+// more than that, so a member can't expand past the size it was checked at
+func readMember(reader io.Reader, size int64) ([]byte, error) {
+    data, err := io.ReadAll(io.LimitReader(reader, size+1))
+    if err != nil {
+        return nil, err
+    }
+    if int64(len(data)) > size {
+        return nil, fmt.Errorf("member is larger than its header's %d bytes", size)
+    }
+    return data, nil
+}
+
+func isZip(archive string) bool {
+    return strings.HasSuffix(strings.ToLower(archive), ".zip")
+}
+
+// walkZip calls visit with each file in a zip file, by its path, until
+// visit returns false
+func walkZip(archive string, visit func(string, *zip.File) (bool, error)) error {
+    reader, err := zip.OpenReader(archive)
+    if err != nil {
+        return err
+    }
+    defer reader.Close()
+
+    for _, file := range reader.File {
+        if file.FileInfo().IsDir() {
+            continue
+        }
+        if more, err := visit(strings.TrimPrefix(file.Name, "./"), file); err != nil || !more {
+            return err
+        }
+    }
+    return nil
+}
+
+// walkTar calls visit with each regular file in a tar file, by its path,
+// until visit returns false. The reader passed to visit yields the
+// member's contents.
+func walkTar(archive string, visit func(string, *tar.Header, io.Reader) (bool, error)) error {
+    file, err := os.Open(archive)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    var stream io.Reader = file
+    if !strings.HasSuffix(strings.ToLower(archive), ".tar") {
+        gz, err := gzip.NewReader(file)
+        if err != nil {
+            return err
+        }
+        defer gz.Close()
+        stream = gz
+    }
+
+    reader := tar.NewReader(stream)
+    for {
+        header, err := reader.Next()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        if header.Typeflag != tar.TypeReg {
+            continue
+        }
+        if more, err := visit(strings.TrimPrefix(header.Name, "./"), header, reader); err != nil || !more {
+            return err
+        }
+    }
+}
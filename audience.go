@@ -0,0 +1,141 @@
+// Audience declarations and privileged instructions
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// AudienceConfig makes pages declare who they are for, which is off by
+// default
+type AudienceConfig struct {
+    Required bool     `yaml:"Required,omitempty"`
+    Roles    []string `yaml:"Roles,omitempty"` // audiences an introduction can name, replacing the defaults
+}
+
+// defaultAudienceRoles are the audiences an introduction can name when
+// the config doesn't set Audience.Roles
+var defaultAudienceRoles = []string{
+    "admin", "administrator", "developer", "end user", "user", "operator", "engineer", "architect",
+    "analyst", "owner", "contributor", "integrator", "maintainer",
+}
+
+// defaultAudienceRegex matches an introduction naming one of the default
+// audiences
+var defaultAudienceRegex = audienceRegex(defaultAudienceRoles)
+
+// audienceRegex returns the pattern matching an introduction that names
+// one of the roles, as in "This guide is for administrators"
+func audienceRegex(roles []string) *regexp.Regexp {
+    var names []string
+    for _, role := range roles {
+        names = append(names, regexp.QuoteMeta(strings.ToLower(role)))
+    }
+    return regexp.MustCompile(`(?i)\b(?:for|intended for|aimed at|written for|audience:?|if you are|as an?)\s+(?:[\w-]+\s+){0,3}?(?:` + strings.Join(names, "|") + `)s?\b`)
+}
+
+// audienceFields are the front matter fields that declare a page's audience
+var audienceFields = []string{"audience", "audiences", "role", "roles", "persona", "personas"}
+
+var (
+    // privilegedCommandRegex matches a code line run with elevated privileges
+    privilegedCommandRegex = regexp.MustCompile(`^\s*(?:[$#>]\s*)?(?:sudo|doas|su\s+-|runas)\b|(?i)-Verb\s+RunAs\b`)
+    // privilegedStepRegex matches prose sending the reader somewhere only
+    // administrators can go
+    privilegedStepRegex = regexp.MustCompile(`(?i)\b(?:admin(?:istrator|istration)? (?:console|portal|center|centre|panel|dashboard|settings)|elevated (?:command prompt|prompt|powershell|terminal))\b`)
+    // privilegeStatementRegex matches prose saying what access a task needs
+    privilegeStatementRegex = regexp.MustCompile(`(?i)\b(?:administrators?|admins?|(?:admin|root|superuser|sudo|elevated|owner) (?:role|rights|access|privileges|permissions|account|user|shell)|superusers?|(?:privileges|permissions?|roles?) (?:to|required|needed)|requires? (?:the )?[\w-]+ (?:role|permission|privilege)s?|must be (?:an? )?(?:owner|member of))\b`)
+)
+
+// analyzeAudience flags pages that don't say who they are for, when
+// Audience.Required is set, and pages with instructions that need elevated
+// privileges but never say so. An assistant asked "can I do this?" needs
+// the page to state both.
+func (a *Analyzer) analyzeAudience(doc *Document) []Issue {
+    var issues []Issue
+    if a.config.Audience.Required && !a.declaresAudience(doc) {
+        issues = append(issues, Issue{
+            File:       doc.Path,
+            Line:       1,
+            Column:     1,
+            Rule:       "missing-audience",
+            Message:    "Page doesn't say who it is for",
+            Severity:   "suggestion",
+            Suggestion: "Add 'audience: admin' (or developer, end user) to the front matter, or say in the introduction who the page is for, such as 'This guide is for workspace administrators.'",
+        })
+    }
+
+    if _, roles, ok := lookupAudience(doc.FrontMatter); ok && privilegeStatementRegex.MatchString(roles) {
+        return issues
+    }
+    instruction := 0
+    for i, line := range doc.Masked {
+        if i < doc.BodyStart-1 {
+            continue
+        }
+        if doc.Fenced[i] {
+            if instruction == 0 && privilegedCommandRegex.MatchString(doc.Lines[i]) {
+                instruction = i + 1
+            }
+            continue
+        }
+        if privilegeStatementRegex.MatchString(blankMatches(inlineCodeRegex, line)) {
+            return issues
+        }
+        if instruction == 0 && privilegedStepRegex.MatchString(line) {
+            instruction = i + 1
+        }
+    }
+    if instruction > 0 {
+        text := strings.TrimSpace(doc.Lines[instruction-1])
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         instruction,
+            Column:       1,
+            Rule:         "privilege-unstated",
+            Message:      fmt.Sprintf("Instruction needs elevated privileges (%q), but the page never says what access it takes", text),
+            Severity:     "warning",
+            Suggestion:   "Say before the steps what role or access they need, such as 'You need root access on the host' or 'Requires the Organization Admin role'",
+            OriginalText: text,
+        })
+    }
+    return issues
+}
+
+// declaresAudience reports whether a page names its audience in front
+// matter, or in one of the first two paragraphs, as in "This guide is for
+// administrators"
+func (a *Analyzer) declaresAudience(doc *Document) bool {
+    if _, roles, ok := lookupAudience(doc.FrontMatter); ok && strings.TrimSpace(roles) != "" {
+        return true
+    }
+    paragraphs := doc.Paragraphs()
+    for i := 0; i < len(paragraphs) && i < 2; i++ {
+        if a.audience.MatchString(plainText(paragraphs[i].Text)) {
+            return true
+        }
+    }
+    return false
+}
+
+// lookupAudience returns the first audience field of front matter and its
+// value as text, lists joined with commas
+func lookupAudience(fields map[string]interface{}) (string, string, bool) {
+    key, value, ok := lookupField(fields, strings.Join(audienceFields, "|"))
+    if !ok {
+        return "", "", false
+    }
+    if list, isList := value.([]interface{}); isList {
+        var items []string
+        for _, item := range list {
+            items = append(items, fmt.Sprint(item))
+        }
+        return key, strings.Join(items, ", "), true
+    }
+    if value == nil {
+        return key, "", true
+    }
+    return key, fmt.Sprint(value), true
+}
@@ -0,0 +1,232 @@
+// Rule benchmarking and profiling
+
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "runtime"
+    "runtime/pprof"
+    "sort"
+    "time"
+)
+
+// RuleCost is the time one pattern rule or built-in check took over a
+// benchmarked corpus
+type RuleCost struct {
+    Name    string        `json:"name"`
+    Kind    string        `json:"kind"` // "rule" or "check"
+    Total   time.Duration `json:"total_ns"`
+    PerFile time.Duration `json:"per_file_ns"`
+    Share   float64       `json:"share"`         // fraction of all rule and check time
+    Ran     float64       `json:"ran,omitempty"` // fraction of lines a line rule ran on, past the literal prefilter
+}
+
+// FileCost is the time one file took to analyze
+type FileCost struct {
+    File  string        `json:"file"`
+    Total time.Duration `json:"total_ns"`
+}
+
+// BenchReport is the result of the bench subcommand
+type BenchReport struct {
+    Files int           `json:"files"`
+    Runs  int           `json:"runs"`
+    Parse time.Duration `json:"parse_ns"`
+    Rules []RuleCost    `json:"rules"`
+    Slow  []FileCost    `json:"slowest_files"`
+}
+
+// runBench implements the bench subcommand
+func runBench(args []string) int {
+    flags := flag.NewFlagSet("bench", flag.ExitOnError)
+    configPath := flags.String("config", "", "Path to configuration file")
+    outputFormat := flags.String("output", "standard", "Output format (standard, json)")
+    recursive := flags.Bool("recursive", false, "Process directories recursively")
+    followSymlinks := flags.Bool("follow-symlinks", false, "Descend into symlinked directories during recursive walks")
+    runs := flags.Int("runs", 3, "Number of times to analyze the corpus")
+    top := flags.Int("top", 10, "Number of slowest rules and files to report (0 for all)")
+    cpuProfile := flags.String("cpuprofile", "", "Write a CPU profile to this file")
+    memProfile := flags.String("memprofile", "", "Write a heap profile to this file")
+    flags.Parse(args)
+
+    if flags.NArg() == 0 || *runs < 1 {
+        fmt.Fprintf(os.Stderr, "Usage: %s bench [options] <file_or_directory>\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+
+    analyzer, err := NewAnalyzer(*configPath)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error creating analyzer: %v\n", err)
+        return 1
+    }
+
+    contents := make(map[string]string)
+    var files []string
+    for _, path := range flags.Args() {
+        found, err := collectFiles(path, WalkOptions{Recursive: *recursive, FollowSymlinks: *followSymlinks, Formats: analyzer.config.Formats})
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", path, err)
+            return 1
+        }
+        for _, file := range found {
+            content, err := readDocument(file)
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "Warning: failed to read %s: %v\n", file, err)
+                continue
+            }
+            contents[file] = content
+            files = append(files, file)
+        }
+    }
+
+    stop, err := startProfiling(*cpuProfile, *memProfile)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+    report := bench(analyzer, files, contents, *runs)
+    stop()
+
+    if *top > 0 {
+        report.Rules = report.Rules[:min(*top, len(report.Rules))]
+        report.Slow = report.Slow[:min(*top, len(report.Slow))]
+    }
+
+    switch *outputFormat {
+    case "json":
+        encoder := json.NewEncoder(os.Stdout)
+        encoder.SetIndent("", "  ")
+        if err := encoder.Encode(report); err != nil {
+            fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+            return 1
+        }
+    default:
+        printBenchReport(report)
+    }
+    return 0
+}
+
+// bench analyzes every file runs times and totals the time spent in each
+// rule, check and file, slowest first. Files are read beforehand so disk
+// I/O isn't measured.
+func bench(analyzer *Analyzer, files []string, contents map[string]string, runs int) BenchReport {
+    totals := newAnalysisTimings()
+    lineRules := make(map[string]bool)
+    for _, rule := range analyzer.rules {
+        // Listed even if the prefilter skips them on every line
+        totals.Rules[rule.Name] += 0
+        lineRules[rule.Name] = rule.Scope != "sentence" && !rule.Multiline
+    }
+    fileTimes := make(map[string]time.Duration)
+    for run := 0; run < runs; run++ {
+        for _, file := range files {
+            start := time.Now()
+            analyzer.analyzeTimed(file, contents[file], totals)
+            fileTimes[file] += time.Since(start)
+        }
+    }
+
+    report := BenchReport{Files: len(files), Runs: runs, Parse: totals.Parse}
+    var sum time.Duration
+    for _, cost := range totals.Rules {
+        sum += cost
+    }
+    for _, cost := range totals.Checks {
+        sum += cost
+    }
+    add := func(kind string, costs map[string]time.Duration) {
+        for name, cost := range costs {
+            rule := RuleCost{Name: name, Kind: kind, Total: cost}
+            if len(files) > 0 {
+                rule.PerFile = cost / time.Duration(len(files)*runs)
+            }
+            if sum > 0 {
+                rule.Share = float64(cost) / float64(sum)
+            }
+            if kind == "rule" && lineRules[name] && totals.Lines > 0 {
+                rule.Ran = float64(totals.RuleLines[name]) / float64(totals.Lines)
+            }
+            report.Rules = append(report.Rules, rule)
+        }
+    }
+    add("rule", totals.Rules)
+    add("check", totals.Checks)
+    sort.Slice(report.Rules, func(i, j int) bool {
+        if report.Rules[i].Total != report.Rules[j].Total {
+            return report.Rules[i].Total > report.Rules[j].Total
+        }
+        return report.Rules[i].Name < report.Rules[j].Name
+    })
+
+    for file, cost := range fileTimes {
+        report.Slow = append(report.Slow, FileCost{File: file, Total: cost})
+    }
+    sort.Slice(report.Slow, func(i, j int) bool {
+        if report.Slow[i].Total != report.Slow[j].Total {
+            return report.Slow[i].Total > report.Slow[j].Total
+        }
+        return report.Slow[i].File < report.Slow[j].File
+    })
+
+    return report
+}
+
+func printBenchReport(report BenchReport) {
+    fmt.Printf("Analyzed %d files %d times (parsing: %s)\n\n", report.Files, report.Runs, report.Parse.Round(time.Microsecond))
+
+    fmt.Println("Slowest rules:")
+    for _, rule := range report.Rules {
+        ran := ""
+        if rule.Ran > 0 {
+            ran = fmt.Sprintf("  ran on %.1f%% of lines", rule.Ran*100)
+        }
+        fmt.Printf("  %-36s %-5s %12s total %10s/file %5.1f%%%s\n",
+            rule.Name, rule.Kind, rule.Total.Round(time.Microsecond), rule.PerFile.Round(time.Microsecond), rule.Share*100, ran)
+    }
+
+    fmt.Println("\nSlowest files:")
+    for _, file := range report.Slow {
+        fmt.Printf("  %-48s %12s\n", file.File, file.Total.Round(time.Microsecond))
+    }
+}
+
+// startProfiling starts a CPU profile and returns a function that stops
+// it and writes a heap profile. Either path may be empty.
+func startProfiling(cpuPath, memPath string) (func(), error) {
+    var cpuFile *os.File
+    if cpuPath != "" {
+        var err error
+        cpuFile, err = os.Create(cpuPath)
+        if err != nil {
+            return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+        }
+        if err := pprof.StartCPUProfile(cpuFile); err != nil {
+            cpuFile.Close()
+            return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+        }
+    }
+
+    return func() {
+        if cpuFile != nil {
+            pprof.StopCPUProfile()
+            cpuFile.Close()
+        }
+        if memPath == "" {
+            return
+        }
+        memFile, err := os.Create(memPath)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Warning: failed to create heap profile: %v\n", err)
+            return
+        }
+        defer memFile.Close()
+        runtime.GC()
+        if err := pprof.WriteHeapProfile(memFile); err != nil {
+            fmt.Fprintf(os.Stderr, "Warning: failed to write heap profile: %v\n", err)
+        }
+    }, nil
+}
@@ -0,0 +1,105 @@
+// Boilerplate detection: paragraphs repeated verbatim across files
+
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// defaultMinBoilerplateFiles is used when the config doesn't set MinBoilerplateFiles
+const defaultMinBoilerplateFiles = 3
+
+// minBoilerplateWords keeps short stock phrases such as "See also" from
+// counting as boilerplate
+const minBoilerplateWords = 8
+
+// boilerplateKey normalizes a paragraph for comparison, ignoring case,
+// markup and line wrapping
+func boilerplateKey(doc *Document, paragraph Paragraph) string {
+    text := plainText(strings.Join(doc.Lines[paragraph.StartLine-1:paragraph.EndLine], " "))
+    words := strings.Fields(strings.ToLower(text))
+    if len(words) < minBoilerplateWords {
+        return ""
+    }
+    return strings.Join(words, " ")
+}
+
+// boilerplateParagraphs returns the keys of paragraphs that appear in at
+// least minFiles of docs, with the number of files each appears in
+func boilerplateParagraphs(docs []*Document, minFiles int) map[string]int {
+    if minFiles <= 0 {
+        minFiles = defaultMinBoilerplateFiles
+    }
+    files := make(map[string]map[string]bool)
+    for _, doc := range docs {
+        for _, paragraph := range doc.Paragraphs() {
+            key := boilerplateKey(doc, paragraph)
+            if key == "" {
+                continue
+            }
+            if files[key] == nil {
+                files[key] = make(map[string]bool)
+            }
+            files[key][doc.Path] = true
+        }
+    }
+
+    boilerplate := make(map[string]int)
+    for key, found := range files {
+        if len(found) >= minFiles {
+            boilerplate[key] = len(found)
+        }
+    }
+    return boilerplate
+}
+
+// analyzeBoilerplate reports each occurrence of a paragraph repeated
+// verbatim across files. Legal disclaimers and support blurbs copied into
+// every page dominate embedding similarity, so unrelated pages look alike
+// to retrieval, and they spend tokens in every chunk that carries them.
+func (a *Analyzer) analyzeBoilerplate(docs []*Document) []Issue {
+    boilerplate := boilerplateParagraphs(docs, a.config.MinBoilerplateFiles)
+    if len(boilerplate) == 0 {
+        return nil
+    }
+
+    var issues []Issue
+    for _, doc := range docs {
+        for _, paragraph := range doc.Paragraphs() {
+            count, ok := boilerplate[boilerplateKey(doc, paragraph)]
+            if !ok {
+                continue
+            }
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         paragraph.StartLine,
+                Column:       1,
+                Rule:         "boilerplate-paragraph",
+                Message:      fmt.Sprintf("Paragraph is repeated verbatim in %d files", count),
+                Severity:     "suggestion",
+                Suggestion:   "Move the paragraph into a shared include or template, and leave it out of exported chunks with 'export -skip-boilerplate'",
+                OriginalText: strings.TrimSpace(doc.Lines[paragraph.StartLine-1]),
+            })
+        }
+    }
+    return issues
+}
+
+// withoutBoilerplate returns a copy of doc with its boilerplate paragraphs
+// blanked out
+func withoutBoilerplate(doc *Document, boilerplate map[string]int) *Document {
+    stripped := *doc
+    stripped.Lines = append([]string(nil), doc.Lines...)
+
+    for _, paragraph := range doc.Paragraphs() {
+        if _, ok := boilerplate[boilerplateKey(doc, paragraph)]; !ok {
+            continue
+        }
+        for i := paragraph.StartLine - 1; i < paragraph.EndLine; i++ {
+            stripped.Lines[i] = ""
+        }
+    }
+
+    return &stripped
+}
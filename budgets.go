@@ -0,0 +1,279 @@
+// Issue budgets: the issues of each severity a directory may have, for
+// cleaning up a large doc set one directory at a time
+
+package main
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// BudgetFile is a -budget-file: for each directory, the number of issues
+// of each severity it may have before the run fails. An issue counts
+// against the most specific directory containing its file, and a severity
+// a directory's budget leaves out fails the run as it would without one.
+type BudgetFile struct {
+    Budgets map[string]map[string]int `yaml:"Budgets"` // directory -> severity -> issues allowed
+}
+
+// budgetUsage is what one directory used of its budget for one severity
+type budgetUsage struct {
+    dir, severity  string
+    count, allowed int
+}
+
+// over reports whether the directory has more issues than it's allowed
+func (u budgetUsage) over() bool {
+    return u.count > u.allowed
+}
+
+// loadBudgets reads a -budget-file, checking its severities against those
+// the configuration allows
+func loadBudgets(path string, severities severityPolicy) (*BudgetFile, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var file BudgetFile
+    if err := yaml.Unmarshal(data, &file); err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    if len(file.Budgets) == 0 {
+        return nil, fmt.Errorf("%s lists no Budgets", path)
+    }
+    for _, dir := range sortedKeys(file.Budgets) {
+        for _, severity := range sortedKeys(file.Budgets[dir]) {
+            switch count := file.Budgets[dir][severity]; {
+            case !severities.allows(severity):
+                return nil, fmt.Errorf("%s: invalid severity %q for %s (want %s)", path, severity, dir, severities.names())
+            case count < 0:
+                return nil, fmt.Errorf("%s: invalid budget %d of %s for %s (want 0 or more)", path, count, severity, dir)
+            }
+        }
+    }
+    return &file, nil
+}
+
+// owner returns the most specific budgeted directory containing file, or
+// "" if none does
+func (b *BudgetFile) owner(file string) string {
+    abs, err := filepath.Abs(file)
+    if err != nil {
+        return ""
+    }
+    owner, depth := "", -1
+    for _, dir := range sortedKeys(b.Budgets) {
+        absDir, err := filepath.Abs(dir)
+        if err != nil {
+            continue
+        }
+        rel, err := filepath.Rel(absDir, abs)
+        if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+            continue
+        }
+        if n := len(absDir); n > depth {
+            owner, depth = dir, n
+        }
+    }
+    return owner
+}
+
+// tally counts the issues against the budgets. It returns the usage of
+// every budget, in directory and severity order, and the issues no budget
+// covers, which fail the run by their severity as usual. Read failures
+// are never budgeted.
+func (b *BudgetFile) tally(issues []Issue) ([]budgetUsage, []Issue) {
+    counts := make(map[string]map[string]int)
+    var unbudgeted []Issue
+    for _, issue := range issues {
+        dir := b.owner(issue.File)
+        if _, ok := b.Budgets[dir][issue.Severity]; !ok || dir == "" || issue.Severity == failureSeverity {
+            unbudgeted = append(unbudgeted, issue)
+            continue
+        }
+        if counts[dir] == nil {
+            counts[dir] = make(map[string]int)
+        }
+        counts[dir][issue.Severity]++
+    }
+
+    var usage []budgetUsage
+    for _, dir := range sortedKeys(b.Budgets) {
+        for _, severity := range sortedKeys(b.Budgets[dir]) {
+            usage = append(usage, budgetUsage{dir, severity, counts[dir][severity], b.Budgets[dir][severity]})
+        }
+    }
+    return usage, unbudgeted
+}
+
+// budgetsExceeded reports whether any directory is over its budget
+func budgetsExceeded(usage []budgetUsage) bool {
+    for _, u := range usage {
+        if u.over() {
+            return true
+        }
+    }
+    return false
+}
+
+// printBudgets writes the budgets a run exceeded, and those it could lower
+// with -ratchet-budgets. With ratcheting, lowered says how many it did.
+func printBudgets(w io.Writer, usage []budgetUsage, ratcheting bool, lowered int) {
+    within, lower := 0, 0
+    for _, u := range usage {
+        switch {
+        case u.over():
+            fmt.Fprintf(w, "Over budget: %s has %d %s issue(s), %d over its budget of %d\n", u.dir, u.count, u.severity, u.count-u.allowed, u.allowed)
+        case u.count < u.allowed:
+            within++
+            lower++
+        default:
+            within++
+        }
+    }
+    summary := fmt.Sprintf("Budgets: %d of %d within budget", within, len(usage))
+    if lowered > 0 {
+        summary += fmt.Sprintf("; lowered %d to this run's counts", lowered)
+    }
+    switch left := lower - lowered; {
+    case left > 0 && ratcheting:
+        summary += fmt.Sprintf("; %d left as they were", left)
+    case left > 0:
+        summary += fmt.Sprintf("; %d can be lowered to this run's counts with -ratchet-budgets", left)
+    }
+    fmt.Fprintln(w, summary)
+}
+
+// ratchetBlocker returns why a run's counts can't lower the budgets, or ""
+// if they can: the issues of files that couldn't be read or were skipped
+// went uncounted
+func ratchetBlocker(issues []Issue) string {
+    unread, skipped := 0, 0
+    for _, issue := range issues {
+        switch {
+        case issue.Severity == failureSeverity:
+            unread++
+        case issue.Rule == "file-skipped":
+            skipped++
+        }
+    }
+    switch {
+    case unread > 0:
+        return fmt.Sprintf("%d path(s) couldn't be read", unread)
+    case skipped > 0:
+        return fmt.Sprintf("%d file(s) were skipped", skipped)
+    }
+    return ""
+}
+
+// analyzedWhole reports whether the paths, walked with recursive, take in
+// every file of dir, so a run's count for it is the directory's own. A
+// directory only partly analyzed would have its budget lowered to the
+// issues of the files the run happened to read.
+func analyzedWhole(dir string, paths []string, recursive bool) bool {
+    if !recursive {
+        return false
+    }
+    absDir, err := filepath.Abs(dir)
+    if err != nil {
+        return false
+    }
+    for _, path := range paths {
+        if info, err := os.Stat(path); err != nil || !info.IsDir() {
+            continue
+        }
+        absPath, err := filepath.Abs(path)
+        if err != nil {
+            continue
+        }
+        if rel, err := filepath.Rel(absPath, absDir); err == nil && filepath.IsLocal(rel) {
+            return true
+        }
+    }
+    return false
+}
+
+// ratchet lowers each budget the run stayed under to the run's count, so
+// a cleaned-up directory can't regress, for the directories whole reports
+// the run analyzed whole. Budgets are never raised. Only the lowered
+// numbers are rewritten in the budget file, which keeps its comments and
+// layout. It returns how many budgets it lowered.
+func (b *BudgetFile) ratchet(path string, usage []budgetUsage, whole func(dir string) bool) (int, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return 0, err
+    }
+    var doc yaml.Node
+    if err := yaml.Unmarshal(data, &doc); err != nil {
+        return 0, fmt.Errorf("%s: %w", path, err)
+    }
+    field := func(node *yaml.Node, key string) *yaml.Node {
+        if node == nil || node.Kind != yaml.MappingNode {
+            return nil
+        }
+        for i := 0; i+1 < len(node.Content); i += 2 {
+            if node.Content[i].Value == key {
+                return node.Content[i+1]
+            }
+        }
+        return nil
+    }
+    var root *yaml.Node
+    if len(doc.Content) > 0 {
+        root = field(doc.Content[0], "Budgets")
+    }
+
+    type edit struct {
+        usage budgetUsage
+        node  *yaml.Node
+    }
+    var edits []edit
+    for _, u := range usage {
+        if u.count >= u.allowed || !whole(u.dir) {
+            continue
+        }
+        node := field(field(root, u.dir), u.severity)
+        if node == nil {
+            return 0, fmt.Errorf("%s: no %s budget for %s", path, u.severity, u.dir)
+        }
+        edits = append(edits, edit{u, node})
+    }
+    if len(edits) == 0 {
+        return 0, nil
+    }
+
+    // From the end, so each edit leaves the positions of the rest in place
+    sort.Slice(edits, func(i, j int) bool {
+        if edits[i].node.Line != edits[j].node.Line {
+            return edits[i].node.Line > edits[j].node.Line
+        }
+        return edits[i].node.Column > edits[j].node.Column
+    })
+    lines := strings.SplitAfter(string(data), "\n")
+    for _, e := range edits {
+        var line []rune
+        if e.node.Line <= len(lines) {
+            line = []rune(lines[e.node.Line-1])
+        }
+        start := e.node.Column - 1
+        end := start + len([]rune(e.node.Value))
+        if end > len(line) || string(line[start:end]) != e.node.Value {
+            return 0, fmt.Errorf("%s:%d: can't rewrite budget %q", path, e.node.Line, e.node.Value)
+        }
+        lines[e.node.Line-1] = string(line[:start]) + strconv.Itoa(e.usage.count) + string(line[end:])
+    }
+    if err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0o644); err != nil {
+        return 0, err
+    }
+    for _, e := range edits {
+        b.Budgets[e.usage.dir][e.usage.severity] = e.usage.count
+    }
+    return len(edits), nil
+}
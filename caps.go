@@ -0,0 +1,82 @@
+// Caps on the issues reported per file and per rule
+
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// IssueCaps bounds how many issues a run reports for any one file, so a
+// pathological file, such as a generated changelog, can't drown the report
+type IssueCaps struct {
+    PerRule int // issues of one rule in one file; 0 for no limit
+    PerFile int // issues in one file; 0 for no limit
+
+    Severities severityPolicy // ranks the omitted issues' severities; the built-in ones if nil
+}
+
+// omittedIssues tallies the issues a cap dropped from one file
+type omittedIssues struct {
+    first    Issue
+    total    int
+    byRule   map[string]int
+    severity string
+}
+
+// apply keeps the first issues of each file up to the caps, in their
+// order, and replaces the rest of each file's issues with one
+// issues-omitted issue counting them by rule. The summary carries the most
+// severe omitted severity, so capping never turns a failing run into a
+// passing one.
+func (c IssueCaps) apply(issues []Issue) []Issue {
+    if c.PerRule <= 0 && c.PerFile <= 0 {
+        return issues
+    }
+
+    perRule := make(map[[2]string]int)
+    perFile := make(map[string]int)
+    omitted := make(map[string]*omittedIssues)
+    var omittedFiles []string
+    var kept []Issue
+    for _, issue := range issues {
+        key := [2]string{issue.File, issue.Rule}
+        if (c.PerRule <= 0 || perRule[key] < c.PerRule) && (c.PerFile <= 0 || perFile[issue.File] < c.PerFile) {
+            perRule[key]++
+            perFile[issue.File]++
+            kept = append(kept, issue)
+            continue
+        }
+        o := omitted[issue.File]
+        if o == nil {
+            o = &omittedIssues{first: issue, byRule: make(map[string]int)}
+            omitted[issue.File] = o
+            omittedFiles = append(omittedFiles, issue.File)
+        }
+        o.total++
+        o.byRule[issue.Rule]++
+        if c.Severities.rank(issue.Severity) > c.Severities.rank(o.severity) {
+            o.severity = issue.Severity
+        }
+    }
+
+    for _, file := range omittedFiles {
+        o := omitted[file]
+        var counts []string
+        for _, rule := range sortedKeys(o.byRule) {
+            counts = append(counts, fmt.Sprintf("%s %d", rule, o.byRule[rule]))
+        }
+        kept = append(kept, Issue{
+            File:       file,
+            Line:       o.first.Line,
+            Column:     o.first.Column,
+            Rule:       "issues-omitted",
+            Message:    fmt.Sprintf("...and %d more issue(s) in this file (%s)", o.total, strings.Join(counts, ", ")),
+            Severity:   o.severity,
+            Suggestion: "Fix or exclude the file, or raise -max-issues-per-rule / -max-issues-per-file to see every issue",
+            URL:        o.first.URL,
+            Root:       o.first.Root,
+        })
+    }
+    return kept
+}
@@ -0,0 +1,319 @@
+// Video captions and transcripts: SRT and WebVTT files
+
+package main
+
+import (
+    "fmt"
+    "html"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+    "unicode"
+)
+
+// captionExtensions are the file extensions of caption files
+var captionExtensions = []string{".srt", ".vtt"}
+
+// captionPause is the gap between cues that ends a paragraph of narration
+const captionPause = 2 * time.Second
+
+var (
+    // captionTimingRegex matches a cue's timing line, in SRT's
+    // "00:01:02,500 --> 00:01:05,000" or WebVTT's "01:02.500 --> 01:05.000"
+    captionTimingRegex = regexp.MustCompile(`^\s*((?:\d+:)?\d{1,2}:\d{2}[.,]\d{3})\s+-->\s+((?:\d+:)?\d{1,2}:\d{2}[.,]\d{3})`)
+    // captionMarkupRegex matches WebVTT and SRT cue markup: voice, class
+    // and timestamp tags, <i> and <b>, and {\an8} style overrides
+    captionMarkupRegex = regexp.MustCompile(`^(?:<[^>\n]*>|\{\\[^}\n]*\})`)
+    // captionSpeakerRegex matches the dash or chevrons that mark a change
+    // of speaker at the start of a line
+    captionSpeakerRegex = regexp.MustCompile(`^(?:-|>>)\s*`)
+    // deicticNarrationRegex matches narration that points at the screen:
+    // "click here", "select this", "over there", "this button"
+    deicticNarrationRegex = regexp.MustCompile(`(?i)\b(?:(?:click|tap|press|hit|select|choose|pick|drag|type|enter|check|uncheck|open|drop|paste|scroll|go)\s+(?:on\s+|in\s+|into\s+|to\s+|over\s+|up\s+|down\s+)?(?:here|there|this(?: one)?|that(?: one)?|these|those)|(?:over|up|down|right|in|out) (?:here|there)|like (?:this|that|so)|(?:this|that) (?:one|guy|thing|bit|part|area|box|button|menu|field|icon|tab|link|option|setting|window|panel))\b`)
+)
+
+// captionCue is one timed cue of a caption file, with its text lines and
+// where in the file each byte of them is
+type captionCue struct {
+    start, end time.Duration
+    text       embeddedText
+}
+
+// isCaptionFile reports whether a path is an SRT or WebVTT caption file
+func isCaptionFile(path string) bool {
+    ext := strings.ToLower(filepath.Ext(path))
+    for _, caption := range captionExtensions {
+        if ext == caption {
+            return true
+        }
+    }
+    return false
+}
+
+// parseCaptions returns the cues of an SRT or WebVTT file in order. A cue
+// is a block of lines with a timing line, whose text is the lines after it;
+// the WebVTT header and NOTE, STYLE and REGION blocks have none.
+func parseCaptions(content string) []captionCue {
+    lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+    var cues []captionCue
+    for i := 0; i < len(lines); i++ {
+        timing := captionTimingRegex.FindStringSubmatch(lines[i])
+        if timing == nil {
+            continue
+        }
+        cue := captionCue{start: parseCaptionTime(timing[1]), end: parseCaptionTime(timing[2])}
+        for i++; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+            text, offsets := captionLineText(lines[i])
+            if text == "" {
+                continue
+            }
+            cue.text.lines = append(cue.text.lines, text)
+            cue.text.positions = append(cue.text.positions, [2]int{i + 1, offsets[0]})
+            cue.text.offsets = append(cue.text.offsets, offsets)
+        }
+        if len(cue.text.lines) > 0 {
+            cues = append(cues, cue)
+        }
+    }
+    return cues
+}
+
+// parseCaptionTime parses a cue timestamp, with a comma or a period before
+// the milliseconds and with or without hours
+func parseCaptionTime(timestamp string) time.Duration {
+    parts := strings.Split(strings.Replace(timestamp, ",", ".", 1), ":")
+    var total time.Duration
+    for _, part := range parts[:len(parts)-1] {
+        n, _ := strconv.Atoi(part)
+        total = total*60 + time.Duration(n)*time.Minute
+    }
+    seconds, _ := strconv.ParseFloat(parts[len(parts)-1], 64)
+    return total + time.Duration(seconds*float64(time.Second))
+}
+
+// formatCaptionTime formats a duration as a WebVTT timestamp,
+// "00:01:02.500"
+func formatCaptionTime(d time.Duration) string {
+    ms := d.Milliseconds()
+    return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// captionLineText returns a cue line without its markup and speaker
+// marker, with character references decoded and whitespace collapsed, and
+// the column in the line of each of its bytes
+func captionLineText(line string) (string, []int) {
+    var text []byte
+    var offsets []int
+    add := func(s string, column int) {
+        if s == " " && (len(text) == 0 || text[len(text)-1] == ' ') {
+            return
+        }
+        text = append(text, s...)
+        for i := 0; i < len(s); i++ {
+            offsets = append(offsets, column)
+        }
+    }
+    i := len(captionSpeakerRegex.FindString(strings.TrimLeft(line, " \t"))) + len(line) - len(strings.TrimLeft(line, " \t"))
+    for i < len(line) {
+        if markup := captionMarkupRegex.FindString(line[i:]); markup != "" {
+            i += len(markup)
+            continue
+        }
+        if entity := emailEntityRegex.FindString(line[i:]); entity != "" {
+            decoded := html.UnescapeString(entity)
+            if decoded == "\u00a0" {
+                decoded = " "
+            }
+            add(decoded, i)
+            i += len(entity)
+            continue
+        }
+        if unicode.IsSpace(rune(line[i])) {
+            add(" ", i)
+        } else {
+            add(line[i:i+1], i)
+        }
+        i++
+    }
+    for len(text) > 0 && text[len(text)-1] == ' ' {
+        text, offsets = text[:len(text)-1], offsets[:len(offsets)-1]
+    }
+    return string(text), offsets
+}
+
+// captionTranscript joins the cues into narration, a blank line between
+// cues a pause apart, so sentences run across cues and paragraphs end at
+// pauses. It also returns the cue each line comes from, -1 for the blanks.
+func captionTranscript(cues []captionCue) (embeddedText, []int) {
+    var transcript embeddedText
+    var cueOf []int
+    for i, cue := range cues {
+        if i > 0 && cue.start-cues[i-1].end >= captionPause {
+            transcript.lines = append(transcript.lines, "")
+            transcript.positions = append(transcript.positions, cue.text.positions[0])
+            transcript.offsets = append(transcript.offsets, nil)
+            cueOf = append(cueOf, -1)
+        }
+        transcript.lines = append(transcript.lines, cue.text.lines...)
+        transcript.positions = append(transcript.positions, cue.text.positions...)
+        transcript.offsets = append(transcript.offsets, cue.text.offsets...)
+        for range cue.text.lines {
+            cueOf = append(cueOf, i)
+        }
+    }
+    return transcript, cueOf
+}
+
+// captionDocument parses a caption file for the cross-file checks and
+// export: each cue's text on its own line, other lines blank, so line
+// numbers match the file, with the cues kept for their timing
+func captionDocument(path, content string) *Document {
+    cues := parseCaptions(content)
+    lines := make([]string, strings.Count(content, "\n")+1)
+    for _, cue := range cues {
+        for k, line := range cue.text.lines {
+            lines[cue.text.positions[k][0]-1] = line
+        }
+    }
+    doc := ParseDocument(path, strings.Join(lines, "\n"))
+    doc.Captions = cues
+    return doc
+}
+
+// analyzeCaptions runs the line, sentence and multiline rules over a
+// caption file's narration, merged across cues, reporting issues at their
+// position in the file, and flags narration that only points at the
+// screen. A transcript is how a video gets answered from, and "click here,
+// then select this" answers nothing without the picture.
+func (a *Analyzer) analyzeCaptions(filePath, content string) []Issue {
+    file := ParseDocument(filePath, content)
+    cues := parseCaptions(content)
+    if len(cues) == 0 {
+        if strings.TrimSpace(content) == "" {
+            return nil
+        }
+        return []Issue{{
+            File:       filePath,
+            Line:       1,
+            Column:     1,
+            Rule:       parseFailureRule,
+            Message:    "Caption file has no timed cues, so its narration isn't checked",
+            Severity:   failureSeverity,
+            Suggestion: "Give each cue a timing line such as '00:00:01.000 --> 00:00:04.000'",
+        }}
+    }
+    transcript, _ := captionTranscript(cues)
+    issues := a.analyzeEmbedded(filePath, []embeddedText{transcript})
+
+    narration := ParseDocument(filePath, strings.Join(transcript.lines, "\n"))
+    for _, paragraph := range narration.Paragraphs() {
+        for _, sentence := range paragraph.Sentences() {
+            match := deicticNarrationRegex.FindStringIndex(sentence.Text)
+            if match == nil || namesOnScreenTarget(sentence.Text) {
+                continue
+            }
+            line, column := paragraph.Position(sentence, match[0])
+            fileLine, fileColumn := transcript.position(line-1, column-1)
+            issues = append(issues, Issue{
+                File:         filePath,
+                Line:         fileLine,
+                Column:       fileColumn + 1,
+                Rule:         "caption-deictic-narration",
+                Message:      fmt.Sprintf("Narration %q points at the screen without naming what's there", sentence.Text[match[0]:match[1]]),
+                Severity:     "warning",
+                Suggestion:   "Name what's on screen, such as 'select Save in the toolbar', so the transcript makes sense without the video",
+                OriginalText: strings.TrimSpace(file.Lines[fileLine-1]),
+            })
+        }
+    }
+
+    issues = a.filterPageTypes(issues, classifyPage(narration))
+    return a.applyOverrides(a.convertColumns(file, issues))
+}
+
+// namesOnScreenTarget reports whether a sentence names what it points at:
+// a capitalized name after its first word, or quoted or code text
+func namesOnScreenTarget(sentence string) bool {
+    if strings.ContainsAny(sentence, "\"`“") {
+        return true
+    }
+    words := strings.Fields(sentence)
+    for i := 1; i < len(words); i++ {
+        word := strings.TrimLeft(words[i], "('")
+        if word == "" || word == "I" || strings.HasPrefix(word, "I'") || strings.HasPrefix(word, "I’") {
+            continue
+        }
+        if first := []rune(word)[0]; unicode.IsUpper(first) || unicode.IsDigit(first) {
+            return true
+        }
+    }
+    return false
+}
+
+// transcriptChunks splits a caption file's narration into chunks of whole
+// sentences, each ending at a pause or once it fills the token budget, with
+// the time span it covers. Its ID is the file with a media fragment for
+// its start, such as "intro.vtt#t=83.5", which players and multimodal
+// pipelines can seek to.
+func transcriptChunks(doc *Document, options ExportOptions, navPath []string) []Chunk {
+    transcript, cueOf := captionTranscript(doc.Captions)
+    narration := ParseDocument(doc.Path, strings.Join(transcript.lines, "\n"))
+    budget := options.MaxTokens
+    if budget <= 0 {
+        budget = defaultChunkTokens
+    }
+    var products map[string]bool
+    if options.Score {
+        products = productTerms(narration)
+    }
+
+    var chunks []Chunk
+    for _, paragraph := range narration.Paragraphs() {
+        var sentences []string
+        first, last := -1, -1
+        flush := func() {
+            if len(sentences) == 0 {
+                return
+            }
+            body := strings.Join(sentences, " ")
+            text := body
+            if options.Breadcrumbs && len(navPath) > 0 {
+                text = strings.Join(navPath, " > ") + "\n\n" + body
+            }
+            var quality *ChunkQuality
+            if options.Score {
+                quality = scoreChunk(Section{}, body, products, options)
+            }
+            start, end := doc.Captions[first].start, doc.Captions[last].end
+            chunks = append(chunks, Chunk{
+                ID:          doc.Path + "#t=" + strconv.FormatFloat(start.Seconds(), 'f', -1, 64),
+                File:        doc.Path,
+                NavPath:     navPath,
+                Line:        doc.Captions[first].text.positions[0][0],
+                ContentHash: contentHash(body),
+                Text:        text,
+                Type:        "transcript",
+                Start:       formatCaptionTime(start),
+                End:         formatCaptionTime(end),
+                Quality:     quality,
+            })
+            sentences, first = nil, -1
+        }
+        for _, sentence := range paragraph.Sentences() {
+            startLine, _ := paragraph.Position(sentence, 0)
+            endLine, _ := paragraph.Position(sentence, len(sentence.Text)-1)
+            if first < 0 {
+                first = cueOf[startLine-1]
+            }
+            last = cueOf[endLine-1]
+            sentences = append(sentences, sentence.Text)
+            if estimateTokens(strings.Join(sentences, " ")) >= budget {
+                flush()
+            }
+        }
+        flush()
+    }
+    return chunks
+}
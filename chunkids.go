@@ -0,0 +1,129 @@
+// Stable chunk IDs and heading anchors
+
+package main
+
+import (
+    "bufio"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "os"
+    "regexp"
+    "strings"
+)
+
+var (
+    // customAnchorRegex matches an explicit heading ID, as in "## Setup {#setup}"
+    customAnchorRegex = regexp.MustCompile(`\s*\{#([\w-]+)\}\s*$`)
+    // anchorStripRegex matches what GitHub-style slugs leave out of an anchor
+    anchorStripRegex = regexp.MustCompile(`[^\p{L}\p{N}\s_-]`)
+)
+
+// headingAnchor returns the anchor site generators give a heading: its
+// explicit {#id}, or its text lowercased, without punctuation, and with
+// spaces as hyphens
+func headingAnchor(heading string) string {
+    if match := customAnchorRegex.FindStringSubmatch(heading); match != nil {
+        return match[1]
+    }
+    text := anchorStripRegex.ReplaceAllString(strings.ToLower(plainText(heading)), "")
+    return strings.ReplaceAll(strings.TrimSpace(text), " ", "-")
+}
+
+// sectionIdentities returns the page anchor and the chunk ID of each
+// headed section of doc, by heading line. A repeated anchor gets a -1, -2
+// suffix, as on GitHub. The ID is the file and the anchors of the heading
+// path, as in "docs/sync.md#setup/schedule", so it survives edits to the
+// section's content and to unrelated headings; a repeated path is
+// suffixed the same way as an anchor.
+func sectionIdentities(doc *Document) (anchors, ids map[int]string) {
+    anchors, ids = make(map[int]string), make(map[int]string)
+    unique := func(seen map[string]int, key string) string {
+        count := seen[key]
+        seen[key]++
+        if count > 0 {
+            return fmt.Sprintf("%s-%d", key, count)
+        }
+        return key
+    }
+
+    seenAnchors, seenPaths := make(map[string]int), make(map[string]int)
+    for _, section := range doc.Sections {
+        if section.Line == 0 {
+            continue
+        }
+        anchors[section.Line] = unique(seenAnchors, headingAnchor(section.Heading))
+        parts := make([]string, len(section.Path))
+        for i, heading := range section.Path {
+            parts[i] = headingAnchor(heading)
+        }
+        ids[section.Line] = doc.Path + "#" + unique(seenPaths, strings.Join(parts, "/"))
+    }
+    return anchors, ids
+}
+
+// contentHash fingerprints a chunk's content, without its heading, so a
+// changed hash under the same ID means the section's text changed
+func contentHash(text string) string {
+    sum := sha256.Sum256([]byte(text))
+    return hex.EncodeToString(sum[:8])
+}
+
+// loadChunks reads chunks written by the export subcommand
+func loadChunks(path string) ([]Chunk, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+
+    var chunks []Chunk
+    scanner := bufio.NewScanner(file)
+    scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+    for line := 1; scanner.Scan(); line++ {
+        if strings.TrimSpace(scanner.Text()) == "" {
+            continue
+        }
+        var chunk Chunk
+        if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+            return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+        }
+        chunks = append(chunks, chunk)
+    }
+    return chunks, scanner.Err()
+}
+
+// renamedChunks describes the chunks of a previous export whose ID is gone
+// while a chunk of the same file has their content: a heading was renamed
+// or moved, which breaks the old ID and any links to the old anchor. A
+// chunk that keeps its ID can still change anchor when a repeated heading
+// above it is renamed.
+func renamedChunks(previous, current []Chunk) []string {
+    byID := make(map[string]Chunk)
+    byContent := make(map[string]Chunk)
+    for _, chunk := range current {
+        byID[chunk.ID] = chunk
+        byContent[chunk.File+"\x00"+chunk.ContentHash] = chunk
+    }
+
+    var renames []string
+    for _, old := range previous {
+        if chunk, ok := byID[old.ID]; ok {
+            if old.Anchor != chunk.Anchor && old.Anchor != "" {
+                renames = append(renames, fmt.Sprintf("%s:%d: heading change moves the anchor of %s from #%s to #%s, breaking links to it", chunk.File, chunk.Line, chunk.ID, old.Anchor, chunk.Anchor))
+            }
+            continue
+        }
+        chunk, ok := byContent[old.File+"\x00"+old.ContentHash]
+        if !ok {
+            continue // removed or rewritten, not renamed
+        }
+        message := fmt.Sprintf("%s:%d: heading change replaces chunk ID %s with %s", chunk.File, chunk.Line, old.ID, chunk.ID)
+        if old.Anchor != chunk.Anchor && old.Anchor != "" {
+            message += fmt.Sprintf(" and breaks links to #%s (now #%s)", old.Anchor, chunk.Anchor)
+        }
+        renames = append(renames, message)
+    }
+    return renames
+}
@@ -0,0 +1,269 @@
+// Chunk overlap simulation, for tuning the chunk size and overlap of an
+// ingestion pipeline
+
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// OverlapReport is what chunking the corpus with one size and overlap
+// costs
+type OverlapReport struct {
+    Size            int              `json:"size"`
+    Overlap         int              `json:"overlap"`
+    Tokens          int              `json:"tokens"` // of the corpus, estimated
+    Chunks          int              `json:"chunks"`
+    ChunkTokens     int              `json:"chunk_tokens"`      // of all chunks, overlap included
+    Duplicated      int              `json:"duplicated_tokens"` // repeated by overlap
+    Ratio           float64          `json:"duplication_ratio"` // duplicated tokens per corpus token
+    SplitParagraphs int              `json:"split_paragraphs"`  // paragraphs longer than a chunk, cut mid-paragraph
+    Sections        []SectionOverlap `json:"sections,omitempty"`
+}
+
+// SectionOverlap is what chunking one section costs, for a section with
+// paragraphs longer than a chunk
+type SectionOverlap struct {
+    File             string   `json:"file"`
+    Line             int      `json:"line"`
+    HeadingPath      []string `json:"heading_path"`
+    Tokens           int      `json:"tokens"`
+    Chunks           int      `json:"chunks"`
+    Duplicated       int      `json:"duplicated_tokens"`
+    SplitParagraphs  int      `json:"split_paragraphs"`
+    LongestParagraph int      `json:"longest_paragraph"` // in tokens
+}
+
+// chunkBlocks splits a chunk's text into the blocks a splitter keeps
+// together: paragraphs, list and table runs, and whole code blocks
+func chunkBlocks(text string) []string {
+    var blocks []string
+    var block []string
+    fence := ""
+    for _, line := range strings.Split(text, "\n") {
+        trimmed := strings.TrimSpace(line)
+        switch {
+        case fence != "":
+            if strings.HasPrefix(trimmed, fence) {
+                fence = ""
+            }
+        case strings.HasPrefix(trimmed, "```"), strings.HasPrefix(trimmed, "~~~"):
+            fence = trimmed[:3]
+        case trimmed == "":
+            if len(block) > 0 {
+                blocks = append(blocks, strings.Join(block, "\n"))
+                block = nil
+            }
+            continue
+        }
+        block = append(block, line)
+    }
+    if len(block) > 0 {
+        blocks = append(blocks, strings.Join(block, "\n"))
+    }
+    return blocks
+}
+
+// simulateChunking splits blocks of the given token counts the way
+// paragraph-aware splitters do: whole blocks are packed into chunks of up
+// to size tokens, and each new chunk repeats as many whole trailing blocks
+// of the previous one as fit in overlap. A block longer than size is cut
+// into windows of size tokens that each repeat overlap tokens of the one
+// before. It returns the number of chunks, the tokens repeated, and the
+// number of blocks cut.
+func simulateChunking(blocks []int, size, overlap int) (chunks, duplicated, split int) {
+    current := 0   // tokens of the chunk being filled
+    var tail []int // its whole blocks, which the next chunk may repeat
+    cut := 0       // tokens of the last window of a cut block, which it may repeat in part
+    for _, block := range blocks {
+        if block > size {
+            if current > 0 {
+                chunks++
+            }
+            windows := 1 + (block-size+size-overlap-1)/(size-overlap)
+            chunks += windows - 1
+            duplicated += (windows - 1) * overlap
+            split++
+            current = block - (windows-1)*(size-overlap)
+            tail, cut = nil, current
+            continue
+        }
+
+        if current > 0 && current+block > size {
+            chunks++
+            carry := 0
+            var carried []int
+            if len(tail) == 0 {
+                carry = min(cut, overlap)
+            }
+            for i := len(tail) - 1; i >= 0 && carry+tail[i] <= overlap; i-- {
+                carry += tail[i]
+                carried = append([]int{tail[i]}, carried...)
+            }
+            if carry+block > size {
+                carry, carried = 0, nil
+            }
+            duplicated += carry
+            current, tail, cut = carry, carried, 0
+        }
+        current += block
+        tail = append(tail, block)
+    }
+    if current > 0 {
+        chunks++
+    }
+    return chunks, duplicated, split
+}
+
+// overlapReport simulates chunking the chunks export would write with one
+// size and overlap
+func overlapReport(chunks []Chunk, size, overlap int) OverlapReport {
+    report := OverlapReport{Size: size, Overlap: overlap}
+    for _, chunk := range chunks {
+        var tokens []int
+        section := SectionOverlap{File: chunk.File, Line: chunk.Line, HeadingPath: chunk.HeadingPath}
+        for _, block := range chunkBlocks(chunk.Text) {
+            n := estimateTokens(block)
+            tokens = append(tokens, n)
+            section.Tokens += n
+            section.LongestParagraph = max(section.LongestParagraph, n)
+        }
+        section.Chunks, section.Duplicated, section.SplitParagraphs = simulateChunking(tokens, size, overlap)
+
+        report.Tokens += section.Tokens
+        report.Chunks += section.Chunks
+        report.Duplicated += section.Duplicated
+        report.SplitParagraphs += section.SplitParagraphs
+        if section.SplitParagraphs > 0 {
+            report.Sections = append(report.Sections, section)
+        }
+    }
+    report.ChunkTokens = report.Tokens + report.Duplicated
+    if report.Tokens > 0 {
+        report.Ratio = float64(report.Duplicated) / float64(report.Tokens)
+    }
+    sort.SliceStable(report.Sections, func(i, j int) bool {
+        x, y := report.Sections[i], report.Sections[j]
+        if x.Duplicated != y.Duplicated {
+            return x.Duplicated > y.Duplicated
+        }
+        return x.LongestParagraph > y.LongestParagraph
+    })
+    return report
+}
+
+// parseIntList parses a comma-separated list of integers
+func parseIntList(list string) ([]int, error) {
+    var values []int
+    for _, field := range strings.Split(list, ",") {
+        value, err := strconv.Atoi(strings.TrimSpace(field))
+        if err != nil || value < 0 {
+            return nil, fmt.Errorf("invalid number %q", field)
+        }
+        values = append(values, value)
+    }
+    return values, nil
+}
+
+// runChunkOverlap implements the chunk-overlap subcommand, reporting how
+// much of the corpus chunk overlap duplicates for each size and overlap
+// given, and which sections force the most overlap with paragraphs longer
+// than a chunk
+func runChunkOverlap(args []string) int {
+    flags := flag.NewFlagSet("chunk-overlap", flag.ExitOnError)
+    sizeList := flags.String("size", strconv.Itoa(defaultChunkTokens), "Chunk size in tokens, or a comma-separated list to compare")
+    overlapList := flags.String("overlap", "64", "Overlap between chunks in tokens, or a comma-separated list to compare")
+    top := flags.Int("top", 10, "Sections to list per size and overlap (0 for all)")
+    outputFormat := flags.String("output", "standard", "Output format (standard, json)")
+    recursive := flags.Bool("recursive", false, "Process directories recursively")
+    followSymlinks := flags.Bool("follow-symlinks", false, "Descend into symlinked directories during recursive walks")
+    flags.Parse(args)
+
+    if flags.NArg() == 0 {
+        fmt.Fprintf(os.Stderr, "Usage: %s chunk-overlap [options] <file_or_directory>...\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+    sizes, err := parseIntList(*sizeList)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: -size: %v\n", err)
+        return 1
+    }
+    overlaps, err := parseIntList(*overlapList)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: -overlap: %v\n", err)
+        return 1
+    }
+
+    var files []string
+    for _, path := range flags.Args() {
+        found, err := collectFiles(path, WalkOptions{Recursive: *recursive, FollowSymlinks: *followSymlinks})
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", path, err)
+            return 1
+        }
+        files = append(files, found...)
+    }
+    var chunks []Chunk
+    for _, doc := range loadDocuments(files, nil) {
+        chunks = append(chunks, buildChunks(doc, ExportOptions{})...)
+    }
+
+    var reports []OverlapReport
+    for _, size := range sizes {
+        for _, overlap := range overlaps {
+            if overlap >= size {
+                fmt.Fprintf(os.Stderr, "Error: overlap %d isn't smaller than chunk size %d\n", overlap, size)
+                return 1
+            }
+            report := overlapReport(chunks, size, overlap)
+            if *top > 0 && len(report.Sections) > *top {
+                report.Sections = report.Sections[:*top]
+            }
+            reports = append(reports, report)
+        }
+    }
+
+    if *outputFormat == "json" {
+        encoder := json.NewEncoder(os.Stdout)
+        encoder.SetIndent("", "  ")
+        if err := encoder.Encode(reports); err != nil {
+            fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+            return 1
+        }
+        return 0
+    }
+    printOverlapReports(reports, len(files), len(chunks))
+    return 0
+}
+
+func printOverlapReports(reports []OverlapReport, files, sections int) {
+    if len(reports) == 0 {
+        return
+    }
+    fmt.Printf("%d file(s), %d section(s), ~%d tokens\n\n", files, sections, reports[0].Tokens)
+    fmt.Printf("%6s %8s %8s %14s %18s %17s\n", "size", "overlap", "chunks", "chunk tokens", "duplicated", "split paragraphs")
+    for _, report := range reports {
+        fmt.Printf("%6d %8d %8d %14d %10d (%4.1f%%) %17d\n", report.Size, report.Overlap, report.Chunks,
+            report.ChunkTokens, report.Duplicated, report.Ratio*100, report.SplitParagraphs)
+    }
+
+    for _, report := range reports {
+        if len(report.Sections) == 0 {
+            continue
+        }
+        fmt.Printf("\nSections with paragraphs cut at size %d, overlap %d:\n", report.Size, report.Overlap)
+        for _, section := range report.Sections {
+            fmt.Printf("  %s:%d %s\n", section.File, section.Line, plainText(strings.Join(section.HeadingPath, " > ")))
+            fmt.Printf("      %d paragraph(s) cut, longest ~%d tokens; %d chunks, %d tokens duplicated (%.0f%% of the section)\n",
+                section.SplitParagraphs, section.LongestParagraph, section.Chunks, section.Duplicated,
+                float64(section.Duplicated)*100/float64(max(section.Tokens, 1)))
+        }
+    }
+}
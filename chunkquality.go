@@ -0,0 +1,140 @@
+// Standalone quality scoring for exported chunks
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+const (
+    // defaultChunkTokens is the token budget of a chunk when none is given
+    defaultChunkTokens = 512
+    // defaultMinChunkScore is the score below which a chunk is low quality
+    defaultMinChunkScore = 70
+    // minChunkWords is the prose a chunk needs to say anything on its own
+    minChunkWords = 20
+)
+
+// contextualOpeningRegex matches a first sentence that leans on what came
+// before the chunk
+var contextualOpeningRegex = regexp.MustCompile(`(?i)^(?:this|that|these|those|it|they|above|here|as\s+(?:mentioned|described|shown)|(?:the\s+)?(?:previous|preceding|following))\b`)
+
+// chunkPenalties are the points a chunk loses for each standalone problem
+var chunkPenalties = map[string]float64{
+    "no-heading":         25,
+    "no-sentences":       25,
+    "contextual-opening": 15,
+    "undefined-term":     5, // per term, up to maxUndefinedPenalty
+    "over-budget":        20,
+    "too-short":          10,
+}
+
+// maxUndefinedPenalty caps what undefined terms can cost a chunk
+const maxUndefinedPenalty = 20
+
+// ChunkQuality rates how well a chunk stands on its own once retrieved
+type ChunkQuality struct {
+    Score    float64  `json:"score"`              // 0 to 100
+    Tokens   int      `json:"tokens"`             // estimated
+    Problems []string `json:"problems,omitempty"` // what cost the chunk points
+    Low      bool     `json:"low_quality,omitempty"`
+}
+
+// estimateTokens approximates a tokenizer at four characters per token
+func estimateTokens(text string) int {
+    return (len([]rune(text)) + 3) / 4
+}
+
+// scoreChunk rates a chunk's text, its heading line and body without
+// breadcrumbs, for standalone use: it should have a heading, make complete
+// sentences, not open by pointing at earlier context, define the jargon it
+// uses, and fit the token budget. Products are the document's product
+// names, which aren't jargon.
+func scoreChunk(section Section, text string, products map[string]bool, options ExportOptions) *ChunkQuality {
+    maxTokens := options.MaxTokens
+    if maxTokens == 0 {
+        maxTokens = defaultChunkTokens
+    }
+    minScore := options.MinScore
+    if minScore == 0 {
+        minScore = defaultMinChunkScore
+    }
+
+    quality := &ChunkQuality{Score: 100, Tokens: estimateTokens(text)}
+    penalize := func(kind, problem string) {
+        quality.Score -= chunkPenalties[kind]
+        quality.Problems = append(quality.Problems, problem)
+    }
+
+    chunk := ParseDocument("", text)
+    if section.Line == 0 {
+        penalize("no-heading", "no heading")
+    }
+
+    var sentences []string
+    words := 0
+    for _, paragraph := range chunk.Paragraphs() {
+        if strings.HasPrefix(strings.TrimSpace(paragraph.Text), "|") {
+            continue // tables
+        }
+        for _, sentence := range paragraph.Sentences() {
+            sentence := plainText(strings.Join(strings.Fields(sentence.Text), " "))
+            words += wordCount(sentence)
+            if wordCount(sentence) >= 3 && strings.ContainsAny(sentence[len(sentence)-1:], ".!?:") {
+                sentences = append(sentences, sentence)
+            }
+        }
+    }
+    switch {
+    case len(sentences) == 0:
+        penalize("no-sentences", "no complete sentences")
+    case contextualOpeningRegex.MatchString(strings.TrimLeft(sentences[0], "-*+> ")):
+        penalize("contextual-opening", fmt.Sprintf("opens with a reference to earlier context: %q", sentences[0]))
+    }
+
+    // A term counts as defined if the chunk itself or the glossary defines it
+    defined := definedTerms(chunk)
+    undefined := make(map[string]int)
+    for _, section := range chunk.Sections {
+        for term, count := range chunk.jargonIn(section) {
+            lower := strings.ToLower(term)
+            if !defined[lower] && !products[lower] && !options.Glossary[lower] && !options.Glossary[strings.TrimSuffix(lower, "s")] {
+                undefined[term] += count
+            }
+        }
+    }
+    if len(undefined) > 0 {
+        quality.Score -= min(chunkPenalties["undefined-term"]*float64(len(undefined)), maxUndefinedPenalty)
+        quality.Problems = append(quality.Problems, "undefined terms: "+strings.Join(sortedKeys(undefined), ", "))
+    }
+
+    if quality.Tokens > maxTokens {
+        penalize("over-budget", fmt.Sprintf("about %d tokens, over the %d-token budget", quality.Tokens, maxTokens))
+    } else if words < minChunkWords {
+        penalize("too-short", fmt.Sprintf("%d words of prose, too short to stand alone", words))
+    }
+
+    quality.Score = max(quality.Score, 0)
+    quality.Low = quality.Score < minScore
+    return quality
+}
+
+// productTerms returns the lowercased mixed-case words a document repeats
+// three or more times, which analyzeJargon also takes for product names
+func productTerms(doc *Document) map[string]bool {
+    counts := make(map[string]int)
+    for _, word := range termWordRegex.FindAllString(doc.Content, -1) {
+        if mixedCaseRegex.MatchString(word) && !acronymRegex.MatchString(word) {
+            counts[strings.ToLower(word)]++
+        }
+    }
+    products := make(map[string]bool)
+    for word, count := range counts {
+        if count >= 3 {
+            products[word] = true
+        }
+    }
+    return products
+}
@@ -0,0 +1,175 @@
+// SARIF and GitLab code quality output, for code scanning and merge
+// request widgets
+
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+func init() {
+    RegisterFormatter("sarif", FormatterFunc(printSARIFIssues))
+    RegisterFormatter("codequality", FormatterFunc(printCodeQualityIssues))
+}
+
+// reportSeverities returns the severities of the run being written, or
+// the built-in ones outside a run
+func reportSeverities() severityPolicy {
+    if currentRun != nil && currentRun.Severities != nil {
+        return currentRun.Severities
+    }
+    return builtinSeverities
+}
+
+// issueLocation returns where a report places an issue: its file, or the
+// web address of a remote document
+func issueLocation(issue Issue) string {
+    if issue.URL != "" {
+        return issue.URL
+    }
+    return filepath.ToSlash(issue.File)
+}
+
+// issueText returns an issue's message with its suggestion
+func issueText(issue Issue) string {
+    if issue.Suggestion == "" {
+        return issue.Message
+    }
+    return fmt.Sprintf("%s\nSuggestion: %s", issue.Message, issue.Suggestion)
+}
+
+// printSARIFIssues writes a SARIF 2.1.0 log, which GitHub code scanning
+// and other static analysis viewers read. Each issue's level comes from
+// the SARIF setting of its severity. The run's invocation records the
+// command line and times, and its properties the run metadata of the JSON
+// report, with the config hash and rule versions.
+func printSARIFIssues(w io.Writer, issues []Issue) error {
+    type message struct {
+        Text string `json:"text"`
+    }
+    type region struct {
+        StartLine   int `json:"startLine"`
+        StartColumn int `json:"startColumn,omitempty"`
+    }
+    type location struct {
+        PhysicalLocation struct {
+            ArtifactLocation struct {
+                URI string `json:"uri"`
+            } `json:"artifactLocation"`
+            Region region `json:"region"`
+        } `json:"physicalLocation"`
+    }
+    type result struct {
+        RuleID              string            `json:"ruleId"`
+        Level               string            `json:"level"`
+        Message             message           `json:"message"`
+        Locations           []location        `json:"locations"`
+        PartialFingerprints map[string]string `json:"partialFingerprints"`
+        Properties          map[string]string `json:"properties"`
+    }
+    type rule struct {
+        ID      string `json:"id"`
+        HelpURI string `json:"helpUri,omitempty"`
+    }
+
+    severities := reportSeverities()
+    prints := fingerprints(issues)
+    var rules []rule
+    seen := make(map[string]bool)
+    results := make([]result, 0, len(issues))
+    for i, issue := range issues {
+        if !seen[issue.Rule] {
+            seen[issue.Rule] = true
+            rules = append(rules, rule{ID: issue.Rule, HelpURI: issue.DocsURL})
+        }
+        var at location
+        at.PhysicalLocation.ArtifactLocation.URI = issueLocation(issue)
+        at.PhysicalLocation.Region = region{StartLine: max(issue.Line, 1), StartColumn: issue.Column}
+        properties := map[string]string{"severity": issue.Severity}
+        if issue.Root != "" {
+            properties["root"] = issue.Root
+        }
+        results = append(results, result{
+            RuleID:              issue.Rule,
+            Level:               severities.level(issue.Severity).SARIF,
+            Message:             message{Text: issueText(issue)},
+            Locations:           []location{at},
+            PartialFingerprints: map[string]string{"aiDocOptimizer/v1": prints[i]},
+            Properties:          properties,
+        })
+    }
+
+    run := map[string]any{
+        "tool": map[string]any{"driver": map[string]any{
+            "name":    "ai-doc-optimizer",
+            "version": toolVersion,
+            "rules":   rules,
+        }},
+        "results": results,
+    }
+    if currentRun != nil {
+        run["invocations"] = []any{map[string]any{
+            "commandLine":         strings.Join(os.Args, " "),
+            "arguments":           os.Args[1:],
+            "startTimeUtc":        currentRun.StartedAt,
+            "endTimeUtc":          currentRun.FinishedAt,
+            "executionSuccessful": true,
+        }}
+        run["properties"] = map[string]any{"run": currentRun}
+    }
+    log := map[string]any{
+        "version": "2.1.0",
+        "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+        "runs":    []any{run},
+    }
+    encoder := json.NewEncoder(w)
+    encoder.SetIndent("", "  ")
+    if err := encoder.Encode(log); err != nil {
+        return fmt.Errorf("failed to encode SARIF: %w", err)
+    }
+    return nil
+}
+
+// printCodeQualityIssues writes a GitLab code quality report, which merge
+// requests show as a widget. Each issue's severity comes from the
+// CodeQuality setting of its severity.
+func printCodeQualityIssues(w io.Writer, issues []Issue) error {
+    type lines struct {
+        Begin int `json:"begin"`
+    }
+    type location struct {
+        Path  string `json:"path"`
+        Lines lines  `json:"lines"`
+    }
+    type entry struct {
+        Description string   `json:"description"`
+        CheckName   string   `json:"check_name"`
+        Fingerprint string   `json:"fingerprint"`
+        Severity    string   `json:"severity"`
+        Location    location `json:"location"`
+    }
+
+    severities := reportSeverities()
+    prints := fingerprints(issues)
+    entries := make([]entry, 0, len(issues))
+    for i, issue := range issues {
+        entries = append(entries, entry{
+            Description: issue.Message,
+            CheckName:   issue.Rule,
+            Fingerprint: prints[i],
+            Severity:    severities.level(issue.Severity).CodeQuality,
+            Location:    location{Path: issueLocation(issue), Lines: lines{Begin: max(issue.Line, 1)}},
+        })
+    }
+    encoder := json.NewEncoder(w)
+    encoder.SetIndent("", "  ")
+    if err := encoder.Encode(entries); err != nil {
+        return fmt.Errorf("failed to encode code quality report: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,450 @@
+// Consistency checks between docs and a machine-readable CLI description
+
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+var (
+    helpUsageRegex   = regexp.MustCompile(`^\s*(?:Usage:\s*)?([\w.-]+)(?:\s+[\w.-]+)*\s+\[(?:command|flags|options)\]`)
+    helpCommandRegex = regexp.MustCompile(`^\s{2,}([a-z][\w-]*)\s{2,}\S`)
+    helpFlagRegex    = regexp.MustCompile(`^\s+(?:(-\w)(?:,\s*|\s+))?(--[\w-]+)`)
+    helpSectionRegex = regexp.MustCompile(`^(?:Available )?(?:Sub)?[Cc]ommands:\s*$`)
+    flagMentionRegex = regexp.MustCompile(`(?:^|[\s` + "`" + `(\[])(--[a-zA-Z][\w-]*)`)
+    shellPromptRegex = regexp.MustCompile(`^(?:\$|#|>|PS>)\s+`)
+    commandWordRegex = regexp.MustCompile(`^[a-z][a-z-]*$`)
+)
+
+// shellLanguages are fence info strings whose blocks hold shell commands
+var shellLanguages = map[string]bool{
+    "": true, "sh": true, "bash": true, "shell": true, "console": true, "zsh": true, "shell-session": true,
+}
+
+// CLIConfig points the CLI reference check at a description of the CLI
+type CLIConfig struct {
+    Spec   string `yaml:"Spec"`             // file or directory
+    Format string `yaml:"Format,omitempty"` // "cobra", "help" or "spec"; detected when empty
+}
+
+// cliSpecCommand is the layout of a "spec" format file
+type cliSpecCommand struct {
+    Name     string           `yaml:"name"`
+    Flags    []string         `yaml:"flags"`
+    Commands []cliSpecCommand `yaml:"commands"`
+}
+
+// cobraCommand is the layout of a file written by cobra's GenYamlTree
+type cobraCommand struct {
+    Name             string        `yaml:"name"`
+    Options          []cobraOption `yaml:"options"`
+    InheritedOptions []cobraOption `yaml:"inherited_options"`
+}
+
+type cobraOption struct {
+    Name      string `yaml:"name"`
+    Shorthand string `yaml:"shorthand"`
+}
+
+// CLIReference is the set of commands and the flags each accepts. Command
+// keys are full paths such as "tool config set".
+type CLIReference struct {
+    source   string
+    program  string
+    commands map[string]*cliCommand
+}
+
+type cliCommand struct {
+    flags     map[string]bool // flags defined on this command
+    inherited map[string]bool // flags accepted from ancestors
+}
+
+// loadCLIReference reads the configured CLI description, or returns nil
+// when none is configured
+func loadCLIReference(config CLIConfig) (*CLIReference, error) {
+    if config.Spec == "" {
+        return nil, nil
+    }
+
+    files := []string{config.Spec}
+    if info, err := os.Stat(config.Spec); err != nil {
+        return nil, fmt.Errorf("failed to read CLI spec: %w", err)
+    } else if info.IsDir() {
+        files, _ = filepath.Glob(filepath.Join(config.Spec, "*.y*ml"))
+        if len(files) == 0 {
+            return nil, fmt.Errorf("no YAML files in CLI spec directory %s", config.Spec)
+        }
+        sort.Strings(files)
+    }
+
+    ref := &CLIReference{source: config.Spec, commands: make(map[string]*cliCommand)}
+    for _, file := range files {
+        data, err := os.ReadFile(file)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read CLI spec: %w", err)
+        }
+        if err := ref.parse(string(data), config.Format, file); err != nil {
+            return nil, fmt.Errorf("failed to parse CLI spec %s: %w", file, err)
+        }
+    }
+    if ref.program == "" {
+        return nil, fmt.Errorf("CLI spec %s names no command", config.Spec)
+    }
+
+    // Flags defined on a command are accepted by its subcommands too
+    for path, command := range ref.commands {
+        for parent := parentCommand(path); parent != ""; parent = parentCommand(parent) {
+            if ancestor, ok := ref.commands[parent]; ok {
+                for flag := range ancestor.flags {
+                    command.inherited[flag] = true
+                }
+            }
+        }
+    }
+
+    return ref, nil
+}
+
+// parse adds the commands described by one file
+func (r *CLIReference) parse(data, format, file string) error {
+    if format == "" {
+        switch {
+        case !strings.HasSuffix(file, ".yaml") && !strings.HasSuffix(file, ".yml") && !strings.HasSuffix(file, ".json"):
+            format = "help"
+        case strings.Contains(data, "options:") || strings.Contains(data, "synopsis:"):
+            format = "cobra"
+        default:
+            format = "spec"
+        }
+    }
+
+    switch format {
+    case "cobra":
+        var command cobraCommand
+        if err := yaml.Unmarshal([]byte(data), &command); err != nil {
+            return err
+        }
+        c := r.command(command.Name)
+        for _, option := range command.Options {
+            addFlag(c.flags, option.Name, option.Shorthand)
+        }
+        for _, option := range command.InheritedOptions {
+            addFlag(c.inherited, option.Name, option.Shorthand)
+        }
+    case "spec":
+        var root cliSpecCommand
+        if err := yaml.Unmarshal([]byte(data), &root); err != nil {
+            return err
+        }
+        r.addSpec(root, "")
+    case "help":
+        r.parseHelp(data)
+    default:
+        return fmt.Errorf("unknown CLI spec format %q", format)
+    }
+    return nil
+}
+
+// addSpec adds a "spec" format command and its subcommands under parent
+func (r *CLIReference) addSpec(spec cliSpecCommand, parent string) {
+    path := strings.TrimSpace(parent + " " + spec.Name)
+    c := r.command(path)
+    for _, flag := range spec.Flags {
+        for _, name := range strings.Split(flag, ",") {
+            if name = strings.TrimSpace(name); strings.HasPrefix(name, "-") {
+                c.flags[name] = true
+            } else if name != "" {
+                c.flags["--"+name] = true
+            }
+        }
+    }
+    for _, sub := range spec.Commands {
+        r.addSpec(sub, path)
+    }
+}
+
+// parseHelp reads captured --help output, one or more commands' worth: each
+// command from its Usage line, then its subcommands and flags
+func (r *CLIReference) parseHelp(data string) {
+    var c *cliCommand
+    program := ""
+    inCommands := false
+
+    for _, line := range strings.Split(data, "\n") {
+        if helpUsageRegex.MatchString(line) && !strings.HasPrefix(strings.TrimSpace(line), "-") {
+            program = strings.Join(strings.Fields(strings.TrimPrefix(strings.TrimSpace(line[:strings.Index(line, "[")]), "Usage:")), " ")
+            c = r.command(program)
+            inCommands = false
+            continue
+        }
+        if c == nil {
+            continue
+        }
+
+        switch {
+        case helpSectionRegex.MatchString(strings.TrimSpace(line)):
+            inCommands = true
+        case strings.TrimSpace(line) == "" || !strings.HasPrefix(line, " "):
+            inCommands = false
+        case inCommands:
+            if match := helpCommandRegex.FindStringSubmatch(line); match != nil {
+                r.command(program + " " + match[1])
+            }
+        default:
+            if match := helpFlagRegex.FindStringSubmatch(line); match != nil {
+                addFlag(c.flags, strings.TrimPrefix(match[2], "--"), strings.TrimPrefix(match[1], "-"))
+            }
+        }
+    }
+}
+
+// command returns the entry for path, creating it and its ancestors
+func (r *CLIReference) command(path string) *cliCommand {
+    path = strings.Join(strings.Fields(path), " ")
+    if r.program == "" && path != "" {
+        r.program = strings.Fields(path)[0]
+    }
+    if c, ok := r.commands[path]; ok {
+        return c
+    }
+    c := &cliCommand{flags: make(map[string]bool), inherited: make(map[string]bool)}
+    r.commands[path] = c
+    if parent := parentCommand(path); parent != "" {
+        r.command(parent)
+    }
+    return c
+}
+
+// addFlag records a long flag and its optional shorthand
+func addFlag(flags map[string]bool, name, shorthand string) {
+    if name != "" {
+        flags["--"+strings.TrimPrefix(name, "--")] = true
+    }
+    if shorthand != "" {
+        flags["-"+strings.TrimPrefix(shorthand, "-")] = true
+    }
+}
+
+// parentCommand returns the command path without its last word
+func parentCommand(path string) string {
+    if i := strings.LastIndex(path, " "); i >= 0 {
+        return path[:i]
+    }
+    return ""
+}
+
+// subcommands reports whether path has any subcommands
+func (r *CLIReference) subcommands(path string) bool {
+    for other := range r.commands {
+        if parentCommand(other) == path {
+            return true
+        }
+    }
+    return false
+}
+
+// accepts reports whether the command at path takes flag
+func (r *CLIReference) accepts(path, flag string) bool {
+    c := r.commands[path]
+    return flag == "--help" || flag == "-h" || c.flags[flag] || c.inherited[flag]
+}
+
+// knownFlag reports whether any command defines flag
+func (r *CLIReference) knownFlag(flag string) bool {
+    for path := range r.commands {
+        if r.accepts(path, flag) {
+            return true
+        }
+    }
+    return false
+}
+
+// cliInvocation is one documented command line
+type cliInvocation struct {
+    line, column int
+    text         string
+}
+
+// invocations returns the documented command lines that start with the
+// program name, from shell code blocks and inline code spans
+func (d *Document) invocations(program string) []cliInvocation {
+    var result []cliInvocation
+    starts := func(text string) bool {
+        return text == program || strings.HasPrefix(text, program+" ")
+    }
+
+    for _, block := range d.CodeBlocks {
+        if !shellLanguages[block.Lang] {
+            continue
+        }
+        for i := block.StartLine; i < block.EndLine-1 && i < len(d.Lines); i++ {
+            text := shellPromptRegex.ReplaceAllString(strings.TrimSpace(d.Lines[i]), "")
+            if starts(text) {
+                result = append(result, cliInvocation{line: i + 1, column: strings.Index(d.Lines[i], text) + 1, text: text})
+            }
+        }
+    }
+
+    for i, line := range d.Masked {
+        if d.Fenced[i] {
+            continue
+        }
+        for _, match := range codeSpanRegex.FindAllStringSubmatchIndex(line, -1) {
+            text := shellPromptRegex.ReplaceAllString(strings.TrimSpace(line[match[2]:match[3]]), "")
+            if starts(text) {
+                result = append(result, cliInvocation{line: i + 1, column: match[2] + 1, text: text})
+            }
+        }
+    }
+
+    return result
+}
+
+// analyzeCLIReferences flags documented commands and flags that the CLI
+// description doesn't define
+func (a *Analyzer) analyzeCLIReferences(doc *Document) []Issue {
+    if a.cli == nil {
+        return nil
+    }
+
+    type position struct{ line, column int }
+    reported := make(map[position]bool) // flags of invocations, which the mentions below skip
+
+    var issues []Issue
+    for _, invocation := range doc.invocations(a.cli.program) {
+        path := a.cli.program
+        positional := false
+
+        for _, token := range strings.Fields(invocation.text)[1:] {
+            if token == "|" || token == "&&" || token == ";" || token == "\\" {
+                break
+            }
+            if strings.HasPrefix(token, "-") && token != "-" && token != "--" {
+                flag := strings.SplitN(token, "=", 2)[0]
+                if !a.cli.accepts(path, flag) {
+                    if invocation.column > 0 {
+                        if offset := strings.Index(doc.Lines[invocation.line-1][invocation.column-1:], flag); offset >= 0 {
+                            reported[position{invocation.line, invocation.column + offset}] = true
+                        }
+                    }
+                    issues = append(issues, Issue{
+                        File:         doc.Path,
+                        Line:         invocation.line,
+                        Column:       invocation.column,
+                        Rule:         "cli-unknown-flag",
+                        Message:      fmt.Sprintf("'%s' does not accept the flag '%s'", path, flag),
+                        Severity:     "warning",
+                        Suggestion:   "Check the flag against the CLI reference; it may have been renamed or removed",
+                        OriginalText: invocation.text,
+                    })
+                }
+                continue
+            }
+            if positional {
+                continue
+            }
+            if _, ok := a.cli.commands[path+" "+token]; ok {
+                path += " " + token
+                continue
+            }
+            if a.cli.subcommands(path) && commandWordRegex.MatchString(token) {
+                issues = append(issues, Issue{
+                    File:         doc.Path,
+                    Line:         invocation.line,
+                    Column:       invocation.column,
+                    Rule:         "cli-unknown-command",
+                    Message:      fmt.Sprintf("'%s %s' is not a command in the CLI reference", path, token),
+                    Severity:     "warning",
+                    Suggestion:   "Check the command against the CLI reference; it may have been renamed or removed",
+                    OriginalText: invocation.text,
+                })
+            }
+            positional = true
+        }
+    }
+
+    for i, line := range doc.Masked {
+        if doc.Fenced[i] {
+            continue
+        }
+        for _, match := range flagMentionRegex.FindAllStringSubmatchIndex(line, -1) {
+            flag := line[match[2]:match[3]]
+            if !a.cli.knownFlag(flag) && !reported[position{i + 1, match[2] + 1}] {
+                issues = append(issues, Issue{
+                    File:         doc.Path,
+                    Line:         i + 1,
+                    Column:       match[2] + 1,
+                    Rule:         "cli-unknown-flag",
+                    Message:      fmt.Sprintf("No command in the CLI reference defines '%s'", flag),
+                    Severity:     "warning",
+                    Suggestion:   "Check the flag against the CLI reference; it may have been renamed or removed",
+                    OriginalText: flag,
+                })
+            }
+        }
+    }
+
+    return issues
+}
+
+// undocumentedCLI reports commands and flags that no document mentions
+func (a *Analyzer) undocumentedCLI(docs []*Document) []Issue {
+    if a.cli == nil {
+        return nil
+    }
+
+    var corpus strings.Builder
+    for _, doc := range docs {
+        corpus.WriteString(doc.Content)
+        corpus.WriteString("\n")
+    }
+    text := corpus.String()
+    mentioned := make(map[string]bool)
+    for _, match := range flagMentionRegex.FindAllStringSubmatch(text, -1) {
+        mentioned[match[1]] = true
+    }
+
+    var paths []string
+    for path := range a.cli.commands {
+        paths = append(paths, path)
+    }
+    sort.Strings(paths)
+
+    var issues []Issue
+    for _, path := range paths {
+        last := path[strings.LastIndex(path, " ")+1:]
+        if path != a.cli.program && last != "help" && last != "completion" && !strings.Contains(text, path) {
+            issues = append(issues, Issue{
+                File:       a.cli.source,
+                Line:       1,
+                Column:     1,
+                Rule:       "cli-undocumented-command",
+                Message:    fmt.Sprintf("Command '%s' is not mentioned in any document", path),
+                Severity:   "suggestion",
+                Suggestion: "Document the command, or remove it from the CLI if it is obsolete",
+            })
+        }
+        for _, flag := range sortedKeys(a.cli.commands[path].flags) {
+            if strings.HasPrefix(flag, "--") && flag != "--help" && !mentioned[flag] {
+                issues = append(issues, Issue{
+                    File:       a.cli.source,
+                    Line:       1,
+                    Column:     1,
+                    Rule:       "cli-undocumented-flag",
+                    Message:    fmt.Sprintf("Flag '%s' of '%s' is not mentioned in any document", flag, path),
+                    Severity:   "suggestion",
+                    Suggestion: "Document the flag, or remove it from the CLI if it is obsolete",
+                })
+            }
+        }
+    }
+
+    return issues
+}
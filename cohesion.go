@@ -0,0 +1,201 @@
+// Paragraph topic cohesion check
+
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+const (
+    // defaultMinCohesionSentences is used when the config doesn't set
+    // Cohesion.MinSentences
+    defaultMinCohesionSentences = 4
+    // defaultMinCohesionSimilarity is used when the config doesn't set
+    // Cohesion.MinSimilarity
+    defaultMinCohesionSimilarity = 0.25
+)
+
+// CohesionConfig enables the paragraph topic cohesion check, which is off
+// by default
+type CohesionConfig struct {
+    Enabled       bool    `yaml:"Enabled,omitempty"`
+    MinSentences  int     `yaml:"MinSentences,omitempty"`  // sentences a paragraph needs to be checked, 4 by default
+    MinSimilarity float64 `yaml:"MinSimilarity,omitempty"` // embedding similarity between the parts of a paragraph below which they're separate topics, 0.25 by default
+}
+
+// cohesionCandidate is a paragraph long enough to split
+type cohesionCandidate struct {
+    paragraph Paragraph
+    sentences []Sentence
+}
+
+// analyzeCohesion flags paragraphs that cover two unrelated topics. A
+// chunker keeps a paragraph together, so its chunk matches queries about
+// either topic and answers neither well. A paragraph is split where the
+// sentences before and after share no vocabulary while each part repeats
+// its own, or, with -semantic, where their embeddings are least similar.
+func (a *Analyzer) analyzeCohesion(doc *Document) []Issue {
+    if !a.config.Cohesion.Enabled {
+        return nil
+    }
+    candidates := a.cohesionCandidates(doc)
+    if len(candidates) == 0 {
+        return nil
+    }
+
+    if a.embedder != nil {
+        issues, err := a.semanticCohesion(doc, candidates)
+        if err == nil {
+            return issues
+        }
+        a.embedder.warnFallback(err)
+    }
+
+    var issues []Issue
+    for _, candidate := range candidates {
+        if split, ok := lexicalSplit(candidate.sentences); ok {
+            issues = append(issues, cohesionIssue(doc, candidate, split,
+                fmt.Sprintf("Paragraph changes topic at sentence %d: the sentences before and after share no vocabulary", split+1)))
+        }
+    }
+    return issues
+}
+
+// cohesionCandidates returns the prose paragraphs of doc with enough
+// sentences to check, leaving out lists, tables and HTML
+func (a *Analyzer) cohesionCandidates(doc *Document) []cohesionCandidate {
+    minSentences := a.config.Cohesion.MinSentences
+    if minSentences <= 0 {
+        minSentences = defaultMinCohesionSentences
+    }
+
+    var candidates []cohesionCandidate
+    for _, paragraph := range doc.Paragraphs() {
+        first := strings.TrimSpace(paragraph.Text)
+        if bulletItemRegex.MatchString(first) || orderedItemRegex.MatchString(first) || strings.HasPrefix(first, "|") || strings.HasPrefix(first, "<") {
+            continue
+        }
+        if sentences := paragraph.Sentences(); len(sentences) >= minSentences {
+            candidates = append(candidates, cohesionCandidate{paragraph, sentences})
+        }
+    }
+    return candidates
+}
+
+// lexicalSplit returns the first sentence after which a paragraph's two
+// parts, of at least two sentences each, share no word stem, while each
+// part has a stem that two of its sentences share
+func lexicalSplit(sentences []Sentence) (int, bool) {
+    stems := make([]map[string]bool, len(sentences))
+    for i, sentence := range sentences {
+        stems[i] = make(map[string]bool)
+        for _, word := range contentWords(plainText(sentence.Text)) {
+            stems[i][stem(word)] = true
+        }
+    }
+
+    for split := 2; split <= len(sentences)-2; split++ {
+        before, after := stemCounts(stems[:split]), stemCounts(stems[split:])
+        shared := false
+        for word := range before {
+            if after[word] > 0 {
+                shared = true
+                break
+            }
+        }
+        if !shared && repeatsStem(before) && repeatsStem(after) {
+            return split, true
+        }
+    }
+    return 0, false
+}
+
+// stemCounts counts the sentences each stem occurs in
+func stemCounts(sentences []map[string]bool) map[string]int {
+    counts := make(map[string]int)
+    for _, stems := range sentences {
+        for word := range stems {
+            counts[word]++
+        }
+    }
+    return counts
+}
+
+// repeatsStem reports whether any stem occurs in two or more sentences
+func repeatsStem(counts map[string]int) bool {
+    for _, count := range counts {
+        if count > 1 {
+            return true
+        }
+    }
+    return false
+}
+
+// semanticCohesion splits each candidate where the mean embeddings of the
+// sentences before and after are least similar, and reports the
+// candidates whose parts are less similar than Cohesion.MinSimilarity
+func (a *Analyzer) semanticCohesion(doc *Document, candidates []cohesionCandidate) ([]Issue, error) {
+    minSimilarity := a.config.Cohesion.MinSimilarity
+    if minSimilarity <= 0 {
+        minSimilarity = defaultMinCohesionSimilarity
+    }
+
+    var texts []string
+    for _, candidate := range candidates {
+        for _, sentence := range candidate.sentences {
+            texts = append(texts, sentence.Text)
+        }
+    }
+    vectors, err := a.embedder.Embed(texts)
+    if err != nil {
+        return nil, err
+    }
+
+    var issues []Issue
+    offset := 0
+    for _, candidate := range candidates {
+        rows := vectors[offset : offset+len(candidate.sentences)]
+        offset += len(candidate.sentences)
+
+        split, lowest := 0, 1.0
+        for i := 2; i <= len(rows)-2; i++ {
+            if similarity := cosineSimilarity(meanVector(rows[:i]), meanVector(rows[i:])); similarity < lowest {
+                split, lowest = i, similarity
+            }
+        }
+        if split > 0 && lowest < minSimilarity {
+            issues = append(issues, cohesionIssue(doc, candidate, split,
+                fmt.Sprintf("Paragraph changes topic at sentence %d (similarity %.2f between the sentences before and after)", split+1, lowest)))
+        }
+    }
+    return issues, nil
+}
+
+// cohesionIssue reports a paragraph at the sentence that starts its second
+// topic, naming the terms of each part
+func cohesionIssue(doc *Document, candidate cohesionCandidate, split int, message string) Issue {
+    var before, after []string
+    for i, sentence := range candidate.sentences {
+        if i < split {
+            before = append(before, sentence.Text)
+        } else {
+            after = append(after, sentence.Text)
+        }
+    }
+    suggestion := "Split the paragraph here, so each chunk covers one topic"
+    if first, second := topTerms(plainText(strings.Join(before, " ")), 2), topTerms(plainText(strings.Join(after, " ")), 2); len(first) > 0 && len(second) > 0 {
+        suggestion = fmt.Sprintf("Split the paragraph here, so each chunk covers one topic (before: %s; after: %s)", strings.Join(first, ", "), strings.Join(second, ", "))
+    }
+    start := candidate.sentences[split]
+    return Issue{
+        File:         doc.Path,
+        Line:         start.Line,
+        Column:       start.Column,
+        Rule:         "mixed-topic-paragraph",
+        Message:      message,
+        Severity:     "suggestion",
+        Suggestion:   suggestion,
+        OriginalText: start.Text,
+    }
+}
@@ -0,0 +1,276 @@
+// Subcommands of the CLI
+
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// command is a subcommand of the CLI
+type command struct {
+    name    string
+    summary string
+    run     func(args []string) int
+}
+
+// commands are the subcommands, in the order help lists them. A first
+// argument that names none of them is taken as the arguments of analyze,
+// so invocations from before the subcommands keep working.
+var commands = []command{
+    {"analyze", "Analyze files for AI-readiness issues (the default)", runAnalyze},
+    {"fix", "Apply available fixes, then report the remaining issues", runFix},
+    {"apply", "Apply reviewed fix patches, merging them into files changed since", runApply},
+    {"chunk", "Preview how documents split into retrieval chunks", runChunk},
+    {"export", "Write retrieval chunks as JSON Lines", runExport},
+    {"chunk-overlap", "Report how much chunk overlap duplicates for a chunk size and overlap", runChunkOverlap},
+    {"embedding-drift", "Report the chunks whose meaning shifted most, and those removed, between two exports", runEmbeddingDrift},
+    {"serve", "Serve analysis over HTTP", runServe},
+    {"rules", "List the rules and checks a configuration runs, or scaffold a new rule with rules new", runRules},
+    {"init", "Write a starter configuration file", runInit},
+    {"config", "Print the config file's JSON Schema, or validate config files", runConfig},
+    {"diff-versions", "Compare two versions of a doc set section by section", runDiffVersions},
+    {"l10n-parity", "Compare translated doc trees with their source", runL10nParity},
+    {"report-diff", "Compare two JSON result files", runReportDiff},
+    {"history", "List the runs or issue lifecycles of a results database", runHistory},
+    {"dashboard", "Serve a web dashboard over a results database", runDashboard},
+    {"pr-comment", "Post new issues as pull request review comments", runPRComment},
+    {"queries", "Check which sections answer a list of target queries", runQueries},
+    {"coverage", "Check which features of a feature list the docs cover", runCoverage},
+    {"glossary", "Generate a glossary of the terms the docs define", runGlossary},
+    {"bench", "Time the rules and checks on a corpus", runBench},
+    {"repo", "Analyze a code repository's READMEs, docs and doc comments", runRepo},
+    {"confluence", "Analyze a Confluence space", runConfluence},
+    {"crawl", "Analyze a website by crawling it", runCrawl},
+    {"sitemap", "Analyze the pages a sitemap lists", runSitemap},
+    {"helpcenter", "Analyze a Zendesk or Intercom help center", runHelpCenter},
+}
+
+// lookupCommand returns the subcommand of a name
+func lookupCommand(name string) (command, bool) {
+    for _, command := range commands {
+        if command.name == name {
+            return command, true
+        }
+    }
+    return command{}, false
+}
+
+// runHelp lists the subcommands, or shows the flags of one
+func runHelp(args []string) int {
+    if len(args) > 0 {
+        command, ok := lookupCommand(args[0])
+        if !ok {
+            fmt.Fprintf(os.Stderr, "Unknown command %q\n", args[0])
+            return 1
+        }
+        return command.run([]string{"-h"})
+    }
+
+    fmt.Fprintf(os.Stderr, "Usage: %s <command> [options] [arguments]\n\nCommands:\n", os.Args[0])
+    for _, command := range commands {
+        fmt.Fprintf(os.Stderr, "  %-14s %s\n", command.name, command.summary)
+    }
+    fmt.Fprintf(os.Stderr, "\nWithout a command, the arguments are those of analyze. Run '%s help <command>' for the options of a command.\n", os.Args[0])
+    return 0
+}
+
+// runFix implements the fix subcommand: analyze with -fix
+func runFix(args []string) int {
+    return runAnalyze(append([]string{"-fix"}, args...))
+}
+
+// runChunk implements the chunk subcommand, listing the chunks export
+// would write, with their IDs, sizes and, with -score, quality
+func runChunk(args []string) int {
+    flags := flag.NewFlagSet("chunk", flag.ExitOnError)
+    configPath := flags.String("config", "", "Path to configuration file, whose Nav orders the chunks")
+    maxTokens := flags.Int("max-tokens", defaultChunkTokens, "Token budget of a chunk; larger chunks are marked")
+    score := flags.Bool("score", false, "Rate each chunk's standalone quality")
+    releaseEntries := flags.Bool("release-entries", false, "Split changelogs and release notes into a chunk per entry, as export does")
+    recursive := flags.Bool("recursive", false, "Process directories recursively")
+    followSymlinks := flags.Bool("follow-symlinks", false, "Descend into symlinked directories during recursive walks")
+    flags.Parse(args)
+
+    if flags.NArg() == 0 {
+        fmt.Fprintf(os.Stderr, "Usage: %s chunk [options] <file_or_directory>...\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+
+    config, err := loadConfig(*configPath)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: failed to load config: %v\n", err)
+        return 1
+    }
+    nav, err := loadNav(config.Nav)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+    options := ExportOptions{Nav: nav, Score: *score, MaxTokens: *maxTokens, ReleaseEntries: *releaseEntries}
+    if *score {
+        if options.Glossary, err = loadGlossary(config.Jargon); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return 1
+        }
+    }
+
+    var files []string
+    for _, path := range flags.Args() {
+        found, err := collectFiles(path, WalkOptions{Recursive: *recursive, FollowSymlinks: *followSymlinks, Formats: config.Formats})
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", path, err)
+            return 1
+        }
+        files = append(files, found...)
+    }
+
+    total, over := 0, 0
+    for _, doc := range loadDocuments(nav.Order(files), config.Formats) {
+        for _, chunk := range buildChunks(doc, options) {
+            tokens := estimateTokens(chunk.Text)
+            total++
+            mark := ""
+            if tokens > *maxTokens {
+                over++
+                mark = " OVER BUDGET"
+            }
+            fmt.Printf("%s:%d %s (~%d tokens)%s\n", chunk.File, chunk.Line, chunk.ID, tokens, mark)
+            if len(chunk.HeadingPath) > 0 {
+                fmt.Printf("  %s\n", strings.Join(append(append([]string(nil), chunk.NavPath...), chunk.HeadingPath...), " > "))
+            }
+            if chunk.Quality != nil {
+                fmt.Printf("  quality %.0f", chunk.Quality.Score)
+                if chunk.Quality.Low {
+                    fmt.Print(" (low)")
+                }
+                if len(chunk.Quality.Problems) > 0 {
+                    fmt.Printf(": %s", strings.Join(chunk.Quality.Problems, "; "))
+                }
+                fmt.Println()
+            }
+        }
+    }
+    fmt.Printf("\n%d chunk(s), %d over the %d-token budget\n", total, over, *maxTokens)
+    return 0
+}
+
+// RuleInfo describes one rule or check for the rules subcommand
+type RuleInfo struct {
+    Name        string `json:"name"`
+    Kind        string `json:"kind"` // "pattern" or "check"
+    Severity    string `json:"severity,omitempty"`
+    Description string `json:"description,omitempty"`
+    Version     int    `json:"version,omitempty"`    // pattern rules only
+    Deprecated  string `json:"deprecated,omitempty"` // likewise
+    DocsURL     string `json:"docs_url,omitempty"`   // page explaining the rule, from DocsBaseURL
+}
+
+// ruleInfos returns the pattern rules an analyzer runs, with the severity
+// a Severities entry gives them, followed by the built-in checks
+func (a *Analyzer) ruleInfos() []RuleInfo {
+    var infos []RuleInfo
+    for _, rule := range a.rules {
+        severity := rule.Severity
+        if override, ok := a.config.Severities[rule.Name]; ok {
+            severity = override
+        }
+        infos = append(infos, RuleInfo{
+            Name:        rule.Name,
+            Kind:        "pattern",
+            Severity:    severity,
+            Description: rule.Description,
+            Version:     rule.version(),
+            Deprecated:  rule.Deprecated,
+            DocsURL:     a.config.ruleDocsURL(rule.Name),
+        })
+    }
+    for _, check := range a.checks("", "en") {
+        infos = append(infos, RuleInfo{Name: check.name, Kind: "check"})
+    }
+    return infos
+}
+
+// runRules implements the rules subcommand
+func runRules(args []string) int {
+    if len(args) > 0 && args[0] == "new" {
+        return runRulesNew(args[1:])
+    }
+    flags := flag.NewFlagSet("rules", flag.ExitOnError)
+    configPath := flags.String("config", "", "Path or HTTPS/git URL of configuration file")
+    outputFormat := flags.String("output", "standard", "Output format (standard, json)")
+    flags.Parse(args)
+
+    analyzer, err := NewAnalyzer(*configPath)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error creating analyzer: %v\n", err)
+        return 1
+    }
+    infos := analyzer.ruleInfos()
+
+    if *outputFormat == "json" {
+        encoder := json.NewEncoder(os.Stdout)
+        encoder.SetIndent("", "  ")
+        if err := encoder.Encode(infos); err != nil {
+            fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+            return 1
+        }
+        return 0
+    }
+    fmt.Println("Pattern rules:")
+    for _, info := range infos {
+        if info.Kind == "pattern" {
+            description := info.Description
+            if info.Deprecated != "" {
+                description += " (deprecated: " + info.Deprecated + ")"
+            }
+            fmt.Printf("  %-32s %-10s v%-3d %s\n", info.Name, info.Severity, info.Version, description)
+        }
+    }
+    fmt.Println("\nChecks (some run only on English documents or with their settings):")
+    for _, info := range infos {
+        if info.Kind == "check" {
+            fmt.Printf("  %s\n", info.Name)
+        }
+    }
+    return 0
+}
+
+// runInit implements the init subcommand, writing the default
+// configuration as a starting point
+func runInit(args []string) int {
+    flags := flag.NewFlagSet("init", flag.ExitOnError)
+    force := flags.Bool("force", false, "Overwrite an existing file")
+    flags.Parse(args)
+
+    path := configFileName
+    if flags.NArg() > 0 {
+        path = flags.Arg(0)
+    }
+    if _, err := os.Stat(path); err == nil && !*force {
+        fmt.Fprintf(os.Stderr, "Error: %s already exists (use -force to overwrite)\n", path)
+        return 1
+    }
+
+    var data bytes.Buffer
+    data.WriteString("# ai-doc-optimizer configuration; pass it with -config " + path + "\n")
+    encoder := yaml.NewEncoder(&data)
+    encoder.SetIndent(2)
+    if err := encoder.Encode(getDefaultConfig()); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+    if err := os.WriteFile(path, data.Bytes(), 0o644); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+    fmt.Printf("Wrote %s\n", path)
+    return 0
+}
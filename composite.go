@@ -0,0 +1,170 @@
+// Composite rule conditions: boolean combinations of patterns and context
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+)
+
+// Condition is a boolean constraint on the line a rule's Pattern matched.
+// A leaf holds when its Pattern matches a line in its Where range and the
+// line sits in the list kind named by InList; All, Any and Not combine
+// leaves. Set fields of one node are ANDed together.
+type Condition struct {
+    All     []Condition `yaml:"All,omitempty"`
+    Any     []Condition `yaml:"Any,omitempty"`
+    Not     *Condition  `yaml:"Not,omitempty"`
+    Pattern string      `yaml:"Pattern,omitempty"`
+    Where   string      `yaml:"Where,omitempty"`  // "line" (default), "before", "after", "near", "section"
+    Within  int         `yaml:"Within,omitempty"` // line distance for before/after/near; 0 means unbounded
+    InList  string      `yaml:"InList,omitempty"` // "ordered", "bullet", "any"
+
+    regex *regexp.Regexp // Pattern, compiled by compile
+}
+
+// conditionWhere are the accepted Where values
+var conditionWhere = map[string]bool{"": true, "line": true, "before": true, "after": true, "near": true, "section": true}
+
+// validate checks patterns compile and enumerated fields hold known values
+func (c Condition) validate() error {
+    if c.Pattern != "" {
+        if _, err := regexp.Compile(c.Pattern); err != nil {
+            return fmt.Errorf("invalid condition pattern %q: %w", c.Pattern, err)
+        }
+    }
+    if !conditionWhere[c.Where] {
+        return fmt.Errorf("invalid condition Where %q (want line, before, after, near or section)", c.Where)
+    }
+    if c.InList != "" && c.InList != "ordered" && c.InList != "bullet" && c.InList != "any" {
+        return fmt.Errorf("invalid condition InList %q (want ordered, bullet or any)", c.InList)
+    }
+    for _, child := range append(append([]Condition{}, c.All...), c.Any...) {
+        if err := child.validate(); err != nil {
+            return err
+        }
+    }
+    if c.Not != nil {
+        return c.Not.validate()
+    }
+    return nil
+}
+
+// compile returns a copy of the condition tree with every Pattern compiled
+func (c Condition) compile() (Condition, error) {
+    if err := c.validate(); err != nil {
+        return Condition{}, err
+    }
+    if c.Pattern != "" {
+        c.regex = regexp.MustCompile(c.Pattern)
+    }
+
+    var err error
+    compileAll := func(conditions []Condition) []Condition {
+        result := make([]Condition, len(conditions))
+        for i, child := range conditions {
+            if result[i], err = child.compile(); err != nil {
+                return nil
+            }
+        }
+        return result
+    }
+    if c.All = compileAll(c.All); err != nil {
+        return Condition{}, err
+    }
+    if c.Any = compileAll(c.Any); err != nil {
+        return Condition{}, err
+    }
+    if c.Not != nil {
+        not, err := c.Not.compile()
+        if err != nil {
+            return Condition{}, err
+        }
+        c.Not = &not
+    }
+    return c, nil
+}
+
+// holds evaluates the compiled condition for a match on the 1-based line lineNum
+func (c Condition) holds(doc *Document, lineNum int) bool {
+    for _, child := range c.All {
+        if !child.holds(doc, lineNum) {
+            return false
+        }
+    }
+    if len(c.Any) > 0 {
+        matched := false
+        for _, child := range c.Any {
+            if child.holds(doc, lineNum) {
+                matched = true
+                break
+            }
+        }
+        if !matched {
+            return false
+        }
+    }
+    if c.Not != nil && c.Not.holds(doc, lineNum) {
+        return false
+    }
+    if c.InList != "" && !doc.inList(lineNum, c.InList) {
+        return false
+    }
+    if c.regex != nil {
+        first, last := c.lineRange(doc, lineNum)
+        for i := first; i <= last; i++ {
+            if c.regex.MatchString(doc.Masked[i-1]) {
+                return true
+            }
+        }
+        return false
+    }
+    return true
+}
+
+// lineRange returns the 1-based lines a leaf pattern is matched against
+func (c Condition) lineRange(doc *Document, lineNum int) (int, int) {
+    first, last := 1, len(doc.Masked)
+    switch c.Where {
+    case "before":
+        last = lineNum - 1
+        if c.Within > 0 {
+            first = lineNum - c.Within
+        }
+    case "after":
+        first = lineNum + 1
+        if c.Within > 0 {
+            last = lineNum + c.Within
+        }
+    case "near":
+        if c.Within > 0 {
+            first, last = lineNum-c.Within, lineNum+c.Within
+        }
+    case "section":
+        if section, ok := doc.SectionAt(lineNum); ok {
+            first, last = section.StartLine, section.EndLine
+            if section.Line > 0 {
+                first = section.Line
+            }
+        }
+    default:
+        first, last = lineNum, lineNum
+    }
+    return max(first, 1), min(last, len(doc.Masked))
+}
+
+// inList reports whether the 1-based line belongs to a list of the given
+// kind: "ordered", "bullet" or "any". The lists are found on the first
+// call, as a composite rule asks about every line it matches.
+func (d *Document) inList(lineNum int, kind string) bool {
+    if !d.listed {
+        d.lists, d.listed = d.Lists(), true
+    }
+    for _, list := range d.lists {
+        if lineNum < list.Items[0].Line || lineNum > list.Items[len(list.Items)-1].EndLine {
+            continue
+        }
+        return kind == "any" || (kind == "ordered") == list.Ordered
+    }
+    return false
+}
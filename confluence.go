@@ -0,0 +1,123 @@
+// Confluence space input
+
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "net/http"
+    "net/url"
+    "os"
+    "strings"
+    "time"
+)
+
+// confluencePageLimit is the page size requested from the content API
+const confluencePageLimit = 50
+
+// ConfluenceSource fetches the pages of one Confluence space through the
+// REST content API, in storage format
+type ConfluenceSource struct {
+    BaseURL string // e.g. https://example.atlassian.net/wiki
+    Space   string
+    User    string // Cloud account email for basic auth; empty to send Token as a bearer token
+    Token   string
+}
+
+// runConfluence implements the confluence subcommand
+func runConfluence(args []string) int {
+    flags := flag.NewFlagSet("confluence", flag.ExitOnError)
+    common := addSourceFlags(flags)
+    baseURL := flags.String("url", os.Getenv("CONFLUENCE_URL"), "Confluence base URL, e.g. https://example.atlassian.net/wiki")
+    space := flags.String("space", "", "Space key")
+    user := flags.String("user", os.Getenv("CONFLUENCE_USER"), "Account email for Confluence Cloud (omit to use the token as a personal access token)")
+    flags.Parse(args)
+
+    source := ConfluenceSource{BaseURL: strings.TrimSuffix(*baseURL, "/"), Space: *space, User: *user, Token: os.Getenv("CONFLUENCE_TOKEN")}
+    if source.BaseURL == "" || source.Space == "" || source.Token == "" {
+        fmt.Fprintf(os.Stderr, "Usage: CONFLUENCE_TOKEN=... %s confluence -url <base_url> -space <key> [options]\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+    common.location = source.BaseURL + "/spaces/" + source.Space
+    return common.run(source.Fetch)
+}
+
+// Fetch returns every current page of the space, converted to Markdown.
+// Issues name a page by its title and ID.
+func (s ConfluenceSource) Fetch() ([]SourceDocument, error) {
+    client := &http.Client{Timeout: 60 * time.Second}
+    var docs []SourceDocument
+
+    for start := 0; ; start += confluencePageLimit {
+        query := url.Values{
+            "spaceKey": {s.Space},
+            "type":     {"page"},
+            "status":   {"current"},
+            "expand":   {"body.storage"},
+            "limit":    {fmt.Sprint(confluencePageLimit)},
+            "start":    {fmt.Sprint(start)},
+        }
+        request, err := http.NewRequest(http.MethodGet, s.BaseURL+"/rest/api/content?"+query.Encode(), nil)
+        if err != nil {
+            return nil, err
+        }
+        request.Header.Set("Accept", "application/json")
+        if s.User != "" {
+            request.SetBasicAuth(s.User, s.Token)
+        } else {
+            request.Header.Set("Authorization", "Bearer "+s.Token)
+        }
+
+        response, err := client.Do(request)
+        if err != nil {
+            return nil, err
+        }
+        var page struct {
+            Results []struct {
+                ID    string `json:"id"`
+                Title string `json:"title"`
+                Body  struct {
+                    Storage struct {
+                        Value string `json:"value"`
+                    } `json:"storage"`
+                } `json:"body"`
+                Links struct {
+                    WebUI string `json:"webui"`
+                } `json:"_links"`
+            } `json:"results"`
+            Links struct {
+                Base string `json:"base"`
+                Next string `json:"next"`
+            } `json:"_links"`
+        }
+        if response.StatusCode != http.StatusOK {
+            response.Body.Close()
+            return nil, fmt.Errorf("%s returned %s", s.BaseURL, response.Status)
+        }
+        err = json.NewDecoder(response.Body).Decode(&page)
+        response.Body.Close()
+        if err != nil {
+            return nil, fmt.Errorf("failed to decode Confluence response: %w", err)
+        }
+
+        base := page.Links.Base
+        if base == "" {
+            base = s.BaseURL
+        }
+        for _, result := range page.Results {
+            docs = append(docs, SourceDocument{
+                Path:    fmt.Sprintf("%s [%s]", result.Title, result.ID),
+                URL:     base + result.Links.WebUI,
+                Content: "# " + result.Title + "\n\n" + htmlToMarkdown(result.Body.Storage.Value),
+            })
+        }
+
+        if page.Links.Next == "" || len(page.Results) < confluencePageLimit {
+            break
+        }
+    }
+
+    return docs, nil
+}
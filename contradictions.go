@@ -0,0 +1,218 @@
+// Candidate contradictions between sentences across the corpus
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+const (
+    // minContradictionSimilarity is the embedding similarity at which two
+    // opposing sentences are taken to be about the same thing
+    minContradictionSimilarity = 0.8
+    // minContradictionOverlap is the share of their content words two
+    // sentences must share when embeddings are unavailable
+    minContradictionOverlap = 0.5
+    // minClaimWords and maxClaimWords keep fragments, labels and run-on
+    // list paragraphs from counting as claims
+    minClaimWords = 5
+    maxClaimWords = 40
+)
+
+var (
+    negationRegex = regexp.MustCompile(`(?i)\b(?:not|never|no|cannot|without)\b|n't\b`)
+    // antonymPairs are words whose presence on opposite sides of two
+    // otherwise similar sentences makes them opposing claims
+    antonymPairs = [][2]string{
+        {"enabled", "disabled"}, {"enable", "disable"}, {"supported", "unsupported"},
+        {"required", "optional"}, {"mandatory", "optional"}, {"allowed", "blocked"},
+        {"available", "unavailable"}, {"synchronous", "asynchronous"}, {"synchronously", "asynchronously"},
+        {"automatically", "manually"}, {"always", "never"}, {"public", "private"},
+        {"encrypted", "unencrypted"}, {"included", "excluded"}, {"before", "after"},
+    }
+)
+
+// claim is a declarative sentence that could contradict another
+type claim struct {
+    file, section string
+    line, column  int
+    text          string
+    stems         map[string]bool // stems of its content words
+    negated       bool
+    terms         map[string]bool // the antonymPairs words it uses
+}
+
+// corpusClaims returns the declarative sentences of the English documents
+func (a *Analyzer) corpusClaims(docs []*Document) []claim {
+    antonyms := make(map[string]bool)
+    for _, pair := range antonymPairs {
+        antonyms[pair[0]], antonyms[pair[1]] = true, true
+    }
+
+    var claims []claim
+    for _, doc := range docs {
+        if a.language(doc) != "en" {
+            continue // the negation and antonym lists are English
+        }
+        for _, paragraph := range doc.Paragraphs() {
+            for _, sentence := range paragraph.Sentences() {
+                text := plainText(strings.Join(strings.Fields(sentence.Text), " "))
+                if words := wordCount(text); words < minClaimWords || words > maxClaimWords || strings.HasSuffix(text, "?") {
+                    continue
+                }
+                c := claim{
+                    file:    doc.Path,
+                    section: paragraph.Section.Breadcrumb(),
+                    line:    sentence.Line,
+                    column:  sentence.Column,
+                    text:    text,
+                    stems:   make(map[string]bool),
+                    negated: negationRegex.MatchString(text),
+                    terms:   make(map[string]bool),
+                }
+                for _, word := range contentWords(text) {
+                    c.stems[stem(word)] = true
+                }
+                for _, word := range strings.Fields(strings.ToLower(text)) {
+                    if word = strings.Trim(word, ".,;:!\"'()"); antonyms[word] {
+                        c.terms[word] = true
+                    }
+                }
+                claims = append(claims, c)
+            }
+        }
+    }
+    return claims
+}
+
+// opposes reports whether two claims point in opposite directions: one is
+// negated and the other isn't, or they use opposite words of a pair
+func (c claim) opposes(other claim) bool {
+    if c.negated != other.negated {
+        return true
+    }
+    for _, pair := range antonymPairs {
+        if c.terms[pair[0]] && other.terms[pair[1]] && !c.terms[pair[1]] && !other.terms[pair[0]] {
+            return true
+        }
+        if c.terms[pair[1]] && other.terms[pair[0]] && !c.terms[pair[0]] && !other.terms[pair[1]] {
+            return true
+        }
+    }
+    return false
+}
+
+// overlap returns the share of the content words of the longer claim that
+// both use, and how many that is
+func (c claim) overlap(other claim) (float64, int) {
+    shared := 0
+    for stem := range c.stems {
+        if other.stems[stem] {
+            shared++
+        }
+    }
+    longer := max(len(c.stems), len(other.stems))
+    if longer == 0 {
+        return 0, 0
+    }
+    return float64(shared) / float64(longer), shared
+}
+
+// analyzeContradictions reports pairs of sentences in different sections
+// that make opposing claims about the same subject, such as "Sync runs
+// automatically" and "Sync must be started manually". Candidates share
+// their subject words and differ in negation or by an antonym; with
+// embeddings, they must also be close in meaning. Each pair is a
+// suggestion for a person to review, not a certain contradiction.
+func (a *Analyzer) analyzeContradictions(docs []*Document) []Issue {
+    claims := a.corpusClaims(docs)
+
+    // Candidates share at least two content words, so claims are grouped
+    // by each pair of their stems and compared only within a group, rather
+    // than every claim of the corpus with every other
+    type pair struct{ first, second int }
+    groups := make(map[[2]string][]int)
+    for i, c := range claims {
+        stems := sortedKeys(c.stems)
+        for x := range stems {
+            for _, other := range stems[x+1:] {
+                key := [2]string{stems[x], other}
+                groups[key] = append(groups[key], i)
+            }
+        }
+    }
+    compared := make(map[pair]bool)
+    var candidates []pair
+    for _, group := range groups {
+        for x, i := range group {
+            for _, j := range group[x+1:] {
+                if compared[pair{i, j}] {
+                    continue
+                }
+                compared[pair{i, j}] = true
+                if claims[i].file == claims[j].file && claims[i].section == claims[j].section {
+                    continue
+                }
+                if claims[i].opposes(claims[j]) {
+                    candidates = append(candidates, pair{i, j})
+                }
+            }
+        }
+    }
+    sort.Slice(candidates, func(i, j int) bool {
+        if candidates[i].first != candidates[j].first {
+            return candidates[i].first < candidates[j].first
+        }
+        return candidates[i].second < candidates[j].second
+    })
+    if len(candidates) == 0 {
+        return nil
+    }
+
+    // Only sentences in a candidate pair are embedded
+    similar := func(p pair) bool {
+        ratio, shared := claims[p.first].overlap(claims[p.second])
+        return shared >= 3 && ratio >= minContradictionOverlap
+    }
+    if a.embedder != nil {
+        index := make(map[int]int)
+        var texts []string
+        for _, p := range candidates {
+            for _, i := range []int{p.first, p.second} {
+                if _, ok := index[i]; !ok {
+                    index[i] = len(texts)
+                    texts = append(texts, claims[i].text)
+                }
+            }
+        }
+        if vectors, err := a.embedder.Embed(texts); err == nil {
+            similar = func(p pair) bool {
+                return cosineSimilarity(vectors[index[p.first]], vectors[index[p.second]]) >= minContradictionSimilarity
+            }
+        } else {
+            a.embedder.warnFallback(err)
+        }
+    }
+
+    var issues []Issue
+    for _, p := range candidates {
+        if !similar(p) {
+            continue
+        }
+        first, second := claims[p.first], claims[p.second]
+        issues = append(issues, Issue{
+            File:         first.file,
+            Line:         first.line,
+            Column:       first.column,
+            Rule:         "possible-contradiction",
+            Message:      fmt.Sprintf("May contradict %s:%d: %q", second.file, second.line, second.text),
+            Severity:     "suggestion",
+            Suggestion:   "Check which statement is current, then correct the other or qualify both (\"in version 2 and later ...\") so they don't give opposite answers",
+            OriginalText: first.text,
+        })
+    }
+    return issues
+}
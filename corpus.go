@@ -0,0 +1,74 @@
+// Corpus-level analysis across all input files
+
+package main
+
+// CorpusOptions selects the optional corpus-level passes
+type CorpusOptions struct {
+    LinkGraph      bool
+    Contradictions bool
+    Mmap           bool // map files into memory instead of reading them, see mapDocuments
+}
+
+// analyzeCorpus runs the checks that need every file of the corpus at once
+func (a *Analyzer) analyzeCorpus(files []string, options CorpusOptions) []Issue {
+    if options.Mmap {
+        return a.analyzeCorpusDocuments(mapDocuments(files, a.config.Formats), options)
+    }
+    return a.analyzeCorpusDocuments(loadDocuments(files, a.config.Formats), options)
+}
+
+// analyzeCorpusDocuments runs the corpus-level checks over parsed documents
+func (a *Analyzer) analyzeCorpusDocuments(docs []*Document, options CorpusOptions) []Issue {
+    issues := duplicateDescriptions(docs)
+    issues = append(issues, duplicatePageMetadata(docs)...)
+    issues = append(issues, a.undocumentedCLI(docs)...)
+    issues = append(issues, a.analyzeBoilerplate(docs)...)
+    issues = append(issues, a.analyzeValueConsistency(docs)...)
+    issues = append(issues, a.nav.missingNavPages()...)
+    if options.LinkGraph {
+        issues = append(issues, a.analyzeLinkGraph(buildLinkGraph(docs))...)
+    }
+    if options.Contradictions {
+        issues = append(issues, a.analyzeContradictions(docs)...)
+    }
+
+    issues = a.applyOverrides(issues)
+    locateSections(issues, docs...)
+    return issues
+}
+
+// loadDocuments reads files and parses them with corpusDocument, warning
+// about and skipping unreadable ones
+func loadDocuments(files []string, formats map[string]Format) []*Document {
+    var docs []*Document
+    for _, file := range files {
+        if hasEmbeddedDocs(file) {
+            continue // only its descriptions or comments are prose, analyzed file by file
+        }
+        content, err := readDocument(file)
+        if err != nil {
+            reportFailure(readFailureRule, file, 0, "failed to read %s: %v", file, err)
+            continue
+        }
+        docs = append(docs, corpusDocument(file, content, formats))
+    }
+    return docs
+}
+
+// corpusDocument parses the content of a file for the cross-file checks:
+// the narration of captions, the text of an email template, and other
+// documents as they are, those of formats with their Parser. Files with
+// embedded docs are left out before they're read.
+func corpusDocument(file, content string, formats map[string]Format) *Document {
+    switch {
+    case isCaptionFile(file):
+        return captionDocument(file, content)
+    case isEmailTemplate(file, content):
+        content = emailMarkdown(content)
+    }
+    doc := ParseDocument(file, content)
+    if format, ok := formatFor(formats, file); ok {
+        doc.useFormat(format)
+    }
+    return doc
+}
@@ -0,0 +1,150 @@
+// Embedding cost estimation and the -max-cost budget
+
+package main
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "os"
+)
+
+// embeddingPrices are the list prices of known embedding models, in US
+// dollars per million input tokens
+var embeddingPrices = map[string]float64{
+    "text-embedding-3-small": 0.02,
+    "text-embedding-3-large": 0.13,
+    "text-embedding-ada-002": 0.10,
+}
+
+// errBudgetExceeded is returned for requests that would overspend -max-cost
+var errBudgetExceeded = errors.New("the -max-cost budget is spent")
+
+// embeddingUsage counts what a run embedded
+type embeddingUsage struct {
+    requests int
+    tokens   int // as reported by the provider, or estimated
+    cached   int // texts read from the on-disk cache
+    reserved int // estimated tokens of the requests in flight, held against the budget
+}
+
+// embeddingPrice returns the configured price per million tokens, or the
+// list price of a known OpenAI model. Local providers cost nothing.
+func embeddingPrice(config EmbeddingsConfig, provider, model string) float64 {
+    if config.PricePerMillionTokens > 0 {
+        return config.PricePerMillionTokens
+    }
+    if provider != "openai" {
+        return 0
+    }
+    return embeddingPrices[model]
+}
+
+// cost returns the price of embedding tokens
+func (e *Embedder) cost(tokens int) float64 {
+    return float64(tokens) * e.price / 1e6
+}
+
+// setBudget limits what the run may spend on embeddings, in dollars. A
+// budget needs a price, so an OpenAI-compatible model whose price isn't
+// known needs PricePerMillionTokens.
+func (e *Embedder) setBudget(maxCost float64) error {
+    if maxCost > 0 && e.price == 0 && e.provider == "openai" {
+        return fmt.Errorf("-max-cost needs the price of %s; set Embeddings PricePerMillionTokens", e.model)
+    }
+    e.budget = maxCost
+    return nil
+}
+
+// charge reserves the estimated tokens of a batch against the budget
+// before the batch is sent, failing once the budget would be overspent,
+// and returns the tokens reserved. A reservation lasts until settle, so
+// batches sent concurrently can't overspend the budget together.
+func (e *Embedder) charge(texts []string) (int, error) {
+    if e.budget <= 0 {
+        return 0, nil
+    }
+    tokens := 0
+    for _, text := range texts {
+        tokens += estimateTokens(text)
+    }
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    if e.cost(e.usage.tokens+e.usage.reserved+tokens) > e.budget {
+        return 0, errBudgetExceeded
+    }
+    e.usage.reserved += tokens
+    return tokens, nil
+}
+
+// settle releases the tokens charge reserved for a batch, once request has
+// counted what the batch used, or the batch failed
+func (e *Embedder) settle(reserved int) {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    e.usage.reserved -= reserved
+}
+
+// projectEmbeddingTokens estimates the tokens the semantic checks will
+// send for docs: the heading path and body of each section the heading
+// check compares and the sentences the cohesion check splits, with
+// semantic, and every candidate claim sentence, with contradictions, which
+// is an upper bound. Texts already in the on-disk cache aren't counted.
+func (a *Analyzer) projectEmbeddingTokens(docs []*Document, semantic, contradictions bool) int {
+    seen := make(map[string]bool)
+    tokens := 0
+    count := func(text string) {
+        if !seen[text] && !a.embedder.cache.has(text) {
+            tokens += estimateTokens(text)
+        }
+        seen[text] = true
+    }
+    if semantic {
+        for _, doc := range docs {
+            for _, candidate := range a.mismatchCandidates(doc) {
+                count(candidate.section.Breadcrumb())
+                count(candidate.body)
+            }
+            if a.config.Cohesion.Enabled {
+                for _, candidate := range a.cohesionCandidates(doc) {
+                    for _, sentence := range candidate.sentences {
+                        count(sentence.Text)
+                    }
+                }
+            }
+        }
+    }
+    if contradictions {
+        for _, claim := range a.corpusClaims(docs) {
+            count(claim.text)
+        }
+    }
+    return tokens
+}
+
+// printUsage reports the embedding usage of a run against its projection
+// and budget
+func (e *Embedder) printUsage(w io.Writer, projected int) {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    fmt.Fprintf(w, "Embeddings: %d request(s), %d tokens", e.usage.requests, e.usage.tokens)
+    if e.price > 0 {
+        fmt.Fprintf(w, " ($%.4f)", e.cost(e.usage.tokens))
+    }
+    fmt.Fprintf(w, ", %d text(s) from cache; projected %d tokens", e.usage.cached, projected)
+    if e.price > 0 {
+        fmt.Fprintf(w, " ($%.4f)", e.cost(projected))
+    }
+    if e.budget > 0 {
+        fmt.Fprintf(w, "; budget $%.4f", e.budget)
+    }
+    fmt.Fprintln(w)
+}
+
+// warnOverBudget warns, before analysis, when the projection exceeds the
+// budget
+func (e *Embedder) warnOverBudget(projected int) {
+    if e.budget > 0 && e.cost(projected) > e.budget {
+        fmt.Fprintf(os.Stderr, "Warning: projected embedding cost $%.4f exceeds -max-cost $%.4f; semantic checks will use keyword overlap once the budget is spent\n", e.cost(projected), e.budget)
+    }
+}
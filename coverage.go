@@ -0,0 +1,226 @@
+// Feature coverage against a product feature list
+
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "regexp"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// Feature is a product feature or topic the docs should cover
+type Feature struct {
+    Name     string   `yaml:"Name" json:"name"`
+    Aliases  []string `yaml:"Aliases,omitempty" json:"aliases,omitempty"` // other names the docs may use, such as "SSO"
+    Priority string   `yaml:"Priority,omitempty" json:"priority"`         // "high", "medium" (default), "low"
+}
+
+// UnmarshalYAML accepts a bare string as a medium-priority feature
+func (f *Feature) UnmarshalYAML(node *yaml.Node) error {
+    if node.Kind == yaml.ScalarNode {
+        f.Name = node.Value
+        return nil
+    }
+    type plain Feature
+    return node.Decode((*plain)(f))
+}
+
+// CoverageSection is a section whose heading names a feature
+type CoverageSection struct {
+    File    string `json:"file"`
+    Line    int    `json:"line"`
+    Section string `json:"section"`
+}
+
+// CoveragePage is a page that mentions a feature in its text
+type CoveragePage struct {
+    File     string `json:"file"`
+    Mentions int    `json:"mentions"`
+}
+
+// FeatureCoverage is how well the docs cover one feature
+type FeatureCoverage struct {
+    Feature
+    Status   string            `json:"status"`             // "documented", "mentioned" (only in passing) or "missing"
+    Sections []CoverageSection `json:"sections,omitempty"` // sections dedicated to the feature
+    Pages    []CoveragePage    `json:"pages,omitempty"`    // pages mentioning it, in corpus order
+}
+
+// CoverageReport is the result of the coverage subcommand
+type CoverageReport struct {
+    Features   []FeatureCoverage `json:"features"`
+    Documented int               `json:"documented"`
+    Mentioned  int               `json:"mentioned"`
+    Missing    int               `json:"missing"`
+    HighUnmet  int               `json:"high_priority_undocumented"`
+}
+
+// runCoverage implements the coverage subcommand
+func runCoverage(args []string) int {
+    flags := flag.NewFlagSet("coverage", flag.ExitOnError)
+    outputFormat := flags.String("output", "standard", "Output format (standard, json)")
+    recursive := flags.Bool("recursive", false, "Process directories recursively")
+    followSymlinks := flags.Bool("follow-symlinks", false, "Descend into symlinked directories during recursive walks")
+    flags.Parse(args)
+
+    if flags.NArg() < 2 {
+        fmt.Fprintf(os.Stderr, "Usage: %s coverage [options] <features.yml> <file_or_directory>...\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+
+    features, err := loadFeatures(flags.Arg(0))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+
+    var docs []*Document
+    for _, path := range flags.Args()[1:] {
+        found, err := collectFiles(path, WalkOptions{Recursive: *recursive, FollowSymlinks: *followSymlinks})
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", path, err)
+            return 1
+        }
+        docs = append(docs, loadDocuments(found, nil)...)
+    }
+
+    report := featureCoverage(features, docs)
+
+    switch *outputFormat {
+    case "json":
+        encoder := json.NewEncoder(os.Stdout)
+        encoder.SetIndent("", "  ")
+        if err := encoder.Encode(report); err != nil {
+            fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+            return 1
+        }
+    default:
+        printCoverageReport(report)
+    }
+    if report.HighUnmet > 0 {
+        return 1
+    }
+    return 0
+}
+
+// loadFeatures reads a YAML file with a Features list, or a bare list. Each
+// entry is a feature name or a Name with Aliases and a Priority.
+func loadFeatures(path string) ([]Feature, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var file struct {
+        Features []Feature `yaml:"Features"`
+    }
+    if err := yaml.Unmarshal(data, &file); err != nil {
+        if err := yaml.Unmarshal(data, &file.Features); err != nil {
+            return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+        }
+    }
+
+    for i := range file.Features {
+        feature := &file.Features[i]
+        feature.Name = strings.TrimSpace(feature.Name)
+        if feature.Name == "" {
+            return nil, fmt.Errorf("%s: feature %d has no name", path, i+1)
+        }
+        switch feature.Priority {
+        case "":
+            feature.Priority = "medium"
+        case "high", "medium", "low":
+        default:
+            return nil, fmt.Errorf("%s: feature %q has invalid priority %q (want high, medium or low)", path, feature.Name, feature.Priority)
+        }
+    }
+    if len(file.Features) == 0 {
+        return nil, fmt.Errorf("%s lists no features", path)
+    }
+    return file.Features, nil
+}
+
+// featureRegex matches any of a feature's names as whole words, ignoring
+// case, spacing and a plural ending
+func featureRegex(feature Feature) *regexp.Regexp {
+    var names []string
+    for _, name := range append([]string{feature.Name}, feature.Aliases...) {
+        if fields := strings.Fields(name); len(fields) > 0 {
+            quoted := make([]string, len(fields))
+            for i, field := range fields {
+                quoted[i] = regexp.QuoteMeta(field)
+            }
+            names = append(names, strings.Join(quoted, `[\s-]+`))
+        }
+    }
+    return regexp.MustCompile(`(?i)(?:^|\W)(?:` + strings.Join(names, "|") + `)(?:e?s)?(?:\W|$)`)
+}
+
+// featureCoverage finds, for each feature, the sections whose heading names
+// it and the pages whose text mentions it. A feature named in a heading is
+// documented; one that appears only in body text is mentioned in passing.
+func featureCoverage(features []Feature, docs []*Document) CoverageReport {
+    var report CoverageReport
+    for _, feature := range features {
+        pattern := featureRegex(feature)
+        result := FeatureCoverage{Feature: feature}
+        for _, doc := range docs {
+            if title, ok := doc.FrontMatter["title"].(string); ok && pattern.MatchString(title) {
+                result.Sections = append(result.Sections, CoverageSection{File: doc.Path, Line: 1, Section: title})
+            }
+            mentions := 0
+            for _, section := range doc.Sections {
+                if section.Line > 0 && pattern.MatchString(plainText(section.Heading)) {
+                    result.Sections = append(result.Sections, CoverageSection{File: doc.Path, Line: section.Line, Section: section.Breadcrumb()})
+                }
+                mentions += len(pattern.FindAllStringIndex(doc.prose(section), -1))
+            }
+            if mentions > 0 {
+                result.Pages = append(result.Pages, CoveragePage{File: doc.Path, Mentions: mentions})
+            }
+        }
+
+        switch {
+        case len(result.Sections) > 0:
+            result.Status = "documented"
+            report.Documented++
+        case len(result.Pages) > 0:
+            result.Status = "mentioned"
+            report.Mentioned++
+        default:
+            result.Status = "missing"
+            report.Missing++
+        }
+        if result.Status != "documented" && feature.Priority == "high" {
+            report.HighUnmet++
+        }
+        report.Features = append(report.Features, result)
+    }
+    return report
+}
+
+func printCoverageReport(report CoverageReport) {
+    for _, result := range report.Features {
+        status := map[string]string{"documented": "documented", "mentioned": "MENTIONED ONLY IN PASSING", "missing": "MISSING"}[result.Status]
+        fmt.Printf("%s [%s] %s\n", result.Name, result.Priority, status)
+        for _, section := range result.Sections {
+            fmt.Printf("  %s:%d %s\n", section.File, section.Line, section.Section)
+        }
+        if len(result.Sections) == 0 {
+            for _, page := range result.Pages {
+                fmt.Printf("  %s (%d mention(s))\n", page.File, page.Mentions)
+            }
+        }
+        if result.Status == "missing" {
+            fmt.Println("  no page mentions this feature")
+        }
+        fmt.Println()
+    }
+    fmt.Printf("%d of %d features documented, %d mentioned only in passing, %d missing (%d high priority undocumented)\n",
+        report.Documented, len(report.Features), report.Mentioned, report.Missing, report.HighUnmet)
+}
@@ -0,0 +1,358 @@
+// Website crawler input
+
+package main
+
+import (
+    "bufio"
+    "flag"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// crawlUserAgent identifies the crawler to sites and to robots.txt rules
+const crawlUserAgent = "ai-doc-optimizer"
+
+// maxPageBytes caps how much of a fetched page is read
+const maxPageBytes = 10 << 20
+
+var (
+    hrefRegex      = regexp.MustCompile(`(?i)<a\b[^>]*?\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+    mainStartRegex = regexp.MustCompile(`(?i)<(main|article)\b[^>]*>|<(\w+)\b[^>]*\brole\s*=\s*["']?main\b[^>]*>`)
+    bodyStartRegex = regexp.MustCompile(`(?i)<body\b[^>]*>`)
+    chromeRegex    = regexp.MustCompile(`(?i)<(nav|footer|aside|form)\b[^>]*>|<(\w+)\b[^>]*\b(?:class|id|role)\s*=\s*["'](?:[^"']*\s)?(?:nav|navbar|navigation|menu|sidebar|footer|breadcrumbs?|cookie-banner|skip-link|pagination)(?:\s[^"']*)?["'][^>]*>`)
+    anyTagRegex    = regexp.MustCompile(`<(/?)([a-zA-Z][\w:-]*)\b[^>]*?(/?)>`)
+    // non-page resources that aren't worth fetching
+    assetExtensionRegex = regexp.MustCompile(`(?i)\.(?:png|jpe?g|gif|svg|webp|ico|css|js|json|xml|pdf|zip|gz|tgz|mp4|mp3|woff2?|ttf)$`)
+)
+
+// Crawler fetches the HTML pages of one site breadth-first from a start
+// URL, staying on its host and obeying its robots.txt
+type Crawler struct {
+    Start    *url.URL
+    Depth    int // link hops from the start page
+    MaxPages int
+    Delay    time.Duration // minimum time between requests; raised by a robots.txt Crawl-delay
+
+    client *http.Client
+    robots robotsRules
+}
+
+// runCrawl implements the crawl subcommand
+func runCrawl(args []string) int {
+    flags := flag.NewFlagSet("crawl", flag.ExitOnError)
+    common := addSourceFlags(flags)
+    depth := flags.Int("depth", 2, "Maximum number of links to follow from the start page")
+    maxPages := flags.Int("max-pages", 500, "Stop after fetching this many pages")
+    delay := flags.Duration("delay", 200*time.Millisecond, "Minimum time between requests")
+    flags.Parse(args)
+
+    if flags.NArg() != 1 {
+        fmt.Fprintf(os.Stderr, "Usage: %s crawl [options] <url>\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+    start, err := url.Parse(flags.Arg(0))
+    if err != nil || (start.Scheme != "http" && start.Scheme != "https") || start.Host == "" {
+        fmt.Fprintf(os.Stderr, "Error: %s is not an http or https URL\n", flags.Arg(0))
+        return 1
+    }
+
+    crawler := &Crawler{Start: start, Depth: *depth, MaxPages: *maxPages, Delay: *delay}
+    common.location = start.String()
+    return common.run(crawler.Fetch)
+}
+
+// Fetch crawls the site and returns the main content of each page as
+// Markdown. Issues name a page by its URL.
+func (c *Crawler) Fetch() ([]SourceDocument, error) {
+    c.client = &http.Client{Timeout: 30 * time.Second}
+    c.robots = c.fetchRobots(c.Start)
+    if c.robots.delay > c.Delay {
+        c.Delay = c.robots.delay
+    }
+
+    type queued struct {
+        url   *url.URL
+        depth int
+    }
+    start := *c.Start
+    start.Fragment = ""
+    queue := []queued{{&start, 0}}
+    seen := map[string]bool{start.String(): true}
+    var docs []SourceDocument
+    var last time.Time
+
+    for len(queue) > 0 && len(docs) < c.MaxPages {
+        next := queue[0]
+        queue = queue[1:]
+        if !c.robots.allowed(next.url.EscapedPath()) {
+            continue
+        }
+
+        if wait := c.Delay - time.Since(last); wait > 0 {
+            time.Sleep(wait)
+        }
+        last = time.Now()
+        page, final, err := c.get(next.url)
+        if err != nil {
+            reportFailure(readFailureRule, next.url.String(), 0, "%v", err)
+            continue
+        }
+
+        docs = append(docs, SourceDocument{Path: final.String(), URL: final.String(), Content: htmlToMarkdown(mainContent(page))})
+        if next.depth >= c.Depth {
+            continue
+        }
+        for _, link := range pageLinks(page, final) {
+            if !seen[link.String()] && link.Host == c.Start.Host {
+                seen[link.String()] = true
+                queue = append(queue, queued{link, next.depth + 1})
+            }
+        }
+    }
+
+    return docs, nil
+}
+
+// get fetches an HTML page, returning its body and its URL after redirects
+func (c *Crawler) get(target *url.URL) (string, *url.URL, error) {
+    request, err := http.NewRequest(http.MethodGet, target.String(), nil)
+    if err != nil {
+        return "", nil, err
+    }
+    request.Header.Set("User-Agent", crawlUserAgent)
+    request.Header.Set("Accept", "text/html")
+
+    response, err := c.client.Do(request)
+    if err != nil {
+        return "", nil, err
+    }
+    defer response.Body.Close()
+    if response.StatusCode != http.StatusOK {
+        return "", nil, fmt.Errorf("%s returned %s", target, response.Status)
+    }
+    if contentType := response.Header.Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "html") {
+        return "", nil, fmt.Errorf("%s is %s, not HTML", target, contentType)
+    }
+    data, err := io.ReadAll(io.LimitReader(response.Body, maxPageBytes))
+    if err != nil {
+        return "", nil, err
+    }
+    return decodeText(data), response.Request.URL, nil
+}
+
+// pageLinks returns the absolute http(s) URLs a page links to, without
+// fragments, skipping links to assets
+func pageLinks(page string, base *url.URL) []*url.URL {
+    var links []*url.URL
+    for _, match := range hrefRegex.FindAllStringSubmatch(page, -1) {
+        href := strings.TrimSpace(match[1] + match[2] + match[3])
+        link, err := base.Parse(href)
+        if err != nil || (link.Scheme != "http" && link.Scheme != "https") || assetExtensionRegex.MatchString(link.Path) {
+            continue
+        }
+        link.Fragment = ""
+        links = append(links, link)
+    }
+    return links
+}
+
+// mainContent applies readability heuristics to find the article in a
+// page: the <main> or <article> element or role="main" region if there is
+// one, otherwise the body, with navigation, footers, sidebars, menus and
+// similar page chrome removed
+func mainContent(page string) string {
+    content := page
+    if loc := mainStartRegex.FindStringSubmatchIndex(page); loc != nil {
+        tag := ""
+        if loc[2] >= 0 {
+            tag = page[loc[2]:loc[3]]
+        } else {
+            tag = page[loc[4]:loc[5]]
+        }
+        content = page[loc[1]:elementEnd(page, loc[1], tag)]
+    } else if loc := bodyStartRegex.FindStringIndex(page); loc != nil {
+        content = page[loc[1]:]
+    }
+
+    for {
+        loc := chromeRegex.FindStringSubmatchIndex(content)
+        if loc == nil {
+            return content
+        }
+        tag := ""
+        if loc[2] >= 0 {
+            tag = content[loc[2]:loc[3]]
+        } else {
+            tag = content[loc[4]:loc[5]]
+        }
+        // A void or self-closing element, or one never closed, has no
+        // content to drop beyond its tag
+        end := elementEnd(content, loc[1], tag)
+        if htmlVoidTags[strings.ToLower(tag)] || strings.HasSuffix(content[loc[0]:loc[1]], "/>") || end == len(content) {
+            end = loc[1]
+        } else if closing := strings.Index(content[end:], ">"); closing >= 0 {
+            end += closing + 1
+        }
+        content = content[:loc[0]] + content[end:]
+    }
+}
+
+// elementEnd returns the offset of the closing tag that matches an element
+// of the given name opened just before offset start, counting nested
+// elements of the same name, or the end of page if it isn't closed
+func elementEnd(page string, start int, tag string) int {
+    depth := 1
+    for _, loc := range anyTagRegex.FindAllStringSubmatchIndex(page[start:], -1) {
+        if !strings.EqualFold(page[start+loc[4]:start+loc[5]], tag) {
+            continue
+        }
+        switch {
+        case loc[3] > loc[2]:
+            depth--
+        case loc[7] > loc[6]:
+            // self-closing
+        default:
+            depth++
+        }
+        if depth == 0 {
+            return start + loc[0]
+        }
+    }
+    return len(page)
+}
+
+// robotsRules are the robots.txt rules that apply to the crawler
+type robotsRules struct {
+    allow, disallow []string
+    delay           time.Duration
+}
+
+// fetchRobots reads the robots.txt of site's host. A missing or unreadable
+// file allows everything.
+func (c *Crawler) fetchRobots(site *url.URL) robotsRules {
+    robotsURL := *site
+    robotsURL.Path, robotsURL.RawQuery, robotsURL.Fragment = "/robots.txt", "", ""
+    request, err := http.NewRequest(http.MethodGet, robotsURL.String(), nil)
+    if err != nil {
+        return robotsRules{}
+    }
+    request.Header.Set("User-Agent", crawlUserAgent)
+    response, err := c.client.Do(request)
+    if err != nil {
+        return robotsRules{}
+    }
+    defer response.Body.Close()
+    if response.StatusCode != http.StatusOK {
+        return robotsRules{}
+    }
+    return parseRobots(io.LimitReader(response.Body, 512<<10), crawlUserAgent)
+}
+
+// parseRobots returns the rules of the most specific group naming agent,
+// the one with the longest name, or of the "*" group when none does
+func parseRobots(r io.Reader, agent string) robotsRules {
+    groups := make(map[string]*robotsRules)
+    var current []string
+    inRules := false
+
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        line := scanner.Text()
+        if i := strings.Index(line, "#"); i >= 0 {
+            line = line[:i]
+        }
+        field, value, ok := strings.Cut(line, ":")
+        if !ok {
+            continue
+        }
+        field, value = strings.ToLower(strings.TrimSpace(field)), strings.TrimSpace(value)
+
+        if field == "user-agent" {
+            if inRules {
+                current, inRules = nil, false
+            }
+            name := strings.ToLower(value)
+            current = append(current, name)
+            if groups[name] == nil {
+                groups[name] = &robotsRules{}
+            }
+            continue
+        }
+        inRules = true
+        for _, name := range current {
+            group := groups[name]
+            switch field {
+            case "allow":
+                group.allow = append(group.allow, value)
+            case "disallow":
+                if value != "" {
+                    group.disallow = append(group.disallow, value)
+                }
+            case "crawl-delay":
+                if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+                    group.delay = time.Duration(seconds * float64(time.Second))
+                }
+            }
+        }
+    }
+
+    best := ""
+    for _, name := range sortedKeys(groups) {
+        if name != "*" && name != "" && len(name) > len(best) && strings.Contains(strings.ToLower(agent), name) {
+            best = name
+        }
+    }
+    if best == "" {
+        best = "*"
+    }
+    if group := groups[best]; group != nil {
+        return *group
+    }
+    return robotsRules{}
+}
+
+// allowed applies the longest matching rule to a path; Allow wins ties
+func (r robotsRules) allowed(urlPath string) bool {
+    if urlPath == "" {
+        urlPath = "/"
+    }
+    longestAllow, longestDisallow := -1, -1
+    for _, rule := range r.allow {
+        if robotsMatch(rule, urlPath) && len(rule) > longestAllow {
+            longestAllow = len(rule)
+        }
+    }
+    for _, rule := range r.disallow {
+        if robotsMatch(rule, urlPath) && len(rule) > longestDisallow {
+            longestDisallow = len(rule)
+        }
+    }
+    return longestDisallow < 0 || longestAllow >= longestDisallow
+}
+
+// robotsMatch matches a robots.txt path rule, where "*" is any run of
+// characters and a trailing "$" anchors the end
+func robotsMatch(rule, urlPath string) bool {
+    anchored := strings.HasSuffix(rule, "$")
+    rule = strings.TrimSuffix(rule, "$")
+    if !strings.Contains(rule, "*") {
+        if anchored {
+            return urlPath == rule
+        }
+        return strings.HasPrefix(urlPath, rule)
+    }
+    pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(rule), `\*`, ".*")
+    if anchored {
+        pattern += "$"
+    }
+    matched, _ := regexp.MatchString(pattern, urlPath)
+    return matched
+}
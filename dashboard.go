@@ -0,0 +1,311 @@
+// Web dashboard over a results database
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "html/template"
+    "net/http"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// RuleCount is how many issues of a rule a run reported
+type RuleCount struct {
+    Rule    string
+    Issues  int
+    DocsURL string // page explaining the rule, from DocsBaseURL
+}
+
+// FileScore is a file's row of a run in the files table
+type FileScore struct {
+    Run       int
+    StartedAt string
+    Path      string
+    Issues    int
+    Words     int
+    Score     float64
+}
+
+// RecordedIssue is an issue of a run with when its fingerprint was first
+// reported
+type RecordedIssue struct {
+    Issue
+    FirstSeen string
+}
+
+// topRules returns the rules with the most issues in run, most first
+func (db ResultsDB) topRules(run, limit int) ([]RuleCount, error) {
+    rows, err := db.query(fmt.Sprintf("SELECT rule, issues FROM rule_trend WHERE run_id = %d ORDER BY issues DESC, rule LIMIT %d", run, limit))
+    if err != nil {
+        return nil, err
+    }
+    counts := make([]RuleCount, 0, len(rows))
+    for _, row := range rows {
+        if len(row) != 2 {
+            return nil, fmt.Errorf("unexpected rule_trend row %q", row)
+        }
+        n, _ := strconv.Atoi(row[1])
+        counts = append(counts, RuleCount{Rule: row[0], Issues: n})
+    }
+    return counts, nil
+}
+
+// worstFiles returns the lowest-scoring files of run, lowest first
+func (db ResultsDB) worstFiles(run, limit int) ([]FileScore, error) {
+    return db.fileScores(fmt.Sprintf("f.run_id = %d ORDER BY f.score, f.issues DESC, f.path LIMIT %d", run, limit))
+}
+
+// fileHistory returns a file's rows of every run, oldest first
+func (db ResultsDB) fileHistory(path string) ([]FileScore, error) {
+    return db.fileScores(fmt.Sprintf("f.path = %s ORDER BY f.run_id", sqlQuote(path)))
+}
+
+// fileScores returns the rows of the files table that condition, an SQL
+// WHERE clause over f, selects
+func (db ResultsDB) fileScores(condition string) ([]FileScore, error) {
+    rows, err := db.query("SELECT f.run_id, r.started_at, f.path, f.issues, f.words, f.score FROM files f JOIN runs r ON r.id = f.run_id WHERE " + condition)
+    if err != nil {
+        return nil, err
+    }
+    scores := make([]FileScore, 0, len(rows))
+    for _, row := range rows {
+        if len(row) != 6 {
+            return nil, fmt.Errorf("unexpected files row %q", row)
+        }
+        run, _ := strconv.Atoi(row[0])
+        issues, _ := strconv.Atoi(row[3])
+        words, _ := strconv.Atoi(row[4])
+        score, _ := strconv.ParseFloat(row[5], 64)
+        scores = append(scores, FileScore{Run: run, StartedAt: row[1], Path: row[2], Issues: issues, Words: words, Score: score})
+    }
+    return scores, nil
+}
+
+// fileIssues returns the issues run reported in path, in file order
+func (db ResultsDB) fileIssues(run int, path string) ([]RecordedIssue, error) {
+    rows, err := db.query(fmt.Sprintf(`SELECT i.line, i.col, i.rule, i.severity, i.message, i.suggestion, l.first_seen
+        FROM issues i JOIN runs r ON r.id = i.run_id
+        JOIN issue_lifecycle l ON l.fingerprint = i.fingerprint AND l.command = r.command AND l.paths = r.paths
+        WHERE i.run_id = %d AND i.file = %s ORDER BY i.line, i.col, i.rule`, run, sqlQuote(path)))
+    if err != nil {
+        return nil, err
+    }
+    issues := make([]RecordedIssue, 0, len(rows))
+    for _, row := range rows {
+        if len(row) != 7 {
+            return nil, fmt.Errorf("unexpected issues row %q", row)
+        }
+        line, _ := strconv.Atoi(row[0])
+        column, _ := strconv.Atoi(row[1])
+        issues = append(issues, RecordedIssue{
+            Issue:     Issue{File: path, Line: line, Column: column, Rule: row[2], Severity: row[3], Message: row[4], Suggestion: row[5]},
+            FirstSeen: row[6],
+        })
+    }
+    return issues, nil
+}
+
+// scoreChart is a line chart of scores from 0 to 100, drawn as SVG
+type scoreChart struct {
+    Width, Height int
+    Points        string // polyline points, x,y pairs
+    First, Last   string // labels of the first and last point
+}
+
+// newScoreChart plots scores evenly spaced, oldest on the left
+func newScoreChart(scores []float64, first, last string) scoreChart {
+    chart := scoreChart{Width: 600, Height: 120, First: first, Last: last}
+    var points []string
+    for i, score := range scores {
+        x := float64(chart.Width) / 2
+        if len(scores) > 1 {
+            x = float64(i) * float64(chart.Width) / float64(len(scores)-1)
+        }
+        y := float64(chart.Height) * (1 - score/100)
+        points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+    }
+    chart.Points = strings.Join(points, " ")
+    return chart
+}
+
+var dashboardTemplates = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+    "score": func(score float64) string { return fmt.Sprintf("%.1f", score) },
+}).Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.}} - ai-doc-optimizer</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em auto; max-width: 60em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; vertical-align: top; }
+td.n { text-align: right; }
+svg { background: #f6f8fa; }
+polyline { fill: none; stroke: #0969da; stroke-width: 2; }
+.error { color: #cf222e; } .warning { color: #9a6700; } .failure { color: #cf222e; font-weight: bold; }
+.muted { color: #777; }
+</style></head><body>
+{{end}}
+
+{{define "chart"}}<svg width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}"><polyline points="{{.Points}}"/></svg>
+<p class="muted">{{.First}} to {{.Last}}, score 0 to 100</p>
+{{end}}
+
+{{define "overview"}}{{template "header" "Dashboard"}}
+<h1>Documentation AI-readiness</h1>
+{{with .Latest}}<p>Run #{{.Run}} at {{.StartedAt}}{{if .Revision}} ({{.Revision}}){{end}}: score <strong>{{score .Score}}</strong>,
+{{.FilesAnalyzed}} files, {{.Issues}} issues ({{.Errors}} errors, {{.Warnings}} warnings, {{.Suggestions}} suggestions), {{.New}} new and {{.Fixed}} fixed since the run before.</p>{{end}}
+<h2>Score trend</h2>
+{{template "chart" .Chart}}
+<h2>Top rules</h2>
+<table><tr><th>Rule</th><th>Issues</th></tr>
+{{range .Rules}}<tr><td>{{if .DocsURL}}<a href="{{.DocsURL}}">{{.Rule}}</a>{{else}}{{.Rule}}{{end}}</td><td class="n">{{.Issues}}</td></tr>
+{{end}}</table>
+<h2>Worst files</h2>
+<table><tr><th>File</th><th>Score</th><th>Issues</th><th>Words</th></tr>
+{{range .Files}}<tr><td><a href="/file?path={{.Path}}">{{.Path}}</a></td><td class="n">{{score .Score}}</td><td class="n">{{.Issues}}</td><td class="n">{{.Words}}</td></tr>
+{{end}}</table>
+<h2>Runs</h2>
+<table><tr><th>Run</th><th>Started</th><th>Score</th><th>Files</th><th>Issues</th><th>New</th><th>Fixed</th></tr>
+{{range .Runs}}<tr><td>#{{.Run}}</td><td>{{.StartedAt}}</td><td class="n">{{score .Score}}</td><td class="n">{{.FilesAnalyzed}}</td><td class="n">{{.Issues}}</td><td class="n">{{.New}}</td><td class="n">{{.Fixed}}</td></tr>
+{{end}}</table>
+</body></html>
+{{end}}
+
+{{define "file"}}{{template "header" .Path}}
+<p><a href="/">Dashboard</a></p>
+<h1>{{.Path}}</h1>
+{{with .Latest}}<p>Run #{{.Run}} at {{.StartedAt}}: score <strong>{{score .Score}}</strong>, {{.Issues}} issues in {{.Words}} words.</p>{{end}}
+<h2>Score trend</h2>
+{{template "chart" .Chart}}
+<h2>Issues</h2>
+<table><tr><th>Line</th><th>Severity</th><th>Rule</th><th>Issue</th><th>First seen</th></tr>
+{{range .Issues}}<tr><td class="n">{{.Line}}:{{.Column}}</td><td class="{{.Severity}}">{{.Severity}}</td><td>{{if .DocsURL}}<a href="{{.DocsURL}}">{{.Rule}}</a>{{else}}{{.Rule}}{{end}}</td>
+<td>{{.Message}}{{if .Suggestion}}<br><span class="muted">{{.Suggestion}}</span>{{end}}</td><td>{{.FirstSeen}}</td></tr>
+{{end}}</table>
+</body></html>
+{{end}}
+`))
+
+// runDashboard implements the dashboard subcommand: a web UI over a
+// results database written with -db, showing the score trend, the top
+// rules and worst files of the latest run, and each file's issues. Pages
+// query the database on every request, so new runs show up on reload.
+func runDashboard(args []string) int {
+    flags := flag.NewFlagSet("dashboard", flag.ExitOnError)
+    dbPath := flags.String("db", "results.db", "SQLite results database written by analyze -db")
+    addr := flags.String("addr", "127.0.0.1:8081", "Address to listen on")
+    top := flags.Int("top", 20, "Number of rules, files and runs to list")
+    configPath := flags.String("config", "", "Path to configuration file, whose DocsBaseURL links each rule")
+    flags.Parse(args)
+
+    config, err := loadConfig(*configPath)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: failed to load config: %v\n", err)
+        return 1
+    }
+
+    if _, err := os.Stat(*dbPath); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+    db := ResultsDB{Path: *dbPath}
+
+    fail := func(w http.ResponseWriter, err error) {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+    }
+    render := func(w http.ResponseWriter, name string, data any) {
+        w.Header().Set("Content-Type", "text/html; charset=utf-8")
+        if err := dashboardTemplates.ExecuteTemplate(w, name, data); err != nil {
+            fmt.Fprintf(os.Stderr, "Error writing response: %v\n", err)
+        }
+    }
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/" {
+            http.NotFound(w, r)
+            return
+        }
+        runs, err := db.runSummaries(0)
+        if err != nil {
+            fail(w, err)
+            return
+        }
+        data := struct {
+            Latest *RunSummary
+            Runs   []RunSummary
+            Chart  scoreChart
+            Rules  []RuleCount
+            Files  []FileScore
+        }{}
+        if len(runs) > 0 {
+            latest := runs[len(runs)-1]
+            data.Latest = &latest
+            var scores []float64
+            for _, run := range runs {
+                scores = append(scores, run.Score)
+            }
+            data.Chart = newScoreChart(scores, runs[0].StartedAt, latest.StartedAt)
+            if data.Rules, err = db.topRules(latest.Run, *top); err != nil {
+                fail(w, err)
+                return
+            }
+            for i := range data.Rules {
+                data.Rules[i].DocsURL = config.ruleDocsURL(data.Rules[i].Rule)
+            }
+            if data.Files, err = db.worstFiles(latest.Run, *top); err != nil {
+                fail(w, err)
+                return
+            }
+            // Most recent runs first
+            for i := len(runs) - 1; i >= 0 && len(data.Runs) < *top; i-- {
+                data.Runs = append(data.Runs, runs[i])
+            }
+        }
+        render(w, "overview", data)
+    })
+    mux.HandleFunc("/file", func(w http.ResponseWriter, r *http.Request) {
+        path := r.URL.Query().Get("path")
+        history, err := db.fileHistory(path)
+        if err != nil {
+            fail(w, err)
+            return
+        }
+        if len(history) == 0 {
+            http.NotFound(w, r)
+            return
+        }
+        latest := history[len(history)-1]
+        var scores []float64
+        for _, row := range history {
+            scores = append(scores, row.Score)
+        }
+        issues, err := db.fileIssues(latest.Run, path)
+        if err != nil {
+            fail(w, err)
+            return
+        }
+        for i := range issues {
+            issues[i].DocsURL = config.ruleDocsURL(issues[i].Rule)
+        }
+        render(w, "file", struct {
+            Path   string
+            Latest FileScore
+            Chart  scoreChart
+            Issues []RecordedIssue
+        }{path, latest, newScoreChart(scores, history[0].StartedAt, latest.StartedAt), issues})
+    })
+    mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprintln(w, "ok")
+    })
+
+    fmt.Fprintf(os.Stderr, "Listening on http://%s\n", *addr)
+    if err := http.ListenAndServe(*addr, mux); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+    return 0
+}
@@ -0,0 +1,135 @@
+// Downloadable data files and the description of their columns
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// dataFileExtensions are the extensions of spreadsheet and data files
+const dataFileExtensions = `csv|tsv|xlsx|xls|xlsm|ods|parquet|jsonl|ndjson`
+
+var (
+    // dataFileLinkRegex matches a Markdown link, link definition or href to
+    // a data file, capturing its target
+    dataFileLinkRegex = regexp.MustCompile(`(?i)\]\(\s*<?([^)\s>]+\.(?:` + dataFileExtensions + `))(?:[?#][^)\s>]*)?>?(?:\s+"[^"]*")?\s*\)|^\s*\[[^\]]+\]:\s*<?(\S+?\.(?:` + dataFileExtensions + `))(?:[?#]\S*)?>?(?:\s|$)|\bhref\s*=\s*["']([^"']+\.(?:` + dataFileExtensions + `))(?:[?#][^"']*)?["']`)
+    // vagueDataFileRegex matches a reference to an attachment the text
+    // doesn't name: "see the attached spreadsheet"
+    vagueDataFileRegex = regexp.MustCompile(`(?i)\b(?:see|refer to|check|open|review|consult|use|download|fill (?:in|out)|in|from)\s+(?:the\s+)?(?:attached|enclosed|accompanying|included|provided)\s+(?:spreadsheets?|csv(?: files?)?|excel(?: (?:files?|sheets?|workbooks?))?|workbooks?|data(?: ?sets?| files?)?|sheets?|files?)\b`)
+    // columnTableHeaderRegex matches the header row of a table describing
+    // columns or fields
+    columnTableHeaderRegex = regexp.MustCompile(`(?i)^\|?\s*(?:\*\*|__)?(?:columns?|fields?|(?:column |field )?names?|headers?|attributes?|variables?|keys?)\b`)
+    // columnItemRegex matches a list item describing a column: a name in
+    // code or bold, or a short name, then its meaning
+    columnItemRegex = regexp.MustCompile("^(?:`[^`]+`|\\*\\*[^*]+\\*\\*|[\\w ]{1,30}?)\\s*(?:[:：—–]|\\s-\\s|\\()\\s*\\S")
+    // columnProseRegex matches a sentence that says what columns a file has
+    columnProseRegex = regexp.MustCompile(`(?i)\b(?:columns?|fields?)\b`)
+)
+
+// minDescribedColumns is the fewest columns a description names
+const minDescribedColumns = 2
+
+// analyzeDataFiles checks that a linked CSV, spreadsheet or other data
+// file has its columns and what they hold described next to the link, and
+// flags references to attached data the text doesn't describe at all. An
+// assistant reading the page never opens the file, so "see the attached
+// spreadsheet" tells it nothing about what's in it.
+func (a *Analyzer) analyzeDataFiles(doc *Document) []Issue {
+    var issues []Issue
+    described := make(map[int]bool) // by section start, whether the section describes columns
+    describes := func(lineNum int) bool {
+        section, ok := doc.SectionAt(lineNum)
+        if !ok {
+            return true
+        }
+        if _, seen := described[section.StartLine]; !seen {
+            described[section.StartLine] = doc.describesColumns(section)
+        }
+        return described[section.StartLine]
+    }
+
+    for i, line := range doc.Lines {
+        if doc.Fenced[i] || i < doc.BodyStart-1 {
+            continue
+        }
+        lineNum := i + 1
+        for _, match := range dataFileLinkRegex.FindAllStringSubmatchIndex(line, -1) {
+            target := ""
+            for g := 2; g+1 < len(match); g += 2 {
+                if match[g] >= 0 {
+                    target = line[match[g]:match[g+1]]
+                }
+            }
+            if describes(lineNum) {
+                continue
+            }
+            column := match[0] // a Markdown link starts at its text
+            if start := strings.LastIndex(line[:column], "["); strings.HasPrefix(line[column:], "]") && start >= 0 {
+                column = start
+            }
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         lineNum,
+                Column:       column + 1,
+                Rule:         "data-file-missing-schema",
+                Message:      fmt.Sprintf("Data file %s is linked without a description of its columns", target),
+                Severity:     "warning",
+                Suggestion:   "Describe the file next to the link with a table or list of its columns and what each holds, such as '`order_id`: the order's unique ID'",
+                OriginalText: strings.TrimSpace(line),
+            })
+        }
+        for _, match := range vagueDataFileRegex.FindAllStringIndex(doc.Masked[i], -1) {
+            if describes(lineNum) {
+                continue
+            }
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         lineNum,
+                Column:       match[0] + 1,
+                Rule:         "data-file-vague-reference",
+                Message:      fmt.Sprintf("%q refers to a data file the text doesn't describe", doc.Masked[i][match[0]:match[1]]),
+                Severity:     "warning",
+                Suggestion:   "Name and link the file, and describe its columns in the text, since an assistant never sees the attachment",
+                OriginalText: strings.TrimSpace(line),
+            })
+        }
+    }
+    return issues
+}
+
+// describesColumns reports whether a section, with the subsections under
+// it, describes the columns of a data file: with a table of columns or
+// fields, a list of at least two names and their meanings, or a sentence
+// about its columns naming at least two in code
+func (d *Document) describesColumns(section Section) bool {
+    end := section.EndLine
+    for _, next := range d.Sections {
+        if next.Line > section.Line && next.Level > section.Level && section.Line > 0 {
+            end = max(end, next.EndLine)
+        } else if next.Line > section.Line && section.Line > 0 {
+            break
+        }
+    }
+
+    items := 0
+    for i := section.StartLine - 1; i < end && i < len(d.Lines); i++ {
+        if d.Fenced[i] {
+            continue
+        }
+        trimmed := strings.TrimSpace(d.Lines[i])
+        if columnTableHeaderRegex.MatchString(trimmed) && strings.Contains(trimmed, "|") && i+1 < len(d.Lines) && tableDividerRegex.MatchString(d.Lines[i+1]) {
+            return true
+        }
+        if _, _, _, text, isItem := listMarker(d.Lines[i]); isItem && columnItemRegex.MatchString(strings.TrimSpace(text)) {
+            if items++; items >= minDescribedColumns {
+                return true
+            }
+        }
+        if columnProseRegex.MatchString(plainText(trimmed)) && strings.Count(trimmed, "`") >= 2*minDescribedColumns {
+            return true
+        }
+    }
+    return false
+}
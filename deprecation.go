@@ -0,0 +1,169 @@
+// Deprecated and legacy content labeling
+
+package main
+
+import (
+    "fmt"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+var (
+    // deprecationRegex matches prose saying a feature is deprecated or gone
+    deprecationRegex = regexp.MustCompile(`(?i)\b(?:deprecated|deprecation|no longer (?:supported|available|maintained)|(?:was|were|has been|have been|will be|is being) removed|removed in|end[- ]of[- ]life|sunset(?:ted)?|discontinued|obsolete)\b`)
+    // deprecationVersionRegex matches the version or date a deprecation
+    // happens in: "3.2", "v4", "version 5", "2025-06-30"
+    deprecationVersionRegex = regexp.MustCompile(`(?i)\b(?:v?\d+\.\d+(?:\.\d+)?|v\d+|(?:version|release)\s+\d+|\d{4}-\d{2}(?:-\d{2})?)\b`)
+    // deprecationNoticeRegex matches a line set apart as a notice: a
+    // blockquote, or a label such as "**Deprecated:**" or "Note:"
+    deprecationNoticeRegex = regexp.MustCompile(`(?i)^\s*(?:>|(?:\*\*|__)?(?:deprecated|deprecation|removed|note|warning|important|caution)\b)`)
+    // legacyTitleRegex matches the title or file name of a legacy page
+    legacyTitleRegex = regexp.MustCompile(`(?i)(?:^|[^a-z])(?:legacy|deprecated|archived?|obsolete|end[- ]of[- ]life)(?:$|[^a-z])`)
+    // legacyStatusRegex matches a front matter status that labels a page
+    // legacy
+    legacyStatusRegex = regexp.MustCompile(`(?i)\b(?:deprecated|legacy|archived|obsolete|retired|sunset|eol|end[- ]of[- ]life)\b`)
+)
+
+// legacyFlagFields and legacyStatusFields are the front matter fields an
+// ingestion pipeline can down-rank a legacy page by: a true flag, or a
+// status naming it legacy
+var (
+    legacyFlagFields   = []string{"deprecated", "archived", "legacy", "obsolete"}
+    legacyStatusFields = []string{"status", "lifecycle", "state", "maturity", "stability"}
+)
+
+// analyzeDeprecation flags sections that describe deprecated or removed
+// features without a deprecation notice that says since when, and legacy
+// pages whose front matter doesn't label them so. An assistant that can't
+// tell a removed feature from a current one recommends it.
+func (a *Analyzer) analyzeDeprecation(doc *Document) []Issue {
+    var issues []Issue
+    legacy, legacyLine := false, 1
+    title, line := doc.Title()
+    if title == "" {
+        title, line = doc.H1()
+    }
+    if legacyTitleRegex.MatchString(plainText(title)) {
+        legacy, legacyLine = true, line
+    }
+    legacy = legacy || legacyTitleRegex.MatchString(strings.TrimSuffix(filepath.Base(doc.Path), filepath.Ext(doc.Path)))
+    if description, _, _ := doc.Description(); deprecationRegex.MatchString(description) {
+        legacy = true // the front matter describes the page as deprecated
+    }
+    leadStart, leadEnd := doc.leadBlock()
+
+    for _, section := range doc.Sections {
+        first, notice := 0, 0 // lines of the first mention and the first notice
+        versioned := false
+        start := section.StartLine
+        if section.Line > 0 {
+            start = section.Line
+        }
+        for lineNum := start; lineNum <= section.EndLine; lineNum++ {
+            line := blankMatches(inlineCodeRegex, doc.Masked[lineNum-1])
+            if doc.Fenced[lineNum-1] || !deprecationRegex.MatchString(line) {
+                continue
+            }
+            if first == 0 {
+                first = lineNum
+            }
+            isNotice := lineNum == section.Line || doc.InAdmonition(lineNum) || deprecationNoticeRegex.MatchString(line)
+            if notice == 0 && isNotice {
+                notice = lineNum
+            }
+            if isNotice && ((lineNum == section.Line && section.Level == 1) || (lineNum >= leadStart && lineNum <= leadEnd)) {
+                legacy = true // the title, a banner or the first paragraph deprecates the whole page
+            }
+            versioned = versioned || deprecationVersionRegex.MatchString(plainText(line))
+        }
+        if first == 0 {
+            continue
+        }
+
+        switch {
+        case versioned:
+        case notice == 0:
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         first,
+                Column:       1,
+                Rule:         "deprecation-without-notice",
+                Message:      "Section describes deprecated or removed functionality without a deprecation notice",
+                Severity:     "warning",
+                Suggestion:   "Open the section with a notice such as '> **Deprecated** in 4.2. Use the v2 API instead.', so every chunk of it says the feature is going away, since when, and what replaces it",
+                OriginalText: strings.TrimSpace(doc.Lines[first-1]),
+            })
+        default:
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         notice,
+                Column:       1,
+                Rule:         "deprecation-without-version",
+                Message:      "Deprecation notice doesn't say in which version or on which date",
+                Severity:     "suggestion",
+                Suggestion:   "Name the version or date the feature was deprecated or removed in, such as 'Deprecated in 4.2' or 'Removed on 2025-06-30'",
+                OriginalText: strings.TrimSpace(doc.Lines[notice-1]),
+            })
+        }
+    }
+
+    if legacy && doc.Format != "html" && !legacyLabeled(doc.FrontMatter) {
+        issues = append(issues, Issue{
+            File:       doc.Path,
+            Line:       legacyLine,
+            Column:     1,
+            Rule:       "legacy-page-unlabeled",
+            Message:    "Page covers legacy or deprecated content, but its front matter doesn't say so",
+            Severity:   "warning",
+            Suggestion: "Add 'deprecated: true' or 'status: deprecated' to the front matter, so ingestion pipelines can down-rank the page or leave it out",
+        })
+    }
+    return issues
+}
+
+// leadBlock returns the first and last lines of the block opening the
+// page, after its front matter and title: a banner, or the first
+// paragraph. A notice there is about the page, where one further down, even
+// in the title's section, is about what's around it. Both are 0 if the page has no such block.
+func (d *Document) leadBlock() (int, int) {
+    for i := d.BodyStart - 1; i < len(d.Lines); i++ {
+        trimmed := strings.TrimSpace(d.Lines[i])
+        if trimmed == "" || (!d.Fenced[i] && atxHeadingRegex.MatchString(trimmed) && !strings.HasPrefix(trimmed, "##")) {
+            continue
+        }
+        if d.Fenced[i] || atxHeadingRegex.MatchString(trimmed) {
+            return 0, 0
+        }
+        for _, adm := range d.Admonitions {
+            if i+1 >= adm.StartLine && i+1 <= adm.EndLine {
+                return adm.StartLine, adm.EndLine
+            }
+        }
+        last := i
+        for last+1 < len(d.Lines) && strings.TrimSpace(d.Lines[last+1]) != "" && !atxHeadingRegex.MatchString(strings.TrimSpace(d.Lines[last+1])) {
+            last++
+        }
+        return i + 1, last + 1
+    }
+    return 0, 0
+}
+
+// legacyLabeled reports whether front matter marks a page as legacy: a
+// true deprecated or archived flag, a legacy status, or noindex
+func legacyLabeled(fields map[string]interface{}) bool {
+    for _, field := range legacyFlagFields {
+        if value, ok := fields[field].(bool); ok && value {
+            return true
+        }
+    }
+    for _, field := range legacyStatusFields {
+        if value, ok := fields[field]; ok && legacyStatusRegex.MatchString(fmt.Sprint(value)) {
+            return true
+        }
+    }
+    if value, ok := fields["noindex"].(bool); ok && value {
+        return true
+    }
+    return false
+}
@@ -0,0 +1,123 @@
+// Meta description quality checks
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var (
+    metaTagRegex     = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+    metaNameRegex    = regexp.MustCompile(`(?i)\bname\s*=\s*["']description["']`)
+    metaContentRegex = regexp.MustCompile(`(?is)\bcontent\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+// defaultMinDescriptionLength is used when the config doesn't set MinDescriptionLength
+const defaultMinDescriptionLength = 50
+
+// maxSuggestedDescriptionLength caps descriptions proposed from the first paragraph
+const maxSuggestedDescriptionLength = 160
+
+// Description returns the page description from front matter or an HTML
+// meta tag, the line it's declared on, and whether the page can carry one
+// at all (plain Markdown without front matter has nowhere to put it)
+func (d *Document) Description() (string, int, bool) {
+    if d.Format == "html" {
+        for _, match := range metaTagRegex.FindAllStringIndex(d.Content, -1) {
+            tag := d.Content[match[0]:match[1]]
+            if !metaNameRegex.MatchString(tag) {
+                continue
+            }
+            line := strings.Count(d.Content[:match[0]], "\n") + 1
+            if content := metaContentRegex.FindStringSubmatch(tag); content != nil {
+                return strings.TrimSpace(content[1] + content[2]), line, true
+            }
+            return "", line, true
+        }
+        return "", 1, true
+    }
+
+    if d.FrontMatter == nil {
+        return "", 1, false
+    }
+    if value, ok := d.FrontMatter["description"]; ok && value != nil {
+        return strings.TrimSpace(fmt.Sprint(value)), d.frontMatterLine("description"), true
+    }
+    return "", 1, true
+}
+
+// analyzeDescription flags pages whose description is missing or too short
+// to be useful as retrieval metadata
+func (a *Analyzer) analyzeDescription(doc *Document) []Issue {
+    description, line, applicable := doc.Description()
+    if !applicable {
+        return nil
+    }
+
+    minLength := a.config.MinDescriptionLength
+    if minLength <= 0 {
+        minLength = defaultMinDescriptionLength
+    }
+
+    var message string
+    switch length := len([]rune(description)); {
+    case length == 0:
+        message = "Page has no meta description"
+    case length < minLength:
+        message = fmt.Sprintf("Meta description is too short (%d characters, minimum %d)", length, minLength)
+    default:
+        return nil
+    }
+
+    return []Issue{{
+        File:         doc.Path,
+        Line:         line,
+        Column:       1,
+        Rule:         "meta-description",
+        Message:      message,
+        Severity:     "warning",
+        Suggestion:   descriptionSuggestion(doc),
+        OriginalText: description,
+    }}
+}
+
+// descriptionSuggestion proposes a description drawn from the first paragraph
+func descriptionSuggestion(doc *Document) string {
+    paragraph, _ := doc.FirstParagraph()
+    if paragraph == "" {
+        return "Add a description summarizing what the page covers"
+    }
+
+    if runes := []rune(paragraph); len(runes) > maxSuggestedDescriptionLength {
+        cut := string(runes[:maxSuggestedDescriptionLength])
+        if i := strings.LastIndex(cut, " "); i > 0 {
+            cut = cut[:i]
+        }
+        paragraph = cut + "..."
+    }
+    return fmt.Sprintf("Consider a description based on the first paragraph: '%s'", paragraph)
+}
+
+// duplicateDescriptions reports descriptions shared by several pages, which
+// makes them useless for telling those pages apart
+func duplicateDescriptions(docs []*Document) []Issue {
+    var issues []Issue
+    for _, group := range groupDocuments(docs, func(doc *Document) string { description, _, _ := doc.Description(); return description }) {
+        for _, doc := range group {
+            description, line, _ := doc.Description()
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         line,
+                Column:       1,
+                Rule:         "duplicate-meta-description",
+                Message:      fmt.Sprintf("Meta description is shared with %d other page(s)", len(group)-1),
+                Severity:     "warning",
+                Suggestion:   descriptionSuggestion(doc),
+                OriginalText: description,
+            })
+        }
+    }
+    return issues
+}
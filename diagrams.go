@@ -0,0 +1,157 @@
+// Diagram-as-code detection and textual description
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var (
+    diagramEdgeRegex    = regexp.MustCompile(`([\w.]+)\s*(?:\[\[?([^\]]*)\]?\]|\(\(?([^)]*)\)?\)|\{([^}]*)\})?\s*(?:-->|->>|-->>|-\.->|==>|->|--|\.\.>)\s*(?:\|([^|]*)\|\s*)?([\w.]+)\s*(?:\[\[?([^\]]*)\]?\]|\(\(?([^)]*)\)?\)|\{([^}]*)\})?(?:\s*:\s*(.+))?`)
+    diagramLabelRegex   = regexp.MustCompile(`\[\s*label\s*=\s*"([^"]*)"`)
+    graphvizHeaderRegex = regexp.MustCompile(`^(?:strict\s+)?(?:di)?graph\b[^\n]*\{`)
+)
+
+// diagramLanguages maps fence info strings to the diagram language they denote
+var diagramLanguages = map[string]string{
+    "mermaid":  "Mermaid",
+    "plantuml": "PlantUML",
+    "puml":     "PlantUML",
+    "uml":      "PlantUML",
+    "dot":      "Graphviz",
+    "graphviz": "Graphviz",
+    "d2":       "D2",
+}
+
+// diagramLanguage returns the diagram language of a code block, recognizing
+// untyped blocks by their opening keyword
+func (d *Document) diagramLanguage(block CodeBlock) (string, bool) {
+    if lang, ok := diagramLanguages[block.Lang]; ok {
+        return lang, true
+    }
+    if block.Lang != "" {
+        return "", false
+    }
+
+    code := strings.TrimSpace(d.Code(block))
+    switch {
+    case strings.HasPrefix(code, "@startuml"):
+        return "PlantUML", true
+    case graphvizHeaderRegex.MatchString(code):
+        return "Graphviz", true
+    }
+    return "", false
+}
+
+// analyzeDiagrams flags diagram-as-code blocks with no prose summary right
+// before or after them, since text-only consumers can't interpret the markup
+func (a *Analyzer) analyzeDiagrams(doc *Document) []Issue {
+    var issues []Issue
+
+    for _, block := range doc.CodeBlocks {
+        lang, ok := doc.diagramLanguage(block)
+        if !ok || doc.hasAdjacentProse(block) {
+            continue
+        }
+
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         block.StartLine,
+            Column:       1,
+            Rule:         "diagram-without-summary",
+            Message:      fmt.Sprintf("%s diagram has no prose summary before or after it", lang),
+            Severity:     "warning",
+            Suggestion:   fmt.Sprintf("Describe what the diagram shows in a sentence next to it, e.g. '%s'", describeDiagram(lang, doc.Code(block))),
+            OriginalText: strings.TrimSpace(doc.Lines[block.StartLine-1]),
+        })
+    }
+
+    return issues
+}
+
+// hasAdjacentProse reports whether the nearest non-blank line before or after
+// the block is a prose line rather than a heading, image, or another block
+func (d *Document) hasAdjacentProse(block CodeBlock) bool {
+    isProse := func(i int) bool {
+        trimmed := strings.TrimSpace(d.Lines[i])
+        return !d.Fenced[i] && !atxHeadingRegex.MatchString(trimmed) &&
+            !strings.HasPrefix(trimmed, "![") && wordCount(plainText(trimmed)) >= 3
+    }
+
+    for i := block.StartLine - 2; i >= d.BodyStart-1; i-- {
+        if strings.TrimSpace(d.Lines[i]) != "" {
+            if isProse(i) {
+                return true
+            }
+            break
+        }
+    }
+    for i := block.EndLine; i < len(d.Lines); i++ {
+        if strings.TrimSpace(d.Lines[i]) != "" {
+            return isProse(i)
+        }
+    }
+    return false
+}
+
+// describeDiagram renders the edges of a diagram as a plain-text sentence,
+// using node labels where the source declares them
+func describeDiagram(lang, code string) string {
+    type edge struct{ from, to, label string }
+    labels := make(map[string]string)
+    var edges []edge
+
+    for _, line := range strings.Split(code, "\n") {
+        line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), ";"))
+        match := diagramEdgeRegex.FindStringSubmatch(line)
+        if match == nil {
+            continue
+        }
+
+        if label := firstNonEmpty(match[2], match[3], match[4]); label != "" {
+            labels[match[1]] = label
+        }
+        if label := firstNonEmpty(match[7], match[8], match[9]); label != "" {
+            labels[match[6]] = label
+        }
+
+        e := edge{from: match[1], to: match[6], label: firstNonEmpty(match[5], match[10])}
+        if m := diagramLabelRegex.FindStringSubmatch(line); m != nil {
+            e.label = m[1]
+        }
+        edges = append(edges, e)
+    }
+
+    if len(edges) == 0 {
+        return fmt.Sprintf("%s diagram; no relationships could be extracted.", lang)
+    }
+
+    name := func(id string) string {
+        if label, ok := labels[id]; ok {
+            return label
+        }
+        return id
+    }
+
+    var parts []string
+    for _, e := range edges {
+        part := name(e.from) + " -> " + name(e.to)
+        if e.label != "" {
+            part += " (" + e.label + ")"
+        }
+        parts = append(parts, part)
+    }
+    return fmt.Sprintf("%s diagram: %s.", lang, strings.Join(parts, "; "))
+}
+
+// firstNonEmpty returns the first of values that isn't blank
+func firstNonEmpty(values ...string) string {
+    for _, value := range values {
+        if strings.TrimSpace(value) != "" {
+            return strings.TrimSpace(value)
+        }
+    }
+    return ""
+}
@@ -0,0 +1,227 @@
+// Versioned docs comparison: align two doc sets and report section changes
+
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+// regressionThreshold is the minimum score drop reported as a regression,
+// so rounding noise from small edits isn't flagged
+const regressionThreshold = 1.0
+
+// SectionChange describes how one section differs between two doc versions
+type SectionChange struct {
+    File      string  `json:"file"`
+    Section   string  `json:"section"`
+    Change    string  `json:"change"` // "added", "removed", "changed"
+    OldScore  float64 `json:"old_score,omitempty"`
+    NewScore  float64 `json:"new_score,omitempty"`
+    Regressed bool    `json:"regressed,omitempty"`
+}
+
+// scoredSection is a section's text and readiness score within one version
+type scoredSection struct {
+    body  string
+    score float64
+}
+
+// runDiffVersions implements the diff-versions subcommand
+func runDiffVersions(args []string) int {
+    flags := flag.NewFlagSet("diff-versions", flag.ExitOnError)
+    configPath := flags.String("config", "", "Path to configuration file")
+    outputFormat := flags.String("output", "standard", "Output format (standard, json)")
+    flags.Parse(args)
+
+    if flags.NArg() != 2 {
+        fmt.Fprintf(os.Stderr, "Usage: %s diff-versions [options] <old_dir> <new_dir>\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+
+    analyzer, err := NewAnalyzer(*configPath)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error creating analyzer: %v\n", err)
+        return 1
+    }
+
+    changes, err := diffVersions(analyzer, flags.Arg(0), flags.Arg(1))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error comparing versions: %v\n", err)
+        return 1
+    }
+
+    switch *outputFormat {
+    case "json":
+        encoder := json.NewEncoder(os.Stdout)
+        encoder.SetEscapeHTML(false)
+        encoder.SetIndent("", "  ")
+        if err := encoder.Encode(changes); err != nil {
+            fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+            return 1
+        }
+    default:
+        printSectionChanges(changes)
+    }
+
+    for _, change := range changes {
+        if change.Regressed {
+            return 1
+        }
+    }
+    return 0
+}
+
+// diffVersions aligns files by their path relative to each root and compares
+// their sections by heading path
+func diffVersions(analyzer *Analyzer, oldRoot, newRoot string) ([]SectionChange, error) {
+    oldSections, err := scoreTree(analyzer, oldRoot)
+    if err != nil {
+        return nil, err
+    }
+    newSections, err := scoreTree(analyzer, newRoot)
+    if err != nil {
+        return nil, err
+    }
+
+    files := make(map[string]bool)
+    for file := range oldSections {
+        files[file] = true
+    }
+    for file := range newSections {
+        files[file] = true
+    }
+
+    var names []string
+    for file := range files {
+        names = append(names, file)
+    }
+    sort.Strings(names)
+
+    var changes []SectionChange
+    for _, file := range names {
+        oldFile, newFile := oldSections[file], newSections[file]
+
+        var keys []string
+        seen := make(map[string]bool)
+        for _, sections := range []map[string]scoredSection{oldFile, newFile} {
+            for key := range sections {
+                if !seen[key] {
+                    seen[key] = true
+                    keys = append(keys, key)
+                }
+            }
+        }
+        sort.Strings(keys)
+
+        for _, key := range keys {
+            before, inOld := oldFile[key]
+            after, inNew := newFile[key]
+
+            switch {
+            case !inNew:
+                changes = append(changes, SectionChange{File: file, Section: key, Change: "removed", OldScore: before.score})
+            case !inOld:
+                changes = append(changes, SectionChange{File: file, Section: key, Change: "added", NewScore: after.score})
+            case before.body != after.body:
+                changes = append(changes, SectionChange{
+                    File:      file,
+                    Section:   key,
+                    Change:    "changed",
+                    OldScore:  before.score,
+                    NewScore:  after.score,
+                    Regressed: before.score-after.score >= regressionThreshold,
+                })
+            }
+        }
+    }
+
+    return changes, nil
+}
+
+// scoreTree analyzes every supported file under root and returns its sections
+// keyed by relative file path and then by heading path
+func scoreTree(analyzer *Analyzer, root string) (map[string]map[string]scoredSection, error) {
+    files, err := collectFiles(root, WalkOptions{Recursive: true, Formats: analyzer.config.Formats})
+    if err != nil {
+        return nil, err
+    }
+
+    tree := make(map[string]map[string]scoredSection)
+    for _, file := range files {
+        content, err := readDocument(file)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Warning: failed to read %s: %v\n", file, err)
+            continue
+        }
+
+        rel, err := filepath.Rel(root, file)
+        if err != nil {
+            rel = file
+        }
+        tree[filepath.ToSlash(rel)] = scoreSections(analyzer, file, content)
+    }
+
+    return tree, nil
+}
+
+// scoreSections scores each section of a file; repeated heading paths get an
+// ordinal suffix so they still align one-to-one between versions
+func scoreSections(analyzer *Analyzer, file, content string) map[string]scoredSection {
+    doc := ParseDocument(file, content)
+    issues := analyzer.analyzeContent(file, content)
+
+    bySection := make(map[int][]Issue)
+    for _, issue := range issues {
+        if section, ok := doc.SectionAt(issue.Line); ok {
+            bySection[section.StartLine] = append(bySection[section.StartLine], issue)
+        }
+    }
+
+    sections := make(map[string]scoredSection)
+    for _, section := range doc.Sections {
+        key := section.Breadcrumb()
+        if key == "" {
+            key = "(preamble)"
+        }
+        base := key
+        for n := 2; ; n++ {
+            if _, taken := sections[key]; !taken {
+                break
+            }
+            key = fmt.Sprintf("%s #%d", base, n)
+        }
+
+        body := doc.Body(section)
+        sections[key] = scoredSection{
+            body:  strings.TrimSpace(body),
+            score: readinessScore(bySection[section.StartLine], wordCount(body), analyzer.severities),
+        }
+    }
+
+    return sections
+}
+
+func printSectionChanges(changes []SectionChange) {
+    for _, change := range changes {
+        switch change.Change {
+        case "removed":
+            fmt.Printf("REMOVED  %s: %s\n", change.File, change.Section)
+        case "added":
+            fmt.Printf("ADDED    %s: %s (score %.1f)\n", change.File, change.Section, change.NewScore)
+        default:
+            note := ""
+            if change.Regressed {
+                note = ", regressed"
+            }
+            fmt.Printf("CHANGED  %s: %s (score %.1f -> %.1f%s)\n",
+                change.File, change.Section, change.OldScore, change.NewScore, note)
+        }
+    }
+}
@@ -0,0 +1,47 @@
+// Links from each issue to the page explaining its rule
+
+package main
+
+import (
+    "fmt"
+    "net/url"
+    "strings"
+)
+
+// ruleDocsPlaceholder marks where DocsBaseURL takes the rule name, for
+// style guides whose pages aren't one path segment per rule
+const ruleDocsPlaceholder = "{rule}"
+
+// validateDocsBaseURL checks that DocsBaseURL is an absolute web address
+func (c *Config) validateDocsBaseURL() error {
+    if c.DocsBaseURL == "" {
+        return nil
+    }
+    parsed, err := url.Parse(strings.ReplaceAll(c.DocsBaseURL, ruleDocsPlaceholder, "rule"))
+    if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+        return fmt.Errorf("invalid DocsBaseURL %q (want an http or https URL)", c.DocsBaseURL)
+    }
+    return nil
+}
+
+// ruleDocsURL returns the address of the page explaining a rule: the
+// DocsBaseURL with the rule name in place of {rule}, or appended as a path
+// segment without one. It's empty when DocsBaseURL is unset.
+func (c *Config) ruleDocsURL(rule string) string {
+    if c.DocsBaseURL == "" || rule == "" {
+        return ""
+    }
+    name := url.PathEscape(rule)
+    if strings.Contains(c.DocsBaseURL, ruleDocsPlaceholder) {
+        return strings.ReplaceAll(c.DocsBaseURL, ruleDocsPlaceholder, name)
+    }
+    return strings.TrimSuffix(c.DocsBaseURL, "/") + "/" + name
+}
+
+// linkRuleDocs sets the DocsURL of each issue, so every output format can
+// link the rule's explanation
+func (c *Config) linkRuleDocs(issues []Issue) {
+    for i := range issues {
+        issues[i].DocsURL = c.ruleDocsURL(issues[i].Rule)
+    }
+}
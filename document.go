@@ -0,0 +1,289 @@
+// Document model shared by structural checks and the export pipeline
+
+package main
+
+import (
+    "path/filepath"
+    "regexp"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+var (
+    atxHeadingRegex = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+    codeFenceRegex  = regexp.MustCompile("^\\s*(```|~~~)")
+    paragraphRegex  = regexp.MustCompile(`(?is)<p\b[^>]*>(.*?)</p>`)
+    htmlTagRegex    = regexp.MustCompile(`<[^>]+>`)
+    inlineLinkRegex = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+    emphasisRegex   = regexp.MustCompile("[*_`]+")
+)
+
+// Document is a parsed, line-oriented view of a documentation file
+type Document struct {
+    Path           string
+    Content        string
+    Format         string // "markdown", "html" or "rst", see detectFormat
+    Lines          []string
+    Masked         []string // Lines with markup syntax blanked out, column-aligned with Lines
+    Fenced         []bool   // whether each line is part of a fenced code block
+    CodeBlocks     []CodeBlock
+    FrontMatter    map[string]interface{}
+    FrontMatterErr error // why a front matter block failed to decode, if it did
+    BodyStart      int   // 1-based first line after the front matter block
+    Sections       []Section
+    Admonitions    []Admonition
+    Tabs           []Tab
+    Captions       []captionCue // the timed cues of a caption file, see captionDocument
+
+    product  string                     // product name for rule templates, see documentProduct
+    expanded map[int][]substitutionSpan // 0-based line -> variable references expanded in it, see substituteVariables
+    lists    []List                     // the Lists inList looks lines up in, once listed is set
+    listed   bool
+}
+
+// Section is a heading together with the body lines that follow it,
+// up to the next heading of any level
+type Section struct {
+    Heading   string
+    Level     int
+    Line      int      // 1-based heading line, 0 for content before the first heading
+    Column    int      // 1-based byte column of the heading text on its line
+    Path      []string // heading texts from the top-level ancestor down to this heading
+    StartLine int      // 1-based first body line
+    EndLine   int      // 1-based last body line (inclusive)
+}
+
+// ParseDocument splits content into lines and sections
+func ParseDocument(path, content string) *Document {
+    doc := &Document{
+        Path:    path,
+        Content: content,
+        Format:  detectFormat(path, content),
+        Lines:   strings.Split(content, "\n"),
+    }
+    doc.Masked = append([]string(nil), doc.Lines...)
+    doc.FrontMatter, doc.BodyStart, doc.FrontMatterErr = parseFrontMatter(doc.Lines)
+    doc.Fenced, doc.CodeBlocks = parseFences(doc.Lines)
+    doc.Sections = parseSections(doc.Lines, doc.Fenced, doc.BodyStart)
+    doc.Admonitions = parseAdmonitions(doc)
+    doc.Tabs = parseTabs(doc)
+    return doc
+}
+
+// parseFrontMatter decodes a leading YAML block delimited by "---" lines and
+// returns it with the first line after it. Documents without front matter,
+// or with front matter that fails to decode, yield a nil map, the latter
+// with the decoding error.
+func parseFrontMatter(lines []string) (map[string]interface{}, int, error) {
+    if len(lines) == 0 || strings.TrimSpace(strings.TrimPrefix(lines[0], "\ufeff")) != "---" {
+        return nil, 1, nil
+    }
+
+    for i := 1; i < len(lines); i++ {
+        if trimmed := strings.TrimSpace(lines[i]); trimmed == "---" || trimmed == "..." {
+            var fields map[string]interface{}
+            if err := yaml.Unmarshal([]byte(strings.Join(lines[1:i], "\n")), &fields); err != nil {
+                return nil, i + 2, err
+            }
+            if fields == nil {
+                fields = make(map[string]interface{})
+            }
+            return fields, i + 2, nil
+        }
+    }
+
+    return nil, 1, nil
+}
+
+// frontMatterLine returns the 1-based line declaring key in the front matter,
+// or 1 when the key is absent
+func (d *Document) frontMatterLine(key string) int {
+    pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(key) + `\s*:`)
+    for i := 1; i < d.BodyStart-1 && i < len(d.Lines); i++ {
+        if pattern.MatchString(d.Lines[i]) {
+            return i + 1
+        }
+    }
+    return 1
+}
+
+// CodeBlock is a fenced code block with its info-string language
+type CodeBlock struct {
+    Lang      string
+    StartLine int // 1-based line of the opening fence
+    EndLine   int // 1-based line of the closing fence, or the last line if unclosed
+    Closed    bool
+}
+
+// parseFences marks the lines belonging to fenced code blocks, fences
+// included, and returns the blocks found
+func parseFences(lines []string) ([]bool, []CodeBlock) {
+    fenced := make([]bool, len(lines))
+    var blocks []CodeBlock
+    var open *CodeBlock
+
+    for i, line := range lines {
+        if codeFenceRegex.MatchString(line) {
+            fenced[i] = true
+            if open == nil {
+                info := strings.TrimLeft(strings.TrimSpace(line), "`~")
+                lang := strings.ToLower(strings.Trim(strings.SplitN(strings.TrimSpace(info)+" ", " ", 2)[0], "{}."))
+                open = &CodeBlock{Lang: lang, StartLine: i + 1}
+            } else {
+                open.EndLine = i + 1
+                open.Closed = true
+                blocks = append(blocks, *open)
+                open = nil
+            }
+            continue
+        }
+        fenced[i] = open != nil
+    }
+
+    if open != nil {
+        open.EndLine = len(lines)
+        blocks = append(blocks, *open)
+    }
+
+    return fenced, blocks
+}
+
+// Code returns the block's content, excluding the fences
+func (d *Document) Code(block CodeBlock) string {
+    end := block.EndLine
+    if block.Closed {
+        end--
+    }
+    if block.StartLine >= end {
+        return ""
+    }
+    return strings.Join(d.Lines[block.StartLine:end], "\n")
+}
+
+// mask blanks out the given byte range of a masked line so rules no longer
+// match it while columns of the remaining text stay unchanged
+func (d *Document) mask(line, start, end int) {
+    masked := []byte(d.Masked[line])
+    for i := start; i < end && i < len(masked); i++ {
+        masked[i] = ' '
+    }
+    d.Masked[line] = string(masked)
+}
+
+// parseSections groups lines from bodyStart on under their nearest preceding
+// ATX heading, ignoring heading-like lines inside code fences
+func parseSections(lines []string, fenced []bool, bodyStart int) []Section {
+    var sections []Section
+    var stack []Section
+
+    current := Section{StartLine: bodyStart}
+
+    for i, line := range lines {
+        if i < bodyStart-1 || fenced[i] {
+            continue
+        }
+
+        loc := atxHeadingRegex.FindStringSubmatchIndex(line)
+        if loc == nil {
+            continue
+        }
+        heading := line[loc[4]:loc[5]]
+
+        current.EndLine = i
+        sections = append(sections, current)
+
+        level := loc[3] - loc[2]
+        for len(stack) > 0 && stack[len(stack)-1].Level >= level {
+            stack = stack[:len(stack)-1]
+        }
+
+        var path []string
+        for _, parent := range stack {
+            path = append(path, parent.Heading)
+        }
+        path = append(path, heading)
+
+        current = Section{
+            Heading:   heading,
+            Level:     level,
+            Line:      i + 1,
+            Column:    loc[4] + 1,
+            Path:      path,
+            StartLine: i + 2,
+        }
+        stack = append(stack, current)
+    }
+
+    current.EndLine = len(lines)
+    sections = append(sections, current)
+
+    // Drop an empty preamble so documents starting with a heading
+    // don't produce a phantom section
+    if len(sections) > 0 && sections[0].Line == 0 && strings.TrimSpace(strings.Join(lines[bodyStart-1:sections[0].EndLine], "")) == "" {
+        sections = sections[1:]
+    }
+
+    return sections
+}
+
+// FirstParagraph returns the first prose paragraph of the document as plain
+// text, skipping headings, code blocks and HTML-only lines
+func (d *Document) FirstParagraph() (string, int) {
+    if match := paragraphRegex.FindStringSubmatchIndex(d.Content); match != nil && d.Format == "html" {
+        text := plainText(d.Content[match[2]:match[3]])
+        return text, strings.Count(d.Content[:match[0]], "\n") + 1
+    }
+
+    return d.paragraphIn(d.BodyStart, len(d.Lines))
+}
+
+// paragraphIn returns the first prose paragraph between the 1-based lines
+// first and last (inclusive) as plain text, with its starting line
+func (d *Document) paragraphIn(first, last int) (string, int) {
+    var paragraph []string
+    start := 0
+    for i := first - 1; i < last && i < len(d.Lines); i++ {
+        trimmed := strings.TrimSpace(d.Lines[i])
+        prose := trimmed != "" && !d.Fenced[i] && !atxHeadingRegex.MatchString(trimmed) &&
+            !strings.HasPrefix(trimmed, "<") && !d.InAdmonition(i+1)
+
+        if prose {
+            if len(paragraph) == 0 {
+                start = i + 1
+            }
+            paragraph = append(paragraph, trimmed)
+        } else if len(paragraph) > 0 {
+            break
+        }
+    }
+
+    return plainText(strings.Join(paragraph, " ")), start
+}
+
+// plainText strips Markdown and HTML markup from a fragment of prose
+func plainText(text string) string {
+    text = htmlTagRegex.ReplaceAllString(text, "")
+    text = inlineLinkRegex.ReplaceAllString(text, "$1")
+    text = emphasisRegex.ReplaceAllString(text, "")
+    return strings.Join(strings.Fields(text), " ")
+}
+
+// isHTML reports whether path names an HTML file
+func isHTML(path string) bool {
+    ext := strings.ToLower(filepath.Ext(path))
+    return ext == ".html" || ext == ".htm"
+}
+
+// Body returns the section's body text, excluding the heading line
+func (d *Document) Body(s Section) string {
+    if s.StartLine > s.EndLine || s.StartLine < 1 {
+        return ""
+    }
+    return strings.Join(d.Lines[s.StartLine-1:s.EndLine], "\n")
+}
+
+// Breadcrumb returns the section's heading path joined for display
+func (s Section) Breadcrumb() string {
+    return strings.Join(s.Path, " > ")
+}
@@ -0,0 +1,283 @@
+// Email templates: MJML and HTML newsletters and announcements
+
+package main
+
+import (
+    "fmt"
+    "html"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+var (
+    // emailMarkerRegex matches markup only email HTML uses: Outlook
+    // conditional comments, Apple Mail's data detectors and an MJML root
+    emailMarkerRegex = regexp.MustCompile(`(?i)<!--\[if (?:[a-z]+ )?mso|\bx-apple-data-detectors\b|<mjml\b`)
+    // emailLayoutRegex matches a layout table, which emails are built of
+    // but documentation HTML uses too
+    emailLayoutRegex = regexp.MustCompile(`(?i)<table\b[^>]*\brole\s*=\s*["']?presentation`)
+    // emailDoctypeRegex matches the doctype that starts a whole HTML
+    // document, as an email is, rather than a fragment of a page
+    emailDoctypeRegex = regexp.MustCompile(`(?i)^\s*<!doctype\b`)
+    // emailDroppedRegex matches the doctype and the MJML head, whose
+    // title, preview text and styles aren't the email's body
+    emailDroppedRegex = regexp.MustCompile(`(?is)<!doctype\b[^>]*>|<mj-head\b.*?</mj-head\s*>`)
+    // emailEntityRegex matches a character reference
+    emailEntityRegex = regexp.MustCompile(`^&(?:#[0-9]+|#[xX][0-9a-fA-F]+|[a-zA-Z][a-zA-Z0-9]*);`)
+    // genericCTARegex matches call-to-action text that only says what to
+    // do in front of the button or banner it's on
+    genericCTARegex = regexp.MustCompile(`(?i)^(?:(?:click|tap|press)(?: here)?|here|this|now|more|go|link|button|details|info|(?:learn|read|see|find out|discover) more|more info(?:rmation)?|view (?:more|details)|(?:shop|buy|order|join|start|register|download|sign up)(?: now| today)?|get started|try (?:it )?(?:now|today|free)|continue|explore|check it out|let'?s go|[>»→]+)[\s.!…>»→]*$`)
+    // emailContextRegex matches phrasing that depends on the email's layout
+    // or the day it was sent, which an archived copy has neither of
+    emailContextRegex = regexp.MustCompile(`(?i)\b(?:(?:click|tap|press|use) (?:on )?the (?:button|image|banner|link|picture)|(?:the |this )?(?:button|image|banner|picture|graphic|link)s? (?:below|above|on the (?:left|right))|(?:see|shown|pictured) (?:below|above|on the (?:left|right))|in this (?:e-?mail|message|newsletter|issue)|(?:this|last|next) (?:week|month|quarter)(?:'s)?|today|tonight|tomorrow|yesterday|(?:ends|expires|closes) (?:soon|tonight|tomorrow|on \w+day)|(?:for a )?limited time(?: only)?)\b`)
+)
+
+// emailInlineTags run inside a block of email text; every other tag ends
+// the block
+var emailInlineTags = map[string]bool{
+    "a": true, "span": true, "strong": true, "b": true, "em": true, "i": true, "u": true, "s": true,
+    "code": true, "small": true, "big": true, "sup": true, "sub": true, "font": true, "mark": true,
+    "abbr": true, "time": true, "img": true, "wbr": true,
+}
+
+// emailCTATags are the MJML elements that are links
+var emailCTATags = map[string]bool{"mj-button": true, "mj-navbar-link": true, "mj-social-element": true}
+
+// emailCTA is a link or button of an email: where it starts, its text and
+// whether it's an image
+type emailCTA struct {
+    line, column int // where its text, or without text, its tag, starts
+    tag          string
+    text         []byte
+    alt          string
+    image        bool
+}
+
+// isEmailTemplate reports whether a file is an email template: an MJML
+// file, or HTML with markup only emails use. Layout tables alone don't
+// make one, as docs use them too, but a whole document built of them does.
+func isEmailTemplate(path, content string) bool {
+    if strings.ToLower(filepath.Ext(path)) == ".mjml" {
+        return true
+    }
+    if detectFormat(path, content) != "html" {
+        return false
+    }
+    return emailMarkerRegex.MatchString(content) || emailDoctypeRegex.MatchString(content) && emailLayoutRegex.MatchString(content)
+}
+
+// analyzeEmail runs the line, sentence and multiline rules over each block
+// of an email's text, reporting issues at their position in the template,
+// and flags calls to action and phrasing that only work in the rendered
+// email. Announcements are archived into the knowledge base as text, where
+// "Click the button below" has no button and "Learn more" no context.
+func (a *Analyzer) analyzeEmail(filePath, content string) []Issue {
+    template := ParseDocument(filePath, content)
+    texts, ctas := emailText(content)
+    issues := a.analyzeEmbedded(filePath, texts)
+
+    original := func(line int) string {
+        return strings.TrimSpace(template.Lines[line-1])
+    }
+    for _, text := range texts {
+        for k, line := range text.lines {
+            for _, match := range emailContextRegex.FindAllStringIndex(line, -1) {
+                fileLine, column := text.position(k, match[0])
+                issues = append(issues, Issue{
+                    File:         filePath,
+                    Line:         fileLine,
+                    Column:       column + 1,
+                    Rule:         "email-context-dependent",
+                    Message:      fmt.Sprintf("%q depends on the email's layout or send date", line[match[0]:match[1]]),
+                    Severity:     "suggestion",
+                    Suggestion:   "Say what the reader should do or when, with the name of the page or an absolute date, so the text still works once the email is archived",
+                    OriginalText: original(fileLine),
+                })
+            }
+        }
+    }
+
+    for _, cta := range ctas {
+        label := strings.Join(strings.Fields(string(cta.text)), " ")
+        if label == "" {
+            label = strings.TrimSpace(cta.alt)
+        }
+        message := ""
+        switch {
+        case label == "" && cta.image:
+            message = "Call to action is an image without alt text, so nothing of it survives as text"
+        case label == "":
+            message = "Call to action has no text"
+        case genericCTARegex.MatchString(label):
+            message = fmt.Sprintf("Call to action %q doesn't say what it does without the email around it", label)
+        default:
+            continue
+        }
+        issues = append(issues, Issue{
+            File:         filePath,
+            Line:         cta.line,
+            Column:       cta.column + 1,
+            Rule:         "email-visual-cta",
+            Message:      message,
+            Severity:     "warning",
+            Suggestion:   "Name the action and what it leads to, such as \"Read the 4.2 release notes\" rather than \"Learn more\"",
+            OriginalText: original(cta.line),
+        })
+    }
+
+    issues = a.filterPageTypes(issues, "conceptual")
+    return a.applyOverrides(a.convertColumns(template, issues))
+}
+
+// emailText extracts the text of an email template a block at a time, with
+// every byte of each line mapped to its column in the file, and its calls
+// to action: the links, buttons and linked images. Character references
+// are decoded and inline markup is dropped, so "Save&nbsp;<b>20%</b>"
+// reads "Save 20%".
+func emailText(content string) ([]embeddedText, []emailCTA) {
+    // Dropped markup is blanked, keeping line breaks, so offsets still
+    // match the file
+    visible := []byte(content)
+    for _, drop := range []*regexp.Regexp{htmlDroppedRegex, emailDroppedRegex} {
+        for _, match := range drop.FindAllIndex(visible, -1) {
+            for i := match[0]; i < match[1]; i++ {
+                if visible[i] != '\n' {
+                    visible[i] = ' '
+                }
+            }
+        }
+    }
+    lineStarts := []int{0}
+    for i, c := range visible {
+        if c == '\n' {
+            lineStarts = append(lineStarts, i+1)
+        }
+    }
+    lineColumn := func(offset int) (int, int) {
+        line := sort.Search(len(lineStarts), func(i int) bool { return lineStarts[i] > offset })
+        return line, offset - lineStarts[line-1]
+    }
+
+    var texts []embeddedText
+    var ctas, open []emailCTA
+    var block embeddedText
+    flush := func() {
+        for k := range block.lines {
+            block.lines[k] = strings.TrimRight(block.lines[k], " ")
+            block.offsets[k] = block.offsets[k][:len(block.lines[k])]
+        }
+        if block = block.trimmed(); len(block.lines) > 0 {
+            texts = append(texts, block)
+        }
+        block = embeddedText{}
+    }
+    // emit appends text that starts at offset in the file to the block,
+    // starting a line for each line of the file and collapsing whitespace
+    emit := func(s string, offset int) {
+        line, column := lineColumn(offset)
+        if len(block.lines) == 0 || block.positions[len(block.lines)-1][0] != line {
+            block.lines = append(block.lines, "")
+            block.positions = append(block.positions, [2]int{line, column})
+            block.offsets = append(block.offsets, nil)
+        }
+        k := len(block.lines) - 1
+        if s == " " && (block.lines[k] == "" || strings.HasSuffix(block.lines[k], " ")) {
+            return
+        }
+        block.lines[k] += s
+        for i := 0; i < len(s); i++ {
+            block.offsets[k] = append(block.offsets[k], column)
+        }
+        for i := range open {
+            if s != " " && len(open[i].text) == 0 {
+                open[i].line, open[i].column = line, column
+            }
+            if s != " " || len(open[i].text) > 0 {
+                open[i].text = append(open[i].text, s...)
+            }
+        }
+    }
+    text := func(start, end int) {
+        for i := start; i < end; {
+            switch c := visible[i]; {
+            case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+                emit(" ", i)
+                i++
+            case c == '&' && emailEntityRegex.Match(visible[i:end]):
+                entity := emailEntityRegex.Find(visible[i:end])
+                decoded := html.UnescapeString(string(entity))
+                if decoded == "\u00a0" {
+                    decoded = " "
+                }
+                emit(decoded, i)
+                i += len(entity)
+            default:
+                emit(string(visible[i:i+1]), i)
+                i++
+            }
+        }
+    }
+
+    position := 0
+    for _, loc := range htmlTokenRegex.FindAllSubmatchIndex(visible, -1) {
+        text(position, loc[0])
+        position = loc[1]
+        closing := loc[3] > loc[2]
+        tag := strings.ToLower(string(visible[loc[4]:loc[5]]))
+        attrs := htmlAttributes(string(visible[loc[6]:loc[7]]))
+        if !emailInlineTags[tag] {
+            flush()
+        }
+
+        line, column := lineColumn(loc[0])
+        switch {
+        case !closing && ((tag == "a" && attrs["href"] != "") || emailCTATags[tag]):
+            open = append(open, emailCTA{line: line, column: column, tag: tag})
+        case closing && len(open) > 0 && open[len(open)-1].tag == tag:
+            ctas = append(ctas, open[len(open)-1])
+            open = open[:len(open)-1]
+        case !closing && (tag == "img" || tag == "mj-image"):
+            for i := range open {
+                open[i].image, open[i].alt = true, open[i].alt+attrs["alt"]
+            }
+            if tag == "mj-image" && attrs["href"] != "" {
+                ctas = append(ctas, emailCTA{line: line, column: column, tag: tag, alt: attrs["alt"], image: true})
+            }
+        }
+    }
+    text(position, len(visible))
+    flush()
+    return texts, ctas
+}
+
+// emailMarkdown renders an email template as the Markdown its text is
+// exported and compared with other pages as. MJML elements become the HTML
+// they stand for, and layout tables become plain blocks rather than
+// Markdown tables.
+func emailMarkdown(content string) string {
+    content = emailDroppedRegex.ReplaceAllString(content, "")
+    content = htmlTokenRegex.ReplaceAllStringFunc(content, func(tag string) string {
+        match := htmlTokenRegex.FindStringSubmatch(tag)
+        closing, name, attrs := match[1] == "/", strings.ToLower(match[2]), htmlAttributes(match[3])
+        switch {
+        case emailCTATags[name] && closing:
+            return "</a></p>"
+        case emailCTATags[name]:
+            return `<p><a href="` + html.EscapeString(attrs["href"]) + `">`
+        case name == "mj-image" && !closing:
+            image := `<img src="` + html.EscapeString(attrs["src"]) + `" alt="` + html.EscapeString(attrs["alt"]) + `">`
+            if attrs["href"] != "" {
+                image = `<a href="` + html.EscapeString(attrs["href"]) + `">` + image + "</a>"
+            }
+            return "<p>" + image + "</p>"
+        case name == "mj-table":
+            return "<" + match[1] + "table>"
+        case strings.HasPrefix(name, "mj") || name == "table" || name == "tbody" || name == "thead" || name == "tfoot" || name == "tr" || name == "td" || name == "th" || name == "center":
+            return "<" + match[1] + "div>"
+        }
+        return tag
+    })
+    return htmlToMarkdown(content)
+}
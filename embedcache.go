@@ -0,0 +1,98 @@
+// On-disk cache of embedding vectors
+
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+)
+
+// embeddingCache stores vectors on disk by the hash of their text, under a
+// directory per endpoint and model, so later runs embed only new or
+// changed content
+type embeddingCache struct {
+    dir string
+}
+
+// newEmbeddingCache returns the cache for an endpoint and model: in the
+// configured directory, or embeddings in the cache directory by default.
+// A Cache setting of "off" disables it, and so does a cache directory that
+// can't be determined, with a warning.
+func newEmbeddingCache(config EmbeddingsConfig, url, model string) *embeddingCache {
+    root := config.Cache
+    switch root {
+    case "off":
+        return nil
+    case "":
+        dir, err := cacheDir()
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Warning: embeddings are not cached: %v\n", err)
+            return nil
+        }
+        root = filepath.Join(dir, "embeddings")
+    }
+    sum := sha256.Sum256([]byte(url + "\x00" + model))
+    return &embeddingCache{dir: filepath.Join(root, hex.EncodeToString(sum[:8]))}
+}
+
+// path returns the file holding a text's vector
+func (c *embeddingCache) path(text string) string {
+    sum := sha256.Sum256([]byte(text))
+    key := hex.EncodeToString(sum[:])
+    return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// get returns the cached vector of a text, if there is one
+func (c *embeddingCache) get(text string) ([]float64, bool) {
+    if c == nil {
+        return nil, false
+    }
+    data, err := os.ReadFile(c.path(text))
+    if err != nil {
+        return nil, false
+    }
+    var vector []float64
+    if err := json.Unmarshal(data, &vector); err != nil || len(vector) == 0 {
+        return nil, false
+    }
+    return vector, true
+}
+
+// has reports whether a text's vector is cached
+func (c *embeddingCache) has(text string) bool {
+    if c == nil {
+        return false
+    }
+    _, err := os.Stat(c.path(text))
+    return err == nil
+}
+
+// put stores a text's vector. The file is written under a temporary name
+// and renamed, so concurrent runs never read a partial vector; failures
+// only cost a later run a request, and are ignored.
+func (c *embeddingCache) put(text string, vector []float64) {
+    if c == nil {
+        return
+    }
+    file := c.path(text)
+    if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+        return
+    }
+    data, err := json.Marshal(vector)
+    if err != nil {
+        return
+    }
+    temp, err := os.CreateTemp(filepath.Dir(file), ".vector-")
+    if err != nil {
+        return
+    }
+    defer os.Remove(temp.Name())
+    _, err = temp.Write(data)
+    if closeErr := temp.Close(); err == nil && closeErr == nil {
+        os.Rename(temp.Name(), file)
+    }
+}
@@ -0,0 +1,264 @@
+// Embedding drift between two exports: the sections whose meaning shifted
+// most from one doc release to the next, and the sections that are gone
+
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+)
+
+// DriftReport compares two exports of a doc set chunk by chunk, matched by
+// ID
+type DriftReport struct {
+    OldChunks int          `json:"old_chunks"`
+    NewChunks int          `json:"new_chunks"`
+    Unchanged int          `json:"unchanged"`          // same content in both
+    Slight    int          `json:"slight"`             // changed, but drifted less than -min-drift
+    Shifted   []ChunkDrift `json:"shifted,omitempty"`  // changed, most drifted first
+    Unscored  []ChunkDrift `json:"unscored,omitempty"` // changed, without an embedding in one of the exports
+    Removed   []ChunkDrift `json:"removed,omitempty"`
+    Added     []ChunkDrift `json:"added,omitempty"`
+}
+
+// ChunkDrift is one chunk of a drift report, located in the export it's
+// in: the new one, or the old one for removed chunks
+type ChunkDrift struct {
+    ID          string   `json:"id"`
+    File        string   `json:"file"`
+    HeadingPath []string `json:"heading_path"`
+    Line        int      `json:"line"`
+    Drift       float64  `json:"drift,omitempty"`      // 1 minus the cosine similarity of the two embeddings
+    MovedTo     string   `json:"moved_to,omitempty"`   // for a removed chunk, the added chunk carrying its content
+    Similarity  float64  `json:"similarity,omitempty"` // of the removed chunk to MovedTo
+}
+
+// driftChunk returns the report entry of a chunk
+func driftChunk(chunk Chunk) ChunkDrift {
+    return ChunkDrift{ID: chunk.ID, File: chunk.File, HeadingPath: chunk.HeadingPath, Line: chunk.Line}
+}
+
+// runEmbeddingDrift implements the embedding-drift subcommand
+func runEmbeddingDrift(args []string) int {
+    flags := flag.NewFlagSet("embedding-drift", flag.ExitOnError)
+    outputFormat := flags.String("output", "standard", "Output format (standard, json)")
+    top := flags.Int("top", 20, "Shifted chunks to list, most drifted first (0 for all)")
+    minDrift := flags.Float64("min-drift", 0.05, "Drift, 1 minus the cosine similarity, below which a changed chunk counts as slightly changed")
+    moveSimilarity := flags.Float64("move-similarity", 0.9, "Similarity at which an added chunk counts as a removed one moved")
+    flags.Parse(args)
+
+    if flags.NArg() != 2 {
+        fmt.Fprintf(os.Stderr, "Usage: %s embedding-drift [options] <old_export.jsonl> <new_export.jsonl>\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+    oldChunks, err := loadChunks(flags.Arg(0))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: failed to read old export: %v\n", err)
+        return 1
+    }
+    newChunks, err := loadChunks(flags.Arg(1))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: failed to read new export: %v\n", err)
+        return 1
+    }
+
+    report, err := embeddingDrift(oldChunks, newChunks, *minDrift, *moveSimilarity)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+    if *top > 0 && len(report.Shifted) > *top {
+        report.Shifted = report.Shifted[:*top]
+    }
+
+    if *outputFormat == "json" {
+        encoder := json.NewEncoder(os.Stdout)
+        encoder.SetEscapeHTML(false)
+        encoder.SetIndent("", "  ")
+        if err := encoder.Encode(report); err != nil {
+            fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+            return 1
+        }
+        return 0
+    }
+    printDriftReport(report)
+    return 0
+}
+
+// embedChunks sets the embedding of each chunk, that of its text, and the
+// model it's from. It returns the tokens estimated for the texts the cache
+// doesn't have.
+func embedChunks(embedder *Embedder, chunks []Chunk) (int, error) {
+    texts := make([]string, len(chunks))
+    projected := 0
+    seen := make(map[string]bool)
+    for i, chunk := range chunks {
+        texts[i] = chunk.Text
+        if !seen[chunk.Text] && !embedder.cache.has(chunk.Text) {
+            projected += estimateTokens(chunk.Text)
+        }
+        seen[chunk.Text] = true
+    }
+    vectors, err := embedder.Embed(texts)
+    if err != nil {
+        return projected, err
+    }
+    model := embedder.model
+    if model == "" {
+        model = embedder.url // a llama.cpp server or command without a configured Model
+    }
+    for i := range chunks {
+        chunks[i].Embedding = vectors[i]
+        chunks[i].EmbeddingModel = model
+    }
+    return projected, nil
+}
+
+// embeddingDrift matches the chunks of two exports by ID and measures how
+// far each changed chunk's embedding moved. A removed chunk whose content
+// an added one carries, verbatim or at moveSimilarity or more, was moved
+// rather than dropped. Embeddings of different models, or of different
+// sizes, can't be compared.
+func embeddingDrift(oldChunks, newChunks []Chunk, minDrift, moveSimilarity float64) (DriftReport, error) {
+    oldChunks, newChunks = exportedChunks(oldChunks), exportedChunks(newChunks)
+    report := DriftReport{OldChunks: len(oldChunks), NewChunks: len(newChunks)}
+    if err := comparableEmbeddings(oldChunks, newChunks); err != nil {
+        return report, err
+    }
+
+    byID := make(map[string]Chunk)
+    for _, chunk := range newChunks {
+        byID[chunk.ID] = chunk
+    }
+    kept := make(map[string]bool)
+    var removed []Chunk
+    for _, old := range oldChunks {
+        chunk, ok := byID[old.ID]
+        if !ok {
+            removed = append(removed, old)
+            continue
+        }
+        kept[old.ID] = true
+        if chunk.ContentHash == old.ContentHash {
+            report.Unchanged++
+            continue
+        }
+        entry := driftChunk(chunk)
+        if len(old.Embedding) == 0 || len(chunk.Embedding) == 0 {
+            report.Unscored = append(report.Unscored, entry)
+            continue
+        }
+        entry.Drift = 1 - cosineSimilarity(old.Embedding, chunk.Embedding)
+        if entry.Drift < minDrift {
+            report.Slight++
+            continue
+        }
+        report.Shifted = append(report.Shifted, entry)
+    }
+    sort.SliceStable(report.Shifted, func(i, j int) bool { return report.Shifted[i].Drift > report.Shifted[j].Drift })
+
+    var added []Chunk
+    for _, chunk := range newChunks {
+        if !kept[chunk.ID] {
+            added = append(added, chunk)
+            report.Added = append(report.Added, driftChunk(chunk))
+        }
+    }
+    for _, old := range removed {
+        entry := driftChunk(old)
+        for _, chunk := range added {
+            similarity := 0.0
+            switch {
+            case chunk.ContentHash == old.ContentHash:
+                similarity = 1
+            case len(old.Embedding) > 0 && len(chunk.Embedding) > 0:
+                similarity = cosineSimilarity(old.Embedding, chunk.Embedding)
+            }
+            if similarity >= moveSimilarity && similarity > entry.Similarity {
+                entry.MovedTo, entry.Similarity = chunk.ID, similarity
+            }
+        }
+        report.Removed = append(report.Removed, entry)
+    }
+    return report, nil
+}
+
+// exportedChunks leaves out the removal entries an export since a
+// manifest ends with, which aren't chunks of the doc set
+func exportedChunks(chunks []Chunk) []Chunk {
+    var exported []Chunk
+    for _, chunk := range chunks {
+        if chunk.Change != "removed" {
+            exported = append(exported, chunk)
+        }
+    }
+    return exported
+}
+
+// comparableEmbeddings checks that the embeddings of two exports come from
+// one model, so their distances mean something: the same model name, where
+// the exports record one, and the same number of dimensions
+func comparableEmbeddings(oldChunks, newChunks []Chunk) error {
+    model, size := "", 0
+    for _, chunk := range append(append([]Chunk(nil), oldChunks...), newChunks...) {
+        if len(chunk.Embedding) == 0 {
+            continue
+        }
+        switch {
+        case chunk.EmbeddingModel != "" && model != "" && chunk.EmbeddingModel != model:
+            return fmt.Errorf("chunk %s is embedded with %s and others with %s; export both with the same model", chunk.ID, chunk.EmbeddingModel, model)
+        case size != 0 && len(chunk.Embedding) != size:
+            return fmt.Errorf("chunk %s has a %d-dimensional embedding and others %d; export both with the same model", chunk.ID, len(chunk.Embedding), size)
+        }
+        if chunk.EmbeddingModel != "" {
+            model = chunk.EmbeddingModel
+        }
+        size = len(chunk.Embedding)
+    }
+    if size == 0 {
+        fmt.Fprintf(os.Stderr, "Warning: neither export has embeddings (export with -embed); changed chunks are listed unscored\n")
+    }
+    return nil
+}
+
+// printDriftReport writes a drift report for people: the counts, then each
+// list with what to do about it
+func printDriftReport(report DriftReport) {
+    changed := len(report.Shifted) + len(report.Unscored) + report.Slight
+    fmt.Printf("Compared %d chunk(s) with %d: %d unchanged, %d changed, %d removed, %d added\n",
+        report.OldChunks, report.NewChunks, report.Unchanged, changed, len(report.Removed), len(report.Added))
+    if report.Slight > 0 {
+        fmt.Printf("%d changed chunk(s) drifted too little to list; re-ingest their text, but cached answers citing them still hold\n", report.Slight)
+    }
+    location := func(entry ChunkDrift) string {
+        return fmt.Sprintf("%s:%d: %s", entry.File, entry.Line, strings.Join(entry.HeadingPath, " > "))
+    }
+
+    if len(report.Shifted) > 0 {
+        fmt.Printf("\nShifted most (re-ingest, and invalidate cached answers citing them):\n")
+        for _, entry := range report.Shifted {
+            fmt.Printf("  %.3f  %s (%s)\n", entry.Drift, location(entry), entry.ID)
+        }
+    }
+    if len(report.Unscored) > 0 {
+        fmt.Printf("\nChanged without embeddings to compare (re-ingest, and review cached answers citing them):\n")
+        for _, entry := range report.Unscored {
+            fmt.Printf("  %s (%s)\n", location(entry), entry.ID)
+        }
+    }
+    if len(report.Removed) > 0 {
+        fmt.Printf("\nRemoved (delete from the index, and invalidate cached answers citing them):\n")
+        for _, entry := range report.Removed {
+            moved := ""
+            if entry.MovedTo != "" {
+                moved = fmt.Sprintf(", moved to %s at similarity %.3f", entry.MovedTo, entry.Similarity)
+            }
+            fmt.Printf("  %s (%s)%s\n", location(entry), entry.ID, moved)
+        }
+    }
+}
@@ -0,0 +1,282 @@
+// Text embeddings for semantic checks
+
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math"
+    "net/http"
+    "os"
+    "strings"
+    "sync"
+    "time"
+)
+
+// defaultEmbeddingBatchSize caps the inputs sent in one embeddings request
+const defaultEmbeddingBatchSize = 96
+
+// EmbeddingsConfig selects the embedding model used with -semantic. Any
+// API compatible with OpenAI's /embeddings endpoint works, including
+// Ollama, vLLM and Azure OpenAI proxies; local models can also be served
+// by llama.cpp or run by a command.
+type EmbeddingsConfig struct {
+    Provider  string `yaml:"Provider,omitempty"`  // "openai" (default), "llamacpp" or "command"
+    URL       string `yaml:"URL,omitempty"`       // API base URL, https://api.openai.com/v1 by default
+    Model     string `yaml:"Model,omitempty"`     // text-embedding-3-small by default
+    APIKeyEnv string `yaml:"APIKeyEnv,omitempty"` // environment variable holding the API key, OPENAI_API_KEY by default
+    Cache     string `yaml:"Cache,omitempty"`     // directory for cached vectors, embeddings in the cache directory by default; "off" disables
+    Command   string `yaml:"Command,omitempty"`   // with Provider command, the program and arguments to run
+
+    BatchSize         int `yaml:"BatchSize,omitempty"`         // inputs per request, 96 by default
+    RequestsPerMinute int `yaml:"RequestsPerMinute,omitempty"` // rate limit, none by default
+    MaxRetries        int `yaml:"MaxRetries,omitempty"`        // retries of a failed request, 3 by default; -1 for none
+
+    PricePerMillionTokens float64 `yaml:"PricePerMillionTokens,omitempty"` // US dollars, for -max-cost; known for OpenAI models
+}
+
+// Embedder computes text embeddings, remembering each text's vector for
+// the rest of the run and, with a cache, for later runs
+type Embedder struct {
+    provider        string
+    url, model, key string
+    command         string
+    client          *http.Client
+    cache           *embeddingCache
+    batchSize       int
+    limiter         *rateLimiter
+    retries         int
+    breaker         circuitBreaker
+    price, budget   float64 // dollars per million tokens, and the -max-cost budget
+
+    mu      sync.Mutex
+    vectors map[string][]float64
+    usage   embeddingUsage
+    warned  sync.Once
+}
+
+// newEmbedder returns an embedder for the configured model
+func newEmbedder(config EmbeddingsConfig) *Embedder {
+    e := &Embedder{
+        provider: config.Provider,
+        url:      strings.TrimSuffix(config.URL, "/"),
+        model:    config.Model,
+        command:  config.Command,
+        client:   &http.Client{Timeout: 60 * time.Second},
+        vectors:  make(map[string][]float64),
+        limiter:  newRateLimiter(config.RequestsPerMinute),
+    }
+    e.batchSize = config.BatchSize
+    if e.batchSize <= 0 {
+        e.batchSize = defaultEmbeddingBatchSize
+    }
+    switch {
+    case config.MaxRetries == 0:
+        e.retries = defaultMaxRetries
+    case config.MaxRetries > 0:
+        e.retries = config.MaxRetries
+    }
+    switch e.provider {
+    case "", "openai":
+        e.provider = "openai"
+        if e.url == "" {
+            e.url = "https://api.openai.com/v1"
+        }
+        if e.model == "" {
+            e.model = "text-embedding-3-small"
+        }
+    case "llamacpp":
+        // The server embeds with the model it loaded; Model only keys the cache
+        if e.url == "" {
+            e.url = "http://localhost:8080"
+        }
+    case "command":
+        e.url = e.command // names the model in messages and the cache
+    }
+    // Local providers only get a key when one is configured
+    keyEnv := config.APIKeyEnv
+    if keyEnv == "" && e.provider == "openai" {
+        keyEnv = "OPENAI_API_KEY"
+    }
+    if keyEnv != "" {
+        e.key = os.Getenv(keyEnv)
+    }
+    e.cache = newEmbeddingCache(config, e.url, e.model)
+    e.price = embeddingPrice(config, e.provider, e.model)
+    return e
+}
+
+// Embed returns a vector for each text, requesting only the texts it
+// hasn't seen in this run or found in the cache
+func (e *Embedder) Embed(texts []string) ([][]float64, error) {
+    e.mu.Lock()
+    var missing []string
+    queued := make(map[string]bool)
+    for _, text := range texts {
+        if _, ok := e.vectors[text]; ok || queued[text] {
+            continue
+        }
+        if vector, ok := e.cache.get(text); ok {
+            e.vectors[text] = vector
+            e.usage.cached++
+            continue
+        }
+        queued[text] = true
+        missing = append(missing, text)
+    }
+    e.mu.Unlock()
+
+    for start := 0; start < len(missing); start += e.batchSize {
+        batch := missing[start:min(start+e.batchSize, len(missing))]
+        reserved, err := e.charge(batch)
+        if err != nil {
+            return nil, err
+        }
+        vectors, err := e.request(batch)
+        e.settle(reserved)
+        if err != nil {
+            return nil, err
+        }
+        e.mu.Lock()
+        for i, text := range batch {
+            e.vectors[text] = vectors[i]
+        }
+        e.mu.Unlock()
+        for i, text := range batch {
+            e.cache.put(text, vectors[i])
+        }
+    }
+
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    result := make([][]float64, len(texts))
+    for i, text := range texts {
+        result[i] = e.vectors[text]
+    }
+    return result, nil
+}
+
+// request embeds one batch with the configured provider, counting its
+// tokens: those the provider reports, or an estimate. Once the breaker
+// opens, it fails at once, and callers fall back to their non-semantic
+// checks.
+func (e *Embedder) request(texts []string) ([][]float64, error) {
+    if !e.breaker.allow() {
+        return nil, errCircuitOpen
+    }
+    var vectors [][]float64
+    var tokens int
+    var err error
+    switch e.provider {
+    case "llamacpp":
+        vectors, err = e.requestLlamaCpp(texts)
+    case "command":
+        vectors, tokens, err = e.requestCommand(texts)
+    default:
+        vectors, tokens, err = e.requestOpenAI(texts)
+    }
+    e.breaker.record("embeddings provider "+e.url, err)
+    if err != nil {
+        return nil, err
+    }
+
+    if tokens == 0 {
+        for _, text := range texts {
+            tokens += estimateTokens(text)
+        }
+    }
+    e.mu.Lock()
+    e.usage.requests++
+    e.usage.tokens += tokens
+    e.mu.Unlock()
+    return vectors, nil
+}
+
+// post sends a JSON body to an endpoint of the provider, with the rate
+// limit and retries
+func (e *Embedder) post(endpoint string, body []byte) (*http.Response, error) {
+    return doWithRetry(e.client, e.limiter, e.retries, func() (*http.Request, error) {
+        request, err := http.NewRequest(http.MethodPost, e.url+endpoint, bytes.NewReader(body))
+        if err != nil {
+            return nil, err
+        }
+        request.Header.Set("Content-Type", "application/json")
+        if e.key != "" {
+            request.Header.Set("Authorization", "Bearer "+e.key)
+        }
+        return request, nil
+    })
+}
+
+// requestOpenAI calls an OpenAI-compatible embeddings endpoint for one batch
+func (e *Embedder) requestOpenAI(texts []string) ([][]float64, int, error) {
+    body, err := json.Marshal(map[string]any{"model": e.model, "input": texts})
+    if err != nil {
+        return nil, 0, err
+    }
+    response, err := e.post("/embeddings", body)
+    if err != nil {
+        return nil, 0, err
+    }
+    defer response.Body.Close()
+    if response.StatusCode != http.StatusOK {
+        return nil, 0, fmt.Errorf("embeddings request to %s returned %s", e.url, response.Status)
+    }
+    data, err := io.ReadAll(response.Body)
+    if err != nil {
+        return nil, 0, err
+    }
+    return decodeEmbeddings(data, len(texts))
+}
+
+// decodeEmbeddings reads an OpenAI-style embeddings response for n inputs,
+// with the prompt tokens it reports, if any
+func decodeEmbeddings(data []byte, n int) ([][]float64, int, error) {
+    var result struct {
+        Data []struct {
+            Index     int       `json:"index"`
+            Embedding []float64 `json:"embedding"`
+        } `json:"data"`
+        Usage struct {
+            PromptTokens int `json:"prompt_tokens"`
+        } `json:"usage"`
+    }
+    if err := json.Unmarshal(data, &result); err != nil {
+        return nil, 0, fmt.Errorf("failed to decode embeddings response: %w", err)
+    }
+    if len(result.Data) != n {
+        return nil, 0, fmt.Errorf("embeddings response has %d vectors for %d inputs", len(result.Data), n)
+    }
+    vectors := make([][]float64, n)
+    for _, item := range result.Data {
+        if item.Index < 0 || item.Index >= n {
+            return nil, 0, fmt.Errorf("embeddings response has out-of-range index %d", item.Index)
+        }
+        vectors[item.Index] = item.Embedding
+    }
+    return vectors, result.Usage.PromptTokens, nil
+}
+
+// warnFallback reports, once per run, that semantic checks fell back to
+// their lexical versions
+func (e *Embedder) warnFallback(err error) {
+    e.warned.Do(func() {
+        fmt.Fprintf(os.Stderr, "Warning: embeddings unavailable, using keyword overlap instead: %v\n", err)
+    })
+}
+
+// cosineSimilarity returns the cosine of the angle between two vectors
+func cosineSimilarity(a, b []float64) float64 {
+    var dot, normA, normB float64
+    for i := 0; i < len(a) && i < len(b); i++ {
+        dot += a[i] * b[i]
+        normA += a[i] * a[i]
+        normB += b[i] * b[i]
+    }
+    if normA == 0 || normB == 0 {
+        return 0
+    }
+    return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,130 @@
+// Input decoding and column units
+
+package main
+
+import (
+    "bytes"
+    "unicode/utf16"
+    "unicode/utf8"
+)
+
+// readDocument reads a documentation file, or a member of an archive, as
+// UTF-8 text
+func readDocument(path string) (string, error) {
+    data, err := readFileData(path)
+    if err != nil {
+        return "", err
+    }
+    return decodeText(data), nil
+}
+
+// decodeText converts file contents to UTF-8, detecting UTF-16 by its byte
+// order mark or by the zero bytes of mostly-ASCII text, and dropping any BOM.
+// Input that isn't valid UTF-8 is read as Windows-1252, the Latin-1
+// superset legacy Windows exports use.
+func decodeText(data []byte) string {
+    switch {
+    case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+        data = data[3:]
+    case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+        return decodeUTF16(data[2:], false)
+    case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+        return decodeUTF16(data[2:], true)
+    default:
+        if bigEndian, ok := looksLikeUTF16(data); ok {
+            return decodeUTF16(data, bigEndian)
+        }
+    }
+
+    if utf8.Valid(data) {
+        return string(data)
+    }
+    runes := make([]rune, len(data))
+    for i, b := range data {
+        runes[i] = rune(b)
+        if b >= 0x80 && b < 0xA0 && windows1252[b-0x80] != 0 {
+            runes[i] = windows1252[b-0x80]
+        }
+    }
+    return string(runes)
+}
+
+// windows1252 maps bytes 0x80 to 0x9F, control codes in Latin-1, to the
+// characters Windows-1252 puts there: curly quotes, dashes, the euro sign.
+// The five bytes it leaves undefined keep their Latin-1 meaning.
+var windows1252 = [32]rune{
+    '€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+    0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+// looksLikeUTF16 guesses the byte order of BOM-less UTF-16 from where its
+// zero bytes fall
+func looksLikeUTF16(data []byte) (bool, bool) {
+    if len(data) < 4 || len(data)%2 != 0 {
+        return false, false
+    }
+    even, odd := 0, 0
+    for i := 0; i+1 < len(data); i += 2 {
+        if data[i] == 0 {
+            even++
+        }
+        if data[i+1] == 0 {
+            odd++
+        }
+    }
+    pairs := len(data) / 2
+    switch {
+    case even*10 > pairs*4 && odd == 0:
+        return true, true
+    case odd*10 > pairs*4 && even == 0:
+        return false, true
+    }
+    return false, false
+}
+
+// decodeUTF16 decodes UTF-16 text in the given byte order
+func decodeUTF16(data []byte, bigEndian bool) string {
+    units := make([]uint16, len(data)/2)
+    for i := range units {
+        if bigEndian {
+            units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+        } else {
+            units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+        }
+    }
+    return string(utf16.Decode(units))
+}
+
+// columnUnits are the accepted ColumnUnit values
+var columnUnits = map[string]bool{"": true, "rune": true, "utf16": true, "byte": true}
+
+// convertColumns rewrites the byte-offset columns the checks produce into
+// the configured unit: runes (the default), UTF-16 code units as used by
+// LSP clients and browsers, or bytes
+func (a *Analyzer) convertColumns(doc *Document, issues []Issue) []Issue {
+    if a.config.ColumnUnit == "byte" {
+        return issues
+    }
+
+    for i, issue := range issues {
+        if issue.File != doc.Path || issue.Column <= 1 || issue.Line < 1 || issue.Line > len(doc.Lines) {
+            continue
+        }
+        line := doc.Lines[issue.Line-1]
+        prefix := line[:min(issue.Column-1, len(line))]
+
+        width := utf8.RuneCountInString(prefix)
+        if a.config.ColumnUnit == "utf16" {
+            width = 0
+            for _, r := range prefix {
+                if r >= 0x10000 {
+                    width += 2
+                } else {
+                    width++
+                }
+            }
+        }
+        issues[i].Column = width + 1
+    }
+    return issues
+}
@@ -0,0 +1,235 @@
+// Error reference structure checks
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var (
+    // errorPageRegex matches the title of an error reference page
+    errorPageRegex = regexp.MustCompile(`(?i)\b(?:errors?|error codes?|error messages?|exceptions|troubleshooting)\b`)
+    // errorHeadingRegex matches a heading naming one error: an error code,
+    // a constant such as ERR_TIMEOUT, or a message like "Error: ..." On a
+    // page known to be an error reference, any quoted message counts too.
+    errorHeadingRegex = regexp.MustCompile(`(?i)\b(?:error|exception|failed|failure)\b`)
+    // errorCodeRegex matches an error code or constant, which is itself the
+    // literal a search would use
+    errorCodeRegex = regexp.MustCompile(`^[A-Z]{1,5}-?\d{2,}\b|\b[A-Z][A-Z0-9]*(?:_[A-Z0-9]+)+\b`)
+    // causeRegex and resolutionRegex match a heading or label introducing
+    // an error's cause or its resolution
+    causeRegex      = regexp.MustCompile(`(?i)^(?:causes?|reason|why (?:this|it) happens|what causes (?:this|it)|problem)\b`)
+    resolutionRegex = regexp.MustCompile(`(?i)^(?:resolution|resolve|solutions?|fix|how to (?:fix|resolve)|workarounds?|what to do|remedy|steps to resolve)\b`)
+    // causeProseRegex and resolutionProseRegex match a sentence stating the
+    // cause or resolution without a label
+    causeProseRegex      = regexp.MustCompile(`(?i)\b(?:(?:occurs|happens|appears|is (?:returned|raised|thrown|shown)) (?:when|if|because)|is caused by|means that|indicates that)\b`)
+    resolutionProseRegex = regexp.MustCompile(`(?i)\b(?:to (?:fix|resolve|work around|avoid) (?:this|it|the)|you can fix|you can resolve|fix this by|resolve this by)\b`)
+    // labelRegex matches a bold or colon-terminated label opening a line
+    labelRegex = regexp.MustCompile(`^(?:\*\*|__)?([A-Za-z][\w' ]{0,30}?)(?:\*\*|__)?\s*:|^(?:\*\*|__)([A-Za-z][\w' ]{0,30}?)(?:\*\*|__)\s*$`)
+    // errorPageTypes are front matter type values of error reference pages
+    errorPageTypes = map[string]bool{"error": true, "errors": true, "error-reference": true, "troubleshooting": true}
+)
+
+// minErrorEntries is how many error headings make a page an error reference
+// when neither its type nor its title says so
+const minErrorEntries = 3
+
+// errorEntry is one documented error: its heading and everything under it
+// up to the next heading of the same or a higher level
+type errorEntry struct {
+    section                   Section
+    endLine                   int
+    literal                   bool   // the heading or body quotes the error string as code
+    cause                     string // how the cause is given: "heading", "label", "prose" or ""
+    resolution                string
+    causeLine, resolutionLine int
+}
+
+// structure describes how an entry lays out its cause and resolution
+func (e errorEntry) structure() string {
+    order := "cause first"
+    if e.resolutionLine < e.causeLine {
+        order = "resolution first"
+    }
+    return fmt.Sprintf("cause as %s, resolution as %s, %s", e.cause, e.resolution, order)
+}
+
+// analyzeErrorReference checks the entries of an error reference page.
+// "What does error X mean" is among the most common questions put to a
+// docs assistant, and it can only answer from an entry that quotes the
+// error string and gives both its cause and resolution, laid out the same
+// way as the entries around it.
+func (a *Analyzer) analyzeErrorReference(doc *Document) []Issue {
+    entries := errorEntries(doc)
+    if len(entries) == 0 {
+        return nil
+    }
+
+    var issues []Issue
+    report := func(entry errorEntry, rule, severity, message, suggestion string) {
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         entry.section.Line,
+            Column:       1,
+            Rule:         rule,
+            Message:      message,
+            Severity:     severity,
+            Suggestion:   suggestion,
+            OriginalText: strings.TrimSpace(doc.Lines[entry.section.Line-1]),
+        })
+    }
+
+    structures := make(map[string]int)
+    for _, entry := range entries {
+        heading := strings.ReplaceAll(entry.section.Heading, "`", "")
+        if !entry.literal {
+            report(entry, "error-missing-message", "warning",
+                fmt.Sprintf("Error entry %q doesn't quote the error message", heading),
+                "Include the exact error string as inline code or a code block, so searches for the message find this entry")
+        }
+        if entry.cause == "" {
+            report(entry, "error-missing-cause", "warning",
+                fmt.Sprintf("Error entry %q doesn't explain the cause", heading),
+                "Add a \"Cause\" part saying what triggers the error")
+        }
+        if entry.resolution == "" {
+            report(entry, "error-missing-resolution", "warning",
+                fmt.Sprintf("Error entry %q doesn't explain how to resolve it", heading),
+                "Add a \"Resolution\" part with the steps that fix the error")
+        }
+        if entry.cause != "" && entry.resolution != "" {
+            structures[entry.structure()]++
+        }
+    }
+
+    // Entries are compared against the layout most of them share
+    common, count := "", 0
+    for _, structure := range sortedKeys(structures) {
+        if structures[structure] > count {
+            common, count = structure, structures[structure]
+        }
+    }
+    if count < 2 || len(structures) < 2 {
+        return issues
+    }
+    for _, entry := range entries {
+        if entry.cause == "" || entry.resolution == "" || entry.structure() == common {
+            continue
+        }
+        report(entry, "error-inconsistent-structure", "suggestion",
+            fmt.Sprintf("Error entry %q gives %s, unlike the other entries", strings.ReplaceAll(entry.section.Heading, "`", ""), entry.structure()),
+            fmt.Sprintf("Lay the entry out like the other %d: %s", count, common))
+    }
+    return issues
+}
+
+// errorEntries returns the documented errors of an error reference page,
+// or nil when the document isn't one. Entries are the headings that name an
+// error at the shallowest level where they occur below the title.
+func errorEntries(doc *Document) []errorEntry {
+    typed := false
+    for _, key := range []string{"type", "content_type", "page_type", "doc_type"} {
+        if value, ok := doc.FrontMatter[key].(string); ok {
+            typed = errorPageTypes[strings.ToLower(strings.TrimSpace(value))]
+            break
+        }
+    }
+    titled := false
+    for _, section := range doc.Sections {
+        if section.Level == 1 {
+            titled = errorPageRegex.MatchString(plainText(section.Heading))
+            break
+        }
+    }
+
+    namesError := func(section Section) bool {
+        return section.Level > 1 && (errorHeadingRegex.MatchString(section.Heading) || errorCodeRegex.MatchString(section.Heading) || ((typed || titled) && strings.Contains(section.Heading, "`")))
+    }
+    level := 0
+    for _, section := range doc.Sections {
+        if namesError(section) && (level == 0 || section.Level < level) {
+            level = section.Level
+        }
+    }
+    if level == 0 {
+        return nil
+    }
+
+    var entries []errorEntry
+    for i, section := range doc.Sections {
+        if section.Level != level || !namesError(section) {
+            continue
+        }
+        entry := errorEntry{section: section, endLine: section.EndLine}
+        for _, next := range doc.Sections[i+1:] {
+            if next.Level <= level {
+                break
+            }
+            entry.endLine = next.EndLine
+        }
+        doc.describeErrorEntry(&entry)
+        entries = append(entries, entry)
+    }
+    if !typed && !titled && len(entries) < minErrorEntries {
+        return nil
+    }
+    return entries
+}
+
+// describeErrorEntry records whether an entry quotes its error and how it
+// gives the cause and resolution
+func (d *Document) describeErrorEntry(entry *errorEntry) {
+    entry.literal = strings.Contains(entry.section.Heading, "`") || errorCodeRegex.MatchString(entry.section.Heading)
+    for i := entry.section.StartLine - 1; i < entry.endLine && i < len(d.Lines); i++ {
+        trimmed := strings.TrimSpace(d.Lines[i])
+        if d.Fenced[i] {
+            entry.literal = true
+            continue
+        }
+        if trimmed == "" {
+            continue
+        }
+        if strings.Contains(trimmed, "`") || strings.HasPrefix(trimmed, ">") {
+            entry.literal = true
+        }
+
+        style, label := "", ""
+        if match := atxHeadingRegex.FindStringSubmatch(trimmed); match != nil {
+            style, label = "heading", plainText(match[2])
+        } else if match := labelRegex.FindStringSubmatch(trimmed); match != nil {
+            style, label = "label", match[1]+match[2]
+        }
+        switch {
+        case style != "" && causeRegex.MatchString(label):
+            entry.setCause(style, i+1)
+        case style != "" && resolutionRegex.MatchString(label):
+            entry.setResolution(style, i+1)
+        default:
+            text := plainText(trimmed)
+            if causeProseRegex.MatchString(text) {
+                entry.setCause("prose", i+1)
+            }
+            if resolutionProseRegex.MatchString(text) {
+                entry.setResolution("prose", i+1)
+            }
+        }
+    }
+}
+
+// setCause records the first cause found, preferring a heading or label
+// over a sentence
+func (e *errorEntry) setCause(style string, line int) {
+    if e.cause == "" || (e.cause == "prose" && style != "prose") {
+        e.cause, e.causeLine = style, line
+    }
+}
+
+// setResolution records the first resolution found, preferring a heading or
+// label over a sentence
+func (e *errorEntry) setResolution(style string, line int) {
+    if e.resolution == "" || (e.resolution == "prose" && style != "prose") {
+        e.resolution, e.resolutionLine = style, line
+    }
+}
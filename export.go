@@ -0,0 +1,312 @@
+// Export pipeline: split documents into retrieval-ready chunks
+
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "regexp"
+    "strings"
+    "time"
+)
+
+// blankRunRegex matches the blank lines left where boilerplate was removed
+var blankRunRegex = regexp.MustCompile(`\n(?:[ \t]*\n){2,}`)
+
+// Chunk is a single section of a document prepared for RAG ingestion
+type Chunk struct {
+    ID          string        `json:"id"` // file and heading path, stable across content edits
+    File        string        `json:"file"`
+    HeadingPath []string      `json:"heading_path"`
+    NavPath     []string      `json:"nav_path,omitempty"` // nav sections above the page, with a nav file configured
+    Line        int           `json:"line"`
+    Anchor      string        `json:"anchor,omitempty"` // the heading's page anchor
+    ContentHash string        `json:"content_hash"`     // of the content without the heading
+    Text        string        `json:"text"`
+    Type        string        `json:"type,omitempty"`    // "troubleshooting" for troubleshooting pages, for retrieval filtering
+    Quality     *ChunkQuality `json:"quality,omitempty"` // with scoring enabled
+    Change      string        `json:"change,omitempty"`  // "added" or "changed", exporting since a manifest
+    Start       string        `json:"start,omitempty"`   // for transcripts, the time the chunk's narration starts, as in "00:01:23.500"
+    End         string        `json:"end,omitempty"`
+    Commit      string        `json:"commit,omitempty"`       // HEAD commit of the file's git checkout
+    Modified    bool          `json:"modified,omitempty"`     // the file has changes not in commit
+    FileHash    string        `json:"file_hash,omitempty"`    // SHA-256 of the source file
+    Source      string        `json:"source,omitempty"`       // the -repos source the file is from
+    ExtractedAt string        `json:"extracted_at,omitempty"` // time of the export, in RFC 3339
+
+    Embedding      []float64 `json:"embedding,omitempty"`       // vector of Text, with -embed
+    EmbeddingModel string    `json:"embedding_model,omitempty"` // model Embedding is from
+}
+
+// ExportOptions selects the transforms applied to exported chunks
+type ExportOptions struct {
+    Breadcrumbs      bool            // prefix each chunk with its full heading path
+    DescribeDiagrams bool            // follow diagram-as-code blocks with a textual description
+    Nav              *Nav            // site navigation giving reading order and the sections above each page
+    Boilerplate      map[string]int  // paragraphs to leave out, from boilerplateParagraphs
+    Score            bool            // rate each chunk's standalone quality
+    MaxTokens        int             // token budget of a chunk, for scoring and splitting transcripts
+    MinScore         float64         // score below which a chunk is marked low quality
+    Glossary         map[string]bool // terms the glossary defines, for scoring
+    ReleaseEntries   bool            // split release notes pages into a chunk per entry
+}
+
+// buildChunks converts each non-empty section of doc into a chunk. With
+// Breadcrumbs set, the chunk text is prefixed with the full heading path
+// so it keeps its hierarchical context once separated from the document,
+// led by the nav sections above the page when a nav is known.
+func buildChunks(doc *Document, options ExportOptions) []Chunk {
+    var chunks []Chunk
+
+    var navPath []string
+    if page, ok := options.Nav.Lookup(doc.Path); ok && len(page.Titles) > 1 {
+        navPath = page.Titles[:len(page.Titles)-1]
+    }
+
+    if options.DescribeDiagrams {
+        doc = withDiagramDescriptions(doc)
+    }
+    if len(options.Boilerplate) > 0 {
+        doc = withoutBoilerplate(doc, options.Boilerplate)
+    }
+
+    if options.ReleaseEntries && isReleaseNotes(doc) {
+        return releaseChunks(doc, options, navPath)
+    }
+    if doc.Captions != nil {
+        return transcriptChunks(doc, options, navPath)
+    }
+    pageType := ""
+    if classifyPage(doc) == "troubleshooting" {
+        pageType = "troubleshooting"
+    }
+
+    anchors, ids := sectionIdentities(doc)
+    var products map[string]bool
+    if options.Score {
+        products = productTerms(doc)
+    }
+
+    for _, section := range doc.Sections {
+        // Heading-only sections carry no content of their own; their
+        // heading survives in the path of the sections beneath them
+        body := strings.TrimSpace(blankRunRegex.ReplaceAllString(doc.Body(section), "\n\n"))
+        if body == "" {
+            continue
+        }
+
+        text := body
+        if section.Line > 0 {
+            text = doc.Lines[section.Line-1] + "\n\n" + body
+        }
+        var quality *ChunkQuality
+        if options.Score {
+            quality = scoreChunk(section, text, products, options)
+        }
+
+        if options.Breadcrumbs && len(section.Path) > 0 {
+            text = strings.Join(append(append([]string(nil), navPath...), section.Path...), " > ") + "\n\n" + text
+        }
+
+        id := doc.Path
+        if section.Line > 0 {
+            id = ids[section.Line]
+        }
+        chunks = append(chunks, Chunk{
+            ID:          id,
+            File:        doc.Path,
+            HeadingPath: section.Path,
+            NavPath:     navPath,
+            Line:        section.Line,
+            Anchor:      anchors[section.Line],
+            ContentHash: contentHash(body),
+            Text:        text,
+            Quality:     quality,
+            Type:        pageType,
+        })
+    }
+
+    return chunks
+}
+
+// runExport implements the export subcommand, writing chunks as JSON Lines
+func runExport(args []string) int {
+    flags := flag.NewFlagSet("export", flag.ExitOnError)
+    configPath := flags.String("config", "", "Path to configuration file, whose Nav orders the chunks")
+    breadcrumbs := flags.Bool("breadcrumbs", false, "Prefix each chunk with its full heading path")
+    describeDiagrams := flags.Bool("describe-diagrams", false, "Add a textual description after diagram-as-code blocks")
+    skipBoilerplate := flags.Bool("skip-boilerplate", false, "Leave out paragraphs repeated verbatim across the exported files")
+    skipNoindex := flags.Bool("skip-noindex", false, "Leave out pages marked noindex")
+    previousPath := flags.String("previous", "", "Previous export (JSON Lines) to warn about chunk IDs and anchors that heading changes break")
+    sinceManifest := flags.String("since-manifest", "", "Emit only chunks added, changed or removed since this manifest")
+    writeManifestPath := flags.String("write-manifest", "", "Write a manifest of the exported chunks to this file")
+    score := flags.Bool("score", false, "Rate each chunk's standalone quality and mark low-quality chunks")
+    maxTokens := flags.Int("max-tokens", defaultChunkTokens, "Token budget of a chunk, for -score and transcripts")
+    minScore := flags.Float64("min-score", defaultMinChunkScore, "Score below which -score marks a chunk low quality")
+    releaseEntries := flags.Bool("release-entries", false, "Split changelogs and release notes into a chunk per entry, led by its version")
+    recursive := flags.Bool("recursive", false, "Process directories recursively")
+    followSymlinks := flags.Bool("follow-symlinks", false, "Descend into symlinked directories during recursive walks")
+    repoManifest := flags.String("repos", "", "Export the git repositories and directories this manifest lists, cloning them shallowly, instead of paths")
+    embed := flags.Bool("embed", false, "Add each chunk's embedding from the configured Embeddings model, for embedding-drift")
+    maxCost := flags.Float64("max-cost", 0, "Most to spend on -embed embeddings, in US dollars (0 for no limit)")
+    flags.Parse(args)
+
+    if *repoManifest != "" && flags.NArg() > 0 {
+        fmt.Fprintf(os.Stderr, "Error: -repos replaces the paths to export\n")
+        return 1
+    }
+    if flags.NArg() == 0 && *repoManifest == "" {
+        fmt.Fprintf(os.Stderr, "Usage: %s export [options] <file_or_directory>\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+
+    config, err := loadConfig(*configPath)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: failed to load config: %v\n", err)
+        return 1
+    }
+    nav, err := loadNav(config.Nav)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+
+    options := ExportOptions{Breadcrumbs: *breadcrumbs, DescribeDiagrams: *describeDiagrams, Nav: nav, Score: *score, MaxTokens: *maxTokens, MinScore: *minScore, ReleaseEntries: *releaseEntries}
+    if *score {
+        if options.Glossary, err = loadGlossary(config.Jargon); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return 1
+        }
+    }
+    encoder := json.NewEncoder(os.Stdout)
+    encoder.SetEscapeHTML(false)
+    status := 0
+
+    walk := WalkOptions{Recursive: *recursive, FollowSymlinks: *followSymlinks, Formats: config.Formats}
+    var docs []*Document
+    // Documents of -repos sources are read from their checkouts and
+    // exported under their source's name
+    var read, sources map[*Document]string
+    if *repoManifest != "" {
+        roots, err := loadRepoRoots(*repoManifest, *configPath)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return 1
+        }
+        docs, read, sources = repoDocuments(roots, walk)
+    } else {
+        var files []string
+        for _, path := range flags.Args() {
+            found, err := collectFiles(path, walk)
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", path, err)
+                status = 1
+                continue
+            }
+            files = append(files, found...)
+        }
+
+        // Chunks follow the site's reading order; without a nav, the walk order
+        docs = loadDocuments(nav.Order(files), config.Formats)
+    }
+    if *skipBoilerplate {
+        options.Boilerplate = boilerplateParagraphs(docs, config.MinBoilerplateFiles)
+    }
+    origin := newProvenance(time.Now())
+    var exported []Chunk
+    for _, doc := range docs {
+        if noindex, _ := doc.Noindex(); noindex {
+            if *skipNoindex {
+                continue
+            }
+            fmt.Fprintf(os.Stderr, "Warning: exporting %s, which is marked noindex (use -skip-noindex to leave it out)\n", doc.Path)
+        }
+        chunks := buildChunks(doc, options)
+        if path, ok := read[doc]; ok {
+            origin.stamp(path, chunks)
+            for i := range chunks {
+                chunks[i].Source = sources[doc]
+            }
+        } else {
+            origin.stamp(doc.Path, chunks)
+        }
+        exported = append(exported, chunks...)
+    }
+    if *embed {
+        embedder := newEmbedder(config.Embeddings)
+        if err := embedder.setBudget(*maxCost); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return 1
+        }
+        projected, err := embedChunks(embedder, exported)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error: failed to embed chunks: %v\n", err)
+            return 1
+        }
+        embedder.printUsage(os.Stderr, projected)
+    }
+
+    // Since a manifest, only the added and changed chunks are written,
+    // followed by an entry for each removed one
+    emitted, removed := exported, []ManifestEntry(nil)
+    if *sinceManifest != "" {
+        manifest, err := loadManifest(*sinceManifest)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return 1
+        }
+        var unchanged int
+        emitted, removed, unchanged = manifest.changeSet(exported)
+        fmt.Fprintf(os.Stderr, "%d chunk(s) added or changed, %d removed, %d unchanged\n", len(emitted), len(removed), unchanged)
+    }
+    for _, chunk := range emitted {
+        if err := encoder.Encode(chunk); err != nil {
+            fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+            return 1
+        }
+    }
+    for _, entry := range removed {
+        if err := encoder.Encode(entry); err != nil {
+            fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+            return 1
+        }
+    }
+
+    if *previousPath != "" {
+        previous, err := loadChunks(*previousPath)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error: failed to read previous export: %v\n", err)
+            return 1
+        }
+        for _, rename := range renamedChunks(previous, exported) {
+            fmt.Fprintf(os.Stderr, "Warning: %s\n", rename)
+        }
+    }
+
+    if *writeManifestPath != "" {
+        if err := writeManifest(*writeManifestPath, exported, origin.extractedAt); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: failed to write manifest: %v\n", err)
+            return 1
+        }
+    }
+
+    return status
+}
+
+// withDiagramDescriptions returns a copy of doc whose diagram blocks are each
+// followed by a plain-text description of their structure
+func withDiagramDescriptions(doc *Document) *Document {
+    described := *doc
+    described.Lines = append([]string(nil), doc.Lines...)
+
+    for _, block := range doc.CodeBlocks {
+        if lang, ok := doc.diagramLanguage(block); ok {
+            described.Lines[block.EndLine-1] += "\n\n" + describeDiagram(lang, doc.Code(block))
+        }
+    }
+
+    return &described
+}
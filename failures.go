@@ -0,0 +1,132 @@
+// Partial failures of a run, reported as issues
+
+package main
+
+import (
+    "fmt"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+    "sync"
+    "unicode"
+    "unicode/utf8"
+)
+
+// failureSeverity is the severity of partial-failure issues: input the run
+// couldn't analyze, as opposed to problems in the docs
+const failureSeverity = "failure"
+
+// The rules of partial-failure issues
+const (
+    readFailureRule    = "read-failure"    // files, directories and archives that couldn't be read
+    parseFailureRule   = "parse-failure"   // content that couldn't be parsed, such as invalid front matter
+    invalidRuleRule    = "invalid-rule"    // rules skipped for an invalid pattern
+    includeFailureRule = "include-failure" // include directives that couldn't be resolved
+)
+
+// failures collects the partial failures of an analysis run, or is nil
+// outside one, where they're printed as warnings. The analyze and remote
+// source subcommands set it.
+var failures *failureLog
+
+// failureLog holds the partial failures of a run, each once, however
+// often the walk or the checks come across it
+type failureLog struct {
+    mu     sync.Mutex
+    seen   map[string]bool
+    issues []Issue
+}
+
+func newFailureLog() *failureLog {
+    return &failureLog{seen: make(map[string]bool)}
+}
+
+// reportFailure records input the run couldn't analyze: with a failure
+// log, as an issue of rule at file and line (1 if unknown), and otherwise
+// as a warning on stderr
+func reportFailure(rule, file string, line int, format string, args ...any) {
+    message := fmt.Sprintf(format, args...)
+    if failures == nil {
+        if line > 0 {
+            fmt.Fprintf(os.Stderr, "Warning: %s:%d: %s\n", file, line, message)
+        } else {
+            fmt.Fprintf(os.Stderr, "Warning: %s\n", message)
+        }
+        return
+    }
+
+    failures.mu.Lock()
+    defer failures.mu.Unlock()
+    key := rule + "\x00" + file + "\x00" + message
+    if failures.seen[key] {
+        return
+    }
+    failures.seen[key] = true
+    first, size := utf8.DecodeRuneInString(message)
+    issue := Issue{
+        File:     file,
+        Line:     max(line, 1),
+        Column:   1,
+        Rule:     rule,
+        Message:  string(unicode.ToUpper(first)) + message[size:],
+        Severity: failureSeverity,
+    }
+    if rule == readFailureRule {
+        issue.Skip = readFailureReason(args)
+    }
+    failures.issues = append(failures.issues, issue)
+}
+
+// reportSkipped records a file a fetch skipped, as reported by
+// skippedFile: with a failure log, as the issue, and otherwise as a
+// warning on stderr
+func reportSkipped(issue Issue) {
+    if failures == nil {
+        fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", issue.File, issue.Message)
+        return
+    }
+    failures.mu.Lock()
+    defer failures.mu.Unlock()
+    failures.issues = append(failures.issues, issue)
+}
+
+// drain returns the failures recorded so far and forgets them
+func (l *failureLog) drain() []Issue {
+    if l == nil {
+        return nil
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    issues := l.issues
+    l.issues = nil
+    l.seen = make(map[string]bool)
+    return issues
+}
+
+// yamlErrorLine matches the line yaml.v3 reports for a syntax error
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+):`)
+
+// frontMatterFailure reports front matter that isn't valid YAML, which
+// the checks otherwise treat as absent
+func frontMatterFailure(doc *Document) []Issue {
+    if doc.FrontMatterErr == nil {
+        return nil
+    }
+    line := 1
+    message := doc.FrontMatterErr.Error()
+    if match := yamlErrorLine.FindStringSubmatch(message); match != nil {
+        n, _ := strconv.Atoi(match[1])
+        line = n + 1 // the YAML starts on the line after the opening ---
+        message = strings.TrimSpace(message[len(match[0]):])
+    }
+    return []Issue{{
+        File:       doc.Path,
+        Line:       line,
+        Column:     1,
+        Rule:       parseFailureRule,
+        Message:    "Front matter is not valid YAML, so checks ignore it: " + strings.TrimPrefix(message, "yaml: "),
+        Severity:   failureSeverity,
+        Suggestion: "Fix the YAML between the --- lines",
+    }}
+}
@@ -0,0 +1,173 @@
+// FAQ structure checks
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+var (
+    // faqTitleRegex matches the title of an FAQ page
+    faqTitleRegex = regexp.MustCompile(`(?i)\b(?:faqs?|frequently asked questions|questions and answers|q&a)\b`)
+    // boldQuestionRegex matches a line holding only a bold question
+    boldQuestionRegex = regexp.MustCompile(`^(?:\*\*|__)(?:Q:\s*)?(.+\?)\s*(?:\*\*|__)$|^(?:\*\*|__)Q:?(?:\*\*|__):?\s*(.+\?)$`)
+    // questionPrefixRegex matches a "Q:" label before a heading question
+    questionPrefixRegex = regexp.MustCompile(`(?i)^q\s*:\s*`)
+    // dependentAnswerRegex matches an answer that only makes sense after
+    // its question: "Yes.", "No, ...", "See above"
+    dependentAnswerRegex = regexp.MustCompile(`(?i)^(?:a:\s*)?(?:yes|no|yep|nope|correct|exactly|sure|absolutely|not yet|see (?:above|below|the previous (?:question|answer)))\b`)
+    // faqTypes are front matter type values of FAQ pages
+    faqTypes = map[string]bool{"faq": true, "faqs": true, "qna": true, "q&a": true}
+)
+
+// minFAQQuestions is how many questions make a page an FAQ when neither its
+// type nor its title says so
+const minFAQQuestions = 3
+
+// faqQuestion is one question of an FAQ page with the span of its answer
+type faqQuestion struct {
+    text      string
+    line      int
+    heading   bool
+    answerEnd int // 1-based last line of the answer (inclusive)
+}
+
+// analyzeFAQ checks the questions of an FAQ page. A question under its own
+// heading becomes a chunk that matches the query and carries the answer;
+// a bold question runs into the neighbouring answers, an answer that opens
+// with "Yes." depends on a question the chunk may not include, and a
+// repeated question splits its answer across two chunks.
+func (a *Analyzer) analyzeFAQ(doc *Document, lang string) []Issue {
+    questions := faqQuestions(doc)
+    if len(questions) == 0 {
+        return nil
+    }
+
+    var issues []Issue
+    report := func(line int, rule, severity, message, suggestion string) {
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         line,
+            Column:       1,
+            Rule:         rule,
+            Message:      message,
+            Severity:     severity,
+            Suggestion:   suggestion,
+            OriginalText: strings.TrimSpace(doc.Lines[line-1]),
+        })
+    }
+
+    seen := make(map[string]faqQuestion)
+    for _, question := range questions {
+        if !question.heading {
+            report(question.line, "faq-question-not-heading", "warning",
+                fmt.Sprintf("FAQ question %q is bold text, not a heading", question.text),
+                fmt.Sprintf("Make the question a heading (\"## %s\") so each question and answer is one chunk", question.text))
+        }
+
+        answer, line := doc.paragraphIn(question.line+1, question.answerEnd)
+        switch {
+        case !doc.hasContent(question.line+1, question.answerEnd):
+            report(question.line, "faq-missing-answer", "warning",
+                fmt.Sprintf("FAQ question %q has no answer", question.text),
+                "Answer the question, or remove it")
+        case lang == "en" && answer != "" && dependentAnswerRegex.MatchString(answer):
+            report(line, "faq-answer-not-self-contained", "warning",
+                fmt.Sprintf("Answer to %q only makes sense with its question", question.text),
+                "Open the answer with a full statement that restates the subject (\"Yes, CloudSync supports SSO with Okta.\")")
+        }
+
+        key := questionKey(question.text)
+        if first, ok := seen[key]; ok {
+            report(question.line, "faq-duplicate-question", "warning",
+                fmt.Sprintf("FAQ question %q repeats the question at line %d", question.text, first.line),
+                "Merge the answers under one question")
+            continue
+        }
+        seen[key] = question
+    }
+    return issues
+}
+
+// faqQuestions returns the questions of an FAQ page, or nil when the
+// document isn't one. Questions are headings ending in "?", and lines
+// holding only a bold question.
+func faqQuestions(doc *Document) []faqQuestion {
+    faq := false
+    for _, key := range []string{"type", "content_type", "page_type", "doc_type"} {
+        if value, ok := doc.FrontMatter[key].(string); ok {
+            faq = faqTypes[strings.ToLower(strings.TrimSpace(value))]
+            break
+        }
+    }
+    for _, section := range doc.Sections {
+        if section.Level == 1 {
+            faq = faq || faqTitleRegex.MatchString(plainText(section.Heading))
+            break
+        }
+    }
+
+    var questions []faqQuestion
+    for _, section := range doc.Sections {
+        heading := questionPrefixRegex.ReplaceAllString(plainText(section.Heading), "")
+        if section.Line > 0 && section.Level > 1 && strings.HasSuffix(heading, "?") {
+            questions = append(questions, faqQuestion{text: heading, line: section.Line, heading: true, answerEnd: section.EndLine})
+        }
+        for i := section.StartLine - 1; i < section.EndLine && i < len(doc.Lines); i++ {
+            if doc.Fenced[i] {
+                continue
+            }
+            match := boldQuestionRegex.FindStringSubmatch(strings.TrimSpace(doc.Lines[i]))
+            if match == nil {
+                continue
+            }
+            questions = append(questions, faqQuestion{text: plainText(match[1] + match[2]), line: i + 1, answerEnd: section.EndLine})
+        }
+    }
+    sort.SliceStable(questions, func(i, j int) bool { return questions[i].line < questions[j].line })
+
+    // A bold question's answer ends where the next bold question starts
+    for i := range questions {
+        if i+1 < len(questions) && !questions[i+1].heading && questions[i+1].line <= questions[i].answerEnd {
+            questions[i].answerEnd = questions[i+1].line - 1
+        }
+    }
+
+    if !faq && len(questions) < minFAQQuestions {
+        return nil
+    }
+    return questions
+}
+
+// hasContent reports whether any line between the 1-based lines first and
+// last (inclusive) is non-blank
+func (d *Document) hasContent(first, last int) bool {
+    for i := first - 1; i < last && i < len(d.Lines); i++ {
+        if strings.TrimSpace(d.Lines[i]) != "" {
+            return true
+        }
+    }
+    return false
+}
+
+// questionKey normalizes a question to its sorted content-word stems, so
+// "How do I reset my password?" and "How can I reset the password?"
+// compare equal
+func questionKey(question string) string {
+    seen := make(map[string]bool)
+    var stems []string
+    for _, word := range contentWords(question) {
+        if term := stem(word); !seen[term] {
+            seen[term] = true
+            stems = append(stems, term)
+        }
+    }
+    if len(stems) == 0 {
+        return strings.ToLower(question)
+    }
+    sort.Strings(stems)
+    return strings.Join(stems, " ")
+}
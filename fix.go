@@ -0,0 +1,186 @@
+// Automatic fixes
+
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+)
+
+// Fix is a mechanical edit that resolves an issue. Without a Column,
+// Insert is added as new lines before the 1-based Line; with one, the
+// Length bytes at that 1-based byte Column of Line are replaced with
+// Replace.
+type Fix struct {
+    Line    int
+    Column  int    `json:",omitempty"`
+    Length  int    `json:",omitempty"`
+    Insert  string `json:",omitempty"`
+    Replace string `json:",omitempty"`
+    Safe    bool   // the edit can't change the meaning, so -safe-only applies it
+}
+
+// describe summarizes the edit for text output
+func (f Fix) describe() string {
+    safety := "needs review"
+    if f.Safe {
+        safety = "safe"
+    }
+    if f.Column > 0 {
+        return fmt.Sprintf("replace with %q (%s)", f.Replace, safety)
+    }
+    return fmt.Sprintf("insert %d line(s) before line %d (%s)", strings.Count(strings.TrimSuffix(f.Insert, "\n"), "\n")+1, f.Line, safety)
+}
+
+// applyFixes applies the fixes of the given issues to their files, or only
+// the safe ones with safeOnly, and returns the issues left unfixed. Only
+// plain UTF-8 files on disk are edited; fixes for remote, archived or
+// re-encoded documents are reported as issues like any other.
+func applyFixes(issues []Issue, safeOnly bool) []Issue {
+    byFile := make(map[string][]int)
+    for i, issue := range issues {
+        if issue.Fix != nil && issue.URL == "" && (issue.Fix.Safe || !safeOnly) {
+            byFile[issue.File] = append(byFile[issue.File], i)
+        }
+    }
+
+    fixed := make(map[int]bool)
+    for _, file := range sortedKeys(byFile) {
+        applied, err := fixFile(file, issues, byFile[file])
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Warning: not fixing %s: %v\n", file, err)
+            continue
+        }
+        if len(applied) == 0 {
+            continue
+        }
+        for _, i := range applied {
+            fixed[i] = true
+        }
+        noun := "issues"
+        if len(applied) == 1 {
+            noun = "issue"
+        }
+        fmt.Fprintf(os.Stderr, "Fixed %d %s in %s\n", len(applied), noun, file)
+    }
+
+    var remaining []Issue
+    for i, issue := range issues {
+        if !fixed[i] {
+            remaining = append(remaining, issue)
+        }
+    }
+    return remaining
+}
+
+// fixFile applies the fixes of the indexed issues to one file and returns
+// the indexes it applied
+func fixFile(file string, issues []Issue, indexes []int) ([]int, error) {
+    fixed, err := fixContent(file, issues, indexes)
+    if err != nil || len(fixed.applied) == 0 {
+        return nil, err
+    }
+    return fixed.applied, os.WriteFile(file, fixed.output, fixed.perm)
+}
+
+// fixedFile is a file with fixes applied, before it is written
+type fixedFile struct {
+    applied  []int // indexes of the issues fixed
+    original []byte
+    output   []byte
+    origin   []int // 1-based line of the original each output line comes from, 0 for inserted lines
+    perm     os.FileMode
+}
+
+// fixContent applies the fixes of the indexed issues to the content of
+// one file, keeping its byte order mark and line endings. A replacement
+// whose text no longer matches the issue, or that overlaps another, is
+// skipped with a warning.
+func fixContent(file string, issues []Issue, indexes []int) (*fixedFile, error) {
+    if _, _, ok := splitArchivePath(file); ok {
+        return nil, fmt.Errorf("archive members can't be edited")
+    }
+    info, err := os.Stat(file)
+    if err != nil {
+        return nil, err
+    }
+    data, err := os.ReadFile(file)
+    if err != nil {
+        return nil, err
+    }
+    bom := []byte{0xEF, 0xBB, 0xBF}
+    body := bytes.TrimPrefix(data, bom)
+    if decodeText(data) != string(body) {
+        return nil, fmt.Errorf("file isn't UTF-8")
+    }
+
+    lines := strings.Split(string(body), "\n")
+    origin := make([]int, len(lines))
+    for i := range origin {
+        origin[i] = i + 1
+    }
+    newline := "\n"
+    if strings.HasSuffix(lines[0], "\r") {
+        newline = "\r\n"
+    }
+
+    // Edit from the bottom up and right to left so earlier positions stay
+    // valid; a line's replacements come before lines inserted above it
+    sort.SliceStable(indexes, func(a, b int) bool {
+        x, y := issues[indexes[a]].Fix, issues[indexes[b]].Fix
+        if x.Line != y.Line {
+            return x.Line > y.Line
+        }
+        return x.Column > y.Column
+    })
+    var applied []int
+    seen := make(map[Fix]bool)
+    edited := make(map[int]int) // line -> start of the leftmost replacement so far
+    for _, i := range indexes {
+        fix := *issues[i].Fix
+        if seen[fix] {
+            applied = append(applied, i)
+            continue
+        }
+        if fix.Line < 1 || fix.Line > len(lines)+1 || (fix.Column > 0 && fix.Line > len(lines)) {
+            return nil, fmt.Errorf("fix for %s at line %d is out of range", issues[i].Rule, fix.Line)
+        }
+
+        if fix.Column > 0 {
+            line := lines[fix.Line-1]
+            start, end := fix.Column-1, fix.Column-1+fix.Length
+            if leftmost, ok := edited[fix.Line]; (ok && end > leftmost) || end > len(strings.TrimSuffix(line, "\r")) || line[start:end] != issues[i].OriginalText {
+                fmt.Fprintf(os.Stderr, "Warning: not fixing %s at %s:%d: the text has changed or overlaps another fix\n", issues[i].Rule, file, fix.Line)
+                continue
+            }
+            lines[fix.Line-1] = line[:start] + fix.Replace + line[end:]
+            edited[fix.Line] = start
+            seen[fix] = true
+            applied = append(applied, i)
+            continue
+        }
+
+        inserted := strings.Split(strings.TrimSuffix(fix.Insert, "\n"), "\n")
+        if newline != "\n" {
+            for j := range inserted {
+                inserted[j] += "\r"
+            }
+        }
+        lines = append(lines[:fix.Line-1], append(inserted, lines[fix.Line-1:]...)...)
+        origin = append(origin[:fix.Line-1], append(make([]int, len(inserted)), origin[fix.Line-1:]...)...)
+        seen[fix] = true
+        applied = append(applied, i)
+    }
+    if len(applied) == 0 {
+        return &fixedFile{}, nil
+    }
+
+    output := strings.Join(lines, "\n")
+    if bytes.HasPrefix(data, bom) {
+        output = string(bom) + output
+    }
+    return &fixedFile{applied: applied, original: data, output: []byte(output), origin: origin, perm: info.Mode().Perm()}, nil
+}
@@ -0,0 +1,118 @@
+// Output formatters
+
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+    "strings"
+)
+
+// Formatter writes the issues of a run in one output format
+type Formatter interface {
+    Format(w io.Writer, issues []Issue) error
+}
+
+// FormatterFunc adapts a function to the Formatter interface
+type FormatterFunc func(w io.Writer, issues []Issue) error
+
+func (f FormatterFunc) Format(w io.Writer, issues []Issue) error {
+    return f(w, issues)
+}
+
+// formatters are the registered output formats, by -output name
+var formatters = make(map[string]Formatter)
+
+// RegisterFormatter makes a formatter available as -output name,
+// replacing any already registered under that name
+func RegisterFormatter(name string, formatter Formatter) {
+    formatters[name] = formatter
+}
+
+func init() {
+    RegisterFormatter("standard", FormatterFunc(printStandardIssues))
+    RegisterFormatter("short", FormatterFunc(printShortIssues))
+    RegisterFormatter("json", FormatterFunc(printJSONIssues))
+}
+
+// printShortIssues writes one gcc-style line per issue, such as
+// "docs/install.md:12:5: warning: Vague quantifier 'some' [vague-quantifier]",
+// without suggestions or blank lines, for pre-commit hooks and editors.
+// The column, or line and column, are left out for issues about a whole
+// line or file.
+func printShortIssues(w io.Writer, issues []Issue) error {
+    for _, issue := range issues {
+        location := issueLocation(issue)
+        if issue.Line > 0 {
+            location += fmt.Sprintf(":%d", issue.Line)
+            if issue.Column > 0 {
+                location += fmt.Sprintf(":%d", issue.Column)
+            }
+        }
+        message := strings.Join(strings.Fields(issue.Message), " ")
+        if _, err := fmt.Fprintf(w, "%s: %s: %s [%s]\n", location, issue.Severity, message, issue.Rule); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// externalFormatterPrefix names executables found on PATH as formatters:
+// ai-doc-optimizer-format-checkstyle serves -output checkstyle
+const externalFormatterPrefix = "ai-doc-optimizer-format-"
+
+// formatterFor resolves an -output name to a formatter: a command from the
+// Formatters config, then a registered formatter, then an executable on PATH
+func formatterFor(name string, configured map[string]string) (Formatter, error) {
+    if command, ok := configured[name]; ok {
+        args := strings.Fields(command)
+        if len(args) == 0 {
+            return nil, fmt.Errorf("formatter %s has an empty command", name)
+        }
+        return externalFormatter{name: name, args: args}, nil
+    }
+    if formatter, ok := formatters[name]; ok {
+        return formatter, nil
+    }
+    if path, err := exec.LookPath(externalFormatterPrefix + name); err == nil {
+        return externalFormatter{name: name, args: []string{path}}, nil
+    }
+
+    names := sortedKeys(formatters)
+    for _, configuredName := range sortedKeys(configured) {
+        if formatters[configuredName] == nil {
+            names = append(names, configuredName)
+        }
+    }
+    return nil, fmt.Errorf("unknown output format %q (available: %s, or an %s<name> executable on PATH)",
+        name, strings.Join(names, ", "), externalFormatterPrefix)
+}
+
+// externalFormatter runs an executable that reads the JSON report on stdin
+// and writes the formatted output to stdout. The format name is passed in
+// AI_DOC_OPTIMIZER_FORMAT, so one executable can serve several formats.
+// A non-zero exit status fails the run.
+type externalFormatter struct {
+    name string
+    args []string
+}
+
+func (f externalFormatter) Format(w io.Writer, issues []Issue) error {
+    var report bytes.Buffer
+    if err := printJSONIssues(&report, issues); err != nil {
+        return err
+    }
+
+    cmd := exec.Command(f.args[0], f.args[1:]...)
+    cmd.Stdin = &report
+    cmd.Stdout = w
+    cmd.Stderr = os.Stderr
+    cmd.Env = append(os.Environ(), "AI_DOC_OPTIMIZER_FORMAT="+f.name)
+    if err := cmd.Run(); err != nil {
+        return fmt.Errorf("formatter %s: %w", f.name, err)
+    }
+    return nil
+}
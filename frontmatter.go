@@ -0,0 +1,121 @@
+// Front matter field checks
+
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// FrontMatterConfig lists the front matter fields documents must declare
+type FrontMatterConfig struct {
+    Required []FieldRequirement `yaml:"Required"`
+}
+
+// FieldRequirement is one required front matter field. Field may list
+// alternatives separated by "|" (e.g. "tags|keywords"); any one satisfies it.
+// Length bounds apply to string values; lists must have a non-empty item.
+type FieldRequirement struct {
+    Field     string `yaml:"Field"`
+    MinLength int    `yaml:"MinLength,omitempty"`
+    MaxLength int    `yaml:"MaxLength,omitempty"`
+}
+
+// analyzeFrontMatter checks the document's front matter against the
+// configured required fields, since many RAG pipelines index them directly
+func (a *Analyzer) analyzeFrontMatter(doc *Document) []Issue {
+    var issues []Issue
+    required := a.config.FrontMatter.Required
+
+    if len(required) == 0 {
+        return nil
+    }
+    if doc.Format == "html" {
+        return nil
+    }
+
+    if doc.FrontMatter == nil {
+        var fields []string
+        for _, req := range required {
+            fields = append(fields, req.Field)
+        }
+        return []Issue{{
+            File:       doc.Path,
+            Line:       1,
+            Column:     1,
+            Rule:       "front-matter-required-fields",
+            Message:    "Document has no front matter",
+            Severity:   "warning",
+            Suggestion: fmt.Sprintf("Add a front matter block declaring: %s", strings.Join(fields, ", ")),
+        }}
+    }
+
+    for _, req := range required {
+        key, value, found := lookupField(doc.FrontMatter, req.Field)
+        if !found {
+            issues = append(issues, Issue{
+                File:       doc.Path,
+                Line:       1,
+                Column:     1,
+                Rule:       "front-matter-required-fields",
+                Message:    fmt.Sprintf("Front matter is missing required field '%s'", req.Field),
+                Severity:   "warning",
+                Suggestion: fmt.Sprintf("Add a non-empty '%s' field", strings.Split(req.Field, "|")[0]),
+            })
+            continue
+        }
+
+        if problem := req.check(value); problem != "" {
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         doc.frontMatterLine(key),
+                Column:       1,
+                Rule:         "front-matter-required-fields",
+                Message:      fmt.Sprintf("Front matter field '%s' %s", key, problem),
+                Severity:     "warning",
+                Suggestion:   fmt.Sprintf("Give '%s' a descriptive value that stands on its own", key),
+                OriginalText: fmt.Sprint(value),
+            })
+        }
+    }
+
+    return issues
+}
+
+// lookupField returns the first of the alternative field names present
+func lookupField(fields map[string]interface{}, names string) (string, interface{}, bool) {
+    for _, name := range strings.Split(names, "|") {
+        name = strings.TrimSpace(name)
+        if value, ok := fields[name]; ok {
+            return name, value, true
+        }
+    }
+    return "", nil, false
+}
+
+// check describes what's wrong with a field value, or returns "" if it's acceptable
+func (req FieldRequirement) check(value interface{}) string {
+    switch v := value.(type) {
+    case nil:
+        return "is empty"
+    case []interface{}:
+        for _, item := range v {
+            if strings.TrimSpace(fmt.Sprint(item)) != "" {
+                return ""
+            }
+        }
+        return "is an empty list"
+    default:
+        text := strings.TrimSpace(fmt.Sprint(v))
+        length := len([]rune(text))
+        switch {
+        case length == 0:
+            return "is empty"
+        case req.MinLength > 0 && length < req.MinLength:
+            return fmt.Sprintf("is too short (%d characters, minimum %d)", length, req.MinLength)
+        case req.MaxLength > 0 && length > req.MaxLength:
+            return fmt.Sprintf("is too long (%d characters, maximum %d)", length, req.MaxLength)
+        }
+        return ""
+    }
+}
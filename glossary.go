@@ -0,0 +1,211 @@
+// Glossary generation
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// GlossaryTerm is one entry of a generated glossary
+type GlossaryTerm struct {
+    Term       string   `yaml:"Term"`
+    Definition string   `yaml:"Definition"`        // empty for a term used but never defined
+    Count      int      `yaml:"Count"`             // occurrences across the corpus
+    Sources    []string `yaml:"Sources,omitempty"` // file:line of the definition, or of the first use when undefined
+}
+
+// runGlossary implements the glossary subcommand
+func runGlossary(args []string) int {
+    flags := flag.NewFlagSet("glossary", flag.ExitOnError)
+    configPath := flags.String("config", "", "Path or HTTPS/git URL of configuration file")
+    format := flags.String("format", "yaml", "Glossary format (yaml, markdown)")
+    recursive := flags.Bool("recursive", false, "Process directories recursively")
+    followSymlinks := flags.Bool("follow-symlinks", false, "Descend into symlinked directories during recursive walks")
+    minCount := flags.Int("min-count", 2, "Include undefined terms used at least this many times")
+    definedOnly := flags.Bool("defined-only", false, "Include only terms with a definition")
+    flags.Parse(args)
+
+    if flags.NArg() == 0 || (*format != "yaml" && *format != "markdown") {
+        fmt.Fprintf(os.Stderr, "Usage: %s glossary [options] <file_or_directory>...\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+
+    analyzer, err := NewAnalyzer(*configPath)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error creating analyzer: %v\n", err)
+        return 1
+    }
+
+    var docs []*Document
+    for _, path := range flags.Args() {
+        found, err := collectFiles(path, WalkOptions{Recursive: *recursive, FollowSymlinks: *followSymlinks, Formats: analyzer.config.Formats})
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", path, err)
+            return 1
+        }
+        for _, file := range found {
+            content, err := readDocument(file)
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "Warning: failed to read %s: %v\n", file, err)
+                continue
+            }
+            docs = append(docs, ParseDocument(file, content))
+        }
+    }
+
+    terms := buildGlossary(docs, analyzer.config.Jargon.Known, *minCount, *definedOnly)
+    if *format == "markdown" {
+        err = writeMarkdownGlossary(os.Stdout, terms)
+    } else {
+        err = writeYAMLGlossary(os.Stdout, terms)
+    }
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error writing glossary: %v\n", err)
+        return 1
+    }
+    return 0
+}
+
+// buildGlossary collects the jargon terms of a corpus with the definitions
+// found for them. Each term takes its most common definition, the first
+// one found on a tie. Known terms are left out.
+func buildGlossary(docs []*Document, known []string, minCount int, definedOnly bool) []GlossaryTerm {
+    skip := make(map[string]bool)
+    for _, term := range append(append([]string(nil), knownTerms...), known...) {
+        skip[strings.ToLower(term)] = true
+    }
+
+    type candidate struct {
+        spellings   map[string]int
+        definitions map[string]int
+        order       []string // definitions in the order found
+        sources     map[string]string
+        count       int
+        firstUse    string
+    }
+    candidates := make(map[string]*candidate)
+    get := func(term string) *candidate {
+        key := strings.ToLower(term)
+        if candidates[key] == nil {
+            candidates[key] = &candidate{spellings: make(map[string]int), definitions: make(map[string]int), sources: make(map[string]string)}
+        }
+        return candidates[key]
+    }
+
+    for _, doc := range docs {
+        for _, def := range documentDefinitions(doc) {
+            if def.text == "" || (!def.entry && !isJargonTerm(def.term)) || skip[strings.ToLower(def.term)] {
+                continue
+            }
+            entry := get(def.term)
+            entry.spellings[def.term]++
+            if entry.definitions[def.text] == 0 {
+                entry.order = append(entry.order, def.text)
+                entry.sources[def.text] = fmt.Sprintf("%s:%d", doc.Path, def.line)
+            }
+            entry.definitions[def.text]++
+        }
+        for _, section := range doc.Sections {
+            for term, count := range doc.jargonIn(section) {
+                if skip[strings.ToLower(term)] {
+                    continue
+                }
+                entry := get(term)
+                entry.spellings[term] += count
+                entry.count += count
+                if entry.firstUse == "" {
+                    line := section.Line
+                    if line == 0 {
+                        line = section.StartLine
+                    }
+                    entry.firstUse = fmt.Sprintf("%s:%d", doc.Path, line)
+                }
+            }
+        }
+    }
+
+    var terms []GlossaryTerm
+    for _, entry := range candidates {
+        // The most frequent spelling names the term: "OAuth" over "Oauth"
+        spellings := sortedKeys(entry.spellings)
+        sort.SliceStable(spellings, func(i, j int) bool { return entry.spellings[spellings[i]] > entry.spellings[spellings[j]] })
+        term := GlossaryTerm{Term: spellings[0], Count: entry.count}
+
+        if len(entry.order) > 0 {
+            best := entry.order[0]
+            for _, text := range entry.order[1:] {
+                if entry.definitions[text] > entry.definitions[best] {
+                    best = text
+                }
+            }
+            term.Definition = best
+            term.Sources = []string{entry.sources[best]}
+        } else {
+            if definedOnly || entry.count < minCount {
+                continue
+            }
+            term.Sources = []string{entry.firstUse}
+        }
+        terms = append(terms, term)
+    }
+    sort.Slice(terms, func(i, j int) bool {
+        a, b := strings.ToLower(terms[i].Term), strings.ToLower(terms[j].Term)
+        if a != b {
+            return a < b
+        }
+        return terms[i].Term < terms[j].Term
+    })
+    return terms
+}
+
+// writeYAMLGlossary writes the glossary in the YAML form Jargon.Glossary reads
+func writeYAMLGlossary(w io.Writer, terms []GlossaryTerm) error {
+    fmt.Fprintln(w, "# Generated by ai-doc-optimizer glossary. Terms without a Definition were used but never defined.")
+    encoder := yaml.NewEncoder(w)
+    encoder.SetIndent(2)
+    if err := encoder.Encode(struct {
+        Terms []GlossaryTerm `yaml:"Terms"`
+    }{terms}); err != nil {
+        return err
+    }
+    return encoder.Close()
+}
+
+// writeMarkdownGlossary writes one heading per defined term, followed by
+// its definition, then lists the undefined terms. They are kept out of
+// headings so the file doesn't define them when read back as a glossary.
+func writeMarkdownGlossary(w io.Writer, terms []GlossaryTerm) error {
+    fmt.Fprintf(w, "# Glossary\n")
+    var undefined []GlossaryTerm
+    for _, term := range terms {
+        if term.Definition == "" {
+            undefined = append(undefined, term)
+            continue
+        }
+        if _, err := fmt.Fprintf(w, "\n## %s\n\n%s\n", term.Term, term.Definition); err != nil {
+            return err
+        }
+    }
+
+    if len(undefined) > 0 {
+        fmt.Fprintf(w, "\n## Undefined Terms\n\nThese terms are used but never defined. Move each one to a heading above with its definition.\n\n")
+    }
+    for _, term := range undefined {
+        uses := "uses"
+        if term.Count == 1 {
+            uses = "use"
+        }
+        if _, err := fmt.Fprintf(w, "- %s, %d %s, first in %s\n", term.Term, term.Count, uses, strings.Join(term.Sources, ", ")); err != nil {
+            return err
+        }
+    }
+    return nil
+}
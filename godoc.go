@@ -0,0 +1,171 @@
+// Go doc comments, extracted with the Go parser
+
+package main
+
+import (
+    "go/ast"
+    "go/parser"
+    "go/scanner"
+    "go/token"
+    "regexp"
+    "strings"
+)
+
+var (
+    // goDirectiveRegex matches a //go:generate, //line or //export style
+    // directive, which isn't part of the comment's text
+    goDirectiveRegex = regexp.MustCompile(`^(?:[a-z0-9]+:[a-z0-9]|line |export |extern |nolint)`)
+    // goListItemRegex matches an indented list item of a doc comment, which
+    // unlike other indented lines isn't code
+    goListItemRegex = regexp.MustCompile(`^\s+(?:[-*+•]|\d+[.)])\s`)
+)
+
+// goDocComments parses a Go file and returns the doc comments of its
+// package clause, declarations, struct fields and interface methods, and
+// the line comments after fields and specs, each named by what it
+// documents, such as "Analyzer.AnalyzeFile" or "Issue.Line". These
+// comments are what go doc, gopls and code assistants show.
+func goDocComments(path, content string) ([]embeddedText, error) {
+    fset := token.NewFileSet()
+    file, err := parser.ParseFile(fset, path, content, parser.ParseComments|parser.SkipObjectResolution)
+    if err != nil {
+        return nil, err
+    }
+
+    var comments []embeddedText
+    add := func(coordinate string, groups ...*ast.CommentGroup) {
+        for _, group := range groups {
+            if text := goCommentText(fset, group); len(text.lines) > 0 {
+                text.coordinate = coordinate
+                comments = append(comments, text)
+            }
+        }
+    }
+
+    add("package "+file.Name.Name, file.Doc)
+    for _, decl := range file.Decls {
+        switch decl := decl.(type) {
+        case *ast.FuncDecl:
+            name := decl.Name.Name
+            if decl.Recv != nil && len(decl.Recv.List) > 0 {
+                name = goReceiverType(decl.Recv.List[0].Type) + "." + name
+            }
+            add(name, decl.Doc)
+        case *ast.GenDecl:
+            for i, spec := range decl.Specs {
+                switch spec := spec.(type) {
+                case *ast.TypeSpec:
+                    name := spec.Name.Name
+                    add(name, goSpecDoc(decl, i, spec.Doc), spec.Comment)
+                    var fields *ast.FieldList
+                    switch kind := spec.Type.(type) {
+                    case *ast.StructType:
+                        fields = kind.Fields
+                    case *ast.InterfaceType:
+                        fields = kind.Methods
+                    }
+                    if fields == nil {
+                        continue
+                    }
+                    for _, field := range fields.List {
+                        member := goReceiverType(field.Type) // an embedded type
+                        if len(field.Names) > 0 {
+                            member = field.Names[0].Name
+                        }
+                        add(name+"."+member, field.Doc, field.Comment)
+                    }
+                case *ast.ValueSpec:
+                    add(spec.Names[0].Name, goSpecDoc(decl, i, spec.Doc), spec.Comment)
+                }
+            }
+        }
+    }
+    return comments, nil
+}
+
+// goSpecDoc returns the doc comment of a type, var or const spec: its own,
+// or for the first spec of a group, the group's when it has none
+func goSpecDoc(decl *ast.GenDecl, i int, doc *ast.CommentGroup) *ast.CommentGroup {
+    if doc == nil && i == 0 {
+        return decl.Doc
+    }
+    return doc
+}
+
+// goReceiverType returns the type name of a method receiver or embedded
+// field, without pointers, type parameters and package
+func goReceiverType(expr ast.Expr) string {
+    switch expr := expr.(type) {
+    case *ast.StarExpr:
+        return goReceiverType(expr.X)
+    case *ast.IndexExpr:
+        return goReceiverType(expr.X)
+    case *ast.IndexListExpr:
+        return goReceiverType(expr.X)
+    case *ast.SelectorExpr:
+        return expr.Sel.Name
+    case *ast.Ident:
+        return expr.Name
+    }
+    return ""
+}
+
+// goCommentText returns the lines of a comment group with the position in
+// the file of each. Directives and indented code are left as blank lines,
+// so they still end paragraphs; indented list items are kept.
+func goCommentText(fset *token.FileSet, group *ast.CommentGroup) embeddedText {
+    var text embeddedText
+    if group == nil {
+        return text
+    }
+    for _, comment := range group.List {
+        position := fset.Position(comment.Slash)
+        if strings.HasPrefix(comment.Text, "//") {
+            line, column := comment.Text[2:], position.Column+1
+            switch {
+            case goDirectiveRegex.MatchString(line):
+                line = ""
+            case goListItemRegex.MatchString(line):
+                trimmed := strings.TrimLeft(line, " \t")
+                line, column = trimmed, column+len(line)-len(trimmed)
+            case strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "  "):
+                line, column = line[1:], column+1
+            case strings.TrimSpace(line) != "":
+                line = "" // indented code
+            }
+            text.lines = append(text.lines, line)
+            text.positions = append(text.positions, [2]int{position.Line, column})
+            continue
+        }
+
+        // A /* block */ comment, whose lines after the first start at the
+        // line's beginning
+        for k, line := range strings.Split(strings.TrimSuffix(comment.Text[2:], "*/"), "\n") {
+            column := 0
+            if k == 0 {
+                column = position.Column + 1
+            }
+            text.lines = append(text.lines, strings.TrimSuffix(line, "\r"))
+            text.positions = append(text.positions, [2]int{position.Line + k, column})
+        }
+    }
+    return text.trimmed()
+}
+
+// goParseFailure reports a Go file that doesn't parse, whose doc comments
+// can't be found
+func goParseFailure(path string, err error) Issue {
+    line, column, message := 1, 1, err.Error()
+    if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+        line, column, message = list[0].Pos.Line, list[0].Pos.Column, list[0].Msg
+    }
+    return Issue{
+        File:       path,
+        Line:       line,
+        Column:     column,
+        Rule:       parseFailureRule,
+        Message:    "Go source doesn't parse, so its doc comments aren't checked: " + message,
+        Severity:   failureSeverity,
+        Suggestion: "Fix the syntax error, or leave the file out",
+    }
+}
@@ -0,0 +1,326 @@
+// Description analysis of GraphQL schema (SDL) files
+
+package main
+
+import (
+    "fmt"
+    "path/filepath"
+    "strings"
+)
+
+// graphQLExtensions are the file extensions of GraphQL schemas
+var graphQLExtensions = []string{".graphql", ".graphqls", ".gql"}
+
+// graphQLDefinitions are the keywords that open a type definition
+var graphQLDefinitions = map[string]bool{"type": true, "interface": true, "input": true, "enum": true, "union": true, "scalar": true}
+
+// graphQLRootTypes are the operation types, which explain themselves
+var graphQLRootTypes = map[string]bool{"Query": true, "Mutation": true, "Subscription": true}
+
+// graphQLToken is a lexical token of an SDL file: a name or number, a
+// punctuator, or a string, with its position
+type graphQLToken struct {
+    text   string // the token as written; for strings, including the quotes
+    str    bool
+    block  bool // a """block string"""
+    line   int  // 1-based
+    column int  // 0-based byte offset in the line
+}
+
+// isGraphQLSchema reports whether a path is a GraphQL schema file
+func isGraphQLSchema(path string) bool {
+    ext := strings.ToLower(filepath.Ext(path))
+    for _, graphQL := range graphQLExtensions {
+        if ext == graphQL {
+            return true
+        }
+    }
+    return false
+}
+
+// analyzeGraphQL runs the line, sentence and multiline rules over each
+// description of a GraphQL schema, reporting issues at their position in
+// the file with the element's schema coordinate. The document-level checks
+// are about pages and don't apply. Types and fields without a description
+// are reported as graphql-missing-description, since an assistant answering
+// questions about the API has nothing else to go on.
+func (a *Analyzer) analyzeGraphQL(filePath, content string) []Issue {
+    schema := ParseDocument(filePath, content)
+    described, undescribed := parseGraphQLDescriptions(tokenizeGraphQL(content))
+
+    issues := a.analyzeEmbedded(filePath, described)
+    for _, element := range undescribed {
+        issues = append(issues, Issue{
+            File:         filePath,
+            Line:         element.line,
+            Column:       element.column + 1,
+            Rule:         "graphql-missing-description",
+            Message:      fmt.Sprintf("%s has no description", element.text),
+            Severity:     "suggestion",
+            Suggestion:   "Add a \"\"\"description\"\"\" saying what it is and when to use it, which is what introspection and docs generators show",
+            OriginalText: strings.TrimSpace(schema.Lines[element.line-1]),
+        })
+    }
+
+    issues = a.filterPageTypes(issues, "reference")
+    return a.applyOverrides(a.convertColumns(schema, issues))
+}
+
+// tokenizeGraphQL splits SDL into tokens, dropping whitespace, commas and
+// comments
+func tokenizeGraphQL(content string) []graphQLToken {
+    var tokens []graphQLToken
+    line, lineStart := 1, 0
+    for i := 0; i < len(content); {
+        c := content[i]
+        switch {
+        case c == '\n':
+            line, lineStart = line+1, i+1
+            i++
+        case c == ' ' || c == '\t' || c == '\r' || c == ',':
+            i++
+        case c == '#':
+            for i < len(content) && content[i] != '\n' {
+                i++
+            }
+        case strings.HasPrefix(content[i:], `"""`):
+            start, startLine, startColumn := i, line, i-lineStart
+            i += 3
+            for i < len(content) && !strings.HasPrefix(content[i:], `"""`) {
+                switch {
+                case strings.HasPrefix(content[i:], `\"""`):
+                    i += 4
+                    continue
+                case content[i] == '\n':
+                    line, lineStart = line+1, i+1
+                }
+                i++
+            }
+            i = min(i+3, len(content))
+            tokens = append(tokens, graphQLToken{text: content[start:i], str: true, block: true, line: startLine, column: startColumn})
+        case c == '"':
+            start := i
+            for i++; i < len(content) && content[i] != '"' && content[i] != '\n'; i++ {
+                if content[i] == '\\' {
+                    i++
+                }
+            }
+            i = min(i+1, len(content))
+            tokens = append(tokens, graphQLToken{text: content[start:i], str: true, line: line, column: start - lineStart})
+        case c == '_' || c == '-' || (c >= '0' && c <= '9') || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z'):
+            start := i
+            for i++; i < len(content) && (content[i] == '_' || content[i] == '.' || content[i] == '+' || (content[i] >= '0' && content[i] <= '9') || (content[i] >= 'A' && content[i] <= 'Z') || (content[i] >= 'a' && content[i] <= 'z')); i++ {
+            }
+            tokens = append(tokens, graphQLToken{text: content[start:i], line: line, column: start - lineStart})
+        case strings.HasPrefix(content[i:], "..."):
+            tokens = append(tokens, graphQLToken{text: "...", line: line, column: i - lineStart})
+            i += 3
+        default:
+            tokens = append(tokens, graphQLToken{text: string(c), line: line, column: i - lineStart})
+            i++
+        }
+    }
+    return tokens
+}
+
+// parseGraphQLDescriptions walks the definitions of a schema, returning the
+// descriptions of its types, fields, arguments, enum values and
+// directives, and the types and fields that have none, as tokens whose
+// text is their coordinate
+func parseGraphQLDescriptions(tokens []graphQLToken) ([]embeddedText, []graphQLToken) {
+    var described []embeddedText
+    var undescribed []graphQLToken
+    i := 0
+    peek := func(text string) bool {
+        return i < len(tokens) && !tokens[i].str && tokens[i].text == text
+    }
+    description := func() *graphQLToken {
+        if i < len(tokens) && tokens[i].str {
+            i++
+            return &tokens[i-1]
+        }
+        return nil
+    }
+    record := func(coordinate string, desc *graphQLToken, at graphQLToken, required bool) {
+        switch {
+        case desc != nil:
+            if parsed := graphQLDescriptionText(*desc); len(parsed.lines) > 0 {
+                parsed.coordinate = coordinate
+                described = append(described, parsed)
+            }
+        case required:
+            at.text = coordinate
+            undescribed = append(undescribed, at)
+        }
+    }
+    skipBalanced := func() {
+        depth := 0
+        for ; i < len(tokens); i++ {
+            if tokens[i].str {
+                continue
+            }
+            switch tokens[i].text {
+            case "(", "[", "{":
+                depth++
+            case ")", "]", "}":
+                depth--
+            }
+            if depth == 0 {
+                i++
+                return
+            }
+        }
+    }
+    skipDirectives := func() {
+        for peek("@") {
+            i += 2
+            if peek("(") {
+                skipBalanced()
+            }
+        }
+    }
+    var skipType func()
+    skipType = func() {
+        if peek("[") {
+            i++
+            skipType()
+            if peek("]") {
+                i++
+            }
+        } else if i < len(tokens) {
+            i++
+        }
+        if peek("!") {
+            i++
+        }
+    }
+    skipValue := func() {
+        if peek("[") || peek("{") {
+            skipBalanced()
+        } else if i < len(tokens) {
+            i++
+        }
+    }
+    arguments := func(prefix string) {
+        i++ // (
+        for i < len(tokens) && !peek(")") {
+            desc := description()
+            if i >= len(tokens) {
+                break
+            }
+            name := tokens[i]
+            i++
+            record(fmt.Sprintf("%s(%s:)", prefix, name.text), desc, name, false)
+            if peek(":") {
+                i++
+                skipType()
+            }
+            if peek("=") {
+                i++
+                skipValue()
+            }
+            skipDirectives()
+        }
+        i++ // )
+    }
+
+    for i < len(tokens) {
+        desc := description()
+        if i >= len(tokens) {
+            break
+        }
+        keyword := tokens[i]
+        i++
+        extended := keyword.text == "extend"
+        if extended {
+            desc = nil
+            if i >= len(tokens) {
+                break
+            }
+            keyword = tokens[i]
+            i++
+        }
+        switch {
+        case keyword.text == "schema":
+            skipDirectives()
+            if peek("{") {
+                skipBalanced()
+            }
+        case keyword.text == "directive" && peek("@") && i+1 < len(tokens):
+            name := tokens[i+1]
+            i += 2
+            coordinate := "@" + name.text
+            record(coordinate, desc, name, false)
+            if peek("(") {
+                arguments(coordinate)
+            }
+            // "repeatable on FIELD_DEFINITION | OBJECT"
+            for i < len(tokens) && !tokens[i].str && (tokens[i].text == "repeatable" || tokens[i].text == "on" || tokens[i].text == "|" || tokens[i].text == strings.ToUpper(tokens[i].text)) {
+                i++
+            }
+        case graphQLDefinitions[keyword.text] && i < len(tokens):
+            name := tokens[i]
+            i++
+            record(name.text, desc, keyword, !extended && !graphQLRootTypes[name.text])
+            // implements, directives and union members up to the body
+            for i < len(tokens) && !tokens[i].str && !peek("{") && !graphQLDefinitions[tokens[i].text] && tokens[i].text != "extend" && tokens[i].text != "directive" && tokens[i].text != "schema" {
+                if peek("@") {
+                    skipDirectives()
+                    continue
+                }
+                i++
+            }
+            if !peek("{") {
+                continue
+            }
+            i++
+            for i < len(tokens) && !peek("}") {
+                desc := description()
+                if i >= len(tokens) {
+                    break
+                }
+                member := tokens[i]
+                i++
+                coordinate := name.text + "." + member.text
+                record(coordinate, desc, member, keyword.text != "enum")
+                if keyword.text != "enum" {
+                    if peek("(") {
+                        arguments(coordinate)
+                    }
+                    if peek(":") {
+                        i++
+                        skipType()
+                    }
+                    if peek("=") {
+                        i++
+                        skipValue()
+                    }
+                }
+                skipDirectives()
+            }
+            i++ // }
+        }
+    }
+    return described, undescribed
+}
+
+// graphQLDescriptionText returns the lines of a description string with
+// the position of each in the file. Block strings lose their common
+// indentation and leading and trailing blank lines, as GraphQL specifies.
+func graphQLDescriptionText(token graphQLToken) embeddedText {
+    var description embeddedText
+    if !token.block {
+        text := strings.TrimSuffix(strings.TrimPrefix(token.text, `"`), `"`)
+        if strings.TrimSpace(text) != "" {
+            description.lines = []string{text}
+            description.positions = [][2]int{{token.line, token.column + 1}}
+        }
+        return description
+    }
+
+    description = dedentedText(strings.TrimSuffix(strings.TrimPrefix(token.text, `"""`), `"""`), token.line, token.column+3)
+    for k, line := range description.lines {
+        description.lines[k] = strings.ReplaceAll(line, `\"""`, `"""`)
+    }
+    return description
+}
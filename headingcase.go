@@ -0,0 +1,203 @@
+// Heading case and length
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// HeadingsConfig opts into normalizing heading case and limiting heading
+// length. Headings label chunks and citations, so a consistent, concise
+// heading serves both.
+type HeadingsConfig struct {
+    Case        string   `yaml:"Case,omitempty"`        // "sentence" or "title"; unset, case isn't checked
+    MaxWords    int      `yaml:"MaxWords,omitempty"`    // words beyond which a heading is too long; 0 for no limit
+    ProperNouns []string `yaml:"ProperNouns,omitempty"` // names sentence case keeps capitalized, besides the ones the page capitalizes
+}
+
+// headingCases are the accepted Headings.Case values
+var headingCases = []string{"sentence", "title"}
+
+var (
+    // headingWordRegex matches a word of a heading, letters with inner
+    // apostrophes
+    headingWordRegex = regexp.MustCompile(`\pL[\pL\pN'’]*`)
+    // headingVerbatimRegex matches the parts of a heading whose case isn't
+    // the heading's to change: code spans, link targets, HTML tags and an
+    // explicit {#id}
+    headingVerbatimRegex = regexp.MustCompile("`[^`]*`|\\]\\([^)]*\\)|<[^>]*>|\\{[^}]*\\}\\s*$")
+    // headingBreakRegex matches punctuation after which a heading's next
+    // word starts a new phrase, capitalized in either case
+    headingBreakRegex = regexp.MustCompile(`[:?!—–]\s*$|\s-\s*$`)
+)
+
+// titleCaseMinorWords stay lowercase in title case unless they open or
+// close the heading
+var titleCaseMinorWords = map[string]bool{
+    "a": true, "an": true, "the": true, "and": true, "but": true, "or": true, "nor": true, "for": true,
+    "so": true, "yet": true, "as": true, "at": true, "by": true, "in": true, "of": true,
+    "on": true, "per": true, "to": true, "via": true, "vs": true, "with": true, "from": true, "into": true,
+}
+
+// validate checks that Case is a known heading case
+func (c HeadingsConfig) validate() error {
+    if c.Case != "" && c.Case != "sentence" && c.Case != "title" {
+        return fmt.Errorf("invalid Headings.Case %q (want %s)", c.Case, strings.Join(headingCases, " or "))
+    }
+    if c.MaxWords < 0 {
+        return fmt.Errorf("invalid Headings.MaxWords %d (want 0 or more)", c.MaxWords)
+    }
+    return nil
+}
+
+// analyzeHeadingCase reports headings that aren't in the configured case,
+// with a fix rewriting them, and headings longer than MaxWords. Both are
+// off unless configured. Changing case leaves heading anchors, and so chunk
+// IDs, as they are; the fix isn't safe, since a name the page doesn't
+// capitalize elsewhere would be lowercased.
+func (a *Analyzer) analyzeHeadingCase(doc *Document) []Issue {
+    config := a.config.Headings
+    if config.Case == "" && config.MaxWords == 0 {
+        return nil
+    }
+    var proper map[string]bool
+    if config.Case == "sentence" {
+        proper = doc.properNouns(config.ProperNouns)
+    }
+
+    var issues []Issue
+    for _, section := range doc.Sections {
+        if section.Line == 0 {
+            continue
+        }
+        line, heading := doc.Lines[section.Line-1], section.Heading
+
+        if words := wordCount(plainText(headingVerbatimRegex.ReplaceAllString(heading, " "))); config.MaxWords > 0 && words > config.MaxWords {
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         section.Line,
+                Column:       section.Column,
+                Rule:         "heading-too-long",
+                Message:      fmt.Sprintf("Heading has %d words, more than %d", words, config.MaxWords),
+                Severity:     "suggestion",
+                Suggestion:   "Shorten the heading to its subject and move the detail into the first sentence; chunk labels and citations show the heading whole",
+                OriginalText: strings.TrimSpace(line),
+            })
+        }
+
+        if config.Case == "" {
+            continue
+        }
+        converted := convertHeadingCase(heading, config.Case, proper)
+        if converted == heading {
+            continue
+        }
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         section.Line,
+            Column:       section.Column,
+            Rule:         "heading-case",
+            Message:      fmt.Sprintf("Heading isn't in %s case", config.Case),
+            Severity:     "suggestion",
+            Suggestion:   fmt.Sprintf("Use '%s'", converted),
+            OriginalText: heading,
+            Fix:          &Fix{Line: section.Line, Column: section.Column, Length: len(heading), Replace: converted},
+        })
+    }
+    return issues
+}
+
+// convertHeadingCase rewrites a heading's words in sentence or title case,
+// leaving code spans, link targets and tags alone. Words with capitals
+// after their first letter, such as "GitHub" and "API", keep their case
+// either way; sentence case keeps the proper nouns too.
+func convertHeadingCase(heading, style string, proper map[string]bool) string {
+    verbatim := headingVerbatimRegex.FindAllStringIndex(heading, -1)
+    var words [][]int
+    for _, word := range headingWordRegex.FindAllStringIndex(heading, -1) {
+        inside := false
+        for _, span := range verbatim {
+            inside = inside || (word[0] >= span[0] && word[0] < span[1])
+        }
+        if !inside {
+            words = append(words, word)
+        }
+    }
+
+    var out strings.Builder
+    last := 0
+    for i, span := range words {
+        word := heading[span[0]:span[1]]
+        before := heading[last:span[0]]
+        out.WriteString(before)
+        last = span[1]
+
+        opens := i == 0 || headingBreakRegex.MatchString(heading[words[i-1][1]:span[0]])
+        lower := strings.ToLower(word)
+        switch {
+        case hasInnerCapital(word) || word == "I" || strings.HasPrefix(word, "I'") || strings.HasPrefix(word, "I’"):
+        case opens:
+            word = capitalize(word)
+        case style == "title" && (!titleCaseMinorWords[lower] || i == len(words)-1):
+            word = capitalize(word)
+        case style == "title":
+            word = lower
+        case !proper[lower]:
+            word = lower
+        }
+        out.WriteString(word)
+    }
+    out.WriteString(heading[last:])
+    return out.String()
+}
+
+// hasInnerCapital reports whether a word has a capital after its first
+// letter, as acronyms and names such as "iOS" and "GitHub" do
+func hasInnerCapital(word string) bool {
+    _, size := utf8.DecodeRuneInString(word)
+    return strings.IndexFunc(word[size:], unicode.IsUpper) >= 0
+}
+
+// capitalize upper-cases a word's first letter
+func capitalize(word string) string {
+    r, size := utf8.DecodeRuneInString(word)
+    return string(unicode.ToUpper(r)) + word[size:]
+}
+
+// properNouns returns the lowercased words sentence case keeps
+// capitalized: the configured names, and the words the page's prose only
+// ever writes capitalized in the middle of a sentence
+func (d *Document) properNouns(configured []string) map[string]bool {
+    capitalized, lowercase := make(map[string]bool), make(map[string]bool)
+    for _, paragraph := range d.Paragraphs() {
+        for _, sentence := range paragraph.Sentences() {
+            for i, word := range headingWordRegex.FindAllString(plainText(sentence.Text), -1) {
+                first, _ := utf8.DecodeRuneInString(word)
+                switch {
+                case i == 0:
+                case unicode.IsUpper(first):
+                    capitalized[strings.ToLower(word)] = true
+                default:
+                    lowercase[strings.ToLower(word)] = true
+                }
+            }
+        }
+    }
+
+    proper := make(map[string]bool)
+    for word := range capitalized {
+        if !lowercase[word] {
+            proper[word] = true
+        }
+    }
+    for _, name := range configured {
+        for _, word := range headingWordRegex.FindAllString(name, -1) {
+            proper[strings.ToLower(word)] = true
+        }
+    }
+    return proper
+}
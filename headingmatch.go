@@ -0,0 +1,170 @@
+// Heading-content mismatch check
+
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+)
+
+const (
+    // minMismatchWords is the body length below which a section is too
+    // short to judge against its heading
+    minMismatchWords = 30
+    // minHeadingSimilarity is the embedding similarity below which a
+    // section's body is considered unrelated to its heading
+    minHeadingSimilarity = 0.2
+)
+
+// structuralHeadings name a kind of content rather than a topic
+var structuralHeadings = map[string]bool{
+    "arguments": true, "background": true, "examples": true, "faq": true, "features": true, "next steps": true,
+    "notes": true, "options": true, "parameters": true, "prerequisites": true, "reference": true, "related": true,
+    "requirements": true, "see also": true, "summary": true, "troubleshooting": true, "usage": true,
+}
+
+// mismatchCandidate is a section whose heading can be compared to its body
+type mismatchCandidate struct {
+    section  Section
+    body     string
+    keywords []string
+    lang     string // the document's language, for stopwords and stems
+}
+
+// analyzeHeadingMismatch flags sections whose body has little to do with
+// their heading. Retrieval pipelines embed a chunk together with its
+// heading path, so a misleading heading sends the chunk to the wrong
+// queries. Sections are compared by keyword overlap, or by embedding
+// similarity when -semantic is on.
+func (a *Analyzer) analyzeHeadingMismatch(doc *Document) []Issue {
+    candidates := a.mismatchCandidates(doc)
+    if len(candidates) == 0 {
+        return nil
+    }
+
+    if a.embedder != nil {
+        issues, err := a.semanticMismatches(doc, candidates)
+        if err == nil {
+            return issues
+        }
+        a.embedder.warnFallback(err)
+    }
+
+    var issues []Issue
+    for _, candidate := range candidates {
+        if sharedWords(candidate.keywords, contentWordsIn(candidate.body, candidate.lang), candidate.lang) > 0 {
+            continue
+        }
+        issues = append(issues, mismatchIssue(doc, candidate,
+            fmt.Sprintf("Section body shares no vocabulary with its heading %q", candidate.section.Heading)))
+    }
+    return issues
+}
+
+// mismatchCandidates returns the sections of doc whose heading is worth
+// comparing to their body
+func (a *Analyzer) mismatchCandidates(doc *Document) []mismatchCandidate {
+    var candidates []mismatchCandidate
+    lang := a.language(doc)
+    for _, section := range doc.Sections {
+        // The title's lead paragraph is checked by first-paragraph-context
+        if section.Line == 0 || section.Level == 1 || a.isGenericHeading(section.Heading) || structuralHeadings[strings.ToLower(section.Heading)] {
+            continue
+        }
+        body := doc.prose(section)
+        keywords := contentWordsIn(section.Heading, lang)
+        if len(keywords) == 0 || wordCount(body) < minMismatchWords {
+            continue
+        }
+        candidates = append(candidates, mismatchCandidate{section, body, keywords, lang})
+    }
+    return candidates
+}
+
+// semanticMismatches compares each candidate's heading path to its body by
+// embedding similarity
+func (a *Analyzer) semanticMismatches(doc *Document, candidates []mismatchCandidate) ([]Issue, error) {
+    var texts []string
+    for _, candidate := range candidates {
+        texts = append(texts, candidate.section.Breadcrumb(), candidate.body)
+    }
+    vectors, err := a.embedder.Embed(texts)
+    if err != nil {
+        return nil, err
+    }
+
+    var issues []Issue
+    for i, candidate := range candidates {
+        similarity := cosineSimilarity(vectors[2*i], vectors[2*i+1])
+        if similarity >= minHeadingSimilarity {
+            continue
+        }
+        issues = append(issues, mismatchIssue(doc, candidate,
+            fmt.Sprintf("Section body is unrelated to its heading %q (similarity %.2f)", candidate.section.Heading, similarity)))
+    }
+    return issues, nil
+}
+
+// mismatchIssue reports a mismatched section at its heading, suggesting
+// the body's most frequent terms for a new heading
+func mismatchIssue(doc *Document, candidate mismatchCandidate, message string) Issue {
+    suggestion := "Rename the heading to say what the section covers, or move the content under a heading that does"
+    if terms := topTerms(candidate.body, 3); len(terms) > 0 {
+        suggestion = fmt.Sprintf("Rename the heading to say what the section covers (it mostly discusses: %s), or move the content under a heading that does", strings.Join(terms, ", "))
+    }
+    return Issue{
+        File:         doc.Path,
+        Line:         candidate.section.Line,
+        Column:       1,
+        Rule:         "heading-content-mismatch",
+        Severity:     "warning",
+        Message:      message,
+        Suggestion:   suggestion,
+        OriginalText: candidate.section.Heading,
+    }
+}
+
+// prose returns a section's body as plain text, without code blocks,
+// headings or HTML lines
+func (d *Document) prose(s Section) string {
+    var lines []string
+    for i := s.StartLine - 1; i < s.EndLine && i < len(d.Lines); i++ {
+        trimmed := strings.TrimSpace(d.Lines[i])
+        if trimmed != "" && !d.Fenced[i] && !atxHeadingRegex.MatchString(trimmed) && !strings.HasPrefix(trimmed, "<") {
+            lines = append(lines, trimmed)
+        }
+    }
+    return plainText(strings.Join(lines, " "))
+}
+
+// sharedWords counts the keywords that occur in words, allowing for the
+// inflections of lang: "configure" matches "configuration", "tokens"
+// matches "token"
+func sharedWords(keywords, words []string, lang string) int {
+    stems := make(map[string]bool)
+    for _, word := range words {
+        stems[stemIn(word, lang)] = true
+    }
+    shared := 0
+    for _, keyword := range keywords {
+        if stems[stemIn(keyword, lang)] {
+            shared++
+        }
+    }
+    return shared
+}
+
+// topTerms returns the n content words that occur most often in text
+func topTerms(text string, n int) []string {
+    counts := make(map[string]int)
+    for _, word := range contentWords(text) {
+        counts[word]++
+    }
+    terms := sortedKeys(counts)
+    sort.SliceStable(terms, func(i, j int) bool { return counts[terms[i]] > counts[terms[j]] })
+    if len(terms) > n {
+        terms = terms[:n]
+    }
+    return terms
+}
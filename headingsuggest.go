@@ -0,0 +1,130 @@
+// Specific replacements for generic headings
+
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "unicode"
+)
+
+// genericHeadingForms turn each generic heading into a specific one about
+// a subject
+var genericHeadingForms = map[string]string{
+    "overview":        "%s overview",
+    "introduction":    "Introduction to %s",
+    "getting started": "Get started with %s",
+    "configuration":   "Configure %s",
+    "setup":           "Set up %s",
+    "installation":    "Install %s",
+}
+
+// suggestHeading proposes a specific heading for the generic heading at
+// lineNum, such as "Configure Acme Gateway TLS certificates" for
+// "Configuration": the document's product followed by what the section's
+// body is about. It returns "" when neither is known.
+func (a *Analyzer) suggestHeading(doc *Document, lineNum int) string {
+    section, ok := doc.SectionAt(lineNum)
+    if !ok || section.Line != lineNum {
+        return ""
+    }
+    lang := a.language(doc)
+    product := a.documentProduct(doc)
+    if product == a.inferProductName(nil) {
+        product = ""
+    }
+    heading := plainText(section.Heading)
+    form, ok := genericHeadingForms[strings.ToLower(heading)]
+    if !ok {
+        form = heading + " for %s"
+    }
+
+    // Terms the heading would already say
+    said := make(map[string]bool)
+    for _, word := range contentWordsIn(heading+" "+product+" "+form, lang) {
+        said[stemIn(word, lang)] = true
+    }
+    subject := strings.TrimSpace(product + " " + strings.Join(headingTerms(doc.prose(section), lang, said), " "))
+    if subject == "" {
+        return ""
+    }
+    suggestion := []rune(fmt.Sprintf(form, subject))
+    suggestion[0] = unicode.ToUpper(suggestion[0])
+    return string(suggestion)
+}
+
+// headingTerms returns the one or two terms that best say what text is
+// about, as the text writes them: its most frequent pair of adjacent
+// content words if a pair repeats, else its two most frequent repeated
+// content words in the order they first occur. Words whose stem is in
+// said are left out.
+func headingTerms(text, lang string, said map[string]bool) []string {
+    counts := make(map[string]int)
+    pairs := make(map[string]int)
+    forms := make(map[string]map[string]int)
+    first := make(map[string]int)
+    previous := ""
+    for i, written := range titleWordRegex.FindAllString(text, -1) {
+        word := strings.ToLower(written)
+        if len([]rune(word)) <= 2 || unicode.IsDigit([]rune(word)[0]) || isStopwordIn(word, lang) || said[stemIn(word, lang)] {
+            previous = ""
+            continue
+        }
+        if forms[word] == nil {
+            forms[word] = make(map[string]int)
+            first[word] = i
+        }
+        counts[word]++
+        forms[word][written]++
+        if previous != "" {
+            pairs[previous+" "+word]++
+        }
+        previous = word
+    }
+
+    var terms []string
+    if pair := mostFrequent(pairs); pair != "" && pairs[pair] > 1 {
+        terms = strings.Fields(pair)
+    } else {
+        for _, word := range sortedKeys(counts) {
+            if counts[word] > 1 {
+                terms = append(terms, word)
+            }
+        }
+        sort.SliceStable(terms, func(i, j int) bool { return counts[terms[i]] > counts[terms[j]] })
+        if len(terms) > 2 {
+            terms = terms[:2]
+        }
+        sort.Slice(terms, func(i, j int) bool { return first[terms[i]] < first[terms[j]] })
+    }
+    for i, term := range terms {
+        terms[i] = writtenForm(term, forms[term])
+    }
+    return terms
+}
+
+// mostFrequent returns the key with the highest count, the first in order
+// among ties, or "" for none
+func mostFrequent(counts map[string]int) string {
+    best := ""
+    for _, key := range sortedKeys(counts) {
+        if best == "" || counts[key] > counts[best] {
+            best = key
+        }
+    }
+    return best
+}
+
+// writtenForm returns how text most often writes a word, such as "TLS" for
+// "tls", preferring lowercase among ties so a sentence-initial capital
+// doesn't stick
+func writtenForm(word string, forms map[string]int) string {
+    best := word
+    for _, form := range sortedKeys(forms) {
+        if forms[form] > forms[best] {
+            best = form
+        }
+    }
+    return best
+}
@@ -0,0 +1,163 @@
+// Help center (knowledge base) input
+
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "net/http"
+    "net/url"
+    "os"
+    "strings"
+    "time"
+)
+
+// HelpCenterSource fetches the published articles of a Zendesk Guide or
+// Intercom Articles help center
+type HelpCenterSource struct {
+    Provider string // "zendesk" or "intercom"
+    BaseURL  string // Zendesk: https://<subdomain>.zendesk.com; Intercom: API URL, https://api.intercom.io by default
+    Locale   string // Zendesk only; empty for every locale
+    User     string // Zendesk agent email for API token auth; empty for anonymous access to public articles
+    Token    string
+
+    client *http.Client
+}
+
+// runHelpCenter implements the helpcenter subcommand
+func runHelpCenter(args []string) int {
+    flags := flag.NewFlagSet("helpcenter", flag.ExitOnError)
+    common := addSourceFlags(flags)
+    provider := flags.String("provider", "", "Help center provider (zendesk, intercom)")
+    baseURL := flags.String("url", "", "Zendesk help center URL, e.g. https://acme.zendesk.com (Intercom: API URL, default https://api.intercom.io)")
+    locale := flags.String("locale", "", "Zendesk locale to fetch, e.g. en-us (default: all locales)")
+    user := flags.String("user", os.Getenv("HELPCENTER_USER"), "Zendesk agent email for API token authentication")
+    flags.Parse(args)
+
+    source := &HelpCenterSource{Provider: *provider, BaseURL: strings.TrimSuffix(*baseURL, "/"), Locale: *locale, User: *user, Token: os.Getenv("HELPCENTER_TOKEN")}
+    if source.Provider == "intercom" && source.BaseURL == "" {
+        source.BaseURL = "https://api.intercom.io"
+    }
+    valid := (source.Provider == "zendesk" && source.BaseURL != "") || (source.Provider == "intercom" && source.Token != "")
+    if !valid {
+        fmt.Fprintf(os.Stderr, "Usage: %s helpcenter -provider zendesk -url <help_center_url> [options]\n", os.Args[0])
+        fmt.Fprintf(os.Stderr, "       HELPCENTER_TOKEN=... %s helpcenter -provider intercom [options]\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+    common.location = source.BaseURL
+    if source.Locale != "" {
+        common.location += "/hc/" + source.Locale
+    }
+    return common.run(source.Fetch)
+}
+
+// Fetch returns every published article, converted to Markdown. Issues
+// name an article by its title and ID and carry its public URL.
+func (s *HelpCenterSource) Fetch() ([]SourceDocument, error) {
+    s.client = &http.Client{Timeout: 60 * time.Second}
+    if s.Provider == "intercom" {
+        return s.fetchIntercom()
+    }
+    return s.fetchZendesk()
+}
+
+func (s *HelpCenterSource) fetchZendesk() ([]SourceDocument, error) {
+    next := s.BaseURL + "/api/v2/help_center/articles.json?per_page=100"
+    if s.Locale != "" {
+        next = s.BaseURL + "/api/v2/help_center/" + url.PathEscape(s.Locale) + "/articles.json?per_page=100"
+    }
+
+    var docs []SourceDocument
+    for next != "" {
+        var page struct {
+            Articles []struct {
+                ID      int64  `json:"id"`
+                Title   string `json:"title"`
+                Body    string `json:"body"`
+                HTMLURL string `json:"html_url"`
+                Draft   bool   `json:"draft"`
+            } `json:"articles"`
+            NextPage string `json:"next_page"`
+        }
+        if err := s.get(next, &page); err != nil {
+            return nil, err
+        }
+        for _, article := range page.Articles {
+            if !article.Draft {
+                docs = append(docs, helpCenterDocument(article.Title, fmt.Sprint(article.ID), article.HTMLURL, article.Body))
+            }
+        }
+        next = page.NextPage
+    }
+    return docs, nil
+}
+
+func (s *HelpCenterSource) fetchIntercom() ([]SourceDocument, error) {
+    var docs []SourceDocument
+    for number := 1; ; number++ {
+        var page struct {
+            Data []struct {
+                ID    string `json:"id"`
+                Title string `json:"title"`
+                Body  string `json:"body"`
+                URL   string `json:"url"`
+                State string `json:"state"`
+            } `json:"data"`
+            Pages struct {
+                TotalPages int `json:"total_pages"`
+            } `json:"pages"`
+        }
+        if err := s.get(fmt.Sprintf("%s/articles?per_page=50&page=%d", s.BaseURL, number), &page); err != nil {
+            return nil, err
+        }
+        for _, article := range page.Data {
+            if article.State == "published" {
+                docs = append(docs, helpCenterDocument(article.Title, article.ID, article.URL, article.Body))
+            }
+        }
+        if number >= page.Pages.TotalPages {
+            break
+        }
+    }
+    return docs, nil
+}
+
+// helpCenterDocument builds the document for one article body
+func helpCenterDocument(title, id, link, body string) SourceDocument {
+    return SourceDocument{
+        Path:    fmt.Sprintf("%s [%s]", title, id),
+        URL:     link,
+        Content: "# " + title + "\n\n" + htmlToMarkdown(body),
+    }
+}
+
+// get requests a page of results from the provider's API and decodes it
+func (s *HelpCenterSource) get(target string, result any) error {
+    request, err := http.NewRequest(http.MethodGet, target, nil)
+    if err != nil {
+        return err
+    }
+    request.Header.Set("Accept", "application/json")
+    switch {
+    case s.Provider == "intercom":
+        request.Header.Set("Authorization", "Bearer "+s.Token)
+        request.Header.Set("Intercom-Version", "2.10")
+    case s.User != "" && s.Token != "":
+        request.SetBasicAuth(s.User+"/token", s.Token)
+    }
+
+    response, err := s.client.Do(request)
+    if err != nil {
+        return err
+    }
+    defer response.Body.Close()
+    if response.StatusCode != http.StatusOK {
+        return fmt.Errorf("%s returned %s", target, response.Status)
+    }
+    if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+        return fmt.Errorf("failed to decode %s response: %w", s.Provider, err)
+    }
+    return nil
+}
@@ -0,0 +1,154 @@
+// HTML-to-text conversion losses in HTML sources
+
+package main
+
+import (
+    "fmt"
+    "html"
+    "regexp"
+    "strings"
+)
+
+var (
+    // htmlSkippedRegex matches markup whose text no converter keeps
+    htmlSkippedRegex = regexp.MustCompile(`(?is)<!--.*?-->|<(script|style|head|noscript|template)\b.*?</(?:script|style|head|noscript|template)\s*>`)
+    styleBlockRegex  = regexp.MustCompile(`(?is)<style\b[^>]*>(.*?)</style\s*>`)
+    // cssContentRegex matches a ::before or ::after rule's content string
+    cssContentRegex  = regexp.MustCompile(`(?is)::?(?:before|after)\s*\{[^}]*?\bcontent\s*:\s*(?:"([^"]*)"|'([^']*)')`)
+    cssEscapeRegex   = regexp.MustCompile(`\\[0-9a-fA-F]{1,6}\s?`)
+    hiddenStyleRegex = regexp.MustCompile(`(?i)\b(?:display\s*:\s*none|visibility\s*:\s*hidden)\b`)
+    hiddenAttrRegex  = regexp.MustCompile(`(?i)\bhidden\b`)
+    mergedCellRegex  = regexp.MustCompile(`(?i)\b(?:colspan|rowspan)\s*=\s*["']?0*(?:[2-9]|[1-9]\d)`)
+    nestedTableRegex = regexp.MustCompile(`(?i)<table\b`)
+    headerCellRegex  = regexp.MustCompile(`(?i)<th\b`)
+    tableRowRegex    = regexp.MustCompile(`(?i)<tr\b`)
+    htmlWordRegex    = regexp.MustCompile(`\pL{2,}`)
+)
+
+// htmlVoidTags have no content or closing tag
+var htmlVoidTags = map[string]bool{
+    "area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+    "input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// analyzeHTMLConversion flags content in HTML sources that a typical
+// HTML-to-text ingestion step drops or mangles, since the converted text
+// is all a retrieval pipeline sees: text hidden from assistive technology
+// or by inline styles, text that exists only in CSS generated content, and
+// tables that flatten into rows without their column names.
+func (a *Analyzer) analyzeHTMLConversion(doc *Document) []Issue {
+    if doc.Format != "html" {
+        return nil
+    }
+    page := doc.Content
+
+    var issues []Issue
+    position := func(offset int) (int, int) {
+        return strings.Count(page[:offset], "\n") + 1, offset - (strings.LastIndex(page[:offset], "\n") + 1) + 1
+    }
+    report := func(offset int, rule, message, suggestion, text string) {
+        line, column := position(offset)
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         line,
+            Column:       column,
+            Rule:         rule,
+            Message:      message,
+            Severity:     "warning",
+            Suggestion:   suggestion,
+            OriginalText: text,
+        })
+    }
+
+    for _, block := range styleBlockRegex.FindAllStringSubmatchIndex(page, -1) {
+        css := page[block[2]:block[3]]
+        for _, match := range cssContentRegex.FindAllStringSubmatchIndex(css, -1) {
+            start, end := match[2], match[3]
+            if start < 0 {
+                start, end = match[4], match[5]
+            }
+            text := strings.TrimSpace(cssEscapeRegex.ReplaceAllString(css[start:end], ""))
+            if !htmlWordRegex.MatchString(text) {
+                continue // icons and quote marks
+            }
+            report(block[2]+start, "html-css-content",
+                fmt.Sprintf("Text %q exists only in CSS generated content and is lost in HTML-to-text conversion", text),
+                "Put the text in the HTML itself; converters don't apply stylesheets",
+                text)
+        }
+    }
+
+    // Blank out what converters skip, keeping offsets and line breaks
+    masked := []byte(page)
+    for _, span := range htmlSkippedRegex.FindAllStringIndex(page, -1) {
+        for i := span[0]; i < span[1]; i++ {
+            if masked[i] != '\n' {
+                masked[i] = ' '
+            }
+        }
+    }
+    visible := string(masked)
+
+    hiddenEnd, tableEnd := 0, 0
+    for _, loc := range htmlTokenRegex.FindAllStringSubmatchIndex(visible, -1) {
+        if loc[3] > loc[2] {
+            continue // closing tag
+        }
+        tag := strings.ToLower(visible[loc[4]:loc[5]])
+        rawAttrs := visible[loc[6]:loc[7]]
+        attrs := htmlAttributes(rawAttrs)
+
+        if loc[0] >= hiddenEnd && !htmlVoidTags[tag] {
+            how := ""
+            switch {
+            case strings.EqualFold(attrs["aria-hidden"], "true"):
+                how = "aria-hidden"
+            case hiddenAttrRegex.MatchString(htmlAttrRegex.ReplaceAllString(rawAttrs, "")):
+                how = "the hidden attribute"
+            case hiddenStyleRegex.MatchString(attrs["style"]):
+                how = strings.ToLower(hiddenStyleRegex.FindString(attrs["style"]))
+            }
+            if how != "" {
+                end := elementEnd(visible, loc[1], tag)
+                text := strings.TrimSpace(whitespaceRegex.ReplaceAllString(html.UnescapeString(htmlTagRegex.ReplaceAllString(visible[loc[1]:end], " ")), " "))
+                if htmlWordRegex.MatchString(text) {
+                    hiddenEnd = end
+                    suggestion := "Show the text, or repeat it in visible content if readers and assistants need it"
+                    if how == "aria-hidden" {
+                        suggestion = "Remove aria-hidden from elements that carry information, or repeat the text in accessible content"
+                    }
+                    report(loc[0], "html-hidden-content",
+                        fmt.Sprintf("Text hidden with %s is dropped, or kept without its context, by HTML-to-text conversion", how),
+                        suggestion,
+                        strings.TrimSpace(visible[loc[0]:loc[1]]))
+                }
+            }
+        }
+
+        if tag == "table" && loc[0] >= tableEnd {
+            end := elementEnd(visible, loc[1], tag)
+            tableEnd = end
+            if role := strings.ToLower(attrs["role"]); role == "presentation" || role == "none" {
+                continue // layout table
+            }
+            table := visible[loc[1]:end]
+            var problems []string
+            if nestedTableRegex.MatchString(table) {
+                problems = append(problems, "nested tables")
+            }
+            if mergedCellRegex.MatchString(table) {
+                problems = append(problems, "merged cells")
+            }
+            if !headerCellRegex.MatchString(table) && len(tableRowRegex.FindAllStringIndex(table, 2)) > 1 {
+                problems = append(problems, "no header cells")
+            }
+            if len(problems) > 0 {
+                report(loc[0], "html-table-flattening",
+                    fmt.Sprintf("Table flattens badly to text: %s", strings.Join(problems, ", ")),
+                    "Mark the header row with <th> cells, and split tables with merged or nested cells into simple ones",
+                    strings.TrimSpace(visible[loc[0]:loc[1]]))
+            }
+        }
+    }
+    return issues
+}
@@ -0,0 +1,189 @@
+// Converting fetched HTML pages to Markdown for analysis
+
+package main
+
+import (
+    "html"
+    "regexp"
+    "strings"
+)
+
+var (
+    htmlTokenRegex     = regexp.MustCompile(`<(/?)([a-zA-Z][\w:-]*)((?:"[^"]*"|'[^']*'|[^'">])*)>`)
+    htmlAttrRegex      = regexp.MustCompile(`([\w:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+    htmlDroppedRegex   = regexp.MustCompile(`(?is)<!--.*?-->|<(script|style|head|noscript|template|svg)\b.*?</(?:script|style|head|noscript|template|svg)\s*>`)
+    cdataRegex         = regexp.MustCompile(`(?s)<!\[CDATA\[(.*?)\]\]>`)
+    confluenceCode     = regexp.MustCompile(`(?is)<ac:structured-macro\b[^>]*ac:name="(?:code|noformat)"[^>]*>(.*?)</ac:structured-macro>`)
+    confluenceLanguage = regexp.MustCompile(`(?is)<ac:parameter\b[^>]*ac:name="language"[^>]*>([^<]*)</ac:parameter>`)
+    confluenceBody     = regexp.MustCompile(`(?is)<ac:plain-text-body>(.*?)</ac:plain-text-body>`)
+    blankLinesRegex    = regexp.MustCompile(`\n[ \t]*\n(?:[ \t]*\n)+`)
+    whitespaceRegex    = regexp.MustCompile(`\s+`)
+)
+
+// paragraphTags end the current paragraph
+var paragraphTags = map[string]bool{
+    "p": true, "div": true, "section": true, "article": true, "main": true, "header": true, "footer": true,
+    "nav": true, "aside": true, "blockquote": true, "dl": true, "dt": true, "dd": true,
+    "figure": true, "figcaption": true, "hr": true, "form": true, "details": true, "summary": true,
+}
+
+// htmlToMarkdown renders an HTML page, including Confluence storage
+// format, as the Markdown the checks understand: headings, paragraphs,
+// lists, links, images, inline code and fenced code blocks. Other markup
+// is dropped.
+func htmlToMarkdown(page string) string {
+    page = htmlDroppedRegex.ReplaceAllString(page, "")
+    page = confluenceCode.ReplaceAllStringFunc(page, func(macro string) string {
+        language := ""
+        if match := confluenceLanguage.FindStringSubmatch(macro); match != nil {
+            language = strings.TrimSpace(match[1])
+        }
+        code := ""
+        if match := confluenceBody.FindStringSubmatch(macro); match != nil {
+            code = cdataRegex.ReplaceAllString(match[1], "$1")
+        }
+        return "<pre data-language=\"" + html.EscapeString(language) + "\">" + html.EscapeString(code) + "</pre>"
+    })
+    page = cdataRegex.ReplaceAllStringFunc(page, func(cdata string) string {
+        return html.EscapeString(cdataRegex.FindStringSubmatch(cdata)[1])
+    })
+
+    var out []byte
+    var lists []string // enclosing list tags, innermost last
+    var links []string // hrefs of open links
+    rows, cells := 0, 0 // rows finished in the current table, cells begun in the current row
+    text := func(s string) {
+        s = whitespaceRegex.ReplaceAllString(html.UnescapeString(s), " ")
+        if len(out) == 0 || out[len(out)-1] == '\n' || out[len(out)-1] == ' ' {
+            s = strings.TrimLeft(s, " ")
+        }
+        out = append(out, s...)
+    }
+    trim := func(cutset string) {
+        for len(out) > 0 && strings.IndexByte(cutset, out[len(out)-1]) >= 0 {
+            out = out[:len(out)-1]
+        }
+    }
+    block := func() {
+        trim(" \n")
+        if len(out) > 0 {
+            out = append(out, "\n\n"...)
+        }
+    }
+
+    position := 0
+    for position < len(page) {
+        loc := htmlTokenRegex.FindStringSubmatchIndex(page[position:])
+        if loc == nil {
+            text(page[position:])
+            break
+        }
+        text(page[position : position+loc[0]])
+        closing := page[position+loc[2]:position+loc[3]] == "/"
+        tag := strings.ToLower(page[position+loc[4] : position+loc[5]])
+        attrs := htmlAttributes(page[position+loc[6] : position+loc[7]])
+        position += loc[1]
+
+        switch {
+        case tag == "pre" && !closing:
+            end := strings.Index(strings.ToLower(page[position:]), "</pre")
+            if end < 0 {
+                end = len(page) - position
+            }
+            code := html.UnescapeString(htmlTagRegex.ReplaceAllString(page[position:position+end], ""))
+            block()
+            out = append(out, "```"+attrs["data-language"]+"\n"+strings.Trim(code, "\n")+"\n```\n\n"...)
+            position += end
+            if next := strings.Index(page[position:], ">"); next >= 0 {
+                position += next + 1
+            }
+        case len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6':
+            block()
+            if !closing {
+                out = append(out, strings.Repeat("#", int(tag[1]-'0'))+" "...)
+            }
+        case tag == "ul" || tag == "ol":
+            if !closing {
+                if len(lists) == 0 {
+                    block()
+                }
+                lists = append(lists, tag)
+            } else if len(lists) > 0 {
+                lists = lists[:len(lists)-1]
+                if len(lists) == 0 {
+                    block()
+                }
+            }
+        case tag == "li" && !closing:
+            marker := "- "
+            if len(lists) > 0 && lists[len(lists)-1] == "ol" {
+                marker = "1. "
+            }
+            trim(" ")
+            if len(out) > 0 && out[len(out)-1] != '\n' {
+                out = append(out, '\n')
+            }
+            out = append(out, strings.Repeat("  ", max(len(lists)-1, 0))+marker...)
+        case tag == "br":
+            trim(" ")
+            out = append(out, '\n')
+        case tag == "table":
+            block()
+            rows = 0
+        case tag == "tr" && !closing:
+            trim(" ")
+            if len(out) > 0 && out[len(out)-1] != '\n' {
+                out = append(out, '\n')
+            }
+            cells = 0
+        case tag == "tr":
+            out = append(out, " |"...)
+            if rows++; rows == 1 {
+                out = append(out, "\n|"+strings.Repeat(" --- |", cells)...)
+            }
+        case (tag == "td" || tag == "th") && !closing:
+            trim(" ")
+            if cells++; cells == 1 {
+                out = append(out, "| "...)
+            } else {
+                out = append(out, " | "...)
+            }
+        case tag == "a" && !closing:
+            links = append(links, attrs["href"])
+            out = append(out, '[')
+        case tag == "a" && len(links) > 0:
+            out = append(out, "]("+links[len(links)-1]+")"...)
+            links = links[:len(links)-1]
+        case tag == "img" || tag == "ri:attachment" && attrs["ri:filename"] != "":
+            src := attrs["src"]
+            if src == "" {
+                src = attrs["ri:filename"]
+            }
+            out = append(out, "!["+attrs["alt"]+"]("+src+")"...)
+        case tag == "code":
+            out = append(out, '`')
+        case tag == "strong" || tag == "b":
+            out = append(out, "**"...)
+        case tag == "em" || tag == "i":
+            out = append(out, '*')
+        case paragraphTags[tag]:
+            block()
+        }
+    }
+
+    markdown := blankLinesRegex.ReplaceAllString(string(out), "\n\n")
+    lines := strings.Split(markdown, "\n")
+    for i, line := range lines {
+        lines[i] = strings.TrimRight(line, " ")
+    }
+    return strings.TrimSpace(strings.Join(lines, "\n")) + "\n"
+}
+
+// htmlAttributes parses a tag's attributes, lowercasing their names
+func htmlAttributes(s string) map[string]string {
+    attrs := make(map[string]string)
+    for _, match := range htmlAttrRegex.FindAllStringSubmatch(s, -1) {
+        attrs[strings.ToLower(match[1])] = html.UnescapeString(match[2] + match[3] + match[4])
+    }
+    return attrs
+}
@@ -0,0 +1,291 @@
+// Include and transclusion resolution
+
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// IncludesConfig turns on resolving include directives before analysis
+type IncludesConfig struct {
+    Syntaxes []string `yaml:"Syntaxes,omitempty"` // "liquid", "mkdocs", "rst", "asciidoc"; none resolves nothing
+    Paths    []string `yaml:"Paths,omitempty"`    // directories searched after the including file's own, such as _includes
+
+    root string // directory includes must stay within: the configuration's, or the working directory
+}
+
+// maxIncludeDepth bounds nested includes
+const maxIncludeDepth = 10
+
+var (
+    // includeSyntaxes match a line holding one include directive and
+    // capture the included path
+    includeSyntaxes = map[string]*regexp.Regexp{
+        "liquid":   regexp.MustCompile(`^\s*\{%-?\s*include(?:_relative)?\s+["']?([^"'\s%]+)["']?[^%]*-?%\}\s*$`),
+        "mkdocs":   regexp.MustCompile(`^\s*-+8<-+\s+["']([^"']+)["']\s*$`),
+        "rst":      regexp.MustCompile(`^\s*\.\.\s+include::\s*(\S+)\s*$`),
+        "asciidoc": regexp.MustCompile(`^include::([^\[]+)\[[^\]]*\]\s*$`),
+    }
+    // snippetBlockRegex opens and closes a block of MkDocs snippet paths
+    snippetBlockRegex = regexp.MustCompile(`^\s*-+8<-+\s*$`)
+    // snippetRangeRegex matches a MkDocs line range: "file.md:3:10"
+    snippetRangeRegex = regexp.MustCompile(`^(.+?):(\d*)(?::(\d*))?$`)
+    // rstOptionRegex matches an option line under an RST directive
+    rstOptionRegex = regexp.MustCompile(`^\s+:[\w-]+:`)
+)
+
+// lineSource is where a line of resolved content came from
+type lineSource struct {
+    file string
+    line int // 1-based
+}
+
+// resolvedContent is a document with its includes expanded, and the origin
+// of each of its lines
+type resolvedContent struct {
+    lines   []string
+    sources []lineSource
+}
+
+// enabled reports whether any include syntax is resolved
+func (c IncludesConfig) enabled() bool {
+    return len(c.Syntaxes) > 0
+}
+
+// validate checks that every syntax is known
+func (c IncludesConfig) validate() error {
+    for _, syntax := range c.Syntaxes {
+        if includeSyntaxes[syntax] == nil {
+            return fmt.Errorf("invalid include syntax %q (want liquid, mkdocs, rst or asciidoc)", syntax)
+        }
+    }
+    return nil
+}
+
+// resolve expands the configured include directives in content,
+// recursively. Includes that can't be read are left in place with a
+// warning.
+func (c IncludesConfig) resolve(file, content string) resolvedContent {
+    var resolved resolvedContent
+    c.expand(file, strings.Split(content, "\n"), 1, &resolved, []string{filepath.Clean(file)})
+    return resolved
+}
+
+// expand appends lines, the lines of file from firstLine on, to resolved,
+// replacing each include directive with the lines of the file it names
+func (c IncludesConfig) expand(file string, lines []string, firstLine int, resolved *resolvedContent, stack []string) {
+    keep := func(i int) {
+        resolved.lines = append(resolved.lines, lines[i])
+        resolved.sources = append(resolved.sources, lineSource{file, firstLine + i})
+    }
+
+    for i := 0; i < len(lines); i++ {
+        targets, skip := c.directive(lines, i)
+        if len(targets) == 0 {
+            keep(i)
+            continue
+        }
+
+        var included [][]string
+        var names []string
+        var starts []int
+        failed := false
+        for _, target := range targets {
+            name, start, end := splitSnippetRange(target)
+            path, content, err := c.read(file, name)
+            if err == nil {
+                for _, ancestor := range stack {
+                    if ancestor == path {
+                        err = fmt.Errorf("include cycle through %s", path)
+                    }
+                }
+            }
+            if err == nil && len(stack) > maxIncludeDepth {
+                err = fmt.Errorf("includes nest deeper than %d levels", maxIncludeDepth)
+            }
+            if err != nil {
+                reportFailure(includeFailureRule, file, firstLine+i, "not including %s: %v", name, err)
+                failed = true
+                break
+            }
+            fragment := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+            if end <= 0 || end > len(fragment) {
+                end = len(fragment)
+            }
+            if start < 1 {
+                start = 1
+            }
+            if start > end {
+                start, end = 1, 0
+            }
+            included = append(included, fragment[start-1:end])
+            names = append(names, path)
+            starts = append(starts, start)
+        }
+        if failed {
+            for j := i; j <= i+skip; j++ {
+                keep(j)
+            }
+            i += skip
+            continue
+        }
+
+        for j, fragment := range included {
+            c.expand(names[j], fragment, starts[j], resolved, append(stack, names[j]))
+        }
+        i += skip
+    }
+}
+
+// directive returns the paths an include directive on line i names, and how
+// many lines after it (a MkDocs snippet block, RST options) belong to it
+func (c IncludesConfig) directive(lines []string, i int) ([]string, int) {
+    for _, syntax := range c.Syntaxes {
+        if syntax == "mkdocs" && snippetBlockRegex.MatchString(lines[i]) {
+            var targets []string
+            for j := i + 1; j < len(lines); j++ {
+                if snippetBlockRegex.MatchString(lines[j]) {
+                    return targets, j - i
+                }
+                if target := strings.TrimSpace(lines[j]); target != "" && !strings.HasPrefix(target, ";") {
+                    targets = append(targets, target)
+                }
+            }
+            return nil, 0
+        }
+
+        match := includeSyntaxes[syntax].FindStringSubmatch(lines[i])
+        if match == nil {
+            continue
+        }
+        skip := 0
+        if syntax == "rst" {
+            for i+skip+1 < len(lines) && rstOptionRegex.MatchString(lines[i+skip+1]) {
+                skip++
+            }
+        }
+        return []string{match[1]}, skip
+    }
+    return nil, 0
+}
+
+// splitSnippetRange splits a MkDocs "file.md:3:10" target into its path
+// and 1-based line range; zero means unbounded
+func splitSnippetRange(target string) (string, int, int) {
+    match := snippetRangeRegex.FindStringSubmatch(target)
+    if match == nil || (match[2] == "" && match[3] == "") {
+        return target, 0, 0
+    }
+    start, _ := strconv.Atoi(match[2])
+    end, _ := strconv.Atoi(match[3])
+    if match[3] == "" && !strings.HasSuffix(target, ":") {
+        end = start
+    }
+    return match[1], start, end
+}
+
+// read finds an included file next to the including file, then in the
+// configured Paths, then in the working directory, and returns its path and
+// decoded content. A file outside the root, by an absolute or ../ path or
+// through a symlink, isn't read.
+func (c IncludesConfig) read(from, name string) (string, string, error) {
+    candidates := []string{filepath.Join(filepath.Dir(from), name)}
+    for _, dir := range c.Paths {
+        candidates = append(candidates, filepath.Join(dir, name))
+    }
+    candidates = append(candidates, name)
+    if filepath.IsAbs(name) {
+        candidates = []string{name}
+    }
+
+    for _, path := range candidates {
+        if info, err := os.Stat(path); err != nil || info.IsDir() {
+            continue
+        }
+        if !c.contains(path) {
+            return "", "", fmt.Errorf("%s is outside %s", path, c.root)
+        }
+        content, err := readDocument(path)
+        if err != nil {
+            return "", "", err
+        }
+        return filepath.Clean(path), content, nil
+    }
+    return "", "", fmt.Errorf("file not found")
+}
+
+// contains reports whether path, with symlinks resolved, lies within the
+// root includes may read from
+func (c IncludesConfig) contains(path string) bool {
+    root := c.root
+    if root == "" {
+        root = "."
+    }
+    resolve := func(p string) (string, error) {
+        abs, err := filepath.Abs(p)
+        if err != nil {
+            return "", err
+        }
+        return filepath.EvalSymlinks(abs)
+    }
+    base, err := resolve(root)
+    if err != nil {
+        return false
+    }
+    target, err := resolve(path)
+    if err != nil {
+        return false
+    }
+    rel, err := filepath.Rel(base, target)
+    return err == nil && filepath.IsLocal(rel)
+}
+
+// attribute maps issues found in resolved content back to the line of the
+// file each came from. A fix that would land in a different file than its
+// issue is dropped.
+func (r resolvedContent) attribute(issues []Issue) []Issue {
+    for i := range issues {
+        issue := &issues[i]
+        if issue.Line < 1 || issue.Line > len(r.sources) {
+            continue
+        }
+        source := r.sources[issue.Line-1]
+        issue.File, issue.Line = source.file, source.line
+        if issue.Fix != nil {
+            fix := *issue.Fix
+            if fix.Line >= 1 && fix.Line <= len(r.sources) && r.sources[fix.Line-1].file == source.file {
+                fix.Line = r.sources[fix.Line-1].line
+                issue.Fix = &fix
+            } else {
+                issue.Fix = nil
+            }
+        }
+    }
+    return issues
+}
+
+// dedupeIssues drops repeats of an issue, as when a fragment included by
+// several pages is reported once per page
+func dedupeIssues(issues []Issue) []Issue {
+    type key struct {
+        file          string
+        line, column  int
+        rule, message string
+    }
+    seen := make(map[key]bool)
+    var unique []Issue
+    for _, issue := range issues {
+        k := key{issue.File, issue.Line, issue.Column, issue.Rule, issue.Message}
+        if seen[k] {
+            continue
+        }
+        seen[k] = true
+        unique = append(unique, issue)
+    }
+    return unique
+}
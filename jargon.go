@@ -0,0 +1,306 @@
+// Jargon density and undefined-term detection
+
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "unicode"
+
+    "gopkg.in/yaml.v3"
+)
+
+const (
+    // defaultMaxJargonDensity is the share of a section's words that may be
+    // undefined terms
+    defaultMaxJargonDensity = 0.03
+    // minJargonWords is the section length below which density is too noisy
+    minJargonWords = 20
+)
+
+var (
+    termWordRegex = regexp.MustCompile(`[\p{L}][\p{L}\p{N}]*(?:-[\p{L}\p{N}]+)*`)
+    // "Security Assertion Markup Language (SAML)" and "SAML (Security ...)"
+    parentheticalRegex = regexp.MustCompile(`([\p{L}][\p{L}\p{N}-]*)\s*\(([^()]{2,80})\)`)
+    // "OAuth is an open standard", "mTLS means", "a JWT, a signed token"
+    copulaRegex = regexp.MustCompile(`([\p{L}][\p{L}\p{N}-]*)(?:\s*,\s*(?:a|an|the)\s|\s+(?:is|are)\s+(?:a|an|the)\s|\s+(?:means|refers\s+to|stands\s+for)\s)`)
+    // "**SAML**: ...", "- SAML: ...", "SAML — ..."
+    glossaryEntryRegex = regexp.MustCompile(`^\s*(?:[-*+]\s+)?(?:\*\*|__)?([^*_:|]{1,60}?)(?:\*\*|__)?\s*(?::|\s[—–]\s)\s*\S`)
+    // the acronyms and initialisms readers of technical docs already know,
+    // and words commonly written in capitals
+    knownTerms = []string{
+        "AI", "API", "APIs", "CDN", "CI", "CLI", "CPU", "CSS", "CSV", "DNS", "FAQ", "GPU", "HTML", "HTTP", "HTTPS",
+        "ID", "IDs", "IDE", "IP", "JSON", "OK", "OS", "PDF", "PNG", "PR", "RAM", "SDK", "SDKs", "SQL", "SSH", "TCP",
+        "UI", "URL", "URLs", "USB", "UTC", "UTF", "VS", "XML", "YAML",
+        "AND", "OR", "NOT", "TRUE", "FALSE", "NULL", "GET", "POST", "PUT", "PATCH", "DELETE", "HEAD",
+        "NOTE", "TIP", "INFO", "WARNING", "CAUTION", "DANGER", "IMPORTANT", "ERROR", "DEBUG", "TODO",
+    }
+)
+
+// JargonConfig tunes undefined-term detection. Terms are acronyms and
+// mixed-case words such as OAuth; a term is defined by a glossary, or by
+// the document through "Expansion (TERM)", "TERM (expansion)", "TERM is
+// a ...", or a "TERM: ..." glossary entry.
+type JargonConfig struct {
+    Glossary   []string `yaml:"Glossary,omitempty"`   // Markdown, text or YAML glossaries
+    Known      []string `yaml:"Known,omitempty"`      // terms readers are assumed to know
+    MaxDensity float64  `yaml:"MaxDensity,omitempty"` // undefined term occurrences per word, 0.03 by default
+}
+
+// loadGlossary returns the lowercased terms the glossaries define,
+// together with the known terms
+func loadGlossary(config JargonConfig) (map[string]bool, error) {
+    terms := make(map[string]bool)
+    for _, term := range append(append([]string(nil), knownTerms...), config.Known...) {
+        terms[strings.ToLower(term)] = true
+    }
+    for _, file := range config.Glossary {
+        data, err := os.ReadFile(file)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read glossary: %w", err)
+        }
+        ext := strings.ToLower(filepath.Ext(file))
+        if ext == ".yml" || ext == ".yaml" {
+            var glossary any
+            if err := yaml.Unmarshal(data, &glossary); err != nil {
+                return nil, fmt.Errorf("failed to parse glossary %s: %w", file, err)
+            }
+            collectGlossaryTerms(glossary, terms)
+            continue
+        }
+        for term := range definedTerms(ParseDocument(file, decodeText(data))) {
+            terms[term] = true
+        }
+        for _, line := range strings.Split(string(data), "\n") {
+            if match := atxHeadingRegex.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+                terms[strings.ToLower(plainText(strings.TrimSpace(strings.TrimLeft(match[0], "#"))))] = true
+            }
+        }
+    }
+    return terms, nil
+}
+
+// collectGlossaryTerms adds the terms of a YAML glossary: the keys of a
+// mapping, the strings of a list, or the Term or Name of list entries,
+// looking inside a top-level Terms or Glossary key
+func collectGlossaryTerms(node any, terms map[string]bool) {
+    switch node := node.(type) {
+    case map[string]any:
+        for key, value := range node {
+            lower := strings.ToLower(key)
+            _, isList := value.([]any)
+            _, isMap := value.(map[string]any)
+            switch {
+            case (lower == "terms" || lower == "glossary") && (isList || isMap):
+                collectGlossaryTerms(value, terms)
+            case lower == "term" || lower == "name":
+                if term, ok := value.(string); ok {
+                    terms[strings.ToLower(term)] = true
+                }
+            default:
+                terms[lower] = true
+            }
+        }
+    case []any:
+        for _, item := range node {
+            if term, ok := item.(string); ok {
+                terms[strings.ToLower(term)] = true
+            } else if entry, ok := item.(map[string]any); ok {
+                // A generated glossary leaves Definition empty for a term
+                // that was used but never defined
+                if definition, ok := entry["Definition"]; ok && definition == "" {
+                    continue
+                }
+                for _, key := range []string{"Term", "term", "Name", "name"} {
+                    if term, ok := entry[key].(string); ok {
+                        terms[strings.ToLower(term)] = true
+                        break
+                    }
+                }
+            }
+        }
+    }
+}
+
+// definition is a term a document defines, as written there
+type definition struct {
+    term, text string
+    line       int  // 1-based
+    entry      bool // a glossary-style entry, which marks a term of any shape
+}
+
+// definedTerms returns the lowercased terms a document defines
+func definedTerms(doc *Document) map[string]bool {
+    defined := make(map[string]bool)
+    for _, def := range documentDefinitions(doc) {
+        defined[strings.ToLower(def.term)] = true
+    }
+    return defined
+}
+
+// documentDefinitions returns the definitions in a document's prose, with
+// the defining text: the expansion of an acronym, a "TERM is a ..."
+// sentence, or the body of a glossary entry
+func documentDefinitions(doc *Document) []definition {
+    var defs []definition
+    for i, line := range doc.Lines {
+        if doc.Fenced[i] {
+            continue
+        }
+        for _, match := range parentheticalRegex.FindAllStringSubmatchIndex(line, -1) {
+            word, inner := line[match[2]:match[3]], strings.TrimSpace(line[match[4]:match[5]])
+            if isJargonTerm(inner) {
+                // Expansion (TERM)
+                defs = append(defs, definition{term: inner, text: expansionBefore(line[:match[3]], inner), line: i + 1})
+            } else if isJargonTerm(word) && len(strings.Fields(inner)) >= 2 {
+                // TERM (expansion)
+                defs = append(defs, definition{term: word, text: plainText(inner), line: i + 1})
+            }
+        }
+        for _, match := range copulaRegex.FindAllStringSubmatchIndex(line, -1) {
+            sentence := line[match[2]:]
+            if end := strings.Index(sentence, ". "); end >= 0 {
+                sentence = sentence[:end+1]
+            }
+            defs = append(defs, definition{term: line[match[2]:match[3]], text: plainText(sentence), line: i + 1})
+        }
+        if match := glossaryEntryRegex.FindStringSubmatchIndex(line); match != nil && !atxHeadingRegex.MatchString(strings.TrimSpace(line)) {
+            body := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line[match[3]:]), "*_:—–"))
+            bold := strings.Contains(line[:match[3]], "**") || strings.Contains(line[:match[3]], "__")
+            defs = append(defs, definition{term: strings.TrimSpace(line[match[2]:match[3]]), text: plainText(body), line: i + 1, entry: bold})
+        }
+        // Markdown definition lists put the term on the line before ": "
+        if i > 0 && strings.HasPrefix(line, ": ") {
+            defs = append(defs, definition{term: plainText(strings.TrimSpace(doc.Lines[i-1])), text: plainText(line[2:]), line: i, entry: true})
+        }
+    }
+    return defs
+}
+
+// expansionBefore returns the words before "(TERM)" that spell it out:
+// back to the capitalized word that starts it, as in "System for
+// Cross-domain Identity Management (SCIM)", or one word per capital of the
+// term when the expansion is lowercase, as in "identity provider (IdP)"
+func expansionBefore(text, term string) string {
+    capitals := 0
+    for _, r := range term {
+        if unicode.IsUpper(r) {
+            capitals++
+        }
+    }
+    capitals = max(capitals, 1)
+    words := strings.Fields(plainText(text))
+
+    start, capitalized := len(words)-min(capitals, len(words)), 0
+    for i := len(words) - 1; i >= 0 && len(words)-i <= 2*capitals; i-- {
+        if first := []rune(words[i])[0]; unicode.IsUpper(first) {
+            capitalized++
+            if capitalized == capitals {
+                start = i
+                break
+            }
+        }
+    }
+    return strings.Trim(strings.Join(words[start:], " "), ",;:")
+}
+
+// isJargonTerm reports whether word looks like domain jargon: an acronym
+// of two or more capitals, or a mixed-case word such as OAuth or mTLS
+func isJargonTerm(word string) bool {
+    if !termWordRegex.MatchString(word) || termWordRegex.FindString(word) != word {
+        return false
+    }
+    capitals := 0
+    for _, r := range word {
+        if unicode.IsUpper(r) {
+            capitals++
+        }
+    }
+    return (acronymRegex.MatchString(word) && capitals >= 2) || mixedCaseRegex.MatchString(word)
+}
+
+// jargonIn counts the jargon terms in a section's prose, outside code,
+// URLs and headings
+func (d *Document) jargonIn(section Section) map[string]int {
+    counts := make(map[string]int)
+    for i := section.StartLine - 1; i < section.EndLine && i < len(d.Masked); i++ {
+        if d.Fenced[i] || i < d.BodyStart-1 || atxHeadingRegex.MatchString(strings.TrimSpace(d.Lines[i])) {
+            continue
+        }
+        line := blankMatches(urlRegex, blankMatches(inlineCodeRegex, d.Masked[i]))
+        for _, word := range termWordRegex.FindAllString(line, -1) {
+            if isJargonTerm(word) {
+                counts[word]++
+            }
+        }
+    }
+    return counts
+}
+
+// analyzeJargon flags sections dense with terms that neither the document
+// nor the glossary defines. A retrieved chunk full of unexplained acronyms
+// is hard for a model, and a reader, to use.
+func (a *Analyzer) analyzeJargon(doc *Document) []Issue {
+    maxDensity := a.config.Jargon.MaxDensity
+    if maxDensity == 0 {
+        maxDensity = defaultMaxJargonDensity
+    }
+    for _, file := range a.config.Jargon.Glossary {
+        if filepath.Clean(file) == filepath.Clean(doc.Path) {
+            return nil
+        }
+    }
+
+    defined := definedTerms(doc)
+    // Mixed-case words the document repeats are usually its product names
+    for _, product := range a.extractProductNames(doc.Content) {
+        if !acronymRegex.MatchString(product) {
+            defined[strings.ToLower(product)] = true
+        }
+    }
+
+    var issues []Issue
+    for _, section := range doc.Sections {
+        words := wordCount(doc.prose(section))
+        if words < minJargonWords {
+            continue
+        }
+
+        counts := make(map[string]int)
+        occurrences := 0
+        for term, count := range doc.jargonIn(section) {
+            lower := strings.ToLower(term)
+            if !defined[lower] && !a.glossary[lower] && !a.glossary[strings.TrimSuffix(lower, "s")] {
+                counts[term] = count
+                occurrences += count
+            }
+        }
+
+        density := float64(occurrences) / float64(words)
+        if len(counts) < 2 || density <= maxDensity {
+            continue
+        }
+        terms := sortedKeys(counts)
+        sort.SliceStable(terms, func(i, j int) bool { return counts[terms[i]] > counts[terms[j]] })
+
+        line, heading := section.Line, section.Heading
+        if line == 0 {
+            line = max(section.StartLine, doc.BodyStart)
+        }
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         line,
+            Column:       1,
+            Rule:         "undefined-jargon",
+            Severity:     "suggestion",
+            Message:      fmt.Sprintf("Section uses %d undefined terms (jargon density %.1f per 100 words)", len(counts), density*100),
+            Suggestion:   fmt.Sprintf("Define these terms on first use or add them to the glossary: %s", strings.Join(terms, ", ")),
+            OriginalText: heading,
+        })
+    }
+    return issues
+}
@@ -0,0 +1,356 @@
+// Localization parity: compare translated doc trees with their source
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "math"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "unicode"
+)
+
+const (
+    // defaultParityRatio is the share of its source's length below which a
+    // translated section or file is reported as drastically shorter
+    defaultParityRatio = 0.5
+    // minParityLength is the length, in non-space characters, a source
+    // section needs before its translation's length is compared, since
+    // short sections vary too much between languages
+    minParityLength = 200
+)
+
+// runL10nParity implements the l10n-parity subcommand
+func runL10nParity(args []string) int {
+    flags := flag.NewFlagSet("l10n-parity", flag.ExitOnError)
+    configPath := flags.String("config", "", "Path to configuration file, for Severities, Overrides and Formatters")
+    outputFormat := flags.String("output", "standard", "Output format (standard, short, json, sections, sarif, codequality, or a configured or external formatter)")
+    minRatio := flags.Float64("min-ratio", defaultParityRatio, "Report translated sections and files shorter than this share of their source")
+    flags.Parse(args)
+
+    if flags.NArg() < 2 {
+        fmt.Fprintf(os.Stderr, "Usage: %s l10n-parity [options] <source_dir> <translation_dir>...\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+
+    failures = newFailureLog()
+    analyzer, err := NewAnalyzer(*configPath)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error creating analyzer: %v\n", err)
+        return 1
+    }
+    formatter, err := formatterFor(*outputFormat, analyzer.config.Formatters)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+
+    run := newRunMetadata("l10n-parity", analyzer, flags.Args())
+    source, err := loadTree(flags.Arg(0), analyzer.config.Formats)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+    files := treeFiles(source)
+    var issues []Issue
+    for _, root := range flags.Args()[1:] {
+        translation, err := loadTree(root, analyzer.config.Formats)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return 1
+        }
+        files = append(files, treeFiles(translation)...)
+        issues = append(issues, compareTranslation(source, translation, root, *minRatio)...)
+    }
+    issues = append(analyzer.applyOverrides(issues), failures.drain()...)
+    sortIssues(issues)
+    analyzer.config.linkRuleDocs(issues)
+    run.countFiles(files, issues)
+    run.finish()
+    currentRun = run
+
+    if err := formatter.Format(os.Stdout, issues); err != nil {
+        fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+        return 1
+    }
+    if analyzer.severities.fails(issues) {
+        return 1
+    }
+    return 0
+}
+
+// loadTree parses every supported file under root, with formats, keyed by
+// its path relative to root
+func loadTree(root string, formats map[string]Format) (map[string]*Document, error) {
+    files, err := collectFiles(root, WalkOptions{Recursive: true, Formats: formats})
+    if err != nil {
+        return nil, err
+    }
+    tree := make(map[string]*Document)
+    for _, doc := range loadDocuments(files, formats) {
+        rel, err := filepath.Rel(root, doc.Path)
+        if err != nil {
+            rel = doc.Path
+        }
+        tree[filepath.ToSlash(rel)] = doc
+    }
+    return tree, nil
+}
+
+// treeFiles returns the paths of a tree's documents
+func treeFiles(tree map[string]*Document) []string {
+    var files []string
+    for _, rel := range sortedKeys(tree) {
+        files = append(files, tree[rel].Path)
+    }
+    return files
+}
+
+// compareTranslation reports the source files a translation tree lacks,
+// the source sections a translated file lacks, and translated sections
+// and files drastically shorter than their source.
+//
+// Headings are translated, so sections are aligned by the outline (see
+// alignOutlines), where sections that both carry an explicit {#id} must
+// also share it. Lengths are compared as
+// shares of their file, so a language that needs fewer characters, such
+// as Japanese, isn't reported as short throughout; a file's own length
+// is compared with the median of the tree for the same reason.
+func compareTranslation(source, translation map[string]*Document, root string, minRatio float64) []Issue {
+    var issues []Issue
+    language := filepath.Base(root)
+
+    var ratios []float64
+    for _, rel := range sortedKeys(source) {
+        if translated, ok := translation[rel]; ok && textLength(source[rel].Content) > 0 {
+            ratios = append(ratios, float64(textLength(translated.Content))/float64(textLength(source[rel].Content)))
+        }
+    }
+    sort.Float64s(ratios)
+
+    for _, rel := range sortedKeys(source) {
+        original := source[rel]
+        translated, ok := translation[rel]
+        if !ok {
+            issues = append(issues, Issue{
+                File:       original.Path,
+                Line:       1,
+                Column:     1,
+                Rule:       "missing-translation",
+                Message:    fmt.Sprintf("Page has no %s translation (expected %s)", language, filepath.Join(root, filepath.FromSlash(rel))),
+                Severity:   "warning",
+                Suggestion: "Translate the page, or link readers of this language to the source, so retrieval in every language finds an answer",
+            })
+            continue
+        }
+        issues = append(issues, compareSections(original, translated, language, minRatio)...)
+
+        sourceLength, translatedLength := textLength(original.Content), textLength(translated.Content)
+        if len(ratios) >= 3 && sourceLength >= minParityLength {
+            median := ratios[len(ratios)/2]
+            if ratio := float64(translatedLength) / float64(sourceLength); ratio < minRatio*median {
+                issues = append(issues, Issue{
+                    File:       translated.Path,
+                    Line:       1,
+                    Column:     1,
+                    Rule:       "short-translation",
+                    Message:    fmt.Sprintf("Translation is %.0f%% of the source's length, against %.0f%% for a typical %s page", ratio*100, median*100, language),
+                    Severity:   "warning",
+                    Suggestion: fmt.Sprintf("Compare with %s and translate what's missing", original.Path),
+                })
+            }
+        }
+    }
+    return issues
+}
+
+// compareSections aligns the headed sections of a source file and its
+// translation and reports the source sections without a counterpart and
+// the translated sections drastically shorter than theirs
+func compareSections(original, translated *Document, language string, minRatio float64) []Issue {
+    from, to := headedSections(original), headedSections(translated)
+    matches := alignOutlines(original, translated, from, to)
+
+    var issues []Issue
+    sourceTotal, translatedTotal := textLength(original.Content), textLength(translated.Content)
+    for i, section := range from {
+        j, ok := matches[i]
+        if !ok {
+            issues = append(issues, Issue{
+                File:         original.Path,
+                Line:         section.Line,
+                Column:       1,
+                Rule:         "missing-translated-section",
+                Message:      fmt.Sprintf("Section %q has no counterpart in the %s translation %s", section.Breadcrumb(), language, translated.Path),
+                Severity:     "warning",
+                Suggestion:   "Translate the section, keeping the heading structure of the source",
+                OriginalText: section.Heading,
+            })
+            continue
+        }
+
+        sourceLength := textLength(original.Body(section))
+        if sourceLength < minParityLength || sourceTotal == 0 || translatedTotal == 0 {
+            continue
+        }
+        sourceShare := float64(sourceLength) / float64(sourceTotal)
+        translatedShare := float64(textLength(translated.Body(to[j]))) / float64(translatedTotal)
+        if ratio := translatedShare / sourceShare; ratio < minRatio {
+            issues = append(issues, Issue{
+                File:         translated.Path,
+                Line:         to[j].Line,
+                Column:       1,
+                Rule:         "short-translation",
+                Message:      fmt.Sprintf("Section is %.0f%% as long as its source %q, relative to the length of each page", ratio*100, section.Breadcrumb()),
+                Severity:     "warning",
+                Suggestion:   fmt.Sprintf("Compare with %s:%d and translate what's missing", original.Path, section.Line),
+                OriginalText: to[j].Heading,
+            })
+        }
+    }
+    return issues
+}
+
+// headedSections returns a document's sections that have a heading
+func headedSections(doc *Document) []Section {
+    var sections []Section
+    for _, section := range doc.Sections {
+        if section.Level > 0 {
+            sections = append(sections, section)
+        }
+    }
+    return sections
+}
+
+// alignOutlines matches the headed sections of a source file and its
+// translation, returning the index in the translation of each matched
+// source section. It keeps the most matches of sections at the same heading
+// level, in order, and among alignments with as many, the one whose
+// matched sections are most alike: in the share of their page they take
+// up, and in the text translation leaves alone, such as code, numbers and
+// URLs. So a missing section is reported where it is missing rather than
+// at the end.
+func alignOutlines(original, translated *Document, from, to []Section) map[int]int {
+    share := func(doc *Document, section Section) float64 {
+        if total := textLength(doc.Content); total > 0 {
+            return float64(textLength(doc.Body(section))) / float64(total)
+        }
+        return 0
+    }
+    fromTokens, toTokens := make([]map[string]bool, len(from)), make([]map[string]bool, len(to))
+    for i, section := range from {
+        fromTokens[i] = invariantTokens(original.Body(section))
+    }
+    for j, section := range to {
+        toTokens[j] = invariantTokens(translated.Body(section))
+    }
+
+    // weight scores a match from 1 to 1.5, so an extra match always
+    // outweighs better similarity
+    weight := func(i, j int) float64 {
+        a, b := from[i], to[j]
+        if a.Level != b.Level {
+            return 0
+        }
+        idA, idB := customAnchorRegex.FindStringSubmatch(a.Heading), customAnchorRegex.FindStringSubmatch(b.Heading)
+        if idA != nil && idB != nil && idA[1] != idB[1] {
+            return 0
+        }
+        similarity := 1.0
+        if shareA, shareB := share(original, a), share(translated, b); shareA+shareB > 0 {
+            similarity = 1 - math.Abs(shareA-shareB)/(shareA+shareB)
+        }
+        if len(fromTokens[i])+len(toTokens[j]) > 0 {
+            similarity = (similarity + jaccard(fromTokens[i], toTokens[j])) / 2
+        }
+        return 1 + 0.5*similarity
+    }
+
+    // best[i][j] is the best score aligning from[i:] with to[j:]
+    best := make([][]float64, len(from)+1)
+    for i := range best {
+        best[i] = make([]float64, len(to)+1)
+    }
+    for i := len(from) - 1; i >= 0; i-- {
+        for j := len(to) - 1; j >= 0; j-- {
+            best[i][j] = max(best[i+1][j], best[i][j+1])
+            if w := weight(i, j); w > 0 {
+                best[i][j] = max(best[i][j], best[i+1][j+1]+w)
+            }
+        }
+    }
+
+    matches := make(map[int]int)
+    for i, j := 0, 0; i < len(from) && j < len(to); {
+        switch w := weight(i, j); {
+        case w > 0 && best[i][j] == best[i+1][j+1]+w:
+            matches[i] = j
+            i++
+            j++
+        case best[i][j] == best[i+1][j]:
+            i++
+        default:
+            j++
+        }
+    }
+    return matches
+}
+
+// invariantTokenRegex matches text that translation leaves alone: code
+// spans, URLs and numbers
+var invariantTokenRegex = regexp.MustCompile("`[^`]+`|https?://\\S+|\\d+(?:[.,]\\d+)*")
+
+// invariantTokens returns the code spans, URLs, numbers and code block
+// lines of a section
+func invariantTokens(body string) map[string]bool {
+    tokens := make(map[string]bool)
+    inFence := false
+    for _, line := range strings.Split(body, "\n") {
+        trimmed := strings.TrimSpace(line)
+        if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+            inFence = !inFence
+            continue
+        }
+        if inFence {
+            if trimmed != "" {
+                tokens[trimmed] = true
+            }
+            continue
+        }
+        for _, token := range invariantTokenRegex.FindAllString(line, -1) {
+            tokens[token] = true
+        }
+    }
+    return tokens
+}
+
+// jaccard returns the overlap of two sets, from 0 to 1
+func jaccard(a, b map[string]bool) float64 {
+    shared := 0
+    for token := range a {
+        if b[token] {
+            shared++
+        }
+    }
+    if union := len(a) + len(b) - shared; union > 0 {
+        return float64(shared) / float64(union)
+    }
+    return 0
+}
+
+// textLength counts the non-space characters of text, which compares
+// better across scripts than words do
+func textLength(text string) int {
+    n := 0
+    for _, r := range text {
+        if !unicode.IsSpace(r) {
+            n++
+        }
+    }
+    return n
+}
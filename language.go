@@ -0,0 +1,229 @@
+// Document language detection and localized rule packs
+
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "unicode"
+
+    "gopkg.in/yaml.v3"
+)
+
+var (
+    htmlLangRegex     = regexp.MustCompile(`(?i)<html\b[^>]*\blang\s*=\s*["']?([\w-]+)`)
+    languageCodeRegex = regexp.MustCompile(`^[a-z]{2}(?:[-_][A-Za-z]{2,4})?$`)
+    wordRegex         = regexp.MustCompile(`\p{L}+`)
+)
+
+// fallbackLanguage is the language assumed for undetected documents and
+// for rules that don't list their languages
+const fallbackLanguage = "en"
+
+// stopwords are high-frequency function words used to guess the language
+// of documents that don't declare one
+var stopwords = map[string][]string{
+    "en": {"the", "and", "is", "to", "of", "you", "with", "for", "this", "that", "are", "your"},
+    "fr": {"le", "la", "les", "et", "est", "des", "une", "pour", "vous", "avec", "dans", "sur"},
+    "de": {"der", "die", "das", "und", "ist", "nicht", "mit", "sie", "für", "ein", "eine", "auf"},
+    "es": {"el", "los", "las", "y", "es", "para", "con", "una", "por", "que", "del", "en"},
+    "it": {"il", "di", "che", "per", "una", "con", "non", "sono", "della", "gli", "è", "nel"},
+    "pt": {"os", "que", "para", "com", "uma", "não", "você", "do", "da", "em", "é", "no"},
+    "nl": {"het", "een", "en", "van", "je", "niet", "met", "voor", "op", "zijn", "te", "dat"},
+}
+
+// Language returns the document's primary language subtag, from front
+// matter, the <html lang> attribute, a language path segment ("docs/fr/",
+// "page.fr.md"), or its text, in that order. It returns "" when none is
+// conclusive.
+func (d *Document) Language() string {
+    if _, value, ok := lookupField(d.FrontMatter, "lang|language|locale"); ok {
+        if code, ok := value.(string); ok && languageCodeRegex.MatchString(code) {
+            return primaryLanguage(code)
+        }
+    }
+    if match := htmlLangRegex.FindStringSubmatch(d.Content); match != nil {
+        return primaryLanguage(match[1])
+    }
+
+    parts := strings.Split(filepath.ToSlash(d.Path), "/")
+    name := parts[len(parts)-1]
+    if infix := strings.Split(name, "."); len(infix) >= 3 && languageCodeRegex.MatchString(infix[len(infix)-2]) {
+        return primaryLanguage(infix[len(infix)-2])
+    }
+    for _, segment := range parts[:len(parts)-1] {
+        if languageCodeRegex.MatchString(segment) {
+            if _, known := stopwords[primaryLanguage(segment)]; known {
+                return primaryLanguage(segment)
+            }
+        }
+    }
+
+    return d.guessLanguage()
+}
+
+// primaryLanguage reduces a language tag such as "pt-BR" to "pt", and a
+// tag with no subtag at all, such as "-", to fallbackLanguage
+func primaryLanguage(tag string) string {
+    subtags := strings.FieldsFunc(tag, func(r rune) bool { return r == '-' || r == '_' })
+    if len(subtags) == 0 {
+        return fallbackLanguage
+    }
+    return strings.ToLower(subtags[0])
+}
+
+// guessLanguage identifies CJK text by script and other text by counting
+// stopwords, returning "" when the text is too short or ambiguous
+func (d *Document) guessLanguage() string {
+    var text strings.Builder
+    for i := d.BodyStart - 1; i < len(d.Masked); i++ {
+        if !d.Fenced[i] {
+            text.WriteString(plainText(d.Masked[i]))
+            text.WriteString(" ")
+        }
+    }
+
+    letters, han, kana, hangul := 0, 0, 0, 0
+    for _, r := range text.String() {
+        switch {
+        case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+            kana++
+        case unicode.Is(unicode.Han, r):
+            han++
+        case unicode.Is(unicode.Hangul, r):
+            hangul++
+        case unicode.IsLetter(r):
+            letters++
+        }
+    }
+    if cjk := han + kana + hangul; cjk*10 > (cjk+letters)*3 {
+        switch {
+        case hangul >= han && hangul >= kana:
+            return "ko"
+        case kana > 0:
+            return "ja"
+        default:
+            return "zh"
+        }
+    }
+
+    counts := make(map[string]int)
+    words := 0
+    for _, word := range wordRegex.FindAllString(strings.ToLower(text.String()), -1) {
+        words++
+        for lang, list := range stopwords {
+            if contains(list, word) {
+                counts[lang]++
+            }
+        }
+    }
+    if words < 20 {
+        return ""
+    }
+
+    best, second := "", 0
+    for _, lang := range sortedLanguages() {
+        if counts[lang] > counts[best] {
+            second = counts[best]
+            best = lang
+        } else if counts[lang] > second {
+            second = counts[lang]
+        }
+    }
+    if best == "" || counts[best] < 3 || counts[best] < second*3/2 {
+        return ""
+    }
+    return best
+}
+
+// sortedLanguages returns the stopword languages in a fixed order
+func sortedLanguages() []string {
+    var langs []string
+    for lang := range stopwords {
+        langs = append(langs, lang)
+    }
+    sort.Strings(langs)
+    return langs
+}
+
+// defaultLanguage returns the configured Language, or English
+func (a *Analyzer) defaultLanguage() string {
+    if a.config.Language != "" {
+        return primaryLanguage(a.config.Language)
+    }
+    return fallbackLanguage
+}
+
+// language returns the language rules are selected for: the detected one,
+// or the configured default
+func (a *Analyzer) language(doc *Document) string {
+    if lang := doc.Language(); lang != "" {
+        return lang
+    }
+    return a.defaultLanguage()
+}
+
+// appliesToLanguage reports whether the rule runs on documents in lang.
+// Rules without Languages are written for the configured default language;
+// "*" matches every language.
+func (r Rule) appliesToLanguage(lang, fallback string) bool {
+    if len(r.Languages) == 0 {
+        return lang == fallback
+    }
+    for _, l := range r.Languages {
+        if l == "*" || primaryLanguage(l) == lang {
+            return true
+        }
+    }
+    return false
+}
+
+// loadRulePacks reads localized rules from StylesPath/<lang>/*.yml. Each
+// file holds a Rules list in the config format; rules that don't name
+// their languages get the directory's.
+func loadRulePacks(stylesPath string) ([]Rule, error) {
+    if stylesPath == "" {
+        return nil, nil
+    }
+    dirs, err := os.ReadDir(stylesPath)
+    if os.IsNotExist(err) {
+        return nil, nil
+    } else if err != nil {
+        return nil, fmt.Errorf("failed to read StylesPath: %w", err)
+    }
+
+    var rules []Rule
+    for _, dir := range dirs {
+        if !dir.IsDir() || !languageCodeRegex.MatchString(dir.Name()) {
+            continue
+        }
+        files, _ := filepath.Glob(filepath.Join(stylesPath, dir.Name(), "*.y*ml"))
+        sort.Strings(files)
+        for _, file := range files {
+            data, err := os.ReadFile(file)
+            if err != nil {
+                return nil, fmt.Errorf("failed to read rule pack %s: %w", file, err)
+            }
+            var pack struct {
+                Rules []Rule `yaml:"Rules"`
+            }
+            if err := yaml.Unmarshal(data, &pack); err != nil {
+                return nil, fmt.Errorf("failed to parse rule pack %s: %w", file, err)
+            }
+            setRuleLines(pack.Rules, data)
+            for _, rule := range pack.Rules {
+                if len(rule.Languages) == 0 {
+                    rule.Languages = []string{dir.Name()}
+                }
+                rule.source = file
+                rules = append(rules, rule)
+            }
+        }
+    }
+
+    return rules, nil
+}
@@ -0,0 +1,77 @@
+// First-paragraph context check
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var (
+    selfReferenceRegex = regexp.MustCompile(`(?i)^(?:this|the following|in this)\s+(?:page|document|doc|article|guide|section|topic|tutorial)\b`)
+    titleWordRegex     = regexp.MustCompile(`[\p{L}\p{N}][\p{L}\p{N}-]*`)
+)
+
+// analyzeLeadParagraph checks that the paragraph following the H1 names the
+// product or feature and what the page covers. The lead paragraph is the
+// highest-value chunk for retrieval, so "This page describes how to do this"
+// wastes it.
+func (a *Analyzer) analyzeLeadParagraph(doc *Document) []Issue {
+    var title *Section
+    for i := range doc.Sections {
+        if doc.Sections[i].Level == 1 {
+            title = &doc.Sections[i]
+            break
+        }
+    }
+    if title == nil {
+        return nil
+    }
+
+    paragraph, line := doc.paragraphIn(title.StartLine, title.EndLine)
+    issue := Issue{
+        File:         doc.Path,
+        Line:         line,
+        Column:       1,
+        Rule:         "first-paragraph-context",
+        Severity:     "warning",
+        OriginalText: paragraph,
+    }
+
+    switch {
+    case paragraph == "":
+        issue.Line = title.Line
+        issue.Message = "Page has no lead paragraph after the title"
+        issue.Suggestion = fmt.Sprintf("Add an opening paragraph stating what %s is and what this page covers", title.Heading)
+    case !a.namesSubject(paragraph, title.Heading, doc.Content, a.language(doc)):
+        issue.Message = "Lead paragraph doesn't name the product or feature the page is about"
+        if selfReferenceRegex.MatchString(paragraph) {
+            issue.Message = "Lead paragraph refers to the page itself without naming its subject"
+        }
+        issue.Suggestion = fmt.Sprintf("Open with the subject by name, e.g. '%s is ... This page explains ...'", title.Heading)
+    case a.config.MinWordCount > 0 && wordCount(paragraph) < a.config.MinWordCount:
+        issue.Message = fmt.Sprintf("Lead paragraph is too short (%d words, minimum %d)", wordCount(paragraph), a.config.MinWordCount)
+        issue.Suggestion = "Expand the lead paragraph to say what the feature is and what the page covers"
+    default:
+        return nil
+    }
+
+    return []Issue{issue}
+}
+
+// namesSubject reports whether the paragraph mentions a content word of
+// the page title, in any inflection, or one of the document's product names
+func (a *Analyzer) namesSubject(paragraph, title, content, lang string) bool {
+    if sharedWords(contentWordsIn(title, lang), contentWordsIn(paragraph, lang), lang) > 0 {
+        return true
+    }
+    lower := strings.ToLower(paragraph)
+    for _, product := range a.extractProductNames(content) {
+        if strings.Contains(lower, strings.ToLower(product)) {
+            return true
+        }
+    }
+
+    return false
+}
@@ -0,0 +1,256 @@
+// Tokenization, stopwords and stemming for the lexical checks
+
+package main
+
+import (
+    "regexp"
+    "sort"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+var (
+    // lexicalStopwords are the function words of each language that say
+    // nothing about what a text is about. They extend the stopwords used
+    // for language detection, which are kept short to stay distinctive.
+    lexicalStopwords = map[string][]string{
+        "en": {
+            "about", "after", "again", "all", "also", "any", "because", "been", "before", "being", "both", "but",
+            "can", "could", "does", "doing", "each", "from", "had", "has", "have", "here", "how", "into", "its",
+            "just", "may", "more", "most", "must", "not", "now", "one", "only", "other", "our", "over", "should",
+            "some", "such", "than", "their", "them", "then", "there", "these", "they", "those", "through", "too",
+            "under", "until", "use", "very", "was", "were", "what", "when", "where", "which", "while", "who", "why",
+            "will", "without", "would", "yours",
+        },
+        "fr": {
+            "alors", "au", "aux", "avez", "cette", "ces", "comme", "dont", "elle", "être", "leur", "mais", "même",
+            "nous", "ou", "où", "par", "pas", "peut", "plus", "qui", "quand", "sans", "ses", "son", "sont", "tout",
+            "très", "votre", "vos",
+        },
+        "de": {
+            "aber", "als", "auch", "aus", "bei", "bis", "dass", "dem", "den", "des", "dies", "diese", "durch",
+            "einen", "einer", "haben", "hat", "ihr", "ihre", "im", "kann", "können", "nach", "noch", "nur", "oder",
+            "sich", "sind", "über", "um", "von", "vor", "werden", "wenn", "wie", "wird", "zu", "zum", "zur",
+        },
+        "es": {
+            "al", "como", "cuando", "desde", "donde", "entre", "esta", "este", "estos", "hay", "las", "más", "muy",
+            "pero", "puede", "se", "sin", "sobre", "son", "su", "sus", "también", "todo", "un", "usted",
+        },
+        "it": {
+            "al", "alla", "anche", "come", "dei", "del", "delle", "ha", "in", "la", "le", "lo", "ma", "nella", "o",
+            "più", "può", "quando", "questo", "se", "si", "su", "tra", "tutti", "un", "uno",
+        },
+        "pt": {
+            "ao", "as", "como", "dos", "das", "está", "isso", "mais", "mas", "na", "nas", "nos", "ou", "pela",
+            "pelo", "pode", "por", "se", "seu", "sua", "também", "um",
+        },
+        "nl": {
+            "aan", "al", "als", "bij", "dan", "de", "deze", "die", "door", "dit", "heeft", "hij", "kan", "maar",
+            "naar", "nog", "of", "om", "ook", "over", "u", "uit", "uw", "was", "wat", "wordt", "zo",
+        },
+    }
+
+    // stemSuffixes are each language's inflectional suffixes, tried
+    // longest first
+    stemSuffixes = map[string][]string{
+        "en": {"ations", "ation", "ings", "ions", "ing", "ion", "ers", "ies", "er", "ed", "es", "s", "e"},
+        "fr": {"issements", "issement", "ations", "ation", "ements", "ement", "euses", "euse", "eurs", "eur", "ées", "és", "ée", "er", "es", "s", "e"},
+        "de": {"ungen", "ung", "heiten", "heit", "keiten", "keit", "ern", "en", "er", "es", "e", "s", "n"},
+        "es": {"aciones", "ación", "amientos", "amiento", "mente", "ando", "iendo", "ados", "idos", "ado", "ido", "ar", "er", "ir", "es", "os", "as", "s"},
+        "it": {"azioni", "azione", "mente", "ando", "endo", "ati", "ate", "ato", "are", "ere", "ire", "i", "e", "o", "a"},
+        "pt": {"ações", "ação", "mente", "ando", "endo", "ados", "idos", "ado", "ido", "ar", "er", "ir", "es", "os", "as", "s"},
+        "nl": {"ingen", "ing", "heden", "heid", "en", "er", "te", "de", "s", "e"},
+    }
+
+    stopwordSets = buildStopwordSets()
+)
+
+// buildStopwordSets merges the detection and lexical stopwords of each
+// language into a set
+func buildStopwordSets() map[string]map[string]bool {
+    sets := make(map[string]map[string]bool)
+    for _, lists := range []map[string][]string{stopwords, lexicalStopwords} {
+        for lang, list := range lists {
+            if sets[lang] == nil {
+                sets[lang] = make(map[string]bool)
+            }
+            for _, word := range list {
+                sets[lang][word] = true
+            }
+        }
+    }
+    return sets
+}
+
+// tokenize returns the lowercased words of text: runs of letters and
+// digits, with inner hyphens. Chinese and Japanese don't separate words
+// with spaces, so their runs are split into overlapping pairs of
+// characters, which match like words do.
+func tokenize(text string) []string {
+    var tokens []string
+    for _, word := range titleWordRegex.FindAllString(strings.ToLower(text), -1) {
+        var run []rune
+        flush := func() {
+            if len(run) == 1 {
+                tokens = append(tokens, string(run))
+            }
+            for i := 0; i+1 < len(run); i++ {
+                tokens = append(tokens, string(run[i:i+2]))
+            }
+            run = run[:0]
+        }
+        var rest strings.Builder
+        for _, r := range word {
+            if isIdeographic(r) {
+                if rest.Len() > 0 {
+                    tokens = append(tokens, rest.String())
+                    rest.Reset()
+                }
+                run = append(run, r)
+                continue
+            }
+            flush()
+            rest.WriteRune(r)
+        }
+        flush()
+        if rest.Len() > 0 {
+            tokens = append(tokens, rest.String())
+        }
+    }
+    return tokens
+}
+
+// isIdeographic reports whether r is written without spaces between
+// words: Han, Hiragana or Katakana
+func isIdeographic(r rune) bool {
+    return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r)
+}
+
+// contentWords returns the words of text that carry meaning, in any known
+// language
+func contentWords(text string) []string {
+    return contentWordsIn(text, "")
+}
+
+// contentWordsIn returns the lowercased words of text that carry meaning:
+// longer than two letters, or ideographic, and not a stopword of lang, or
+// of any known language if lang is "" or has no stopword list
+func contentWordsIn(text, lang string) []string {
+    var words []string
+    for _, word := range tokenize(text) {
+        first := []rune(word)[0]
+        if (isIdeographic(first) || len([]rune(word)) > 2) && !isStopwordIn(word, lang) {
+            words = append(words, word)
+        }
+    }
+    return words
+}
+
+// isStopword reports whether word is a stopword of any known language
+func isStopword(word string) bool {
+    return isStopwordIn(word, "")
+}
+
+// isStopwordIn reports whether the lowercased word is a stopword of lang,
+// or of any known language if lang is "" or has no stopword list
+func isStopwordIn(word, lang string) bool {
+    if set, ok := stopwordSets[lang]; ok {
+        return set[word]
+    }
+    for _, set := range stopwordSets {
+        if set[word] {
+            return true
+        }
+    }
+    return false
+}
+
+// stem reduces a word to a crude stem with the English suffixes
+func stem(word string) string {
+    return stemIn(word, fallbackLanguage)
+}
+
+// stemIn reduces a word to a crude stem, dropping a common suffix of lang
+// (English if it has no list) and keeping at most six letters, so
+// inflected forms usually agree: "configure" and "configuration" both
+// stem to "config"
+func stemIn(word, lang string) string {
+    suffixes, ok := stemSuffixes[lang]
+    if !ok {
+        suffixes = stemSuffixes[fallbackLanguage]
+    }
+    for _, suffix := range suffixes {
+        if trimmed, ok := strings.CutSuffix(word, suffix); ok && len([]rune(trimmed)) >= 3 {
+            word = trimmed
+            break
+        }
+    }
+    if runes := []rune(word); len(runes) > 6 {
+        return string(runes[:6])
+    }
+    return word
+}
+
+// productWordRegex matches a word for product name extraction
+var productWordRegex = regexp.MustCompile(`\p{L}[\p{L}\p{N}]*`)
+
+// productNames returns the words content uses as names, most frequent
+// first: capitalized words of four or more letters, outside code, that
+// occur at least three times, at least once in mid-sentence, never in
+// lowercase, and that aren't a stopword of any known language. So "The" or
+// "Click", capitalized only where a sentence or list item starts, don't
+// count.
+func productNames(content string) []string {
+    frequency := make(map[string]int)
+    midSentence := make(map[string]bool)
+    lowercase := make(map[string]bool)
+    inFence := false
+    for _, line := range strings.Split(content, "\n") {
+        if codeFenceRegex.MatchString(line) {
+            inFence = !inFence
+            continue
+        }
+        if inFence {
+            continue
+        }
+        text := plainText(codeSpanRegex.ReplaceAllString(line, " "))
+        for _, match := range productWordRegex.FindAllStringIndex(text, -1) {
+            word := text[match[0]:match[1]]
+            if first, _ := utf8.DecodeRuneInString(word); !unicode.IsUpper(first) {
+                lowercase[word] = true
+                continue
+            }
+            if len([]rune(word)) <= 3 || isStopword(strings.ToLower(word)) {
+                continue
+            }
+            frequency[word]++
+            if !startsClause(text[:match[0]]) {
+                midSentence[word] = true
+            }
+        }
+    }
+
+    var products []string
+    for word, count := range frequency {
+        if count >= 3 && midSentence[word] && !lowercase[strings.ToLower(word)] {
+            products = append(products, word)
+        }
+    }
+    // Most frequent first, as inferProductName relies on, ties by name so
+    // the choice doesn't follow map order
+    sort.Slice(products, func(i, j int) bool {
+        if frequency[products[i]] != frequency[products[j]] {
+            return frequency[products[i]] > frequency[products[j]]
+        }
+        return products[i] < products[j]
+    })
+    return products
+}
+
+// startsClause reports whether a word after text starts a sentence,
+// heading, list item or table cell, where any word may be capitalized
+func startsClause(text string) bool {
+    trimmed := strings.TrimRight(text, " \t\"'([*_")
+    return trimmed == "" || strings.ContainsAny(trimmed[len(trimmed)-1:], ".!?:;#>|-+")
+}
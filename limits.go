@@ -0,0 +1,115 @@
+// Per-file resource limits
+
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// FileLimits bounds the work spent on any one file, so a pathological
+// input can't hang or exhaust a CI run
+type FileLimits struct {
+    Timeout time.Duration // 0 for no limit
+    MaxSize int64         // bytes; 0 for no limit
+}
+
+// analyzeFileWithin analyzes a file unless it is too large, looks binary,
+// or takes longer than the timeout, in which case it returns a single
+// file-skipped issue instead
+func (a *Analyzer) analyzeFileWithin(filePath string, limits FileLimits) ([]Issue, error) {
+    size, err := fileSize(filePath)
+    if err != nil {
+        return nil, err
+    }
+    if limits.MaxSize > 0 && size > limits.MaxSize {
+        return []Issue{skippedFile(filePath, skipTooLarge, fmt.Sprintf("file is %s, over the %s limit", formatSize(size), formatSize(limits.MaxSize)))}, nil
+    }
+
+    data, err := readFileData(filePath)
+    if err != nil {
+        return nil, err
+    }
+    if isBinary(data) {
+        return []Issue{skippedFile(filePath, skipBinary, "file looks binary")}, nil
+    }
+    content := decodeText(data)
+
+    if limits.Timeout <= 0 {
+        return a.analyzeContent(filePath, content), nil
+    }
+
+    // The analysis can't be interrupted, so on timeout it finishes in the
+    // background and its result is dropped
+    done := make(chan []Issue, 1)
+    go func() {
+        done <- a.analyzeContent(filePath, content)
+    }()
+    select {
+    case issues := <-done:
+        return issues, nil
+    case <-time.After(limits.Timeout):
+        return []Issue{skippedFile(filePath, skipTimeout, fmt.Sprintf("analysis took longer than %s", limits.Timeout))}, nil
+    }
+}
+
+// skippedFile is the issue reported in place of a file's results, skipped
+// for reason (skipTooLarge, skipBinary or skipTimeout)
+func skippedFile(filePath, reason, detail string) Issue {
+    return Issue{
+        File:       filePath,
+        Line:       1,
+        Column:     1,
+        Rule:       "file-skipped",
+        Message:    "Skipped: " + detail,
+        Severity:   "warning",
+        Suggestion: "Exclude the file from the run, or raise -max-file-size / -timeout-per-file",
+        Skip:       reason,
+    }
+}
+
+// isBinary reports whether data has NUL bytes that UTF-16 decoding
+// doesn't account for
+func isBinary(data []byte) bool {
+    if bytes.HasPrefix(data, []byte{0xFF, 0xFE}) || bytes.HasPrefix(data, []byte{0xFE, 0xFF}) {
+        return false
+    }
+    if _, utf16 := looksLikeUTF16(data); utf16 {
+        return false
+    }
+    return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
+
+// parseSize parses a byte count with an optional KB, MB or GB suffix
+func parseSize(value string) (int64, error) {
+    value = strings.ToUpper(strings.TrimSpace(value))
+    multiplier := int64(1)
+    for _, unit := range []struct {
+        suffix string
+        factor int64
+    }{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+        if strings.HasSuffix(value, unit.suffix) {
+            value, multiplier = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), unit.factor
+            break
+        }
+    }
+    n, err := strconv.ParseInt(value, 10, 64)
+    if err != nil || n < 0 {
+        return 0, fmt.Errorf("invalid size %q", value)
+    }
+    return n * multiplier, nil
+}
+
+// formatSize renders a byte count for messages
+func formatSize(n int64) string {
+    switch {
+    case n >= 1<<20:
+        return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+    case n >= 1<<10:
+        return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+    }
+    return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,279 @@
+// Cross-file link graph for corpus-level navigation checks
+
+package main
+
+import (
+    "fmt"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+var (
+    markdownLinkRegex  = regexp.MustCompile(`\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+    referenceLinkRegex = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s*<?(\S+?)>?(?:\s+.*)?$`)
+    htmlLinkRegex      = regexp.MustCompile(`(?i)<a\s[^>]*href\s*=\s*["']([^"']+)["']`)
+    externalLinkRegex  = regexp.MustCompile(`(?i)^(?:[a-z][a-z0-9+.-]*:|//)`)
+)
+
+// defaultMaxLinkDepth is used when the config doesn't set MaxLinkDepth
+const defaultMaxLinkDepth = 4
+
+// hubInboundThreshold is the number of inbound links that makes a page a hub
+const hubInboundThreshold = 3
+
+// Link is a reference from one corpus file to another
+type Link struct {
+    Source string
+    Target string
+    Anchor string // fragment without '#', empty when linking to the page itself
+    Line   int    // 1-based line of the link in Source
+}
+
+// LinkGraph holds the links between files of one analyzed corpus
+type LinkGraph struct {
+    Files    []string
+    Outbound map[string][]Link
+    Inbound  map[string][]Link
+}
+
+// buildLinkGraph records every relative link between the given documents
+func buildLinkGraph(docs []*Document) *LinkGraph {
+    graph := &LinkGraph{
+        Outbound: make(map[string][]Link),
+        Inbound:  make(map[string][]Link),
+    }
+
+    known := make(map[string]bool)
+    for _, doc := range docs {
+        clean := filepath.Clean(doc.Path)
+        known[clean] = true
+        graph.Files = append(graph.Files, clean)
+    }
+    sort.Strings(graph.Files)
+
+    for _, doc := range docs {
+        source := filepath.Clean(doc.Path)
+        for i, line := range doc.Lines {
+            if doc.Fenced[i] {
+                continue
+            }
+            for _, raw := range extractLinkTargets(line) {
+                target, anchor, ok := resolveLink(source, raw, known)
+                if !ok || target == source {
+                    continue
+                }
+                link := Link{Source: source, Target: target, Anchor: anchor, Line: i + 1}
+                graph.Outbound[source] = append(graph.Outbound[source], link)
+                graph.Inbound[target] = append(graph.Inbound[target], link)
+            }
+        }
+    }
+
+    return graph
+}
+
+// extractLinkTargets returns the raw targets of inline, reference-style and
+// HTML links on a line
+func extractLinkTargets(line string) []string {
+    var targets []string
+    for _, pattern := range []*regexp.Regexp{markdownLinkRegex, referenceLinkRegex, htmlLinkRegex} {
+        for _, match := range pattern.FindAllStringSubmatch(line, -1) {
+            targets = append(targets, match[1])
+        }
+    }
+    return targets
+}
+
+// resolveLink maps a relative link target to a known corpus file, trying the
+// extension and index-page conventions of common static site generators
+func resolveLink(source, raw string, known map[string]bool) (string, string, bool) {
+    if externalLinkRegex.MatchString(raw) {
+        return "", "", false
+    }
+
+    target, anchor := raw, ""
+    if i := strings.Index(target, "#"); i >= 0 {
+        target, anchor = target[:i], target[i+1:]
+    }
+    if i := strings.Index(target, "?"); i >= 0 {
+        target = target[:i]
+    }
+    if target == "" {
+        return source, anchor, true
+    }
+
+    base := filepath.Join(filepath.Dir(source), filepath.FromSlash(target))
+    candidates := []string{base}
+    for _, ext := range []string{".md", ".markdown", ".html", ".rst"} {
+        candidates = append(candidates, base+ext, filepath.Join(base, "index"+ext))
+    }
+    candidates = append(candidates, filepath.Join(base, "README.md"))
+    if ext := filepath.Ext(base); ext == ".html" || ext == ".htm" {
+        candidates = append(candidates, strings.TrimSuffix(base, ext)+".md")
+    }
+
+    for _, candidate := range candidates {
+        if known[filepath.Clean(candidate)] {
+            return filepath.Clean(candidate), anchor, true
+        }
+    }
+    return "", "", false
+}
+
+// entryPages returns the shallowest index/README pages, which navigation is
+// assumed to start from
+func (g *LinkGraph) entryPages() []string {
+    var entries []string
+    minDepth := -1
+
+    for _, file := range g.Files {
+        name := strings.ToLower(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+        if name != "index" && name != "readme" {
+            continue
+        }
+
+        depth := strings.Count(filepath.ToSlash(file), "/")
+        switch {
+        case minDepth < 0 || depth < minDepth:
+            minDepth = depth
+            entries = []string{file}
+        case depth == minDepth:
+            entries = append(entries, file)
+        }
+    }
+
+    return entries
+}
+
+// linkingPages returns the number of distinct pages linking to file
+func (g *LinkGraph) linkingPages(file string) int {
+    sources := make(map[string]bool)
+    for _, link := range g.Inbound[file] {
+        sources[link.Source] = true
+    }
+    return len(sources)
+}
+
+// depths returns the shortest link distance of every reachable page from the entries
+func (g *LinkGraph) depths(entries []string) map[string]int {
+    depth := make(map[string]int)
+    queue := append([]string(nil), entries...)
+    for _, entry := range entries {
+        depth[entry] = 0
+    }
+
+    for len(queue) > 0 {
+        page := queue[0]
+        queue = queue[1:]
+        for _, link := range g.Outbound[page] {
+            if _, seen := depth[link.Target]; !seen {
+                depth[link.Target] = depth[page] + 1
+                queue = append(queue, link.Target)
+            }
+        }
+    }
+
+    return depth
+}
+
+// analyzeLinkGraph reports orphan pages, dead-end hubs and pages buried too
+// deep in the navigation to be verified by following citations. With a nav
+// file configured, every page it lists is an entry, a page's depth is its
+// nesting in the nav, and pages only reachable by links are reported as
+// missing from it.
+func (a *Analyzer) analyzeLinkGraph(graph *LinkGraph) []Issue {
+    var issues []Issue
+
+    maxDepth := a.config.MaxLinkDepth
+    if maxDepth <= 0 {
+        maxDepth = defaultMaxLinkDepth
+    }
+
+    entries := graph.entryPages()
+    if a.nav != nil {
+        entries = nil
+        for _, file := range graph.Files {
+            if _, ok := a.nav.Lookup(file); ok || navKey(file) == navKey(a.nav.Source) {
+                entries = append(entries, file)
+            }
+        }
+    }
+    isEntry := make(map[string]bool)
+    for _, entry := range entries {
+        isEntry[entry] = true
+    }
+    depth := graph.depths(entries)
+
+    for _, file := range graph.Files {
+        inbound, outbound := graph.linkingPages(file), len(graph.Outbound[file])
+
+        if inbound == 0 && !isEntry[file] {
+            message := "Page is not linked from any other page in the corpus"
+            if a.nav != nil {
+                message = "Page is not in the navigation and not linked from any other page"
+            }
+            issues = append(issues, Issue{
+                File:       file,
+                Line:       1,
+                Column:     1,
+                Rule:       "orphan-page",
+                Message:    message,
+                Severity:   "warning",
+                Suggestion: "Link to this page from a related page or navigation index",
+            })
+            continue
+        }
+
+        if a.nav != nil && !isEntry[file] {
+            issues = append(issues, Issue{
+                File:       file,
+                Line:       1,
+                Column:     1,
+                Rule:       "missing-from-nav",
+                Message:    fmt.Sprintf("Page is linked from %d page(s) but missing from the navigation in %s", inbound, a.nav.Source),
+                Severity:   "suggestion",
+                Suggestion: "Add the page to the navigation so readers and crawlers find it in reading order",
+            })
+        }
+
+        if inbound >= hubInboundThreshold && outbound == 0 {
+            issues = append(issues, Issue{
+                File:       file,
+                Line:       1,
+                Column:     1,
+                Rule:       "dead-end-hub",
+                Message:    fmt.Sprintf("Page is linked from %d pages but links to none", inbound),
+                Severity:   "suggestion",
+                Suggestion: "Add links to related or next-step pages",
+            })
+        }
+
+        if page, ok := a.nav.Lookup(file); ok {
+            if nesting := len(page.Titles) - 1; nesting > maxDepth {
+                issues = append(issues, Issue{
+                    File:       file,
+                    Line:       1,
+                    Column:     1,
+                    Rule:       "deep-navigation",
+                    Message:    fmt.Sprintf("Page is nested %d levels deep in the navigation (max %d): %s", nesting, maxDepth, strings.Join(page.Titles, " > ")),
+                    Severity:   "suggestion",
+                    Suggestion: "Move the page up the navigation, or flatten the sections above it",
+                })
+            }
+        } else if d, ok := depth[file]; ok && len(entries) > 0 && d > maxDepth {
+            issues = append(issues, Issue{
+                File:       file,
+                Line:       1,
+                Column:     1,
+                Rule:       "deep-navigation",
+                Message:    fmt.Sprintf("Page is %d links away from the entry page (max %d)", d, maxDepth),
+                Severity:   "suggestion",
+                Suggestion: "Link to this page from a higher-level page",
+            })
+        }
+    }
+
+    return issues
+}
@@ -0,0 +1,114 @@
+// List item parallelism and self-containment
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var (
+    // listLabelRegex matches a bold or code term labelling an item
+    // ("**Retries**: ..."), which is left out when classifying its text
+    listLabelRegex = regexp.MustCompile("^(?:\\*\\*[^*]+\\*\\*|__[^_]+__|`[^`]+`)\\s*[:—–-]\\s+")
+    // listPronounRegex matches a pronoun that needs an antecedent
+    listPronounRegex = regexp.MustCompile(`\b(?i:it|its|they|them|their|this|these|those)\b`)
+)
+
+// listPronounWords is how far into an item a pronoun has nothing before it
+// to refer to, as in "Enable it in settings"
+const listPronounWords = 3
+
+// analyzeListItems flags bullet lists that mix full sentences with
+// fragments, and list items that lean on context outside themselves. A
+// list is often retrieved as a chunk of its own, without the sentence that
+// introduces it, so each item has to make sense alone and the items have
+// to read as one series.
+func (a *Analyzer) analyzeListItems(doc *Document) []Issue {
+    var issues []Issue
+    for _, list := range doc.Lists() {
+        var sentences, fragments []ListItem
+        for _, item := range list.Items {
+            text := strings.SplitN(item.Text, "\n", 2)[0]
+            if listSentence(text) {
+                sentences = append(sentences, item)
+            } else {
+                fragments = append(fragments, item)
+            }
+            if issue, ok := listItemContext(doc, item, text); ok {
+                issues = append(issues, issue)
+            }
+        }
+
+        if list.Ordered || len(list.Items) < 3 || len(sentences) == 0 || len(fragments) == 0 {
+            continue
+        }
+        odd, kind := fragments, "fragment(s)"
+        if len(sentences) < len(fragments) {
+            odd, kind = sentences, "full sentence(s)"
+        }
+        var lines []string
+        for _, item := range odd {
+            lines = append(lines, fmt.Sprintf("%d", item.Line))
+        }
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         odd[0].Line,
+            Column:       1,
+            Rule:         "list-parallelism",
+            Message:      fmt.Sprintf("List mixes %d full sentence(s) with %d fragment(s)", len(sentences), len(fragments)),
+            Severity:     "suggestion",
+            Suggestion:   fmt.Sprintf("Write every item the same way, as full sentences or as fragments; the %s at line(s) %s differ", kind, strings.Join(lines, ", ")),
+            OriginalText: strings.TrimSpace(doc.Lines[odd[0].Line-1]),
+        })
+    }
+    return issues
+}
+
+// listSentence reports whether an item's text is written as a full
+// sentence: at least three words ending in a period, question or
+// exclamation mark. A bold or code label before it doesn't count.
+func listSentence(text string) bool {
+    text = plainText(listLabelRegex.ReplaceAllString(strings.TrimSpace(text), ""))
+    text = strings.TrimRight(text, ` "')*_`)
+    return len(strings.Fields(text)) >= 3 && strings.ContainsAny(text[len(text)-1:], ".!?")
+}
+
+// listItemContext returns an issue for a list item whose first line uses a
+// pronoun within its first few words, before it could name what the
+// pronoun refers to: "Enable it in settings". "This" and similar only
+// count standing alone, not in "this setting".
+func listItemContext(doc *Document, item ListItem, text string) (Issue, bool) {
+    line := doc.Masked[item.Line-1]
+    offset := strings.Index(line, text)
+    if offset < 0 {
+        return Issue{}, false
+    }
+    body := listLabelRegex.ReplaceAllString(text, "")
+    offset += len(text) - len(body)
+
+    for _, match := range listPronounRegex.FindAllStringIndex(body, -1) {
+        if len(strings.Fields(body[:match[0]])) >= listPronounWords {
+            break
+        }
+        pronoun := body[match[0]:match[1]]
+        if lower := strings.ToLower(pronoun); lower == "this" || lower == "these" || lower == "those" {
+            next := firstWord(body[match[1]:])
+            if next != "" && !pronounVerbs[next] && !determiners[next] {
+                continue // "this setting"
+            }
+        }
+        return Issue{
+            File:         doc.Path,
+            Line:         item.Line,
+            Column:       offset + match[0] + 1,
+            Rule:         "list-item-context",
+            Message:      fmt.Sprintf("List item refers with '%s' to something it doesn't name", pronoun),
+            Severity:     "warning",
+            Suggestion:   fmt.Sprintf("Replace '%s' with what it refers to, since the list may be retrieved without its introduction", pronoun),
+            OriginalText: strings.TrimSpace(doc.Lines[item.Line-1]),
+        }, true
+    }
+    return Issue{}, false
+}
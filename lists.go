@@ -0,0 +1,134 @@
+// Markdown list parsing
+
+package main
+
+import (
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+var (
+    orderedItemRegex    = regexp.MustCompile(`^(\s*)(\d+)([.)])\s+(.*)$`)
+    bulletItemRegex     = regexp.MustCompile(`^(\s*)([-*+])\s+(.*)$`)
+    horizontalRuleRegex = regexp.MustCompile(`^\s*(?:[-*_]\s*){3,}$`)
+)
+
+// ListItem is one top-level item of a list, including its continuation lines
+type ListItem struct {
+    Number  int    // ordinal for ordered items, 0 for bullets
+    Line    int    // 1-based line of the item marker
+    EndLine int    // 1-based last line of the item (inclusive)
+    Text    string // marker-line text followed by continuation lines, joined with "\n"
+}
+
+// List is a run of sibling items at the same indentation
+type List struct {
+    Ordered bool
+    Items   []ListItem
+}
+
+// listMarker parses a list item line, returning its indent, ordinal (0 for
+// bullets), whether it's ordered and the text after the marker
+func listMarker(line string) (int, int, bool, string, bool) {
+    if match := orderedItemRegex.FindStringSubmatch(line); match != nil {
+        number, _ := strconv.Atoi(match[2])
+        return leadingWidth(match[1]), number, true, match[4], true
+    }
+    if match := bulletItemRegex.FindStringSubmatch(line); match != nil && !horizontalRuleRegex.MatchString(line) {
+        return leadingWidth(match[1]), 0, false, match[3], true
+    }
+    return 0, 0, false, "", false
+}
+
+// Lists returns the document's top-level lists in order. Nested items and
+// indented content belong to the enclosing item's text.
+func (d *Document) Lists() []List {
+    var lists []List
+    var current *List
+    indent := 0
+    blank := false
+
+    closeList := func() {
+        if current != nil {
+            lists = append(lists, *current)
+            current = nil
+        }
+    }
+
+    for i := d.BodyStart - 1; i < len(d.Lines); i++ {
+        line := d.Masked[i]
+        trimmed := strings.TrimSpace(line)
+
+        if trimmed == "" {
+            blank = true
+            continue
+        }
+
+        itemIndent, number, ordered, text, isItem := listMarker(line)
+        inFence := d.Fenced[i]
+
+        if current != nil && (!isItem || itemIndent > indent) && leadingWidth(line) > indent {
+            // Indented content continues the current item, fenced code included
+            item := &current.Items[len(current.Items)-1]
+            item.Text += "\n" + trimmed
+            item.EndLine = i + 1
+            blank = false
+            continue
+        }
+
+        if isItem && !inFence {
+            if current != nil && (itemIndent != indent || ordered != current.Ordered) {
+                closeList()
+            }
+            if current == nil {
+                current = &List{Ordered: ordered}
+                indent = itemIndent
+            }
+            if !ordered {
+                number = 0
+            }
+            current.Items = append(current.Items, ListItem{Number: number, Line: i + 1, EndLine: i + 1, Text: text})
+            blank = false
+            continue
+        }
+
+        if current != nil && !blank && !inFence && !atxHeadingRegex.MatchString(trimmed) {
+            // Lazy continuation of the item's paragraph
+            item := &current.Items[len(current.Items)-1]
+            item.Text += "\n" + trimmed
+            item.EndLine = i + 1
+            continue
+        }
+
+        closeList()
+        blank = false
+    }
+    closeList()
+
+    return lists
+}
+
+// Procedures returns the document's ordered lists, joining a list with the
+// previous one in the same section when its numbering continues it, as
+// when an unindented code block or paragraph interrupts a procedure
+func (d *Document) Procedures() []List {
+    var procedures []List
+    for _, list := range d.Lists() {
+        if !list.Ordered {
+            continue
+        }
+        if n := len(procedures); n > 0 {
+            previous := &procedures[n-1]
+            last := previous.Items[len(previous.Items)-1]
+            before, _ := d.SectionAt(last.Line)
+            after, _ := d.SectionAt(list.Items[0].Line)
+            if list.Items[0].Number == last.Number+1 && before.StartLine == after.StartLine {
+                previous.Items = append(previous.Items, list.Items...)
+                continue
+            }
+        }
+        procedures = append(procedures, list)
+    }
+    return procedures
+}
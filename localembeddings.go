@@ -0,0 +1,129 @@
+// Local embedding models: a llama.cpp server or an embedding command
+
+package main
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "os"
+    "os/exec"
+    "strings"
+    "time"
+)
+
+// embeddingCommandTimeout bounds one batch run through a local command,
+// which may load a model from disk first
+const embeddingCommandTimeout = 5 * time.Minute
+
+// embeddingProviders are the values of the Provider setting
+var embeddingProviders = map[string]bool{"openai": true, "llamacpp": true, "command": true}
+
+// validate rejects an unknown provider, and a command provider with no
+// command or a command setting the provider wouldn't use
+func (c EmbeddingsConfig) validate() error {
+    if c.Provider != "" && !embeddingProviders[c.Provider] {
+        return fmt.Errorf("invalid Embeddings Provider %q (want openai, llamacpp or command)", c.Provider)
+    }
+    if c.Provider == "command" && len(strings.Fields(c.Command)) == 0 {
+        return fmt.Errorf("no Command for Embeddings Provider command")
+    }
+    if c.Command != "" && c.Provider != "command" {
+        return fmt.Errorf("an Embeddings Command is only run with Provider command")
+    }
+    return nil
+}
+
+// requestLlamaCpp calls the native /embedding endpoint of a llama.cpp
+// server. Recent servers answer a list of inputs with a list of results,
+// each holding one pooled vector, or one vector per token when pooling is
+// off, which is averaged here; older ones answer a single input with a
+// single object.
+func (e *Embedder) requestLlamaCpp(texts []string) ([][]float64, error) {
+    body, err := json.Marshal(map[string]any{"content": texts})
+    if err != nil {
+        return nil, err
+    }
+    response, err := e.post("/embedding", body)
+    if err != nil {
+        return nil, err
+    }
+    defer response.Body.Close()
+    if response.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("embedding request to %s returned %s", e.url, response.Status)
+    }
+
+    type result struct {
+        Index     int             `json:"index"`
+        Embedding json.RawMessage `json:"embedding"`
+    }
+    var raw json.RawMessage
+    if err := json.NewDecoder(response.Body).Decode(&raw); err != nil {
+        return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+    }
+    var results []result
+    if err := json.Unmarshal(raw, &results); err != nil {
+        var single result
+        if err := json.Unmarshal(raw, &single); err != nil {
+            return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+        }
+        results = []result{single}
+    }
+    if len(results) != len(texts) {
+        return nil, fmt.Errorf("embedding response has %d vectors for %d inputs", len(results), len(texts))
+    }
+
+    vectors := make([][]float64, len(texts))
+    for _, item := range results {
+        if item.Index < 0 || item.Index >= len(texts) {
+            return nil, fmt.Errorf("embedding response has out-of-range index %d", item.Index)
+        }
+        var vector []float64
+        if err := json.Unmarshal(item.Embedding, &vector); err != nil {
+            var rows [][]float64
+            if err := json.Unmarshal(item.Embedding, &rows); err != nil || len(rows) == 0 {
+                return nil, fmt.Errorf("embedding response has no vector for input %d", item.Index)
+            }
+            vector = meanVector(rows)
+        }
+        vectors[item.Index] = vector
+    }
+    return vectors, nil
+}
+
+// requestCommand runs the configured command on one batch. It reads an
+// OpenAI-style request, {"model": ..., "input": [...]}, on stdin and
+// writes an OpenAI-style response, {"data": [{"index": 0, "embedding":
+// [...]}]}, on stdout; a short script around ONNX Runtime or
+// sentence-transformers fits this, and no text leaves the machine.
+func (e *Embedder) requestCommand(texts []string) ([][]float64, int, error) {
+    body, err := json.Marshal(map[string]any{"model": e.model, "input": texts})
+    if err != nil {
+        return nil, 0, err
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), embeddingCommandTimeout)
+    defer cancel()
+
+    args := strings.Fields(e.command)
+    cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+    cmd.Stdin = bytes.NewReader(body)
+    cmd.Stderr = os.Stderr
+    output, err := cmd.Output()
+    if err != nil {
+        return nil, 0, fmt.Errorf("embedding command %s: %w", args[0], err)
+    }
+    return decodeEmbeddings(output, len(texts))
+}
+
+// meanVector averages vectors of equal length
+func meanVector(rows [][]float64) []float64 {
+    mean := make([]float64, len(rows[0]))
+    for _, row := range rows {
+        for i := 0; i < len(mean) && i < len(row); i++ {
+            mean[i] += row[i] / float64(len(rows))
+        }
+    }
+    return mean
+}
@@ -0,0 +1,91 @@
+// Export manifests for incremental re-ingestion
+
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+)
+
+// Manifest records the chunks of one export, so the next export can emit
+// only what changed since
+type Manifest struct {
+    ExtractedAt string          `json:"extracted_at,omitempty"` // time of the export, in RFC 3339
+    Chunks      []ManifestEntry `json:"chunks"`
+}
+
+// ManifestEntry identifies one exported chunk and fingerprints its text
+type ManifestEntry struct {
+    ID       string `json:"id"`
+    File     string `json:"file"`
+    Hash     string `json:"hash"`                // of the exported text, breadcrumbs and transforms included
+    Commit   string `json:"commit,omitempty"`    // the chunk's commit, from its provenance
+    FileHash string `json:"file_hash,omitempty"` // the chunk's file hash, from its provenance
+    Change   string `json:"change,omitempty"`    // "removed", when emitted as a removal
+}
+
+// manifestEntry returns the manifest entry of an exported chunk
+func manifestEntry(chunk Chunk) ManifestEntry {
+    return ManifestEntry{ID: chunk.ID, File: chunk.File, Hash: contentHash(chunk.Text), Commit: chunk.Commit, FileHash: chunk.FileHash}
+}
+
+// loadManifest reads a manifest written by export -write-manifest
+func loadManifest(path string) (*Manifest, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var manifest Manifest
+    if err := json.Unmarshal(data, &manifest); err != nil {
+        return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+    }
+    return &manifest, nil
+}
+
+// writeManifest saves the manifest of the chunks exported at extractedAt
+func writeManifest(path string, chunks []Chunk, extractedAt string) error {
+    manifest := Manifest{ExtractedAt: extractedAt, Chunks: make([]ManifestEntry, 0, len(chunks))}
+    for _, chunk := range chunks {
+        manifest.Chunks = append(manifest.Chunks, manifestEntry(chunk))
+    }
+    data, err := json.MarshalIndent(manifest, "", "  ")
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// changeSet compares chunks against a previous manifest. It returns the
+// chunks to emit, each marked "added" or "changed", the manifest entries
+// of chunks that are gone, marked "removed", and the number unchanged.
+func (m *Manifest) changeSet(chunks []Chunk) (emitted []Chunk, removed []ManifestEntry, unchanged int) {
+    previous := make(map[string]string)
+    for _, entry := range m.Chunks {
+        previous[entry.ID] = entry.Hash
+    }
+
+    current := make(map[string]bool)
+    for _, chunk := range chunks {
+        current[chunk.ID] = true
+        hash, ok := previous[chunk.ID]
+        switch {
+        case !ok:
+            chunk.Change = "added"
+        case hash != contentHash(chunk.Text):
+            chunk.Change = "changed"
+        default:
+            unchanged++
+            continue
+        }
+        emitted = append(emitted, chunk)
+    }
+
+    for _, entry := range m.Chunks {
+        if !current[entry.ID] {
+            entry.Change = "removed"
+            removed = append(removed, entry)
+        }
+    }
+    return emitted, removed, unchanged
+}
@@ -0,0 +1,185 @@
+// Markdown syntax lint: constructs that silently degrade parsing
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var (
+    spacedLinkRegex   = regexp.MustCompile(`!?\[[^\]]+\]\s+\([^)\s]+\)`)
+    unclosedLinkRegex = regexp.MustCompile(`!?\[[^\]]*\]\([^)\s]*$`)
+    emptyLinkRegex    = regexp.MustCompile(`!?\[[^\]]*\]\(\s*\)`)
+    tableDividerRegex = regexp.MustCompile(`^\s*\|?\s*:?-{3,}:?\s*(?:\|\s*:?-{3,}:?\s*)*\|?\s*$`)
+)
+
+// analyzeMarkdownSyntax reports broken Markdown that chunkers and converters
+// mangle: unclosed fences, malformed links, ragged tables and lists indented
+// with a mix of tabs and spaces
+func (a *Analyzer) analyzeMarkdownSyntax(doc *Document) []Issue {
+    if doc.Format != "markdown" {
+        return nil
+    }
+
+    var issues []Issue
+    issue := func(rule string, line, column int, severity, message, suggestion string) {
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         line,
+            Column:       column,
+            Rule:         rule,
+            Message:      message,
+            Severity:     severity,
+            Suggestion:   suggestion,
+            OriginalText: strings.TrimSpace(doc.Lines[line-1]),
+        })
+    }
+
+    for _, block := range doc.CodeBlocks {
+        if !block.Closed {
+            issue("markdown-unclosed-fence", block.StartLine, 1, "error",
+                "Code fence is never closed; the rest of the document is treated as code",
+                "Add the closing fence after the code block")
+        }
+    }
+
+    for i, line := range doc.Masked {
+        if doc.Fenced[i] {
+            continue
+        }
+        line = blankMatches(inlineCodeRegex, line)
+
+        if match := spacedLinkRegex.FindStringIndex(line); match != nil {
+            issue("markdown-malformed-link", i+1, match[0]+1, "warning",
+                "Space between link text and target breaks the link",
+                "Remove the space between ']' and '('")
+        }
+        if match := unclosedLinkRegex.FindStringIndex(line); match != nil {
+            issue("markdown-malformed-link", i+1, match[0]+1, "warning",
+                "Link target is never closed",
+                "Add the closing ')' after the link target")
+        }
+        if match := emptyLinkRegex.FindStringIndex(line); match != nil {
+            issue("markdown-malformed-link", i+1, match[0]+1, "warning",
+                "Link has an empty target",
+                "Point the link at its destination or remove the link markup")
+        }
+    }
+
+    issues = append(issues, a.analyzeTables(doc)...)
+    issues = append(issues, a.analyzeListIndentation(doc)...)
+
+    return issues
+}
+
+// tableCells splits a pipe table row into its cells, honoring escaped pipes
+func tableCells(row string) []string {
+    row = strings.TrimSpace(row)
+    row = strings.TrimPrefix(row, "|")
+    if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+        row = row[:len(row)-1]
+    }
+
+    var cells []string
+    var cell strings.Builder
+    for i := 0; i < len(row); i++ {
+        switch {
+        case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+            cell.WriteString(`\|`)
+            i++
+        case row[i] == '|':
+            cells = append(cells, strings.TrimSpace(cell.String()))
+            cell.Reset()
+        default:
+            cell.WriteByte(row[i])
+        }
+    }
+    return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// analyzeTables flags pipe table rows whose cell count differs from the header
+func (a *Analyzer) analyzeTables(doc *Document) []Issue {
+    var issues []Issue
+
+    for i := 1; i < len(doc.Lines); i++ {
+        if doc.Fenced[i] || !tableDividerRegex.MatchString(doc.Lines[i]) || !strings.Contains(doc.Lines[i-1], "|") {
+            continue
+        }
+
+        columns := len(tableCells(doc.Lines[i-1]))
+        if dividers := len(tableCells(doc.Lines[i])); dividers != columns {
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         i + 1,
+                Column:       1,
+                Rule:         "markdown-table-columns",
+                Message:      fmt.Sprintf("Table divider has %d columns but the header has %d", dividers, columns),
+                Severity:     "warning",
+                Suggestion:   "Give the divider row one '---' cell per header column",
+                OriginalText: strings.TrimSpace(doc.Lines[i]),
+            })
+        }
+
+        for j := i + 1; j < len(doc.Lines) && !doc.Fenced[j] && strings.Contains(doc.Lines[j], "|"); j++ {
+            if cells := len(tableCells(doc.Lines[j])); cells != columns {
+                issues = append(issues, Issue{
+                    File:         doc.Path,
+                    Line:         j + 1,
+                    Column:       1,
+                    Rule:         "markdown-table-columns",
+                    Message:      fmt.Sprintf("Table row has %d cells but the header has %d", cells, columns),
+                    Severity:     "warning",
+                    Suggestion:   "Add or remove cells so every row matches the header; escape literal pipes as '\\|'",
+                    OriginalText: strings.TrimSpace(doc.Lines[j]),
+                })
+            }
+            i = j
+        }
+    }
+
+    return issues
+}
+
+// analyzeListIndentation flags lists indented with tabs on some lines and
+// spaces on others, which renderers nest inconsistently
+func (a *Analyzer) analyzeListIndentation(doc *Document) []Issue {
+    var issues []Issue
+
+    for _, list := range doc.Lists() {
+        first := list.Items[0].Line
+        last := list.Items[len(list.Items)-1].EndLine
+        tabs, spaces := 0, 0
+
+        for lineNum := first; lineNum <= last; lineNum++ {
+            line := doc.Lines[lineNum-1]
+            indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+            if indent == "" || strings.TrimSpace(line) == "" {
+                continue
+            }
+
+            if strings.Contains(indent, "\t") {
+                tabs++
+            }
+            if strings.Contains(indent, " ") {
+                spaces++
+            }
+            if tabs > 0 && spaces > 0 {
+                issues = append(issues, Issue{
+                    File:         doc.Path,
+                    Line:         lineNum,
+                    Column:       1,
+                    Rule:         "markdown-list-indentation",
+                    Message:      "List mixes tab and space indentation",
+                    Severity:     "warning",
+                    Suggestion:   "Indent nested list content consistently with spaces",
+                    OriginalText: strings.TrimSpace(line),
+                })
+                break
+            }
+        }
+    }
+
+    return issues
+}
@@ -0,0 +1,64 @@
+// Memory-mapped document loading for the corpus checks
+
+package main
+
+import (
+    "bytes"
+    "unicode/utf8"
+    "unsafe"
+)
+
+// mapDocuments loads files like loadDocuments, but maps each file into
+// memory instead of reading it. A UTF-8 file's content, lines and most
+// masked lines are then views of the mapping, which the kernel pages in as
+// the checks read them and can drop under memory pressure, so a corpus
+// larger than free memory can still be checked. Files that need decoding,
+// and archive members, are read as usual. The mappings last until the
+// process exits, as issues keep pointing into them.
+func mapDocuments(files []string, formats map[string]Format) []*Document {
+    var docs []*Document
+    for _, file := range files {
+        if hasEmbeddedDocs(file) {
+            continue // only its descriptions or comments are prose, analyzed file by file
+        }
+        if _, _, ok := splitArchivePath(file); ok {
+            docs = append(docs, loadDocuments([]string{file}, formats)...)
+            continue
+        }
+        data, err := mapFile(file)
+        if err != nil {
+            reportFailure(readFailureRule, file, 0, "failed to read %s: %v", file, err)
+            continue
+        }
+        content, ok := mappedText(data)
+        if !ok {
+            content = decodeText(data)
+            unmapFile(data)
+        }
+        docs = append(docs, corpusDocument(file, content, formats))
+    }
+    return docs
+}
+
+// mappedText returns data as a string sharing its memory, without a UTF-8
+// byte order mark, or false if data needs the conversion decodeText does
+func mappedText(data []byte) (string, bool) {
+    text := data
+    switch {
+    case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+        text = data[3:]
+    case bytes.HasPrefix(data, []byte{0xFF, 0xFE}), bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+        return "", false
+    default:
+        if _, ok := looksLikeUTF16(data); ok {
+            return "", false
+        }
+    }
+    if !utf8.Valid(text) {
+        return "", false
+    }
+    if len(text) == 0 {
+        return "", true
+    }
+    return unsafe.String(&text[0], len(text)), true
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+// Fallback for systems without memory mapping support here
+
+package main
+
+import "os"
+
+// mapFile reads path into memory, as memory mapping isn't implemented on
+// this system
+func mapFile(path string) ([]byte, error) {
+    return os.ReadFile(path)
+}
+
+// unmapFile does nothing, as mapFile's data is ordinary memory
+func unmapFile(data []byte) {}
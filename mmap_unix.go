@@ -0,0 +1,35 @@
+//go:build unix
+
+// Memory mapping on Unix systems
+
+package main
+
+import (
+    "os"
+    "syscall"
+)
+
+// mapFile maps path read-only into memory. An empty file yields no data,
+// since it can't be mapped.
+func mapFile(path string) ([]byte, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    info, err := file.Stat()
+    if err != nil {
+        return nil, err
+    }
+    if info.Size() == 0 {
+        return nil, nil
+    }
+    return syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// unmapFile releases a mapping made by mapFile
+func unmapFile(data []byte) {
+    if len(data) > 0 {
+        syscall.Munmap(data)
+    }
+}
@@ -0,0 +1,408 @@
+// Site navigation: reading order and hierarchy from nav files
+
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+var (
+    // summaryItemRegex matches a SUMMARY.md entry: "- [Title](path.md)",
+    // or a prefix chapter without a bullet
+    summaryItemRegex = regexp.MustCompile(`^(\s*)(?:[-*+]\s+)?\[([^\]]*)\]\(([^)]*)\)`)
+    // antoraItemRegex matches an Antora nav list item
+    antoraItemRegex = regexp.MustCompile(`^(\*+)\s+(.+?)\s*$`)
+    antoraXrefRegex = regexp.MustCompile(`xref:([^\[]+)\[([^\]]*)\]`)
+    // jsCommentRegex and trailingCommaRegex clean up a JavaScript sidebar
+    // object for the YAML parser, keeping line numbers
+    jsCommentRegex     = regexp.MustCompile(`(?m)(^|[^:"'])//.*$|/\*[\s\S]*?\*/`)
+    trailingCommaRegex = regexp.MustCompile(`,(\s*[\]}])`)
+)
+
+// NavPage is one page listed in a site's navigation
+type NavPage struct {
+    File   string   // path of the page, relative to the working directory
+    Titles []string // nav titles from the top-level section down to the page's own
+    Line   int      // 1-based line of the entry in the nav file
+}
+
+// Nav is a site's navigation in reading order
+type Nav struct {
+    Source  string    // the nav file
+    Pages   []NavPage // pages in reading order
+    Missing []NavPage // entries naming a file that doesn't exist
+    index   map[string]int
+}
+
+// loadNav reads a navigation file: mkdocs.yml, a Docusaurus sidebars file
+// (.js, .ts or .json), an Antora nav.adoc, or an mdBook or GitBook SUMMARY.md
+func loadNav(path string) (*Nav, error) {
+    if path == "" {
+        return nil, nil
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read nav: %w", err)
+    }
+
+    nav := &Nav{Source: path}
+    dir := filepath.Dir(path)
+    switch ext := strings.ToLower(filepath.Ext(path)); {
+    case ext == ".adoc":
+        nav.readAntora(string(data), dir)
+    case ext == ".md":
+        nav.readSummary(string(data), dir)
+    case strings.HasPrefix(strings.ToLower(filepath.Base(path)), "sidebars"):
+        err = nav.readSidebars(data, dir, ext == ".json")
+    case ext == ".yml" || ext == ".yaml":
+        err = nav.readMkDocs(data, dir)
+    default:
+        err = fmt.Errorf("unrecognized nav file (want mkdocs.yml, sidebars.js, nav.adoc or SUMMARY.md)")
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to parse nav %s: %w", path, err)
+    }
+
+    nav.index = make(map[string]int)
+    var pages []NavPage
+    for _, page := range nav.Pages {
+        key := navKey(page.File)
+        if _, err := os.Stat(page.File); err != nil {
+            nav.Missing = append(nav.Missing, page)
+            continue
+        }
+        if _, seen := nav.index[key]; !seen {
+            nav.index[key] = len(pages)
+            pages = append(pages, page)
+        }
+    }
+    nav.Pages = pages
+    return nav, nil
+}
+
+// add records the page target, relative to dir, at the given section
+// titles, ignoring external links
+func (n *Nav) add(dir, target string, titles []string, line int) {
+    if target == "" || externalLinkRegex.MatchString(target) {
+        return
+    }
+    if i := strings.IndexAny(target, "#?"); i >= 0 {
+        target = target[:i]
+    }
+    n.Pages = append(n.Pages, NavPage{File: filepath.Join(dir, filepath.FromSlash(target)), Titles: append([]string(nil), titles...), Line: line})
+}
+
+// readMkDocs reads the nav tree of mkdocs.yml, whose pages are relative to
+// docs_dir. The file is walked as a node tree, since MkDocs configs use
+// Python tags a plain decode rejects.
+func (n *Nav) readMkDocs(data []byte, dir string) error {
+    var root yaml.Node
+    if err := yaml.Unmarshal(data, &root); err != nil {
+        return err
+    }
+    if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+        return fmt.Errorf("no nav section")
+    }
+    docsDir := "docs"
+    var tree *yaml.Node
+    mapping := root.Content[0]
+    for i := 0; i+1 < len(mapping.Content); i += 2 {
+        switch mapping.Content[i].Value {
+        case "docs_dir":
+            docsDir = mapping.Content[i+1].Value
+        case "nav":
+            tree = mapping.Content[i+1]
+        }
+    }
+    if tree == nil {
+        return fmt.Errorf("no nav section")
+    }
+
+    var walk func(node *yaml.Node, titles []string)
+    walk = func(node *yaml.Node, titles []string) {
+        for _, item := range node.Content {
+            switch item.Kind {
+            case yaml.ScalarNode:
+                n.add(filepath.Join(dir, docsDir), item.Value, append(titles, pageTitle(item.Value)), item.Line)
+            case yaml.MappingNode:
+                for i := 0; i+1 < len(item.Content); i += 2 {
+                    title, value := item.Content[i].Value, item.Content[i+1]
+                    if value.Kind == yaml.SequenceNode {
+                        walk(value, append(titles, title))
+                    } else {
+                        n.add(filepath.Join(dir, docsDir), value.Value, append(titles, title), value.Line)
+                    }
+                }
+            }
+        }
+    }
+    walk(tree, nil)
+    return nil
+}
+
+// readSidebars reads a Docusaurus sidebars file. A JavaScript or TypeScript
+// file is reduced to its object literal, which the YAML parser reads once
+// comments and trailing commas are gone. Doc ids resolve under docs/ next
+// to the sidebars file; autogenerated and link items are skipped.
+func (n *Nav) readSidebars(data []byte, dir string, isJSON bool) error {
+    text := string(data)
+    if !isJSON {
+        text = jsCommentRegex.ReplaceAllStringFunc(text, func(comment string) string {
+            prefix := ""
+            if !strings.HasPrefix(comment, "/") {
+                prefix = comment[:1]
+            }
+            return prefix + strings.Repeat("\n", strings.Count(comment, "\n"))
+        })
+        start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+        if start < 0 || end < start {
+            return fmt.Errorf("no sidebar object")
+        }
+        text = strings.Repeat("\n", strings.Count(text[:start], "\n")) + trailingCommaRegex.ReplaceAllString(text[start:end+1], "$1")
+    }
+
+    var root yaml.Node
+    if err := yaml.Unmarshal([]byte(text), &root); err != nil {
+        return err
+    }
+    if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+        return fmt.Errorf("no sidebar object")
+    }
+
+    docs := filepath.Join(dir, "docs")
+    addDoc := func(id string, titles []string, line int) {
+        file := filepath.Join(docs, id+".md")
+        for _, candidate := range []string{file, filepath.Join(docs, id+".mdx"), filepath.Join(docs, id, "index.md")} {
+            if _, err := os.Stat(candidate); err == nil {
+                file = candidate
+                break
+            }
+        }
+        n.add("", file, titles, line)
+    }
+    field := func(node *yaml.Node, key string) *yaml.Node {
+        for i := 0; i+1 < len(node.Content); i += 2 {
+            if node.Content[i].Value == key {
+                return node.Content[i+1]
+            }
+        }
+        return nil
+    }
+
+    var walk func(node *yaml.Node, titles []string)
+    walk = func(node *yaml.Node, titles []string) {
+        switch node.Kind {
+        case yaml.SequenceNode:
+            for _, item := range node.Content {
+                walk(item, titles)
+            }
+        case yaml.ScalarNode:
+            addDoc(node.Value, append(titles, pageTitle(node.Value)), node.Line)
+        case yaml.MappingNode:
+            kind := field(node, "type")
+            if kind == nil {
+                // Shorthand category: { "Label": [items] }
+                for i := 0; i+1 < len(node.Content); i += 2 {
+                    walk(node.Content[i+1], append(titles, node.Content[i].Value))
+                }
+                return
+            }
+            label := ""
+            if value := field(node, "label"); value != nil {
+                label = value.Value
+            }
+            switch kind.Value {
+            case "doc":
+                if id := field(node, "id"); id != nil {
+                    if label == "" {
+                        label = pageTitle(id.Value)
+                    }
+                    addDoc(id.Value, append(titles, label), id.Line)
+                }
+            case "category":
+                section := append(titles, label)
+                if link := field(node, "link"); link != nil {
+                    if id := field(link, "id"); id != nil {
+                        addDoc(id.Value, section, id.Line)
+                    }
+                }
+                if items := field(node, "items"); items != nil {
+                    walk(items, section)
+                }
+            }
+        }
+    }
+    // Each top-level key is one sidebar; its name isn't a nav title
+    sidebars := root.Content[0]
+    for i := 0; i+1 < len(sidebars.Content); i += 2 {
+        walk(sidebars.Content[i+1], nil)
+    }
+    return nil
+}
+
+// readAntora reads an Antora nav.adoc. Pages resolve under the module's
+// pages directory, or another module's with a "module:" prefix.
+func (n *Nav) readAntora(text, dir string) {
+    modules := filepath.Dir(dir)
+    var stack []string
+    group := ""
+    scanner := bufio.NewScanner(strings.NewReader(text))
+    for line := 1; scanner.Scan(); line++ {
+        raw := scanner.Text()
+        if strings.HasPrefix(raw, ".") && len(raw) > 1 && raw[1] != '.' {
+            group, stack = strings.TrimSpace(raw[1:]), nil
+            continue
+        }
+        match := antoraItemRegex.FindStringSubmatch(raw)
+        if match == nil {
+            continue
+        }
+        depth := len(match[1])
+        if len(stack) >= depth {
+            stack = stack[:depth-1]
+        }
+        for len(stack) < depth-1 {
+            stack = append(stack, "")
+        }
+
+        title, file := match[2], ""
+        if xref := antoraXrefRegex.FindStringSubmatch(match[2]); xref != nil {
+            target, module := xref[1], filepath.Base(dir)
+            if parts := strings.Split(target, ":"); len(parts) == 2 {
+                module, target = parts[0], parts[1]
+            } else if len(parts) > 2 {
+                target = "" // another component
+            }
+            if target != "" {
+                file = filepath.Join(module, "pages", target)
+            }
+            title = xref[2]
+            if title == "" {
+                title = pageTitle(target)
+            }
+        }
+        stack = append(stack, title)
+
+        var titles []string
+        if group != "" {
+            titles = append(titles, group)
+        }
+        for _, title := range stack {
+            if title != "" {
+                titles = append(titles, title)
+            }
+        }
+        n.add(modules, file, titles, line)
+    }
+}
+
+// readSummary reads an mdBook or GitBook SUMMARY.md: nested links, with
+// headings naming parts
+func (n *Nav) readSummary(text, dir string) {
+    type level struct {
+        indent int
+        title  string
+    }
+    var stack []level
+    part := ""
+    scanner := bufio.NewScanner(strings.NewReader(text))
+    for line := 1; scanner.Scan(); line++ {
+        raw := scanner.Text()
+        if match := atxHeadingRegex.FindStringSubmatch(strings.TrimSpace(raw)); match != nil {
+            if len(match[1]) > 1 || part != "" || len(n.Pages) > 0 {
+                part, stack = match[2], nil
+            }
+            continue
+        }
+        match := summaryItemRegex.FindStringSubmatch(raw)
+        if match == nil {
+            continue
+        }
+        indent := leadingWidth(match[1])
+        for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+            stack = stack[:len(stack)-1]
+        }
+        stack = append(stack, level{indent, match[2]})
+
+        var titles []string
+        if part != "" {
+            titles = append(titles, part)
+        }
+        for _, entry := range stack {
+            titles = append(titles, entry.title)
+        }
+        if target := strings.TrimSpace(match[3]); target != "" {
+            n.add(dir, target, titles, line)
+        }
+    }
+}
+
+// pageTitle names a page listed by path or id only
+func pageTitle(path string) string {
+    return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// navKey identifies a file regardless of how its path is written
+func navKey(file string) string {
+    if abs, err := filepath.Abs(file); err == nil {
+        return abs
+    }
+    return filepath.Clean(file)
+}
+
+// Lookup returns the nav entry for a file
+func (n *Nav) Lookup(file string) (NavPage, bool) {
+    if n == nil {
+        return NavPage{}, false
+    }
+    i, ok := n.index[navKey(file)]
+    if !ok {
+        return NavPage{}, false
+    }
+    return n.Pages[i], true
+}
+
+// Order sorts files into reading order: files in the nav first, in nav
+// order, then the rest as given
+func (n *Nav) Order(files []string) []string {
+    ordered := append([]string(nil), files...)
+    if n == nil {
+        return ordered
+    }
+    position := func(file string) int {
+        if i, ok := n.index[navKey(file)]; ok {
+            return i
+        }
+        return len(n.Pages)
+    }
+    sort.SliceStable(ordered, func(i, j int) bool { return position(ordered[i]) < position(ordered[j]) })
+    return ordered
+}
+
+// missingNavPages reports nav entries that name a file that doesn't exist
+func (n *Nav) missingNavPages() []Issue {
+    if n == nil {
+        return nil
+    }
+    var issues []Issue
+    for _, page := range n.Missing {
+        issues = append(issues, Issue{
+            File:       n.Source,
+            Line:       page.Line,
+            Column:     1,
+            Rule:       "nav-missing-page",
+            Message:    fmt.Sprintf("Navigation entry %q points to %s, which doesn't exist", strings.Join(page.Titles, " > "), page.File),
+            Severity:   "error",
+            Suggestion: "Fix the path or remove the entry from the navigation",
+        })
+    }
+    return issues
+}
@@ -0,0 +1,236 @@
+// Numeric value and unit consistency across the corpus
+
+package main
+
+import (
+    "fmt"
+    "math"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+const (
+    // valuePattern matches a number with an optional unit
+    valuePattern = `(?P<number>\d+(?:\.\d+)?)\s*(?P<unit>%|(?i:ms|milliseconds?|secs?|seconds?|s|mins?|minutes?|m|hrs?|hours?|h|days?|d|bytes?|[kmgt]i?b|kilobytes?|megabytes?|gigabytes?|terabytes?|percent)\b)?`
+    // parameterPattern matches a parameter name: a code span or one or two words
+    parameterPattern = "(?P<parameter>`[^`]+`|[A-Za-z][\\w.-]*(?:\\s+[a-z][\\w.-]*)?)"
+)
+
+var (
+    // valueStatementRegexes match a statement of a parameter's default or
+    // limit, capturing its qualifier, parameter, number and unit
+    valueStatementRegexes = []*regexp.Regexp{
+        // "the default timeout is 30 seconds", "a maximum file size of 5 GB"
+        regexp.MustCompile(`(?i)\b(?P<qualifier>default|maximum|minimum|max|min)\s+` + parameterPattern + `\s+(?:is\s+set\s+to|is|of)\s+(?:(?:about|approximately|up\s+to)\s+)?` + valuePattern),
+        // "the default value of timeout is 30 seconds"
+        regexp.MustCompile(`(?i)\b(?P<qualifier>default|maximum|minimum|max|min)(?:\s+value)?\s+(?:for|of)\s+(?:the\s+)?` + parameterPattern + `\s+is\s+` + valuePattern),
+        // "timeout defaults to 30s", "timeout has a maximum of 10"
+        regexp.MustCompile(`(?i)` + parameterPattern + `\s+(?:(?P<qualifier>default)s\s+to|has\s+an?\s+(?P<qualifier>default|maximum|minimum)(?:\s+value)?\s+of)\s+` + valuePattern),
+    }
+    // genericParameterWords name the kind of parameter rather than the parameter
+    genericParameterWords = map[string]bool{"option": true, "setting": true, "parameter": true, "field": true, "flag": true, "value": true, "property": true, "variable": true}
+    // nonParameterWords can't name a parameter
+    nonParameterWords = map[string]bool{"it": true, "this": true, "that": true, "which": true, "the": true, "a": true, "an": true, "value": true, "is": true}
+    // qualifierNames normalize the qualifier of a statement
+    qualifierNames = map[string]string{"default": "default", "maximum": "maximum", "max": "maximum", "minimum": "minimum", "min": "minimum"}
+)
+
+// valueUnit is a unit's canonical spelling, its dimension, and its size in
+// the dimension's base unit
+type valueUnit struct {
+    name      string
+    dimension string
+    scale     float64
+}
+
+// valueUnits map the lowercased spellings of units to what they measure
+var valueUnits = func() map[string]valueUnit {
+    units := make(map[string]valueUnit)
+    for _, unit := range []struct {
+        valueUnit
+        spellings string
+    }{
+        {valueUnit{"ms", "time", 0.001}, "ms millisecond milliseconds"},
+        {valueUnit{"s", "time", 1}, "s sec secs second seconds"},
+        {valueUnit{"min", "time", 60}, "m min mins minute minutes"},
+        {valueUnit{"h", "time", 3600}, "h hr hrs hour hours"},
+        {valueUnit{"d", "time", 86400}, "d day days"},
+        {valueUnit{"B", "size", 1}, "b byte bytes"},
+        {valueUnit{"KB", "size", 1e3}, "kb kilobyte kilobytes"},
+        {valueUnit{"MB", "size", 1e6}, "mb megabyte megabytes"},
+        {valueUnit{"GB", "size", 1e9}, "gb gigabyte gigabytes"},
+        {valueUnit{"TB", "size", 1e12}, "tb terabyte terabytes"},
+        {valueUnit{"KiB", "size", 1 << 10}, "kib"},
+        {valueUnit{"MiB", "size", 1 << 20}, "mib"},
+        {valueUnit{"GiB", "size", 1 << 30}, "gib"},
+        {valueUnit{"TiB", "size", 1 << 40}, "tib"},
+        {valueUnit{"%", "percent", 1}, "% percent"},
+    } {
+        for _, spelling := range strings.Fields(unit.spellings) {
+            units[spelling] = unit.valueUnit
+        }
+    }
+    return units
+}()
+
+// valueStatement is one stated default or limit
+type valueStatement struct {
+    file      string
+    line      int
+    column    int
+    key       string // qualifier and parameter, such as "default timeout"
+    text      string // the value as written, such as "30s"
+    unit      valueUnit
+    base      float64 // the value in the base unit of its dimension
+    statement string
+}
+
+// valueStatements returns the defaults and limits a document states in prose
+func valueStatements(doc *Document) []valueStatement {
+    var statements []valueStatement
+    for _, paragraph := range doc.Paragraphs() {
+        for _, sentence := range paragraph.Sentences() {
+            for _, regex := range valueStatementRegexes {
+                for _, match := range regex.FindAllStringSubmatchIndex(sentence.Text, -1) {
+                    if statement, ok := newValueStatement(regex, sentence, match); ok {
+                        statement.file = doc.Path
+                        statements = append(statements, statement)
+                    }
+                }
+            }
+        }
+    }
+    return statements
+}
+
+// newValueStatement builds a statement from a match of one of the
+// valueStatementRegexes
+func newValueStatement(regex *regexp.Regexp, sentence Sentence, match []int) (valueStatement, bool) {
+    text := sentence.Text
+    // named returns the first participating group of that name, and its start
+    named := func(name string) (string, int) {
+        for i, subexp := range regex.SubexpNames() {
+            if subexp == name && match[2*i] >= 0 {
+                return text[match[2*i]:match[2*i+1]], match[2*i]
+            }
+        }
+        return "", -1
+    }
+
+    // The parameter's last words name it; "the `timeout` option" is timeout
+    parameter, _ := named("parameter")
+    words := strings.Fields(strings.ToLower(strings.Trim(parameter, "`")))
+    for len(words) > 1 && genericParameterWords[words[len(words)-1]] {
+        words = words[:len(words)-1]
+    }
+    if len(words) > 1 && words[0] == "the" {
+        words = words[1:]
+    }
+    if nonParameterWords[words[0]] || nonParameterWords[words[len(words)-1]] {
+        return valueStatement{}, false
+    }
+
+    qualifier, _ := named("qualifier")
+    number, start := named("number")
+    value, err := strconv.ParseFloat(number, 64)
+    if err != nil {
+        return valueStatement{}, false
+    }
+    spelling, _ := named("unit")
+    unit, ok := valueUnits[strings.ToLower(spelling)]
+    if spelling != "" && !ok {
+        return valueStatement{}, false
+    }
+    if spelling == "" {
+        unit.scale = 1
+    }
+
+    before := text[:match[0]]
+    line, column := sentence.Line+strings.Count(before, "\n"), sentence.Column+match[0]
+    if newline := strings.LastIndex(before, "\n"); newline >= 0 {
+        column = match[0] - newline
+    }
+    return valueStatement{
+        line:      line,
+        column:    column,
+        key:       qualifierNames[strings.ToLower(qualifier)] + " " + strings.Join(words, " "),
+        text:      strings.TrimSpace(text[start:match[1]]),
+        unit:      unit,
+        base:      value * unit.scale,
+        statement: strings.TrimSpace(text[match[0]:match[1]]),
+    }, true
+}
+
+// analyzeValueConsistency flags a parameter whose default or limit is
+// stated differently in different places. "Timeout defaults to 30s" on one
+// page and "the default timeout is 60 seconds" on another give an
+// assistant two answers to choose from; "30s" against "30000 ms" is the
+// same answer in two units, which is reported as a suggestion.
+func (a *Analyzer) analyzeValueConsistency(docs []*Document) []Issue {
+    byKey := make(map[string][]valueStatement)
+    var keys []string
+    for _, doc := range docs {
+        if a.language(doc) != "en" {
+            continue // the statement patterns are English
+        }
+        for _, statement := range valueStatements(doc) {
+            if _, seen := byKey[statement.key]; !seen {
+                keys = append(keys, statement.key)
+            }
+            byKey[statement.key] = append(byKey[statement.key], statement)
+        }
+    }
+
+    var issues []Issue
+    for _, key := range keys {
+        statements := byKey[key]
+        for i, statement := range statements {
+            var conflict, notation *valueStatement
+            for j := range statements {
+                other := &statements[j]
+                if i == j {
+                    continue
+                }
+                switch {
+                case other.unit.dimension != statement.unit.dimension && other.unit.dimension != "" && statement.unit.dimension != "",
+                    other.unit.dimension == statement.unit.dimension && math.Abs(other.base-statement.base) > 1e-9*math.Abs(statement.base):
+                    if conflict == nil {
+                        conflict = other
+                    }
+                case other.unit.name != statement.unit.name:
+                    if notation == nil {
+                        notation = other
+                    }
+                }
+            }
+
+            name := strings.ToUpper(key[:1]) + key[1:]
+            switch {
+            case conflict != nil:
+                issues = append(issues, Issue{
+                    File:         statement.file,
+                    Line:         statement.line,
+                    Column:       statement.column,
+                    Rule:         "conflicting-values",
+                    Message:      fmt.Sprintf("%s is %s here but %s in %s:%d", name, statement.text, conflict.text, conflict.file, conflict.line),
+                    Severity:     "warning",
+                    Suggestion:   "Settle on the correct value and state it the same way everywhere, ideally on one reference page the others link to",
+                    OriginalText: statement.statement,
+                })
+            case notation != nil:
+                issues = append(issues, Issue{
+                    File:         statement.file,
+                    Line:         statement.line,
+                    Column:       statement.column,
+                    Rule:         "inconsistent-units",
+                    Message:      fmt.Sprintf("%s is written as %s here but %s in %s:%d", name, statement.text, notation.text, notation.file, notation.line),
+                    Severity:     "suggestion",
+                    Suggestion:   "Write the value with the same unit everywhere",
+                    OriginalText: statement.statement,
+                })
+            }
+        }
+    }
+    return issues
+}
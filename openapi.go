@@ -0,0 +1,352 @@
+// Consistency checks between prose docs and OpenAPI specs
+
+package main
+
+import (
+    "fmt"
+    "net/url"
+    "os"
+    "regexp"
+    "sort"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+var (
+    endpointMentionRegex = regexp.MustCompile(`\b(GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS)\s+` + "`?" + `(/[\w/{}:.~%-]*)`)
+    pathSpanRegex        = regexp.MustCompile("`(/[\\w/{}:.~%-]+)`")
+    parameterAfterRegex  = regexp.MustCompile("`([A-Za-z_][\\w.-]*)`\\s+(?:(?:query|path|header|body|request|form)\\s+)?(?:parameter|param|field|argument)s?\\b")
+    parameterBeforeRegex = regexp.MustCompile("\\b(?:parameter|param|field|argument)\\s+`([A-Za-z_][\\w.-]*)`")
+    enumAssignRegex      = regexp.MustCompile("`([A-Za-z_][\\w.-]*)`\\s*(?:=|:|\\s+to)\\s*`([^`]+)`|`([A-Za-z_][\\w.-]*)=([^`\\s]+)`")
+    pathTemplateRegex    = regexp.MustCompile(`^\{[^}]*\}$|^:\w+$|^<[^>]*>$`)
+)
+
+// OpenAPIConfig points the API reference check at one or more specs
+type OpenAPIConfig struct {
+    Specs []string `yaml:"Specs"` // OpenAPI 3 or Swagger 2 files, YAML or JSON
+}
+
+// APISpec is the endpoint, parameter and enum inventory of the configured specs
+type APISpec struct {
+    basePaths  []string
+    operations map[string]map[string]bool // path template -> method -> exists
+    parameters map[string]bool            // parameter and schema property names
+    enums      map[string][]string        // parameter or property name -> allowed values
+}
+
+// loadAPISpec reads the configured specs, or returns nil when none are configured
+func loadAPISpec(config OpenAPIConfig) (*APISpec, error) {
+    if len(config.Specs) == 0 {
+        return nil, nil
+    }
+
+    spec := &APISpec{
+        operations: make(map[string]map[string]bool),
+        parameters: make(map[string]bool),
+        enums:      make(map[string][]string),
+    }
+    for _, path := range config.Specs {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read OpenAPI spec %s: %w", path, err)
+        }
+        var root map[string]interface{}
+        if err := yaml.Unmarshal(data, &root); err != nil {
+            return nil, fmt.Errorf("failed to parse OpenAPI spec %s: %w", path, err)
+        }
+        spec.add(root)
+    }
+
+    return spec, nil
+}
+
+// add merges one parsed spec document into the inventory
+func (s *APISpec) add(root map[string]interface{}) {
+    if basePath, ok := root["basePath"].(string); ok && basePath != "/" {
+        s.basePaths = append(s.basePaths, strings.TrimSuffix(basePath, "/"))
+    }
+    for _, server := range asList(root["servers"]) {
+        if raw, ok := asMap(server)["url"].(string); ok {
+            if parsed, err := url.Parse(raw); err == nil && parsed.Path != "" && parsed.Path != "/" {
+                s.basePaths = append(s.basePaths, strings.TrimSuffix(parsed.Path, "/"))
+            }
+        }
+    }
+
+    resolve := func(node interface{}) map[string]interface{} {
+        return resolveRef(root, node, 0)
+    }
+
+    for path, item := range asMap(root["paths"]) {
+        methods := make(map[string]bool)
+        pathItem := resolve(item)
+        for _, param := range asList(pathItem["parameters"]) {
+            s.addParameter(resolve(param), resolve)
+        }
+        for method, operation := range pathItem {
+            switch method {
+            case "get", "post", "put", "patch", "delete", "head", "options", "trace":
+            default:
+                continue
+            }
+            methods[strings.ToUpper(method)] = true
+            op := resolve(operation)
+            for _, param := range asList(op["parameters"]) {
+                s.addParameter(resolve(param), resolve)
+            }
+            for _, media := range asMap(resolve(op["requestBody"])["content"]) {
+                s.addSchema(resolve(asMap(media)["schema"]), resolve, 0)
+            }
+        }
+        s.operations[path] = methods
+    }
+
+    for _, schema := range asMap(asMap(root["components"])["schemas"]) {
+        s.addSchema(resolve(schema), resolve, 0)
+    }
+    for _, schema := range asMap(root["definitions"]) {
+        s.addSchema(resolve(schema), resolve, 0)
+    }
+}
+
+// addParameter records a parameter's name and, if present, its enum
+func (s *APISpec) addParameter(param map[string]interface{}, resolve func(interface{}) map[string]interface{}) {
+    name, ok := param["name"].(string)
+    if !ok {
+        return
+    }
+    s.parameters[name] = true
+    s.addEnum(name, param["enum"])
+    if schema := resolve(param["schema"]); schema != nil {
+        s.addEnum(name, schema["enum"])
+        s.addSchema(schema, resolve, 0)
+    }
+}
+
+// addSchema records the property names and enums of a schema, recursing
+// into nested objects, arrays and compositions
+func (s *APISpec) addSchema(schema map[string]interface{}, resolve func(interface{}) map[string]interface{}, depth int) {
+    if schema == nil || depth > 8 {
+        return
+    }
+    for name, property := range asMap(schema["properties"]) {
+        prop := resolve(property)
+        s.parameters[name] = true
+        s.addEnum(name, prop["enum"])
+        s.addSchema(prop, resolve, depth+1)
+    }
+    s.addSchema(resolve(schema["items"]), resolve, depth+1)
+    for _, key := range []string{"allOf", "oneOf", "anyOf"} {
+        for _, part := range asList(schema[key]) {
+            s.addSchema(resolve(part), resolve, depth+1)
+        }
+    }
+}
+
+// addEnum merges allowed values for name
+func (s *APISpec) addEnum(name string, values interface{}) {
+    for _, value := range asList(values) {
+        text := fmt.Sprint(value)
+        if !contains(s.enums[name], text) {
+            s.enums[name] = append(s.enums[name], text)
+        }
+    }
+}
+
+// resolveRef follows local "#/..." references until it reaches a map
+func resolveRef(root map[string]interface{}, node interface{}, depth int) map[string]interface{} {
+    m := asMap(node)
+    ref, ok := m["$ref"].(string)
+    if !ok || depth > 16 {
+        return m
+    }
+    if !strings.HasPrefix(ref, "#/") {
+        return nil
+    }
+
+    var target interface{} = root
+    for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+        part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+        target = asMap(target)[part]
+    }
+    return resolveRef(root, target, depth+1)
+}
+
+// asMap returns node as a map, or nil
+func asMap(node interface{}) map[string]interface{} {
+    m, _ := node.(map[string]interface{})
+    return m
+}
+
+// asList returns node as a list, or nil
+func asList(node interface{}) []interface{} {
+    l, _ := node.([]interface{})
+    return l
+}
+
+// contains reports whether values includes value
+func contains(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
+
+// findOperation returns the spec path template matching a documented path,
+// trying it with and without the spec's base paths. Template segments in
+// either path match any segment. Of several matching templates, the one
+// with the fewest template segments wins, then the first in order, so the
+// same operation is checked every run.
+func (s *APISpec) findOperation(path string) (string, bool) {
+    path = strings.TrimSuffix(strings.SplitN(path, "?", 2)[0], "/")
+    candidates := []string{path}
+    for _, base := range s.basePaths {
+        if strings.HasPrefix(path, base+"/") {
+            candidates = append(candidates, strings.TrimPrefix(path, base))
+        }
+    }
+
+    for _, candidate := range candidates {
+        if _, ok := s.operations[candidate]; ok {
+            return candidate, true
+        }
+    }
+    templates := sortedKeys(s.operations)
+    sort.SliceStable(templates, func(i, j int) bool {
+        return strings.Count(templates[i], "{") < strings.Count(templates[j], "{")
+    })
+    for _, candidate := range candidates {
+        for _, template := range templates {
+            if pathsMatch(candidate, strings.TrimSuffix(template, "/")) {
+                return template, true
+            }
+        }
+    }
+    return "", false
+}
+
+// looksLikeAPIPath reports whether a bare path in a code span shares its
+// first segment with the spec, so file paths like /etc/hosts are left alone
+func (s *APISpec) looksLikeAPIPath(path string) bool {
+    first := func(p string) string {
+        return strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2)[0]
+    }
+    for _, base := range s.basePaths {
+        if first(path) == first(base) {
+            return true
+        }
+    }
+    for template := range s.operations {
+        if first(path) == first(template) {
+            return true
+        }
+    }
+    return false
+}
+
+// pathsMatch compares two paths segment by segment
+func pathsMatch(documented, template string) bool {
+    a, b := strings.Split(documented, "/"), strings.Split(template, "/")
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] && !pathTemplateRegex.MatchString(a[i]) && !pathTemplateRegex.MatchString(b[i]) {
+            return false
+        }
+    }
+    return true
+}
+
+// analyzeAPIReferences flags endpoints, parameters and enum values mentioned
+// in prose that don't exist in the configured OpenAPI specs
+func (a *Analyzer) analyzeAPIReferences(doc *Document) []Issue {
+    if a.api == nil {
+        return nil
+    }
+
+    var issues []Issue
+    issue := func(line, column int, rule, severity, message, suggestion, text string) {
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         line,
+            Column:       column,
+            Rule:         rule,
+            Message:      message,
+            Severity:     severity,
+            Suggestion:   suggestion,
+            OriginalText: text,
+        })
+    }
+
+    for i, line := range doc.Masked {
+        if doc.Fenced[i] || i < doc.BodyStart-1 {
+            continue
+        }
+
+        mentioned := make(map[int]bool)
+        for _, match := range endpointMentionRegex.FindAllStringSubmatchIndex(line, -1) {
+            method, path := line[match[2]:match[3]], line[match[4]:match[5]]
+            mentioned[match[4]] = true
+            template, ok := a.api.findOperation(path)
+            switch {
+            case !ok:
+                issue(i+1, match[0]+1, "openapi-unknown-endpoint", "error",
+                    fmt.Sprintf("Endpoint %s %s is not in the OpenAPI spec", method, path),
+                    "Update the path to match the spec, or remove the stale reference", line[match[0]:match[1]])
+            case !a.api.operations[template][method]:
+                issue(i+1, match[0]+1, "openapi-unknown-endpoint", "error",
+                    fmt.Sprintf("%s is not a documented method for %s", method, template),
+                    fmt.Sprintf("Use one of: %s", strings.Join(sortedKeys(a.api.operations[template]), ", ")), line[match[0]:match[1]])
+            }
+        }
+        for _, match := range pathSpanRegex.FindAllStringSubmatchIndex(line, -1) {
+            path := line[match[2]:match[3]]
+            if mentioned[match[2]] || !a.api.looksLikeAPIPath(path) {
+                continue
+            }
+            if _, ok := a.api.findOperation(path); !ok {
+                issue(i+1, match[0]+1, "openapi-unknown-endpoint", "error",
+                    fmt.Sprintf("Endpoint %s is not in the OpenAPI spec", path),
+                    "Update the path to match the spec, or remove the stale reference", path)
+            }
+        }
+
+        for _, pattern := range []*regexp.Regexp{parameterAfterRegex, parameterBeforeRegex} {
+            for _, match := range pattern.FindAllStringSubmatchIndex(line, -1) {
+                name := line[match[2]:match[3]]
+                if !a.api.parameters[name] {
+                    issue(i+1, match[2]+1, "openapi-unknown-parameter", "warning",
+                        fmt.Sprintf("Parameter '%s' is not defined in the OpenAPI spec", name),
+                        "Check the parameter name against the spec", name)
+                }
+            }
+        }
+
+        for _, match := range enumAssignRegex.FindAllStringSubmatch(line, -1) {
+            name, value := firstNonEmpty(match[1], match[3]), firstNonEmpty(match[2], match[4])
+            allowed := a.api.enums[name]
+            if len(allowed) == 0 || contains(allowed, strings.Trim(value, `"'`)) {
+                continue
+            }
+            issue(i+1, strings.Index(line, match[0])+1, "openapi-invalid-enum", "error",
+                fmt.Sprintf("'%s' is not an allowed value of '%s'", value, name),
+                fmt.Sprintf("Use one of: %s", strings.Join(allowed, ", ")), match[0])
+        }
+    }
+
+    return issues
+}
+
+// sortedKeys returns the keys of set in order
+func sortedKeys[V any](set map[string]V) []string {
+    var keys []string
+    for key := range set {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    return keys
+}
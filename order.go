@@ -0,0 +1,30 @@
+// Deterministic issue order
+
+package main
+
+import "sort"
+
+// sortIssues orders issues by file path, line, column and rule, then by
+// message and text, so reports of the same tree are identical whichever
+// order the checks, the walk or the corpus pass produced them in
+func sortIssues(issues []Issue) {
+    sort.SliceStable(issues, func(i, j int) bool {
+        a, b := issues[i], issues[j]
+        if a.File != b.File {
+            return a.File < b.File
+        }
+        if a.Line != b.Line {
+            return a.Line < b.Line
+        }
+        if a.Column != b.Column {
+            return a.Column < b.Column
+        }
+        if a.Rule != b.Rule {
+            return a.Rule < b.Rule
+        }
+        if a.Message != b.Message {
+            return a.Message < b.Message
+        }
+        return a.OriginalText < b.OriginalText
+    })
+}
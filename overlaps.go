@@ -0,0 +1,98 @@
+// Resolution of rule matches that overlap on a line
+
+package main
+
+import (
+    "sort"
+    "strings"
+)
+
+// overlapPolicies are the accepted Overlaps values: report every match,
+// or of overlapping matches only the most severe or the narrowest one
+var overlapPolicies = []string{"all", "highest-severity", "most-specific"}
+
+// matchSpan is the byte range on its line of the text an issue quotes
+type matchSpan struct {
+    start, end int
+}
+
+// issueSpan returns the span of the line an issue's OriginalText quotes
+// at its column. Issues without one, and those quoting their whole line,
+// report a line or a section rather than a match in it and have no span.
+func issueSpan(doc *Document, issue Issue) (matchSpan, bool) {
+    if issue.File != doc.Path || issue.Column < 1 || issue.Line < 1 || issue.Line > len(doc.Lines) || issue.OriginalText == "" {
+        return matchSpan{}, false
+    }
+    line := doc.Lines[issue.Line-1]
+    start := issue.Column - 1
+    if start >= len(line) || !strings.HasPrefix(line[start:], issue.OriginalText) || issue.OriginalText == strings.TrimSpace(line) {
+        return matchSpan{}, false
+    }
+    return matchSpan{start, start + len(issue.OriginalText)}, true
+}
+
+// resolveOverlaps applies the Overlaps policy to a document's issues,
+// whose columns are still byte offsets. Of the issues whose matches
+// overlap on a line, highest-severity keeps the most severe and
+// most-specific the one quoting the least text, each breaking ties by the
+// other and then by the order the issues were found. Issues that don't
+// overlap a kept one are kept too, so two narrow matches inside a wide one
+// both survive it.
+func (a *Analyzer) resolveOverlaps(doc *Document, issues []Issue) []Issue {
+    policy := a.config.Overlaps
+    if policy == "" || policy == "all" {
+        return issues
+    }
+
+    type candidate struct {
+        index int
+        span  matchSpan
+        rank  int // severity rank
+    }
+    byLine := make(map[int][]candidate)
+    for i, issue := range issues {
+        if span, ok := issueSpan(doc, issue); ok {
+            byLine[issue.Line] = append(byLine[issue.Line], candidate{i, span, a.severities.rank(issue.Severity)})
+        }
+    }
+
+    dropped := make(map[int]bool)
+    for _, candidates := range byLine {
+        if len(candidates) < 2 {
+            continue
+        }
+        sort.SliceStable(candidates, func(i, j int) bool {
+            x, y := candidates[i], candidates[j]
+            widthX, widthY := x.span.end-x.span.start, y.span.end-y.span.start
+            if policy == "most-specific" && widthX != widthY {
+                return widthX < widthY
+            }
+            if x.rank != y.rank {
+                return x.rank > y.rank
+            }
+            return widthX < widthY
+        })
+        var kept []matchSpan
+    candidates:
+        for _, c := range candidates {
+            for _, span := range kept {
+                if c.span.start < span.end && span.start < c.span.end {
+                    dropped[c.index] = true
+                    continue candidates
+                }
+            }
+            kept = append(kept, c.span)
+        }
+    }
+    if len(dropped) == 0 {
+        return issues
+    }
+
+    var resolved []Issue
+    for i, issue := range issues {
+        if !dropped[i] {
+            resolved = append(resolved, issue)
+        }
+    }
+    return resolved
+}
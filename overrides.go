@@ -0,0 +1,176 @@
+// Per-path rule overrides
+
+package main
+
+import (
+    "fmt"
+    "path"
+    "path/filepath"
+    "strings"
+)
+
+// PathOverride adjusts rules for files matching any of its path globs.
+// Later overrides take precedence over earlier ones.
+type PathOverride struct {
+    Paths    []string          `yaml:"Paths"`              // globs; "**" matches any number of directories
+    Disable  []string          `yaml:"Disable,omitempty"`  // rule names to drop
+    Enable   []string          `yaml:"Enable,omitempty"`   // rule names an earlier override dropped, to report again
+    Severity map[string]string `yaml:"Severity,omitempty"` // rule name -> severity
+}
+
+// matches reports whether file falls under any of the override's globs
+func (o PathOverride) matches(file string) bool {
+    for _, pattern := range o.Paths {
+        if matchGlob(pattern, file) {
+            return true
+        }
+    }
+    return false
+}
+
+// matchGlob matches a slash-separated glob against file. A trailing slash
+// matches everything below a directory, and patterns without a leading
+// slash may match at any directory level, as in .gitignore.
+func matchGlob(pattern, file string) bool {
+    pattern = filepath.ToSlash(pattern)
+    if strings.HasSuffix(pattern, "/") {
+        pattern += "**"
+    }
+    segments := strings.Split(strings.TrimPrefix(filepath.ToSlash(filepath.Clean(file)), "./"), "/")
+
+    if strings.HasPrefix(pattern, "/") {
+        return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), segments)
+    }
+    parts := strings.Split(pattern, "/")
+    for start := range segments {
+        if matchSegments(parts, segments[start:]) {
+            return true
+        }
+    }
+    return false
+}
+
+// matchSegments matches glob segments against path segments, letting "**"
+// consume zero or more of them
+func matchSegments(pattern, segments []string) bool {
+    if len(pattern) == 0 {
+        return len(segments) == 0
+    }
+    if pattern[0] == "**" {
+        for i := 0; i <= len(segments); i++ {
+            if matchSegments(pattern[1:], segments[i:]) {
+                return true
+            }
+        }
+        return false
+    }
+    if len(segments) == 0 {
+        return false
+    }
+    if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+        return false
+    }
+    return matchSegments(pattern[1:], segments[1:])
+}
+
+// applyOverrides remaps severities from Severities, then drops disabled
+// rules and remaps severities according to the overrides matching each
+// issue's file. Only Enable brings back a rule an earlier override
+// disabled; a later Severity for it changes what it would be reported as.
+func (a *Analyzer) applyOverrides(issues []Issue) []Issue {
+    if len(a.config.Severities) == 0 && len(a.config.Overrides) == 0 {
+        return issues
+    }
+
+    var result []Issue
+    for _, issue := range issues {
+        if severity, ok := a.config.Severities[issue.Rule]; ok {
+            issue.Severity = severity
+        }
+        keep := true
+        for _, override := range a.config.Overrides {
+            if !override.matches(issue.File) {
+                continue
+            }
+            if severity, ok := override.Severity[issue.Rule]; ok {
+                issue.Severity = severity
+            }
+            for _, rule := range override.Enable {
+                if rule == issue.Rule {
+                    keep = true
+                }
+            }
+            for _, rule := range override.Disable {
+                if rule == issue.Rule {
+                    keep = false
+                }
+            }
+        }
+        if keep {
+            result = append(result, issue)
+        }
+    }
+    return result
+}
+
+// builtinCheckRules are the rule names the built-in checks report issues
+// under, which Severities can remap like those of pattern rules
+var builtinCheckRules = []string{
+    "anchor-changed", "boilerplate-paragraph", "callout-only-warning", "caption-deictic-narration",
+    "cli-undocumented-command", "cli-undocumented-flag", "cli-unknown-command", "cli-unknown-flag",
+    "conflicting-values", "data-file-missing-schema", "data-file-vague-reference", "dead-end-hub",
+    "deep-navigation", "deprecation-without-notice", "deprecation-without-version",
+    "destructive-command", "diagram-without-summary", "duplicate-canonical",
+    "duplicate-meta-description", "duplicate-title", "email-context-dependent", "email-visual-cta",
+    "error-inconsistent-structure", "error-missing-cause", "error-missing-message",
+    "error-missing-resolution", "faq-answer-not-self-contained", "faq-duplicate-question",
+    "faq-missing-answer", "faq-question-not-heading", "file-skipped", "first-paragraph-context",
+    "front-matter-required-fields", "graphql-missing-description", "hardcoded-version",
+    "heading-case", "heading-content-mismatch", "heading-order", "heading-too-long", "hedging",
+    "html-css-content", "html-hidden-content", "html-table-flattening", "image-alt-text",
+    "include-failure", "inconsistent-units", "invalid-canonical", "invalid-code-snippet",
+    "invalid-rule", "issues-omitted", "legacy-page-unlabeled", "list-item-context",
+    "list-parallelism", "markdown-list-indentation", "markdown-malformed-link",
+    "markdown-table-columns", "markdown-unclosed-fence", "meta-description", "missing-audience",
+    "missing-canonical", "missing-from-nav", "missing-prerequisites", "missing-product-context",
+    "missing-translated-section", "missing-translation", "mixed-topic-paragraph",
+    "nav-missing-page", "noindex-page", "non-imperative-step", "openapi-unknown-endpoint",
+    "openapi-unknown-parameter", "orphan-page", "parameter-missing-details", "parameter-name-only",
+    "parse-failure", "passive-step", "pipe-to-shell", "possible-contradiction",
+    "privilege-unstated", "procedure-missing-outcome", "pronoun-subject", "raw-html",
+    "read-failure", "release-entry-missing-component", "release-entry-missing-version",
+    "release-entry-not-self-contained", "screenshot-only-procedure", "short-translation",
+    "single-step-procedure", "spelling", "step-numbering", "step-without-verb",
+    "tab-only-instruction", "table-header", "troubleshooting-missing-error-text",
+    "troubleshooting-missing-part", "troubleshooting-order", "undefined-jargon",
+    "unlabeled-platform-instruction", "unresolved-placeholder", "unresolved-reference",
+    "vague-quantifier", "vague-timing",
+}
+
+// validateRuleNames rejects Severities and Remediation.Minutes entries
+// that name no rule, by its name or one of its Aliases, and none of the
+// built-in checks' rules, which would otherwise be silently ignored, as a
+// misspelled name is
+func (c *Config) validateRuleNames(rules []Rule) error {
+    known := make(map[string]bool)
+    for _, name := range builtinCheckRules {
+        known[name] = true
+    }
+    for _, rule := range rules {
+        known[rule.Name] = true
+        for _, alias := range rule.Aliases {
+            known[alias] = true
+        }
+    }
+    for _, name := range sortedKeys(c.Severities) {
+        if !known[name] {
+            return fmt.Errorf("Severities: unknown rule %q (see the rules subcommand for the configured rules)", name)
+        }
+    }
+    for _, name := range sortedKeys(c.Remediation.Minutes) {
+        if !known[name] {
+            return fmt.Errorf("Remediation.Minutes: unknown rule %q (see the rules subcommand for the configured rules)", name)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,259 @@
+// Page metadata checks: canonical URLs, noindex and duplicate titles
+
+package main
+
+import (
+    "fmt"
+    "html"
+    "regexp"
+    "strings"
+)
+
+var (
+    linkTagRegex       = regexp.MustCompile(`(?is)<link\s[^>]*>`)
+    canonicalRelRegex  = regexp.MustCompile(`(?i)\brel\s*=\s*["']?canonical\b`)
+    linkHrefRegex      = regexp.MustCompile(`(?is)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+    htmlTitleRegex     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+    htmlH1Regex        = regexp.MustCompile(`(?is)<h1\b[^>]*>(.*?)</h1>`)
+    metaRobotsRegex    = regexp.MustCompile(`(?i)\bname\s*=\s*["'](?:robots|googlebot)["']`)
+    absoluteURLRegex   = regexp.MustCompile(`^https?://[^/\s]+`)
+    canonicalFieldKeys = []string{"canonical", "canonical_url", "canonicalURL", "canonical-url"}
+)
+
+// Title returns the page title from front matter or the HTML title tag,
+// with the line it's declared on, or "" if the page has none
+func (d *Document) Title() (string, int) {
+    if d.Format == "html" {
+        if match := htmlTitleRegex.FindStringSubmatchIndex(d.Content); match != nil {
+            title := strings.Join(strings.Fields(html.UnescapeString(d.Content[match[2]:match[3]])), " ")
+            return title, strings.Count(d.Content[:match[0]], "\n") + 1
+        }
+        return "", 1
+    }
+    if value, ok := d.FrontMatter["title"]; ok && value != nil {
+        return strings.TrimSpace(fmt.Sprint(value)), d.frontMatterLine("title")
+    }
+    return "", 1
+}
+
+// H1 returns the text of the page's first top-level heading, with its
+// line, or "" if the page has none
+func (d *Document) H1() (string, int) {
+    if d.Format == "html" {
+        if match := htmlH1Regex.FindStringSubmatchIndex(d.Content); match != nil {
+            return plainText(html.UnescapeString(d.Content[match[2]:match[3]])), strings.Count(d.Content[:match[0]], "\n") + 1
+        }
+        return "", 1
+    }
+    for _, section := range d.Sections {
+        if section.Level == 1 {
+            return plainText(customAnchorRegex.ReplaceAllString(section.Heading, "")), section.Line
+        }
+    }
+    return "", 1
+}
+
+// Canonical returns the page's canonical URL from an HTML link tag or a
+// front matter canonical, canonical_url or canonicalURL field, with the
+// line it's declared on, or "" if the page declares none
+func (d *Document) Canonical() (string, int) {
+    if d.Format == "html" {
+        for _, match := range linkTagRegex.FindAllStringIndex(d.Content, -1) {
+            tag := d.Content[match[0]:match[1]]
+            if !canonicalRelRegex.MatchString(tag) {
+                continue
+            }
+            href := ""
+            if value := linkHrefRegex.FindStringSubmatch(tag); value != nil {
+                href = strings.TrimSpace(value[1] + value[2])
+            }
+            return href, strings.Count(d.Content[:match[0]], "\n") + 1
+        }
+        return "", 1
+    }
+    for _, key := range canonicalFieldKeys {
+        if value, ok := d.FrontMatter[key]; ok && value != nil {
+            return strings.TrimSpace(fmt.Sprint(value)), d.frontMatterLine(key)
+        }
+    }
+    return "", 1
+}
+
+// Noindex reports whether the page asks search engines not to index it,
+// with a robots meta tag or a front matter noindex or robots field, and
+// the line it does so on
+func (d *Document) Noindex() (bool, int) {
+    if d.Format == "html" {
+        for _, match := range metaTagRegex.FindAllStringIndex(d.Content, -1) {
+            tag := d.Content[match[0]:match[1]]
+            if !metaRobotsRegex.MatchString(tag) {
+                continue
+            }
+            if content := metaContentRegex.FindStringSubmatch(tag); content != nil && strings.Contains(strings.ToLower(content[1]+content[2]), "noindex") {
+                return true, strings.Count(d.Content[:match[0]], "\n") + 1
+            }
+        }
+        return false, 0
+    }
+    if value, ok := d.FrontMatter["noindex"].(bool); ok && value {
+        return true, d.frontMatterLine("noindex")
+    }
+    if value, ok := d.FrontMatter["robots"]; ok && strings.Contains(strings.ToLower(fmt.Sprint(value)), "noindex") {
+        return true, d.frontMatterLine("robots")
+    }
+    return false, 0
+}
+
+// analyzePageMetadata flags HTML pages without a canonical URL, canonical
+// URLs that aren't absolute, and noindex pages: a page kept out of search
+// engines usually shouldn't be in the RAG corpus either
+func (a *Analyzer) analyzePageMetadata(doc *Document) []Issue {
+    var issues []Issue
+    canonical, line := doc.Canonical()
+    switch {
+    case canonical == "" && doc.Format == "html":
+        issues = append(issues, Issue{
+            File:       doc.Path,
+            Line:       1,
+            Column:     1,
+            Rule:       "missing-canonical",
+            Message:    "Page has no canonical URL",
+            Severity:   "suggestion",
+            Suggestion: `Add <link rel="canonical" href="..."> with the page's public URL, so copies of the page resolve to one citation`,
+        })
+    case canonical != "" && !absoluteURLRegex.MatchString(canonical):
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         line,
+            Column:       1,
+            Rule:         "invalid-canonical",
+            Message:      fmt.Sprintf("Canonical URL %q is not an absolute http(s) URL", canonical),
+            Severity:     "warning",
+            Suggestion:   "Use the full public URL, such as https://docs.example.com/guide/",
+            OriginalText: canonical,
+        })
+    }
+
+    if noindex, line := doc.Noindex(); noindex {
+        issues = append(issues, Issue{
+            File:       doc.Path,
+            Line:       line,
+            Column:     1,
+            Rule:       "noindex-page",
+            Message:    "Page is marked noindex but is part of the analyzed corpus",
+            Severity:   "warning",
+            Suggestion: "Leave it out of the RAG corpus (export -skip-noindex does), or drop noindex if the page is meant to be found",
+        })
+    }
+    return issues
+}
+
+// duplicatePageMetadata reports pages sharing a title, which citations
+// can't tell apart, and pages sharing a canonical URL, which are copies of
+// one page that would enter the corpus twice
+func duplicatePageMetadata(docs []*Document) []Issue {
+    issues := duplicateTitles(docs)
+    for _, group := range groupDocuments(docs, func(doc *Document) string { canonical, _ := doc.Canonical(); return canonical }) {
+        var paths []string
+        for _, doc := range group {
+            paths = append(paths, doc.Path)
+        }
+        for _, doc := range group {
+            canonical, line := doc.Canonical()
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         line,
+                Column:       1,
+                Rule:         "duplicate-canonical",
+                Message:      fmt.Sprintf("Canonical URL %s is shared by %s", canonical, strings.Join(paths, ", ")),
+                Severity:     "warning",
+                Suggestion:   "Keep one of the copies in the corpus, or correct the canonical URL of the page that isn't a copy",
+                OriginalText: canonical,
+            })
+        }
+    }
+    return issues
+}
+
+// duplicateTitles reports each page whose title or H1 is the title or H1
+// of another page, as copy-pasted pages often are, once, listing the pages
+// it collides with. Titles are compared case-insensitively.
+func duplicateTitles(docs []*Document) []Issue {
+    type pageTitle struct {
+        kind, text string
+        line       int
+    }
+    titles := make([][]pageTitle, len(docs))
+    byTitle := make(map[string][]int)
+    for i, doc := range docs {
+        title, titleLine := doc.Title()
+        h1, h1Line := doc.H1()
+        for _, t := range []pageTitle{{"Title", title, titleLine}, {"H1", h1, h1Line}} {
+            key := strings.ToLower(t.text)
+            if key == "" || (len(titles[i]) > 0 && strings.ToLower(titles[i][0].text) == key) {
+                continue
+            }
+            titles[i] = append(titles[i], t)
+            byTitle[key] = append(byTitle[key], i)
+        }
+    }
+
+    var issues []Issue
+    for i, doc := range docs {
+        var first *pageTitle
+        var others []string
+        seen := map[int]bool{i: true}
+        for k, t := range titles[i] {
+            for _, j := range byTitle[strings.ToLower(t.text)] {
+                if seen[j] {
+                    continue
+                }
+                seen[j] = true
+                others = append(others, docs[j].Path)
+                if first == nil {
+                    first = &titles[i][k]
+                }
+            }
+        }
+        if first == nil {
+            continue
+        }
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         first.line,
+            Column:       1,
+            Rule:         "duplicate-title",
+            Message:      fmt.Sprintf("%s %q is also the title or H1 of %s", first.kind, first.text, strings.Join(others, ", ")),
+            Severity:     "warning",
+            Suggestion:   "Give each page a title and H1 that say what sets it apart, so retrieval and citations can tell the pages apart",
+            OriginalText: first.text,
+        })
+    }
+    return issues
+}
+
+// groupDocuments returns the groups of two or more documents with the same
+// value, compared case-insensitively, in order of first appearance.
+// Documents without a value are left out.
+func groupDocuments(docs []*Document, value func(*Document) string) [][]*Document {
+    byValue := make(map[string][]*Document)
+    var order []string
+    for _, doc := range docs {
+        key := strings.ToLower(value(doc))
+        if key == "" {
+            continue
+        }
+        if _, seen := byValue[key]; !seen {
+            order = append(order, key)
+        }
+        byValue[key] = append(byValue[key], doc)
+    }
+
+    var groups [][]*Document
+    for _, key := range order {
+        if len(byValue[key]) > 1 {
+            groups = append(groups, byValue[key])
+        }
+    }
+    return groups
+}
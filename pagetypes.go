@@ -0,0 +1,158 @@
+// Page type classification: conceptual, task, reference, troubleshooting
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// pageTypeNames are the page types rules can be limited to
+var pageTypeNames = []string{"conceptual", "task", "reference", "troubleshooting"}
+
+var (
+    // pageTypeAliases map front matter type values to page types
+    pageTypeAliases = map[string]string{
+        "concept": "conceptual", "conceptual": "conceptual", "overview": "conceptual", "explanation": "conceptual", "introduction": "conceptual", "about": "conceptual",
+        "quickstart": "task", "getting-started": "task",
+        "reference": "reference", "api": "reference", "api-reference": "reference", "cli": "reference", "cli-reference": "reference", "glossary": "reference", "specification": "reference",
+    }
+    // referenceTitleRegex matches the title of a reference page
+    referenceTitleRegex = regexp.MustCompile(`(?i)\b(?:reference|api|cli|commands|options|parameters|settings|properties|fields|endpoints|flags|environment variables|glossary|release notes|changelog)\b`)
+    // troubleshootingTitleRegex matches the title of a troubleshooting page
+    troubleshootingTitleRegex = regexp.MustCompile(`(?i)\b(?:troubleshoot(?:ing)?|known issues|common (?:problems|issues))\b`)
+    // definitionRegex matches the definition line of a definition list
+    definitionRegex = regexp.MustCompile(`^:\s+\S`)
+)
+
+// defaultPageTypes limit built-in rules that only make sense on some pages:
+// a reference table or concept page with a numbered list isn't a procedure
+// missing its outcome. The PageTypes config setting replaces these.
+var defaultPageTypes = map[string][]string{
+    "single-step-procedure":              {"task", "troubleshooting"},
+    "procedure-missing-outcome":          {"task", "troubleshooting"},
+    "missing-prerequisites":              {"task"},
+    "error-missing-message":              {"troubleshooting", "reference"},
+    "error-missing-cause":                {"troubleshooting", "reference"},
+    "error-missing-resolution":           {"troubleshooting", "reference"},
+    "error-inconsistent-structure":       {"troubleshooting", "reference"},
+    "parameter-name-only":                {"reference"},
+    "parameter-missing-details":          {"reference"},
+    "troubleshooting-missing-part":       {"troubleshooting"},
+    "troubleshooting-order":              {"troubleshooting"},
+    "troubleshooting-missing-error-text": {"troubleshooting"},
+}
+
+// minReferenceShare is the share of body lines in tables, definition lists
+// and code blocks that makes a page reference material
+const minReferenceShare = 0.5
+
+// classifyPage returns the type of a page: its front matter type when it
+// names one, otherwise a guess from its title and content
+func classifyPage(doc *Document) string {
+    for _, key := range []string{"type", "content_type", "page_type", "doc_type"} {
+        if value, ok := doc.FrontMatter[key].(string); ok {
+            value = strings.ToLower(strings.TrimSpace(value))
+            switch {
+            case taskTypes[value]:
+                return "task"
+            case errorPageTypes[value]:
+                return "troubleshooting"
+            case pageTypeAliases[value] != "":
+                return pageTypeAliases[value]
+            }
+            break
+        }
+    }
+
+    var title string
+    if value, ok := doc.FrontMatter["title"].(string); ok {
+        title = value
+    }
+    for _, section := range doc.Sections {
+        if title == "" && section.Level == 1 {
+            title = section.Heading
+        }
+    }
+    title = plainText(title)
+
+    switch {
+    case troubleshootingTitleRegex.MatchString(title) || errorEntries(doc) != nil:
+        return "troubleshooting"
+    case referenceTitleRegex.MatchString(title):
+        return "reference"
+    case isTaskPage(doc):
+        return "task"
+    case doc.referenceShare() >= minReferenceShare:
+        return "reference"
+    }
+    return "conceptual"
+}
+
+// referenceShare returns the share of non-blank body lines that are table
+// rows, definitions or code, the stuff of reference pages
+func (d *Document) referenceShare() float64 {
+    total, reference := 0, 0
+    for i := d.BodyStart - 1; i < len(d.Lines); i++ {
+        if i < 0 {
+            continue
+        }
+        trimmed := strings.TrimSpace(d.Lines[i])
+        if trimmed == "" || atxHeadingRegex.MatchString(trimmed) {
+            continue
+        }
+        total++
+        if d.Fenced[i] || strings.HasPrefix(trimmed, "|") || tableDividerRegex.MatchString(trimmed) || definitionRegex.MatchString(trimmed) {
+            reference++
+        }
+    }
+    if total == 0 {
+        return 0
+    }
+    return float64(reference) / float64(total)
+}
+
+// pageTypesFor returns the page types a rule runs on, or nil for all: the
+// PageTypes setting, else the rule's own, else the built-in default
+func (a *Analyzer) pageTypesFor(rule string) []string {
+    if types, ok := a.config.PageTypes[rule]; ok {
+        return types
+    }
+    for _, compiled := range a.rules {
+        if compiled.Name == rule && len(compiled.PageTypes) > 0 {
+            return compiled.PageTypes
+        }
+    }
+    return defaultPageTypes[rule]
+}
+
+// filterPageTypes drops the issues of rules that don't apply to pageType
+func (a *Analyzer) filterPageTypes(issues []Issue, pageType string) []Issue {
+    kept := issues[:0]
+    for _, issue := range issues {
+        types := a.pageTypesFor(issue.Rule)
+        applies := len(types) == 0
+        for _, t := range types {
+            applies = applies || t == pageType
+        }
+        if applies {
+            kept = append(kept, issue)
+        }
+    }
+    return kept
+}
+
+// validatePageTypes checks that every name is a known page type
+func validatePageTypes(types []string) error {
+    for _, t := range types {
+        known := false
+        for _, name := range pageTypeNames {
+            known = known || t == name
+        }
+        if !known {
+            return fmt.Errorf("invalid page type %q (want %s)", t, strings.Join(pageTypeNames, ", "))
+        }
+    }
+    return nil
+}
@@ -0,0 +1,189 @@
+// Parameter tables and definition lists checked for type, default and
+// description
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var (
+    // parameterHeaderRegex matches the first header cell of a table of
+    // parameters, options or settings
+    parameterHeaderRegex   = regexp.MustCompile(`(?i)^(?:parameter|param|option|flag|field|property|setting|argument|attribute|key|name|(?:environment |env )?variable)s?$`)
+    typeHeaderRegex        = regexp.MustCompile(`(?i)^(?:type|data type|value type|format)$`)
+    defaultHeaderRegex     = regexp.MustCompile(`(?i)^(?:default|defaults|default value)$`)
+    descriptionHeaderRegex = regexp.MustCompile(`(?i)^(?:description|details|meaning|purpose|notes?|explanation|summary|usage)$`)
+    // parameterTypeRegex and parameterDefaultRegex match a description that
+    // states the type or default itself
+    parameterTypeRegex    = regexp.MustCompile(`(?i)\b(?:string|integer|int|boolean|bool|number|float|double|array|list of|object|map|duration|enum|bytes|timestamp|true or false)\b`)
+    parameterDefaultRegex = regexp.MustCompile(`(?i)\bdefaults?\b|\bby default\b|\bunset\b|\bif (?:not set|omitted|unset)\b`)
+    // switchTermRegex matches a command-line switch that takes no value,
+    // whose type and default go without saying
+    switchTermRegex = regexp.MustCompile("^`?--?[A-Za-z][\\w-]*`?$")
+)
+
+// parameterColumns are the columns of a parameter table, -1 when missing
+type parameterColumns struct {
+    kind, fallback, description int
+}
+
+// analyzeParameters flags parameter and option entries that don't say
+// their type, their default or what they do. A model asked "what's the
+// default of retries?" can only answer from a default the docs write
+// down. In pipe tables whose first column names parameters, a row with
+// nothing but the name is reported as parameter-name-only, and rows with
+// empty type, default or description cells, or a table with no such
+// column, as parameter-missing-details. Definition list entries are
+// checked for a type and default in their definition.
+func (a *Analyzer) analyzeParameters(doc *Document) []Issue {
+    var issues []Issue
+    issue := func(rule string, line int, severity, message, suggestion string) {
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         line,
+            Column:       1,
+            Rule:         rule,
+            Message:      message,
+            Severity:     severity,
+            Suggestion:   suggestion,
+            OriginalText: strings.TrimSpace(doc.Lines[line-1]),
+        })
+    }
+
+    for i := 1; i < len(doc.Lines); i++ {
+        if doc.Fenced[i] || !tableDividerRegex.MatchString(doc.Lines[i]) || !strings.Contains(doc.Lines[i-1], "|") {
+            continue
+        }
+        header := tableCells(doc.Lines[i-1])
+        for j := range header {
+            header[j] = strings.TrimSpace(plainText(header[j]))
+        }
+        if !parameterHeaderRegex.MatchString(header[0]) {
+            continue
+        }
+        columns := parameterColumns{kind: -1, fallback: -1, description: -1}
+        for j, cell := range header[1:] {
+            switch {
+            case typeHeaderRegex.MatchString(cell):
+                columns.kind = j + 1
+            case defaultHeaderRegex.MatchString(cell):
+                columns.fallback = j + 1
+            case descriptionHeaderRegex.MatchString(cell):
+                columns.description = j + 1
+            }
+        }
+
+        var rows []int
+        for j := i + 1; j < len(doc.Lines) && !doc.Fenced[j] && strings.Contains(doc.Lines[j], "|"); j++ {
+            rows = append(rows, j)
+        }
+        i += len(rows)
+        typed, defaulted := 0, 0 // rows whose description states what a missing column would
+        for _, row := range rows {
+            cells := tableCells(doc.Lines[row])
+            cell := func(column int) string {
+                if column < 0 || column >= len(cells) {
+                    return ""
+                }
+                return strings.TrimSpace(plainText(cells[column]))
+            }
+            name := cell(0)
+            if name == "" {
+                continue
+            }
+            filled := false
+            for column := 1; column < len(cells); column++ {
+                filled = filled || cell(column) != ""
+            }
+            if !filled {
+                issue("parameter-name-only", row+1, "warning",
+                    fmt.Sprintf("Parameter '%s' has a row with nothing but its name", name),
+                    "Fill in its type, default and what it does")
+                continue
+            }
+
+            description := cell(columns.description)
+            if columns.description < 0 {
+                // Without a description column, the other cells describe it
+                for column := 1; column < len(cells); column++ {
+                    if column != columns.kind && column != columns.fallback {
+                        description += " " + cell(column)
+                    }
+                }
+                description = strings.TrimSpace(description)
+            }
+            if parameterTypeRegex.MatchString(description) {
+                typed++
+            }
+            if parameterDefaultRegex.MatchString(description) {
+                defaulted++
+            }
+
+            var missing []string
+            if columns.kind >= 0 && cell(columns.kind) == "" {
+                missing = append(missing, "type")
+            }
+            if columns.fallback >= 0 && cell(columns.fallback) == "" {
+                missing = append(missing, "default")
+            }
+            if description == "" {
+                missing = append(missing, "description")
+            }
+            if len(missing) > 0 {
+                issue("parameter-missing-details", row+1, "suggestion",
+                    fmt.Sprintf("Parameter '%s' has no %s", name, strings.Join(missing, " or ")),
+                    fmt.Sprintf("Fill in its %s; write 'none' rather than leave a cell empty", strings.Join(missing, " and ")))
+            }
+        }
+
+        var absent []string
+        if columns.kind < 0 && typed < len(rows) {
+            absent = append(absent, "Type")
+        }
+        if columns.fallback < 0 && defaulted < len(rows) {
+            absent = append(absent, "Default")
+        }
+        if len(rows) > 0 && len(absent) > 0 {
+            issue("parameter-missing-details", i-len(rows), "suggestion",
+                fmt.Sprintf("Parameter table has no %s column", strings.Join(absent, " or ")),
+                fmt.Sprintf("Add a %s column, so each parameter's row answers questions about it on its own", strings.Join(absent, " and a ")))
+        }
+    }
+
+    // Definition lists put each term on the line before its ": " definitions
+    for i := 1; i < len(doc.Lines); i++ {
+        if doc.Fenced[i] || !definitionRegex.MatchString(doc.Lines[i]) || definitionRegex.MatchString(doc.Lines[i-1]) {
+            continue
+        }
+        term := strings.TrimSpace(doc.Lines[i-1])
+        if !strings.HasPrefix(term, "`") && !strings.HasPrefix(term, "-") {
+            continue // a glossary term, not a parameter
+        }
+        if switchTermRegex.MatchString(term) {
+            continue
+        }
+        var definition []string
+        for j := i; j < len(doc.Lines) && !doc.Fenced[j] && definitionRegex.MatchString(doc.Lines[j]); j++ {
+            definition = append(definition, plainText(strings.TrimSpace(doc.Lines[j][1:])))
+        }
+        text := strings.Join(definition, " ")
+
+        var missing []string
+        if !parameterTypeRegex.MatchString(text) {
+            missing = append(missing, "type")
+        }
+        if !parameterDefaultRegex.MatchString(text) {
+            missing = append(missing, "default")
+        }
+        if len(missing) > 0 {
+            issue("parameter-missing-details", i, "suggestion",
+                fmt.Sprintf("Parameter '%s' isn't given a %s", strings.Trim(term, "`"), strings.Join(missing, " or ")),
+                fmt.Sprintf("State its %s in the definition, such as \"An integer. Defaults to 3.\"", strings.Join(missing, " and ")))
+        }
+    }
+
+    return issues
+}
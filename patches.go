@@ -0,0 +1,182 @@
+// Safe fixes written as patches for review instead of applied in place
+
+package main
+
+import (
+    "crypto/sha1"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// patchContext is the number of unchanged lines around each hunk
+const patchContext = 3
+
+// emitPatches writes the safe fixes of the issues to dir as one unified
+// diff per file, named after the file with a .patch suffix, and leaves the
+// files themselves alone. The patches apply with git apply or patch -p1
+// from the current directory, or with the apply subcommand, which merges
+// them into files changed since. It returns the number of patches written.
+func emitPatches(dir string, issues []Issue) (int, error) {
+    byFile := make(map[string][]int)
+    for i, issue := range issues {
+        if issue.Fix != nil && issue.URL == "" && issue.Fix.Safe {
+            byFile[issue.File] = append(byFile[issue.File], i)
+        }
+    }
+
+    written := 0
+    for _, file := range sortedKeys(byFile) {
+        fixed, err := fixContent(file, issues, byFile[file])
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Warning: no patch for %s: %v\n", file, err)
+            continue
+        }
+        if len(fixed.applied) == 0 {
+            continue
+        }
+        name := patchPath(file)
+        path := filepath.Join(dir, filepath.FromSlash(name)+".patch")
+        if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+            return written, err
+        }
+        if err := os.WriteFile(path, []byte(unifiedDiff(name, fixed)), 0o644); err != nil {
+            return written, err
+        }
+        written++
+    }
+    return written, nil
+}
+
+// patchPath returns the slash-separated path patches name a file by:
+// relative to the current directory when the file is under it, so the
+// patch applies from there, else its absolute path without the leading /
+func patchPath(file string) string {
+    abs, err := filepath.Abs(file)
+    if err != nil {
+        return filepath.ToSlash(filepath.Clean(file))
+    }
+    if dir, err := os.Getwd(); err == nil {
+        if rel, err := filepath.Rel(dir, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+            return filepath.ToSlash(rel)
+        }
+    }
+    return strings.TrimLeft(filepath.ToSlash(abs), "/")
+}
+
+// unifiedDiff renders a fixed file as a git-style unified diff. Its index
+// line names the blobs of both versions, so git apply --3way can merge the
+// patch into a file that has changed since, if the original is committed.
+// Fixes only rewrite and insert lines, so each output line either keeps,
+// rewrites or adds to the original line it comes from, and no longest
+// common subsequence is needed to line the two versions up.
+func unifiedDiff(name string, fixed *fixedFile) string {
+    type op struct {
+        kind byte // ' ' kept, '-' removed, '+' added
+        text string
+        last bool // line ends its version of the file without a newline
+    }
+
+    old := strings.Split(string(fixed.original), "\n")
+    lines := strings.Split(string(fixed.output), "\n")
+    // A final newline leaves an empty element, not a line
+    oldLines, newLines := len(old), len(lines)
+    if old[oldLines-1] == "" {
+        oldLines--
+    }
+    if lines[newLines-1] == "" {
+        newLines--
+    }
+
+    var ops []op
+    for i := 0; i < newLines; i++ {
+        line, from := lines[i], fixed.origin[i]
+        last := i == len(lines)-1
+        switch {
+        case from == 0:
+            ops = append(ops, op{'+', line, last})
+        case from > oldLines:
+            // the empty element after a final newline, with lines inserted after it
+        case old[from-1] == line:
+            ops = append(ops, op{' ', line, last && from == len(old)})
+        default:
+            ops = append(ops, op{'-', old[from-1], from == len(old)}, op{'+', line, last})
+        }
+    }
+
+    var diff strings.Builder
+    mode := "100644"
+    if fixed.perm&0o111 != 0 {
+        mode = "100755"
+    }
+    fmt.Fprintf(&diff, "diff --git a/%s b/%s\nindex %s..%s %s\n", name, name, gitBlobID(fixed.original), gitBlobID(fixed.output), mode)
+    fmt.Fprintf(&diff, "--- a/%s\n+++ b/%s\n", name, name)
+    oldLine, newLine := 1, 1 // numbers of the next line of each version
+    for start := 0; start < len(ops); {
+        if ops[start].kind == ' ' {
+            oldLine++
+            newLine++
+            start++
+            continue
+        }
+
+        // A hunk runs from patchContext lines before a change to
+        // patchContext lines after the last change close enough to share it
+        from := max(start-patchContext, 0)
+        end := start
+        for i := start; i < len(ops) && i-end <= 2*patchContext+1; i++ {
+            if ops[i].kind != ' ' {
+                end = i
+            }
+        }
+        end = min(end+patchContext+1, len(ops))
+
+        oldStart, newStart := oldLine-(start-from), newLine-(start-from)
+        var oldCount, newCount int
+        var body strings.Builder
+        for _, o := range ops[from:end] {
+            if o.kind != '+' {
+                oldCount++
+            }
+            if o.kind != '-' {
+                newCount++
+            }
+            fmt.Fprintf(&body, "%c%s\n", o.kind, o.text)
+            if o.last {
+                body.WriteString("\\ No newline at end of file\n")
+            }
+        }
+        fmt.Fprintf(&diff, "@@ -%s +%s @@\n%s", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount), body.String())
+
+        for _, o := range ops[start:end] {
+            if o.kind != '+' {
+                oldLine++
+            }
+            if o.kind != '-' {
+                newLine++
+            }
+        }
+        start = end
+    }
+    return diff.String()
+}
+
+// gitBlobID returns the object name git gives a file's content
+func gitBlobID(data []byte) string {
+    hash := sha1.New()
+    fmt.Fprintf(hash, "blob %d\x00", len(data))
+    hash.Write(data)
+    return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// hunkRange formats the start and length of one side of a hunk header
+func hunkRange(start, count int) string {
+    if count == 0 {
+        return fmt.Sprintf("%d,0", start-1)
+    }
+    if count == 1 {
+        return fmt.Sprintf("%d", start)
+    }
+    return fmt.Sprintf("%d,%d", start, count)
+}
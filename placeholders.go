@@ -0,0 +1,213 @@
+// Unresolved placeholders and hardcoded version numbers in prose
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var (
+    // unresolvedPlaceholderRegex matches placeholder syntax left in prose: a template
+    // reference no template syntax masked ({{ version }}), a shell or build
+    // variable (${YEAR}), and a named slot in angle brackets, escaped or not,
+    // or square brackets (<product>, &lt;your-name&gt;, [PRODUCT_NAME])
+    unresolvedPlaceholderRegex = regexp.MustCompile(`\{\{[^{}\n]*\}\}|\$\{[A-Za-z_][\w.]*\}|<([A-Za-z][\w-]*(?: [A-Za-z][\w-]*)?)>|&lt;([A-Za-z][\w-]*)&gt;|(?:^|[^!\]\w])\[([A-Za-z][\w-]*(?: [A-Za-z][\w-]*)*)\]`)
+    // placeholderWordRegex matches the words a slot's name is made of when
+    // it's a placeholder rather than an HTML tag or a link reference
+    placeholderWordRegex = regexp.MustCompile(`(?i)^(?:your|insert|enter|product|project|company|organization|org|brand|version|release|year|date|name|username|user|email|url|host|hostname|domain|token|placeholder|tbd)$`)
+    // placeholderSiteReferenceRegex matches a {{ }} reference to a site
+    // variable, such as {{ site.version }}, capturing its name
+    placeholderSiteReferenceRegex = templateReferenceRegex
+
+    // hardcodedVersionRegex matches a version number in prose, "version 3",
+    // "release is 2.4" or "v1.2", capturing the number. A bare number such as
+    // "SARIF 2.1.0" more often names a standard than the product's release.
+    hardcodedVersionRegex = regexp.MustCompile(`(?i)\b(?:(?:version|release)\s+(?:is\s+)?v?(\d+(?:\.\d+)*)|v(\d+(?:\.\d+)+))\b(?:[^.\w]|\.(?:\D|$)|$)`)
+    // versionFramedBeforeRegex and versionFramedAfterRegex match the words
+    // around a version that tie the sentence to it, so it stays true after
+    // later releases: "as of version 3.2", "introduced in 2.0", "4.1 or later"
+    versionFramedBeforeRegex = regexp.MustCompile(`(?i)\b(?:as of|since|starting (?:with|in|from)|from|(?:introduced|added|new|available|changed|deprecated|removed|fixed|released|shipped|updated) (?:in|with|on)|until|before|after|prior to|up to|through|between)\s+$`)
+    versionFramedAfterRegex  = regexp.MustCompile(`(?i)^\s*(?:(?:or|and) (?:later|newer|higher|above|up|earlier|older|lower|below)|through|to \d|\+|[-–]\s*\d|onward|and beyond)`)
+    // versionLinkRegex matches link targets and bare URLs, whose version
+    // segments aren't prose
+    versionLinkRegex = regexp.MustCompile(`\]\([^)]*\)|<[^>\s]*>|\bhttps?://\S+`)
+)
+
+// analyzeUnresolvedPlaceholders flags placeholders that reach readers
+// unfilled: template references outside any masked template syntax, shell
+// variables, named slots such as <product> or [PRODUCT_NAME], and, when
+// Variables are configured, references to site variables none of them
+// define. An assistant quotes "Install <product> ${VERSION}" as written.
+// Placeholders in code are left alone, where they're for the reader to
+// fill in.
+func (a *Analyzer) analyzeUnresolvedPlaceholders(doc *Document) []Issue {
+    var issues []Issue
+    for i := doc.BodyStart - 1; i < len(doc.Masked); i++ {
+        if doc.Fenced[i] {
+            continue
+        }
+        lineNum := i + 1
+        line := blankMatches(inlineCodeRegex, doc.Masked[i])
+        for _, match := range unresolvedPlaceholderRegex.FindAllStringSubmatchIndex(line, -1) {
+            start, end := match[0], match[1]
+            name := ""
+            for group := 2; group < len(match); group += 2 {
+                if match[group] >= 0 {
+                    name = line[match[group]:match[group+1]]
+                    if group == 6 {
+                        start = match[group] - 1 // past the character before the bracket
+                    }
+                }
+            }
+            if name != "" && !placeholderName(name) {
+                continue
+            }
+            if match[6] >= 0 && end < len(line) && strings.ContainsRune("([:", rune(line[end])) {
+                continue // link text, or a link reference
+            }
+            text := line[start:end]
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         lineNum,
+                Column:       start + 1,
+                Rule:         "unresolved-placeholder",
+                Message:      fmt.Sprintf("Unresolved placeholder %s in published text", text),
+                Severity:     "warning",
+                Suggestion:   "Replace the placeholder with its value, or define it as a variable the build fills in; a placeholder the reader is meant to fill in belongs in `code`",
+                OriginalText: text,
+            })
+        }
+
+        // Known site variables were expanded before the checks ran, so a
+        // reference left in the text names one the Variables don't define
+        if len(a.variables) == 0 {
+            continue
+        }
+        for _, match := range placeholderSiteReferenceRegex.FindAllStringSubmatchIndex(doc.Lines[i], -1) {
+            name := doc.Lines[i][match[2]:match[3]]
+            if unprefixed(strings.ToLower(name)) == strings.ToLower(name) || inlineCodeAt(doc.Lines[i], match[0]) {
+                continue // a page or loop variable, or code
+            }
+            if strings.TrimSpace(doc.Masked[i][match[0]:match[1]]) != "" {
+                continue // no template syntax masked it, so it's reported above
+            }
+            text := doc.Lines[i][match[0]:match[1]]
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         lineNum,
+                Column:       match[0] + 1,
+                Rule:         "unresolved-placeholder",
+                Message:      fmt.Sprintf("%s references site variable %s, which none of the configured Variables define", text, name),
+                Severity:     "warning",
+                Suggestion:   "Correct the variable name, or add the variable to the site configuration the Variables setting reads, so the build doesn't publish it blank",
+                OriginalText: text,
+            })
+        }
+    }
+    return issues
+}
+
+// placeholderName reports whether every word of a slot's name is one a
+// placeholder is made of, as in <product>, <your-name> or [PRODUCT_NAME]
+func placeholderName(name string) bool {
+    words := strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' || r == ' ' })
+    if len(words) == 0 {
+        return false
+    }
+    for _, word := range words {
+        if !placeholderWordRegex.MatchString(word) {
+            return false
+        }
+    }
+    return true
+}
+
+// inlineCodeAt reports whether byte offset lies in a code span of line
+func inlineCodeAt(line string, offset int) bool {
+    for _, span := range inlineCodeRegex.FindAllStringIndex(line, -1) {
+        if span[0] <= offset && offset < span[1] {
+            return true
+        }
+    }
+    return false
+}
+
+// analyzeHardcodedVersions flags version numbers in prose that state what
+// is current, such as "the latest release is 4.1" or "install version
+// 2.3.0", which stop being true with the next release while the sentence
+// keeps saying them. A version the sentence ties itself to ("as of 4.1",
+// "introduced in 2.0", "3.8 or later") stays true and isn't reported, nor
+// is one filled in from a variable. Release notes, headings and tables
+// are expected to name versions.
+func (a *Analyzer) analyzeHardcodedVersions(doc *Document) []Issue {
+    if isReleaseNotes(doc) {
+        return nil
+    }
+    var issues []Issue
+    for _, paragraph := range doc.Paragraphs() {
+        for lineNum := paragraph.StartLine; lineNum <= paragraph.EndLine; lineNum++ {
+            line := doc.Masked[lineNum-1]
+            if strings.HasPrefix(strings.TrimSpace(line), "|") {
+                continue
+            }
+            line = blankMatches(versionLinkRegex, blankMatches(inlineCodeRegex, line))
+            for _, match := range hardcodedVersionRegex.FindAllStringSubmatchIndex(line, -1) {
+                start, end := match[0], match[1]
+                version := ""
+                for group := 2; group < len(match); group += 2 {
+                    if match[group] >= 0 {
+                        version = line[match[group]:match[group+1]]
+                        end = match[group+1]
+                    }
+                }
+                if start > 0 && line[start-1] == '.' {
+                    continue // the tail of an address such as 10.0.0.1
+                }
+                if versionFramedBeforeRegex.MatchString(line[:start]) || versionFramedAfterRegex.MatchString(line[end:]) || doc.expandedAt(lineNum, start, end) {
+                    continue
+                }
+
+                suggestion := fmt.Sprintf("Fill the number in from a variable the build sets, such as {{ version }}, or tie the sentence to it with 'as of version %s', so it stays true after the next release", version)
+                if name := a.variableWithValue(version); name != "" {
+                    suggestion = fmt.Sprintf("Use the %s variable, whose value is %s, so the number follows the release, or tie the sentence to it with 'as of version %s'", name, version, version)
+                }
+                text := doc.Lines[lineNum-1][start:end]
+                issues = append(issues, Issue{
+                    File:         doc.Path,
+                    Line:         lineNum,
+                    Column:       start + 1,
+                    Rule:         "hardcoded-version",
+                    Message:      fmt.Sprintf("Hardcoded version '%s' in prose goes stale with the next release", text),
+                    Severity:     "suggestion",
+                    Suggestion:   suggestion,
+                    OriginalText: text,
+                })
+            }
+        }
+    }
+    return issues
+}
+
+// variableWithValue returns the name of a configured variable whose value
+// is value, or "" if there is none
+func (a *Analyzer) variableWithValue(value string) string {
+    for _, name := range sortedKeys(a.variables) {
+        if strings.TrimSpace(a.variables[name]) == value {
+            return name
+        }
+    }
+    return ""
+}
+
+// expandedAt reports whether the byte range [start, end) of a line holds
+// the value of an expanded variable reference
+func (d *Document) expandedAt(lineNum, start, end int) bool {
+    for _, span := range d.expanded[lineNum-1] {
+        if start < span.end && span.start < end {
+            return true
+        }
+    }
+    return false
+}
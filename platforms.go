@@ -0,0 +1,100 @@
+// Platform-specific instructions that don't name their platform
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// platform is an operating system, with the words that name it and the
+// commands, paths and files only instructions for it use
+type platform struct {
+    name    string
+    label   *regexp.Regexp
+    markers *regexp.Regexp
+}
+
+var platforms = []platform{
+    {
+        name:    "Windows",
+        label:   regexp.MustCompile(`\b(?:Windows|Win(?:32|64))\b|\bPowerShell\b`),
+        markers: regexp.MustCompile(`(?i:\b[\w-]+\.(?:exe|msi|bat|ps1)\b|%(?:APPDATA|LOCALAPPDATA|USERPROFILE|PROGRAMFILES|PROGRAMDATA)%|\b(?:choco|winget|scoop) install\b|\bregedit\b)|\b[A-Z]:\\|\b(?:Get|Set|New|Remove|Install|Start|Stop)-[A-Z][a-zA-Z]+\b|\bControl Panel\b`),
+    },
+    {
+        name:    "macOS",
+        label:   regexp.MustCompile(`\b(?:macOS|Mac|OS X|OSX)\b`),
+        markers: regexp.MustCompile(`\bbrew (?:install|upgrade|services)\b|\b[\w-]+\.dmg\b|~/Library/|/Applications/|\bSystem (?:Preferences|Settings)\b|\blaunchctl\b|\bCmd\+|⌘`),
+    },
+    {
+        name:    "Linux",
+        label:   regexp.MustCompile(`\b(?:Linux|Ubuntu|Debian|RHEL|Red Hat|CentOS|Fedora|SUSE|Alpine|Amazon Linux)\b`),
+        markers: regexp.MustCompile(`\b(?:apt|apt-get|yum|dnf|zypper|apk) (?:install|add)\b|\b[\w.-]+\.(?:deb|rpm)\b|\bsystemctl\b|\bsnap install\b`),
+    },
+}
+
+// platformFields are the front matter fields that name a page's platform
+var platformFields = []string{"os", "platform", "platforms"}
+
+// analyzePlatforms flags instructions only one operating system can follow,
+// such as "Run setup.exe" or "brew install", when neither the heading path
+// nor the section's text up to the end of the instruction names that
+// system. Retrieved text loses the tabs and icons that show the platform
+// on the rendered page, so a model hands the Windows steps to a macOS
+// reader. Instructions inside tabs are left to analyzeTabs.
+func (a *Analyzer) analyzePlatforms(doc *Document) []Issue {
+    var issues []Issue
+    declared := ""
+    if _, value, ok := lookupField(doc.FrontMatter, strings.Join(platformFields, "|")); ok {
+        declared = fmt.Sprint(value)
+    }
+
+    for _, section := range doc.Sections {
+        context := plainText(section.Breadcrumb()) + " " + declared
+        reported := make(map[string]bool)
+        for lineNum := section.StartLine; lineNum <= section.EndLine; lineNum++ {
+            line := doc.Masked[lineNum-1]
+            if (!doc.Fenced[lineNum-1] && !instructionRegex.MatchString(line)) || doc.inTab(lineNum) {
+                continue
+            }
+            for _, p := range platforms {
+                match := p.markers.FindStringIndex(line)
+                if match == nil || reported[p.name] || p.label.MatchString(context) {
+                    continue
+                }
+                // The text so far, and the rest of the instruction's block
+                end := lineNum
+                for end < section.EndLine && strings.TrimSpace(doc.Masked[end]) != "" {
+                    end++
+                }
+                if p.label.MatchString(strings.Join(doc.Masked[section.StartLine-1:end], "\n")) {
+                    continue
+                }
+                reported[p.name] = true
+                marker := line[match[0]:match[1]]
+                issues = append(issues, Issue{
+                    File:         doc.Path,
+                    Line:         lineNum,
+                    Column:       match[0] + 1,
+                    Rule:         "unlabeled-platform-instruction",
+                    Message:      fmt.Sprintf("Instruction is specific to %s (%q), but the text doesn't say so", p.name, marker),
+                    Severity:     "warning",
+                    Suggestion:   fmt.Sprintf("Name the platform in the text, e.g. 'On %s, ...', or in the heading, since retrieved text loses tabs and platform icons", p.name),
+                    OriginalText: strings.TrimSpace(doc.Lines[lineNum-1]),
+                })
+            }
+        }
+    }
+    return issues
+}
+
+// inTab reports whether a line is inside a tab pane
+func (d *Document) inTab(lineNum int) bool {
+    for _, tab := range d.Tabs {
+        if lineNum >= tab.StartLine && lineNum <= tab.EndLine {
+            return true
+        }
+    }
+    return false
+}
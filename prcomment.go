@@ -0,0 +1,302 @@
+// Posting results to a GitHub pull request
+
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// commentMarker identifies the summary comment, so later runs update it
+// instead of adding another
+const commentMarker = "<!-- ai-doc-optimizer -->"
+
+// maxSummaryRows caps the issue table in the summary comment
+const maxSummaryRows = 50
+
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// githubClient calls the GitHub REST API for one repository
+type githubClient struct {
+    api   string
+    token string
+    repo  string // "owner/name"
+    http  *http.Client
+}
+
+// runPRComment implements the pr-comment subcommand: it posts the issues of
+// a JSON report as a single summary comment on a pull request, updating the
+// comment from an earlier run, and optionally as inline review comments
+func runPRComment(args []string) int {
+    flags := flag.NewFlagSet("pr-comment", flag.ExitOnError)
+    repo := flags.String("repo", os.Getenv("GITHUB_REPOSITORY"), "Repository as owner/name")
+    pr := flags.Int("pr", 0, "Pull request number (default: from the GitHub Actions event)")
+    token := flags.String("token", "", "GitHub token (default: $GITHUB_TOKEN)")
+    api := flags.String("api", "", "GitHub API URL (default: $GITHUB_API_URL or https://api.github.com)")
+    inline := flags.Bool("inline", false, "Also comment on the changed lines that have issues")
+    flags.Parse(args)
+
+    if *token == "" {
+        *token = os.Getenv("GITHUB_TOKEN")
+    }
+    if *api == "" {
+        *api = os.Getenv("GITHUB_API_URL")
+    }
+    if *api == "" {
+        *api = "https://api.github.com"
+    }
+    if *pr == 0 {
+        *pr = eventPullRequest()
+    }
+    if flags.NArg() != 1 || *repo == "" || *pr == 0 || *token == "" {
+        fmt.Fprintf(os.Stderr, "Usage: %s pr-comment [options] <report.json>\n", os.Args[0])
+        fmt.Fprintln(os.Stderr, "A repository, pull request number and token are required.")
+        flags.PrintDefaults()
+        return 1
+    }
+
+    issues, severities, err := readReport(flags.Arg(0))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+
+    client := &githubClient{api: strings.TrimSuffix(*api, "/"), token: *token, repo: *repo, http: &http.Client{Timeout: 30 * time.Second}}
+    if err := client.upsertSummary(*pr, summaryComment(issues, severities)); err != nil {
+        fmt.Fprintf(os.Stderr, "Error posting summary comment: %v\n", err)
+        return 1
+    }
+    if *inline {
+        if err := client.postInlineComments(*pr, issues); err != nil {
+            fmt.Fprintf(os.Stderr, "Error posting inline comments: %v\n", err)
+            return 1
+        }
+    }
+    return 0
+}
+
+// eventPullRequest reads the pull request number from the GitHub Actions
+// event payload, returning 0 outside a pull request workflow
+func eventPullRequest() int {
+    data, err := os.ReadFile(os.Getenv("GITHUB_EVENT_PATH"))
+    if err != nil {
+        return 0
+    }
+    var event struct {
+        Number      int `json:"number"`
+        PullRequest struct {
+            Number int `json:"number"`
+        } `json:"pull_request"`
+    }
+    if json.Unmarshal(data, &event) != nil {
+        return 0
+    }
+    if event.PullRequest.Number != 0 {
+        return event.PullRequest.Number
+    }
+    return event.Number
+}
+
+// summaryComment renders the issues as the Markdown body of the summary
+// comment, counting them by the severities of the report
+func summaryComment(issues []Issue, severities severityPolicy) string {
+    var body strings.Builder
+    body.WriteString(commentMarker + "\n### Documentation issues\n\n")
+    if len(issues) == 0 {
+        body.WriteString("No new documentation issues found.\n")
+        return body.String()
+    }
+
+    bySeverity := make(map[string]int)
+    for _, issue := range issues {
+        bySeverity[issue.Severity]++
+    }
+    fmt.Fprintf(&body, "%d new issues: %s\n\n", len(issues), severities.describeCounts(bySeverity))
+
+    body.WriteString("| File | Line | Severity | Rule | Message |\n|---|---|---|---|---|\n")
+    for i, issue := range issues {
+        if i == maxSummaryRows {
+            fmt.Fprintf(&body, "\n…and %d more. Run ai-doc-optimizer locally for the full list.\n", len(issues)-maxSummaryRows)
+            break
+        }
+        fmt.Fprintf(&body, "| `%s` | %d | %s | `%s` | %s |\n",
+            repoPath(issue.File), issue.Line, issue.Severity, issue.Rule, strings.ReplaceAll(issue.Message, "|", `\|`))
+    }
+    return body.String()
+}
+
+// repoPath converts an issue's file path to the slash-separated form the
+// GitHub API uses for files relative to the repository root
+func repoPath(file string) string {
+    return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(file)), "./")
+}
+
+// upsertSummary updates the summary comment from an earlier run, or posts
+// a new one
+func (c *githubClient) upsertSummary(pr int, body string) error {
+    for page := 1; ; page++ {
+        var comments []struct {
+            ID   int64  `json:"id"`
+            Body string `json:"body"`
+        }
+        if err := c.do(http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100&page=%d", c.repo, pr, page), nil, &comments); err != nil {
+            return err
+        }
+        for _, comment := range comments {
+            if strings.HasPrefix(comment.Body, commentMarker) {
+                return c.do(http.MethodPatch, fmt.Sprintf("/repos/%s/issues/comments/%d", c.repo, comment.ID), map[string]string{"body": body}, nil)
+            }
+        }
+        if len(comments) < 100 {
+            break
+        }
+    }
+    return c.do(http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", c.repo, pr), map[string]string{"body": body}, nil)
+}
+
+// postInlineComments posts one review with a comment for each issue on a
+// line the pull request added, skipping issues already commented on by an
+// earlier run. GitHub rejects comments outside the diff, so other issues
+// appear only in the summary.
+func (c *githubClient) postInlineComments(pr int, issues []Issue) error {
+    var pull struct {
+        Head struct {
+            SHA string `json:"sha"`
+        } `json:"head"`
+    }
+    if err := c.do(http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d", c.repo, pr), nil, &pull); err != nil {
+        return err
+    }
+
+    added := make(map[string]map[int]bool)
+    for page := 1; ; page++ {
+        var files []struct {
+            Filename string `json:"filename"`
+            Patch    string `json:"patch"`
+        }
+        if err := c.do(http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d/files?per_page=100&page=%d", c.repo, pr, page), nil, &files); err != nil {
+            return err
+        }
+        for _, file := range files {
+            added[file.Filename] = addedLines(file.Patch)
+        }
+        if len(files) < 100 {
+            break
+        }
+    }
+
+    posted := make(map[string]bool)
+    for page := 1; ; page++ {
+        var existing []struct {
+            Path string `json:"path"`
+            Line int    `json:"line"`
+            Body string `json:"body"`
+        }
+        if err := c.do(http.MethodGet, fmt.Sprintf("/repos/%s/pulls/%d/comments?per_page=100&page=%d", c.repo, pr, page), nil, &existing); err != nil {
+            return err
+        }
+        for _, comment := range existing {
+            posted[fmt.Sprintf("%s:%d:%s", comment.Path, comment.Line, comment.Body)] = true
+        }
+        if len(existing) < 100 {
+            break
+        }
+    }
+
+    var comments []map[string]any
+    for _, issue := range issues {
+        path := repoPath(issue.File)
+        if !added[path][issue.Line] {
+            continue
+        }
+        body := fmt.Sprintf("%s\n**%s** `%s`: %s", commentMarker, issue.Severity, issue.Rule, issue.Message)
+        if issue.Suggestion != "" {
+            body += "\n\n" + issue.Suggestion
+        }
+        if posted[fmt.Sprintf("%s:%d:%s", path, issue.Line, body)] {
+            continue
+        }
+        comments = append(comments, map[string]any{"path": path, "line": issue.Line, "side": "RIGHT", "body": body})
+    }
+    if len(comments) == 0 {
+        return nil
+    }
+
+    review := map[string]any{
+        "commit_id": pull.Head.SHA,
+        "event":     "COMMENT",
+        "body":      fmt.Sprintf("ai-doc-optimizer found %d issues on changed lines.", len(comments)),
+        "comments":  comments,
+    }
+    return c.do(http.MethodPost, fmt.Sprintf("/repos/%s/pulls/%d/reviews", c.repo, pr), review, nil)
+}
+
+// addedLines returns the new-file line numbers a unified diff patch adds
+func addedLines(patch string) map[int]bool {
+    lines := make(map[int]bool)
+    line := 0
+    for _, text := range strings.Split(patch, "\n") {
+        if match := hunkHeaderRegex.FindStringSubmatch(text); match != nil {
+            line, _ = strconv.Atoi(match[1])
+            continue
+        }
+        switch {
+        case strings.HasPrefix(text, "+"):
+            lines[line] = true
+            line++
+        case strings.HasPrefix(text, "-"), strings.HasPrefix(text, `\`):
+            // removed lines and "\ No newline at end of file" don't advance
+        default:
+            line++
+        }
+    }
+    return lines
+}
+
+// do sends a request to the API, encoding body and decoding the response
+// into result when they aren't nil
+func (c *githubClient) do(method, path string, body, result any) error {
+    var reader io.Reader
+    if body != nil {
+        data, err := json.Marshal(body)
+        if err != nil {
+            return err
+        }
+        reader = bytes.NewReader(data)
+    }
+
+    request, err := http.NewRequest(method, c.api+path, reader)
+    if err != nil {
+        return err
+    }
+    request.Header.Set("Authorization", "Bearer "+c.token)
+    request.Header.Set("Accept", "application/vnd.github+json")
+    request.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+    if body != nil {
+        request.Header.Set("Content-Type", "application/json")
+    }
+
+    response, err := c.http.Do(request)
+    if err != nil {
+        return err
+    }
+    defer response.Body.Close()
+    if response.StatusCode >= 300 {
+        message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+        return fmt.Errorf("%s %s: %s: %s", method, path, response.Status, strings.TrimSpace(string(message)))
+    }
+    if result != nil {
+        return json.NewDecoder(response.Body).Decode(result)
+    }
+    return nil
+}
@@ -0,0 +1,208 @@
+// Literal prefilter for pattern rules
+
+package main
+
+import (
+    "regexp/syntax"
+)
+
+// minFilterLiteral is the length the shortest required literal of a rule
+// needs for the prefilter to skip the rule; shorter ones occur on most
+// lines anyway
+const minFilterLiteral = 2
+
+// ruleFilter picks the pattern rules worth running on a line. Most rules
+// can only match where one of a few literals occurs, such as "simply" or
+// "click here", so one Aho-Corasick scan for every rule's literals replaces
+// a regex evaluation per rule on most lines.
+//
+// The scan folds ASCII case only, which is exact for ASCII lines: every
+// rule runs on a line with other bytes, so case folding beyond ASCII, such
+// as "ſ" matching (?i)s, can't hide a match.
+type ruleFilter struct {
+    rules  int
+    always []int     // rules without required literals, run on every line
+    next   []int32   // automaton transitions, 128 per state
+    out    [][]int32 // rules whose literals end at each state
+}
+
+// newRuleFilter builds the automaton over the required literals of rules
+func newRuleFilter(rules []compiledRule) *ruleFilter {
+    f := &ruleFilter{rules: len(rules)}
+    f.addState()
+    for i, rule := range rules {
+        literals, ok := requiredLiterals(rule.pattern.String())
+        if !ok {
+            f.always = append(f.always, i)
+            continue
+        }
+        for _, literal := range literals {
+            f.insert(literal, int32(i))
+        }
+    }
+    f.link()
+    return f
+}
+
+// addState appends a state without transitions and returns it
+func (f *ruleFilter) addState() int32 {
+    state := int32(len(f.out))
+    for c := 0; c < 128; c++ {
+        f.next = append(f.next, -1)
+    }
+    f.out = append(f.out, nil)
+    return state
+}
+
+// insert adds literal to the trie, reporting rule at its end. Literals
+// with bytes beyond ASCII can't occur on the lines the automaton scans and
+// are left out.
+func (f *ruleFilter) insert(literal string, rule int32) {
+    state := int32(0)
+    for i := 0; i < len(literal); i++ {
+        c := literal[i]
+        if c >= 0x80 {
+            return
+        }
+        if f.next[int(state)*128+int(c)] < 0 {
+            child := f.addState()
+            f.next[int(state)*128+int(c)] = child
+        }
+        state = f.next[int(state)*128+int(c)]
+    }
+    f.out[state] = append(f.out[state], rule)
+}
+
+// link adds the failure transitions, turning the trie into an automaton
+// whose state after each byte is the longest literal prefix ending there
+func (f *ruleFilter) link() {
+    fail := make([]int32, len(f.out))
+    var queue []int32
+    for c := 0; c < 128; c++ {
+        if child := f.next[c]; child < 0 {
+            f.next[c] = 0
+        } else {
+            queue = append(queue, child)
+        }
+    }
+    for len(queue) > 0 {
+        state := queue[0]
+        queue = queue[1:]
+        f.out[state] = append(f.out[state], f.out[fail[state]]...)
+        for c := 0; c < 128; c++ {
+            i := int(state)*128 + c
+            if child := f.next[i]; child >= 0 {
+                fail[child] = f.next[int(fail[state])*128+c]
+                queue = append(queue, child)
+            } else {
+                f.next[i] = f.next[int(fail[state])*128+c]
+            }
+        }
+    }
+}
+
+// candidates reports, by rule index, the rules that may match text
+func (f *ruleFilter) candidates(text string) []bool {
+    run := make([]bool, f.rules)
+    for i := 0; i < len(text); i++ {
+        if text[i] >= 0x80 {
+            for j := range run {
+                run[j] = true
+            }
+            return run
+        }
+    }
+    for _, rule := range f.always {
+        run[rule] = true
+    }
+    state := int32(0)
+    for i := 0; i < len(text); i++ {
+        c := text[i]
+        if 'A' <= c && c <= 'Z' {
+            c += 'a' - 'A'
+        }
+        state = f.next[int(state)*128+int(c)]
+        for _, rule := range f.out[state] {
+            run[rule] = true
+        }
+    }
+    return run
+}
+
+// requiredLiterals returns literals, lowercased, one of which occurs in
+// every match of pattern, or false when no such set has literals of at
+// least minFilterLiteral bytes
+func requiredLiterals(pattern string) ([]string, bool) {
+    re, err := syntax.Parse(pattern, syntax.Perl)
+    if err != nil {
+        return nil, false
+    }
+    literals, ok := literalsOf(re.Simplify())
+    if !ok || shortest(literals) < minFilterLiteral {
+        return nil, false
+    }
+    return literals, true
+}
+
+// literalsOf returns a set of literals one of which every match of re
+// contains, if re has one
+func literalsOf(re *syntax.Regexp) ([]string, bool) {
+    switch re.Op {
+    case syntax.OpLiteral:
+        return []string{lowerASCII(string(re.Rune))}, true
+    case syntax.OpCapture, syntax.OpPlus:
+        return literalsOf(re.Sub[0])
+    case syntax.OpRepeat:
+        if re.Min >= 1 {
+            return literalsOf(re.Sub[0])
+        }
+    case syntax.OpConcat:
+        // Any part's literals will do; the longest shortest literal
+        // filters best, and fewer alternatives break ties
+        var best []string
+        for _, sub := range re.Sub {
+            literals, ok := literalsOf(sub)
+            if !ok {
+                continue
+            }
+            if best == nil || shortest(literals) > shortest(best) || (shortest(literals) == shortest(best) && len(literals) < len(best)) {
+                best = literals
+            }
+        }
+        return best, best != nil
+    case syntax.OpAlternate:
+        var all []string
+        for _, sub := range re.Sub {
+            literals, ok := literalsOf(sub)
+            if !ok {
+                return nil, false
+            }
+            all = append(all, literals...)
+        }
+        return all, true
+    }
+    return nil, false
+}
+
+// shortest returns the length of the shortest literal
+func shortest(literals []string) int {
+    n := -1
+    for _, literal := range literals {
+        if n < 0 || len(literal) < n {
+            n = len(literal)
+        }
+    }
+    return n
+}
+
+// lowerASCII lowercases the ASCII letters of s, the only folding the
+// filter's scan applies
+func lowerASCII(s string) string {
+    b := []byte(s)
+    for i, c := range b {
+        if 'A' <= c && c <= 'Z' {
+            b[i] = c + 'a' - 'A'
+        }
+    }
+    return string(b)
+}
@@ -0,0 +1,565 @@
+// Procedure (ordered step list) checks
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var imageReferenceRegex = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)|(?i)<img\b[^>]*>`)
+
+// stepPunctuation may surround an image without adding any text to a step
+const stepPunctuation = " \t\n.:;,-"
+
+// imageOnly reports whether a step's text consists of nothing but image references
+func imageOnly(text string) bool {
+    if !imageReferenceRegex.MatchString(text) {
+        return false
+    }
+    return strings.Trim(imageReferenceRegex.ReplaceAllString(text, ""), stepPunctuation) == ""
+}
+
+// analyzeScreenshotProcedures flags ordered procedures where most steps are
+// only an image reference, which text-only AI consumers can't follow
+func (a *Analyzer) analyzeScreenshotProcedures(doc *Document) []Issue {
+    var issues []Issue
+
+    for _, list := range doc.Lists() {
+        if !list.Ordered || len(list.Items) < 2 {
+            continue
+        }
+
+        imageSteps := 0
+        for _, item := range list.Items {
+            if imageOnly(item.Text) {
+                imageSteps++
+            }
+        }
+        if imageSteps*2 <= len(list.Items) {
+            continue
+        }
+
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         list.Items[0].Line,
+            Column:       1,
+            Rule:         "screenshot-only-procedure",
+            Message:      fmt.Sprintf("%d of %d steps consist only of an image", imageSteps, len(list.Items)),
+            Severity:     "error",
+            Suggestion:   "Write each step as text (action, location, expected result) and keep screenshots as supplements",
+            OriginalText: strings.TrimSpace(doc.Lines[list.Items[0].Line-1]),
+        })
+    }
+
+    return issues
+}
+
+var (
+    // outcomeRegex matches a sentence describing a procedure's result or
+    // how to verify it
+    outcomeRegex = regexp.MustCompile(`(?i)\b(?:you should (?:now )?see|you(?:'ll| will) see|should (?:now )?(?:see|appear|show|display|return|list|be)|appears?|(?:is|are) (?:now )?(?:displayed|shown|listed|created|available|running|ready|complete|saved|updated|deployed|installed)|displays|shows|lists|returns|succeeds|verify|confirm|check that|to check|to make sure|the (?:output|result|response)|now (?:has|have|is|are|shows|lists|contains)|success(?:ful(?:ly)?)?)\b`)
+    // outcomeHeadingRegex matches a heading that introduces the result
+    outcomeHeadingRegex = regexp.MustCompile(`(?i)^(?:result|results|outcome|verify|verification|next steps|what's next|confirm)`)
+    // beVerbs are verbs no instruction list covers but that make a step a clause
+    beVerbs = map[string]bool{
+        "is": true, "are": true, "was": true, "were": true, "be": true, "been": true, "has": true, "have": true,
+        "do": true, "does": true, "can": true, "should": true, "must": true, "will": true, "may": true, "need": true,
+        "needs": true, "let": true, "lets": true, "keep": true, "leave": true, "ensure": true, "accept": true, "sign": true,
+    }
+
+    // prerequisiteRegex matches a heading or label introducing what a task needs
+    prerequisiteRegex = regexp.MustCompile(`(?i)^(?:prerequisites?|pre-requisites?|requirements|before you (?:begin|start)|what you(?:'ll| will)? need|you(?:'ll| will) need)\b`)
+    // taskTitleRegex matches a title naming a task: "How to ...", "Install ...", "Configuring ..."
+    taskTitleRegex = regexp.MustCompile(`(?i)^(?:how to|how do i)\b`)
+    // taskTypes are front matter type values of task pages
+    taskTypes = map[string]bool{"task": true, "how-to": true, "howto": true, "how_to": true, "procedure": true, "tutorial": true}
+
+    // leadInRegex matches a sentence introducing a procedure
+    leadInRegex = regexp.MustCompile(`(?i)^(?:to\s|follow\b|complete\b|perform\b|do the following\b)|\bsteps?\b.*:$`)
+    // stepOptionalRegex matches a label before a step's instruction
+    stepOptionalRegex = regexp.MustCompile(`^(?i:optional|recommended|note)\s*:\s*`)
+    // stepSubjectRegex matches a step that opens with its subject rather
+    // than a verb: "The button should be clicked", "You need to run"
+    stepSubjectRegex = regexp.MustCompile(`^(?i:the|a|an|you|your|we|users?|this|that|these|it|they|there|one)\b`)
+    // passiveRegex matches a form of "be" followed by a past participle
+    passiveRegex = regexp.MustCompile(`(?i)\b(?:is|are|was|were|be|been|being)\s+(?:\w+ly\s+)?(\w+ed|done|made|shown|set|run|written|given|taken|chosen|seen|sent|built|found|kept|put|shut|hidden|held|begun)\b(\s+by\b)?`)
+    // modalPassiveRegex captures the object and participle of a passive
+    // instruction: "The Save button should be clicked"
+    modalPassiveRegex = regexp.MustCompile(`^(?i:the|a|an|your)\s+(.+?)\s+(?i:should|must|needs to|has to|is to|can|will|may)\s+be\s+(\w+)\b(.*)$`)
+    // modalInstructionRegex captures the instruction in "You should run ..."
+    modalInstructionRegex = regexp.MustCompile(`^(?i:you|users?)\s+(?i:should|must|need to|needs to|have to|has to|can|will|may)\s+(.+)$`)
+    // byUserRegex matches an agent the imperative makes redundant
+    byUserRegex = regexp.MustCompile(`(?i)^by\s+(?:the\s+)?(?:users?|you)\b\s*`)
+)
+
+// statives are participles that after "is" usually describe a state the
+// step checks or relies on, not an action: "Make sure the plugin is enabled"
+var statives = map[string]bool{
+    "allowed": true, "based": true, "called": true, "connected": true, "disabled": true, "enabled": true,
+    "expected": true, "installed": true, "located": true, "logged": true, "named": true, "needed": true,
+    "required": true, "selected": true, "signed": true, "supported": true, "used": true,
+}
+
+// imperativeVerbs are common instruction verbs. A step opening with one is
+// imperative, and a passive instruction's participle maps back to one.
+var imperativeVerbs = []string{
+    "add", "allow", "apply", "assign", "back", "browse", "build", "change", "check", "choose", "clear", "click",
+    "clone", "close", "configure", "confirm", "connect", "copy", "create", "define", "delete", "deploy", "disable",
+    "download", "drag", "edit", "enable", "enter", "expand", "export", "fill", "find", "generate", "go", "grant",
+    "import", "install", "launch", "log", "make", "move", "name", "navigate", "open", "paste", "press", "provide",
+    "remove", "rename", "replace", "restart", "review", "run", "save", "scroll", "select", "set", "sign", "specify",
+    "start", "stop", "submit", "switch", "tap", "test", "turn", "type", "uncheck", "update", "upload", "use",
+    "verify", "view", "wait", "write",
+}
+
+// irregularParticiples map past participles to their base verb
+var irregularParticiples = map[string]string{
+    "built": "build", "chosen": "choose", "done": "do", "found": "find", "given": "give", "made": "make",
+    "put": "put", "run": "run", "sent": "send", "set": "set", "shown": "show", "taken": "take", "written": "write",
+}
+
+// analyzeStepVoice flags procedure steps that aren't direct instructions:
+// steps opening with a subject instead of a verb, and steps whose
+// instruction is passive. Models follow "Click Save" more reliably than
+// "The Save button should be clicked", and so do readers.
+func (a *Analyzer) analyzeStepVoice(doc *Document) []Issue {
+    var issues []Issue
+
+    for _, list := range doc.Procedures() {
+        if len(list.Items) < 2 || !isProcedure(doc, list) {
+            continue
+        }
+
+        for _, item := range list.Items {
+            instruction, column := stepInstruction(doc, item)
+            if instruction == "" {
+                continue
+            }
+            original := strings.TrimSpace(doc.Lines[item.Line-1])
+
+            passive := passiveRegex.FindAllStringSubmatchIndex(instruction, -1)
+            if stepSubjectRegex.MatchString(instruction) {
+                message := fmt.Sprintf("Step %d doesn't start with an instruction verb", item.Number)
+                if len(passive) > 0 {
+                    message = fmt.Sprintf("Step %d is in the passive voice and doesn't start with an instruction verb", item.Number)
+                }
+                suggestion := "Start the step with the action to take, such as \"Click\", \"Enter\" or \"Run\""
+                if rewrite := imperativeRewrite(instruction); rewrite != "" {
+                    suggestion = fmt.Sprintf("Start the step with the action: %q", rewrite)
+                }
+                issues = append(issues, Issue{
+                    File:         doc.Path,
+                    Line:         item.Line,
+                    Column:       column,
+                    Rule:         "non-imperative-step",
+                    Message:      message,
+                    Severity:     "warning",
+                    Suggestion:   suggestion,
+                    OriginalText: original,
+                })
+                continue
+            }
+
+            for _, match := range passive {
+                participle := strings.ToLower(instruction[match[2]:match[3]])
+                if statives[participle] && match[4] < 0 {
+                    continue
+                }
+                issues = append(issues, Issue{
+                    File:         doc.Path,
+                    Line:         item.Line,
+                    Column:       column + match[0],
+                    Rule:         "passive-step",
+                    Message:      fmt.Sprintf("Step %d uses the passive voice (%q)", item.Number, instruction[match[0]:match[1]]),
+                    Severity:     "suggestion",
+                    Suggestion:   "Say who does what: address the reader directly (\"Click Deploy\") or name the component that acts (\"CloudSync deploys the app\")",
+                    OriginalText: original,
+                })
+                break
+            }
+        }
+    }
+
+    return issues
+}
+
+// isProcedure reports whether an ordered list is a set of steps rather than
+// a ranked or numbered enumeration: one of its steps opens with an
+// instruction verb, or the sentence before it introduces a procedure
+func isProcedure(doc *Document, list List) bool {
+    for _, item := range list.Items {
+        if instruction, _ := stepInstruction(doc, item); instruction != "" && imperativeVerb(firstWord(instruction)) != "" {
+            return true
+        }
+    }
+    for i := list.Items[0].Line - 2; i >= doc.BodyStart-1; i-- {
+        if trimmed := strings.TrimSpace(doc.Masked[i]); trimmed != "" {
+            return !doc.Fenced[i] && leadInRegex.MatchString(plainText(trimmed))
+        }
+    }
+    return false
+}
+
+// stepInstruction returns the first sentence of a step as plain text, with
+// any "Optional:" label removed, and its 1-based column
+func stepInstruction(doc *Document, item ListItem) (string, int) {
+    line := doc.Masked[item.Line-1]
+    text := strings.SplitN(item.Text, "\n", 2)[0]
+    offset := strings.Index(line, text)
+    if offset < 0 || imageOnly(text) {
+        return "", 0
+    }
+
+    sentences := Paragraph{StartLine: item.Line, Text: text}.Sentences()
+    if len(sentences) == 0 {
+        return "", 0
+    }
+    instruction := plainText(sentences[0].Text)
+    if label := stepOptionalRegex.FindString(instruction); label != "" {
+        instruction = instruction[len(label):]
+    }
+    return instruction, offset + sentences[0].Column
+}
+
+// firstWord returns the lowercased first word of text
+func firstWord(text string) string {
+    if fields := strings.Fields(text); len(fields) > 0 {
+        return strings.ToLower(strings.Trim(fields[0], ".,:;!?\"'"))
+    }
+    return ""
+}
+
+// imperativeVerb returns word as an instruction verb, or "" if it isn't one
+func imperativeVerb(word string) string {
+    for _, verb := range imperativeVerbs {
+        if word == verb {
+            return verb
+        }
+    }
+    return ""
+}
+
+// imperativeRewrite turns an indirect instruction into an imperative one:
+// "The Save button should be clicked" becomes "Click the Save button" and
+// "You need to enter the region" becomes "Enter the region". It returns ""
+// when the verb isn't a known instruction verb.
+func imperativeRewrite(instruction string) string {
+    if match := modalInstructionRegex.FindStringSubmatch(instruction); match != nil && imperativeVerb(firstWord(match[1])) != "" {
+        return strings.ToUpper(match[1][:1]) + match[1][1:]
+    }
+    match := modalPassiveRegex.FindStringSubmatch(instruction)
+    if match == nil {
+        return ""
+    }
+    verb := baseVerb(strings.ToLower(match[2]))
+    if verb == "" {
+        return ""
+    }
+    rest := byUserRegex.ReplaceAllString(strings.TrimSpace(match[3]), "")
+    rewrite := strings.ToUpper(verb[:1]) + verb[1:] + " the " + match[1]
+    if rest != "" && !strings.ContainsAny(rest[:1], ".,;:!?") {
+        rewrite += " "
+    }
+    return rewrite + rest
+}
+
+// baseVerb maps a past participle to the instruction verb it comes from
+func baseVerb(participle string) string {
+    if verb, ok := irregularParticiples[participle]; ok {
+        return verb
+    }
+    for _, verb := range imperativeVerbs {
+        switch participle {
+        case verb + "ed", verb + "d", verb + verb[len(verb)-1:] + "ed":
+            return verb
+        }
+        if strings.HasSuffix(verb, "y") && participle == verb[:len(verb)-1]+"ied" {
+            return verb
+        }
+    }
+    return ""
+}
+
+// analyzeStepNumbering flags ordered procedures whose numbers skip or
+// repeat, and one-step "procedures". Lists numbered "1." throughout are
+// fine, since Markdown renumbers them.
+func (a *Analyzer) analyzeStepNumbering(doc *Document) []Issue {
+    var issues []Issue
+
+    for _, list := range doc.Procedures() {
+        first := list.Items[0]
+        if len(list.Items) == 1 {
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         first.Line,
+                Column:       1,
+                Rule:         "single-step-procedure",
+                Message:      "Numbered list has a single step",
+                Severity:     "suggestion",
+                Suggestion:   "Write a single step as a sentence, or split it into the separate actions it involves",
+                OriginalText: strings.TrimSpace(doc.Lines[first.Line-1]),
+            })
+            continue
+        }
+
+        uniform := true
+        for _, item := range list.Items[1:] {
+            uniform = uniform && item.Number == first.Number
+        }
+        if uniform {
+            continue
+        }
+        for i, item := range list.Items[1:] {
+            previous := list.Items[i]
+            if item.Number == previous.Number+1 {
+                continue
+            }
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         item.Line,
+                Column:       1,
+                Rule:         "step-numbering",
+                Message:      fmt.Sprintf("Step %d follows step %d", item.Number, previous.Number),
+                Severity:     "warning",
+                Suggestion:   fmt.Sprintf("Renumber the step as %d, or restore the missing step", previous.Number+1),
+                OriginalText: strings.TrimSpace(doc.Lines[item.Line-1]),
+            })
+        }
+    }
+
+    return issues
+}
+
+// analyzeStepCompleteness flags procedure steps with no verb, which are
+// labels rather than actions, and procedures that never say what the
+// reader should see when they're done. A retrieved procedure without an
+// outcome gives a model nothing to check the result against.
+func (a *Analyzer) analyzeStepCompleteness(doc *Document) []Issue {
+    var issues []Issue
+
+    for _, list := range doc.Procedures() {
+        if len(list.Items) < 2 || !isProcedure(doc, list) {
+            continue
+        }
+
+        for _, item := range list.Items {
+            instruction, column := stepInstruction(doc, item)
+            if instruction == "" || hasVerb(instruction) {
+                continue
+            }
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         item.Line,
+                Column:       column,
+                Rule:         "step-without-verb",
+                Message:      fmt.Sprintf("Step %d has no verb", item.Number),
+                Severity:     "warning",
+                Suggestion:   fmt.Sprintf("Say what to do with %q, starting with the action, such as \"Open\" or \"Select\"", strings.TrimRight(instruction, ".:")),
+                OriginalText: strings.TrimSpace(doc.Lines[item.Line-1]),
+            })
+        }
+
+        last := list.Items[len(list.Items)-1]
+        if outcomeRegex.MatchString(plainText(strings.ReplaceAll(last.Text, "\n", " "))) || doc.outcomeAfter(last.EndLine) {
+            continue
+        }
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         last.Line,
+            Column:       1,
+            Rule:         "procedure-missing-outcome",
+            Message:      fmt.Sprintf("Procedure of %d steps doesn't describe its result", len(list.Items)),
+            Severity:     "suggestion",
+            Suggestion:   "End the procedure with what the reader should see when it succeeds, or how to verify it (\"The webhook now appears in the list with status Active.\")",
+            OriginalText: strings.TrimSpace(doc.Lines[last.Line-1]),
+        })
+    }
+
+    return issues
+}
+
+// hasVerb reports whether an instruction contains a recognizable verb
+func hasVerb(instruction string) bool {
+    for _, field := range strings.Fields(instruction) {
+        word := strings.ToLower(strings.Trim(field, ".,:;!?\"'()*_`"))
+        if beVerbs[word] || imperativeVerb(word) != "" || baseVerb(word) != "" || strings.HasSuffix(word, "ed") {
+            return true
+        }
+        if trimmed, ok := strings.CutSuffix(word, "ing"); ok && (imperativeVerb(trimmed) != "" || imperativeVerb(trimmed+"e") != "") {
+            return true
+        }
+        if trimmed, ok := strings.CutSuffix(word, "s"); ok && imperativeVerb(strings.TrimSuffix(trimmed, "e")) != "" {
+            return true
+        }
+    }
+    return false
+}
+
+// outcomeAfter reports whether the two paragraphs right after a procedure,
+// up to the next heading or list, describe its result. Code blocks in
+// between, such as sample output, are skipped.
+func (d *Document) outcomeAfter(endLine int) bool {
+    paragraphs := 0
+    for i := endLine; i < len(d.Lines); i++ {
+        trimmed := strings.TrimSpace(d.Masked[i])
+        if trimmed == "" || d.Fenced[i] {
+            continue
+        }
+        if i == endLine || strings.TrimSpace(d.Masked[i-1]) == "" || d.Fenced[i-1] {
+            if paragraphs++; paragraphs > 2 {
+                return false
+            }
+        }
+        if match := atxHeadingRegex.FindStringSubmatch(trimmed); match != nil {
+            return outcomeHeadingRegex.MatchString(strings.TrimSpace(strings.TrimLeft(trimmed, "#")))
+        }
+        if _, _, _, _, isItem := listMarker(d.Masked[i]); isItem {
+            return false
+        }
+        if outcomeRegex.MatchString(plainText(trimmed)) {
+            return true
+        }
+    }
+    return false
+}
+
+// introducesPrerequisites reports whether the 0-based line i introduces
+// what a task needs: a heading such as "Prerequisites", or a lead-in such
+// as "**Before you begin:**" or "You'll need the following:" that ends in
+// a colon or comes right before a list. A sentence such as "You'll need to
+// restart the service" in the middle of a paragraph doesn't.
+func (d *Document) introducesPrerequisites(i int) bool {
+    trimmed := strings.TrimSpace(d.Masked[i])
+    label := plainText(strings.TrimSpace(strings.TrimLeft(trimmed, "#")))
+    if !prerequisiteRegex.MatchString(label) {
+        return false
+    }
+    if atxHeadingRegex.MatchString(trimmed) {
+        return true
+    }
+    if i > d.BodyStart-1 && strings.TrimSpace(d.Masked[i-1]) != "" && !atxHeadingRegex.MatchString(strings.TrimSpace(d.Masked[i-1])) {
+        return false // inside a paragraph
+    }
+    if strings.HasSuffix(label, ":") {
+        return true
+    }
+    for next := i + 1; next < len(d.Masked); next++ {
+        if line := d.Masked[next]; strings.TrimSpace(line) != "" {
+            _, _, _, _, ok := listMarker(line)
+            return ok
+        }
+    }
+    return false
+}
+
+// analyzePrerequisites flags task pages without a prerequisites section
+// before their first procedure. A procedure retrieved on its own needs its
+// requirements stated up front, or a model starts the steps and fails
+// midway. The fix inserts a stub section to fill in.
+func (a *Analyzer) analyzePrerequisites(doc *Document) []Issue {
+    if !isTaskPage(doc) {
+        return nil
+    }
+    var first *List
+    for _, list := range doc.Procedures() {
+        if len(list.Items) >= 2 && isProcedure(doc, list) {
+            first = &list
+            break
+        }
+    }
+    if first == nil {
+        return nil
+    }
+    start := first.Items[0].Line
+
+    for i := doc.BodyStart - 1; i < len(doc.Lines); i++ {
+        if doc.Fenced[i] {
+            continue
+        }
+        if !doc.introducesPrerequisites(i) {
+            continue
+        }
+        if i+1 < start {
+            return nil
+        }
+        return []Issue{{
+            File:         doc.Path,
+            Line:         i + 1,
+            Column:       1,
+            Rule:         "missing-prerequisites",
+            Message:      "Prerequisites come after the first procedure",
+            Severity:     "warning",
+            Suggestion:   fmt.Sprintf("Move the prerequisites before the procedure at line %d", start),
+            OriginalText: strings.TrimSpace(doc.Lines[i]),
+        }}
+    }
+
+    // The stub goes before the procedure's section, or, when the procedure
+    // is in the title section, before it and the sentence introducing it
+    section, _ := doc.SectionAt(start)
+    line, level := section.Line, section.Level
+    if line == 0 || level <= 1 {
+        line, level = start, 2
+        above := line - 1
+        for above > section.StartLine && strings.TrimSpace(doc.Lines[above-1]) == "" {
+            above--
+        }
+        if above >= section.StartLine && strings.HasSuffix(strings.TrimSpace(doc.Lines[above-1]), ":") {
+            for line = above; line > section.StartLine && strings.TrimSpace(doc.Lines[line-2]) != ""; line-- {
+            }
+        }
+    }
+    heading := strings.Repeat("#", level) + " Prerequisites"
+    stub := heading + "\n\n- TODO: list the access, software, and setup this task requires\n"
+    if line > 1 && strings.TrimSpace(doc.Lines[line-2]) != "" {
+        stub = "\n" + stub
+    }
+    if strings.TrimSpace(doc.Lines[line-1]) != "" {
+        stub += "\n"
+    }
+
+    issue := Issue{
+        File:         doc.Path,
+        Line:         start,
+        Column:       1,
+        Rule:         "missing-prerequisites",
+        Message:      "Task page has no prerequisites section before its first procedure",
+        Severity:     "warning",
+        Suggestion:   fmt.Sprintf("Add a %q section before the procedure listing the access, software, and setup the task requires", heading),
+        OriginalText: strings.TrimSpace(doc.Lines[start-1]),
+    }
+    if doc.Format != "html" {
+        issue.Fix = &Fix{Line: line, Insert: stub}
+    }
+    return []Issue{issue}
+}
+
+// isTaskPage reports whether a document is a task or how-to page, by its
+// front matter type or by a title that names a task
+func isTaskPage(doc *Document) bool {
+    for _, key := range []string{"type", "content_type", "page_type", "doc_type"} {
+        if value, ok := doc.FrontMatter[key].(string); ok {
+            return taskTypes[strings.ToLower(strings.TrimSpace(value))]
+        }
+    }
+    var title string
+    if value, ok := doc.FrontMatter["title"].(string); ok {
+        title = value
+    }
+    for _, section := range doc.Sections {
+        if title == "" && section.Level == 1 {
+            title = section.Heading
+        }
+    }
+    title = plainText(title)
+    if taskTitleRegex.MatchString(title) {
+        return true
+    }
+    word := firstWord(title)
+    if trimmed, ok := strings.CutSuffix(word, "ing"); ok {
+        return imperativeVerb(trimmed) != "" || imperativeVerb(trimmed+"e") != "" || baseVerb(trimmed+"ed") != ""
+    }
+    return imperativeVerb(word) != ""
+}
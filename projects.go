@@ -0,0 +1,84 @@
+// Projects of a multi-project server
+
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+
+    "gopkg.in/yaml.v3"
+)
+
+// ServeProject is one of the projects a server hosts with -projects. Each
+// has its own configuration, token and results database, so one team's
+// token can't analyze with, or read the schedule of, another team's
+// project.
+type ServeProject struct {
+    Name     string `yaml:"Name"`               // names the project in its URLs, /projects/<name>/
+    Config   string `yaml:"Config,omitempty"`   // path or HTTPS/git URL of the configuration; the defaults if empty
+    DB       string `yaml:"DB,omitempty"`       // results database the project's scheduled runs record to
+    Token    string `yaml:"Token,omitempty"`    // bearer token requests must carry
+    TokenEnv string `yaml:"TokenEnv,omitempty"` // environment variable holding the token, used when Token is empty
+}
+
+// projectNameRegex matches the project names that are safe in URLs
+var projectNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// loadProjects reads a projects file: a YAML Projects list. Relative
+// Config and DB paths are taken relative to the file.
+func loadProjects(path string) ([]ServeProject, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var file struct {
+        Projects []ServeProject `yaml:"Projects"`
+    }
+    if err := yaml.Unmarshal(data, &file); err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    if len(file.Projects) == 0 {
+        return nil, fmt.Errorf("%s lists no Projects", path)
+    }
+
+    dir := filepath.Dir(path)
+    names, dbs := make(map[string]bool), make(map[string]bool)
+    for i := range file.Projects {
+        project := &file.Projects[i]
+        switch {
+        case !projectNameRegex.MatchString(project.Name):
+            return nil, fmt.Errorf("%s: invalid project name %q (want letters, digits, '.', '_' or '-')", path, project.Name)
+        case names[project.Name]:
+            return nil, fmt.Errorf("%s: project %s is listed twice", path, project.Name)
+        case project.token() == "":
+            return nil, fmt.Errorf("%s: project %s has no Token, or its TokenEnv is unset", path, project.Name)
+        }
+        names[project.Name] = true
+        if project.Config != "" && !isRemote(project.Config) && !filepath.IsAbs(project.Config) {
+            project.Config = filepath.Join(dir, project.Config)
+        }
+        if project.DB != "" {
+            if !filepath.IsAbs(project.DB) {
+                project.DB = filepath.Join(dir, project.DB)
+            }
+            if dbs[filepath.Clean(project.DB)] {
+                return nil, fmt.Errorf("%s: project %s shares its DB with another project", path, project.Name)
+            }
+            dbs[filepath.Clean(project.DB)] = true
+        }
+    }
+    return file.Projects, nil
+}
+
+// token returns the project's bearer token
+func (p ServeProject) token() string {
+    if p.Token != "" {
+        return p.Token
+    }
+    if p.TokenEnv != "" {
+        return os.Getenv(p.TokenEnv)
+    }
+    return ""
+}
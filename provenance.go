@@ -0,0 +1,110 @@
+// Provenance of exported chunks: the revision of the file each came from
+
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// provenance finds the commit and hash of exported files, caching the git
+// state of each checkout, and stamps one extraction time on every chunk
+type provenance struct {
+    extractedAt string
+    tops        map[string]string    // directory -> top of its git checkout, "" outside one
+    checkouts   map[string]*checkout // by top
+}
+
+// checkout is the git state of one working tree
+type checkout struct {
+    commit   string          // HEAD
+    modified map[string]bool // paths, relative to the top, with changes not in HEAD
+}
+
+// newProvenance starts provenance for an export extracted now
+func newProvenance(now time.Time) *provenance {
+    return &provenance{
+        extractedAt: now.UTC().Format(time.RFC3339),
+        tops:        make(map[string]string),
+        checkouts:   make(map[string]*checkout),
+    }
+}
+
+// stamp sets the provenance fields of chunks, all of them from the file at
+// path: the HEAD commit of its checkout, whether the file differs from it,
+// the SHA-256 of the file's bytes and the extraction time. A file in an
+// archive takes the commit of the archive; one outside git has no commit.
+func (p *provenance) stamp(path string, chunks []Chunk) {
+    fileHash := ""
+    if data, err := readFileData(path); err == nil {
+        sum := sha256.Sum256(data)
+        fileHash = hex.EncodeToString(sum[:])
+    }
+    tracked := path
+    if archive, _, ok := splitArchivePath(path); ok {
+        tracked = archive
+    }
+    commit, modified := p.revision(tracked)
+    for i := range chunks {
+        chunks[i].Commit = commit
+        chunks[i].Modified = modified
+        chunks[i].FileHash = fileHash
+        chunks[i].ExtractedAt = p.extractedAt
+    }
+}
+
+// revision returns the HEAD commit of the checkout path is in and whether
+// path has changes not in it, uncommitted or untracked
+func (p *provenance) revision(path string) (string, bool) {
+    abs, err := filepath.Abs(path)
+    if err != nil {
+        return "", false
+    }
+    dir := filepath.Dir(abs)
+    top, ok := p.tops[dir]
+    if !ok {
+        top, _ = git("-C", dir, "rev-parse", "--show-toplevel")
+        p.tops[dir] = top
+    }
+    if top == "" {
+        return "", false
+    }
+    state, ok := p.checkouts[top]
+    if !ok {
+        state = loadCheckout(top)
+        p.checkouts[top] = state
+    }
+    if state.commit == "" {
+        return "", false
+    }
+    if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+        abs = resolved
+    }
+    rel, err := filepath.Rel(top, abs)
+    if err != nil {
+        return state.commit, false
+    }
+    return state.commit, state.modified[filepath.ToSlash(rel)]
+}
+
+// loadCheckout reads the HEAD commit of the working tree at top and the
+// files that differ from it. A repository without commits has no HEAD.
+func loadCheckout(top string) *checkout {
+    state := &checkout{modified: make(map[string]bool)}
+    commit, err := git("-C", top, "rev-parse", "--verify", "--quiet", "HEAD")
+    if err != nil {
+        return state
+    }
+    state.commit = commit
+    changed, _ := git("-C", top, "diff", "--name-only", "-z", "HEAD")
+    untracked, _ := git("-C", top, "ls-files", "--others", "--exclude-standard", "-z")
+    for _, rel := range strings.Split(changed+"\x00"+untracked, "\x00") {
+        if rel != "" {
+            state.modified[rel] = true
+        }
+    }
+    return state
+}
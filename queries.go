@@ -0,0 +1,322 @@
+// Query alignment analysis
+
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "math"
+    "os"
+    "sort"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// BM25 parameters
+const (
+    bm25K1 = 1.2
+    bm25B  = 0.75
+)
+
+// TargetQuery is a search query or user question the docs should answer
+type TargetQuery struct {
+    Query    string `yaml:"Query" json:"query"`
+    Priority string `yaml:"Priority,omitempty" json:"priority"` // "high", "medium" (default), "low"
+}
+
+// UnmarshalYAML accepts a bare string as a medium-priority query
+func (q *TargetQuery) UnmarshalYAML(node *yaml.Node) error {
+    if node.Kind == yaml.ScalarNode {
+        q.Query = node.Value
+        return nil
+    }
+    type plain TargetQuery
+    return node.Decode((*plain)(q))
+}
+
+// QueryMatch is one section's match for a query
+type QueryMatch struct {
+    File       string  `json:"file"`
+    Line       int     `json:"line"`
+    Section    string  `json:"section"`
+    Score      float64 `json:"bm25"`
+    Coverage   float64 `json:"coverage"`             // fraction of the query's terms the section contains
+    Similarity float64 `json:"similarity,omitempty"` // embedding similarity, with -semantic
+}
+
+// QueryResult is the best-matching sections for one query
+type QueryResult struct {
+    TargetQuery
+    Matches  []QueryMatch `json:"matches"`
+    Answered bool         `json:"answered"`
+}
+
+// QueryReport is the result of the queries subcommand
+type QueryReport struct {
+    Queries    []QueryResult `json:"queries"`
+    Unanswered int           `json:"unanswered"`
+    HighUnmet  int           `json:"high_priority_unanswered"`
+}
+
+// querySection is a section as a retrieval unit
+type querySection struct {
+    file, heading string
+    line          int
+    text          string
+    terms         map[string]int // stem -> count
+    length        int
+}
+
+// runQueries implements the queries subcommand
+func runQueries(args []string) int {
+    flags := flag.NewFlagSet("queries", flag.ExitOnError)
+    configPath := flags.String("config", "", "Path or HTTPS/git URL of configuration file")
+    outputFormat := flags.String("output", "standard", "Output format (standard, json)")
+    recursive := flags.Bool("recursive", false, "Process directories recursively")
+    followSymlinks := flags.Bool("follow-symlinks", false, "Descend into symlinked directories during recursive walks")
+    semantic := flags.Bool("semantic", false, "Also rank sections by embedding similarity to each query")
+    top := flags.Int("top", 3, "Number of matching sections to report per query")
+    minCoverage := flags.Float64("min-coverage", 0.6, "Fraction of a query's terms a section must contain to answer it")
+    minSimilarity := flags.Float64("min-similarity", 0.5, "Embedding similarity at which a section answers a query, with -semantic")
+    flags.Parse(args)
+
+    if flags.NArg() < 2 || *top < 1 {
+        fmt.Fprintf(os.Stderr, "Usage: %s queries [options] <queries.yml> <file_or_directory>...\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+
+    queries, err := loadQueries(flags.Arg(0))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+    var embedder *Embedder
+    if *semantic {
+        analyzer, err := NewAnalyzer(*configPath)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error creating analyzer: %v\n", err)
+            return 1
+        }
+        embedder = newEmbedder(analyzer.config.Embeddings)
+    }
+
+    var sections []querySection
+    for _, path := range flags.Args()[1:] {
+        found, err := collectFiles(path, WalkOptions{Recursive: *recursive, FollowSymlinks: *followSymlinks})
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", path, err)
+            return 1
+        }
+        for _, file := range found {
+            content, err := readDocument(file)
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "Warning: failed to read %s: %v\n", file, err)
+                continue
+            }
+            sections = append(sections, querySections(ParseDocument(file, content))...)
+        }
+    }
+
+    report := alignQueries(queries, sections, embedder, *top, *minCoverage, *minSimilarity)
+
+    switch *outputFormat {
+    case "json":
+        encoder := json.NewEncoder(os.Stdout)
+        encoder.SetIndent("", "  ")
+        if err := encoder.Encode(report); err != nil {
+            fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+            return 1
+        }
+    default:
+        printQueryReport(report)
+    }
+    if report.HighUnmet > 0 {
+        return 1
+    }
+    return 0
+}
+
+// loadQueries reads a YAML file with a Queries list, or a bare list. Each
+// entry is a query string or a Query with a Priority.
+func loadQueries(path string) ([]TargetQuery, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var file struct {
+        Queries []TargetQuery `yaml:"Queries"`
+    }
+    if err := yaml.Unmarshal(data, &file); err != nil {
+        if err := yaml.Unmarshal(data, &file.Queries); err != nil {
+            return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+        }
+    }
+
+    for i := range file.Queries {
+        query := &file.Queries[i]
+        query.Query = strings.TrimSpace(query.Query)
+        if query.Query == "" {
+            return nil, fmt.Errorf("%s: query %d is empty", path, i+1)
+        }
+        switch query.Priority {
+        case "":
+            query.Priority = "medium"
+        case "high", "medium", "low":
+        default:
+            return nil, fmt.Errorf("%s: query %q has invalid priority %q (want high, medium or low)", path, query.Query, query.Priority)
+        }
+    }
+    if len(file.Queries) == 0 {
+        return nil, fmt.Errorf("%s lists no queries", path)
+    }
+    return file.Queries, nil
+}
+
+// querySections returns a document's sections as retrieval units, each
+// carrying its heading path the way chunkers embed it
+func querySections(doc *Document) []querySection {
+    var sections []querySection
+    for _, section := range doc.Sections {
+        text := strings.TrimSpace(section.Breadcrumb() + "\n" + doc.prose(section))
+        if text == "" {
+            continue
+        }
+        terms := make(map[string]int)
+        words := contentWords(text)
+        for _, word := range words {
+            terms[stem(word)]++
+        }
+        heading, line := section.Breadcrumb(), section.Line
+        if line == 0 {
+            heading, line = "(before first heading)", section.StartLine
+        }
+        sections = append(sections, querySection{file: doc.Path, heading: heading, line: line, text: text, terms: terms, length: len(words)})
+    }
+    return sections
+}
+
+// alignQueries ranks the sections for each query, by BM25 or, with an
+// embedder, by embedding similarity, and decides whether any section
+// answers it. If the embeddings API fails, ranking falls back to BM25.
+func alignQueries(queries []TargetQuery, sections []querySection, embedder *Embedder, top int, minCoverage, minSimilarity float64) QueryReport {
+    var similarities [][]float64
+    if embedder != nil && len(sections) > 0 {
+        var texts []string
+        for _, query := range queries {
+            texts = append(texts, query.Query)
+        }
+        for _, section := range sections {
+            texts = append(texts, section.text)
+        }
+        vectors, err := embedder.Embed(texts)
+        for i := range queries {
+            if err != nil {
+                embedder.warnFallback(err)
+                break
+            }
+            row := make([]float64, len(sections))
+            for j := range sections {
+                row[j] = cosineSimilarity(vectors[i], vectors[len(queries)+j])
+            }
+            similarities = append(similarities, row)
+        }
+    }
+
+    documentFrequency := make(map[string]int)
+    totalLength := 0
+    for _, section := range sections {
+        for term := range section.terms {
+            documentFrequency[term]++
+        }
+        totalLength += section.length
+    }
+    averageLength := 1.0
+    if len(sections) > 0 && totalLength > 0 {
+        averageLength = float64(totalLength) / float64(len(sections))
+    }
+
+    var report QueryReport
+    for i, query := range queries {
+        var terms []string
+        seen := make(map[string]bool)
+        for _, word := range contentWords(query.Query) {
+            if term := stem(word); !seen[term] {
+                seen[term] = true
+                terms = append(terms, term)
+            }
+        }
+
+        var matches []QueryMatch
+        for j, section := range sections {
+            match := QueryMatch{File: section.file, Line: section.line, Section: section.heading}
+            found := 0
+            for _, term := range terms {
+                count := float64(section.terms[term])
+                if count == 0 {
+                    continue
+                }
+                found++
+                df := float64(documentFrequency[term])
+                idf := math.Log(1 + (float64(len(sections))-df+0.5)/(df+0.5))
+                match.Score += idf * count * (bm25K1 + 1) / (count + bm25K1*(1-bm25B+bm25B*float64(section.length)/averageLength))
+            }
+            if len(terms) > 0 {
+                match.Coverage = float64(found) / float64(len(terms))
+            }
+            if similarities != nil {
+                match.Similarity = similarities[i][j]
+            }
+            if match.Score > 0 || match.Similarity > 0 {
+                matches = append(matches, match)
+            }
+        }
+        sort.SliceStable(matches, func(a, b int) bool {
+            if similarities != nil {
+                return matches[a].Similarity > matches[b].Similarity
+            }
+            return matches[a].Score > matches[b].Score
+        })
+
+        result := QueryResult{TargetQuery: query}
+        for _, match := range matches {
+            if match.Coverage >= minCoverage || (similarities != nil && match.Similarity >= minSimilarity) {
+                result.Answered = true
+                break
+            }
+        }
+        result.Matches = matches[:min(top, len(matches))]
+        if !result.Answered {
+            report.Unanswered++
+            if query.Priority == "high" {
+                report.HighUnmet++
+            }
+        }
+        report.Queries = append(report.Queries, result)
+    }
+    return report
+}
+
+func printQueryReport(report QueryReport) {
+    for _, result := range report.Queries {
+        status := "answered"
+        if !result.Answered {
+            status = "NO GOOD MATCH"
+        }
+        fmt.Printf("%s [%s] %s\n", result.Query, result.Priority, status)
+        for _, match := range result.Matches {
+            semantic := ""
+            if match.Similarity != 0 {
+                semantic = fmt.Sprintf(", similarity %.2f", match.Similarity)
+            }
+            fmt.Printf("  %s:%d %s (bm25 %.2f, coverage %.0f%%%s)\n", match.File, match.Line, match.Section, match.Score, match.Coverage*100, semantic)
+        }
+        if len(result.Matches) == 0 {
+            fmt.Println("  no section mentions this query's terms")
+        }
+        fmt.Println()
+    }
+    fmt.Printf("%d of %d queries have no good matching section (%d high priority)\n", report.Unanswered, len(report.Queries), report.HighUnmet)
+}
@@ -0,0 +1,121 @@
+// Raw HTML detection in Markdown sources
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var (
+    rawHTMLTagRegex  = regexp.MustCompile(`<([a-z][a-z0-9]*)((?:\s[^>]*)?)/?>`)
+    inlineStyleRegex = regexp.MustCompile(`(?i)\bstyle\s*=`)
+)
+
+// htmlEquivalents maps HTML tags to the Markdown construct that replaces
+// them. Tags without an entry have no Markdown equivalent.
+var htmlEquivalents = map[string]string{
+    "table":      "a pipe table (| a | b |)",
+    "b":          "**bold**",
+    "strong":     "**bold**",
+    "i":          "*emphasis*",
+    "em":         "*emphasis*",
+    "a":          "[text](url)",
+    "img":        "![alt text](src)",
+    "h1":         "a '#' heading",
+    "h2":         "a '##' heading",
+    "h3":         "a '###' heading",
+    "h4":         "a '####' heading",
+    "h5":         "a '#####' heading",
+    "h6":         "a '######' heading",
+    "ul":         "a '-' list",
+    "ol":         "a '1.' list",
+    "li":         "a list item",
+    "pre":        "a fenced code block",
+    "code":       "`inline code`",
+    "p":          "a blank line between paragraphs",
+    "br":         "a blank line or a trailing backslash",
+    "hr":         "'---'",
+    "blockquote": "a '>' blockquote",
+}
+
+// htmlBlockTags open blocks whose nested tags aren't reported separately
+var htmlBlockTags = map[string]bool{
+    "table": true, "div": true, "pre": true, "ul": true, "ol": true,
+    "details": true, "center": true, "blockquote": true, "figure": true,
+}
+
+// analyzeRawHTML flags raw HTML embedded in Markdown, which many ingestion
+// pipelines strip or mis-convert, suggesting the Markdown equivalent where
+// one exists
+func (a *Analyzer) analyzeRawHTML(doc *Document) []Issue {
+    if doc.Format != "markdown" {
+        return nil
+    }
+
+    var issues []Issue
+    for i := doc.BodyStart - 1; i < len(doc.Masked); i++ {
+        if doc.Fenced[i] {
+            continue
+        }
+        line := blankMatches(inlineCodeRegex, doc.Masked[i])
+
+        // The line's first tag, passing over placeholders such as <product>,
+        // which analyzeUnresolvedPlaceholders reports
+        var match []int
+        for _, found := range rawHTMLTagRegex.FindAllStringSubmatchIndex(line, -1) {
+            if found[4] != found[5] || !placeholderName(line[found[2]:found[3]]) {
+                match = found
+                break
+            }
+        }
+        if match == nil {
+            continue
+        }
+        lineNum := i + 1
+        tag := line[match[2]:match[3]]
+        attributes := line[match[4]:match[5]]
+
+        severity := "suggestion"
+        message := fmt.Sprintf("Raw HTML <%s> may be stripped or mis-converted during ingestion", tag)
+        suggestion := fmt.Sprintf("Use %s instead", htmlEquivalents[tag])
+
+        if htmlBlockTags[tag] {
+            severity = "warning"
+            message = fmt.Sprintf("Raw HTML <%s> block may be stripped or mis-converted during ingestion", tag)
+            i = htmlBlockEnd(doc, i, tag)
+        }
+        if _, ok := htmlEquivalents[tag]; !ok {
+            suggestion = "Express the content in plain Markdown; there is no Markdown equivalent for this element"
+        }
+        if inlineStyleRegex.MatchString(attributes) {
+            suggestion += "; inline styling is lost in text-only output"
+        }
+
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         lineNum,
+            Column:       match[0] + 1,
+            Rule:         "raw-html",
+            Message:      message,
+            Severity:     severity,
+            Suggestion:   suggestion,
+            OriginalText: strings.TrimSpace(line[match[0]:match[1]]),
+        })
+    }
+
+    return issues
+}
+
+// htmlBlockEnd returns the index of the line closing the tag block opened on
+// line start, or start itself when the block closes on the same line
+func htmlBlockEnd(doc *Document, start int, tag string) int {
+    closing := regexp.MustCompile(`(?i)</` + tag + `\s*>`)
+    for i := start; i < len(doc.Lines); i++ {
+        if closing.MatchString(doc.Lines[i]) {
+            return i
+        }
+    }
+    return start
+}
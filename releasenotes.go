@@ -0,0 +1,279 @@
+// Release notes and changelogs, checked and exported entry by entry
+
+package main
+
+import (
+    "fmt"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// ReleaseNotesConfig tunes the release notes checks
+type ReleaseNotesConfig struct {
+    Components []string `yaml:"Components,omitempty"` // names every entry must mention one of; unset, any label, code span or name will do
+}
+
+var (
+    // releaseNotesFileRegex matches the file name of a changelog
+    releaseNotesFileRegex = regexp.MustCompile(`(?i)^(?:changelog|changes|history|news|releases|release[-_ ]?notes?)$`)
+    // releaseNotesTitleRegex matches the title of a release notes page
+    releaseNotesTitleRegex = regexp.MustCompile(`(?i)\b(?:release notes|changelog|change log|what's new)\b`)
+    // releaseVersionRegex matches the version or date a release heading
+    // names: "4.2.0", "v3.1", "[1.0.0-rc.1]", "2025-06-30"
+    releaseVersionRegex = regexp.MustCompile(`\bv?\d+\.\d+(?:\.\d+)?(?:-[\w.]+)?\b|\b\d{4}-\d{2}-\d{2}\b`)
+    // unreleasedRegex matches the heading of changes not yet released
+    unreleasedRegex = regexp.MustCompile(`(?i)\b(?:unreleased|upcoming|next release)\b`)
+    // releaseComponentRegex matches an entry that labels its component, as
+    // in "**Sync:** ...", "feat(sync): ..." or "[CLI] ..."
+    releaseComponentRegex = regexp.MustCompile("^(?:\\*\\*[^*]+\\*\\*|__[^_]+__|\\[[^\\]]+\\]|\\w+\\([\\w/.-]+\\)!?:|[A-Z][\\w /.-]{0,30}:)|`[^`]+`")
+    // releaseDependentRegex matches an entry that leans on the entries
+    // around it, or says nothing on its own
+    releaseDependentRegex = regexp.MustCompile(`(?i)^(?:(?:it|this|these|they|that|also|same|now)\b|(?:see|as) above\b|(?:(?:minor|various|general|performance|small)\s+)?(?:bug\s*fixes|fixes|improvements|changes|updates|tweaks)\.?$|misc(?:ellaneous)?\b)`)
+)
+
+// releaseTypes are the front matter types of release notes pages
+var releaseTypes = map[string]bool{"release-notes": true, "release_notes": true, "changelog": true, "releases": true, "whats-new": true}
+
+// releaseCategories are headings that group a release's entries by kind
+// rather than by the component they affect
+var releaseCategories = map[string]bool{
+    "added": true, "changed": true, "deprecated": true, "removed": true, "fixed": true, "security": true,
+    "features": true, "new features": true, "bug fixes": true, "fixes": true, "improvements": true,
+    "enhancements": true, "breaking changes": true, "known issues": true, "other": true, "misc": true,
+    "miscellaneous": true, "highlights": true, "performance": true, "documentation": true,
+}
+
+// minReleaseEntryWords is the fewest words an entry can say what changed in
+const minReleaseEntryWords = 4
+
+// releaseEntry is one entry of a release notes page: a top-level list item
+// under a release heading
+type releaseEntry struct {
+    section Section
+    item    ListItem
+    release string // the heading naming the release's version, "" if none does
+}
+
+// isReleaseNotes reports whether doc is a changelog or release notes page,
+// by its front matter type, its file name or its title
+func isReleaseNotes(doc *Document) bool {
+    if value, ok := doc.FrontMatter["type"].(string); ok && releaseTypes[strings.ToLower(strings.TrimSpace(value))] {
+        return true
+    }
+    if releaseNotesFileRegex.MatchString(strings.TrimSuffix(filepath.Base(doc.Path), filepath.Ext(doc.Path))) {
+        return true
+    }
+    title, _ := doc.Title()
+    if title == "" {
+        title, _ = doc.H1()
+    }
+    return releaseNotesTitleRegex.MatchString(plainText(title))
+}
+
+// releaseEntries returns the entries of a release notes page in order,
+// each with the release it belongs to
+func releaseEntries(doc *Document) []releaseEntry {
+    var entries []releaseEntry
+    for _, list := range doc.Lists() {
+        section, ok := doc.SectionAt(list.Items[0].Line)
+        if !ok {
+            continue
+        }
+        release := ""
+        for _, heading := range section.Path {
+            if releaseVersionRegex.MatchString(plainText(heading)) || unreleasedRegex.MatchString(heading) {
+                release = heading
+            }
+        }
+        for _, item := range list.Items {
+            entries = append(entries, releaseEntry{section: section, item: item, release: release})
+        }
+    }
+    return entries
+}
+
+// analyzeReleaseNotes checks each entry of a changelog or release notes
+// page: that it falls under a heading naming its version, names the
+// component it affects, and says what changed without leaning on the
+// entries around it. Release notes are retrieved an entry at a time, to
+// answer "when did X change?", so each entry has to carry all of that.
+func (a *Analyzer) analyzeReleaseNotes(doc *Document) []Issue {
+    if !isReleaseNotes(doc) {
+        return nil
+    }
+    var issues []Issue
+    unversioned := make(map[int]bool) // sections already reported
+    for _, entry := range releaseEntries(doc) {
+        item := entry.item
+        original := strings.TrimSpace(doc.Lines[item.Line-1])
+        text := strings.Join(strings.Fields(item.Text), " ")
+        if entry.release == "" && !unversioned[entry.section.StartLine] {
+            unversioned[entry.section.StartLine] = true
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         item.Line,
+                Column:       1,
+                Rule:         "release-entry-missing-version",
+                Message:      "Release notes entries aren't under a heading naming their version",
+                Severity:     "warning",
+                Suggestion:   "Put the entries under a heading with the release's version and date, such as '## 4.2.0 (2025-06-30)'",
+                OriginalText: original,
+            })
+        }
+
+        plain := plainText(text)
+        if releaseDependentRegex.MatchString(plain) || len(strings.Fields(plain)) < minReleaseEntryWords {
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         item.Line,
+                Column:       1,
+                Rule:         "release-entry-not-self-contained",
+                Message:      "Release notes entry doesn't say what changed on its own",
+                Severity:     "warning",
+                Suggestion:   "Say what changed and where, such as 'Fixed a crash in the sync agent when uploading files over 2 GB'",
+                OriginalText: original,
+            })
+            continue
+        }
+        if !a.namesComponent(entry, text) {
+            message := "Release notes entry doesn't name the component it affects"
+            suggestion := "Start the entry with the component, such as '**Sync agent:** Fixed ...', or name it in the text"
+            if len(a.config.ReleaseNotes.Components) > 0 {
+                suggestion = fmt.Sprintf("Name the component it affects: %s", strings.Join(a.config.ReleaseNotes.Components, ", "))
+            }
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         item.Line,
+                Column:       1,
+                Rule:         "release-entry-missing-component",
+                Message:      message,
+                Severity:     "suggestion",
+                Suggestion:   suggestion,
+                OriginalText: original,
+            })
+        }
+    }
+    return issues
+}
+
+// componentRegexes returns a pattern for each configured component, which
+// matches its name as a whole word in lowercased text
+func componentRegexes(components []string) []*regexp.Regexp {
+    var patterns []*regexp.Regexp
+    for _, component := range components {
+        patterns = append(patterns, regexp.MustCompile(`\b`+regexp.QuoteMeta(strings.ToLower(component))+`\b`))
+    }
+    return patterns
+}
+
+// namesComponent reports whether an entry names what it affects: one of
+// the configured components, or without any configured, a label or code
+// span, a capitalized name after its first word, or a heading above it
+// that names a component rather than a kind of change
+func (a *Analyzer) namesComponent(entry releaseEntry, text string) bool {
+    if len(a.components) > 0 {
+        lower := strings.ToLower(plainText(text))
+        for _, component := range a.components {
+            if component.MatchString(lower) {
+                return true
+            }
+        }
+        return false
+    }
+    if releaseComponentRegex.MatchString(text) {
+        return true
+    }
+    words := strings.Fields(plainText(text))
+    for i := 1; i < len(words); i++ {
+        if first := []rune(words[i])[0]; first >= 'A' && first <= 'Z' && !strings.HasSuffix(words[i-1], ".") {
+            return true
+        }
+    }
+    heading := strings.ToLower(strings.TrimSpace(plainText(entry.section.Heading)))
+    return entry.section.Heading != entry.release && heading != "" && !releaseCategories[heading]
+}
+
+// releaseChunks splits a release notes page into one chunk per entry, led
+// by its heading path so the version travels with it. The rest of each
+// section's text becomes a chunk of its own, as buildChunks would write it.
+func releaseChunks(doc *Document, options ExportOptions, navPath []string) []Chunk {
+    anchors, ids := sectionIdentities(doc)
+    entries := releaseEntries(doc)
+    var products map[string]bool
+    if options.Score {
+        products = productTerms(doc)
+    }
+
+    var chunks []Chunk
+    for _, section := range doc.Sections {
+        id := doc.Path
+        if section.Line > 0 {
+            id = ids[section.Line]
+        }
+        path := strings.Join(append(append([]string(nil), navPath...), section.Path...), " > ")
+        chunk := func(line int, id, body, text string) Chunk {
+            var quality *ChunkQuality
+            if options.Score {
+                quality = scoreChunk(section, text, products, options)
+            }
+            return Chunk{
+                ID:          id,
+                File:        doc.Path,
+                HeadingPath: section.Path,
+                NavPath:     navPath,
+                Line:        line,
+                Anchor:      anchors[section.Line],
+                ContentHash: contentHash(body),
+                Text:        text,
+                Quality:     quality,
+            }
+        }
+
+        var rest []string
+        var sectionEntries []releaseEntry
+        for lineNum := section.StartLine; lineNum <= section.EndLine; lineNum++ {
+            inEntry := false
+            for _, entry := range entries {
+                inEntry = inEntry || (lineNum >= entry.item.Line && lineNum <= entry.item.EndLine)
+                if lineNum == entry.item.Line {
+                    sectionEntries = append(sectionEntries, entry)
+                }
+            }
+            if !inEntry {
+                rest = append(rest, doc.Lines[lineNum-1])
+            }
+        }
+        if body := strings.TrimSpace(blankRunRegex.ReplaceAllString(strings.Join(rest, "\n"), "\n\n")); body != "" {
+            text := body
+            if section.Line > 0 {
+                text = doc.Lines[section.Line-1] + "\n\n" + body
+            }
+            if options.Breadcrumbs && len(section.Path) > 0 {
+                text = path + "\n\n" + text
+            }
+            chunks = append(chunks, chunk(section.Line, id, body, text))
+        }
+
+        // An entry's ID comes from its opening words, so it survives
+        // entries added before it
+        seen := make(map[string]int)
+        for _, entry := range sectionEntries {
+            body := strings.TrimSpace(strings.Join(doc.Lines[entry.item.Line-1:entry.item.EndLine], "\n"))
+            words := strings.Fields(plainText(entry.item.Text))
+            key := headingAnchor(strings.Join(words[:min(len(words), 8)], " "))
+            if key == "" {
+                key = "entry"
+            }
+            if seen[key]++; seen[key] > 1 {
+                key = fmt.Sprintf("%s-%d", key, seen[key]-1)
+            }
+            text := body
+            if path != "" {
+                text = path + "\n\n" + body
+            }
+            chunks = append(chunks, chunk(entry.item.Line, id+"/"+key, body, text))
+        }
+    }
+    return chunks
+}
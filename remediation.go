@@ -0,0 +1,114 @@
+// Remediation effort estimate printed at the end of a run
+
+package main
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "sort"
+    "strings"
+)
+
+// RemediationConfig sets what the end-of-run estimate charges for each
+// issue, for planning a cleanup across sprints
+type RemediationConfig struct {
+    Minutes       map[string]float64 `yaml:"Minutes,omitempty"`       // rule name -> minutes to resolve one of its issues
+    FixMinutes    *float64           `yaml:"FixMinutes,omitempty"`    // minutes to review an issue with a fix, for rules not in Minutes; 1 if unset
+    ManualMinutes *float64           `yaml:"ManualMinutes,omitempty"` // minutes to resolve an issue by hand, for rules not in Minutes; 5 if unset
+}
+
+// Default minutes for the rules without a Minutes entry
+const (
+    defaultFixMinutes    = 1
+    defaultManualMinutes = 5
+)
+
+// remediationTopRules is how many of the costliest rules the summary names
+const remediationTopRules = 3
+
+// remediationEstimate is the effort the issues of a run need
+type remediationEstimate struct {
+    issues, fixable, safe int
+    fixMinutes, manual    float64
+    byRule                map[string]float64 // minutes by rule
+}
+
+// validate rejects negative costs
+func (c RemediationConfig) validate() error {
+    if (c.FixMinutes != nil && *c.FixMinutes < 0) || (c.ManualMinutes != nil && *c.ManualMinutes < 0) {
+        return fmt.Errorf("invalid Remediation minutes (want 0 or more)")
+    }
+    for _, rule := range sortedKeys(c.Minutes) {
+        if c.Minutes[rule] < 0 {
+            return fmt.Errorf("invalid Remediation.Minutes %v for %s (want 0 or more)", c.Minutes[rule], rule)
+        }
+    }
+    return nil
+}
+
+// minutes returns what resolving one issue costs: its rule's Minutes
+// entry, or the fix or manual default
+func (c RemediationConfig) minutes(issue Issue) float64 {
+    if minutes, ok := c.Minutes[issue.Rule]; ok {
+        return minutes
+    }
+    if issue.Fix != nil {
+        if c.FixMinutes != nil {
+            return *c.FixMinutes
+        }
+        return defaultFixMinutes
+    }
+    if c.ManualMinutes != nil {
+        return *c.ManualMinutes
+    }
+    return defaultManualMinutes
+}
+
+// estimate totals the effort of issues, which are those left after -fix
+// and before the issue caps, so capping a report doesn't shrink the work
+func (c RemediationConfig) estimate(issues []Issue) remediationEstimate {
+    estimate := remediationEstimate{issues: len(issues), byRule: make(map[string]float64)}
+    for _, issue := range issues {
+        minutes := c.minutes(issue)
+        estimate.byRule[issue.Rule] += minutes
+        if issue.Fix == nil {
+            estimate.manual += minutes
+            continue
+        }
+        estimate.fixable++
+        estimate.fixMinutes += minutes
+        if issue.Fix.Safe {
+            estimate.safe++
+        }
+    }
+    return estimate
+}
+
+// print writes the estimate as a summary line, followed by the rules that
+// take the most time
+func (e remediationEstimate) print(w io.Writer) {
+    if e.issues == 0 {
+        return
+    }
+    fmt.Fprintf(w, "Remediation: %d issue(s), %d with a fix (%d safe) and %d manual; about %s (%s reviewing fixes, %s by hand)\n",
+        e.issues, e.fixable, e.safe, e.issues-e.fixable, formatMinutes(e.fixMinutes+e.manual), formatMinutes(e.fixMinutes), formatMinutes(e.manual))
+
+    rules := sortedKeys(e.byRule)
+    sort.SliceStable(rules, func(i, j int) bool { return e.byRule[rules[i]] > e.byRule[rules[j]] })
+    var top []string
+    for _, rule := range rules[:min(len(rules), remediationTopRules)] {
+        top = append(top, fmt.Sprintf("%s %s", rule, formatMinutes(e.byRule[rule])))
+    }
+    fmt.Fprintf(w, "  Most effort: %s\n", strings.Join(top, ", "))
+}
+
+// formatMinutes renders a duration in minutes as hours and minutes,
+// rounded to the minute: "2h 05m", "40m"
+func formatMinutes(minutes float64) string {
+    total := int(math.Round(minutes))
+    if total < 60 {
+        return fmt.Sprintf("%dm", total)
+    }
+    return fmt.Sprintf("%dh %02dm", total/60, total%60)
+}
@@ -0,0 +1,396 @@
+// Remote configuration and rule packs
+
+package main
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "compress/gzip"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "path"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "time"
+)
+
+var commitRegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// isRemote reports whether a -config or StylesPath value names a remote
+// location rather than a local path
+func isRemote(location string) bool {
+    return strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "git+")
+}
+
+// cacheDir returns the directory remote files are cached in:
+// AI_DOC_OPTIMIZER_CACHE, or ai-doc-optimizer in the user cache directory
+func cacheDir() (string, error) {
+    if dir := os.Getenv("AI_DOC_OPTIMIZER_CACHE"); dir != "" {
+        return dir, nil
+    }
+    dir, err := os.UserCacheDir()
+    if err != nil {
+        return "", fmt.Errorf("no cache directory: %w; set AI_DOC_OPTIMIZER_CACHE", err)
+    }
+    return filepath.Join(dir, "ai-doc-optimizer"), nil
+}
+
+// remoteCacheEntry returns the cache directory for one remote location
+func remoteCacheEntry(location string) (string, error) {
+    root, err := cacheDir()
+    if err != nil {
+        return "", err
+    }
+    sum := sha256.Sum256([]byte(location))
+    dir := filepath.Join(root, "remote", hex.EncodeToString(sum[:8]))
+    return dir, os.MkdirAll(dir, 0o755)
+}
+
+// fetchRemoteFile resolves a remote -config value to a cached local file.
+// An HTTPS URL may pin its content with a #sha256=<hex> fragment; a git
+// URL names a file in a repository, as in
+// git+https://host/org/repo.git//path/config.yml#<ref>.
+func fetchRemoteFile(location string) (string, error) {
+    if strings.HasPrefix(location, "git+") {
+        repo, subpath, ref := parseGitLocation(location)
+        if subpath == "" {
+            return "", fmt.Errorf("%s names a repository, not a file; add //path/to/config.yml", location)
+        }
+        dir, err := fetchGit(repo, ref)
+        if err != nil {
+            return "", err
+        }
+        return filepath.Join(dir, filepath.FromSlash(subpath)), nil
+    }
+
+    address, checksum, err := parseHTTPLocation(location)
+    if err != nil {
+        return "", err
+    }
+    dir, err := remoteCacheEntry(address)
+    if err != nil {
+        return "", err
+    }
+    name := path.Base(address)
+    if parsed, err := url.Parse(address); err == nil {
+        name = path.Base(parsed.Path)
+    }
+    file := filepath.Join(dir, name)
+    if _, err := fetchHTTP(address, file, checksum); err != nil {
+        return "", err
+    }
+    return file, nil
+}
+
+// fetchRemoteStyles resolves a remote StylesPath to a cached local
+// directory: a .zip or .tar.gz archive of the styles directory over HTTPS,
+// optionally pinned with #sha256=<hex>, or a directory of a git
+// repository, as in git+https://host/org/styles.git//styles#v1.2.0
+func fetchRemoteStyles(location string) (string, error) {
+    if strings.HasPrefix(location, "git+") {
+        repo, subpath, ref := parseGitLocation(location)
+        dir, err := fetchGit(repo, ref)
+        if err != nil {
+            return "", err
+        }
+        return filepath.Join(dir, filepath.FromSlash(subpath)), nil
+    }
+
+    address, checksum, err := parseHTTPLocation(location)
+    if err != nil {
+        return "", err
+    }
+    parsed, err := url.Parse(address)
+    if err != nil || !isArchive(parsed.Path) {
+        return "", fmt.Errorf("StylesPath %s must name a .zip or .tar.gz archive, or a git+ repository", location)
+    }
+    dir, err := remoteCacheEntry(address)
+    if err != nil {
+        return "", err
+    }
+    archive := filepath.Join(dir, path.Base(parsed.Path))
+    styles := filepath.Join(dir, "styles")
+    downloaded, err := fetchHTTP(address, archive, checksum)
+    if err != nil {
+        return "", err
+    }
+    if _, err := os.Stat(styles); downloaded || err != nil {
+        os.RemoveAll(styles)
+        if err := extractArchive(archive, styles); err != nil {
+            os.RemoveAll(styles)
+            return "", fmt.Errorf("failed to extract %s: %w", location, err)
+        }
+    }
+    return singleDirectory(styles), nil
+}
+
+// parseHTTPLocation splits a #sha256=<hex> pin from a URL
+func parseHTTPLocation(location string) (string, string, error) {
+    address, fragment, _ := strings.Cut(location, "#")
+    if fragment == "" {
+        return address, "", nil
+    }
+    checksum, ok := strings.CutPrefix(fragment, "sha256=")
+    if !ok || len(checksum) != 64 {
+        return "", "", fmt.Errorf("invalid pin #%s in %s (want #sha256=<64 hex digits>)", fragment, location)
+    }
+    return address, strings.ToLower(checksum), nil
+}
+
+// parseGitLocation splits git+<repo>[//<path>][#<ref>]
+func parseGitLocation(location string) (string, string, string) {
+    location, ref, _ := strings.Cut(strings.TrimPrefix(location, "git+"), "#")
+    start := 0
+    if i := strings.Index(location, "://"); i >= 0 {
+        start = i + len("://")
+    }
+    if i := strings.Index(location[start:], "//"); i >= 0 {
+        return location[:start+i], strings.Trim(location[start+i+2:], "/"), ref
+    }
+    return location, "", ref
+}
+
+// fetchHTTP downloads address to file and reports whether it did. With a
+// checksum, a cached file that matches is used without a request, and a
+// download that doesn't match is an error. Without one, the file is
+// downloaded every run, and the cached copy is used when that fails.
+func fetchHTTP(address, file, checksum string) (bool, error) {
+    if checksum != "" {
+        if sum, err := fileChecksum(file); err == nil && sum == checksum {
+            return false, nil
+        }
+    }
+
+    client := &http.Client{Timeout: 60 * time.Second}
+    err := func() error {
+        response, err := client.Get(address)
+        if err != nil {
+            return err
+        }
+        defer response.Body.Close()
+        if response.StatusCode != http.StatusOK {
+            return fmt.Errorf("%s returned %s", address, response.Status)
+        }
+
+        temp, err := os.CreateTemp(filepath.Dir(file), ".download-")
+        if err != nil {
+            return err
+        }
+        defer os.Remove(temp.Name())
+        hash := sha256.New()
+        _, err = io.Copy(io.MultiWriter(temp, hash), response.Body)
+        temp.Close()
+        if err != nil {
+            return err
+        }
+        if sum := hex.EncodeToString(hash.Sum(nil)); checksum != "" && sum != checksum {
+            return fmt.Errorf("checksum mismatch for %s: pinned sha256 %s, got %s", address, checksum, sum)
+        }
+        return os.Rename(temp.Name(), file)
+    }()
+    if err == nil {
+        return true, nil
+    }
+
+    if _, statErr := os.Stat(file); statErr == nil && checksum == "" && !strings.Contains(err.Error(), "checksum") {
+        fmt.Fprintf(os.Stderr, "Warning: failed to fetch %s, using cached copy: %v\n", address, err)
+        return false, nil
+    }
+    return false, err
+}
+
+// fileChecksum returns the hex SHA-256 of a file
+func fileChecksum(file string) (string, error) {
+    f, err := os.Open(file)
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+    hash := sha256.New()
+    if _, err := io.Copy(hash, f); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fetchGit checks out ref (the default branch if empty) of a repository
+// into the cache and returns the checkout. A ref that is a full commit SHA
+// is pinned: once checked out it is reused without fetching. Other refs
+// are fetched every run, falling back to the cached checkout.
+func fetchGit(repo, ref string) (string, error) {
+    entry, err := remoteCacheEntry("git+" + repo + "#" + ref)
+    if err != nil {
+        return "", err
+    }
+    dir := filepath.Join(entry, "checkout")
+
+    pinned := commitRegex.MatchString(ref)
+    cached := false
+    if head, err := git("-C", dir, "rev-parse", "HEAD"); err == nil {
+        cached = true
+        if pinned && head == ref {
+            return dir, nil
+        }
+    }
+
+    if !cached {
+        os.RemoveAll(dir)
+        if _, err := git("init", "--quiet", dir); err != nil {
+            return "", err
+        }
+        if _, err := git("-C", dir, "remote", "add", "origin", repo); err != nil {
+            return "", err
+        }
+    }
+    target := ref
+    if target == "" {
+        target = "HEAD"
+    }
+    _, err = git("-C", dir, "fetch", "--quiet", "--depth", "1", "origin", target)
+    if err == nil {
+        _, err = git("-C", dir, "checkout", "--quiet", "--force", "FETCH_HEAD")
+    }
+    if err != nil {
+        if cached && !pinned {
+            fmt.Fprintf(os.Stderr, "Warning: failed to fetch %s, using cached checkout: %v\n", repo, err)
+            return dir, nil
+        }
+        return "", fmt.Errorf("failed to fetch %s: %w", repo, err)
+    }
+    if pinned {
+        if head, _ := git("-C", dir, "rev-parse", "HEAD"); head != ref {
+            return "", fmt.Errorf("%s: fetched %s, pinned %s", repo, head, ref)
+        }
+    }
+    return dir, nil
+}
+
+// extractArchive writes the regular files of a zip or tar archive under
+// dir, rejecting members that would land outside it
+func extractArchive(archive, dir string) error {
+    write := func(name string, r io.Reader) error {
+        target := filepath.Join(dir, filepath.FromSlash(name))
+        if rel, err := filepath.Rel(dir, target); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+            return fmt.Errorf("member %s is outside the archive root", name)
+        }
+        if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+            return err
+        }
+        out, err := os.Create(target)
+        if err != nil {
+            return err
+        }
+        defer out.Close()
+        _, err = io.Copy(out, r)
+        return err
+    }
+
+    if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+        reader, err := zip.OpenReader(archive)
+        if err != nil {
+            return err
+        }
+        defer reader.Close()
+        for _, file := range reader.File {
+            if file.FileInfo().IsDir() {
+                continue
+            }
+            contents, err := file.Open()
+            if err != nil {
+                return err
+            }
+            err = write(file.Name, contents)
+            contents.Close()
+            if err != nil {
+                return err
+            }
+        }
+        return nil
+    }
+
+    file, err := os.Open(archive)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    var stream io.Reader = file
+    if !strings.HasSuffix(strings.ToLower(archive), ".tar") {
+        gz, err := gzip.NewReader(file)
+        if err != nil {
+            return err
+        }
+        defer gz.Close()
+        stream = gz
+    }
+    reader := tar.NewReader(stream)
+    for {
+        header, err := reader.Next()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        if header.Typeflag == tar.TypeReg {
+            if err := write(header.Name, reader); err != nil {
+                return err
+            }
+        }
+    }
+}
+
+// singleDirectory descends into dir while it holds nothing but one
+// directory, as archives of a repository usually do
+func singleDirectory(dir string) string {
+    for {
+        entries, err := os.ReadDir(dir)
+        if err != nil || len(entries) != 1 || !entries[0].IsDir() || languageCodeRegex.MatchString(entries[0].Name()) {
+            return dir
+        }
+        dir = filepath.Join(dir, entries[0].Name())
+    }
+}
+
+// checkRemoteCommands rejects the settings of a remote config that run
+// commands on this machine, so fetching a config never runs what it says
+func (c *Config) checkRemoteCommands(location string) error {
+    if len(c.Formatters) > 0 {
+        return fmt.Errorf("%s: Formatters run commands, so a remote config can't set them; install the formatter as an %s<name> executable on PATH instead", location, externalFormatterPrefix)
+    }
+    if c.Embeddings.Command != "" {
+        return fmt.Errorf("%s: an Embeddings Command runs on this machine, so a remote config can't set it; use it from a local config", location)
+    }
+    return nil
+}
+
+// relativeToRemote resolves a relative StylesPath in a remote config
+// against the config's location, so a config and the rule packs beside it
+// can be published together: against the checkout for a git config, and
+// against the URL for an HTTPS config when StylesPath names an archive
+func relativeToRemote(location, localConfig, stylesPath string) string {
+    if stylesPath == "" || isRemote(stylesPath) || filepath.IsAbs(stylesPath) {
+        return stylesPath
+    }
+    if strings.HasPrefix(location, "git+") {
+        return filepath.Join(filepath.Dir(localConfig), filepath.FromSlash(stylesPath))
+    }
+    if name, _, _ := strings.Cut(stylesPath, "#"); !isArchive(name) {
+        return stylesPath
+    }
+    address, _, _ := strings.Cut(location, "#")
+    base, err := url.Parse(address)
+    if err != nil {
+        return stylesPath
+    }
+    styles, err := base.Parse(filepath.ToSlash(stylesPath))
+    if err != nil {
+        return stylesPath
+    }
+    return styles.String()
+}
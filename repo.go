@@ -0,0 +1,106 @@
+// Analyzing a code repository's READMEs, docs and doc comments
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+var (
+    // repoDocRegex matches the file names of a repository's docs outside
+    // its docs directory, at any depth
+    repoDocRegex = regexp.MustCompile(`(?i)^(?:readme|contributing)(?:\.(?:md|markdown|rst|txt))?$`)
+    // generatedSourceRegex matches the marker of a generated Go file
+    generatedSourceRegex = regexp.MustCompile(`(?m)^// Code generated .* DO NOT EDIT\.$`)
+)
+
+// repoSkippedDirs are left out of a repository walk on top of the usual
+// skippedDirs: test fixtures, virtual environments and bytecode caches
+var repoSkippedDirs = map[string]bool{
+    "testdata": true, "__pycache__": true, ".venv": true, "venv": true, ".tox": true, "third_party": true,
+}
+
+// RepoSource is a code repository checked out on disk
+type RepoSource struct {
+    Root     string
+    Comments bool              // include the doc comments of Go, Python and JavaScript/TypeScript files
+    Formats  map[string]Format // the configured formats, whose files under docs/ are documents too
+    MaxSize  int64             // -max-file-size; larger files are skipped unread, 0 for no limit
+}
+
+// runRepo implements the repo subcommand
+func runRepo(args []string) int {
+    flags := flag.NewFlagSet("repo", flag.ExitOnError)
+    common := addSourceFlags(flags)
+    skipComments := flags.Bool("skip-comments", false, "Analyze only the READMEs and docs, not the doc comments of source files")
+    maxSize := flags.String("max-file-size", "10MB", "Skip files larger than this (0 for no limit)")
+    flags.Parse(args)
+
+    source := RepoSource{Root: ".", Comments: !*skipComments}
+    var err error
+    if source.MaxSize, err = parseSize(*maxSize); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: -max-file-size: %v\n", err)
+        return 1
+    }
+    if flags.NArg() > 0 {
+        source.Root = flags.Arg(0)
+    }
+    common.location = source.Root
+    return common.runWith(func(analyzer *Analyzer) ([]SourceDocument, error) {
+        source.Formats = analyzer.config.Formats
+        return source.Fetch()
+    })
+}
+
+// Fetch returns the documentation of the repository: every README and
+// CONTRIBUTING file, the supported files under docs/ and doc/ directories,
+// and, with Comments, the source files whose doc comments are analyzed.
+// Tests, generated files and minified scripts are left out, and files over
+// MaxSize are reported as skipped.
+func (s RepoSource) Fetch() ([]SourceDocument, error) {
+    var docs []SourceDocument
+    err := filepath.WalkDir(s.Root, func(path string, entry fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        name := entry.Name()
+        if entry.IsDir() {
+            if path != s.Root && (skippedDirs[name] || repoSkippedDirs[name]) {
+                return filepath.SkipDir
+            }
+            return nil
+        }
+
+        inDocs := false
+        if rel, err := filepath.Rel(s.Root, path); err == nil {
+            for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(rel)), "/") {
+                inDocs = inDocs || dir == "docs" || dir == "doc"
+            }
+        }
+        source := s.Comments && sourceLanguage(path) != "" && !isSourceTest(path) && !strings.HasSuffix(name, ".min.js")
+        if !repoDocRegex.MatchString(name) && !(inDocs && isSupportedFile(path, s.Formats)) && !source {
+            return nil
+        }
+        if info, err := entry.Info(); err == nil && s.MaxSize > 0 && info.Size() > s.MaxSize {
+            reportSkipped(skippedFile(path, skipTooLarge, fmt.Sprintf("file is %s, over the %s limit", formatSize(info.Size()), formatSize(s.MaxSize))))
+            return nil
+        }
+        content, err := readDocument(path)
+        if err != nil {
+            reportFailure(readFailureRule, path, 0, "failed to read %s: %v", path, err)
+            return nil
+        }
+        if source && sourceLanguage(path) == "go" && generatedSourceRegex.MatchString(content) {
+            return nil
+        }
+        docs = append(docs, SourceDocument{Path: path, Content: content})
+        return nil
+    })
+    return docs, err
+}
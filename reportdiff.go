@@ -0,0 +1,170 @@
+// Comparing two result files by issue fingerprint
+
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+)
+
+// ReportDiff classifies the issues of two runs
+type ReportDiff struct {
+    New        []Issue `json:"new"`
+    Fixed      []Issue `json:"fixed"`
+    Persisting []Issue `json:"persisting"`
+    Summary    struct {
+        New        int `json:"new"`
+        Fixed      int `json:"fixed"`
+        Persisting int `json:"persisting"`
+    } `json:"summary"`
+}
+
+// fingerprints identifies each issue by its file, rule and matched text
+// (or message) together with how many identical issues precede it in the
+// file, and not by line or column, so an issue keeps its fingerprint when
+// edits elsewhere move it
+func fingerprints(issues []Issue) []string {
+    seen := make(map[string]int)
+    prints := make([]string, len(issues))
+    for i, issue := range issues {
+        text := issue.OriginalText
+        if text == "" {
+            text = issue.Message
+        }
+        key := strings.Join([]string{issue.File, issue.Rule, strings.TrimSpace(text)}, "\x00")
+        sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", key, seen[key])))
+        seen[key]++
+        prints[i] = hex.EncodeToString(sum[:8])
+    }
+    return prints
+}
+
+// diffReports matches the issues of a base and a head run by fingerprint
+func diffReports(base, head []Issue) ReportDiff {
+    var diff ReportDiff
+    basePrints := make(map[string]bool)
+    for _, fingerprint := range fingerprints(base) {
+        basePrints[fingerprint] = true
+    }
+
+    headPrints := make(map[string]bool)
+    for i, fingerprint := range fingerprints(head) {
+        headPrints[fingerprint] = true
+        if basePrints[fingerprint] {
+            diff.Persisting = append(diff.Persisting, head[i])
+        } else {
+            diff.New = append(diff.New, head[i])
+        }
+    }
+    for i, fingerprint := range fingerprints(base) {
+        if !headPrints[fingerprint] {
+            diff.Fixed = append(diff.Fixed, base[i])
+        }
+    }
+
+    diff.Summary.New = len(diff.New)
+    diff.Summary.Fixed = len(diff.Fixed)
+    diff.Summary.Persisting = len(diff.Persisting)
+    return diff
+}
+
+// readReport reads the issues of a JSON report written by -output json, or
+// a bare JSON array of issues. "-" reads standard input.
+func readReport(path string) ([]Issue, severityPolicy, error) {
+    var data []byte
+    var err error
+    if path == "-" {
+        data, err = io.ReadAll(os.Stdin)
+    } else {
+        data, err = os.ReadFile(path)
+    }
+    if err != nil {
+        return nil, nil, err
+    }
+
+    var issues []Issue
+    severities := builtinSeverities
+    if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+        err = json.Unmarshal(data, &issues)
+    } else {
+        var report struct {
+            Run    *RunMetadata `json:"run"`
+            Issues []Issue      `json:"issues"`
+        }
+        err = json.Unmarshal(data, &report)
+        issues = report.Issues
+        if report.Run != nil && len(report.Run.Severities) > 0 {
+            severities = report.Run.Severities.named()
+        }
+    }
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+    }
+    return issues, severities, nil
+}
+
+// runReportDiff implements the report-diff subcommand. It exits with
+// status 1 when the second report has issues the first doesn't.
+func runReportDiff(args []string) int {
+    flags := flag.NewFlagSet("report-diff", flag.ExitOnError)
+    outputFormat := flags.String("output", "standard", "Output format (standard, json)")
+    showPersisting := flags.Bool("persisting", false, "List persisting issues as well as new and fixed ones")
+    flags.Parse(args)
+
+    if flags.NArg() != 2 {
+        fmt.Fprintf(os.Stderr, "Usage: %s report-diff [options] <base.json> <head.json>\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+
+    base, _, err := readReport(flags.Arg(0))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+    head, _, err := readReport(flags.Arg(1))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+
+    diff := diffReports(base, head)
+    switch *outputFormat {
+    case "json":
+        encoder := json.NewEncoder(os.Stdout)
+        encoder.SetEscapeHTML(false)
+        encoder.SetIndent("", "  ")
+        if err := encoder.Encode(diff); err != nil {
+            fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+            return 1
+        }
+    default:
+        printReportDiff(diff, *showPersisting)
+    }
+
+    if len(diff.New) > 0 {
+        return 1
+    }
+    return 0
+}
+
+func printReportDiff(diff ReportDiff, showPersisting bool) {
+    list := func(label string, issues []Issue) {
+        for _, issue := range issues {
+            fmt.Printf("%-10s %s:%d:%d: %s [%s] %s\n", label,
+                issue.File, issue.Line, issue.Column, strings.ToUpper(issue.Severity), issue.Rule, issue.Message)
+        }
+    }
+    list("NEW", diff.New)
+    list("FIXED", diff.Fixed)
+    if showPersisting {
+        list("PERSISTING", diff.Persisting)
+    }
+    fmt.Printf("\n%d new, %d fixed, %d persisting\n", diff.Summary.New, diff.Summary.Fixed, diff.Summary.Persisting)
+}
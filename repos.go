@@ -0,0 +1,147 @@
+// Multi-repository runs: the doc sources a -repos manifest lists, cloned
+// shallowly and analyzed or exported as one corpus
+
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+
+    "gopkg.in/yaml.v3"
+)
+
+// RepoEntry is one source of a -repos manifest: a git repository, cloned
+// at depth 1 into the cache and fetched again on later runs, or a local
+// directory
+type RepoEntry struct {
+    Name   string   `yaml:"Name"`             // labels the source's issues and prefixes its paths in reports and chunk IDs
+    Repo   string   `yaml:"Repo,omitempty"`   // git URL of the repository
+    Ref    string   `yaml:"Ref,omitempty"`    // branch, tag or commit of Repo; its default branch if empty
+    Path   string   `yaml:"Path,omitempty"`   // local directory instead of Repo, relative to the manifest
+    Paths  []string `yaml:"Paths,omitempty"`  // files and directories within the source to analyze; all of it if empty
+    Config string   `yaml:"Config,omitempty"` // configuration within the source, or an HTTPS/git URL; discovered if empty
+}
+
+// loadRepoManifest reads a -repos manifest: a YAML Repos list
+func loadRepoManifest(path string) ([]RepoEntry, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var file struct {
+        Repos []RepoEntry `yaml:"Repos"`
+    }
+    if err := yaml.Unmarshal(data, &file); err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    if len(file.Repos) == 0 {
+        return nil, fmt.Errorf("%s lists no Repos", path)
+    }
+
+    names := make(map[string]bool)
+    for _, entry := range file.Repos {
+        switch {
+        case !projectNameRegex.MatchString(entry.Name):
+            return nil, fmt.Errorf("%s: invalid repo name %q (want letters, digits, '.', '_' or '-')", path, entry.Name)
+        case names[entry.Name]:
+            return nil, fmt.Errorf("%s: repo %s is listed twice", path, entry.Name)
+        case (entry.Repo == "") == (entry.Path == ""):
+            return nil, fmt.Errorf("%s: repo %s needs one of Repo or Path", path, entry.Name)
+        case entry.Ref != "" && entry.Repo == "":
+            return nil, fmt.Errorf("%s: repo %s has a Ref but no Repo", path, entry.Name)
+        }
+        names[entry.Name] = true
+        for _, inner := range entry.Paths {
+            if filepath.IsAbs(inner) || !filepath.IsLocal(inner) {
+                return nil, fmt.Errorf("%s: repo %s: path %s is outside the repo", path, entry.Name, inner)
+            }
+        }
+    }
+    return file.Repos, nil
+}
+
+// loadRepoRoots fetches the sources of a -repos manifest and returns a
+// root for each. A source's paths are reported under its name, and its
+// configuration is its Config or the one discovered in it, or else
+// fallback.
+func loadRepoRoots(manifest, fallback string) ([]projectRoot, error) {
+    entries, err := loadRepoManifest(manifest)
+    if err != nil {
+        return nil, err
+    }
+    var roots []projectRoot
+    for _, entry := range entries {
+        dir := entry.Path
+        if entry.Repo != "" {
+            fmt.Fprintf(os.Stderr, "Fetching %s\n", entry.Repo)
+            if dir, err = fetchGit(entry.Repo, entry.Ref); err != nil {
+                return nil, fmt.Errorf("repo %s: %w", entry.Name, err)
+            }
+        } else if !filepath.IsAbs(dir) {
+            dir = filepath.Join(filepath.Dir(manifest), dir)
+        }
+        if _, err := os.Stat(dir); err != nil {
+            return nil, fmt.Errorf("repo %s: %w", entry.Name, err)
+        }
+        if dir, err = filepath.Abs(dir); err != nil {
+            return nil, fmt.Errorf("repo %s: %w", entry.Name, err)
+        }
+
+        root := projectRoot{label: entry.Name, base: dir, shown: entry.Name}
+        for _, inner := range entry.Paths {
+            root.paths = append(root.paths, filepath.Join(dir, filepath.FromSlash(inner)))
+        }
+        if len(root.paths) == 0 {
+            root.paths = []string{root.base}
+        }
+        switch {
+        case entry.Config == "":
+            root.config = discoverConfig(dir, fallback)
+        case isRemote(entry.Config) || filepath.IsAbs(entry.Config):
+            root.config = entry.Config
+        default:
+            root.config = filepath.Join(dir, filepath.FromSlash(entry.Config))
+        }
+        roots = append(roots, root)
+    }
+    return roots, nil
+}
+
+// repoDocuments loads the documents of each root for export, with the
+// Formats of the root's configuration, each under the path its root shows
+// it as. It returns the documents with the path each was read from and the
+// name of its source.
+func repoDocuments(roots []projectRoot, walk WalkOptions) (docs []*Document, read, sources map[*Document]string) {
+    read, sources = make(map[*Document]string), make(map[*Document]string)
+    configs := make(map[string]*Config)
+    for _, root := range roots {
+        config, ok := configs[root.config]
+        if !ok {
+            var err error
+            if config, err = loadConfig(root.config); err != nil {
+                fmt.Fprintf(os.Stderr, "Error: root %s: %v\n", root.label, err)
+                continue
+            }
+            configs[root.config] = config
+        }
+        walk := walk
+        walk.Formats = config.Formats
+        var files []string
+        for _, path := range root.paths {
+            found, err := collectFiles(path, walk)
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "Error processing %s: %v\n", root.show(path), err)
+                continue
+            }
+            files = append(files, found...)
+        }
+        for _, doc := range loadDocuments(files, config.Formats) {
+            read[doc] = doc.Path
+            sources[doc] = root.label
+            doc.Path = root.show(doc.Path)
+            docs = append(docs, doc)
+        }
+    }
+    return docs, read, sources
+}
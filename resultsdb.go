@@ -0,0 +1,469 @@
+// Results database: runs and their issues persisted to SQLite
+
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "os"
+    "os/exec"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// resultsSchema creates the tables and views of a results database. Issues
+// carry the fingerprint report-diff matches them by, so the views can
+// follow an issue across runs while edits elsewhere move it. The views
+// compare each run with the one before it of the same command and paths,
+// so runs over other paths don't read as fixing every issue. A run's
+// severities keep their ranks, so run_summary counts a custom severity
+// with the built-in one it ranks with; runs recorded before severities had
+// rows count the built-in names only.
+const resultsSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+    id             INTEGER PRIMARY KEY,
+    started_at     TEXT NOT NULL,
+    finished_at    TEXT NOT NULL,
+    tool_version   TEXT NOT NULL,
+    config_hash    TEXT NOT NULL,
+    revision       TEXT,
+    command        TEXT NOT NULL,
+    paths          TEXT NOT NULL,
+    files_analyzed INTEGER NOT NULL,
+    files_skipped  INTEGER NOT NULL,
+    issues         INTEGER NOT NULL,
+    words          INTEGER NOT NULL,
+    score          REAL NOT NULL
+);
+CREATE TABLE IF NOT EXISTS files (
+    run_id INTEGER NOT NULL REFERENCES runs (id),
+    path   TEXT NOT NULL,
+    issues INTEGER NOT NULL,
+    words  INTEGER NOT NULL,
+    score  REAL NOT NULL,
+    PRIMARY KEY (run_id, path)
+);
+CREATE TABLE IF NOT EXISTS issues (
+    run_id        INTEGER NOT NULL REFERENCES runs (id),
+    fingerprint   TEXT NOT NULL,
+    file          TEXT NOT NULL,
+    line          INTEGER NOT NULL,
+    col           INTEGER NOT NULL,
+    rule          TEXT NOT NULL,
+    severity      TEXT NOT NULL,
+    message       TEXT NOT NULL,
+    suggestion    TEXT NOT NULL,
+    original_text TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS severities (
+    run_id   INTEGER NOT NULL REFERENCES runs (id),
+    severity TEXT NOT NULL,
+    rank     INTEGER NOT NULL,
+    issues   INTEGER NOT NULL,
+    PRIMARY KEY (run_id, severity)
+);
+CREATE INDEX IF NOT EXISTS issues_run ON issues (run_id);
+CREATE INDEX IF NOT EXISTS issues_fingerprint ON issues (fingerprint);
+
+CREATE VIEW IF NOT EXISTS run_summary AS
+SELECT r.id AS run_id, r.started_at, r.revision, r.command, r.paths, r.files_analyzed, r.issues, r.score,
+    coalesce((SELECT sum(s.issues) FROM severities s WHERE s.run_id = r.id AND s.severity != 'failure' AND s.rank >= r.error_rank),
+        (SELECT count(*) FROM issues i WHERE i.run_id = r.id AND i.severity = 'error')) AS errors,
+    coalesce((SELECT sum(s.issues) FROM severities s WHERE s.run_id = r.id AND s.severity != 'failure' AND s.rank >= r.warning_rank AND s.rank < r.error_rank),
+        (SELECT count(*) FROM issues i WHERE i.run_id = r.id AND i.severity = 'warning')) AS warnings,
+    coalesce((SELECT sum(s.issues) FROM severities s WHERE s.run_id = r.id AND s.severity != 'failure' AND s.rank < r.warning_rank),
+        (SELECT count(*) FROM issues i WHERE i.run_id = r.id AND i.severity = 'suggestion')) AS suggestions,
+    (SELECT count(*) FROM issues i WHERE i.run_id = r.id AND i.fingerprint NOT IN
+        (SELECT fingerprint FROM issues p WHERE p.run_id = r.previous)) AS new_issues,
+    (SELECT count(*) FROM issues p WHERE p.run_id = r.previous AND p.fingerprint NOT IN
+        (SELECT fingerprint FROM issues i WHERE i.run_id = r.id)) AS fixed_issues
+FROM (SELECT *, (SELECT max(p.id) FROM runs p WHERE p.id < runs.id AND p.command = runs.command AND p.paths = runs.paths) AS previous,
+        (SELECT rank FROM severities s WHERE s.run_id = runs.id AND s.severity = 'error') AS error_rank,
+        (SELECT rank FROM severities s WHERE s.run_id = runs.id AND s.severity = 'warning') AS warning_rank
+    FROM runs) r;
+
+CREATE VIEW IF NOT EXISTS issue_lifecycle AS
+SELECT command, paths, fingerprint, file, rule, severity, message, first_run, last_run, runs,
+    (SELECT started_at FROM runs WHERE id = first_run) AS first_seen,
+    (SELECT started_at FROM runs WHERE id = last_run) AS last_seen,
+    (SELECT min(s.id) FROM runs s WHERE s.id > last_run AND s.command = l.command AND s.paths = l.paths) AS fixed_in,
+    CASE WHEN last_run = (SELECT max(s.id) FROM runs s WHERE s.command = l.command AND s.paths = l.paths) THEN 'open' ELSE 'fixed' END AS status
+FROM (SELECT r.command, r.paths, i.fingerprint, i.file, i.rule, i.severity, i.message,
+        min(i.run_id) AS first_run, max(i.run_id) AS last_run, count(DISTINCT i.run_id) AS runs
+    FROM issues i JOIN runs r ON r.id = i.run_id GROUP BY r.command, r.paths, i.fingerprint) l;
+
+CREATE VIEW IF NOT EXISTS rule_trend AS
+SELECT run_id, rule, count(*) AS issues FROM issues GROUP BY run_id, rule;
+`
+
+// resultsVersion is the schema version of a results database, kept in its
+// user_version. Databases written before the schema had a version read as
+// 0.
+const resultsVersion = 2
+
+// resultsColumns are the columns added to the tables after their first
+// release, which migrate adds to an older database
+var resultsColumns = []struct{ table, column, definition string }{
+    {"runs", "words", "INTEGER NOT NULL DEFAULT 0"},
+    {"runs", "score", "REAL"},
+    {"files", "words", "INTEGER NOT NULL DEFAULT 0"},
+    {"files", "score", "REAL"},
+    {"runs", "command", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// lockWait is how long a statement waits for another process, such as a
+// scheduled job or a CI run, to finish writing the database before failing
+const lockWait = 30 * time.Second
+
+// ResultsDB is a SQLite results database. It is written and queried with
+// the sqlite3 command-line shell, so the tool needs no database driver.
+type ResultsDB struct {
+    Path string
+}
+
+// exec runs script against the database, creating it and its schema, or
+// bringing an older schema up to date, if needed, and returns what the
+// shell prints
+func (db ResultsDB) exec(script string) (string, error) {
+    migration, err := db.migration()
+    if err != nil {
+        return "", err
+    }
+    return db.shell(migration + resultsSchema + script)
+}
+
+// migration returns the script that brings the database's schema up to
+// resultsVersion: the columns it lacks, and its views dropped, as the
+// schema only creates views that are missing. A new database only needs
+// its version set.
+func (db ResultsDB) migration() (string, error) {
+    out, err := db.shell("PRAGMA user_version;\nSELECT m.name || '.' || c.name FROM sqlite_master m, pragma_table_info(m.name) c WHERE m.type = 'table';\n")
+    if err != nil {
+        return "", err
+    }
+    lines := strings.Fields(out)
+    version := 0
+    if len(lines) > 0 {
+        version, _ = strconv.Atoi(lines[0])
+        lines = lines[1:]
+    }
+    switch {
+    case version > resultsVersion:
+        return "", fmt.Errorf("%s has schema version %d, newer than this version of the tool reads (%d)", db.Path, version, resultsVersion)
+    case version == resultsVersion:
+        return "", nil
+    }
+
+    var script strings.Builder
+    script.WriteString("BEGIN IMMEDIATE;\n")
+    if len(lines) > 0 {
+        columns := make(map[string]bool)
+        for _, column := range lines {
+            columns[column] = true
+        }
+        for _, added := range resultsColumns {
+            if !columns[added.table+"."+added.column] {
+                fmt.Fprintf(&script, "ALTER TABLE %s ADD COLUMN %s %s;\n", added.table, added.column, added.definition)
+            }
+        }
+        script.WriteString("DROP VIEW IF EXISTS run_summary;\nDROP VIEW IF EXISTS issue_lifecycle;\nDROP VIEW IF EXISTS rule_trend;\n")
+    }
+    fmt.Fprintf(&script, "PRAGMA user_version = %d;\nCOMMIT;\n", resultsVersion)
+    return script.String(), nil
+}
+
+// shell runs script against the database with the sqlite3 shell and
+// returns what it prints
+func (db ResultsDB) shell(script string) (string, error) {
+    cmd := exec.Command("sqlite3", "-bail", db.Path)
+    cmd.Stdin = strings.NewReader(fmt.Sprintf(".timeout %d\n", lockWait.Milliseconds()) + script)
+    var stdout, stderr bytes.Buffer
+    cmd.Stdout, cmd.Stderr = &stdout, &stderr
+    if err := cmd.Run(); err != nil {
+        if message := strings.TrimSpace(stderr.String()); message != "" {
+            return "", fmt.Errorf("sqlite3 %s: %s", db.Path, message)
+        }
+        return "", fmt.Errorf("sqlite3 %s: %w", db.Path, err)
+    }
+    return stdout.String(), nil
+}
+
+// query returns the rows of a SELECT, each a list of column values; NULL
+// reads as ""
+func (db ResultsDB) query(sql string) ([][]string, error) {
+    // ASCII mode separates columns and rows with the unit and record
+    // separators, which don't occur in the text of issues
+    out, err := db.exec(".mode ascii\n" + sql + ";\n")
+    if err != nil {
+        return nil, err
+    }
+    var rows [][]string
+    for _, row := range strings.Split(out, "\x1e") {
+        if row != "" {
+            rows = append(rows, strings.Split(row, "\x1f"))
+        }
+    }
+    return rows, nil
+}
+
+// record adds a run, the files it analyzed, with their word counts, and
+// the issues it reported, in one transaction. Each file, and the run as a
+// whole, is scored with readinessScore over its words.
+func (db ResultsDB) record(run *RunMetadata, files []string, words map[string]int, issues []Issue) error {
+    paths, _ := json.Marshal(run.Paths)
+    revision := "NULL"
+    if commit, err := git("rev-parse", "HEAD"); err == nil {
+        revision = sqlQuote(commit)
+    }
+
+    perFile := make(map[string][]Issue)
+    bySeverity := make(map[string]int)
+    for _, issue := range issues {
+        perFile[issue.File] = append(perFile[issue.File], issue)
+        bySeverity[issue.Severity]++
+    }
+    for name := range run.Severities {
+        if _, ok := bySeverity[name]; !ok {
+            bySeverity[name] = 0 // every level gets a row, so its count reads 0 rather than missing
+        }
+    }
+    var fileRows strings.Builder
+    seen := make(map[string]bool)
+    total := 0
+    for _, file := range files {
+        if seen[file] {
+            continue
+        }
+        seen[file] = true
+        total += words[file]
+        fmt.Fprintf(&fileRows, "INSERT INTO files VALUES ((SELECT id FROM current_run), %s, %d, %d, %.2f);\n",
+            sqlQuote(file), len(perFile[file]), words[file], readinessScore(perFile[file], words[file], run.Severities))
+    }
+
+    // Immediate, so a concurrent writer makes it wait rather than fail
+    var script strings.Builder
+    script.WriteString("BEGIN IMMEDIATE;\n")
+    fmt.Fprintf(&script, "INSERT INTO runs (started_at, finished_at, tool_version, config_hash, revision, command, paths, files_analyzed, files_skipped, issues, words, score) VALUES (%s, %s, %s, %s, %s, %s, %s, %d, %d, %d, %d, %.2f);\n",
+        sqlQuote(run.StartedAt.Format(timeLayout)), sqlQuote(run.FinishedAt.Format(timeLayout)), sqlQuote(run.ToolVersion), sqlQuote(run.ConfigHash),
+        revision, sqlQuote(run.Command), sqlQuote(string(paths)), run.FilesAnalyzed, run.FilesSkipped, len(issues), total, readinessScore(issues, total, run.Severities))
+    script.WriteString("CREATE TEMP TABLE current_run AS SELECT last_insert_rowid() AS id;\n")
+    script.WriteString(fileRows.String())
+    for i, fingerprint := range fingerprints(issues) {
+        issue := issues[i]
+        fmt.Fprintf(&script, "INSERT INTO issues VALUES ((SELECT id FROM current_run), %s, %s, %d, %d, %s, %s, %s, %s, %s);\n",
+            sqlQuote(fingerprint), sqlQuote(issue.File), issue.Line, issue.Column, sqlQuote(issue.Rule), sqlQuote(issue.Severity),
+            sqlQuote(issue.Message), sqlQuote(issue.Suggestion), sqlQuote(issue.OriginalText))
+    }
+    for _, severity := range sortedKeys(bySeverity) {
+        fmt.Fprintf(&script, "INSERT INTO severities VALUES ((SELECT id FROM current_run), %s, %d, %d);\n",
+            sqlQuote(severity), run.Severities.rank(severity), bySeverity[severity])
+    }
+    script.WriteString("COMMIT;\n")
+    _, err := db.exec(script.String())
+    return err
+}
+
+// reportRun records a finished run in the results database at dbPath, if
+// one is given, and notifies webhook when the run breaches its thresholds,
+// with the score of the run recorded before it. Only recording can fail;
+// a failed notification is a warning.
+func reportRun(dbPath string, webhook WebhookConfig, run *RunMetadata, files []string, words map[string]int, issues []Issue) error {
+    var previous *float64
+    if dbPath != "" {
+        db := ResultsDB{Path: dbPath}
+        if score, ok, err := db.lastScore(run); err == nil && ok {
+            previous = &score
+        }
+        if err := db.record(run, files, words, issues); err != nil {
+            return err
+        }
+    }
+
+    total := 0
+    for _, count := range words {
+        total += count
+    }
+    paths := run.Paths
+    if len(paths) == 0 {
+        paths = []string{run.Command}
+    }
+    if _, err := webhook.notify(newWebhookSummary(paths, issues, total, previous, run.Severities)); err != nil {
+        fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+    }
+    return nil
+}
+
+// lastScore returns the score of the most recent run of the same command
+// and paths as run, or false if the database has none
+func (db ResultsDB) lastScore(run *RunMetadata) (float64, bool, error) {
+    paths, _ := json.Marshal(run.Paths)
+    rows, err := db.query(fmt.Sprintf("SELECT score FROM runs WHERE command = %s AND paths = %s ORDER BY id DESC LIMIT 1", sqlQuote(run.Command), sqlQuote(string(paths))))
+    if err != nil || len(rows) == 0 {
+        return 0, false, err
+    }
+    score, err := strconv.ParseFloat(rows[0][0], 64)
+    return score, err == nil, err
+}
+
+// timeLayout is how the database stores times: RFC 3339 in UTC, which
+// sorts as text and which SQLite's date functions read
+const timeLayout = "2006-01-02T15:04:05Z"
+
+// sqlQuote returns s as an SQL string literal. NUL bytes, which the shell
+// can't read, are dropped.
+func sqlQuote(s string) string {
+    return "'" + strings.ReplaceAll(strings.ReplaceAll(s, "\x00", ""), "'", "''") + "'"
+}
+
+// RunSummary is a row of the run_summary view
+type RunSummary struct {
+    Run           int     `json:"run"`
+    StartedAt     string  `json:"started_at"`
+    Revision      string  `json:"revision,omitempty"`
+    FilesAnalyzed int     `json:"files_analyzed"`
+    Issues        int     `json:"issues"`
+    Score         float64 `json:"score"`
+    Errors        int     `json:"errors"`
+    Warnings      int     `json:"warnings"`
+    Suggestions   int     `json:"suggestions"`
+    New           int     `json:"new"`
+    Fixed         int     `json:"fixed"`
+}
+
+// IssueLifecycle is a row of the issue_lifecycle view
+type IssueLifecycle struct {
+    Fingerprint string `json:"fingerprint"`
+    File        string `json:"file"`
+    Rule        string `json:"rule"`
+    Severity    string `json:"severity"`
+    Message     string `json:"message"`
+    Status      string `json:"status"` // "open" or "fixed"
+    FirstSeen   string `json:"first_seen"`
+    LastSeen    string `json:"last_seen"`
+    Runs        int    `json:"runs"`
+}
+
+// runSummaries returns the last limit runs, oldest first, or all of them
+// if limit is 0
+func (db ResultsDB) runSummaries(limit int) ([]RunSummary, error) {
+    sql := "SELECT run_id, started_at, revision, files_analyzed, issues, score, errors, warnings, suggestions, new_issues, fixed_issues FROM run_summary ORDER BY run_id"
+    if limit > 0 {
+        sql = fmt.Sprintf("SELECT * FROM (%s DESC LIMIT %d) ORDER BY run_id", sql, limit)
+    }
+    rows, err := db.query(sql)
+    if err != nil {
+        return nil, err
+    }
+    summaries := make([]RunSummary, 0, len(rows))
+    for _, row := range rows {
+        if len(row) != 11 {
+            return nil, fmt.Errorf("unexpected run_summary row %q", row)
+        }
+        n := make([]int, len(row))
+        for i := range row {
+            n[i], _ = strconv.Atoi(row[i])
+        }
+        score, _ := strconv.ParseFloat(row[5], 64)
+        summaries = append(summaries, RunSummary{
+            Run: n[0], StartedAt: row[1], Revision: row[2], FilesAnalyzed: n[3], Issues: n[4], Score: score,
+            Errors: n[6], Warnings: n[7], Suggestions: n[8], New: n[9], Fixed: n[10],
+        })
+    }
+    return summaries, nil
+}
+
+// lifecycles returns the issues of every run with when they were first
+// and last reported, open ones first and the longest-standing first, with
+// status "open" or "fixed" to select only those
+func (db ResultsDB) lifecycles(status string) ([]IssueLifecycle, error) {
+    sql := "SELECT fingerprint, file, rule, severity, message, status, first_seen, last_seen, runs FROM issue_lifecycle"
+    if status != "" {
+        sql += " WHERE status = " + sqlQuote(status)
+    }
+    rows, err := db.query(sql + " ORDER BY status = 'fixed', first_seen, file, rule")
+    if err != nil {
+        return nil, err
+    }
+    lifecycles := make([]IssueLifecycle, 0, len(rows))
+    for _, row := range rows {
+        if len(row) != 9 {
+            return nil, fmt.Errorf("unexpected issue_lifecycle row %q", row)
+        }
+        runs, _ := strconv.Atoi(row[8])
+        lifecycles = append(lifecycles, IssueLifecycle{
+            Fingerprint: row[0], File: row[1], Rule: row[2], Severity: row[3], Message: row[4],
+            Status: row[5], FirstSeen: row[6], LastSeen: row[7], Runs: runs,
+        })
+    }
+    return lifecycles, nil
+}
+
+// runHistory implements the history subcommand, which reads back a
+// results database written with -db
+func runHistory(args []string) int {
+    flags := flag.NewFlagSet("history", flag.ExitOnError)
+    dbPath := flags.String("db", "results.db", "SQLite results database written by analyze -db")
+    outputFormat := flags.String("output", "standard", "Output format (standard, json)")
+    issues := flags.Bool("issues", false, "List each issue with when it was first and last reported, instead of the runs")
+    status := flags.String("status", "", "With -issues, list only open or fixed issues")
+    limit := flags.Int("limit", 20, "Number of most recent runs to list, 0 for all")
+    flags.Parse(args)
+
+    if flags.NArg() != 0 || (*status != "" && *status != "open" && *status != "fixed") {
+        fmt.Fprintf(os.Stderr, "Usage: %s history [options]\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+    if _, err := os.Stat(*dbPath); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+
+    db := ResultsDB{Path: *dbPath}
+    var result any
+    var err error
+    if *issues {
+        result, err = db.lifecycles(*status)
+    } else {
+        result, err = db.runSummaries(*limit)
+    }
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+
+    if *outputFormat == "json" {
+        encoder := json.NewEncoder(os.Stdout)
+        encoder.SetEscapeHTML(false)
+        encoder.SetIndent("", "  ")
+        if err := encoder.Encode(result); err != nil {
+            fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+            return 1
+        }
+        return 0
+    }
+    switch result := result.(type) {
+    case []RunSummary:
+        for _, run := range result {
+            revision := ""
+            if run.Revision != "" {
+                revision = " " + run.Revision[:min(len(run.Revision), 12)]
+            }
+            other := ""
+            if n := run.Issues - run.Errors - run.Warnings - run.Suggestions; n > 0 {
+                other = fmt.Sprintf(", %d of other severities", n) // failures, and custom severities of older runs
+            }
+            fmt.Printf("#%-5d %s%s  score %.1f, %d files, %d issues (%d errors, %d warnings, %d suggestions%s), %d new, %d fixed\n",
+                run.Run, run.StartedAt, revision, run.Score, run.FilesAnalyzed, run.Issues, run.Errors, run.Warnings, run.Suggestions, other, run.New, run.Fixed)
+        }
+    case []IssueLifecycle:
+        for _, issue := range result {
+            fmt.Printf("%-6s %s .. %s (%d runs) %s [%s] %s\n",
+                strings.ToUpper(issue.Status), issue.FirstSeen, issue.LastSeen, issue.Runs, issue.File, issue.Rule, issue.Message)
+        }
+    }
+    return 0
+}
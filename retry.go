@@ -0,0 +1,139 @@
+// Rate limiting, retries and a circuit breaker for provider calls
+
+package main
+
+import (
+    "errors"
+    "fmt"
+    "math/rand"
+    "net/http"
+    "os"
+    "strconv"
+    "sync"
+    "time"
+)
+
+const (
+    // defaultMaxRetries is how often a failed provider call is retried
+    defaultMaxRetries = 3
+    // retryBaseDelay and maxRetryDelay bound the exponential backoff
+    retryBaseDelay = time.Second
+    maxRetryDelay  = 30 * time.Second
+    // breakerThreshold is the number of consecutive failed calls, after
+    // retries, that stops further calls for the rest of the run
+    breakerThreshold = 3
+)
+
+// errCircuitOpen is returned for calls made after the breaker opened
+var errCircuitOpen = errors.New("stopped calling the provider after repeated failures")
+
+// rateLimiter spaces calls to a provider evenly, to at most a given number
+// per minute. A nil limiter doesn't wait.
+type rateLimiter struct {
+    mu       sync.Mutex
+    interval time.Duration
+    next     time.Time
+}
+
+// newRateLimiter returns a limiter for perMinute calls, or nil for no limit
+func newRateLimiter(perMinute int) *rateLimiter {
+    if perMinute <= 0 {
+        return nil
+    }
+    return &rateLimiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// wait blocks until the next call may start
+func (l *rateLimiter) wait() {
+    if l == nil {
+        return
+    }
+    l.mu.Lock()
+    now := time.Now()
+    start := l.next
+    if start.Before(now) {
+        start = now
+    }
+    l.next = start.Add(l.interval)
+    l.mu.Unlock()
+    time.Sleep(time.Until(start))
+}
+
+// circuitBreaker stops calling a provider that keeps failing, so the run
+// degrades to its non-semantic checks instead of waiting out every call
+type circuitBreaker struct {
+    mu       sync.Mutex
+    failures int
+    open     bool
+}
+
+// allow reports whether calls may still be made
+func (b *circuitBreaker) allow() bool {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return !b.open
+}
+
+// record counts a call's outcome, opening the breaker, with a warning, at
+// breakerThreshold consecutive failures
+func (b *circuitBreaker) record(name string, err error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if err == nil {
+        b.failures = 0
+        return
+    }
+    b.failures++
+    if b.failures >= breakerThreshold && !b.open {
+        b.open = true
+        fmt.Fprintf(os.Stderr, "Warning: %s failed %d times in a row; not calling it again this run: %v\n", name, b.failures, err)
+    }
+}
+
+// retryable reports whether a response status is worth retrying: rate
+// limiting and server errors
+func retryable(status int) bool {
+    return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500
+}
+
+// retryDelay returns the wait before retry attempt (0-based): the
+// response's Retry-After in seconds if it gives one, or an exponential
+// backoff from retryBaseDelay with jitter, capped at maxRetryDelay
+func retryDelay(attempt int, response *http.Response) time.Duration {
+    if response != nil {
+        if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+            return min(time.Duration(seconds)*time.Second, maxRetryDelay)
+        }
+    }
+    // Doubling stops at the cap, before the shift could overflow
+    delay := retryBaseDelay
+    for i := 0; i < attempt && delay < maxRetryDelay; i++ {
+        delay *= 2
+    }
+    delay = min(delay, maxRetryDelay)
+    return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// doWithRetry sends the request that build returns, waiting for the rate
+// limiter before each attempt and retrying network errors and retryable
+// statuses up to maxRetries times. build is called for every attempt, so
+// each gets a fresh body. The last response is returned whatever its
+// status, for the caller to check.
+func doWithRetry(client *http.Client, limiter *rateLimiter, maxRetries int, build func() (*http.Request, error)) (*http.Response, error) {
+    for attempt := 0; ; attempt++ {
+        request, err := build()
+        if err != nil {
+            return nil, err
+        }
+        limiter.wait()
+        response, err := client.Do(request)
+        if attempt >= maxRetries || (err == nil && !retryable(response.StatusCode)) {
+            return response, err
+        }
+        delay := retryDelay(attempt, response)
+        if response != nil {
+            response.Body.Close()
+        }
+        time.Sleep(delay)
+    }
+}
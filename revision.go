@@ -0,0 +1,183 @@
+// Analyzing another git revision for -only-new
+
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+)
+
+// revisionResults is what analyzing a revision found: the issues and the
+// content of the documents, both under the working tree paths. It is
+// cached as JSON, see revisionCachePath.
+type revisionResults struct {
+    Issues    []Issue
+    Documents []revisionDocument
+}
+
+// revisionDocument is the content of one document at a revision
+type revisionDocument struct {
+    Path    string
+    Content string
+}
+
+// analyzeRevision analyzes paths as they are at the git revision base,
+// checked out into a temporary worktree. Issues are reported under the
+// working tree paths, so their fingerprints match those of the current run.
+// Paths that don't exist at base contribute no issues. The documents
+// analyzed are returned too, under the working tree paths. The results are
+// cached by commit, so later runs against the same base, configuration
+// and paths don't check it out again.
+func analyzeRevision(analyzer *Analyzer, base string, paths []string, walk WalkOptions, limits FileLimits, corpusOptions CorpusOptions) ([]Issue, []*Document, error) {
+    top, err := git("rev-parse", "--show-toplevel")
+    if err != nil {
+        return nil, nil, err
+    }
+    commit, err := git("rev-parse", "--verify", "--quiet", base+"^{commit}")
+    if err != nil {
+        return nil, nil, fmt.Errorf("unknown revision %s", base)
+    }
+    top, err = filepath.EvalSymlinks(top)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    // rels[i] is paths[i] relative to the top of the checkout
+    var rels []string
+    for _, path := range paths {
+        abs, err := filepath.Abs(path)
+        if err != nil {
+            return nil, nil, err
+        }
+        if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+            abs = resolved
+        }
+        rel, err := filepath.Rel(top, abs)
+        if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+            return nil, nil, fmt.Errorf("%s is outside the repository", path)
+        }
+        rels = append(rels, rel)
+    }
+
+    cached, cachePath := revisionResults{}, revisionCachePath(analyzer, commit, paths, rels, walk, limits, corpusOptions)
+    if data, err := os.ReadFile(cachePath); err == nil && json.Unmarshal(data, &cached) == nil {
+        return cached.Issues, cached.documents(analyzer.config.Formats), nil
+    }
+
+    worktree, err := os.MkdirTemp("", "ai-doc-optimizer-base-")
+    if err != nil {
+        return nil, nil, err
+    }
+    defer os.RemoveAll(worktree)
+    if _, err := git("worktree", "add", "--detach", "--quiet", worktree, commit); err != nil {
+        return nil, nil, err
+    }
+    defer git("worktree", "remove", "--force", worktree)
+
+    // basePaths[i] is the worktree counterpart of headPaths[i]
+    var basePaths, headPaths []string
+    for i, path := range paths {
+        basePath := filepath.Join(worktree, rels[i])
+        if _, err := os.Stat(basePath); err != nil {
+            continue
+        }
+        basePaths = append(basePaths, basePath)
+        headPaths = append(headPaths, path)
+    }
+
+    results := revisionResults{Issues: analyzePaths(analyzer, basePaths, walk, limits, corpusOptions)}
+    for j, basePath := range basePaths {
+        found, err := collectFiles(basePath, walk)
+        if err != nil {
+            continue
+        }
+        for _, file := range found {
+            if hasEmbeddedDocs(file) {
+                continue
+            }
+            content, err := readDocument(file)
+            if err != nil {
+                continue // reported by the analysis, among the failures the run drops
+            }
+            results.Documents = append(results.Documents, revisionDocument{rebasePath(file, basePath, headPaths[j]), content})
+        }
+    }
+    for i := range results.Issues {
+        for j, basePath := range basePaths {
+            results.Issues[i].File = rebasePath(results.Issues[i].File, basePath, headPaths[j])
+            results.Issues[i].Message = strings.ReplaceAll(results.Issues[i].Message, basePath, filepath.Clean(headPaths[j]))
+            results.Issues[i].Suggestion = strings.ReplaceAll(results.Issues[i].Suggestion, basePath, filepath.Clean(headPaths[j]))
+        }
+    }
+
+    // A failure to cache only costs a later run the checkout
+    if data, err := json.Marshal(results); err == nil && cachePath != "" && os.MkdirAll(filepath.Dir(cachePath), 0o755) == nil {
+        if temp, err := os.CreateTemp(filepath.Dir(cachePath), ".results-"); err == nil {
+            _, err = temp.Write(data)
+            if closeErr := temp.Close(); err == nil && closeErr == nil {
+                os.Rename(temp.Name(), cachePath)
+            }
+            os.Remove(temp.Name())
+        }
+    }
+    return results.Issues, results.documents(analyzer.config.Formats), nil
+}
+
+// documents parses the cached documents for the cross-file checks
+func (r revisionResults) documents(formats map[string]Format) []*Document {
+    var docs []*Document
+    for _, doc := range r.Documents {
+        docs = append(docs, corpusDocument(doc.Path, doc.Content, formats))
+    }
+    return docs
+}
+
+// revisionCachePath returns the file the results of analyzing paths at a
+// commit are cached in, under revisions in the cache directory, or "" if
+// there is none. The key covers everything the results depend on that a
+// commit doesn't fix: the tool version, the effective configuration and
+// rules, the paths and the options of the run.
+func revisionCachePath(analyzer *Analyzer, commit string, paths, rels []string, walk WalkOptions, limits FileLimits, corpusOptions CorpusOptions) string {
+    dir, err := cacheDir()
+    if err != nil {
+        return ""
+    }
+    options := fmt.Sprintf("%q %q %t %t %+v %+v", paths, rels, walk.Recursive, walk.FollowSymlinks, limits, corpusOptions)
+    key := strings.Join([]string{toolVersion, commit, yamlHash(analyzer.config), yamlHash(analyzer.rules), options}, "\x00")
+    sum := sha256.Sum256([]byte(key))
+    return filepath.Join(dir, "revisions", hex.EncodeToString(sum[:16])+".json")
+}
+
+// rebasePath maps a file at or under from to the same place under to
+func rebasePath(file, from, to string) string {
+    if file == from {
+        return to
+    }
+    if rest, ok := strings.CutPrefix(file, from+string(filepath.Separator)); ok {
+        return filepath.Join(to, rest)
+    }
+    return file
+}
+
+// git runs a git command in the current directory and returns its trimmed
+// output
+func git(args ...string) (string, error) {
+    out, err := exec.Command("git", args...).Output()
+    if err != nil {
+        command := args[0]
+        if command == "-C" && len(args) > 2 {
+            command = args[2]
+        }
+        if exit, ok := err.(*exec.ExitError); ok && len(exit.Stderr) > 0 {
+            return "", fmt.Errorf("git %s: %s", command, strings.TrimSpace(string(exit.Stderr)))
+        }
+        return "", fmt.Errorf("git %s: %w", command, err)
+    }
+    return strings.TrimSpace(string(out)), nil
+}
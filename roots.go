@@ -0,0 +1,232 @@
+// Multi-root runs: several doc trees analyzed in one invocation, each with
+// the configuration nearest it
+
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// configFileName is the configuration file init writes and -roots discovers
+const configFileName = ".ai-doc-optimizer.yml"
+
+// projectRoot is one root of a -roots or -repos run
+type projectRoot struct {
+    label    string    // name the root's issues are labeled with
+    paths    []string  // files and directories analyzed
+    config   string    // configuration it's analyzed with, "" for the defaults
+    analyzer *Analyzer // analyzer for config
+
+    // A root checked out elsewhere, as the repositories of -repos are, is
+    // reported with shown in place of its directory, base
+    base, shown string
+}
+
+// parseRoot splits a -roots argument into its label and path: "name=path"
+// names the root, and a bare path is its own label
+func parseRoot(arg string) (label, path string) {
+    if name, path, ok := strings.Cut(arg, "="); ok && projectNameRegex.MatchString(name) {
+        return name, path
+    }
+    return filepath.ToSlash(filepath.Clean(arg)), arg
+}
+
+// discoverConfig returns the configuration file a root is analyzed with:
+// the one in its directory or the nearest one above it, looking no higher
+// than the top of its git checkout, or else fallback
+func discoverConfig(root, fallback string) string {
+    dir, err := filepath.Abs(root)
+    if err != nil {
+        return fallback
+    }
+    if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+        dir = filepath.Dir(dir)
+    }
+    for {
+        candidate := filepath.Join(dir, configFileName)
+        if _, err := os.Stat(candidate); err == nil {
+            if cwd, err := os.Getwd(); err == nil {
+                if relative, err := filepath.Rel(cwd, candidate); err == nil {
+                    return relative
+                }
+            }
+            return candidate
+        }
+        if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+            return fallback
+        }
+        parent := filepath.Dir(dir)
+        if parent == dir {
+            return fallback
+        }
+        dir = parent
+    }
+}
+
+// parseRoots returns the roots of -roots arguments, each with its
+// discovered configuration
+func parseRoots(args []string, fallback string) ([]projectRoot, error) {
+    labels := make(map[string]bool)
+    var roots []projectRoot
+    for _, arg := range args {
+        label, path := parseRoot(arg)
+        if labels[label] {
+            return nil, fmt.Errorf("two roots are labeled %q", label)
+        }
+        labels[label] = true
+        roots = append(roots, projectRoot{label: label, paths: []string{path}, config: discoverConfig(path, fallback)})
+    }
+    return roots, nil
+}
+
+// loadRootAnalyzers creates the analyzer of each root. Roots sharing a
+// configuration share its analyzer, and primary, the -config one, serves
+// the roots without their own; all of them use primary's embedder.
+func loadRootAnalyzers(roots []projectRoot, fallback string, primary *Analyzer) error {
+    analyzers := map[string]*Analyzer{fallback: primary}
+    for i := range roots {
+        root := &roots[i]
+        analyzer, ok := analyzers[root.config]
+        if !ok {
+            var err error
+            if analyzer, err = NewAnalyzer(root.config); err != nil {
+                return fmt.Errorf("root %s: %w", root.label, err)
+            }
+            analyzer.embedder = primary.embedder
+            analyzers[root.config] = analyzer
+        }
+        root.analyzer = analyzer
+    }
+    return nil
+}
+
+// rootPaths returns the paths a run analyzes: those of its roots, or args
+// when it has none
+func rootPaths(roots []projectRoot, args []string) []string {
+    if roots == nil {
+        return args
+    }
+    var paths []string
+    for _, root := range roots {
+        paths = append(paths, root.paths...)
+    }
+    return paths
+}
+
+// show returns how a path under the root is reported
+func (r projectRoot) show(path string) string {
+    if r.base == "" {
+        return path
+    }
+    return filepath.ToSlash(rebasePath(path, r.base, r.shown))
+}
+
+// analyzeRoots analyzes each root with its analyzer and labels its issues
+// with the root, along with the input of the root that couldn't be read.
+// The cross-file checks see one root at a time. It returns the issues and
+// the files analyzed.
+func analyzeRoots(roots []projectRoot, walk WalkOptions, limits FileLimits, corpusOptions CorpusOptions) ([]Issue, []string) {
+    var allIssues []Issue
+    var files []string
+    for _, root := range roots {
+        // Which files are documents, and how they're parsed, is the
+        // root's configuration's to say
+        walk := walk
+        walk.Formats = root.analyzer.config.Formats
+        issues := analyzePaths(root.analyzer, root.paths, walk, limits, corpusOptions)
+        issues = append(issues, failures.drain()...)
+        for i := range issues {
+            issues[i].Root = root.label
+            if root.base != "" {
+                issues[i].File = root.show(issues[i].File)
+                issues[i].Message = strings.ReplaceAll(issues[i].Message, root.base, root.shown)
+                issues[i].Suggestion = strings.ReplaceAll(issues[i].Suggestion, root.base, root.shown)
+            }
+        }
+        allIssues = append(allIssues, issues...)
+
+        for _, path := range root.paths {
+            found, _ := collectFiles(path, walk)
+            files = append(files, found...)
+        }
+    }
+    return allIssues, files
+}
+
+// linkRootDocs sets the DocsURL of each issue from the DocsBaseURL of its
+// root's configuration, or of primary's for issues outside the roots
+func linkRootDocs(roots []projectRoot, primary *Analyzer, issues []Issue) {
+    configs := make(map[string]*Config)
+    for _, root := range roots {
+        configs[root.label] = root.analyzer.config
+    }
+    for i := range issues {
+        config, ok := configs[issues[i].Root]
+        if !ok {
+            config = primary.config
+        }
+        issues[i].DocsURL = config.ruleDocsURL(issues[i].Rule)
+    }
+}
+
+// byRoot groups the issues by the analyzer of their root, keeping their
+// order, with primary's taking those outside the roots
+func byRoot(roots []projectRoot, primary *Analyzer, issues []Issue) map[*Analyzer][]Issue {
+    analyzers := make(map[string]*Analyzer)
+    for _, root := range roots {
+        analyzers[root.label] = root.analyzer
+    }
+    groups := make(map[*Analyzer][]Issue)
+    for _, issue := range issues {
+        analyzer, ok := analyzers[issue.Root]
+        if !ok {
+            analyzer = primary
+        }
+        groups[analyzer] = append(groups[analyzer], issue)
+    }
+    return groups
+}
+
+// rootsFail reports whether the issues fail the run, each root's by the
+// severities of its own configuration, so a root can loosen what fails
+// as well as tighten it
+func rootsFail(roots []projectRoot, primary *Analyzer, issues []Issue) bool {
+    for analyzer, issues := range byRoot(roots, primary, issues) {
+        if analyzer.severities.fails(issues) {
+            return true
+        }
+    }
+    return false
+}
+
+// capRoots applies the caps to the issues, ranking the severities a cap
+// omits from a root's files by the root's configuration
+func capRoots(roots []projectRoot, primary *Analyzer, caps IssueCaps, issues []Issue) []Issue {
+    var capped []Issue
+    for analyzer, issues := range byRoot(roots, primary, issues) {
+        caps.Severities = analyzer.severities
+        capped = append(capped, caps.apply(issues)...)
+    }
+    return capped
+}
+
+// printRoots lists the configuration each root is analyzed with
+func printRoots(roots []projectRoot) {
+    for _, root := range roots {
+        config := root.config
+        if abs, err := filepath.Abs(config); err == nil && root.base != "" && root.show(abs) != abs {
+            config = root.show(abs)
+        }
+        if config == "" {
+            config = "defaults"
+        }
+        var paths []string
+        for _, path := range root.paths {
+            paths = append(paths, root.show(path))
+        }
+        fmt.Fprintf(os.Stderr, "Root %s: %s with %s\n", root.label, strings.Join(paths, ", "), config)
+    }
+}
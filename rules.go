@@ -0,0 +1,165 @@
+// Pattern rule compilation
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// compiledRule is a Rule with its patterns compiled once by NewAnalyzer, so
+// analysis only reads it
+type compiledRule struct {
+    Rule
+    pattern    *regexp.Regexp
+    exceptions []*regexp.Regexp
+    conditions *Condition
+}
+
+// validate rejects rules that couldn't run as written, given the
+// configuration's severities
+func (r Rule) validate(severities severityPolicy) error {
+    if !severities.allows(r.Severity) {
+        return fmt.Errorf("invalid severity %q (want %s)", r.Severity, severities.names())
+    }
+    if _, err := regexp.Compile(r.pattern()); err != nil {
+        return fmt.Errorf("invalid pattern: %w", err)
+    }
+    if r.Multiline && r.Scope == "sentence" {
+        return fmt.Errorf("Multiline rules match the whole document, so they can't have Scope sentence")
+    }
+    if r.Version < 0 {
+        return fmt.Errorf("invalid Version %d (want 1 or more)", r.Version)
+    }
+    if err := validatePageTypes(r.PageTypes); err != nil {
+        return err
+    }
+    if r.Fix != nil && r.Fix.Safety != "" && r.Fix.Safety != "safe" && r.Fix.Safety != "review" {
+        return fmt.Errorf("invalid fix safety %q (want safe or review)", r.Fix.Safety)
+    }
+    if r.Conditions != nil {
+        if err := r.Conditions.validate(); err != nil {
+            return err
+        }
+    }
+    for _, exception := range r.Exceptions {
+        if _, err := exceptionRegex(exception); err != nil {
+            return fmt.Errorf("invalid exception %q: %w", exception, err)
+        }
+    }
+    return nil
+}
+
+// pattern returns the expression the rule compiles: Multiline patterns
+// match ^ and $ at line boundaries
+func (r Rule) pattern() string {
+    if r.Multiline {
+        return "(?m)" + r.Pattern
+    }
+    return r.Pattern
+}
+
+// location returns the file and line that define the rule, for messages
+func (r Rule) location() string {
+    if r.line > 0 {
+        return fmt.Sprintf("%s:%d", r.source, r.line)
+    }
+    return r.source
+}
+
+// checkRules returns the rules that are valid, skipping the others with an
+// invalid-rule failure at the rule's definition. It also returns an error
+// for each rule skipped, for -strict-config.
+func checkRules(rules []Rule, severities severityPolicy) ([]Rule, []error) {
+    var valid []Rule
+    var invalid []error
+    for _, rule := range rules {
+        if err := rule.validate(severities); err != nil {
+            reportFailure(invalidRuleRule, rule.source, rule.line, "skipping rule %s: %v", rule.Name, err)
+            invalid = append(invalid, fmt.Errorf("%s: rule %s: %w", rule.location(), rule.Name, err))
+            continue
+        }
+        valid = append(valid, rule)
+    }
+    return valid, invalid
+}
+
+// setRuleLines records the line of each entry of the top-level Rules list
+// of a YAML file in rules, the rules decoded from it
+func setRuleLines(rules []Rule, data []byte) {
+    var doc yaml.Node
+    if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 {
+        return
+    }
+    root := doc.Content[0]
+    for i := 0; i+1 < len(root.Content); i += 2 {
+        if root.Content[i].Value != "Rules" {
+            continue
+        }
+        for j, entry := range root.Content[i+1].Content {
+            if j < len(rules) {
+                rules[j].line = entry.Line
+            }
+        }
+    }
+}
+
+// strictRules returns an error listing the rules NewAnalyzer skipped as
+// invalid, if any
+func (a *Analyzer) strictRules() error {
+    if len(a.invalidRules) == 0 {
+        return nil
+    }
+    messages := make([]string, len(a.invalidRules))
+    for i, err := range a.invalidRules {
+        messages[i] = err.Error()
+    }
+    return fmt.Errorf("-strict-config: %d invalid rule(s):\n  %s", len(messages), strings.Join(messages, "\n  "))
+}
+
+// compileRules compiles the patterns of every rule, which checkRules has
+// validated
+func compileRules(rules []Rule) ([]compiledRule, error) {
+    compiled := make([]compiledRule, 0, len(rules))
+    for _, rule := range rules {
+        pattern, err := regexp.Compile(rule.pattern())
+        if err != nil {
+            return nil, fmt.Errorf("rule %s: invalid pattern: %w", rule.Name, err)
+        }
+
+        c := compiledRule{Rule: rule, pattern: pattern}
+        for _, exception := range rule.Exceptions {
+            regex, err := exceptionRegex(exception)
+            if err != nil {
+                return nil, fmt.Errorf("rule %s: invalid exception %q: %w", rule.Name, exception, err)
+            }
+            c.exceptions = append(c.exceptions, regex)
+        }
+        if rule.Conditions != nil {
+            conditions, err := rule.Conditions.compile()
+            if err != nil {
+                return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+            }
+            c.conditions = &conditions
+        }
+
+        compiled = append(compiled, c)
+    }
+    return compiled, nil
+}
+
+// excepted reports whether an exception occurs in line overlapping the
+// match at [start, end)
+func (r compiledRule) excepted(line string, start, end int) bool {
+    for _, regex := range r.exceptions {
+        for _, span := range regex.FindAllStringIndex(line, -1) {
+            if span[0] < end && start < span[1] {
+                return true
+            }
+        }
+    }
+    return false
+}
@@ -0,0 +1,174 @@
+// Scaffolding for custom rules: rules new
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+
+    "gopkg.in/yaml.v3"
+)
+
+// ruleNameRegex matches the names rules new accepts: lowercase words
+// joined by hyphens, as the built-in rules are named
+var ruleNameRegex = regexp.MustCompile(`^[a-z][a-z0-9]*(?:-[a-z0-9]+)*$`)
+
+// ruleFixtureDir is the directory of StylesPath holding rule fixtures,
+// which isn't a language directory, so its files aren't loaded as rules
+const ruleFixtureDir = "testdata"
+
+// ruleTemplate is the rule pack file rules new writes, with each field
+// explained. Its arguments are the name, language, severity and the
+// command checking the rule against its fixture.
+const ruleTemplate = `# Rule pack for %[1]s, loaded from StylesPath/%[2]s/ for documents in that
+# language. Edit the fields, then check the rule against its fixture:
+#
+#   %[4]s
+#
+# The lines under "Reported" should each get a [%[1]s] issue and the lines
+# under "Not reported" none.
+Rules:
+  - Name: "%[1]s"
+    # What the rule finds, shown by the rules subcommand and as the message
+    # of each issue unless Message is set
+    Description: "Describe the convention the rule enforces"
+    # RE2 regular expression matched against each line of prose; (?i)
+    # ignores case and \b marks a word boundary
+    Pattern: '(?i)\bexample phrase\b'
+    # error, warning or suggestion, or a level from SeverityLevels
+    Severity: "%[3]s"
+    Type: "suggest"
+    # What to write instead. A template: ${match} is the matched text, $1
+    # the first group, ${product} and ${heading} the page's product and
+    # heading. Replacement: suggests a replacement for the match instead.
+    Suggestion: "Replace '${match}' with wording that stands on its own"
+    # Optional settings:
+    # Scope: "sentence"           # also "body" or "admonition"
+    # Exceptions:                 # literal text, or /regex/, that excuses a match
+    #   - "example phrases"
+    # PageTypes: ["task"]         # conceptual, task, reference or troubleshooting
+    # Fix:                        # rewrite -fix applies to each match
+    #   Replace: "replacement"
+    #   Safety: "review"          # or "safe" when it can't change the meaning
+    # Version: 1                  # raise when the rule's matches change
+`
+
+// ruleFixtureTemplate is the fixture rules new writes. Its arguments are
+// the name and the rule pack file.
+const ruleFixtureTemplate = `# Fixture for %[1]s
+
+Examples of what the %[1]s rule in %[2]s reports and leaves alone. Replace them with real sentences from your docs when you write the rule's pattern.
+
+## Reported
+
+- A sentence with the example phrase in it.
+
+## Not reported
+
+- A sentence the rule should leave alone, such as a near miss or an exception.
+`
+
+// runRulesNew implements rules new, which scaffolds a custom rule: a rule
+// pack file in StylesPath with the rule's fields explained, and a fixture
+// of text it should and shouldn't report
+func runRulesNew(args []string) int {
+    flags := flag.NewFlagSet("rules new", flag.ExitOnError)
+    configPath := flags.String("config", "", "Path to configuration file, whose StylesPath receives the rule")
+    language := flags.String("lang", "en", "Language of the documents the rule applies to, and so the StylesPath directory it's written to")
+    severity := flags.String("severity", "warning", "Severity of the rule's issues")
+    force := flags.Bool("force", false, "Overwrite existing files")
+    flags.Parse(args)
+
+    if flags.NArg() != 1 {
+        fmt.Fprintf(os.Stderr, "Usage: %s rules new [options] <rule-name>\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+    name := flags.Arg(0)
+    if !ruleNameRegex.MatchString(name) {
+        fmt.Fprintf(os.Stderr, "Error: invalid rule name %q (want lowercase words joined by hyphens, such as my-rule)\n", name)
+        return 1
+    }
+    if !languageCodeRegex.MatchString(*language) {
+        fmt.Fprintf(os.Stderr, "Error: invalid -lang %q (want a language code such as en or pt-BR)\n", *language)
+        return 1
+    }
+
+    config, err := loadConfig(*configPath)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: failed to load config: %v\n", err)
+        return 1
+    }
+    switch {
+    case config.StylesPath == "":
+        fmt.Fprintf(os.Stderr, "Error: the configuration has no StylesPath to write the rule to\n")
+        return 1
+    case isRemote(config.StylesPath):
+        fmt.Fprintf(os.Stderr, "Error: StylesPath %s is remote; add the rule to its repository instead\n", config.StylesPath)
+        return 1
+    }
+    if severities := config.severities(); !severities.allows(*severity) {
+        fmt.Fprintf(os.Stderr, "Error: invalid -severity %q (want %s)\n", *severity, severities.names())
+        return 1
+    }
+
+    analyzer, err := NewAnalyzer(*configPath)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error creating analyzer: %v\n", err)
+        return 1
+    }
+    for _, info := range analyzer.ruleInfos() {
+        if info.Name == name {
+            fmt.Fprintf(os.Stderr, "Error: the configuration already has a rule or check named %s\n", name)
+            return 1
+        }
+    }
+
+    rulePath := filepath.Join(config.StylesPath, *language, name+".yml")
+    fixturePath := filepath.Join(config.StylesPath, ruleFixtureDir, name+".md")
+    check := "ai-doc-optimizer analyze -output short "
+    if *configPath != "" {
+        check += "-config " + *configPath + " "
+    }
+    check += filepath.ToSlash(fixturePath)
+    files := []struct{ path, content string }{
+        {rulePath, fmt.Sprintf(ruleTemplate, name, *language, *severity, check)},
+        {fixturePath, fmt.Sprintf(ruleFixtureTemplate, name, filepath.ToSlash(rulePath))},
+    }
+    for _, file := range files {
+        if _, err := os.Stat(file.path); err == nil && !*force {
+            fmt.Fprintf(os.Stderr, "Error: %s already exists (use -force to overwrite)\n", file.path)
+            return 1
+        }
+    }
+
+    // The template has to stay a valid rule, or the first run would skip it
+    var pack struct {
+        Rules []Rule `yaml:"Rules"`
+    }
+    if err := yaml.Unmarshal([]byte(files[0].content), &pack); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: failed to generate rule %s: %v\n", name, err)
+        return 1
+    }
+    if err := pack.Rules[0].validate(config.severities()); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: failed to generate rule %s: %v\n", name, err)
+        return 1
+    }
+
+    for _, file := range files {
+        if err := os.MkdirAll(filepath.Dir(file.path), 0o755); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return 1
+        }
+        if err := os.WriteFile(file.path, []byte(file.content), 0o644); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return 1
+        }
+        fmt.Printf("Wrote %s\n", file.path)
+    }
+    fmt.Printf("Edit the rule in %s, then check it against the fixture with:\n  %s\n", rulePath, check)
+    return 0
+}
@@ -0,0 +1,111 @@
+// Rule versions, deprecations and renames, so rules can change between
+// releases without silently changing results
+
+package main
+
+import (
+    "fmt"
+    "os"
+)
+
+// version returns the rule's Version, 1 if unset
+func (r Rule) version() int {
+    return max(r.Version, 1)
+}
+
+// checkRuleVersions applies MinRuleVersion to rules. A rule older than
+// the version the configuration names is skipped with an invalid-rule
+// failure, as the rule pack defining it is out of date; one that changed
+// since prints a warning with its migration hint. Deprecated rules warn
+// too. It returns the rules to run, and an error for each rule skipped,
+// for -strict-config.
+func (c *Config) checkRuleVersions(rules []Rule) ([]Rule, []error) {
+    c.renameRules(rules)
+
+    var current []Rule
+    var outdated []error
+    for _, rule := range rules {
+        min, pinned := c.MinRuleVersion[rule.Name]
+        switch {
+        case pinned && rule.version() < min:
+            err := fmt.Errorf("version %d is older than MinRuleVersion %d; update the rule pack that defines it", rule.version(), min)
+            reportFailure(invalidRuleRule, rule.source, rule.line, "skipping rule %s: %v", rule.Name, err)
+            outdated = append(outdated, fmt.Errorf("%s: rule %s: %w", rule.location(), rule.Name, err))
+            continue
+        case pinned && rule.version() > min:
+            change := ""
+            if rule.Changed != "" {
+                change = ": " + rule.Changed
+            }
+            warnRule(rule, "changed in version %d, after MinRuleVersion %d%s. Review its issues, then set MinRuleVersion to %d", rule.version(), min, change, rule.version())
+        }
+        if rule.Deprecated != "" {
+            warnRule(rule, "is deprecated: %s", rule.Deprecated)
+        }
+        current = append(current, rule)
+    }
+    return current, outdated
+}
+
+// renameRules rewrites the settings that refer to a rule by one of its
+// Aliases, the names it had before, to its current name, with a warning
+func (c *Config) renameRules(rules []Rule) {
+    renamed := make(map[string]string)
+    for _, rule := range rules {
+        for _, alias := range rule.Aliases {
+            renamed[alias] = rule.Name
+        }
+    }
+    if len(renamed) == 0 {
+        return
+    }
+
+    rename := func(name, setting string) string {
+        current, ok := renamed[name]
+        if !ok {
+            return name
+        }
+        fmt.Fprintf(os.Stderr, "Warning: %s refers to rule %s by its former name %s; rename it\n", setting, current, name)
+        return current
+    }
+    c.Severities = renameKeys(c.Severities, "Severities", rename)
+    c.PageTypes = renameKeys(c.PageTypes, "PageTypes", rename)
+    c.MinRuleVersion = renameKeys(c.MinRuleVersion, "MinRuleVersion", rename)
+    c.Remediation.Minutes = renameKeys(c.Remediation.Minutes, "Remediation.Minutes", rename)
+    for i := range c.Overrides {
+        override := &c.Overrides[i]
+        for j, name := range override.Disable {
+            override.Disable[j] = rename(name, fmt.Sprintf("Overrides[%d].Disable", i))
+        }
+        for j, name := range override.Enable {
+            override.Enable[j] = rename(name, fmt.Sprintf("Overrides[%d].Enable", i))
+        }
+        override.Severity = renameKeys(override.Severity, fmt.Sprintf("Overrides[%d].Severity", i), rename)
+    }
+}
+
+// renameKeys returns m with its keys renamed. A setting under a rule's
+// current name wins over one under its former name.
+func renameKeys[V any](m map[string]V, setting string, rename func(name, setting string) string) map[string]V {
+    if m == nil {
+        return nil
+    }
+    renamed := make(map[string]V, len(m))
+    for _, name := range sortedKeys(m) {
+        current := rename(name, setting)
+        if _, ok := m[current]; ok && current != name {
+            continue
+        }
+        renamed[current] = m[name]
+    }
+    return renamed
+}
+
+// warnRule prints a warning about a rule, at its definition if known
+func warnRule(rule Rule, format string, args ...any) {
+    where := ""
+    if location := rule.location(); location != "" {
+        where = location + ": "
+    }
+    fmt.Fprintf(os.Stderr, "Warning: %srule %s %s\n", where, rule.Name, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,102 @@
+// Run metadata for reproducible, auditable reports
+
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "time"
+
+    "gopkg.in/yaml.v3"
+)
+
+// toolVersion is the version of ai-doc-optimizer reported in outputs
+const toolVersion = "1.0.0"
+
+// currentRun describes the run whose issues are being written, or is nil
+// outside a run, as in serve. The JSON output, and so every external
+// formatter, includes it.
+var currentRun *RunMetadata
+
+// RunMetadata records what produced a report, so a result can be traced
+// back to the tool, configuration and rules that made it
+type RunMetadata struct {
+    Tool          string            `json:"tool"`
+    ToolVersion   string            `json:"tool_version"`
+    Command       string            `json:"command"`
+    Paths         []string          `json:"paths,omitempty"`
+    ConfigHash    string            `json:"config_hash"`
+    Rules         map[string]string `json:"rules"` // rule or check name -> version
+    StartedAt     time.Time         `json:"started_at"`
+    FinishedAt    time.Time         `json:"finished_at"`
+    FilesAnalyzed int               `json:"files_analyzed"`
+    FilesSkipped  int               `json:"files_skipped"`
+    Skipped       map[string]int    `json:"skipped,omitempty"` // paths not analyzed, by reason: too-large, binary, timeout, permission-denied, not-found or unreadable
+    Severities    severityPolicy    `json:"severities"`        // what each severity means for the exit status, SARIF and code quality
+}
+
+// newRunMetadata starts the metadata of a run of command with an analyzer.
+// The config hash covers the effective configuration, after defaults and
+// packs are applied. A pattern rule's version is the hash of its
+// definition with its effective severity, so editing a rule changes it;
+// built-in checks carry the tool version.
+func newRunMetadata(command string, analyzer *Analyzer, paths []string) *RunMetadata {
+    run := &RunMetadata{
+        Tool:        "ai-doc-optimizer",
+        ToolVersion: toolVersion,
+        Command:     command,
+        Paths:       paths,
+        ConfigHash:  yamlHash(analyzer.config),
+        Rules:       make(map[string]string),
+        StartedAt:   time.Now().UTC(),
+        Severities:  analyzer.severities,
+    }
+    for _, rule := range analyzer.rules {
+        if severity, ok := analyzer.config.Severities[rule.Name]; ok {
+            rule.Severity = severity
+        }
+        run.Rules[rule.Name] = yamlHash(rule)[:16]
+    }
+    for _, check := range analyzer.checks("", "en") {
+        run.Rules[check.name] = toolVersion
+    }
+    return run
+}
+
+// countFiles records the files a run analyzed: those of files without a
+// file-skipped or read-failure issue. Every file a file-skipped issue
+// reports counts as skipped, including those a fetch left out of files.
+func (r *RunMetadata) countFiles(files []string, issues []Issue) {
+    skipped, unread := make(map[string]bool), make(map[string]bool)
+    for _, issue := range issues {
+        switch issue.Rule {
+        case "file-skipped":
+            skipped[issue.File] = true
+        case readFailureRule:
+            unread[issue.File] = true
+        }
+    }
+    r.FilesSkipped = len(skipped)
+    r.FilesAnalyzed = 0
+    for _, file := range files {
+        if !skipped[file] && !unread[file] {
+            r.FilesAnalyzed++
+        }
+    }
+}
+
+// finish records the end of the run, when its output is written
+func (r *RunMetadata) finish() {
+    r.FinishedAt = time.Now().UTC()
+}
+
+// yamlHash returns the SHA-256 of a value's YAML encoding, which is stable
+// across runs since map keys are sorted
+func yamlHash(value any) string {
+    data, err := yaml.Marshal(value)
+    if err != nil {
+        return ""
+    }
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,125 @@
+// Safety framing for destructive example commands
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var (
+    pipeToShellRegex = regexp.MustCompile(`\b(?:curl|wget)\b[^|]*\|\s*(?:sudo\s+)?(?:ba|z)?sh\b`)
+    riskFramingRegex = regexp.MustCompile(`(?i)\b(?:warning|caution|careful|danger(?:ous)?|destructive|irreversibl[ey]|permanently|cannot be undone|can't be undone|data loss|back ?up|deletes?|removes?|erases?|overwrites?|wipes?|review the script|inspect the script)\b`)
+)
+
+// destructiveCommands are example commands that destroy data or state
+var destructiveCommands = []struct {
+    pattern     *regexp.Regexp
+    description string
+}{
+    {regexp.MustCompile(`\brm\s+(?:-\w*r\w*f\w*|-\w*f\w*r\w*|-r\s+-f|-f\s+-r|--recursive\s+--force|--force\s+--recursive)\b`), "recursively force-deletes files"},
+    {regexp.MustCompile(`(?i)\bDROP\s+(?:TABLE|DATABASE|SCHEMA)\b`), "drops data"},
+    {regexp.MustCompile(`(?i)\bTRUNCATE\s+(?:TABLE\s+)?\w`), "deletes all rows"},
+    {regexp.MustCompile(`(?i)\bDELETE\s+FROM\s+[\w."]+\s*(?:;|$)`), "deletes all rows"},
+    {regexp.MustCompile(`\bgit\s+push\b.*(?:--force\b|\s-f\b)`), "rewrites remote history"},
+    {regexp.MustCompile(`\bgit\s+(?:reset\s+--hard|clean\s+-\w*f)`), "discards local changes"},
+    {regexp.MustCompile(`\b(?:mkfs(?:\.\w+)?|dd\s+.*\bof=/dev/)`), "overwrites a disk"},
+    {regexp.MustCompile(`\bchmod\s+(?:-R\s+)?777\b`), "makes files world-writable"},
+    {regexp.MustCompile(`\bkubectl\s+delete\b|\bterraform\s+destroy\b|\bdocker\s+system\s+prune\b`), "deletes infrastructure"},
+    {regexp.MustCompile(`\s--force\b|\s--yes-i-really-mean-it\b`), "skips safety prompts"},
+}
+
+// unsafeCommand returns a description of the danger in a command line, and
+// the rule it breaks
+func unsafeCommand(line string) (string, string, bool) {
+    if pipeToShellRegex.MatchString(line) {
+        return "pipe-to-shell", "pipes a downloaded script straight into a shell", true
+    }
+    for _, command := range destructiveCommands {
+        if command.pattern.MatchString(line) {
+            return "destructive-command", command.description, true
+        }
+    }
+    return "", "", false
+}
+
+// analyzeCommandSafety flags destructive or pipe-to-shell example commands
+// that no nearby text warns about, so assistants that surface the command
+// alone don't drop the caveat
+func (a *Analyzer) analyzeCommandSafety(doc *Document) []Issue {
+    var issues []Issue
+    report := func(lineNum int, text, rule, description string) {
+        suggestion := "Add a sentence before the command explaining what it destroys and how to back up first"
+        if rule == "pipe-to-shell" {
+            suggestion = "Tell readers to download and review the script before running it, or show the two steps separately"
+        }
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         lineNum,
+            Column:       1,
+            Rule:         rule,
+            Message:      fmt.Sprintf("Example command %s without a warning", description),
+            Severity:     "warning",
+            Suggestion:   suggestion,
+            OriginalText: text,
+        })
+    }
+
+    for _, block := range doc.CodeBlocks {
+        if !shellLanguages[block.Lang] && block.Lang != "sql" && block.Lang != "psql" {
+            continue
+        }
+        if doc.InAdmonition(block.StartLine) || doc.isFramed(block.StartLine) {
+            continue
+        }
+        for offset, line := range strings.Split(doc.Code(block), "\n") {
+            if strings.HasPrefix(strings.TrimSpace(line), "#") && !shellPromptRegex.MatchString(strings.TrimSpace(line)) {
+                continue
+            }
+            if rule, description, ok := unsafeCommand(line); ok {
+                report(block.StartLine+offset+1, strings.TrimSpace(line), rule, description)
+                break
+            }
+        }
+    }
+
+    for i, line := range doc.Masked {
+        if doc.Fenced[i] || doc.InAdmonition(i+1) || riskFramingRegex.MatchString(line) {
+            continue
+        }
+        for _, match := range codeSpanRegex.FindAllStringSubmatch(line, -1) {
+            if rule, description, ok := unsafeCommand(match[1]); ok && !doc.isFramed(i+1) {
+                report(i+1, match[1], rule, description)
+                break
+            }
+        }
+    }
+
+    return issues
+}
+
+// isFramed reports whether the paragraph ending just before lineNum, or the
+// line itself, carries warning language
+func (d *Document) isFramed(lineNum int) bool {
+    seenText := false
+    for i := lineNum - 1; i >= d.BodyStart-1 && i >= 0; i-- {
+        line := d.Lines[i]
+        if strings.TrimSpace(line) == "" {
+            if seenText {
+                break
+            }
+            continue
+        }
+        if i < lineNum-1 && (d.Fenced[i] || atxHeadingRegex.MatchString(strings.TrimSpace(line))) {
+            break
+        }
+        if riskFramingRegex.MatchString(line) {
+            return true
+        }
+        if i < lineNum-1 {
+            seenText = true
+        }
+    }
+    return false
+}
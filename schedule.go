@@ -0,0 +1,311 @@
+// Scheduled runs for serve, which turn it into a continuous monitor
+
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// ScheduledJob is a run serve starts on a schedule, such as a nightly
+// crawl of the published site
+type ScheduledJob struct {
+    Name  string   `yaml:"Name"`
+    Every string   `yaml:"Every,omitempty"` // interval between runs, such as 6h or 30m
+    Cron  string   `yaml:"Cron,omitempty"`  // minute, hour, day of month, month and day of week, in local time, as in crontab
+    Args  []string `yaml:"Args"`            // command and its arguments, as on the command line
+}
+
+// scheduledCommands are the commands a job can run: those that record
+// their runs with -db
+var scheduledCommands = map[string]bool{
+    "analyze": true, "confluence": true, "crawl": true, "sitemap": true, "helpcenter": true,
+}
+
+// schedule returns when a job next runs after a time
+type schedule interface {
+    next(after time.Time) time.Time
+}
+
+// interval runs a job at a fixed interval
+type interval time.Duration
+
+func (d interval) next(after time.Time) time.Time {
+    return after.Add(time.Duration(d))
+}
+
+// schedule parses the job's Every or Cron
+func (j ScheduledJob) schedule() (schedule, error) {
+    switch {
+    case j.Every != "" && j.Cron != "":
+        return nil, fmt.Errorf("set Every or Cron, not both")
+    case j.Every != "":
+        every, err := time.ParseDuration(j.Every)
+        if err != nil {
+            return nil, fmt.Errorf("invalid Every: %w", err)
+        }
+        if every < time.Minute {
+            return nil, fmt.Errorf("Every %s is shorter than a minute", j.Every)
+        }
+        return interval(every), nil
+    case j.Cron != "":
+        spec, err := parseCron(j.Cron)
+        if err != nil {
+            return nil, fmt.Errorf("invalid Cron %q: %w", j.Cron, err)
+        }
+        if spec.next(time.Now()).IsZero() {
+            return nil, fmt.Errorf("Cron %q never matches", j.Cron)
+        }
+        return spec, nil
+    }
+    return nil, fmt.Errorf("set Every or Cron")
+}
+
+// validate rejects jobs serve couldn't run
+func (j ScheduledJob) validate() error {
+    if j.Name == "" {
+        return fmt.Errorf("scheduled job has no Name")
+    }
+    if _, err := j.schedule(); err != nil {
+        return fmt.Errorf("scheduled job %s: %w", j.Name, err)
+    }
+    if len(j.Args) == 0 || !scheduledCommands[j.Args[0]] {
+        return fmt.Errorf("scheduled job %s: Args must start with analyze, crawl, sitemap, confluence or helpcenter", j.Name)
+    }
+    return nil
+}
+
+// cronSpec is a parsed crontab schedule: the values each field allows
+type cronSpec struct {
+    minute, hour, day, month, weekday uint64
+    // Days match by day of month or day of week when both are restricted,
+    // as in cron
+    anyDay, anyWeekday bool
+}
+
+// parseCron parses five crontab fields. Each is *, a value, a range such
+// as 1-5, or a comma-separated list of those, each optionally with a step
+// such as */15. Day of week runs from 0 (Sunday) to 6, with 7 also Sunday.
+func parseCron(expression string) (cronSpec, error) {
+    fields := strings.Fields(expression)
+    if len(fields) != 5 {
+        return cronSpec{}, fmt.Errorf("want 5 fields, got %d", len(fields))
+    }
+    var spec cronSpec
+    bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+    sets := [5]*uint64{&spec.minute, &spec.hour, &spec.day, &spec.month, &spec.weekday}
+    for i, field := range fields {
+        set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+        if err != nil {
+            return cronSpec{}, fmt.Errorf("field %d: %w", i+1, err)
+        }
+        *sets[i] = set
+    }
+    if spec.weekday&(1<<7) != 0 {
+        spec.weekday |= 1
+    }
+    spec.anyDay, spec.anyWeekday = fields[2] == "*", fields[4] == "*"
+    return spec, nil
+}
+
+// parseCronField returns the set of values a field allows, as bits
+func parseCronField(field string, low, high int) (uint64, error) {
+    var set uint64
+    for _, item := range strings.Split(field, ",") {
+        step := 1
+        if rest, stepText, ok := strings.Cut(item, "/"); ok {
+            n, err := strconv.Atoi(stepText)
+            if err != nil || n < 1 {
+                return 0, fmt.Errorf("invalid step %q", stepText)
+            }
+            item, step = rest, n
+        }
+        start, end := low, high
+        if item != "*" {
+            first, last, isRange := strings.Cut(item, "-")
+            var err error
+            if start, err = strconv.Atoi(first); err != nil {
+                return 0, fmt.Errorf("invalid value %q", first)
+            }
+            end = start
+            if isRange {
+                if end, err = strconv.Atoi(last); err != nil {
+                    return 0, fmt.Errorf("invalid value %q", last)
+                }
+            } else if step > 1 {
+                end = high // 5/15 means from 5 on, every 15
+            }
+        }
+        if start < low || end > high || start > end {
+            return 0, fmt.Errorf("%q is outside %d-%d", item, low, high)
+        }
+        for v := start; v <= end; v += step {
+            set |= 1 << v
+        }
+    }
+    return set, nil
+}
+
+// next returns the first minute after a time that the schedule matches,
+// within five years, or the zero time
+func (s cronSpec) next(after time.Time) time.Time {
+    t := after.Truncate(time.Minute).Add(time.Minute)
+    for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+        switch {
+        case s.month&(1<<uint(t.Month())) == 0:
+            t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+        case !s.dayMatches(t):
+            t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+        case s.hour&(1<<uint(t.Hour())) == 0:
+            t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+        case s.minute&(1<<uint(t.Minute())) == 0:
+            t = t.Add(time.Minute)
+        default:
+            return t
+        }
+    }
+    return time.Time{}
+}
+
+func (s cronSpec) dayMatches(t time.Time) bool {
+    day := s.day&(1<<uint(t.Day())) != 0
+    weekday := s.weekday&(1<<uint(t.Weekday())) != 0
+    if !s.anyDay && !s.anyWeekday {
+        return day || weekday
+    }
+    return day && weekday
+}
+
+// JobStatus is what /schedule reports about a job
+type JobStatus struct {
+    Name         string     `json:"name"`
+    Args         []string   `json:"args"`
+    Running      bool       `json:"running"`
+    Next         time.Time  `json:"next"`
+    LastStart    *time.Time `json:"last_start,omitempty"`
+    LastDuration string     `json:"last_duration,omitempty"`
+    LastExit     *int       `json:"last_exit,omitempty"` // 0 without issues, 1 with issues or on failure
+}
+
+// scheduler runs the jobs of a configuration, each in its own goroutine.
+// A job's runs never overlap: an occurrence due while the job still runs
+// is skipped.
+type scheduler struct {
+    jobs       []ScheduledJob
+    configPath string // passed to each run, before the job's own arguments
+    dbPath     string // likewise
+
+    mu     sync.Mutex
+    status []JobStatus
+}
+
+func newScheduler(jobs []ScheduledJob, configPath, dbPath string) *scheduler {
+    s := &scheduler{jobs: jobs, configPath: configPath, dbPath: dbPath}
+    for _, job := range jobs {
+        s.status = append(s.status, JobStatus{Name: job.Name, Args: job.Args})
+    }
+    return s
+}
+
+// start starts running the jobs, Every jobs right away and Cron jobs at
+// their first match
+func (s *scheduler) start() {
+    for i := range s.jobs {
+        go s.loop(i)
+    }
+}
+
+func (s *scheduler) loop(i int) {
+    job := s.jobs[i]
+    sched, _ := job.schedule() // validated with the configuration
+    next := time.Now()
+    if _, ok := sched.(cronSpec); ok {
+        next = sched.next(next)
+    }
+    for {
+        s.update(i, func(status *JobStatus) { status.Next = next })
+        time.Sleep(time.Until(next))
+
+        start := time.Now()
+        s.update(i, func(status *JobStatus) { status.Running, status.LastStart = true, &start })
+        exit := s.run(job)
+        duration := time.Since(start).Round(time.Second)
+        s.update(i, func(status *JobStatus) {
+            status.Running, status.LastDuration, status.LastExit = false, duration.String(), &exit
+        })
+        fmt.Fprintf(os.Stderr, "Scheduled job %s finished in %s with exit status %d\n", job.Name, duration, exit)
+
+        next = sched.next(start)
+        for !next.After(time.Now()) {
+            next = sched.next(next)
+        }
+    }
+}
+
+func (s *scheduler) update(i int, change func(*JobStatus)) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    change(&s.status[i])
+}
+
+// statuses returns a snapshot of the jobs' status
+func (s *scheduler) statuses() []JobStatus {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return append([]JobStatus(nil), s.status...)
+}
+
+// run runs a job as a child process of the tool itself, so each run has
+// its own state and a failing run can't take the server down. Its report
+// is discarded, as the results database and webhook receive it; its
+// messages are logged with the job's name.
+func (s *scheduler) run(job ScheduledJob) int {
+    executable, err := os.Executable()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: scheduled job %s: %v\n", job.Name, err)
+        return 1
+    }
+    args := []string{job.Args[0]}
+    if s.configPath != "" {
+        args = append(args, "-config", s.configPath)
+    }
+    if s.dbPath != "" {
+        args = append(args, "-db", s.dbPath)
+    }
+    args = append(args, job.Args[1:]...)
+
+    cmd := exec.Command(executable, args...)
+    stderr, err := cmd.StderrPipe()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: scheduled job %s: %v\n", job.Name, err)
+        return 1
+    }
+    if err := cmd.Start(); err != nil {
+        fmt.Fprintf(os.Stderr, "Error: scheduled job %s: %v\n", job.Name, err)
+        return 1
+    }
+    logLines(stderr, "["+job.Name+"] ")
+    if err := cmd.Wait(); err != nil {
+        if exit, ok := err.(*exec.ExitError); ok {
+            return exit.ExitCode()
+        }
+        fmt.Fprintf(os.Stderr, "Error: scheduled job %s: %v\n", job.Name, err)
+        return 1
+    }
+    return 0
+}
+
+// logLines copies r to stderr line by line, each line prefixed
+func logLines(r io.Reader, prefix string) {
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        fmt.Fprintf(os.Stderr, "%s%s\n", prefix, scanner.Text())
+    }
+}
@@ -0,0 +1,350 @@
+// JSON Schema of the config file, for editors and load-time validation
+
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "reflect"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// jsonSchema is the subset of JSON Schema the config schema uses
+type jsonSchema struct {
+    Schema               string                 `json:"$schema,omitempty"`
+    Title                string                 `json:"title,omitempty"`
+    Ref                  string                 `json:"$ref,omitempty"`
+    Type                 string                 `json:"type,omitempty"`
+    Description          string                 `json:"description,omitempty"`
+    Enum                 []string               `json:"enum,omitempty"`
+    Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+    AdditionalProperties any                    `json:"additionalProperties,omitempty"` // false, or the *jsonSchema of every value
+    Items                *jsonSchema            `json:"items,omitempty"`
+    Defs                 map[string]*jsonSchema `json:"$defs,omitempty"`
+}
+
+// schemaEnums are the values a field accepts, by "Type.Field", for the
+// fields validate checks against a fixed list. For lists and maps they
+// constrain each item or value.
+var schemaEnums = map[string][]string{
+    "Config.ColumnUnit":         {"rune", "utf16", "byte"},
+    "Config.Overlaps":           overlapPolicies,
+    "Format.Parser":             documentFormats,
+    "Config.PageTypes":          pageTypeNames,
+    "Config.Packs":              sortedKeys(builtinRulePacks),
+    "Rule.PageTypes":            pageTypeNames,
+    "RuleFix.Safety":            {"safe", "review"},
+    "Condition.Where":           {"line", "before", "after", "near", "section"},
+    "Condition.InList":          {"ordered", "bullet", "any"},
+    "SeverityLevel.SARIF":       sarifLevels,
+    "SeverityLevel.CodeQuality": codeQualitySeverities,
+    "IncludesConfig.Syntaxes":   sortedKeys(includeSyntaxes),
+    "HeadingsConfig.Case":       headingCases,
+    "EmbeddingsConfig.Provider": sortedKeys(embeddingProviders),
+}
+
+// schemaDescriptions document fields in editors, by "Type.Field"
+var schemaDescriptions = map[string]string{
+    "Config.StylesPath":           "Directory of rule files, with localized rules in <lang> subdirectories, or an HTTPS or git URL",
+    "Config.Language":             "Default document language, en if unset",
+    "Config.MinWordCount":         "Words a lead paragraph needs",
+    "Config.MaxLinkDepth":         "Link depth from an entry page beyond which -link-graph reports a page as hard to reach, 4 by default",
+    "Config.MinDescriptionLength": "Shortest acceptable front matter description",
+    "Config.MinBoilerplateFiles":  "Files a paragraph must repeat in to count as boilerplate",
+    "Config.Formats":              "File formats by name, with their extensions and parser",
+    "Config.FrontMatter":          "Front matter fields documents must have",
+    "Config.SpellCheck":           "Hunspell dictionaries and a project word list",
+    "Config.OpenAPI":              "OpenAPI specs that API references are checked against",
+    "Config.CLI":                  "CLI reference that command examples are checked against",
+    "Config.ValidateSnippets":     "Code block languages to validate: json, yaml, shell, go",
+    "Config.Severities":           "Severity of any rule or check, by name",
+    "Config.PageTypes":            "Page types a rule or check runs on, by name",
+    "Config.Overrides":            "Rules to disable and severities to change for matching paths",
+    "Config.ColumnUnit":           "Unit of reported columns",
+    "Config.Overlaps":             "Which of the rule matches overlapping on a line to report: all of them, the most severe or the narrowest",
+    "Config.Formatters":           "External formatter commands, by -output name",
+    "Config.DocsBaseURL":          "URL of the pages explaining each rule, linked from every issue: the rule name is appended as a path segment, or replaces {rule}",
+    "Config.Embeddings":           "Embedding model for -semantic and -contradictions",
+    "Config.Jargon":               "Glossaries and known terms for the jargon check",
+    "Config.Cohesion":             "Paragraph topic cohesion check, off unless enabled",
+    "Config.Audience":             "Whether pages must declare their audience, and the audiences they can name",
+    "Config.ReleaseNotes":         "Components every changelog and release notes entry must name one of",
+    "Config.Headings":             "Heading case to normalize to and heading length limit, off unless set",
+    "Config.Remediation":          "Minutes each issue takes to resolve, for the effort estimate at the end of a run",
+    "Config.Webhook":              "Webhook to notify when a run breaches its error or score thresholds",
+    "Config.Schedule":             "Runs serve starts periodically, recording them with its -db",
+    "Config.Includes":             "Include directives to resolve before analysis",
+    "Config.Variables":            "Values of template variables, for measuring what readers see",
+    "Config.Nav":                  "mkdocs.yml, sidebars.js, nav.adoc or SUMMARY.md giving the reading order",
+    "Config.Packs":                "Built-in rule packs to enable",
+    "Config.Rules":                "Pattern rules, run on every matching line; the built-in ones if unset",
+    "Config.SeverityLevels":       "Severities beyond error, warning and suggestion, and changes to what those mean",
+    "Config.MinRuleVersion":       "Version of each rule the configuration was reviewed against: older rules are skipped, newer ones warn with what changed",
+    "Rule.Name":                   "Name reported with each issue, and used by Severities, PageTypes and Overrides",
+    "Rule.Description":            "Message reported with each issue",
+    "Rule.Message":                "Message template for each issue, replacing Description: ${match} is the match, $1 or ${name} its groups",
+    "Rule.Pattern":                "Go regular expression matched against each line",
+    "Rule.Replacement":            "Suggested replacement for each match, a template: ${match} is the match, $1 or ${name} its groups",
+    "Rule.Suggestion":             "Suggestion template, replacing the built-in one",
+    "Rule.Severity":               "Severity of the rule's issues",
+    "Rule.Type":                   "suggest, error or warning",
+    "Rule.Scope":                  "Text the rule runs on: every line by default, admonition or body lines, or each sentence of prose",
+    "Rule.Multiline":              "Match Pattern against the whole document instead of each line, with ^ and $ at line boundaries and (?s) letting . match newlines",
+    "Rule.Exceptions":             "Literal text, or /regex/, that suppresses an overlapping match",
+    "Rule.Conditions":             "Further constraints on the matched line",
+    "Rule.Languages":              "Document languages the rule runs on; the default Language if empty, * for all",
+    "Rule.PageTypes":              "Page types the rule runs on; all if empty",
+    "Rule.Fix":                    "Mechanical replacement for each match, applied by -fix",
+    "Rule.Version":                "Revision of the rule's behavior, 1 if unset; raise it when the rule's matches change",
+    "Rule.Changed":                "What changed in this Version, shown to configurations on an earlier one",
+    "Rule.Deprecated":             "Why the rule is going away and what to use instead, shown as a warning",
+    "Rule.Aliases":                "Former names of the rule, still accepted in Severities, PageTypes, Overrides and MinRuleVersion",
+    "SeverityLevel.Name":          "Severity name, for Severity, Severities and Overrides; error, warning or suggestion to change a built-in one",
+    "SeverityLevel.Rank":          "Orders severities: suggestion is 10, warning 20 and error 30; higher is more severe",
+    "SeverityLevel.Fails":         "Whether issues of this severity make the run exit with status 1, true by default",
+    "SeverityLevel.SARIF":         "SARIF result level of the severity's issues, warning by default",
+    "SeverityLevel.CodeQuality":   "GitLab code quality severity of the severity's issues, minor by default",
+    "RuleFix.Replace":             "Replacement text, a template as for Replacement",
+    "RuleFix.Safety":              "safe if the rewrite can't change meaning, else review (the default)",
+    "Condition.All":               "Conditions that must all hold",
+    "Condition.Any":               "Conditions of which one must hold",
+    "Condition.Not":               "Condition that must not hold",
+    "Condition.Pattern":           "Go regular expression to look for",
+    "Condition.Where":             "Where to look, relative to the matched line; line by default",
+    "Condition.Within":            "Line distance for before, after and near; 0 means unbounded",
+    "Condition.InList":            "Require the matched line to be in a list of this kind",
+}
+
+// configSchema returns the JSON Schema of the config file, generated from
+// the Config type so it can't drift from what loadConfig reads
+func configSchema() *jsonSchema {
+    defs := make(map[string]*jsonSchema)
+    schemaFor(reflect.TypeOf(Config{}), "", defs)
+    root := *defs["Config"]
+    delete(defs, "Config")
+    root.Schema = "https://json-schema.org/draft/2020-12/schema"
+    root.Title = "ai-doc-optimizer configuration"
+    root.Defs = defs
+    return &root
+}
+
+// schemaFor returns the schema of a Go type, adding the schema of each
+// struct type to defs and referring to it there. field is the
+// "Type.Field" the type belongs to, for enums.
+func schemaFor(t reflect.Type, field string, defs map[string]*jsonSchema) *jsonSchema {
+    switch t.Kind() {
+    case reflect.Pointer:
+        return schemaFor(t.Elem(), field, defs)
+    case reflect.Struct:
+        if _, ok := defs[t.Name()]; !ok {
+            def := &jsonSchema{Type: "object", Properties: make(map[string]*jsonSchema), AdditionalProperties: false}
+            defs[t.Name()] = def // before the fields, for recursive types
+            for i := 0; i < t.NumField(); i++ {
+                f := t.Field(i)
+                if !f.IsExported() {
+                    continue
+                }
+                name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+                if name == "" {
+                    name = strings.ToLower(f.Name)
+                }
+                key := t.Name() + "." + f.Name
+                property := schemaFor(f.Type, key, defs)
+                property.Description = schemaDescriptions[key]
+                def.Properties[name] = property
+            }
+        }
+        return &jsonSchema{Ref: "#/$defs/" + t.Name()}
+    case reflect.Slice:
+        return &jsonSchema{Type: "array", Items: schemaFor(t.Elem(), field, defs)}
+    case reflect.Map:
+        return &jsonSchema{Type: "object", AdditionalProperties: schemaFor(t.Elem(), field, defs)}
+    case reflect.String:
+        return &jsonSchema{Type: "string", Enum: schemaEnums[field]}
+    case reflect.Int, reflect.Int64:
+        return &jsonSchema{Type: "integer"}
+    case reflect.Float64:
+        return &jsonSchema{Type: "number"}
+    case reflect.Bool:
+        return &jsonSchema{Type: "boolean"}
+    }
+    return &jsonSchema{}
+}
+
+// validateConfigSchema checks a config file against the schema before it
+// is decoded, so misspelled keys and mistyped values, which decoding
+// ignores or reports without a location, fail with the file, line, column
+// and key path of every problem
+func validateConfigSchema(file string, data []byte) error {
+    var doc yaml.Node
+    if err := yaml.Unmarshal(data, &doc); err != nil {
+        return err
+    }
+    if len(doc.Content) == 0 {
+        return nil
+    }
+
+    schema := configSchema()
+    var problems []error
+    report := func(node *yaml.Node, path, format string, args ...any) {
+        if path == "" {
+            path = "config"
+        }
+        problems = append(problems, fmt.Errorf("%s:%d:%d: %s: %s", file, node.Line, node.Column, path, fmt.Sprintf(format, args...)))
+    }
+
+    var check func(node *yaml.Node, s *jsonSchema, path string)
+    check = func(node *yaml.Node, s *jsonSchema, path string) {
+        if node.Kind == yaml.AliasNode {
+            node = node.Alias
+        }
+        if s.Ref != "" {
+            s = schema.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+        }
+        if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+            return
+        }
+
+        switch s.Type {
+        case "object":
+            if node.Kind != yaml.MappingNode {
+                report(node, path, "want a mapping, got %s", describeNode(node))
+                return
+            }
+            for i := 0; i+1 < len(node.Content); i += 2 {
+                key, value := node.Content[i], node.Content[i+1]
+                if key.Value == "<<" {
+                    continue // merge key; the merged mapping is checked where it's defined
+                }
+                keyPath := key.Value
+                if path != "" {
+                    keyPath = path + "." + key.Value
+                }
+                if property, ok := s.Properties[key.Value]; ok {
+                    check(value, property, keyPath)
+                } else if values, ok := s.AdditionalProperties.(*jsonSchema); ok {
+                    check(value, values, keyPath)
+                } else {
+                    report(key, path, "unknown key %q%s", key.Value, didYouMean(key.Value, sortedKeys(s.Properties)))
+                }
+            }
+        case "array":
+            if node.Kind != yaml.SequenceNode {
+                report(node, path, "want a list, got %s", describeNode(node))
+                return
+            }
+            for i, item := range node.Content {
+                check(item, s.Items, fmt.Sprintf("%s[%d]", path, i))
+            }
+        case "string", "integer", "number", "boolean":
+            ok := node.Kind == yaml.ScalarNode
+            switch s.Type {
+            case "integer":
+                ok = ok && node.Tag == "!!int"
+            case "number":
+                ok = ok && (node.Tag == "!!int" || node.Tag == "!!float")
+            case "boolean":
+                ok = ok && node.Tag == "!!bool"
+            }
+            if !ok {
+                report(node, path, "want %s, got %s", map[string]string{"string": "a string", "integer": "an integer", "number": "a number", "boolean": "true or false"}[s.Type], describeNode(node))
+                return
+            }
+            if len(s.Enum) > 0 && !contains(s.Enum, node.Value) {
+                report(node, path, "%q is not one of %s%s", node.Value, strings.Join(s.Enum, ", "), didYouMean(node.Value, s.Enum))
+            }
+        }
+    }
+    check(doc.Content[0], schema, "")
+    return errors.Join(problems...)
+}
+
+// describeNode names a YAML node's kind, or quotes a scalar, for messages
+func describeNode(node *yaml.Node) string {
+    switch node.Kind {
+    case yaml.MappingNode:
+        return "a mapping"
+    case yaml.SequenceNode:
+        return "a list"
+    }
+    return fmt.Sprintf("%q", node.Value)
+}
+
+// didYouMean suggests the candidate closest to a misspelled name, if one
+// is within two edits of it
+func didYouMean(name string, candidates []string) string {
+    best, bestDistance := "", 3
+    for _, candidate := range candidates {
+        if distance := editDistance(strings.ToLower(name), strings.ToLower(candidate)); distance < bestDistance {
+            best, bestDistance = candidate, distance
+        }
+    }
+    if best == "" {
+        return ""
+    }
+    return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+// runConfig implements the config subcommand: config schema prints the
+// JSON Schema of the config file, and config validate checks files
+// against it and the other load-time checks
+func runConfig(args []string) int {
+    if len(args) == 0 || (args[0] != "schema" && args[0] != "validate") {
+        fmt.Fprintf(os.Stderr, "Usage: %s config schema\n       %s config validate <config_file>...\n", os.Args[0], os.Args[0])
+        return 1
+    }
+
+    if args[0] == "schema" {
+        encoder := json.NewEncoder(os.Stdout)
+        encoder.SetIndent("", "  ")
+        if err := encoder.Encode(configSchema()); err != nil {
+            fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+            return 1
+        }
+        return 0
+    }
+
+    status := 0
+    for _, path := range args[1:] {
+        config, err := loadConfig(path)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "%v\n", err)
+            status = 1
+            continue
+        }
+
+        // Rules, including those of local rule packs, are skipped rather
+        // than failing the load, so report them here
+        rules := config.Rules
+        for i := range rules {
+            rules[i].source = path
+        }
+        if !isRemote(config.StylesPath) {
+            packs, err := loadRulePacks(config.StylesPath)
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+                status = 1
+                continue
+            }
+            rules = append(rules, packs...)
+        }
+        valid := true
+        severities := config.severities()
+        for _, rule := range rules {
+            if err := rule.validate(severities); err != nil {
+                fmt.Fprintf(os.Stderr, "%s: rule %s: %v\n", rule.location(), rule.Name, err)
+                valid = false
+            }
+        }
+        if !valid {
+            status = 1
+            continue
+        }
+        fmt.Printf("%s: ok\n", path)
+    }
+    return status
+}
@@ -0,0 +1,59 @@
+// AI-readiness scoring
+
+package main
+
+import "strings"
+
+// readinessScore rates text from 0 to 100 by the density of its issues:
+// every issue costs the weight of its severity per 100 words, so one
+// warning in a 100-word section scores 95 while the same warning in 1,000
+// words scores 99.5. Texts shorter than 100 words are weighed as if they
+// had 100.
+func readinessScore(issues []Issue, words int, severities severityPolicy) float64 {
+    if words < 100 {
+        words = 100
+    }
+
+    penalty := 0.0
+    for _, issue := range issues {
+        penalty += severities.weight(issue.Severity)
+    }
+
+    score := 100 - penalty*100/float64(words)
+    if score < 0 {
+        return 0
+    }
+    return score
+}
+
+// wordCount returns the number of whitespace-separated words in text
+func wordCount(text string) int {
+    return len(strings.Fields(text))
+}
+
+// fileWords returns the word count of each file, for scoring a run. Files
+// that can't be read count no words.
+func fileWords(files []string) map[string]int {
+    words := make(map[string]int)
+    for _, file := range files {
+        if _, seen := words[file]; !seen {
+            content, _ := readDocument(file)
+            words[file] = wordCount(content)
+        }
+    }
+    return words
+}
+
+// SectionAt returns the section containing the 1-based line, heading included
+func (d *Document) SectionAt(lineNum int) (Section, bool) {
+    for _, section := range d.Sections {
+        start := section.StartLine
+        if section.Line > 0 {
+            start = section.Line
+        }
+        if lineNum >= start && lineNum <= section.EndLine {
+            return section, true
+        }
+    }
+    return Section{}, false
+}
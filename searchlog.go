@@ -0,0 +1,246 @@
+// Search and chat log prioritization
+
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/url"
+    "os"
+    "path"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+var (
+    // pageFields and countFields are the log columns or keys, in order of
+    // preference, that name the retrieved page and how often it was hit
+    pageFields  = []string{"page", "url", "path", "document", "doc", "source", "sources", "file"}
+    countFields = []string{"count", "hits", "retrievals", "views", "clicks"}
+    // pageListRegex separates several pages in one field, as chat logs
+    // list every source used for an answer
+    pageListRegex = regexp.MustCompile(`[;|\s]+`)
+    pageExtRegex  = regexp.MustCompile(`(?i)\.(?:md|markdown|mdx|html?|rst|txt)$`)
+)
+
+// SearchLog counts how often each page was retrieved
+type SearchLog struct {
+    hits map[string]int // normalized page path -> retrievals
+}
+
+// loadSearchLog reads a search or chat query log. CSV files need a header
+// row; JSON files hold an array of objects or one object per line. Each
+// record names the page or pages it retrieved, and may carry a count.
+func loadSearchLog(file string) (*SearchLog, error) {
+    data, err := os.ReadFile(file)
+    if err != nil {
+        return nil, err
+    }
+    log := &SearchLog{hits: make(map[string]int)}
+
+    trimmed := bytes.TrimSpace(data)
+    if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+        err = log.readJSON(trimmed)
+    } else {
+        err = log.readCSV(data)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to read search log %s: %w", file, err)
+    }
+    if len(log.hits) == 0 {
+        return nil, fmt.Errorf("search log %s names no pages; expected a %s field", file, strings.Join(pageFields, ", "))
+    }
+    return log, nil
+}
+
+func (l *SearchLog) readCSV(data []byte) error {
+    reader := csv.NewReader(bytes.NewReader(data))
+    reader.FieldsPerRecord = -1
+    header, err := reader.Read()
+    if err != nil {
+        return err
+    }
+    columns := make(map[string]int)
+    for i, name := range header {
+        columns[strings.ToLower(strings.TrimSpace(name))] = i
+    }
+    pageColumn, countColumn := -1, -1
+    for _, name := range pageFields {
+        if i, ok := columns[name]; ok {
+            pageColumn = i
+            break
+        }
+    }
+    for _, name := range countFields {
+        if i, ok := columns[name]; ok {
+            countColumn = i
+            break
+        }
+    }
+    if pageColumn < 0 {
+        return fmt.Errorf("no page column in header %q", strings.Join(header, ","))
+    }
+
+    for {
+        record, err := reader.Read()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        if pageColumn >= len(record) {
+            continue
+        }
+        count := 1
+        if countColumn >= 0 && countColumn < len(record) {
+            count = parseCount(record[countColumn])
+        }
+        l.add(pageListRegex.Split(record[pageColumn], -1), count)
+    }
+}
+
+func (l *SearchLog) readJSON(data []byte) error {
+    var records []map[string]any
+    if data[0] == '[' {
+        if err := json.Unmarshal(data, &records); err != nil {
+            return err
+        }
+    } else {
+        scanner := bufio.NewScanner(bytes.NewReader(data))
+        scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+        for scanner.Scan() {
+            line := bytes.TrimSpace(scanner.Bytes())
+            if len(line) == 0 {
+                continue
+            }
+            var record map[string]any
+            if err := json.Unmarshal(line, &record); err != nil {
+                return err
+            }
+            records = append(records, record)
+        }
+        if err := scanner.Err(); err != nil {
+            return err
+        }
+    }
+
+    for _, record := range records {
+        fields := make(map[string]any)
+        for key, value := range record {
+            fields[strings.ToLower(key)] = value
+        }
+        count := 1
+        for _, name := range countFields {
+            if value, ok := fields[name]; ok {
+                count = parseCount(fmt.Sprint(value))
+                break
+            }
+        }
+        for _, name := range pageFields {
+            var pages []string
+            switch value := fields[name].(type) {
+            case string:
+                pages = pageListRegex.Split(value, -1)
+            case []any:
+                for _, item := range value {
+                    switch item := item.(type) {
+                    case string:
+                        pages = append(pages, item)
+                    case map[string]any:
+                        // chat logs often list sources as objects
+                        for _, key := range pageFields {
+                            if page, ok := item[key].(string); ok {
+                                pages = append(pages, page)
+                                break
+                            }
+                        }
+                    }
+                }
+            }
+            if len(pages) > 0 {
+                l.add(pages, count)
+                break
+            }
+        }
+    }
+    return nil
+}
+
+// parseCount reads a hit count, treating anything unparsable as one hit
+func parseCount(value string) int {
+    count, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+    if err != nil || count < 0 {
+        return 1
+    }
+    return int(count)
+}
+
+func (l *SearchLog) add(pages []string, count int) {
+    for _, page := range pages {
+        if key := pageKey(page); key != "" {
+            l.hits[key] += count
+        }
+    }
+}
+
+// pageKey normalizes a URL or file path so a page's published URL and its
+// source file agree: https://docs.example.com/guide/install/ and
+// docs/guide/install.md both become guide/install and docs/guide/install,
+// which match by suffix
+func pageKey(page string) string {
+    page = strings.TrimSpace(page)
+    if _, member, ok := splitArchivePath(page); ok {
+        page = member
+    }
+    if parsed, err := url.Parse(page); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+        page = parsed.Path
+    } else if i := strings.IndexAny(page, "?#"); i >= 0 {
+        page = page[:i]
+    }
+    page = strings.ToLower(path.Clean("/" + strings.ReplaceAll(page, "\\", "/")))
+    page = pageExtRegex.ReplaceAllString(page, "")
+    page = strings.TrimSuffix(strings.TrimSuffix(page, "/index"), "/readme")
+    return strings.Trim(page, "/")
+}
+
+// Hits returns how often the page a file or URL holds was retrieved: the
+// count of the longest logged page path that ends the file's path
+func (l *SearchLog) Hits(page string) int {
+    key := pageKey(page)
+    if hits, ok := l.hits[key]; ok {
+        return hits
+    }
+    best, hits := 0, 0
+    for logged, count := range l.hits {
+        if len(logged) > best && strings.HasSuffix(key, "/"+logged) {
+            best, hits = len(logged), count
+        }
+    }
+    return hits
+}
+
+// prioritize records on each issue how often its page was retrieved and
+// orders the issues most-retrieved first, keeping file order otherwise
+func (l *SearchLog) prioritize(issues []Issue) {
+    cache := make(map[string]int)
+    for i := range issues {
+        page := issues[i].URL
+        if page == "" {
+            page = issues[i].File
+        }
+        hits, ok := cache[page]
+        if !ok {
+            hits = l.Hits(page)
+            cache[page] = hits
+        }
+        issues[i].Hits = hits
+    }
+    sort.SliceStable(issues, func(i, j int) bool { return issues[i].Hits > issues[j].Hits })
+}
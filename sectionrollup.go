@@ -0,0 +1,148 @@
+// Issue rollup by section, for fixing a document one section at a time
+
+package main
+
+import (
+    "fmt"
+    "io"
+    "sort"
+    "strings"
+)
+
+func init() {
+    RegisterFormatter("sections", FormatterFunc(printSectionRollup))
+}
+
+// IssueSection is the section of its document an issue falls in
+type IssueSection struct {
+    Path   string // heading path, or (preamble) before the first heading
+    Line   int    // line of the heading, or the first line of the preamble
+    Tokens int    // estimated size of the heading and body
+}
+
+// locateSections records on each issue of the documents the section it
+// falls in, from the documents as analysis parsed them: fetched, converted
+// and with their variables substituted. Issues that already have one, and
+// those outside any section, are left as they are.
+func locateSections(issues []Issue, docs ...*Document) {
+    byPath := make(map[string]*Document)
+    for _, doc := range docs {
+        byPath[doc.Path] = doc
+    }
+    located := make(map[*Document]map[int]*IssueSection)
+    for i := range issues {
+        doc := byPath[issues[i].File]
+        if doc == nil || issues[i].Section != nil {
+            continue
+        }
+        section, ok := doc.SectionAt(issues[i].Line)
+        if !ok {
+            continue
+        }
+        if located[doc] == nil {
+            located[doc] = make(map[int]*IssueSection)
+        }
+        found := located[doc][section.StartLine]
+        if found == nil {
+            found = &IssueSection{Path: plainText(section.Breadcrumb()), Line: section.Line}
+            text := doc.Body(section)
+            if section.Line > 0 {
+                text = doc.Lines[section.Line-1] + "\n" + text
+            } else {
+                found.Path, found.Line = "(preamble)", section.StartLine
+            }
+            found.Tokens = estimateTokens(text)
+            located[doc][section.StartLine] = found
+        }
+        issues[i].Section = found
+    }
+}
+
+// sectionRollup is the issues of one section of a file
+type sectionRollup struct {
+    path   string // heading path, or (preamble) before the first heading
+    line   int
+    tokens int // estimated size of the heading and body
+    issues []Issue
+}
+
+// rollUpSections groups the issues of one file by the section analysis
+// found them in, in document order. Issues outside any section, such as
+// those about the whole file, come first.
+func rollUpSections(issues []Issue) []*sectionRollup {
+    whole := &sectionRollup{path: "(document)"}
+    byLine := make(map[int]*sectionRollup)
+    var sections []*sectionRollup
+    for _, issue := range issues {
+        section := issue.Section
+        if section == nil {
+            whole.issues = append(whole.issues, issue)
+            continue
+        }
+        rollup := byLine[section.Line]
+        if rollup == nil {
+            rollup = &sectionRollup{path: section.Path, line: section.Line, tokens: section.Tokens}
+            byLine[section.Line] = rollup
+            sections = append(sections, rollup)
+        }
+        rollup.issues = append(rollup.issues, issue)
+    }
+    sort.SliceStable(sections, func(i, j int) bool { return sections[i].line < sections[j].line })
+    if len(whole.issues) > 0 {
+        sections = append([]*sectionRollup{whole}, sections...)
+    }
+    return sections
+}
+
+// printSectionRollup writes the issues of each file rolled up by section:
+// the section's heading path and size, its issue count by severity, and
+// how many issues each rule raised in it
+func printSectionRollup(w io.Writer, issues []Issue) error {
+    var files []string
+    byFile := make(map[string][]Issue)
+    for _, issue := range issues {
+        if _, seen := byFile[issue.File]; !seen {
+            files = append(files, issue.File)
+        }
+        byFile[issue.File] = append(byFile[issue.File], issue)
+    }
+
+    severities := reportSeverities().ordered()
+    for _, file := range files {
+        fmt.Fprintf(w, "%s (%d issue(s))\n", file, len(byFile[file]))
+        for _, section := range rollUpSections(byFile[file]) {
+            bySeverity := make(map[string]int)
+            byRule := make(map[string]int)
+            for _, issue := range section.issues {
+                bySeverity[issue.Severity]++
+                byRule[issue.Rule]++
+            }
+            var counts []string
+            for _, level := range severities {
+                if n := bySeverity[level.Name]; n > 0 {
+                    counts = append(counts, fmt.Sprintf("%d %s", n, level.Name))
+                    delete(bySeverity, level.Name)
+                }
+            }
+            for _, name := range sortedKeys(bySeverity) {
+                counts = append(counts, fmt.Sprintf("%d %s", bySeverity[name], name))
+            }
+
+            where := ""
+            if section.line > 0 {
+                where = fmt.Sprintf(" (line %d, ~%d tokens)", section.line, section.tokens)
+            }
+            fmt.Fprintf(w, "  %s%s: %d issue(s) (%s)\n", section.path, where, len(section.issues), strings.Join(counts, ", "))
+
+            rules := sortedKeys(byRule)
+            sort.SliceStable(rules, func(i, j int) bool { return byRule[rules[i]] > byRule[rules[j]] })
+            for _, rule := range rules {
+                fmt.Fprintf(w, "      %3d %s\n", byRule[rule], rule)
+            }
+        }
+        if _, err := fmt.Fprintln(w); err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,231 @@
+// Paragraph and sentence segmentation
+
+package main
+
+import (
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// Paragraph is a run of consecutive prose lines within one section
+type Paragraph struct {
+    Section   Section
+    StartLine int    // 1-based first line
+    EndLine   int    // 1-based last line (inclusive)
+    Text      string // the paragraph's masked lines joined with "\n"
+}
+
+// Sentence is one sentence of a paragraph with its position in the file
+type Sentence struct {
+    Text   string // the sentence with each run of whitespace as one space
+    Line   int    // 1-based line of the first character
+    Column int    // 1-based byte column of the first character
+    Start  int    // byte offset of the first character in the paragraph's Text
+}
+
+// sentenceAbbreviations end with a period that doesn't end a sentence
+var sentenceAbbreviations = []string{
+    "e.g.", "i.e.", "etc.", "vs.", "cf.", "approx.", "dr.", "mr.", "mrs.", "ms.", "no.", "fig.", "inc.", "ltd.",
+    "al.", "ca.", "ch.", "corp.", "dept.", "eq.", "esp.", "jr.", "sr.", "st.", "pp.", "ref.", "sec.", "ver.", "vol.",
+}
+
+// Paragraphs returns the document's prose paragraphs in order, excluding
+// headings, code blocks and front matter
+func (d *Document) Paragraphs() []Paragraph {
+    var paragraphs []Paragraph
+
+    for _, section := range d.Sections {
+        start := 0
+        flush := func(end int) {
+            if start > 0 {
+                paragraphs = append(paragraphs, Paragraph{
+                    Section:   section,
+                    StartLine: start,
+                    EndLine:   end,
+                    Text:      strings.Join(d.Masked[start-1:end], "\n"),
+                })
+                start = 0
+            }
+        }
+
+        for lineNum := section.StartLine; lineNum <= section.EndLine; lineNum++ {
+            trimmed := strings.TrimSpace(d.Masked[lineNum-1])
+            if trimmed == "" || d.Fenced[lineNum-1] {
+                flush(lineNum - 1)
+                continue
+            }
+            if start == 0 {
+                start = lineNum
+            }
+        }
+        flush(section.EndLine)
+    }
+
+    return paragraphs
+}
+
+// Sentences splits the paragraph into sentences at terminal punctuation
+// followed by whitespace and an uppercase letter, digit, quote or code
+// span. It doesn't split inside code spans, after common abbreviations and
+// initials such as "J.", or inside decimal and version numbers such as
+// 2.5 or v1.2.3, which have no whitespace after the period.
+func (p Paragraph) Sentences() []Sentence {
+    var sentences []Sentence
+    text := p.Text
+    start := skipSpace(text, 0)
+
+    for i := 0; i < len(text); i++ {
+        c := text[i]
+        if c == '`' {
+            i = skipCodeSpan(text, i) - 1
+            continue
+        }
+        if c != '.' && c != '!' && c != '?' {
+            continue
+        }
+
+        next := i + 1
+        for next < len(text) && strings.ContainsRune(`"')]*_`, rune(text[next])) {
+            next++
+        }
+        if next < len(text) && !unicode.IsSpace(rune(text[next])) {
+            continue
+        }
+        after := skipSpace(text, next)
+        if after < len(text) && !startsSentence(text[after:]) {
+            continue
+        }
+        if c == '.' && (endsWithAbbreviation(text[start:i+1]) || endsWithInitial(text[start:i+1])) {
+            continue
+        }
+
+        sentences = append(sentences, p.sentence(start, next))
+        start = after
+        i = after - 1
+    }
+
+    if start < len(text) && strings.TrimSpace(text[start:]) != "" {
+        sentences = append(sentences, p.sentence(start, len(text)))
+    }
+
+    return sentences
+}
+
+// skipCodeSpan returns the offset after the code span opening with the
+// backtick run at i, or after the run itself if nothing closes it
+func skipCodeSpan(text string, i int) int {
+    run := i
+    for run < len(text) && text[run] == '`' {
+        run++
+    }
+    fence := text[i:run]
+    for j := run; j < len(text); {
+        k := strings.Index(text[j:], fence)
+        if k < 0 {
+            break
+        }
+        end := j + k + len(fence)
+        if end == len(text) || text[end] != '`' {
+            return end
+        }
+        for end < len(text) && text[end] == '`' {
+            end++
+        }
+        j = end
+    }
+    return run
+}
+
+// Position returns the file position of the byte at offset in a
+// sentence's Text, mapping each run of whitespace back to the lines it
+// spans
+func (p Paragraph) Position(sentence Sentence, offset int) (line, column int) {
+    i, n := sentence.Start, 0
+    for i < len(p.Text) && n < offset {
+        r, size := utf8.DecodeRuneInString(p.Text[i:])
+        if !unicode.IsSpace(r) {
+            i += size
+            n += size
+            continue
+        }
+        for i < len(p.Text) {
+            r, size := utf8.DecodeRuneInString(p.Text[i:])
+            if !unicode.IsSpace(r) {
+                break
+            }
+            i += size
+        }
+        n++
+    }
+    before := p.Text[:i]
+    return p.StartLine + strings.Count(before, "\n"), i - (strings.LastIndex(before, "\n") + 1) + 1
+}
+
+// sentence builds the Sentence spanning text[start:end], mapping the start
+// offset back to a file position
+func (p Paragraph) sentence(start, end int) Sentence {
+    before := p.Text[:start]
+    line := p.StartLine + strings.Count(before, "\n")
+    column := start - (strings.LastIndex(before, "\n") + 1) + 1
+
+    return Sentence{
+        Text:   strings.Join(strings.Fields(p.Text[start:end]), " "),
+        Line:   line,
+        Column: column,
+        Start:  start,
+    }
+}
+
+// skipSpace returns the offset of the first non-space byte at or after i
+func skipSpace(text string, i int) int {
+    for i < len(text) && unicode.IsSpace(rune(text[i])) {
+        i++
+    }
+    return i
+}
+
+// startsSentence reports whether text begins like a new sentence: with
+// an uppercase letter, a digit or a code span, after any quotes, brackets
+// or emphasis
+func startsSentence(text string) bool {
+    for _, r := range text {
+        if r == '`' {
+            return true
+        }
+        if strings.ContainsRune(`"'(*_[`, r) {
+            continue
+        }
+        return unicode.IsUpper(r) || unicode.IsDigit(r)
+    }
+    return false
+}
+
+// endsWithAbbreviation reports whether text ends with a known abbreviation
+func endsWithAbbreviation(text string) bool {
+    lower := strings.ToLower(text)
+    for _, abbr := range sentenceAbbreviations {
+        if strings.HasSuffix(lower, abbr) {
+            before := len(lower) - len(abbr)
+            if before == 0 || !unicode.IsLetter(rune(lower[before-1])) {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// endsWithInitial reports whether text ends with a single capital letter
+// and a period, as in "J. Smith" or "U.S.", which rarely end a sentence
+func endsWithInitial(text string) bool {
+    body := strings.TrimSuffix(text, ".")
+    last, size := utf8.DecodeLastRuneInString(body)
+    if !unicode.IsUpper(last) {
+        return false
+    }
+    if len(body) == size {
+        return true
+    }
+    before, _ := utf8.DecodeLastRuneInString(body[:len(body)-size])
+    return unicode.IsSpace(before) || before == '.' || before == '('
+}
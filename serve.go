@@ -0,0 +1,191 @@
+// HTTP server for editor integrations and docs pipelines
+
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "flag"
+    "fmt"
+    "net/http"
+    "os"
+    "time"
+)
+
+// AnalyzeRequest is the body of a POST to /analyze
+type AnalyzeRequest struct {
+    Path    string `json:"path"` // names the document and selects its format, document.md by default
+    Content string `json:"content"`
+}
+
+// runServe implements the serve subcommand. POST /analyze takes an
+// AnalyzeRequest and answers with the JSON output of analyze for that one
+// document; GET /rules lists the rules; GET /schedule reports the jobs of
+// the configuration's Schedule, which serve runs in the background; GET
+// /healthz answers ok. Cross-file checks don't run, since a request
+// carries a single document. With -projects, each project gets its own
+// /analyze, /rules and /schedule under /projects/<name>/, behind its
+// token; otherwise -token-env puts the endpoints behind a token. -tls-cert
+// and -tls-key serve HTTPS, and -client-ca also requires client
+// certificates it signed.
+func runServe(args []string) int {
+    flags := flag.NewFlagSet("serve", flag.ExitOnError)
+    configPath := flags.String("config", "", "Path or HTTPS/git URL of configuration file")
+    addr := flags.String("addr", "127.0.0.1:8080", "Address to listen on")
+    dbPath := flags.String("db", "", "SQLite results database the scheduled runs record to")
+    projectsPath := flags.String("projects", "", "YAML file of projects to host, each with its own configuration, token and results database")
+    tokenEnv := flags.String("token-env", "", "Environment variable holding the bearer token requests must carry")
+    maxBody := flags.String("max-body", "10MB", "Largest request body to accept")
+    tlsCert := flags.String("tls-cert", "", "PEM certificate chain to serve HTTPS with")
+    tlsKey := flags.String("tls-key", "", "PEM private key of -tls-cert")
+    clientCA := flags.String("client-ca", "", "PEM bundle of CAs whose client certificates to require (mutual TLS)")
+    flags.Parse(args)
+
+    limit, err := parseSize(*maxBody)
+    if err == nil && limit == 0 {
+        err = fmt.Errorf("must be more than 0")
+    }
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: -max-body: %v\n", err)
+        return 1
+    }
+    if (*tlsCert == "") != (*tlsKey == "") {
+        fmt.Fprintf(os.Stderr, "Error: -tls-cert and -tls-key go together\n")
+        return 1
+    }
+    if *clientCA != "" && *tlsCert == "" {
+        fmt.Fprintf(os.Stderr, "Error: -client-ca requires -tls-cert and -tls-key\n")
+        return 1
+    }
+    server := &http.Server{
+        Addr:              *addr,
+        ReadHeaderTimeout: 10 * time.Second,
+        ReadTimeout:       time.Minute,
+        IdleTimeout:       2 * time.Minute,
+        MaxHeaderBytes:    64 << 10,
+    }
+    if *tlsCert != "" {
+        if server.TLSConfig, err = serverTLSConfig(*clientCA); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: -client-ca: %v\n", err)
+            return 1
+        }
+    }
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprintln(w, "ok")
+    })
+    var schedulers []*scheduler
+    if *projectsPath != "" {
+        if *configPath != "" || *dbPath != "" || *tokenEnv != "" {
+            fmt.Fprintf(os.Stderr, "Error: with -projects, each project sets its own Config, DB and Token instead of -config, -db and -token-env\n")
+            return 1
+        }
+        projects, err := loadProjects(*projectsPath)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return 1
+        }
+        for _, project := range projects {
+            handler, jobs, err := newServeHandler(project.Config, project.DB, limit)
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "Error creating analyzer for project %s: %v\n", project.Name, err)
+                return 1
+            }
+            prefix := "/projects/" + project.Name
+            mux.Handle(prefix+"/", bearerAuth(project.Name, project.token(), http.StripPrefix(prefix, handler)))
+            schedulers = append(schedulers, jobs)
+        }
+    } else {
+        handler, jobs, err := newServeHandler(*configPath, *dbPath, limit)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error creating analyzer: %v\n", err)
+            return 1
+        }
+        if *tokenEnv != "" {
+            token := os.Getenv(*tokenEnv)
+            if token == "" {
+                fmt.Fprintf(os.Stderr, "Error: -token-env: %s is unset\n", *tokenEnv)
+                return 1
+            }
+            handler = bearerAuth("ai-doc-optimizer", token, handler)
+        }
+        mux.Handle("/", handler)
+        schedulers = append(schedulers, jobs)
+    }
+    for _, jobs := range schedulers {
+        jobs.start()
+    }
+
+    server.Handler = mux
+    if *tlsCert != "" {
+        fmt.Fprintf(os.Stderr, "Listening on https://%s\n", *addr)
+        err = server.ListenAndServeTLS(*tlsCert, *tlsKey)
+    } else {
+        fmt.Fprintf(os.Stderr, "Listening on http://%s\n", *addr)
+        err = server.ListenAndServe()
+    }
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+    return 0
+}
+
+// newServeHandler returns the /analyze, /rules and /schedule endpoints of
+// one configuration, with the scheduler of its Schedule, not yet started.
+// Request bodies over maxBody bytes are rejected.
+func newServeHandler(configPath, dbPath string, maxBody int64) (http.Handler, *scheduler, error) {
+    analyzer, err := NewAnalyzer(configPath)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    // An Analyzer is safe for concurrent use, so requests are analyzed as
+    // they arrive
+    mux := http.NewServeMux()
+    mux.HandleFunc("/analyze", func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            http.Error(w, "use POST", http.StatusMethodNotAllowed)
+            return
+        }
+        var request AnalyzeRequest
+        if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(&request); err != nil {
+            var tooLarge *http.MaxBytesError
+            if errors.As(err, &tooLarge) {
+                http.Error(w, fmt.Sprintf("request is larger than %s", formatSize(maxBody)), http.StatusRequestEntityTooLarge)
+                return
+            }
+            http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+            return
+        }
+        if request.Path == "" {
+            request.Path = "document.md"
+        }
+
+        // The request's include directives aren't followed: they would read
+        // files from the server's disk
+        issues := analyzer.analyzeContentIncluding(request.Path, request.Content, false)
+        sortIssues(issues)
+        analyzer.config.linkRuleDocs(issues)
+
+        w.Header().Set("Content-Type", "application/json")
+        if err := printJSONIssues(w, issues); err != nil {
+            fmt.Fprintf(os.Stderr, "Error writing response: %v\n", err)
+        }
+    })
+    mux.HandleFunc("/rules", func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(analyzer.ruleInfos())
+    })
+    jobs := newScheduler(analyzer.config.Schedule, configPath, dbPath)
+    mux.HandleFunc("/schedule", func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(jobs.statuses())
+    })
+
+    if len(analyzer.config.Schedule) > 0 && dbPath == "" {
+        fmt.Fprintf(os.Stderr, "Warning: scheduled runs are only recorded with -db\n")
+    }
+    return mux, jobs, nil
+}
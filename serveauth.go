@@ -0,0 +1,47 @@
+// Authentication and TLS for serve
+
+package main
+
+import (
+    "crypto/subtle"
+    "crypto/tls"
+    "crypto/x509"
+    "fmt"
+    "net/http"
+    "os"
+    "strings"
+)
+
+// bearerAuth passes on the requests that carry token in an Authorization:
+// Bearer header, and rejects the others
+func bearerAuth(realm, token string, next http.Handler) http.Handler {
+    want := []byte(token)
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+        if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(given)), want) != 1 {
+            w.Header().Set("WWW-Authenticate", `Bearer realm="`+realm+`"`)
+            http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// serverTLSConfig returns the TLS configuration of serve. With a CA
+// bundle, each client must present a certificate signed by one of its CAs.
+func serverTLSConfig(caPath string) (*tls.Config, error) {
+    config := &tls.Config{MinVersion: tls.VersionTLS12}
+    if caPath == "" {
+        return config, nil
+    }
+    data, err := os.ReadFile(caPath)
+    if err != nil {
+        return nil, err
+    }
+    config.ClientCAs = x509.NewCertPool()
+    if !config.ClientCAs.AppendCertsFromPEM(data) {
+        return nil, fmt.Errorf("%s holds no PEM certificates", caPath)
+    }
+    config.ClientAuth = tls.RequireAndVerifyClientCert
+    return config, nil
+}
@@ -0,0 +1,224 @@
+// Severity levels and what each one means for the exit status and for
+// SARIF and code quality reports
+
+package main
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "strings"
+)
+
+// SeverityLevel defines a severity beyond error, warning and suggestion,
+// such as blocker or info, or changes what one of those means. Unset
+// fields of a built-in severity keep their built-in values.
+type SeverityLevel struct {
+    Name        string `yaml:"Name" json:"-"`
+    Rank        int    `yaml:"Rank,omitempty" json:"rank"`                // orders severities: suggestion is 10, warning 20, error 30; higher is more severe
+    Fails       *bool  `yaml:"Fails,omitempty" json:"fails"`              // whether issues of this severity make the run exit with status 1, true by default
+    SARIF       string `yaml:"SARIF,omitempty" json:"sarif"`              // SARIF result level: error, warning, note or none; warning by default
+    CodeQuality string `yaml:"CodeQuality,omitempty" json:"code_quality"` // GitLab code quality severity: info, minor, major, critical or blocker; minor by default
+}
+
+// sarifLevels and codeQualitySeverities are the values SARIF and GitLab
+// code quality reports accept
+var (
+    sarifLevels           = []string{"error", "warning", "note", "none"}
+    codeQualitySeverities = []string{"info", "minor", "major", "critical", "blocker"}
+)
+
+// failureRank is the rank of failures, above every other severity, as
+// they mean the run is incomplete
+const failureRank = 1000
+
+// builtinSeverities are the severities every configuration has
+var builtinSeverities = severityPolicy{
+    "error":         {Name: "error", Rank: 30, Fails: boolPointer(true), SARIF: "error", CodeQuality: "major"},
+    "warning":       {Name: "warning", Rank: 20, Fails: boolPointer(true), SARIF: "warning", CodeQuality: "minor"},
+    "suggestion":    {Name: "suggestion", Rank: 10, Fails: boolPointer(true), SARIF: "note", CodeQuality: "info"},
+    failureSeverity: {Name: failureSeverity, Rank: failureRank, Fails: boolPointer(true), SARIF: "error", CodeQuality: "critical"},
+}
+
+func boolPointer(b bool) *bool {
+    return &b
+}
+
+// severityPolicy holds the severities a configuration allows, by name
+type severityPolicy map[string]SeverityLevel
+
+// severities returns the built-in severities with the configuration's
+// SeverityLevels applied
+func (c *Config) severities() severityPolicy {
+    policy := make(severityPolicy, len(builtinSeverities)+len(c.SeverityLevels))
+    for name, level := range builtinSeverities {
+        policy[name] = level
+    }
+    for _, level := range c.SeverityLevels {
+        base, ok := policy[level.Name]
+        if !ok {
+            base = customSeverity(level.Name)
+        }
+        if level.Rank != 0 || !ok {
+            base.Rank = level.Rank
+        }
+        if level.Fails != nil {
+            base.Fails = level.Fails
+        }
+        if level.SARIF != "" {
+            base.SARIF = level.SARIF
+        }
+        if level.CodeQuality != "" {
+            base.CodeQuality = level.CodeQuality
+        }
+        policy[level.Name] = base
+    }
+    return policy
+}
+
+// validateSeverityLevels rejects SeverityLevels entries that can't apply
+func (c *Config) validateSeverityLevels() error {
+    seen := make(map[string]bool)
+    for i, level := range c.SeverityLevels {
+        switch {
+        case level.Name == "":
+            return fmt.Errorf("SeverityLevels[%d] has no Name", i)
+        case level.Name == failureSeverity:
+            return fmt.Errorf("SeverityLevels: %s is reserved for input the run couldn't analyze", failureSeverity)
+        case seen[level.Name]:
+            return fmt.Errorf("SeverityLevels: %s is listed twice", level.Name)
+        case level.Rank >= failureRank:
+            return fmt.Errorf("SeverityLevels: Rank %d of %s isn't below %d, the rank of failures", level.Rank, level.Name, failureRank)
+        case level.SARIF != "" && !contains(sarifLevels, level.SARIF):
+            return fmt.Errorf("SeverityLevels: invalid SARIF %q for %s (want %s)", level.SARIF, level.Name, strings.Join(sarifLevels, ", "))
+        case level.CodeQuality != "" && !contains(codeQualitySeverities, level.CodeQuality):
+            return fmt.Errorf("SeverityLevels: invalid CodeQuality %q for %s (want %s)", level.CodeQuality, level.Name, strings.Join(codeQualitySeverities, ", "))
+        }
+        seen[level.Name] = true
+    }
+    return nil
+}
+
+// allows reports whether rules may carry a severity. Failures are only
+// reported by the run itself.
+func (p severityPolicy) allows(severity string) bool {
+    _, ok := p[severity]
+    return ok && severity != failureSeverity
+}
+
+// names lists the severities rules may carry, from most to least severe
+func (p severityPolicy) names() string {
+    var names []string
+    for _, level := range p.ordered() {
+        if level.Name != failureSeverity {
+            names = append(names, level.Name)
+        }
+    }
+    return strings.Join(names, ", ")
+}
+
+// ordered returns the severities from most to least severe
+func (p severityPolicy) ordered() []SeverityLevel {
+    var levels []SeverityLevel
+    for _, name := range sortedKeys(p) {
+        levels = append(levels, p[name])
+    }
+    sort.SliceStable(levels, func(i, j int) bool { return levels[i].Rank > levels[j].Rank })
+    return levels
+}
+
+// level returns the definition of a severity, the built-in one if the
+// policy doesn't have it
+func (p severityPolicy) level(severity string) SeverityLevel {
+    if level, ok := p[severity]; ok {
+        return level
+    }
+    if level, ok := builtinSeverities[severity]; ok {
+        return level
+    }
+    return customSeverity(severity)
+}
+
+// customSeverity returns the defaults of a severity that isn't built in
+func customSeverity(name string) SeverityLevel {
+    return SeverityLevel{Name: name, Fails: boolPointer(true), SARIF: "warning", CodeQuality: "minor"}
+}
+
+// rank orders severities; higher is more severe
+func (p severityPolicy) rank(severity string) int {
+    if severity == "" {
+        return math.MinInt
+    }
+    return p.level(severity).Rank
+}
+
+// named returns the policy with each level's Name set from its key, which
+// report JSON leaves out
+func (p severityPolicy) named() severityPolicy {
+    named := make(severityPolicy, len(p))
+    for name, level := range p {
+        level.Name = name
+        named[name] = level
+    }
+    return named
+}
+
+// weight is the readiness score penalty of an issue of a severity, from
+// its rank: 2 for a suggestion, 5 for a warning and 10 for an error, and in
+// proportion for severities ranked between or beyond those. Failures, and
+// severities ranked 0 or below, cost nothing.
+func (p severityPolicy) weight(severity string) float64 {
+    rank := float64(p.rank(severity))
+    switch {
+    case severity == failureSeverity || rank <= 0:
+        return 0
+    case rank <= 10:
+        return rank * 0.2
+    case rank <= 20:
+        return 2 + (rank-10)*0.3
+    default:
+        return 5 + (rank-20)*0.5
+    }
+}
+
+// atLeastErrors counts the issues of severities ranked as error or above,
+// failures aside, for thresholds on errors
+func (p severityPolicy) atLeastErrors(bySeverity map[string]int) int {
+    count := 0
+    for severity, n := range bySeverity {
+        if severity != failureSeverity && p.rank(severity) >= p.rank("error") {
+            count += n
+        }
+    }
+    return count
+}
+
+// describeCounts lists the issues of each severity, most severe first, as
+// in "2 errors, 5 warnings, 0 suggestions". Failures, and severities the
+// policy doesn't define, are listed only when there are some.
+func (p severityPolicy) describeCounts(bySeverity map[string]int) string {
+    var counts []string
+    listed := make(map[string]bool)
+    for _, level := range p.ordered() {
+        if level.Name != failureSeverity || bySeverity[level.Name] > 0 {
+            counts = append(counts, fmt.Sprintf("%d %ss", bySeverity[level.Name], level.Name))
+        }
+        listed[level.Name] = true
+    }
+    for _, severity := range sortedKeys(bySeverity) {
+        if !listed[severity] && bySeverity[severity] > 0 {
+            counts = append(counts, fmt.Sprintf("%d %ss", bySeverity[severity], severity))
+        }
+    }
+    return strings.Join(counts, ", ")
+}
+
+// fails reports whether any of the issues makes the run fail
+func (p severityPolicy) fails(issues []Issue) bool {
+    for _, issue := range issues {
+        if *p.level(issue.Severity).Fails {
+            return true
+        }
+    }
+    return false
+}
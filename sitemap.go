@@ -0,0 +1,185 @@
+// Sitemap-driven batch input
+
+package main
+
+import (
+    "bytes"
+    "compress/gzip"
+    "encoding/xml"
+    "flag"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "strings"
+    "sync"
+    "time"
+)
+
+// maxSitemapDepth bounds how deeply sitemap indexes may nest
+const maxSitemapDepth = 3
+
+// SitemapSource fetches the pages a sitemap lists, several at a time
+type SitemapSource struct {
+    Location    string // sitemap URL or file path; may be a sitemap index, and gzipped
+    Concurrency int
+    MaxPages    int
+}
+
+// runSitemap implements the sitemap subcommand
+func runSitemap(args []string) int {
+    flags := flag.NewFlagSet("sitemap", flag.ExitOnError)
+    common := addSourceFlags(flags)
+    concurrency := flags.Int("concurrency", 4, "Number of pages to fetch at once")
+    maxPages := flags.Int("max-pages", 5000, "Fetch at most this many of the listed pages")
+    flags.Parse(args)
+
+    if flags.NArg() != 1 || *concurrency < 1 {
+        fmt.Fprintf(os.Stderr, "Usage: %s sitemap [options] <sitemap_url_or_file>\n", os.Args[0])
+        flags.PrintDefaults()
+        return 1
+    }
+
+    source := SitemapSource{Location: flags.Arg(0), Concurrency: *concurrency, MaxPages: *maxPages}
+    common.location = source.Location
+    return common.run(source.Fetch)
+}
+
+// Fetch reads the sitemap and fetches its pages, returning the main
+// content of each as Markdown in sitemap order. Pages robots.txt disallows
+// and pages that fail to load are skipped, the latter with a warning.
+func (s SitemapSource) Fetch() ([]SourceDocument, error) {
+    fetcher := &Crawler{client: &http.Client{Timeout: 30 * time.Second}}
+    pages, err := s.pageURLs(fetcher, s.Location, 0, make(map[string]bool))
+    if err != nil {
+        return nil, err
+    }
+    if s.MaxPages > 0 && len(pages) > s.MaxPages {
+        fmt.Fprintf(os.Stderr, "Warning: sitemap lists %d pages, fetching the first %d\n", len(pages), s.MaxPages)
+        pages = pages[:s.MaxPages]
+    }
+
+    robots := make(map[string]robotsRules)
+    for _, page := range pages {
+        if _, ok := robots[page.Host]; !ok {
+            robots[page.Host] = fetcher.fetchRobots(page)
+        }
+    }
+
+    docs := make([]*SourceDocument, len(pages))
+    var wait sync.WaitGroup
+    slots := make(chan struct{}, s.Concurrency)
+    for i, page := range pages {
+        if !robots[page.Host].allowed(page.EscapedPath()) {
+            continue
+        }
+        wait.Add(1)
+        slots <- struct{}{}
+        go func(i int, page *url.URL) {
+            defer func() { <-slots; wait.Done() }()
+            content, final, err := fetcher.get(page)
+            if err != nil {
+                reportFailure(readFailureRule, page.String(), 0, "%v", err)
+                return
+            }
+            docs[i] = &SourceDocument{Path: final.String(), URL: final.String(), Content: htmlToMarkdown(mainContent(content))}
+        }(i, page)
+    }
+    wait.Wait()
+
+    var fetched []SourceDocument
+    for _, doc := range docs {
+        if doc != nil {
+            fetched = append(fetched, *doc)
+        }
+    }
+    return fetched, nil
+}
+
+// pageURLs returns the page URLs a sitemap lists, following sitemap index
+// entries into the sitemaps they name
+func (s SitemapSource) pageURLs(fetcher *Crawler, location string, depth int, visited map[string]bool) ([]*url.URL, error) {
+    if visited[location] {
+        return nil, nil
+    }
+    visited[location] = true
+
+    data, err := readSitemap(fetcher, location)
+    if err != nil {
+        return nil, err
+    }
+    var sitemap struct {
+        URLs []struct {
+            Loc string `xml:"loc"`
+        } `xml:"url"`
+        Sitemaps []struct {
+            Loc string `xml:"loc"`
+        } `xml:"sitemap"`
+    }
+    if err := xml.Unmarshal(data, &sitemap); err != nil {
+        return nil, fmt.Errorf("failed to parse sitemap %s: %w", location, err)
+    }
+
+    var pages []*url.URL
+    for _, entry := range sitemap.URLs {
+        page, err := url.Parse(strings.TrimSpace(entry.Loc))
+        if err != nil || (page.Scheme != "http" && page.Scheme != "https") {
+            fmt.Fprintf(os.Stderr, "Warning: skipping sitemap entry %q\n", entry.Loc)
+            continue
+        }
+        pages = append(pages, page)
+    }
+    for _, entry := range sitemap.Sitemaps {
+        if depth+1 >= maxSitemapDepth {
+            fmt.Fprintf(os.Stderr, "Warning: skipping sitemap %s nested more than %d deep\n", entry.Loc, maxSitemapDepth)
+            continue
+        }
+        nested, err := s.pageURLs(fetcher, strings.TrimSpace(entry.Loc), depth+1, visited)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+            continue
+        }
+        pages = append(pages, nested...)
+    }
+    return pages, nil
+}
+
+// readSitemap reads a sitemap from a URL or a file, decompressing it if
+// it is gzipped
+func readSitemap(fetcher *Crawler, location string) ([]byte, error) {
+    var data []byte
+    if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+        request, err := http.NewRequest(http.MethodGet, location, nil)
+        if err != nil {
+            return nil, err
+        }
+        request.Header.Set("User-Agent", crawlUserAgent)
+        response, err := fetcher.client.Do(request)
+        if err != nil {
+            return nil, err
+        }
+        defer response.Body.Close()
+        if response.StatusCode != http.StatusOK {
+            return nil, fmt.Errorf("%s returned %s", location, response.Status)
+        }
+        if data, err = io.ReadAll(io.LimitReader(response.Body, maxPageBytes*5)); err != nil {
+            return nil, err
+        }
+    } else {
+        var err error
+        if data, err = os.ReadFile(location); err != nil {
+            return nil, err
+        }
+    }
+
+    if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+        reader, err := gzip.NewReader(bytes.NewReader(data))
+        if err != nil {
+            return nil, fmt.Errorf("failed to decompress sitemap %s: %w", location, err)
+        }
+        defer reader.Close()
+        return io.ReadAll(io.LimitReader(reader, maxPageBytes*5))
+    }
+    return data, nil
+}
@@ -0,0 +1,112 @@
+// Files a run couldn't analyze, classified for the end-of-run summary
+
+package main
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "sort"
+    "strings"
+)
+
+// The reasons a path isn't analyzed, as Issue.Skip
+const (
+    skipTooLarge   = "too-large"         // over -max-file-size
+    skipBinary     = "binary"            // NUL bytes, though its extension says it's a document
+    skipTimeout    = "timeout"           // over -timeout-per-file
+    skipPermission = "permission-denied" // not readable by the user running the tool
+    skipNotFound   = "not-found"         // gone, or a dangling symlink
+    skipUnreadable = "unreadable"        // any other read error
+)
+
+// skipReasons lists the reasons in the order the summary gives them
+var skipReasons = []string{skipTooLarge, skipBinary, skipTimeout, skipPermission, skipNotFound, skipUnreadable}
+
+// readFailureReason classifies a read failure by the error among its
+// message arguments
+func readFailureReason(args []any) string {
+    for _, arg := range args {
+        err, ok := arg.(error)
+        if !ok {
+            continue
+        }
+        switch {
+        case errors.Is(err, fs.ErrPermission):
+            return skipPermission
+        case errors.Is(err, fs.ErrNotExist):
+            return skipNotFound
+        }
+    }
+    return skipUnreadable
+}
+
+// skipTally is what a run left unanalyzed: the skipped and unreadable
+// paths, by reason
+type skipTally struct {
+    issues []Issue // one per path, in order
+}
+
+// tallySkips collects the issues of the paths a run didn't analyze
+func tallySkips(issues []Issue) skipTally {
+    var tally skipTally
+    seen := make(map[string]bool)
+    for _, issue := range issues {
+        if issue.Skip == "" || seen[issue.File] {
+            continue
+        }
+        seen[issue.File] = true
+        tally.issues = append(tally.issues, issue)
+    }
+    sort.SliceStable(tally.issues, func(i, j int) bool { return tally.issues[i].File < tally.issues[j].File })
+    return tally
+}
+
+// counts returns the number of paths skipped for each reason, or nil if
+// none were
+func (t skipTally) counts() map[string]int {
+    if len(t.issues) == 0 {
+        return nil
+    }
+    counts := make(map[string]int)
+    for _, issue := range t.issues {
+        counts[issue.Skip]++
+    }
+    return counts
+}
+
+// print writes the summary line: how many paths were skipped, and why.
+// listed names the -skip-report file, if any.
+func (t skipTally) print(w io.Writer, listed string) {
+    if len(t.issues) == 0 {
+        return
+    }
+    counts := t.counts()
+    var parts []string
+    for _, reason := range skipReasons {
+        if counts[reason] > 0 {
+            parts = append(parts, fmt.Sprintf("%d %s", counts[reason], reason))
+        }
+    }
+    where := "list them with -skip-report"
+    if listed != "" {
+        where = "listed in " + listed
+    }
+    fmt.Fprintf(w, "Skipped: %d path(s) not analyzed: %s (%s)\n", len(t.issues), strings.Join(parts, ", "), where)
+}
+
+// write saves the listing of -skip-report: a tab-separated line of reason,
+// path and message for each path, grouped by reason
+func (t skipTally) write(path string) error {
+    var b strings.Builder
+    for _, reason := range skipReasons {
+        for _, issue := range t.issues {
+            if issue.Skip == reason {
+                fmt.Fprintf(&b, "%s\t%s\t%s\n", reason, issueLocation(issue), issue.Message)
+            }
+        }
+    }
+    return os.WriteFile(path, []byte(b.String()), 0o644)
+}
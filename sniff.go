@@ -0,0 +1,163 @@
+// Document formats: the configured extensions and parsers, and detection
+// from content for files without an extension or with the wrong one
+
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// documentFormats are the formats a document can be parsed as
+var documentFormats = []string{"markdown", "html", "rst"}
+
+// forcedFormat is the format of every document when -force-format is set,
+// whatever its extension or content
+var forcedFormat string
+
+// sniffSize is how much of an extensionless file is read to tell whether
+// it's a document
+const sniffSize = 64 << 10
+
+var (
+    // htmlStartRegex matches the start of an HTML page
+    htmlStartRegex = regexp.MustCompile(`(?i)^\s*(?:<\?xml[^>]*>\s*)?(?:<!doctype\s+html\b|<html\b)`)
+    // markdownSignalRegexes match constructs only Markdown has: front
+    // matter, ATX headings, fences and inline links
+    markdownSignalRegexes = []*regexp.Regexp{
+        regexp.MustCompile(`\A(?:\x{feff})?---\r?\n`),
+        regexp.MustCompile(`(?m)^#{1,6}[ \t]+\S`),
+        regexp.MustCompile("(?m)^[ \t]{0,3}(?:```|~~~)"),
+        regexp.MustCompile(`\[[^\]\n]+\]\([^)\s]+\)`),
+    }
+    // rstSignalRegex matches constructs only reStructuredText has: directives,
+    // link targets, roles and titles with an overline
+    rstSignalRegex = regexp.MustCompile("(?m)^[ \\t]*\\.\\. [\\w:-]+::|^\\.\\. _[^:\\n]+:|:[\\w:]+:`[^`\\n]+`|^[=\\-~^\"'*+#]{3,}\\r?\\n.+\\r?\\n[=\\-~^\"'*+#]{3,}\\r?$")
+)
+
+// minSniffSignals is how many Markdown constructs, or reStructuredText
+// lines, content needs before it's taken for that format
+const minSniffSignals = 2
+
+// isDocumentFormat reports whether documents can be parsed as format
+func isDocumentFormat(format string) bool {
+    for _, known := range documentFormats {
+        if format == known {
+            return true
+        }
+    }
+    return false
+}
+
+// validateFormats checks that each format's extensions start with a dot
+// and that its parser is one documents can be parsed as
+func (c *Config) validateFormats() error {
+    for _, name := range sortedKeys(c.Formats) {
+        format := c.Formats[name]
+        for _, ext := range format.Extensions {
+            if !strings.HasPrefix(ext, ".") || len(ext) < 2 {
+                return fmt.Errorf("invalid extension %q in Formats.%s (want one starting with a dot, such as .mdx)", ext, name)
+            }
+        }
+        if format.Parser != "" && !isDocumentFormat(format.Parser) {
+            return fmt.Errorf("invalid Formats.%s.Parser %q (want %s)", name, format.Parser, strings.Join(documentFormats, ", "))
+        }
+    }
+    return nil
+}
+
+// extensionFormat returns the built-in format path's extension implies,
+// or "" for plain text and unknown extensions
+func extensionFormat(path string) string {
+    switch ext := strings.ToLower(filepath.Ext(path)); {
+    case isHTML(path):
+        return "html"
+    case ext == ".rst":
+        return "rst"
+    case ext == ".md" || ext == ".markdown":
+        return "markdown"
+    }
+    return ""
+}
+
+// sniffFormat returns the format content is unmistakably written in: HTML
+// for a page starting with a doctype or <html>, Markdown or
+// reStructuredText for text with enough of one's constructs and none of
+// the other's. It returns "" when the content doesn't say.
+func sniffFormat(content string) string {
+    if htmlStartRegex.MatchString(content) {
+        return "html"
+    }
+    markdown := 0
+    for _, signal := range markdownSignalRegexes {
+        if signal.MatchString(content) {
+            markdown++
+        }
+    }
+    rst := len(rstSignalRegex.FindAllStringIndex(content, minSniffSignals))
+    switch {
+    case markdown >= minSniffSignals && rst == 0:
+        return "markdown"
+    case rst >= minSniffSignals && markdown == 0:
+        return "rst"
+    }
+    return ""
+}
+
+// detectFormat returns the format to parse a document as: the forced one,
+// the one its content shows when that disagrees with its extension, or
+// else the one its extension implies, Markdown if none
+func detectFormat(path, content string) string {
+    if forcedFormat != "" {
+        return forcedFormat
+    }
+    implied := extensionFormat(path)
+    if sniffed := sniffFormat(content); sniffed != "" && sniffed != implied {
+        return sniffed
+    }
+    if implied == "" {
+        return "markdown"
+    }
+    return implied
+}
+
+// useFormat parses the document as format's Parser, which stands in for
+// the extension, so content that shows another format still wins
+func (d *Document) useFormat(format Format) {
+    if format.Parser != "" && forcedFormat == "" && sniffFormat(d.Content) == "" {
+        d.Format = format.Parser
+    }
+}
+
+// documentFormat returns the format a document at path with content is
+// parsed as, with its configured format's Parser, before parsing it
+func (c *Config) documentFormat(path, content string) string {
+    doc := &Document{Path: path, Content: content, Format: detectFormat(path, content)}
+    if format, ok := c.formatFor(path); ok {
+        doc.useFormat(format)
+    }
+    return doc.Format
+}
+
+// isSniffedDocument reports whether a file without an extension is a
+// document, by the start of its content. Binary files never are.
+func isSniffedDocument(path string) bool {
+    if filepath.Ext(path) != "" {
+        return false
+    }
+    file, err := os.Open(path)
+    if err != nil {
+        return false
+    }
+    defer file.Close()
+    head, err := io.ReadAll(io.LimitReader(file, sniffSize))
+    if err != nil || bytes.IndexByte(head, 0) >= 0 {
+        return false
+    }
+    return sniffFormat(string(head)) != ""
+}
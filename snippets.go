@@ -0,0 +1,261 @@
+// Syntax validation of fenced code examples
+
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "go/parser"
+    "go/scanner"
+    "go/token"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+var (
+    yamlErrorLineRegex = regexp.MustCompile(`line (\d+)`)
+    goTopLevelRegex    = regexp.MustCompile(`(?m)^(?:func|type|import|var|const)\b`)
+    placeholderRegex   = regexp.MustCompile(`(?m)^\s*(?:\.\.\.|…|// \.\.\.|# \.\.\.)\s*,?\s*$|\.\.\.\s*[}\]]`)
+    shellKeywordRegex  = regexp.MustCompile(`(?:^|[;&|(]\s*|\b(?:then|do|else)\s+)(if|case|do|done|fi|esac)\b`)
+)
+
+// snippetLanguages maps fence info strings to the validator that checks them
+var snippetLanguages = map[string]string{
+    "json":    "json",
+    "yaml":    "yaml",
+    "yml":     "yaml",
+    "sh":      "shell",
+    "bash":    "shell",
+    "shell":   "shell",
+    "zsh":     "shell",
+    "console": "shell",
+    "go":      "go",
+    "golang":  "go",
+}
+
+// analyzeSnippets reports code examples in the languages listed under
+// ValidateSnippets that don't parse. Blocks elided with "..." are skipped.
+func (a *Analyzer) analyzeSnippets(doc *Document) []Issue {
+    if len(a.config.ValidateSnippets) == 0 {
+        return nil
+    }
+
+    var issues []Issue
+    for _, block := range doc.CodeBlocks {
+        lang := snippetLanguages[block.Lang]
+        if lang == "" || !contains(a.config.ValidateSnippets, lang) {
+            continue
+        }
+        code := doc.Code(block)
+        if strings.TrimSpace(code) == "" || placeholderRegex.MatchString(code) {
+            continue
+        }
+
+        var line int
+        var err error
+        switch lang {
+        case "json":
+            line, err = validateJSON(code)
+        case "yaml":
+            line, err = validateYAML(code)
+        case "shell":
+            line, err = validateShell(code)
+        case "go":
+            line, err = validateGo(code)
+        }
+        if err == nil {
+            continue
+        }
+
+        lineNum := block.StartLine + max(line, 1)
+        issues = append(issues, Issue{
+            File:         doc.Path,
+            Line:         lineNum,
+            Column:       1,
+            Rule:         "invalid-code-snippet",
+            Message:      fmt.Sprintf("%s example does not parse: %v", block.Lang, err),
+            Severity:     "error",
+            Suggestion:   "Fix the example so readers and AI assistants don't copy broken code",
+            OriginalText: strings.TrimSpace(doc.Lines[min(lineNum, len(doc.Lines))-1]),
+        })
+    }
+
+    return issues
+}
+
+// validateJSON returns the line of the first syntax error in code
+func validateJSON(code string) (int, error) {
+    var value interface{}
+    err := json.Unmarshal([]byte(code), &value)
+    if syntaxErr, ok := err.(*json.SyntaxError); ok {
+        return strings.Count(code[:syntaxErr.Offset], "\n") + 1, err
+    }
+    return 0, err
+}
+
+// validateYAML parses every document in code
+func validateYAML(code string) (int, error) {
+    decoder := yaml.NewDecoder(strings.NewReader(code))
+    for {
+        var value interface{}
+        err := decoder.Decode(&value)
+        if err == nil {
+            continue
+        }
+        if err.Error() == "EOF" {
+            return 0, nil
+        }
+        line := 0
+        if match := yamlErrorLineRegex.FindStringSubmatch(err.Error()); match != nil {
+            line, _ = strconv.Atoi(match[1])
+        }
+        return line, fmt.Errorf("%s", strings.TrimPrefix(err.Error(), "yaml: "))
+    }
+}
+
+// validateGo parses code as a file, as top-level declarations, or as
+// statements, accepting whichever form fits the example
+func validateGo(code string) (int, error) {
+    fset := token.NewFileSet()
+    if _, err := parser.ParseFile(fset, "", code, parser.AllErrors); err == nil {
+        return 0, nil
+    } else if strings.HasPrefix(strings.TrimSpace(code), "package ") {
+        return goErrorLine(err, 0)
+    }
+
+    if _, err := parser.ParseFile(fset, "", "package snippet\n"+code, parser.AllErrors); err == nil {
+        return 0, nil
+    } else if goTopLevelRegex.MatchString(code) {
+        return goErrorLine(err, 1)
+    }
+
+    _, err := parser.ParseFile(fset, "", "package snippet\nfunc _() {\n"+code+"\n}", parser.AllErrors)
+    if err == nil {
+        return 0, nil
+    }
+    return goErrorLine(err, 2)
+}
+
+// goErrorLine returns the first parse error and its line in the original
+// code, given how many wrapper lines preceded it
+func goErrorLine(err error, offset int) (int, error) {
+    if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+        return list[0].Pos.Line - offset, fmt.Errorf("%s", list[0].Msg)
+    }
+    return 0, err
+}
+
+// validateShell checks the structure a shell parser would reject: unclosed
+// quotes, substitutions and brackets, and unbalanced if/case/loop keywords.
+// Lines of console output after a prompt are ignored.
+func validateShell(code string) (int, error) {
+    lines := strings.Split(code, "\n")
+    prompted := false
+    for _, line := range lines {
+        if shellPromptRegex.MatchString(strings.TrimSpace(line)) {
+            prompted = true
+            break
+        }
+    }
+
+    type opener struct {
+        text string
+        line int
+    }
+    var stack []opener
+    closes := map[string]string{"fi": "if", "esac": "case", "done": "do", ")": "(", "}": "{"}
+    quote, quoteLine := byte(0), 0
+    continued := false
+
+    for i, line := range lines {
+        trimmed := strings.TrimSpace(line)
+        if prompted && !continued && quote == 0 && len(stack) == 0 {
+            if !shellPromptRegex.MatchString(trimmed) {
+                continue
+            }
+            trimmed = shellPromptRegex.ReplaceAllString(trimmed, "")
+        }
+        continued = strings.HasSuffix(trimmed, "\\")
+
+        for j := 0; j < len(trimmed); j++ {
+            c := trimmed[j]
+            switch {
+            case quote != 0:
+                if c == '\\' && quote == '"' {
+                    j++
+                } else if c == quote {
+                    quote = 0
+                }
+                continue
+            case c == '\\':
+                j++
+                continue
+            case c == '#' && (j == 0 || trimmed[j-1] == ' ' || trimmed[j-1] == '\t'):
+                j = len(trimmed)
+                continue
+            case c == '\'' || c == '"':
+                quote, quoteLine = c, i+1
+            case c == '(' || c == '{':
+                stack = append(stack, opener{string(c), i + 1})
+            case c == ')' || c == '}':
+                if len(stack) > 0 && stack[len(stack)-1].text == "case" && c == ')' {
+                    continue // case pattern
+                }
+                if len(stack) == 0 || stack[len(stack)-1].text != closes[string(c)] {
+                    return i + 1, fmt.Errorf("unexpected '%c'", c)
+                }
+                stack = stack[:len(stack)-1]
+            }
+        }
+
+        for _, match := range shellKeywordRegex.FindAllStringSubmatch(stripShellQuotes(trimmed), -1) {
+            switch keyword := match[1]; keyword {
+            case "if", "case", "do":
+                stack = append(stack, opener{keyword, i + 1})
+            case "fi", "esac", "done":
+                if len(stack) == 0 || stack[len(stack)-1].text != closes[keyword] {
+                    return i + 1, fmt.Errorf("unexpected '%s'", keyword)
+                }
+                stack = stack[:len(stack)-1]
+            }
+        }
+    }
+
+    if quote != 0 {
+        return quoteLine, fmt.Errorf("unterminated %c quote", quote)
+    }
+    if len(stack) > 0 {
+        open := stack[len(stack)-1]
+        return open.line, fmt.Errorf("'%s' is never closed", open.text)
+    }
+    if continued {
+        return len(lines), fmt.Errorf("last line ends with a line continuation")
+    }
+    return 0, nil
+}
+
+// stripShellQuotes removes quoted strings and comments so keywords inside
+// them aren't counted
+func stripShellQuotes(line string) string {
+    var out strings.Builder
+    quote := byte(0)
+    for i := 0; i < len(line); i++ {
+        c := line[i]
+        switch {
+        case quote != 0:
+            if c == quote {
+                quote = 0
+            }
+        case c == '\'' || c == '"':
+            quote = c
+        case c == '#' && (i == 0 || line[i-1] == ' '):
+            return out.String()
+        default:
+            out.WriteByte(c)
+        }
+    }
+    return out.String()
+}
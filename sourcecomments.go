@@ -0,0 +1,348 @@
+// Doc comments of Go, Python and JavaScript/TypeScript source files
+
+package main
+
+import (
+    "fmt"
+    "path/filepath"
+    "regexp"
+    "strings"
+)
+
+// embeddedText is documentation embedded in a file that isn't prose, such
+// as a doc comment or a schema description, split into lines with the
+// position in the file each starts at
+type embeddedText struct {
+    coordinate string // what it documents, such as "Analyzer.AnalyzeFile" or "User.email"
+    lines      []string
+    positions  [][2]int // line and 0-based byte column of each line
+    offsets    [][]int  // column of each byte of each line, for text that isn't a contiguous slice of its line
+}
+
+// sourceLanguages map source file extensions to the language of their doc
+// comments
+var sourceLanguages = map[string]string{
+    ".go": "go", ".py": "python",
+    ".js": "javascript", ".jsx": "javascript", ".mjs": "javascript", ".cjs": "javascript", ".ts": "javascript", ".tsx": "javascript",
+}
+
+var (
+    pythonDefRegex       = regexp.MustCompile(`^(\s*)(?:async\s+)?(?:def|class)\s+(\w+)`)
+    pythonDocstringRegex = regexp.MustCompile(`^(\s*)[rRuU]?("""|''')`)
+    pythonPreambleRegex  = regexp.MustCompile(`^\s*(?:#.*)?$`)
+    jsDocRegex           = regexp.MustCompile(`(?s)/\*\*(?:[^*]|\*[^/]).*?\*/`)
+    jsDocLineRegex       = regexp.MustCompile(`^\s*\*(?: |$)?`)
+    jsDocParamRegex      = regexp.MustCompile(`^@(?:param|arg|argument|property|prop)\s+(?:\{[^}]*\}\s*)?\[?[\w.$]+(?:=[^\]]*)?\]?\s*(?:-\s*)?`)
+    jsDocReturnsRegex    = regexp.MustCompile(`^@(?:returns?|throws|deprecated|description|summary)\s*(?:\{[^}]*\}\s*)?(?:-\s*)?`)
+    jsClassRegex         = regexp.MustCompile(`(?m)^(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(\w+)`)
+    jsDeclarationRegex   = regexp.MustCompile(`^(\s*)(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:abstract\s+)?(?:async\s+)?(?:function\*?\s*(\w+)|(class)\s+(\w+)|(?:interface|type|enum)\s+(\w+)|(?:const|let|var)\s+(\w+)|(?:(?:public|private|protected|static|readonly|get|set)\s+)*#?(\w+)\s*[(:=?<])`)
+    sourceTestFileRegex  = regexp.MustCompile(`(?:_test\.go|^test_.*\.py|_test\.py|\.(?:test|spec)\.[cm]?[jt]sx?)$`)
+)
+
+// sourceLanguage returns the doc comment language of a source file, or ""
+// for files that aren't source code
+func sourceLanguage(path string) string {
+    return sourceLanguages[strings.ToLower(filepath.Ext(path))]
+}
+
+// analyzeEmbedded runs the line, sentence and multiline rules over each
+// piece of embedded documentation, reporting issues at their position in
+// the file with a message that leads with what they document. The
+// document-level checks are about pages and don't apply.
+func (a *Analyzer) analyzeEmbedded(filePath string, texts []embeddedText) []Issue {
+    var issues []Issue
+    for _, text := range texts {
+        doc := ParseDocument(filePath, strings.Join(text.lines, "\n"))
+        lang := a.language(doc)
+        var found []Issue
+        for i, line := range doc.Masked {
+            found = append(found, a.analyzeLine(doc, line, i+1, lang, nil)...)
+        }
+        found = append(found, a.analyzeSentences(doc, lang, nil)...)
+        found = append(found, a.analyzeMultiline(doc, lang, nil)...)
+
+        for _, issue := range found {
+            if issue.Line < 1 || issue.Line > len(text.positions) {
+                continue
+            }
+            line, column := text.position(issue.Line-1, issue.Column-1)
+            issue.Line, issue.Column = line, column+1
+            if issue.Fix != nil {
+                issue.Fix.Line, issue.Fix.Column = issue.Line, issue.Column
+            }
+            if text.coordinate != "" {
+                issue.Message = fmt.Sprintf("%s: %s", text.coordinate, issue.Message)
+            }
+            issues = append(issues, issue)
+        }
+    }
+    return issues
+}
+
+// analyzeSourceComments analyzes the doc comments of a source file
+func (a *Analyzer) analyzeSourceComments(filePath, content string) []Issue {
+    var comments []embeddedText
+    switch sourceLanguage(filePath) {
+    case "go":
+        var err error
+        if comments, err = goDocComments(filePath, content); err != nil {
+            return []Issue{goParseFailure(filePath, err)}
+        }
+    case "python":
+        comments = pythonDocstrings(content)
+    case "javascript":
+        comments = jsDocComments(content)
+    }
+    issues := a.filterPageTypes(a.analyzeEmbedded(filePath, comments), "reference")
+    return a.applyOverrides(a.convertColumns(ParseDocument(filePath, content), issues))
+}
+
+// dedentedText returns the lines of a block string or docstring, whose
+// first line starts at line and column, without the common indentation of
+// the lines after the first and without leading and trailing blank lines,
+// as GraphQL and PEP 257 specify
+func dedentedText(raw string, line, column int) embeddedText {
+    var text embeddedText
+    lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+    indent := -1
+    for _, l := range lines[1:] {
+        if trimmed := strings.TrimLeft(l, " \t"); trimmed != "" && (indent < 0 || len(l)-len(trimmed) < indent) {
+            indent = len(l) - len(trimmed)
+        }
+    }
+    for k, l := range lines {
+        start := column
+        if k > 0 {
+            start = min(max(indent, 0), len(l))
+            l = l[start:]
+        }
+        text.lines = append(text.lines, l)
+        text.positions = append(text.positions, [2]int{line + k, start})
+    }
+    return text.trimmed()
+}
+
+// trimmed drops leading and trailing blank lines
+func (t embeddedText) trimmed() embeddedText {
+    for len(t.lines) > 0 && strings.TrimSpace(t.lines[0]) == "" {
+        t.lines, t.positions = t.lines[1:], t.positions[1:]
+        if len(t.offsets) > 0 {
+            t.offsets = t.offsets[1:]
+        }
+    }
+    for n := len(t.lines); n > 0 && strings.TrimSpace(t.lines[n-1]) == ""; n-- {
+        t.lines, t.positions = t.lines[:n-1], t.positions[:n-1]
+        if len(t.offsets) > 0 {
+            t.offsets = t.offsets[:n-1]
+        }
+    }
+    return t
+}
+
+// position returns the line and 0-based byte column in the file of byte i
+// of line k
+func (t embeddedText) position(k, i int) (int, int) {
+    line, column := t.positions[k][0], t.positions[k][1]+i
+    if k < len(t.offsets) && len(t.offsets[k]) > 0 {
+        last := len(t.offsets[k]) - 1
+        column = t.offsets[k][min(i, last)] + max(i-last, 0)
+    }
+    return line, column
+}
+
+// pythonDocstrings returns the docstrings of a Python file: the string
+// that opens the module, or the body of a class or function. Nested
+// definitions are named by their path, such as "Client.fetch".
+func pythonDocstrings(content string) []embeddedText {
+    lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+    var docstrings []embeddedText
+    // docstring reads the docstring starting on line i, if there is one
+    docstring := func(i int, coordinate string) {
+        for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+            i++
+        }
+        if i >= len(lines) {
+            return
+        }
+        match := pythonDocstringRegex.FindStringSubmatchIndex(lines[i])
+        if match == nil {
+            return
+        }
+        quote, column := lines[i][match[4]:match[5]], match[5]
+        rest := lines[i][column:]
+        var raw []string
+        for j := i; j < len(lines); j++ {
+            if end := strings.Index(rest, quote); end >= 0 {
+                raw = append(raw, rest[:end])
+                text := dedentedText(strings.Join(raw, "\n"), i+1, column)
+                text.coordinate = coordinate
+                docstrings = append(docstrings, text)
+                return
+            }
+            raw = append(raw, rest)
+            if j+1 < len(lines) {
+                rest = lines[j+1]
+            }
+        }
+    }
+
+    // The module docstring follows only blank lines, comments and a shebang
+    for i, line := range lines {
+        if !pythonPreambleRegex.MatchString(line) {
+            docstring(i, "module")
+            break
+        }
+    }
+
+    type scope struct {
+        indent int
+        name   string
+    }
+    var scopes []scope
+    for i, line := range lines {
+        match := pythonDefRegex.FindStringSubmatch(line)
+        if match == nil {
+            continue
+        }
+        indent := len(match[1])
+        for len(scopes) > 0 && scopes[len(scopes)-1].indent >= indent {
+            scopes = scopes[:len(scopes)-1]
+        }
+        var names []string
+        for _, s := range scopes {
+            names = append(names, s.name)
+        }
+        coordinate := strings.Join(append(names, match[2]), ".")
+        scopes = append(scopes, scope{indent, match[2]})
+
+        // The body starts after the line that ends the signature, where its
+        // brackets close
+        depth := 0
+        for end := i; end < len(lines); end++ {
+            code := strings.TrimSpace(stripPythonComment(lines[end]))
+            depth += strings.Count(code, "(") + strings.Count(code, "[") - strings.Count(code, ")") - strings.Count(code, "]")
+            if depth <= 0 {
+                if strings.HasSuffix(code, ":") {
+                    docstring(end+1, coordinate)
+                }
+                break
+            }
+        }
+    }
+    return docstrings
+}
+
+// stripPythonComment removes a trailing # comment from a line of code,
+// ignoring # inside quotes
+func stripPythonComment(line string) string {
+    var quote rune
+    for i, r := range line {
+        switch {
+        case quote != 0 && r == quote:
+            quote = 0
+        case quote == 0 && (r == '"' || r == '\''):
+            quote = r
+        case quote == 0 && r == '#':
+            return line[:i]
+        }
+    }
+    return line
+}
+
+// jsDocComments returns the /** JSDoc */ comments of a JavaScript or
+// TypeScript file, named by the declaration that follows, as in
+// "Client.fetch" for a method. The descriptions of @param, @returns and
+// @deprecated tags are kept; other tags, and @example code, are left out.
+func jsDocComments(content string) []embeddedText {
+    lineStarts := []int{0}
+    for i, c := range content {
+        if c == '\n' {
+            lineStarts = append(lineStarts, i+1)
+        }
+    }
+    // The comments come in order, so the line and the last class declared
+    // above each are found by moving on from the previous comment's
+    classes := jsClassRegex.FindAllStringSubmatchIndex(content, -1)
+    line, class := 0, 0
+
+    var comments []embeddedText
+    for _, match := range jsDocRegex.FindAllStringIndex(content, -1) {
+        for line+1 < len(lineStarts) && lineStarts[line+1] <= match[0] {
+            line++
+        }
+        for class < len(classes) && classes[class][1] <= match[0] {
+            class++
+        }
+        var text embeddedText
+        example := false
+        for k, raw := range strings.Split(content[match[0]+3:match[1]-2], "\n") {
+            raw = strings.TrimSuffix(raw, "\r")
+            column := match[0] + 3 - lineStarts[line] // the first line starts after "/**"
+            body := raw
+            if k > 0 {
+                column = len(jsDocLineRegex.FindString(raw))
+                body = raw[column:]
+            }
+            if strings.HasPrefix(strings.TrimSpace(body), "@") {
+                lead := len(body) - len(strings.TrimLeft(body, " \t"))
+                body, column = body[lead:], column+lead
+                tag := jsDocParamRegex.FindString(body)
+                if tag == "" {
+                    tag = jsDocReturnsRegex.FindString(body)
+                }
+                example = tag == ""
+                if example {
+                    body = ""
+                } else {
+                    body, column = body[len(tag):], column+len(tag)
+                }
+            } else if example {
+                body = ""
+            }
+            text.lines = append(text.lines, body)
+            text.positions = append(text.positions, [2]int{line + k + 1, column})
+        }
+
+        // The declaration on the next line with code names the comment
+        coordinate := "comment"
+        for rest := content[match[1]:]; rest != ""; {
+            var next string
+            next, rest, _ = strings.Cut(rest, "\n")
+            if strings.TrimSpace(next) == "" {
+                continue
+            }
+            if declaration := jsDeclarationRegex.FindStringSubmatch(next); declaration != nil {
+                for _, name := range declaration[2:] {
+                    if name != "" && name != "class" {
+                        coordinate = name
+                        break
+                    }
+                }
+                // An indented member belongs to the last class declared above
+                if declaration[1] != "" && declaration[7] != "" && class > 0 {
+                    last := classes[class-1]
+                    coordinate = content[last[2]:last[3]] + "." + declaration[7]
+                }
+            }
+            break
+        }
+        if text = text.trimmed(); len(text.lines) > 0 {
+            text.coordinate = coordinate
+            comments = append(comments, text)
+        }
+    }
+    return comments
+}
+
+// hasEmbeddedDocs reports whether a file is code or a schema whose only
+// prose is its doc comments or descriptions, which the cross-file checks
+// and export leave out
+func hasEmbeddedDocs(path string) bool {
+    return isGraphQLSchema(path) || sourceLanguage(path) != ""
+}
+
+// isSourceTest reports whether a source file holds tests, whose comments
+// aren't documentation anyone reads
+func isSourceTest(path string) bool {
+    return sourceTestFileRegex.MatchString(filepath.Base(path))
+}
@@ -0,0 +1,135 @@
+// Analyzing documents fetched from remote sources
+
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+)
+
+// SourceDocument is a page fetched from outside the filesystem, converted
+// to Markdown
+type SourceDocument struct {
+    Path    string // names the document in issues, e.g. "Install guide [123456]"
+    URL     string // where a reader can open the document
+    Content string
+}
+
+// sourceFlags are the options shared by the subcommands that analyze a
+// remote source
+type sourceFlags struct {
+    command      string
+    location     string // where the documents come from, recorded as the run's path
+    configPath   *string
+    outputFormat *string
+    maxPerRule   *int
+    maxPerFile   *int
+    dbPath       *string
+    strictConfig *bool
+}
+
+func addSourceFlags(flags *flag.FlagSet) sourceFlags {
+    return sourceFlags{
+        command:      flags.Name(),
+        configPath:   flags.String("config", "", "Path to configuration file"),
+        outputFormat: flags.String("output", "standard", "Output format (standard, short, json, sections, sarif, codequality, or a configured or external formatter)"),
+        maxPerRule:   flags.Int("max-issues-per-rule", 0, "Report at most this many issues of one rule in one page, summarizing the rest (0 for no limit)"),
+        maxPerFile:   flags.Int("max-issues-per-file", 0, "Report at most this many issues in one page, summarizing the rest (0 for no limit)"),
+        dbPath:       flags.String("db", "", "Record the run and its issues in this SQLite results database, using the sqlite3 command"),
+        strictConfig: flags.Bool("strict-config", false, "Fail instead of skipping rules that are invalid"),
+    }
+}
+
+// run fetches documents, analyzes them and reports their issues like the
+// main command does for files, returning the exit status
+func (f sourceFlags) run(fetch func() ([]SourceDocument, error)) int {
+    return f.runWith(func(*Analyzer) ([]SourceDocument, error) { return fetch() })
+}
+
+// runWith is run for a source that fetches by the analyzer's configuration
+func (f sourceFlags) runWith(fetch func(*Analyzer) ([]SourceDocument, error)) int {
+    failures = newFailureLog()
+    analyzer, err := NewAnalyzer(*f.configPath)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error creating analyzer: %v\n", err)
+        return 1
+    }
+    if *f.strictConfig {
+        if err := analyzer.strictRules(); err != nil {
+            fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+            return 1
+        }
+    }
+    formatter, err := formatterFor(*f.outputFormat, analyzer.config.Formatters)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        return 1
+    }
+
+    run := newRunMetadata(f.command, analyzer, []string{f.location})
+    docs, err := fetch(analyzer)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error fetching documents: %v\n", err)
+        return 1
+    }
+
+    issues := append(analyzer.analyzeSources(docs), failures.drain()...)
+    var paths []string
+    for _, doc := range docs {
+        paths = append(paths, doc.Path)
+    }
+    run.countFiles(paths, issues)
+    sortIssues(issues)
+    recorded := issues // every issue, not just those the caps keep
+    issues = IssueCaps{PerRule: *f.maxPerRule, PerFile: *f.maxPerFile, Severities: analyzer.severities}.apply(issues)
+    sortIssues(issues)
+    analyzer.config.linkRuleDocs(issues)
+    run.finish()
+    currentRun = run
+    if err := formatter.Format(os.Stdout, issues); err != nil {
+        fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+        return 1
+    }
+    if *f.dbPath != "" || analyzer.config.Webhook.url() != "" {
+        var paths []string
+        words := make(map[string]int)
+        for _, doc := range docs {
+            paths = append(paths, doc.Path)
+            words[doc.Path] = wordCount(doc.Content)
+        }
+        if err := reportRun(*f.dbPath, analyzer.config.Webhook, run, paths, words, recorded); err != nil {
+            fmt.Fprintf(os.Stderr, "Error recording results: %v\n", err)
+            return 1
+        }
+    }
+    if analyzer.severities.fails(issues) {
+        return 1
+    }
+    return 0
+}
+
+// analyzeSources analyzes fetched documents one by one and then together,
+// attaching each document's URL to its issues
+func (a *Analyzer) analyzeSources(sources []SourceDocument) []Issue {
+    var issues []Issue
+    var docs []*Document
+    urls := make(map[string]string)
+    for _, source := range sources {
+        issues = append(issues, a.analyzeContent(source.Path, source.Content)...)
+        urls[source.Path] = source.URL
+        if !hasEmbeddedDocs(source.Path) {
+            doc := ParseDocument(source.Path, source.Content)
+            if format, ok := a.config.formatFor(source.Path); ok {
+                doc.useFormat(format)
+            }
+            docs = append(docs, doc)
+        }
+    }
+    issues = append(issues, a.analyzeCorpusDocuments(docs, CorpusOptions{})...)
+
+    for i := range issues {
+        issues[i].URL = urls[issues[i].File]
+    }
+    return issues
+}
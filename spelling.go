@@ -0,0 +1,313 @@
+// Spell checking against hunspell-compatible and project dictionaries
+
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+var (
+    spellWordRegex  = regexp.MustCompile(`[\p{L}][\p{L}']*[\p{L}]|[\p{L}]`)
+    inlineCodeRegex = regexp.MustCompile("`[^`]*`")
+    urlRegex        = regexp.MustCompile(`(?i)\b(?:https?|ftp)://\S+|\]\([^)]*\)|<[^>]+>`)
+    acronymRegex    = regexp.MustCompile(`^[\p{Lu}\d]+s?$`)
+    mixedCaseRegex  = regexp.MustCompile(`\p{Ll}\p{Lu}`)
+)
+
+// SpellCheckConfig enables the spelling pass
+type SpellCheckConfig struct {
+    Dictionaries      []string `yaml:"Dictionaries"`      // hunspell base paths, without .dic/.aff
+    ProjectDictionary string   `yaml:"ProjectDictionary"` // one accepted word per line
+}
+
+// SpellChecker holds the set of accepted words, with hunspell affixes expanded
+type SpellChecker struct {
+    words map[string]bool
+}
+
+// affixRule is one hunspell PFX/SFX entry
+type affixRule struct {
+    prefix    bool
+    strip     string
+    add       string
+    condition *regexp.Regexp
+}
+
+// newSpellChecker loads the configured dictionaries, or returns nil when
+// spell checking isn't configured
+func newSpellChecker(config SpellCheckConfig) (*SpellChecker, error) {
+    if len(config.Dictionaries) == 0 && config.ProjectDictionary == "" {
+        return nil, nil
+    }
+
+    checker := &SpellChecker{words: make(map[string]bool)}
+    for _, base := range config.Dictionaries {
+        base = strings.TrimSuffix(strings.TrimSuffix(base, ".dic"), ".aff")
+        if err := checker.loadHunspell(base); err != nil {
+            return nil, fmt.Errorf("failed to load dictionary %s: %w", base, err)
+        }
+    }
+    if config.ProjectDictionary != "" {
+        if err := checker.loadWordList(config.ProjectDictionary); err != nil {
+            return nil, fmt.Errorf("failed to load project dictionary: %w", err)
+        }
+    }
+
+    return checker, nil
+}
+
+// loadHunspell reads base.dic and, if present, the prefix and suffix rules
+// of base.aff. Other affix features (compounding, replacement tables) are ignored.
+func (s *SpellChecker) loadHunspell(base string) error {
+    flagMode := "char"
+    rules := make(map[string][]affixRule)
+
+    if data, err := os.ReadFile(base + ".aff"); err == nil {
+        for _, line := range strings.Split(string(data), "\n") {
+            fields := strings.Fields(line)
+            if len(fields) >= 2 && fields[0] == "FLAG" {
+                flagMode = fields[1]
+            }
+            if len(fields) < 5 || (fields[0] != "PFX" && fields[0] != "SFX") {
+                continue
+            }
+
+            strip := fields[2]
+            if strip == "0" {
+                strip = ""
+            }
+            add := strings.SplitN(fields[3], "/", 2)[0]
+            if add == "0" {
+                add = ""
+            }
+
+            condition := fields[4]
+            if condition == "." {
+                condition = ""
+            }
+            if fields[0] == "PFX" {
+                condition = "^" + condition
+            } else {
+                condition += "$"
+            }
+            pattern, err := regexp.Compile(condition)
+            if err != nil {
+                continue
+            }
+
+            rules[fields[1]] = append(rules[fields[1]], affixRule{
+                prefix:    fields[0] == "PFX",
+                strip:     strip,
+                add:       add,
+                condition: pattern,
+            })
+        }
+    } else if !os.IsNotExist(err) {
+        return err
+    }
+
+    file, err := os.Open(base + ".dic")
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    scanner := bufio.NewScanner(file)
+    first := true
+    for scanner.Scan() {
+        line := strings.TrimSpace(scanner.Text())
+        if first {
+            first = false
+            if _, err := strconv.Atoi(line); err == nil {
+                continue
+            }
+        }
+        if line == "" {
+            continue
+        }
+
+        word, flags := line, ""
+        if i := strings.Index(line, "/"); i >= 0 {
+            word, flags = line[:i], line[i+1:]
+        }
+        if i := strings.IndexAny(flags, " \t"); i >= 0 {
+            flags = flags[:i]
+        }
+
+        s.add(word)
+        for _, flag := range splitFlags(flags, flagMode) {
+            for _, rule := range rules[flag] {
+                if form, ok := rule.apply(word); ok {
+                    s.add(form)
+                }
+            }
+        }
+    }
+
+    return scanner.Err()
+}
+
+// splitFlags splits a hunspell flag string according to the FLAG mode
+func splitFlags(flags, mode string) []string {
+    switch mode {
+    case "long":
+        var result []string
+        for i := 0; i+1 < len(flags); i += 2 {
+            result = append(result, flags[i:i+2])
+        }
+        return result
+    case "num":
+        return strings.Split(flags, ",")
+    default:
+        var result []string
+        for _, r := range flags {
+            result = append(result, string(r))
+        }
+        return result
+    }
+}
+
+// apply derives the affixed form of word, if the rule's condition allows it
+func (r affixRule) apply(word string) (string, bool) {
+    if !r.condition.MatchString(word) {
+        return "", false
+    }
+    if r.prefix {
+        if !strings.HasPrefix(word, r.strip) {
+            return "", false
+        }
+        return r.add + strings.TrimPrefix(word, r.strip), true
+    }
+    if !strings.HasSuffix(word, r.strip) {
+        return "", false
+    }
+    return strings.TrimSuffix(word, r.strip) + r.add, true
+}
+
+// loadWordList adds one word per line, ignoring blank lines and '#' comments
+func (s *SpellChecker) loadWordList(path string) error {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return err
+    }
+    for _, line := range strings.Split(string(data), "\n") {
+        if word := strings.TrimSpace(line); word != "" && !strings.HasPrefix(word, "#") {
+            s.add(word)
+        }
+    }
+    return nil
+}
+
+// add accepts word in its given and lowercase forms
+func (s *SpellChecker) add(word string) {
+    s.words[word] = true
+    s.words[strings.ToLower(word)] = true
+}
+
+// known reports whether word, or its lowercase form, is in the dictionary
+func (s *SpellChecker) known(word string) bool {
+    return s.words[word] || s.words[strings.ToLower(word)] || s.words[strings.Trim(strings.ToLower(word), "'")]
+}
+
+// suggest returns the closest dictionary word within edit distance 2, or ""
+// for words too short for a distance-based guess to mean anything
+func (s *SpellChecker) suggest(word string) string {
+    lower := strings.ToLower(word)
+    best, bestDistance := "", 3
+    if len([]rune(lower)) < 4 {
+        return ""
+    }
+
+    for candidate := range s.words {
+        if candidate != strings.ToLower(candidate) || candidate == "" || candidate[0] != lower[0] {
+            continue
+        }
+        if diff := len(candidate) - len(lower); diff > 2 || diff < -2 {
+            continue
+        }
+        if distance := editDistance(lower, candidate); distance < bestDistance || distance == bestDistance && candidate < best {
+            best, bestDistance = candidate, distance
+        }
+    }
+
+    return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+    ra, rb := []rune(a), []rune(b)
+    previous := make([]int, len(rb)+1)
+    current := make([]int, len(rb)+1)
+    for j := range previous {
+        previous[j] = j
+    }
+
+    for i := 1; i <= len(ra); i++ {
+        current[0] = i
+        for j := 1; j <= len(rb); j++ {
+            cost := 1
+            if ra[i-1] == rb[j-1] {
+                cost = 0
+            }
+            current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+        }
+        previous, current = current, previous
+    }
+
+    return previous[len(rb)]
+}
+
+// analyzeSpelling reports words missing from the configured dictionaries,
+// skipping code, URLs, acronyms and camelCase identifiers
+func (a *Analyzer) analyzeSpelling(doc *Document) []Issue {
+    if a.spelling == nil {
+        return nil
+    }
+
+    var issues []Issue
+    for i, line := range doc.Masked {
+        if doc.Fenced[i] || i < doc.BodyStart-1 {
+            continue
+        }
+
+        line = blankMatches(inlineCodeRegex, line)
+        line = blankMatches(urlRegex, line)
+
+        for _, match := range spellWordRegex.FindAllStringIndex(line, -1) {
+            word := line[match[0]:match[1]]
+            if len([]rune(word)) < 2 || acronymRegex.MatchString(word) || mixedCaseRegex.MatchString(word) || a.spelling.known(word) {
+                continue
+            }
+
+            suggestion := "Fix the spelling or add the term to the project dictionary"
+            if alternative := a.spelling.suggest(word); alternative != "" {
+                suggestion = fmt.Sprintf("Did you mean '%s'? Otherwise add the term to the project dictionary", alternative)
+            }
+
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         i + 1,
+                Column:       match[0] + 1,
+                Rule:         "spelling",
+                Message:      fmt.Sprintf("Unknown word '%s'", word),
+                Severity:     "warning",
+                Suggestion:   suggestion,
+                OriginalText: word,
+            })
+        }
+    }
+
+    return issues
+}
+
+// blankMatches replaces every match of pattern in line with spaces of equal width
+func blankMatches(pattern *regexp.Regexp, line string) string {
+    return pattern.ReplaceAllStringFunc(line, func(match string) string {
+        return strings.Repeat(" ", len(match))
+    })
+}
@@ -0,0 +1,141 @@
+// Tabbed and conditional content detection
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var (
+    mkdocsTabRegex    = regexp.MustCompile(`^\s*===\+?\s+"([^"]+)"`)
+    sphinxTabRegex    = regexp.MustCompile(`^\s*\.\.\s+(?:group-)?tab::\s*(.+?)\s*$`)
+    tabItemOpenRegex  = regexp.MustCompile(`<TabItem\b[^>]*>`)
+    tabItemCloseRegex = regexp.MustCompile(`</TabItem\s*>`)
+    tabItemLabelRegex = regexp.MustCompile(`\blabel\s*=\s*["']([^"']+)`)
+    tabItemValueRegex = regexp.MustCompile(`\bvalue\s*=\s*["']([^"']+)`)
+    hugoTabOpenRegex  = regexp.MustCompile(`\{\{[<%]\s*tab\s+(?:name\s*=\s*)?"([^"]+)"\s*[>%]\}\}`)
+    hugoTabCloseRegex = regexp.MustCompile(`\{\{[<%]\s*/tab\s*[>%]\}\}`)
+    instructionRegex  = regexp.MustCompile(`(?i)^\s*(?:\d+[.)]\s+|[-*+]\s+)?(?:run|install|open|click|select|enter|type|navigate|download|execute|launch|copy|double-click|press|choose)\b`)
+    labelWordRegex    = regexp.MustCompile(`[\w.+#-]+`)
+)
+
+// Tab is one pane of a tabbed block (sphinx-tabs, Docusaurus Tabs,
+// MkDocs content tabs, Hugo tab shortcodes)
+type Tab struct {
+    Label     string
+    StartLine int // 1-based line of the tab marker
+    EndLine   int // 1-based last line of the tab content (inclusive)
+}
+
+// parseTabs finds tab panes outside code fences and masks their markers
+func parseTabs(doc *Document) []Tab {
+    var tabs []Tab
+    lines := doc.Lines
+
+    for i := 0; i < len(lines); i++ {
+        if doc.Fenced[i] {
+            continue
+        }
+        line := lines[i]
+
+        // Indentation-scoped tabs: the pane is the block indented beneath the marker
+        for _, pattern := range []*regexp.Regexp{mkdocsTabRegex, sphinxTabRegex} {
+            if match := pattern.FindStringSubmatchIndex(line); match != nil {
+                end := indentedBlockEnd(lines, i)
+                doc.mask(i, match[0], match[1])
+                tabs = append(tabs, Tab{
+                    Label:     line[match[2]:match[3]],
+                    StartLine: i + 1,
+                    EndLine:   end + 1,
+                })
+                break
+            }
+        }
+
+        // Tag-scoped tabs: the pane runs until the matching closing tag
+        if match := tabItemOpenRegex.FindStringIndex(line); match != nil {
+            tag := line[match[0]:match[1]]
+            label := ""
+            if m := tabItemLabelRegex.FindStringSubmatch(tag); m != nil {
+                label = m[1]
+            } else if m := tabItemValueRegex.FindStringSubmatch(tag); m != nil {
+                label = m[1]
+            }
+            doc.mask(i, match[0], match[1])
+            tabs = append(tabs, Tab{Label: label, StartLine: i + 1, EndLine: closeTab(doc, i, match[1], tabItemCloseRegex)})
+        } else if match := hugoTabOpenRegex.FindStringSubmatchIndex(line); match != nil {
+            doc.mask(i, match[0], match[1])
+            tabs = append(tabs, Tab{Label: line[match[2]:match[3]], StartLine: i + 1, EndLine: closeTab(doc, i, match[1], hugoTabCloseRegex)})
+        }
+    }
+
+    return tabs
+}
+
+// closeTab finds the closing tag for a pane opened on line start (content
+// beginning at byte from), masks it and returns its 1-based line
+func closeTab(doc *Document, start, from int, closing *regexp.Regexp) int {
+    for j := start; j < len(doc.Lines); j++ {
+        offset := 0
+        if j == start {
+            offset = from
+        }
+        if match := closing.FindStringIndex(doc.Lines[j][offset:]); match != nil {
+            doc.mask(j, offset+match[0], offset+match[1])
+            return j + 1
+        }
+    }
+    return len(doc.Lines)
+}
+
+// mentionsLabel reports whether text names the tab label, or any significant
+// word of it ("macOS / Linux" is satisfied by either platform)
+func mentionsLabel(text, label string) bool {
+    lower := strings.ToLower(text)
+    for _, word := range labelWordRegex.FindAllString(strings.ToLower(label), -1) {
+        if len(word) > 1 && strings.Contains(lower, word) {
+            return true
+        }
+    }
+    return false
+}
+
+// analyzeTabs flags instructions that live inside a single tab without the
+// tab's platform or variant being named in the text itself, since converted
+// and retrieved text loses the tab affordance
+func (a *Analyzer) analyzeTabs(doc *Document) []Issue {
+    var issues []Issue
+
+    for _, tab := range doc.Tabs {
+        if tab.Label == "" {
+            continue
+        }
+
+        content := strings.Join(doc.Lines[tab.StartLine:tab.EndLine], "\n")
+        if mentionsLabel(content, tab.Label) {
+            continue
+        }
+
+        for lineNum := tab.StartLine + 1; lineNum <= tab.EndLine; lineNum++ {
+            if doc.Fenced[lineNum-1] || !instructionRegex.MatchString(doc.Lines[lineNum-1]) {
+                continue
+            }
+
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         lineNum,
+                Column:       len(doc.Lines[lineNum-1]) - len(strings.TrimLeft(doc.Lines[lineNum-1], " \t")) + 1,
+                Rule:         "tab-only-instruction",
+                Message:      fmt.Sprintf("Instruction exists only inside the '%s' tab without naming it", tab.Label),
+                Severity:     "warning",
+                Suggestion:   fmt.Sprintf("Label the instruction in the text, e.g. 'On %s, ...'", tab.Label),
+                OriginalText: strings.TrimSpace(doc.Lines[lineNum-1]),
+            })
+            break
+        }
+    }
+
+    return issues
+}
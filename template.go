@@ -0,0 +1,70 @@
+// Rule message templates: capture groups and document context
+
+package main
+
+import (
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// templateVariableRegex matches $$, $name and ${name} in a rule template
+var templateVariableRegex = regexp.MustCompile(`\$(?:\$|\{(\w+)\}|(\w+))`)
+
+// expandTemplate fills in a rule's Message, Replacement, Suggestion or Fix
+// template for one match. $1 and ${name} expand the pattern's groups, as in
+// regexp.Expand; ${match}, ${product}, ${heading} and ${heading_path}
+// expand the matched text, the document's product name, the enclosing
+// heading and its full path, unless the pattern has a group of that name.
+// $$ is a literal $, and unknown names expand to nothing.
+func (a *Analyzer) expandTemplate(template string, doc *Document, rule compiledRule, line string, lineNum int, match []int) string {
+    if !strings.Contains(template, "$") {
+        return template
+    }
+    return templateVariableRegex.ReplaceAllStringFunc(template, func(reference string) string {
+        if reference == "$$" {
+            return "$"
+        }
+        name := strings.Trim(reference, "${}")
+        if group, err := strconv.Atoi(name); err == nil {
+            return submatch(line, match, group)
+        }
+        if group := rule.pattern.SubexpIndex(name); group >= 0 {
+            return submatch(line, match, group)
+        }
+
+        switch name {
+        case "match":
+            return line[match[0]:match[1]]
+        case "product":
+            return a.documentProduct(doc)
+        case "heading":
+            if section, ok := doc.SectionAt(lineNum); ok {
+                return plainText(section.Heading)
+            }
+        case "heading_path":
+            if section, ok := doc.SectionAt(lineNum); ok {
+                return plainText(section.Breadcrumb())
+            }
+        }
+        return ""
+    })
+}
+
+// submatch returns the text of a group in a FindStringSubmatchIndex match,
+// or "" when the group doesn't exist or didn't participate
+func submatch(line string, match []int, group int) string {
+    if group < 0 || 2*group+1 >= len(match) || match[2*group] < 0 {
+        return ""
+    }
+    return line[match[2*group]:match[2*group+1]]
+}
+
+// documentProduct returns the product name templates use for doc, worked
+// out once per document
+func (a *Analyzer) documentProduct(doc *Document) string {
+    if doc.product == "" {
+        doc.product = a.inferProductName(a.extractProductNames(doc.Content))
+    }
+    return doc.product
+}
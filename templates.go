@@ -0,0 +1,89 @@
+// Template syntax masking for Jinja, Liquid and Hugo sources
+
+package main
+
+import (
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+// templateSyntaxes maps a template language name to the tag patterns it uses.
+// Patterns may span lines; masked tags keep their byte width so columns
+// reported for the surrounding prose stay correct.
+var templateSyntaxes = map[string][]*regexp.Regexp{
+    "jinja": {
+        regexp.MustCompile(`(?s)\{\{.*?\}\}`),
+        regexp.MustCompile(`(?s)\{%.*?%\}`),
+        regexp.MustCompile(`(?s)\{#.*?#\}`),
+    },
+    "liquid": {
+        regexp.MustCompile(`(?s)\{\{.*?\}\}`),
+        regexp.MustCompile(`(?s)\{%.*?%\}`),
+    },
+    "hugo": {
+        regexp.MustCompile(`(?s)\{\{<.*?>\}\}`),
+        regexp.MustCompile(`(?s)\{\{%.*?%\}\}`),
+        regexp.MustCompile(`(?s)\{\{.*?\}\}`),
+    },
+}
+
+// formatFor returns the configured format whose extensions include path's
+func (c *Config) formatFor(path string) (Format, bool) {
+    return formatFor(c.Formats, path)
+}
+
+// formatFor returns the format whose extensions path ends with. The
+// longest extension wins, so ".txt.erb" takes precedence over ".erb".
+func formatFor(formats map[string]Format, path string) (Format, bool) {
+    lower := strings.ToLower(filepath.Base(path))
+    var found Format
+    longest := 0
+    // Iterate in name order so overlapping extension lists resolve the same way every run
+    for _, name := range sortedKeys(formats) {
+        for _, candidate := range formats[name].Extensions {
+            candidate = strings.ToLower(candidate)
+            if len(candidate) > longest && len(candidate) < len(lower) && strings.HasSuffix(lower, candidate) {
+                found, longest = formats[name], len(candidate)
+            }
+        }
+    }
+    return found, longest > 0
+}
+
+// MaskTemplates blanks out template tags of the given syntaxes in the masked
+// lines, so rules neither match inside tags nor quote them in suggestions
+func (d *Document) MaskTemplates(syntaxes []string) {
+    if len(syntaxes) == 0 {
+        return
+    }
+
+    lineStarts := make([]int, len(d.Lines))
+    offset := 0
+    for i, line := range d.Lines {
+        lineStarts[i] = offset
+        offset += len(line) + 1
+    }
+
+    for _, syntax := range syntaxes {
+        for _, pattern := range templateSyntaxes[strings.ToLower(syntax)] {
+            for _, match := range pattern.FindAllStringIndex(d.Content, -1) {
+                d.maskRange(lineStarts, match[0], match[1])
+            }
+        }
+    }
+}
+
+// maskRange masks the content byte range [start, end) across line boundaries
+func (d *Document) maskRange(lineStarts []int, start, end int) {
+    line := sort.Search(len(lineStarts), func(i int) bool { return lineStarts[i] > start }) - 1
+
+    for ; line < len(d.Lines) && lineStarts[line] < end; line++ {
+        from := start - lineStarts[line]
+        if from < 0 {
+            from = 0
+        }
+        d.mask(line, from, end-lineStarts[line])
+    }
+}
@@ -0,0 +1,268 @@
+// OpenTelemetry tracing of the analysis pipeline
+
+package main
+
+import (
+    "bytes"
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// tracing is the run's tracer, or nil when tracing is off. main sets it
+// for analysis runs; subcommands aren't traced.
+var tracing *tracer
+
+// tracer collects the spans of one run and exports them as OTLP/HTTP JSON
+// when the run ends. Only the standard environment variables configure
+// it, so no flags are needed in CI.
+type tracer struct {
+    endpoint string // OTLP traces URL; "" writes the payload to stderr
+    headers  map[string]string
+    service  string
+    traceID  string
+    root     *span
+
+    mu    sync.Mutex
+    spans []*span
+}
+
+// span is one timed operation. Its methods are no-ops on a nil span, so
+// call sites don't check whether tracing is on.
+type span struct {
+    tracer     *tracer
+    id, parent string
+    name       string
+    start, end time.Time
+    attributes map[string]any
+}
+
+// newTracer configures tracing from OTEL_SDK_DISABLED,
+// OTEL_TRACES_EXPORTER (otlp, console or none),
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, OTEL_EXPORTER_OTLP_ENDPOINT,
+// OTEL_EXPORTER_OTLP_HEADERS and OTEL_SERVICE_NAME. Tracing is on only when
+// an exporter or endpoint is set, and returns nil otherwise.
+func newTracer() *tracer {
+    if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+        return nil
+    }
+
+    t := &tracer{service: os.Getenv("OTEL_SERVICE_NAME"), headers: make(map[string]string)}
+    if t.service == "" {
+        t.service = "ai-doc-optimizer"
+    }
+
+    exporter := os.Getenv("OTEL_TRACES_EXPORTER")
+    switch exporter {
+    case "none":
+        return nil
+    case "console":
+        // endpoint stays empty
+    case "", "otlp":
+        t.endpoint = os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+        if t.endpoint == "" {
+            if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+                t.endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+            } else if exporter == "otlp" {
+                t.endpoint = "http://localhost:4318/v1/traces"
+            } else {
+                return nil
+            }
+        }
+        if protocol := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); protocol != "" && protocol != "http/json" {
+            fmt.Fprintf(os.Stderr, "Warning: OTEL_EXPORTER_OTLP_PROTOCOL %s is not supported, exporting traces as http/json\n", protocol)
+        }
+        for _, pair := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+            if key, value, ok := strings.Cut(pair, "="); ok {
+                t.headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+            }
+        }
+    default:
+        fmt.Fprintf(os.Stderr, "Warning: OTEL_TRACES_EXPORTER %s is not supported, tracing is off\n", exporter)
+        return nil
+    }
+
+    t.traceID = randomID(16)
+    t.root = &span{tracer: t, id: randomID(8), name: "ai-doc-optimizer", start: time.Now(), attributes: make(map[string]any)}
+    return t
+}
+
+// randomID returns n random bytes in hex, as OTLP JSON encodes trace and
+// span IDs
+func randomID(n int) string {
+    id := make([]byte, n)
+    rand.Read(id)
+    return hex.EncodeToString(id)
+}
+
+// start begins a span under parent, or under the run's root span
+func (t *tracer) start(parent *span, name string) *span {
+    if t == nil {
+        return nil
+    }
+    if parent == nil {
+        parent = t.root
+    }
+    return &span{tracer: t, id: randomID(8), parent: parent.id, name: name, start: time.Now(), attributes: make(map[string]any)}
+}
+
+// set records a string or integer attribute
+func (s *span) set(key string, value any) {
+    if s != nil {
+        s.attributes[key] = value
+    }
+}
+
+// finish ends the span now
+func (s *span) finish() {
+    if s != nil {
+        s.finishAt(time.Now())
+    }
+}
+
+// finishAt ends the span at the given time
+func (s *span) finishAt(end time.Time) {
+    if s == nil {
+        return
+    }
+    s.end = end
+    s.tracer.mu.Lock()
+    s.tracer.spans = append(s.tracer.spans, s)
+    s.tracer.mu.Unlock()
+}
+
+// shutdown ends the root span and exports every span of the run. Export
+// failures are warnings: tracing never fails an analysis.
+func (t *tracer) shutdown() {
+    if t == nil {
+        return
+    }
+    t.root.finish()
+
+    payload, err := json.Marshal(t.payload())
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Warning: failed to encode traces: %v\n", err)
+        return
+    }
+    if t.endpoint == "" {
+        fmt.Fprintln(os.Stderr, string(payload))
+        return
+    }
+
+    request, err := http.NewRequest(http.MethodPost, t.endpoint, bytes.NewReader(payload))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Warning: failed to export traces: %v\n", err)
+        return
+    }
+    request.Header.Set("Content-Type", "application/json")
+    for key, value := range t.headers {
+        request.Header.Set(key, value)
+    }
+    client := &http.Client{Timeout: 10 * time.Second}
+    response, err := client.Do(request)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Warning: failed to export traces: %v\n", err)
+        return
+    }
+    response.Body.Close()
+    if response.StatusCode >= 300 {
+        fmt.Fprintf(os.Stderr, "Warning: failed to export traces: %s returned %s\n", t.endpoint, response.Status)
+    }
+}
+
+// payload builds the OTLP ExportTraceServiceRequest for the run
+func (t *tracer) payload() map[string]any {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    spans := make([]map[string]any, 0, len(t.spans))
+    for _, s := range t.spans {
+        spans = append(spans, map[string]any{
+            "traceId":           t.traceID,
+            "spanId":            s.id,
+            "parentSpanId":      s.parent,
+            "name":              s.name,
+            "kind":              1, // SPAN_KIND_INTERNAL
+            "startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+            "endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+            "attributes":        otlpAttributes(s.attributes),
+        })
+    }
+
+    return map[string]any{
+        "resourceSpans": []any{map[string]any{
+            "resource": map[string]any{
+                "attributes": otlpAttributes(map[string]any{"service.name": t.service}),
+            },
+            "scopeSpans": []any{map[string]any{
+                "scope": map[string]any{"name": "ai-doc-optimizer", "version": toolVersion},
+                "spans": spans,
+            }},
+        }},
+    }
+}
+
+// otlpAttributes encodes attributes as OTLP key-value pairs, sorted by key
+func otlpAttributes(attributes map[string]any) []map[string]any {
+    encoded := make([]map[string]any, 0, len(attributes))
+    for _, key := range sortedKeys(attributes) {
+        var value map[string]any
+        switch v := attributes[key].(type) {
+        case int:
+            value = map[string]any{"intValue": strconv.Itoa(v)}
+        default:
+            value = map[string]any{"stringValue": fmt.Sprint(v)}
+        }
+        encoded = append(encoded, map[string]any{"key": key, "value": value})
+    }
+    return encoded
+}
+
+// analysisTimings accumulates how long each stage of a file's analysis
+// takes. Pattern rules run interleaved line by line, so each holds the sum
+// of its time over every line.
+type analysisTimings struct {
+    Parse     time.Duration
+    Rules     map[string]time.Duration // pattern rules, by rule name
+    Checks    map[string]time.Duration // built-in checks, by check name
+    Lines     int                      // lines the line rules were considered for
+    RuleLines map[string]int           // lines each line rule ran on, past the literal prefilter
+}
+
+func newAnalysisTimings() *analysisTimings {
+    return &analysisTimings{Rules: make(map[string]time.Duration), Checks: make(map[string]time.Duration), RuleLines: make(map[string]int)}
+}
+
+// record adds a child span to parent for each stage. The spans are laid
+// end to end from the parent's start, so their durations are exact and
+// their offsets approximate.
+func (t *analysisTimings) record(parent *span) {
+    if parent == nil {
+        return
+    }
+    at := parent.start
+    child := func(name, attribute, value string, d time.Duration) {
+        s := parent.tracer.start(parent, name)
+        s.start = at
+        if attribute != "" {
+            s.set(attribute, value)
+        }
+        at = at.Add(d)
+        s.finishAt(at)
+    }
+
+    child("parse", "", "", t.Parse)
+    for _, name := range sortedKeys(t.Rules) {
+        child("rule "+name, "rule.name", name, t.Rules[name])
+    }
+    for _, name := range sortedKeys(t.Checks) {
+        child("check "+name, "check.name", name, t.Checks[name])
+    }
+}
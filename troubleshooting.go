@@ -0,0 +1,229 @@
+// Troubleshooting rule pack: symptom, cause and resolution structure
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var (
+    // symptomRegex matches a heading or label introducing a problem's
+    // symptom
+    symptomRegex = regexp.MustCompile(`(?i)^(?:symptoms?|problem|issue|what you (?:see|notice)|error messages?|observed behaviou?r)\b`)
+    // symptomProseRegex matches a sentence describing the symptom without a
+    // label
+    symptomProseRegex = regexp.MustCompile(`(?i)\b(?:you (?:see|get|receive|notice|might see|may see)|fails with|(?:returns|shows|displays|reports|logs) (?:the |an? )?(?:error|message|warning)|the (?:following )?(?:error|message) (?:appears|is (?:shown|displayed|returned)))\b`)
+    // symptomErrorRegex matches a symptom that is an error, whose text
+    // the entry has to quote
+    symptomErrorRegex = regexp.MustCompile(`(?i)\b(?:errors?|messages?|exceptions?|fails? with|warnings?|codes?)\b`)
+    // troubleshootingAsideRegex matches headings on a troubleshooting page
+    // that aren't a problem of their own
+    troubleshootingAsideRegex = regexp.MustCompile(`(?i)^(?:overview|introduction|about|before you begin|prerequisites|related (?:articles|topics|links)|see also|next steps|more information|get(?:ting)? help|contact support|feedback)\b`)
+)
+
+// troubleshootingPart is a symptom, cause or resolution of a problem: how
+// the entry gives it ("heading", "label" or "prose") and where
+type troubleshootingPart struct {
+    style string
+    line  int
+}
+
+// set records the first part found, preferring a heading or label over a
+// sentence
+func (p *troubleshootingPart) set(style string, line int) {
+    if p.style == "" || (p.style == "prose" && style != "prose") {
+        p.style, p.line = style, line
+    }
+}
+
+// troubleshootingEntry is one problem of a troubleshooting page
+type troubleshootingEntry struct {
+    name                       string
+    line, startLine, endLine   int
+    symptom, cause, resolution troubleshootingPart
+}
+
+// analyzeTroubleshooting runs the structural checks of the troubleshooting
+// pack. Each problem on a troubleshooting page should give its symptom,
+// then its cause, then its resolution, and quote the error it describes: a
+// user pastes the error into the assistant, and only an entry that
+// contains it, and says why it happens and what to do, answers them.
+func (a *Analyzer) analyzeTroubleshooting(doc *Document) []Issue {
+    if !a.config.packEnabled("troubleshooting") {
+        return nil
+    }
+    // The error reference checks already cover the cause, resolution and
+    // message of the entries they find
+    covered := make(map[int]bool)
+    for _, entry := range errorEntries(doc) {
+        covered[entry.section.Line] = true
+    }
+
+    var issues []Issue
+    for _, entry := range troubleshootingEntries(doc) {
+        report := func(line int, rule, severity, message, suggestion string) {
+            issues = append(issues, Issue{
+                File:         doc.Path,
+                Line:         line,
+                Column:       1,
+                Rule:         rule,
+                Message:      message,
+                Severity:     severity,
+                Suggestion:   suggestion,
+                OriginalText: strings.TrimSpace(doc.Lines[line-1]),
+            })
+        }
+
+        var missing []string
+        if entry.symptom.style == "" {
+            missing = append(missing, "symptom")
+        }
+        if entry.cause.style == "" && !covered[entry.line] {
+            missing = append(missing, "cause")
+        }
+        if entry.resolution.style == "" && !covered[entry.line] {
+            missing = append(missing, "resolution")
+        }
+        if n := len(missing); n > 0 {
+            listed := strings.Join(missing, " or ")
+            if n == 3 {
+                listed = "symptom, cause or resolution"
+            }
+            report(entry.line, "troubleshooting-missing-part", "warning",
+                fmt.Sprintf("Troubleshooting entry %q has no %s", entry.name, listed),
+                "Give the symptom, cause and resolution in that order, under headings or labels such as \"Symptom\", \"Cause\" and \"Resolution\"")
+        }
+
+        // Parts out of order: the resolution before the cause, or either
+        // before the symptom
+        parts := []troubleshootingPart{entry.symptom, entry.cause, entry.resolution}
+        names := []string{"symptom", "cause", "resolution"}
+    order:
+        for i := range parts {
+            for j := i + 1; j < len(parts); j++ {
+                if parts[i].line > 0 && parts[j].line > 0 && parts[j].line < parts[i].line {
+                    report(parts[j].line, "troubleshooting-order", "suggestion",
+                        fmt.Sprintf("Troubleshooting entry %q gives the %s before the %s", entry.name, names[j], names[i]),
+                        "Order the entry symptom, cause, resolution, so a chunk split from it reads as problem and answer")
+                    break order
+                }
+            }
+        }
+
+        if !covered[entry.line] && entry.symptom.style != "" {
+            if quoted, errorText := doc.quotesSymptom(entry); errorText && !quoted {
+                report(entry.symptom.line, "troubleshooting-missing-error-text", "warning",
+                    fmt.Sprintf("Troubleshooting entry %q describes an error without quoting it", entry.name),
+                    "Include the exact error text as inline code or a code block, so a search for the pasted error finds this entry")
+            }
+        }
+    }
+    return issues
+}
+
+// troubleshootingEntries returns the problems of a troubleshooting page:
+// the headings at the shallowest level below the title, other than part
+// labels and asides such as "Before you begin". A page whose headings are
+// all parts is a single problem, named by its title.
+func troubleshootingEntries(doc *Document) []troubleshootingEntry {
+    isPart := func(heading string) bool {
+        heading = plainText(heading)
+        return symptomRegex.MatchString(heading) || causeRegex.MatchString(heading) || resolutionRegex.MatchString(heading)
+    }
+    level := 0
+    for _, section := range doc.Sections {
+        if section.Level > 1 && !isPart(section.Heading) && !troubleshootingAsideRegex.MatchString(plainText(section.Heading)) && (level == 0 || section.Level < level) {
+            level = section.Level
+        }
+    }
+
+    var entries []troubleshootingEntry
+    for i, section := range doc.Sections {
+        if level == 0 || section.Level != level || isPart(section.Heading) || troubleshootingAsideRegex.MatchString(plainText(section.Heading)) {
+            continue
+        }
+        entry := troubleshootingEntry{name: plainText(section.Heading), line: section.Line, startLine: section.StartLine, endLine: section.EndLine}
+        for _, next := range doc.Sections[i+1:] {
+            if next.Level <= level {
+                break
+            }
+            entry.endLine = next.EndLine
+        }
+        doc.describeTroubleshootingEntry(&entry)
+        entries = append(entries, entry)
+    }
+    if level > 0 {
+        return entries
+    }
+
+    title, line := doc.Title()
+    if title == "" {
+        title, line = doc.H1()
+    }
+    if title == "" || len(doc.Lines) < doc.BodyStart {
+        return nil
+    }
+    entry := troubleshootingEntry{name: plainText(title), line: max(line, doc.BodyStart), startLine: doc.BodyStart, endLine: len(doc.Lines)}
+    doc.describeTroubleshootingEntry(&entry)
+    return []troubleshootingEntry{entry}
+}
+
+// describeTroubleshootingEntry finds where an entry gives its symptom,
+// cause and resolution
+func (d *Document) describeTroubleshootingEntry(entry *troubleshootingEntry) {
+    for i := entry.startLine - 1; i < entry.endLine && i < len(d.Lines); i++ {
+        trimmed := strings.TrimSpace(d.Lines[i])
+        if d.Fenced[i] || trimmed == "" {
+            continue
+        }
+        style, label := "", ""
+        if match := atxHeadingRegex.FindStringSubmatch(trimmed); match != nil {
+            style, label = "heading", plainText(match[2])
+        } else if match := labelRegex.FindStringSubmatch(trimmed); match != nil {
+            style, label = "label", match[1]+match[2]
+        }
+        switch {
+        case style != "" && symptomRegex.MatchString(label):
+            entry.symptom.set(style, i+1)
+        case style != "" && causeRegex.MatchString(label):
+            entry.cause.set(style, i+1)
+        case style != "" && resolutionRegex.MatchString(label):
+            entry.resolution.set(style, i+1)
+        default:
+            text := plainText(trimmed)
+            if symptomProseRegex.MatchString(text) {
+                entry.symptom.set("prose", i+1)
+            }
+            if causeProseRegex.MatchString(text) {
+                entry.cause.set("prose", i+1)
+            }
+            if resolutionProseRegex.MatchString(text) {
+                entry.resolution.set("prose", i+1)
+            }
+        }
+    }
+}
+
+// quotesSymptom reports whether an entry's heading or symptom quotes text
+// as code or a blockquote, and whether the symptom is an error that needs
+// quoting. The symptom runs from its line to the first cause or
+// resolution after it.
+func (d *Document) quotesSymptom(entry troubleshootingEntry) (quoted, errorText bool) {
+    end := entry.endLine
+    for _, part := range []troubleshootingPart{entry.cause, entry.resolution} {
+        if part.line > entry.symptom.line && part.line-1 < end {
+            end = part.line - 1
+        }
+    }
+    quoted = strings.Contains(d.Lines[entry.line-1], "`")
+    for i := entry.symptom.line - 1; i < end && i < len(d.Lines); i++ {
+        trimmed := strings.TrimSpace(d.Lines[i])
+        if d.Fenced[i] || strings.Contains(trimmed, "`") || strings.HasPrefix(trimmed, ">") {
+            quoted = true
+        }
+        errorText = errorText || (!d.Fenced[i] && symptomErrorRegex.MatchString(plainText(trimmed)))
+    }
+    return quoted, errorText || symptomErrorRegex.MatchString(entry.name)
+}
@@ -0,0 +1,100 @@
+// Vague quantifier, hedging and timing detection
+
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// vaguePhrase is one family of vague wording with what to ask for instead
+type vaguePhrase struct {
+    rule       string
+    pattern    *regexp.Regexp
+    message    string // format taking the matched phrase
+    suggestion string
+}
+
+var (
+    // vaguePhrases are checked in order; a phrase overlapping an earlier
+    // match ("a few" in "a few minutes") isn't reported twice
+    vaguePhrases = []vaguePhrase{
+        {
+            rule:       "vague-timing",
+            pattern:    regexp.MustCompile(`(?i)\b(?:soon|shortly|eventually|a while|periodically|from time to time|occasionally|at some point|in a timely manner|(?:some|a long|a short) time|(?:a few|several|some|many) (?:seconds|minutes|hours|days|weeks))\b`),
+            message:    "%q doesn't say how long or how often",
+            suggestion: "Give the duration or interval (\"within 30 seconds\", \"every 15 minutes\")",
+        },
+        {
+            rule:       "hedging",
+            pattern:    regexp.MustCompile(`(?i)\b(?:may or may not|might or might not|possibly|perhaps|probably|in (?:some|certain|most) cases|under certain conditions|it depends|depending on (?:various|several|many) factors|more or less|somewhat|sort of|kind of)\b`),
+            message:    "%q hedges without saying when it applies",
+            suggestion: "State the condition that decides it (\"if the cluster has fewer than three nodes\"), or state it as fact",
+        },
+        {
+            rule:       "vague-quantifier",
+            pattern:    regexp.MustCompile(`(?i)\b(?:some|several|various|numerous|many|a (?:number|variety|couple|handful|bunch) of|a few|a lot of|lots of)\b`),
+            message:    "%q doesn't say how many",
+            suggestion: "State the exact number or list the items (\"three regions: us-east-1, eu-west-1 and ap-south-1\")",
+        },
+    }
+    // vagueConcreteRegex matches the rest of a sentence that goes on to give
+    // the specifics: a number, a list, an example
+    vagueConcreteRegex = regexp.MustCompile(`(?i)^[^.;!?]*?(?:\d|:|\(|\bsuch as\b|\bincluding\b|\bfor example\b|\be\.g\.|\bthe following\b|\bbelow\b|\blisted\b)`)
+    // vagueQuestionRegex matches the words before a quantifier that make it
+    // part of a question or comparison rather than a claim
+    vagueQuestionRegex = regexp.MustCompile(`(?i)\b(?:how|too|so|as)\s+$`)
+    // vagueContrastRegex matches the rest of a sentence contrasting "some"
+    // with the others ("on some lines and spaces on others")
+    vagueContrastRegex = regexp.MustCompile(`(?i)^[^.;!?]*\bothers?\b`)
+)
+
+// analyzeVagueness flags vague quantifiers, hedging and timing in prose.
+// An assistant answering from "the sync may take some time" can only be as
+// vague as the source, where "within 5 minutes" gives it a fact to repeat.
+// A phrase the sentence goes on to make concrete ("several regions, such
+// as ...") isn't reported.
+func (a *Analyzer) analyzeVagueness(doc *Document) []Issue {
+    var issues []Issue
+    for _, paragraph := range doc.Paragraphs() {
+        for lineNum := paragraph.StartLine; lineNum <= paragraph.EndLine; lineNum++ {
+            line := doc.Masked[lineNum-1]
+            var taken [][2]int
+            for _, phrase := range vaguePhrases {
+            matches:
+                for _, match := range phrase.pattern.FindAllStringIndex(line, -1) {
+                    start, end := match[0], match[1]
+                    if (end < len(line) && line[end] == '-') || (start > 0 && line[start-1] == '-') {
+                        continue // "many-to-many"
+                    }
+                    if vagueQuestionRegex.MatchString(line[:start]) || vagueConcreteRegex.MatchString(line[end:]) {
+                        continue
+                    }
+                    if strings.EqualFold(line[start:end], "some") && vagueContrastRegex.MatchString(line[end:]) {
+                        continue
+                    }
+                    for _, span := range taken {
+                        if start < span[1] && span[0] < end {
+                            continue matches
+                        }
+                    }
+                    taken = append(taken, [2]int{start, end})
+
+                    text := doc.Lines[lineNum-1][start:end]
+                    issues = append(issues, Issue{
+                        File:         doc.Path,
+                        Line:         lineNum,
+                        Column:       start + 1,
+                        Rule:         phrase.rule,
+                        Message:      fmt.Sprintf(phrase.message, strings.ToLower(text)),
+                        Severity:     "suggestion",
+                        Suggestion:   phrase.suggestion,
+                        OriginalText: text,
+                    })
+                }
+            }
+        }
+    }
+    return issues
+}
@@ -0,0 +1,275 @@
+// Doc-site variable and substitution expansion
+
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// VariablesConfig supplies the values of doc-site variables, so rules see
+// "CloudSync" where the source says {{ site.product_name }}
+type VariablesConfig struct {
+    Files  []string          `yaml:"Files,omitempty"`  // YAML values files, such as _config.yml or antora.yml; nested keys join with "."
+    Values map[string]string `yaml:"Values,omitempty"` // values set in the config, taking precedence over Files
+}
+
+var (
+    // templateReferenceRegex, rstReferenceRegex and asciidocReferenceRegex
+    // match a variable reference and capture its name: {{ site.name }}
+    // (Jinja, Liquid, MyST, Hugo's {{ .Site.Params.name }}), Sphinx |name|
+    // and AsciiDoc {name}
+    templateReferenceRegex = regexp.MustCompile(`\{\{-?\s*\.?([A-Za-z_][\w.-]*)\s*(?:\|[^}]*)?-?\}\}`)
+    rstReferenceRegex      = regexp.MustCompile(`\|([A-Za-z0-9_](?:[\w .-]*[\w.-])?)\|_{0,2}`)
+    asciidocReferenceRegex = regexp.MustCompile(`\{([A-Za-z0-9_][\w-]*)\}`)
+    // rstSubstitutionRegex and asciidocAttributeRegex match definitions a
+    // document makes for itself
+    rstSubstitutionRegex   = regexp.MustCompile(`^\.\.\s+\|([^|]+)\|\s+replace::\s*(.+?)\s*$`)
+    asciidocAttributeRegex = regexp.MustCompile(`^:([A-Za-z0-9_][\w-]*):\s+(.+?)\s*$`)
+)
+
+// asciidocExtensions name AsciiDoc files, which configured Formats can
+// make documents
+var asciidocExtensions = []string{".adoc", ".asciidoc", ".asc"}
+
+// isAsciiDoc reports whether path names an AsciiDoc file
+func isAsciiDoc(path string) bool {
+    ext := strings.ToLower(filepath.Ext(path))
+    for _, asciidoc := range asciidocExtensions {
+        if ext == asciidoc {
+            return true
+        }
+    }
+    return false
+}
+
+// loadVariables reads the configured values files and values into one map
+// keyed by lowercased dotted name. A value is one line, as expanding more
+// would move every later line of a document; the files' longer values are
+// left out.
+func loadVariables(config VariablesConfig) (map[string]string, error) {
+    variables := make(map[string]string)
+    for _, file := range config.Files {
+        data, err := os.ReadFile(file)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read variables: %w", err)
+        }
+        var values any
+        if err := yaml.Unmarshal(data, &values); err != nil {
+            return nil, fmt.Errorf("failed to parse variables file %s: %w", file, err)
+        }
+        flattenValues("", values, variables)
+    }
+    for _, name := range sortedKeys(config.Values) {
+        if strings.ContainsAny(config.Values[name], "\r\n") {
+            return nil, fmt.Errorf("invalid Variables.Values.%s: a value must be one line", name)
+        }
+        variables[strings.ToLower(name)] = config.Values[name]
+    }
+    // A prefixed key is also known by its bare name, unless that is taken
+    for _, name := range sortedKeys(variables) {
+        if bare := unprefixed(name); bare != name {
+            if _, taken := variables[bare]; !taken {
+                variables[bare] = variables[name]
+            }
+        }
+    }
+    return variables, nil
+}
+
+// flattenValues adds the scalar values of a decoded YAML tree under their
+// dotted paths
+func flattenValues(prefix string, value any, variables map[string]string) {
+    switch value := value.(type) {
+    case map[string]any:
+        for key, child := range value {
+            name := strings.ToLower(key)
+            if prefix != "" {
+                name = prefix + "." + name
+            }
+            flattenValues(name, child, variables)
+        }
+    case []any, nil:
+    default:
+        if text := fmt.Sprint(value); prefix != "" && !strings.ContainsAny(text, "\r\n") {
+            variables[prefix] = text
+        }
+    }
+}
+
+// variablePrefixes are the containers site generators nest values under:
+// Jekyll's site, Hugo's .Site.Params, MkDocs' config.extra, Antora's
+// asciidoc.attributes. Longer prefixes come first.
+var variablePrefixes = []string{"site.params.", "config.extra.", "asciidoc.attributes.", "site.", "params.", "config.", "extra.", "vars.", "variables."}
+
+// unprefixed strips the container prefix from a dotted name
+func unprefixed(name string) string {
+    for _, prefix := range variablePrefixes {
+        if trimmed, ok := strings.CutPrefix(name, prefix); ok {
+            return trimmed
+        }
+    }
+    return name
+}
+
+// lookupVariable finds the value of a reference, matching names with or
+// without their container prefix, so {{ site.product_name }} finds a
+// product_name key and {product-name} finds asciidoc.attributes.product-name
+func lookupVariable(variables map[string]string, name string) (string, bool) {
+    name = strings.ToLower(name)
+    if value, ok := variables[name]; ok {
+        return value, true
+    }
+    value, ok := variables[unprefixed(name)]
+    return value, ok
+}
+
+// productVariables returns the values of variables that name a product or
+// brand, such as product_name, which count as product context even where
+// the text mentions them less often than the frequency heuristic needs
+func (a *Analyzer) productVariables() []string {
+    var products []string
+    seen := make(map[string]bool)
+    for _, name := range sortedKeys(a.variables) {
+        value := strings.TrimSpace(a.variables[name])
+        key := name[strings.LastIndex(name, ".")+1:]
+        if value != "" && !seen[value] && (strings.Contains(key, "product") || strings.Contains(key, "brand")) {
+            seen[value] = true
+            products = append(products, value)
+        }
+    }
+    return products
+}
+
+// substitutionSpan is one replaced reference: its byte range in the
+// expanded line and in the original line
+type substitutionSpan struct {
+    start, end                 int
+    originalStart, originalEnd int
+}
+
+// substitution is a document with its variables expanded, and what it takes
+// to map issue columns back to the source
+type substitution struct {
+    content  string
+    original []string
+    spans    map[int][]substitutionSpan // 0-based line -> spans in order
+}
+
+// substituteVariables replaces the references to known variables in the
+// content of the document at path, of format. {{ name }} references are
+// expanded in any document, but |name| only in reStructuredText and {name}
+// only in AsciiDoc, where Markdown would have them match table cells and
+// path templates such as /users/{id}. Unknown references are left as they
+// are. RST substitutions and AsciiDoc attributes the document defines are
+// expanded too.
+func substituteVariables(path, format, content string, variables map[string]string) *substitution {
+    syntaxes := []*regexp.Regexp{templateReferenceRegex}
+    var definition *regexp.Regexp
+    switch {
+    case isAsciiDoc(path):
+        syntaxes, definition = append(syntaxes, asciidocReferenceRegex), asciidocAttributeRegex
+    case format == "rst":
+        syntaxes, definition = append(syntaxes, rstReferenceRegex), rstSubstitutionRegex
+    }
+
+    lines := strings.Split(content, "\n")
+    local := make(map[string]string)
+    for _, line := range lines {
+        if definition == nil {
+            break
+        }
+        if match := definition.FindStringSubmatch(line); match != nil {
+            local[strings.ToLower(match[1])] = match[2]
+        }
+    }
+
+    result := &substitution{original: lines, spans: make(map[int][]substitutionSpan)}
+    expanded := make([]string, len(lines))
+    for i, line := range lines {
+        if definition != nil && definition.MatchString(line) {
+            expanded[i] = line
+            continue
+        }
+
+        type reference struct {
+            start, end int
+            value      string
+        }
+        var references []reference
+        for _, syntax := range syntaxes {
+            for _, match := range syntax.FindAllStringSubmatchIndex(line, -1) {
+                name := line[match[2]:match[3]]
+                value, ok := local[strings.ToLower(name)]
+                if !ok {
+                    value, ok = lookupVariable(variables, name)
+                }
+                if ok {
+                    references = append(references, reference{match[0], match[1], value})
+                }
+            }
+        }
+        if len(references) == 0 {
+            expanded[i] = line
+            continue
+        }
+        sort.SliceStable(references, func(a, b int) bool { return references[a].start < references[b].start })
+
+        var builder strings.Builder
+        last := 0
+        for _, ref := range references {
+            if ref.start < last {
+                continue // overlaps a reference already replaced
+            }
+            builder.WriteString(line[last:ref.start])
+            start := builder.Len()
+            builder.WriteString(ref.value)
+            result.spans[i] = append(result.spans[i], substitutionSpan{start, builder.Len(), ref.start, ref.end})
+            last = ref.end
+        }
+        builder.WriteString(line[last:])
+        expanded[i] = builder.String()
+    }
+    result.content = strings.Join(expanded, "\n")
+    return result
+}
+
+// restore maps the byte columns of the document's issues back to the
+// source lines, and puts those lines back in the document so later column
+// conversion measures the text the user sees. Replacements on a line with
+// expanded variables are dropped, since they were made against the values.
+func (s *substitution) restore(doc *Document, issues []Issue) []Issue {
+    for i, issue := range issues {
+        if fix := issue.Fix; fix != nil && fix.Column > 0 && len(s.spans[fix.Line-1]) > 0 {
+            issues[i].Fix = nil
+        }
+        spans := s.spans[issue.Line-1]
+        if issue.File != doc.Path || issue.Column < 1 || len(spans) == 0 {
+            continue
+        }
+        offset, shift := issue.Column-1, 0
+        column := 0
+        for _, span := range spans {
+            if offset < span.start {
+                break
+            }
+            if offset < span.end {
+                column = span.originalStart + 1 // inside the value: point at the reference
+                break
+            }
+            shift = span.originalEnd - span.end
+        }
+        if column == 0 {
+            column = offset + shift + 1
+        }
+        issues[i].Column = column
+    }
+    doc.Lines = s.original
+    return issues
+}
@@ -0,0 +1,142 @@
+// Directory walking
+
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "sort"
+)
+
+// WalkOptions controls how directory arguments expand into files
+type WalkOptions struct {
+    Recursive      bool
+    FollowSymlinks bool              // descend into symlinked directories, guarding against cycles
+    Formats        map[string]Format // the configured formats, whose files are documents too
+}
+
+// skippedDirs are never descended into during recursive walks: VCS
+// metadata, dependencies, and common site generator output. A directory
+// named explicitly on the command line is still walked.
+var skippedDirs = map[string]bool{
+    ".git":         true,
+    ".hg":          true,
+    ".svn":         true,
+    "node_modules": true,
+    "vendor":       true,
+    "_build":       true,
+    "_site":        true,
+    "build":        true,
+    "dist":         true,
+    "site":         true,
+    "public":       true,
+    ".docusaurus":  true,
+    ".next":        true,
+    ".cache":       true,
+}
+
+// collectFiles expands path into the supported files it names or contains,
+// including the files inside archives
+func collectFiles(path string, options WalkOptions) ([]string, error) {
+    stat, err := os.Stat(path)
+    if err != nil {
+        return nil, err
+    }
+
+    if !stat.IsDir() {
+        if isArchive(path) {
+            return archiveFiles(path, options.Formats)
+        }
+        // A source file named on its own has its doc comments checked;
+        // directories only yield them through the repo command. Any file
+        // named on its own is a document with -force-format.
+        if isSupportedFile(path, options.Formats) || sourceLanguage(path) != "" || isSniffedDocument(path) || forcedFormat != "" {
+            return []string{path}, nil
+        }
+        return nil, nil
+    }
+
+    walker := &walker{options: options, visited: make(map[string]bool), seen: make(map[string]bool)}
+    if err := walker.walk(path, true); err != nil {
+        return nil, err
+    }
+    return walker.files, nil
+}
+
+// walker accumulates files from one directory argument. visited holds the
+// real paths of directories entered, so symlink cycles end; seen holds real
+// paths of files, so a file reachable through several links is read once.
+type walker struct {
+    options WalkOptions
+    visited map[string]bool
+    seen    map[string]bool
+    files   []string
+}
+
+func (w *walker) walk(dir string, root bool) error {
+    real, err := filepath.EvalSymlinks(dir)
+    if err != nil {
+        return err
+    }
+    if w.visited[real] {
+        return nil
+    }
+    w.visited[real] = true
+
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        if root {
+            return err
+        }
+        reportFailure(readFailureRule, dir, 0, "failed to read %s: %v", dir, err)
+        return nil
+    }
+    sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+    for _, entry := range entries {
+        path := filepath.Join(dir, entry.Name())
+        isDir := entry.IsDir()
+
+        if entry.Type()&os.ModeSymlink != 0 {
+            target, err := os.Stat(path)
+            if err != nil {
+                continue // dangling link
+            }
+            isDir = target.IsDir()
+            if isDir && !w.options.FollowSymlinks {
+                continue
+            }
+        }
+
+        if isDir {
+            if w.options.Recursive && !skippedDirs[entry.Name()] {
+                if err := w.walk(path, false); err != nil {
+                    reportFailure(readFailureRule, path, 0, "failed to walk %s: %v", path, err)
+                }
+            }
+            continue
+        }
+
+        if !isSupportedFile(path, w.options.Formats) && !isArchive(path) && !isSniffedDocument(path) {
+            continue
+        }
+        if real, err := filepath.EvalSymlinks(path); err == nil {
+            if w.seen[real] {
+                continue
+            }
+            w.seen[real] = true
+        }
+        if !isArchive(path) {
+            w.files = append(w.files, path)
+            continue
+        }
+        files, err := archiveFiles(path, w.options.Formats)
+        if err != nil {
+            reportFailure(readFailureRule, path, 0, "%v", err)
+            continue
+        }
+        w.files = append(w.files, files...)
+    }
+
+    return nil
+}
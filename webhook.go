@@ -0,0 +1,131 @@
+// Webhook notifications when a run breaches its quality thresholds
+
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "os"
+    "strings"
+    "time"
+)
+
+// WebhookConfig posts a summary to a webhook when a run breaches one of
+// its thresholds. The payload's text field is what Slack, Mattermost and
+// compatible incoming webhooks display.
+type WebhookConfig struct {
+    URL          string  `yaml:"URL,omitempty"`          // webhook to post to
+    URLEnv       string  `yaml:"URLEnv,omitempty"`       // environment variable holding the URL, for webhooks whose URL is a secret
+    MaxErrors    int     `yaml:"MaxErrors,omitempty"`    // issues ranked error or above a run may report, 0 by default; -1 for no limit
+    MinScore     float64 `yaml:"MinScore,omitempty"`     // lowest acceptable score, none by default
+    MaxScoreDrop float64 `yaml:"MaxScoreDrop,omitempty"` // points the score may drop since the previous run in -db, 0 by default; -1 for no limit
+}
+
+// url returns the webhook to post to, or "" if none is configured
+func (c WebhookConfig) url() string {
+    if c.URL != "" {
+        return c.URL
+    }
+    if c.URLEnv != "" {
+        return os.Getenv(c.URLEnv)
+    }
+    return ""
+}
+
+// WebhookSummary is the summary a webhook receives
+type WebhookSummary struct {
+    Paths         []string       `json:"paths"`
+    Issues        int            `json:"issues"`
+    BySeverity    map[string]int `json:"by_severity"`
+    Score         float64        `json:"score"`
+    PreviousScore *float64       `json:"previous_score,omitempty"` // score of the previous run in -db
+    Breaches      []string       `json:"breaches"`
+    RunURL        string         `json:"run_url,omitempty"` // CI run that produced the issues, if known
+
+    severities severityPolicy // of the configuration that found the issues
+}
+
+// newWebhookSummary summarizes issues found in words words of paths,
+// with the score of the run before, if known
+func newWebhookSummary(paths []string, issues []Issue, words int, previous *float64, severities severityPolicy) WebhookSummary {
+    summary := WebhookSummary{
+        Paths:         paths,
+        Issues:        len(issues),
+        BySeverity:    make(map[string]int),
+        Score:         readinessScore(issues, words, severities),
+        PreviousScore: previous,
+        RunURL:        ciRunURL(),
+        severities:    severities,
+    }
+    for _, issue := range issues {
+        summary.BySeverity[issue.Severity]++
+    }
+    return summary
+}
+
+// breaches returns the thresholds summary exceeds, described for people
+func (c WebhookConfig) breaches(summary WebhookSummary) []string {
+    var breaches []string
+    if errors := summary.severities.atLeastErrors(summary.BySeverity); c.MaxErrors >= 0 && errors > c.MaxErrors {
+        breaches = append(breaches, fmt.Sprintf("%d error(s), more than MaxErrors %d", errors, c.MaxErrors))
+    }
+    if c.MinScore > 0 && summary.Score < c.MinScore {
+        breaches = append(breaches, fmt.Sprintf("score %.1f, below MinScore %.1f", summary.Score, c.MinScore))
+    }
+    if summary.PreviousScore != nil && c.MaxScoreDrop >= 0 {
+        // Scores are stored to two decimals, so smaller drops are rounding
+        if drop := *summary.PreviousScore - summary.Score; drop > c.MaxScoreDrop && drop >= 0.01 {
+            breaches = append(breaches, fmt.Sprintf("score dropped %.1f points since the previous run, more than MaxScoreDrop %.1f", drop, c.MaxScoreDrop))
+        }
+    }
+    return breaches
+}
+
+// notify posts summary to the webhook if it breaches a threshold, and
+// reports whether it did
+func (c WebhookConfig) notify(summary WebhookSummary) (bool, error) {
+    url := c.url()
+    if url == "" {
+        return false, nil
+    }
+    summary.Breaches = c.breaches(summary)
+    if len(summary.Breaches) == 0 {
+        return false, nil
+    }
+
+    text := fmt.Sprintf("ai-doc-optimizer: %s scored %.1f with %d issue(s) (%s).\nThresholds breached: %s",
+        strings.Join(summary.Paths, ", "), summary.Score, summary.Issues,
+        summary.severities.describeCounts(summary.BySeverity), strings.Join(summary.Breaches, "; "))
+    if summary.RunURL != "" {
+        text += "\n" + summary.RunURL
+    }
+    body, err := json.Marshal(struct {
+        Text    string         `json:"text"`
+        Summary WebhookSummary `json:"summary"`
+    }{text, summary})
+    if err != nil {
+        return false, err
+    }
+
+    client := &http.Client{Timeout: 30 * time.Second}
+    response, err := client.Post(url, "application/json", bytes.NewReader(body))
+    if err != nil {
+        return false, fmt.Errorf("webhook: %w", err)
+    }
+    defer response.Body.Close()
+    if response.StatusCode >= 300 {
+        return false, fmt.Errorf("webhook: %s", response.Status)
+    }
+    return true, nil
+}
+
+// ciRunURL returns the web address of the GitHub Actions or GitLab CI job
+// running the tool, or ""
+func ciRunURL() string {
+    if server, repo, run := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_RUN_ID"); server != "" && repo != "" && run != "" {
+        return fmt.Sprintf("%s/%s/actions/runs/%s", server, repo, run)
+    }
+    return os.Getenv("CI_JOB_URL")
+}